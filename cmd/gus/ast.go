@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/parser"
+)
+
+var astCommand = &command{
+	name:  "ast",
+	args:  "[--json] <file>",
+	short: "print the syntax tree of a file",
+	setup: func(fs *flag.FlagSet) runFunc {
+		asJSON := fs.Bool("json", false, "print the tree as JSON")
+		return func(args []string, stdout io.Writer) error {
+			return runAST(args, *asJSON, stdout)
+		}
+	},
+}
+
+// runAST prints the tree even when the file has syntax errors, since the
+// partial tree shows where the parser lost track.
+func runAST(args []string, asJSON bool, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	file, parseErr := parser.ParseFile(args[0], f)
+
+	print := ast.Fprint
+	if asJSON {
+		print = ast.FprintJSON
+	}
+	if err := print(stdout, file); err != nil {
+		return err
+	}
+	return parseErr
+}
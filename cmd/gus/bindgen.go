@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gusset-lang/gusset/pkg/bindgen"
+)
+
+var bindgenCommand = &command{
+	name:  "bindgen",
+	args:  "[-module name] [-package name] [-o file] <file.d.ts>",
+	short: "generate extern declarations from TypeScript declarations",
+	setup: func(fs *flag.FlagSet) runFunc {
+		var conf bindgen.Config
+		fs.StringVar(&conf.Module, "module", "", "bind to the module `name` instead of globals")
+		fs.StringVar(&conf.Package, "package", "", "declare the package `name` (default main)")
+		out := fs.String("o", "", "write the declarations to `file` instead of standard output")
+		return func(args []string, stdout io.Writer) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			return runBindgen(&conf, args[0], *out, stdout)
+		}
+	},
+}
+
+// runBindgen generates the bindings for the declaration file name and
+// writes them to the file out, or to stdout if out is "".
+func runBindgen(conf *bindgen.Config, name, out string, stdout io.Writer) error {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := conf.Generate(&buf, filepath.Base(name), src); err != nil {
+		return err
+	}
+	if out == "" {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0o644)
+}
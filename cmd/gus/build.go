@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/codegen/js"
+	"github.com/gusset-lang/gusset/pkg/parser"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+var buildCommand = &command{
+	name:  "build",
+	args:  "[-o dir] [-sourcemap mode] [-dts=false] [-enum name=item,...] [-jsx runtime] [-jsx-import-source module] [-jsx-factory name] [-jsx-fragment name] [-minify] [files]",
+	short: "compile a package to a JavaScript module",
+	setup: func(fs *flag.FlagSet) runFunc {
+		outDir := fs.String("o", "dist", "write the module to `dir`")
+		sourceMap := fs.String("sourcemap", "", "write a source map to a `file` next to the module, or inline it")
+		dts := fs.Bool("dts", true, "write the TypeScript declarations of the module next to it")
+		enums := make(enumFlag)
+		fs.Var(enums, "enum", "lower the enum `name`, or * for all, as object, inline or tagged, with the helpers string, values and parse (repeatable)")
+		minify := fs.Bool("minify", false, "minify the module, leaving out the declarations its exports and main do not use")
+		jsxRuntime := fs.String("jsx", "automatic", "compile JSX to the automatic `runtime` of React, or to the classic calls of a factory")
+		jsxImportSource := fs.String("jsx-import-source", "react", "import the JSX runtime from `module`")
+		jsxFactory := fs.String("jsx-factory", "createElement", "call the function `name` for JSX elements in the classic runtime")
+		jsxFragment := fs.String("jsx-fragment", "Fragment", "use `name` as the type of JSX fragments in the classic runtime")
+		return func(args []string, stdout io.Writer) error {
+			switch *sourceMap {
+			case "", "file", "inline":
+			default:
+				return fmt.Errorf("invalid -sourcemap %q: want file or inline", *sourceMap)
+			}
+			conf := js.Config{SourceMap: *sourceMap != "", Enums: enums, Minify: *minify}
+			switch *jsxRuntime {
+			case "automatic":
+				conf.JSX.Runtime = js.JSXAutomatic
+			case "classic":
+				conf.JSX.Runtime = js.JSXClassic
+			default:
+				return fmt.Errorf("invalid -jsx %q: want automatic or classic", *jsxRuntime)
+			}
+			conf.JSX.ImportSource = *jsxImportSource
+			conf.JSX.Factory = *jsxFactory
+			conf.JSX.Fragment = *jsxFragment
+			return runBuild(args, *outDir, &conf, *sourceMap, *dts)
+		}
+	},
+}
+
+// enumFlag holds the enum configurations given by -enum flags.
+type enumFlag map[string]js.EnumConfig
+
+func (f enumFlag) String() string { return "" }
+
+func (f enumFlag) Set(s string) error {
+	name, conf, err := js.ParseEnumConfig(s)
+	if err != nil {
+		return err
+	}
+	f[name] = conf
+	return nil
+}
+
+// runBuild compiles the files of a package with conf, by default the .gus
+// files in the current directory, to the module dir/<package>.js, with its
+// TypeScript declarations in dir/<package>.d.ts if dts is set. A source map
+// mode of "file" writes the source map of the module to
+// dir/<package>.js.map, and "inline" embeds it in the module.
+func runBuild(args []string, dir string, conf *js.Config, sourceMap string, dts bool) error {
+	if len(args) == 0 {
+		matches, err := filepath.Glob("*.gus")
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return errors.New("no .gus files in the current directory")
+		}
+		args = matches
+	}
+
+	files, err := parseFiles(args)
+	if err != nil {
+		return err
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := types.Check(files, info)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	m, err := conf.Generate(&buf, pkg, files, info)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := pkg.Name() + ".js"
+	if m != nil {
+		m.File = name
+		// the sources are found relative to the source map
+		for i, src := range m.Sources {
+			if rel, err := relPath(dir, src); err == nil {
+				m.Sources[i] = filepath.ToSlash(rel)
+			}
+		}
+		url := name + ".map"
+		if sourceMap == "inline" {
+			url = m.DataURL()
+		} else if err := os.WriteFile(filepath.Join(dir, url), m.JSON(), 0o644); err != nil {
+			return err
+		}
+		buf.WriteString("\n" + js.SourceMappingURL(url))
+	}
+	if dts {
+		var decls bytes.Buffer
+		if err := conf.Declarations(&decls, pkg); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, pkg.Name()+".d.ts"), decls.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644)
+}
+
+// relPath returns the path of the file name relative to the directory dir.
+func relPath(dir, name string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absDir, absName)
+}
+
+// parseFiles parses the named files, stopping at the first one with syntax
+// errors.
+func parseFiles(names []string) ([]*ast.File, error) {
+	var files []*ast.File
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(name, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,101 @@
+// Command gus builds Gusset programs and inspects how the compiler sees them.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errUsage is returned by commands invoked with bad arguments. The usage
+// has already been printed when it is returned.
+var errUsage = errors.New("usage")
+
+// runFunc runs a command with the arguments left after its flags.
+type runFunc func(args []string, stdout io.Writer) error
+
+type command struct {
+	name  string
+	args  string
+	short string
+	// setup registers the command's flags and returns the function that runs
+	// it once they are parsed.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = []*command{
+	tokensCommand,
+	astCommand,
+	buildCommand,
+	bindgenCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		fs := flag.NewFlagSet("gus "+cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: gus %s %s\n", cmd.name, cmd.args)
+			fs.PrintDefaults()
+		}
+		runCmd := cmd.setup(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			if err == flag.ErrHelp {
+				return 0
+			}
+			return 2
+		}
+
+		if err := runCmd(fs.Args(), stdout); err != nil {
+			if errors.Is(err, errUsage) {
+				fs.Usage()
+				return 2
+			}
+			printError(stderr, cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "gus: unknown command %q\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+// printError prints the error of the command name. The errors of a list,
+// such as the syntax or type errors of a package, are printed one per
+// line.
+func printError(w io.Writer, name string, err error) {
+	list, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		fmt.Fprintf(w, "gus %s: %s\n", name, err)
+		return
+	}
+	for _, err := range list.Unwrap() {
+		fmt.Fprintln(w, err)
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gus <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestDumpCommands(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.gus")
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		base := strings.TrimSuffix(input, ".gus")
+		for _, tc := range []struct {
+			args   []string
+			golden string
+		}{
+			{[]string{"tokens", input}, base + ".tokens.golden"},
+			{[]string{"tokens", "--json", input}, base + ".tokens.json.golden"},
+			{[]string{"ast", input}, base + ".ast.golden"},
+			{[]string{"ast", "--json", input}, base + ".ast.json.golden"},
+		} {
+			t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+				var stdout, stderr bytes.Buffer
+				code := run(tc.args, &stdout, &stderr)
+				require.Equalf(t, 0, code, "stderr: %s", stderr.String())
+
+				if *update {
+					require.NoError(t, os.WriteFile(tc.golden, stdout.Bytes(), 0o644))
+				}
+				want, err := os.ReadFile(tc.golden)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), stdout.String())
+			})
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: gus <command>")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"ast"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: gus ast [--json] <file>")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"unknown"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown command "unknown"`)
+}
+
+func TestASTSyntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tx := \n}\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 1, run([]string{"ast", path}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "BadExpr")
+	assert.Contains(t, stderr.String(), "bad.gus:5:1: expected expression, found '}'")
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\nfunc Add(x, y int) int { return x + y }\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	out := filepath.Join(dir, "out")
+	require.Equalf(t, 0, run([]string{"build", "-o", out, path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "export { Add };")
+	data, err = os.ReadFile(filepath.Join(out, "lib.d.ts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "declare function Add(x: number, y: number): number;")
+
+	stderr.Reset()
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\nvar x int = \"a\"\nvar y string = 1\nvar z = w\n"), 0o644))
+	assert.Equal(t, 1, run([]string{"build", "-o", out, path}, &stdout, &stderr))
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	require.Len(t, lines, 3, stderr.String())
+	assert.True(t, strings.HasPrefix(lines[0], path+":3:13: "), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], path+":4:16: "), lines[1])
+	assert.Equal(t, path+":5:9: undefined: w", lines[2])
+}
+
+func TestBuildSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\nfunc Add(x, y int) int { return x + y }\n"), 0o644))
+	out := filepath.Join(dir, "out")
+
+	var stdout, stderr bytes.Buffer
+	require.Equalf(t, 0, run([]string{"build", "-o", out, "-sourcemap", "file", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "\n//# sourceMappingURL=lib.js.map\n"))
+	data, err = os.ReadFile(filepath.Join(out, "lib.js.map"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"file":"lib.js","sources":["../lib.gus"]`)
+
+	require.Equalf(t, 0, run([]string{"build", "-o", out, "-sourcemap", "inline", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err = os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\n//# sourceMappingURL=data:application/json;charset=utf-8;base64,")
+
+	assert.Equal(t, 1, run([]string{"build", "-sourcemap", "external", path}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `invalid -sourcemap "external"`)
+}
+
+func TestBuildEnums(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\ntype Color enum(int) {\n\tRed\n\tGreen\n}\n\nvar C = Color.Green\n"), 0o644))
+	out := filepath.Join(dir, "out")
+
+	var stdout, stderr bytes.Buffer
+	require.Equalf(t, 0, run([]string{"build", "-o", out, "-enum", "Color=inline,string", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "let C = 1;")
+	assert.Contains(t, string(data), "String($v) {")
+	data, err = os.ReadFile(filepath.Join(out, "lib.d.ts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "type Color = 0 | 1;\ndeclare const Color: {\n  String(v: Color): string;\n};")
+
+	assert.Equal(t, 1, run([]string{"build", "-o", out, "-enum", "Shade=inline", path}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Shade is not a type of package lib")
+	assert.Equal(t, 2, run([]string{"build", "-o", out, "-enum", "Color=flat", path}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown lowering or helper "flat"`)
+}
+
+func TestBuildJSX(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\nvar V any = <p>hi</p>\n"), 0o644))
+	out := filepath.Join(dir, "out")
+
+	var stdout, stderr bytes.Buffer
+	require.Equalf(t, 0, run([]string{"build", "-o", out, "-jsx", "classic", "-jsx-import-source", "preact", "-jsx-factory", "h", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "import { h } from \"preact\";")
+	assert.Contains(t, string(data), "let V = h(\"p\", null, \"hi\");")
+
+	assert.Equal(t, 1, run([]string{"build", "-o", out, "-jsx", "preact", path}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `invalid -jsx "preact"`)
+}
+
+func TestBuildMinify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.gus")
+	require.NoError(t, os.WriteFile(path, []byte("package lib\n\nfunc unused() {}\n\nfunc Add(x, y int) int {\n\treturn x + y\n}\n"), 0o644))
+	out := filepath.Join(dir, "out")
+
+	var stdout, stderr bytes.Buffer
+	require.Equalf(t, 0, run([]string{"build", "-o", out, "-minify", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(filepath.Join(out, "lib.js"))
+	require.NoError(t, err)
+	assert.Equal(t, "// Code generated by gus from package lib. DO NOT EDIT.\nfunction Add(a,b){return a+b|0;}export{Add};\n", string(data))
+}
+
+func TestBindgen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.d.ts")
+	require.NoError(t, os.WriteFile(path, []byte("export declare function add(x: number, y: number): number;\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.Equalf(t, 0, run([]string{"bindgen", "-module", "lib", path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	assert.Equal(t, "// Code generated by gus bindgen from lib.d.ts. DO NOT EDIT.\n\npackage main\n\n"+
+		"#[extern(\"lib\")]\nfunc add(x float, y float) float\n", stdout.String())
+
+	out := filepath.Join(dir, "lib.gus")
+	require.Equalf(t, 0, run([]string{"bindgen", "-package", "lib", "-o", out, path}, &stdout, &stderr), "stderr: %s", stderr.String())
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "package lib\n\n#[extern]\nfunc add(")
+
+	assert.Equal(t, 2, run([]string{"bindgen"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: gus bindgen")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+var tokensCommand = &command{
+	name:  "tokens",
+	args:  "[--json] <file>",
+	short: "print the lexer items of a file",
+	setup: func(fs *flag.FlagSet) runFunc {
+		asJSON := fs.Bool("json", false, "print items as a JSON array")
+		return func(args []string, stdout io.Writer) error {
+			return runTokens(args, *asJSON, stdout)
+		}
+	},
+}
+
+type tokenItem struct {
+	Pos   string `json:"pos"`
+	Token string `json:"token"`
+	Text  string `json:"text"`
+}
+
+func runTokens(args []string, asJSON bool, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	items, lexErr := lexer.Items(f)
+
+	if asJSON {
+		list := make([]tokenItem, len(items))
+		for i, item := range items {
+			list[i] = tokenItem{item.Pos.String(), item.Token.String(), item.String}
+		}
+		b, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(stdout, "%s\n", b); err != nil {
+			return err
+		}
+		return lexErr
+	}
+
+	for _, item := range items {
+		if _, err := fmt.Fprintf(stdout, "%-8s %-16s %q\n", item.Pos, item.Token, item.String); err != nil {
+			return err
+		}
+	}
+	return lexErr
+}
@@ -0,0 +1,817 @@
+// Package ast declares the types used to represent Gusset syntax trees.
+package ast
+
+import (
+	"unicode"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Node is implemented by every node of the syntax tree.
+type Node interface {
+	Pos() lexer.Position
+}
+
+// Expr is implemented by expression and type nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is implemented by statement nodes.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Decl is implemented by declaration nodes.
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Spec is implemented by the specs of a GenDecl.
+type Spec interface {
+	Node
+	specNode()
+}
+
+// ----------------------------------------------------------------------------
+// Fields
+
+// Field is a named or anonymous entry of a parameter, result, struct or
+// interface list. Names is empty for anonymous entries. Type is nil for the
+// untyped parameters of an arrow function.
+type Field struct {
+	Names []*Ident
+	Type  Expr
+}
+
+func (f *Field) Pos() lexer.Position {
+	if len(f.Names) > 0 {
+		return f.Names[0].Pos()
+	}
+	if f.Type != nil {
+		return f.Type.Pos()
+	}
+	return lexer.Position{}
+}
+
+// FieldList is a list of fields enclosed by parentheses or braces.
+type FieldList struct {
+	Opening lexer.Position
+	List    []*Field
+	Closing lexer.Position
+}
+
+func (f *FieldList) Pos() lexer.Position {
+	return f.Opening
+}
+
+// NumFields returns the number of entries in the list, counting each name of
+// a grouped field separately.
+func (f *FieldList) NumFields() int {
+	if f == nil {
+		return 0
+	}
+	n := 0
+	for _, field := range f.List {
+		if len(field.Names) == 0 {
+			n++
+		} else {
+			n += len(field.Names)
+		}
+	}
+	return n
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+type (
+	// BadExpr is a placeholder for an expression that failed to parse.
+	BadExpr struct {
+		From lexer.Position
+	}
+
+	Ident struct {
+		NamePos lexer.Position
+		Name    string
+	}
+
+	// BasicLit is a literal of kind INT, FLOAT, STRING, TEMPLATE, STRUCTURED,
+	// SYMBOL, BOOL or NIL. Value holds the literal as written.
+	BasicLit struct {
+		ValuePos lexer.Position
+		Kind     lexer.Token
+		Value    string
+	}
+
+	// JSONLit is a #json literal, as in #json({"a": [1, 2]}). Value is the
+	// JSON value between its delimiters, parsed from the literal as
+	// written in Raw.
+	JSONLit struct {
+		ValuePos lexer.Position
+		Raw      string
+		Value    *JSONValue
+	}
+
+	// JSXElement is a JSX element, as in <Button label="ok">{n}</Button>.
+	// Tag is the name of the element as written, empty for fragments. Name
+	// refers to the component the element renders and is nil for
+	// intrinsic elements, whose tags start with a lower case letter, and
+	// for fragments. Children holds JSXText, JSXElement and the expressions
+	// in braces, other than empty ones, in order.
+	JSXElement struct {
+		Lt       lexer.Position
+		Tag      string
+		Name     Expr
+		Attrs    []*JSXAttr
+		Children []Expr
+	}
+
+	// JSXText is text between the tags of a JSX element. Value holds the
+	// text with its entities decoded and its line breaks and the
+	// indentation around them removed, as React does.
+	JSXText struct {
+		ValuePos lexer.Position
+		Value    string
+	}
+
+	// CompositeLit is a struct, array, slice or map literal. Type is nil for
+	// elements of an enclosing literal whose type is elided, as in []Row{{}}.
+	CompositeLit struct {
+		Type   Expr
+		Lbrace lexer.Position
+		Elts   []Expr
+		Rbrace lexer.Position
+	}
+
+	// KeyValueExpr is a `key => value` entry of a composite literal.
+	KeyValueExpr struct {
+		Key   Expr
+		Arrow lexer.Position
+		Value Expr
+	}
+
+	// TupleLit is a parenthesized list of two or more values.
+	TupleLit struct {
+		Lparen lexer.Position
+		Elts   []Expr
+		Rparen lexer.Position
+	}
+
+	// FuncLit is an arrow function. Exactly one of Body and Result is set;
+	// Result holds the implicitly returned expression of `(x) => x + 1`.
+	FuncLit struct {
+		Type   *FuncType
+		Arrow  lexer.Position
+		Body   *BlockStmt
+		Result Expr
+	}
+
+	ParenExpr struct {
+		Lparen lexer.Position
+		X      Expr
+		Rparen lexer.Position
+	}
+
+	SelectorExpr struct {
+		X   Expr
+		Sel *Ident
+	}
+
+	// IndexExpr is an index expression such as a[i], or the instantiation
+	// of a generic function or type with type arguments, as in Map[K, V].
+	IndexExpr struct {
+		X       Expr
+		Lbrack  lexer.Position
+		Indices []Expr
+		Rbrack  lexer.Position
+	}
+
+	CallExpr struct {
+		Fun    Expr
+		Lparen lexer.Position
+		Args   []Expr
+		Rparen lexer.Position
+	}
+
+	UnaryExpr struct {
+		OpPos lexer.Position
+		Op    lexer.Token
+		X     Expr
+	}
+
+	BinaryExpr struct {
+		X     Expr
+		OpPos lexer.Position
+		Op    lexer.Token
+		Y     Expr
+	}
+
+	// ListComp is a list comprehension such as `[x * 2 for x in xs if x > 0]`.
+	ListComp struct {
+		Lbrack  lexer.Position
+		Elt     Expr
+		Clauses []*CompClause
+		Rbrack  lexer.Position
+	}
+
+	// MapComp is a map comprehension such as `{k => v for k, v in m}`.
+	MapComp struct {
+		Lbrace  lexer.Position
+		Key     Expr
+		Arrow   lexer.Position
+		Value   Expr
+		Clauses []*CompClause
+		Rbrace  lexer.Position
+	}
+
+	// TupleIndexExpr selects an element of a tuple by position, as in t.0.
+	TupleIndexExpr struct {
+		X        Expr
+		IndexPos lexer.Position
+		Index    int
+	}
+
+	// WithExpr is a copy-update expression such as `p with {x => 1}`, which
+	// yields a copy of the record p with the listed fields replaced. Elts are
+	// KeyValueExprs keyed by field name.
+	WithExpr struct {
+		X      Expr
+		With   lexer.Position
+		Lbrace lexer.Position
+		Elts   []Expr
+		Rbrace lexer.Position
+	}
+
+	// MatchExpr is a `match x { pattern => body, ... }` expression. The arms
+	// are tried in order and the value of the first matching arm is the value
+	// of the match.
+	MatchExpr struct {
+		Match  lexer.Position
+		X      Expr
+		Lbrace lexer.Position
+		Arms   []*MatchArm
+		Rbrace lexer.Position
+	}
+)
+
+// CompClause is a `for vars in x if cond` clause of a comprehension. With a
+// single variable the clause ranges over the elements of a slice, array or
+// string or the keys of a map; with two it ranges over index or key and value
+// pairs. Cond is nil for clauses without a condition.
+type CompClause struct {
+	For  lexer.Position
+	Vars []*Ident
+	In   lexer.Position
+	X    Expr
+	If   lexer.Position
+	Cond Expr
+}
+
+func (c *CompClause) Pos() lexer.Position { return c.For }
+
+// JSXAttr is a `name=value` attribute of a JSX element. Value is a STRING
+// BasicLit for quoted values, the expression of braced ones and nil for
+// attributes without a value, which are true.
+type JSXAttr struct {
+	NamePos lexer.Position
+	Name    string
+	Value   Expr
+}
+
+func (a *JSXAttr) Pos() lexer.Position { return a.NamePos }
+
+// MatchArm is a `pattern if guard => body` arm of a match expression. Guard is
+// nil for arms without one. Body is a block or a simple statement.
+type MatchArm struct {
+	Pattern Pattern
+	If      lexer.Position
+	Guard   Expr
+	Arrow   lexer.Position
+	Body    Stmt
+}
+
+func (a *MatchArm) Pos() lexer.Position { return a.Pattern.Pos() }
+
+// Type expressions.
+type (
+	// ArrayType is an array type when Len is set and a slice type otherwise.
+	ArrayType struct {
+		Lbrack lexer.Position
+		Len    Expr
+		Elt    Expr
+	}
+
+	MapType struct {
+		Map   lexer.Position
+		Key   Expr
+		Value Expr
+	}
+
+	// OptionalType is a `?T` type, whose values are those of T or nil.
+	// Types are not nullable otherwise.
+	OptionalType struct {
+		Question lexer.Position
+		Elt      Expr
+	}
+
+	// FuncType is a function signature. TypeParams is nil unless the type
+	// belongs to the declaration of a generic function.
+	FuncType struct {
+		Func       lexer.Position
+		TypeParams *FieldList
+		Params     *FieldList
+		Results    *FieldList
+	}
+
+	StructType struct {
+		Struct lexer.Position
+		Fields *FieldList
+	}
+
+	// RecordType is a record type. Records are immutable values with
+	// structural identity: record types with the same field names and types
+	// are identical, and record values are equal when their fields are.
+	RecordType struct {
+		Record lexer.Position
+		Fields *FieldList
+	}
+
+	// InterfaceType is an interface type. Besides methods and embedded
+	// interfaces, Methods may hold type elements such as `~int | float`,
+	// which restrict the interface to a type set. Such interfaces may only
+	// be used as type parameter constraints.
+	InterfaceType struct {
+		Interface lexer.Position
+		Methods   *FieldList
+	}
+
+	// TupleType is a tuple type such as tuple(int, string). The results of
+	// a function with several results form a value of the corresponding
+	// tuple type, which may be stored, indexed as f().0, returned by a
+	// function with the same results, or destructured by an assignment with
+	// one variable per element.
+	TupleType struct {
+		Tuple  lexer.Position
+		Lparen lexer.Position
+		Elts   []Expr
+		Rparen lexer.Position
+	}
+
+	// EnumType is an enum type. Backing holds the types listed in parentheses
+	// after the enum keyword, as in enum(int) or enum(int, int), and is nil
+	// for enums whose variants are plain tags or carry payloads.
+	EnumType struct {
+		Enum     lexer.Position
+		Backing  []Expr
+		Lbrace   lexer.Position
+		Variants []*EnumVariant
+		Rbrace   lexer.Position
+	}
+)
+
+// EnumVariant is a variant of an enum type. Payload lists the associated data
+// of a variant such as RGB(int, int, int). Value is the explicit value of a
+// variant of a backed enum, as in First = "first"; variants of enum(int) and
+// enum(string) without one take the previous int value plus one, starting at
+// zero, or their own name.
+type EnumVariant struct {
+	Name    *Ident
+	Payload *FieldList
+	Value   Expr
+}
+
+func (v *EnumVariant) Pos() lexer.Position { return v.Name.Pos() }
+
+func (x *BadExpr) Pos() lexer.Position    { return x.From }
+func (x *Ident) Pos() lexer.Position      { return x.NamePos }
+func (x *BasicLit) Pos() lexer.Position   { return x.ValuePos }
+func (x *JSONLit) Pos() lexer.Position    { return x.ValuePos }
+func (x *JSXElement) Pos() lexer.Position { return x.Lt }
+func (x *JSXText) Pos() lexer.Position    { return x.ValuePos }
+func (x *CompositeLit) Pos() lexer.Position {
+	if x.Type != nil {
+		return x.Type.Pos()
+	}
+	return x.Lbrace
+}
+func (x *KeyValueExpr) Pos() lexer.Position   { return x.Key.Pos() }
+func (x *TupleLit) Pos() lexer.Position       { return x.Lparen }
+func (x *FuncLit) Pos() lexer.Position        { return x.Type.Pos() }
+func (x *ParenExpr) Pos() lexer.Position      { return x.Lparen }
+func (x *SelectorExpr) Pos() lexer.Position   { return x.X.Pos() }
+func (x *IndexExpr) Pos() lexer.Position      { return x.X.Pos() }
+func (x *CallExpr) Pos() lexer.Position       { return x.Fun.Pos() }
+func (x *UnaryExpr) Pos() lexer.Position      { return x.OpPos }
+func (x *BinaryExpr) Pos() lexer.Position     { return x.X.Pos() }
+func (x *ListComp) Pos() lexer.Position       { return x.Lbrack }
+func (x *MapComp) Pos() lexer.Position        { return x.Lbrace }
+func (x *TupleIndexExpr) Pos() lexer.Position { return x.X.Pos() }
+func (x *WithExpr) Pos() lexer.Position       { return x.X.Pos() }
+func (x *MatchExpr) Pos() lexer.Position      { return x.Match }
+func (x *ArrayType) Pos() lexer.Position      { return x.Lbrack }
+func (x *MapType) Pos() lexer.Position        { return x.Map }
+func (x *OptionalType) Pos() lexer.Position   { return x.Question }
+func (x *FuncType) Pos() lexer.Position {
+	if !x.Func.IsValid() && x.Params != nil {
+		return x.Params.Opening
+	}
+	return x.Func
+}
+func (x *StructType) Pos() lexer.Position    { return x.Struct }
+func (x *RecordType) Pos() lexer.Position    { return x.Record }
+func (x *InterfaceType) Pos() lexer.Position { return x.Interface }
+func (x *TupleType) Pos() lexer.Position     { return x.Tuple }
+func (x *EnumType) Pos() lexer.Position      { return x.Enum }
+
+func (*BadExpr) exprNode()        {}
+func (*Ident) exprNode()          {}
+func (*BasicLit) exprNode()       {}
+func (*JSONLit) exprNode()        {}
+func (*JSXElement) exprNode()     {}
+func (*JSXText) exprNode()        {}
+func (*CompositeLit) exprNode()   {}
+func (*KeyValueExpr) exprNode()   {}
+func (*TupleLit) exprNode()       {}
+func (*FuncLit) exprNode()        {}
+func (*ParenExpr) exprNode()      {}
+func (*SelectorExpr) exprNode()   {}
+func (*IndexExpr) exprNode()      {}
+func (*CallExpr) exprNode()       {}
+func (*UnaryExpr) exprNode()      {}
+func (*BinaryExpr) exprNode()     {}
+func (*ListComp) exprNode()       {}
+func (*MapComp) exprNode()        {}
+func (*TupleIndexExpr) exprNode() {}
+func (*WithExpr) exprNode()       {}
+func (*MatchExpr) exprNode()      {}
+func (*ArrayType) exprNode()      {}
+func (*MapType) exprNode()        {}
+func (*OptionalType) exprNode()   {}
+func (*FuncType) exprNode()       {}
+func (*StructType) exprNode()     {}
+func (*RecordType) exprNode()     {}
+func (*InterfaceType) exprNode()  {}
+func (*TupleType) exprNode()      {}
+func (*EnumType) exprNode()       {}
+
+// JSONKind is the kind of a JSON value.
+type JSONKind int
+
+const (
+	JSONNull JSONKind = iota
+	JSONBool
+	JSONNumber
+	JSONString
+	JSONArray
+	JSONObject
+)
+
+var jsonKinds = [...]string{
+	JSONNull:   "null",
+	JSONBool:   "boolean",
+	JSONNumber: "number",
+	JSONString: "string",
+	JSONArray:  "array",
+	JSONObject: "object",
+}
+
+func (k JSONKind) String() string { return jsonKinds[k] }
+
+// JSONValue is a value of a #json literal. Text holds a number as written,
+// the contents of a string or "true" or "false". The elements of an array
+// are in Elts, and the members of an object in Keys and Elts.
+type JSONValue struct {
+	Pos  lexer.Position
+	Kind JSONKind
+	Text string
+	Keys []string
+	Elts []*JSONValue
+}
+
+// ----------------------------------------------------------------------------
+// Patterns
+
+// Pattern is implemented by the pattern nodes of match arms.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+type (
+	// BadPat is a placeholder for a pattern that failed to parse.
+	BadPat struct {
+		From lexer.Position
+	}
+
+	// WildcardPat is the `_` pattern, which matches any value.
+	WildcardPat struct {
+		Underscore lexer.Position
+	}
+
+	// BindPat matches any value and binds it to Name.
+	BindPat struct {
+		Name *Ident
+	}
+
+	// LitPat matches a value equal to a literal. Value is a BasicLit or a
+	// negated numeric BasicLit.
+	LitPat struct {
+		Value Expr
+	}
+
+	// VariantPat matches an enum variant named by Path, such as Color.Red,
+	// and destructures its payload with Args. Lparen is invalid when the
+	// pattern has no argument list, in which case any payload matches. Path
+	// may also name a constant, whose value is then matched.
+	VariantPat struct {
+		Path   Expr
+		Lparen lexer.Position
+		Args   []Pattern
+		Rparen lexer.Position
+	}
+
+	// TuplePat destructures a tuple of the same length.
+	TuplePat struct {
+		Lparen lexer.Position
+		Elts   []Pattern
+		Rparen lexer.Position
+	}
+
+	// RecordPat destructures the fields of a struct or record value. Type is
+	// nil when the pattern omits it. Fields that are not listed are not
+	// matched.
+	RecordPat struct {
+		Type   Expr
+		Lbrace lexer.Position
+		Fields []*FieldPat
+		Rbrace lexer.Position
+	}
+
+	// OrPat matches when any of its alternatives, separated by `|`, matches.
+	OrPat struct {
+		Alts []Pattern
+	}
+)
+
+// FieldPat is a `name => pattern` entry of a record pattern. Pattern is nil
+// for the shorthand `name`, which binds the field to a variable of the same
+// name.
+type FieldPat struct {
+	Name    *Ident
+	Arrow   lexer.Position
+	Pattern Pattern
+}
+
+func (f *FieldPat) Pos() lexer.Position { return f.Name.Pos() }
+
+func (x *BadPat) Pos() lexer.Position      { return x.From }
+func (x *WildcardPat) Pos() lexer.Position { return x.Underscore }
+func (x *BindPat) Pos() lexer.Position     { return x.Name.Pos() }
+func (x *LitPat) Pos() lexer.Position      { return x.Value.Pos() }
+func (x *VariantPat) Pos() lexer.Position  { return x.Path.Pos() }
+func (x *TuplePat) Pos() lexer.Position    { return x.Lparen }
+func (x *RecordPat) Pos() lexer.Position {
+	if x.Type != nil {
+		return x.Type.Pos()
+	}
+	return x.Lbrace
+}
+func (x *OrPat) Pos() lexer.Position { return x.Alts[0].Pos() }
+
+func (*BadPat) patternNode()      {}
+func (*WildcardPat) patternNode() {}
+func (*BindPat) patternNode()     {}
+func (*LitPat) patternNode()      {}
+func (*VariantPat) patternNode()  {}
+func (*TuplePat) patternNode()    {}
+func (*RecordPat) patternNode()   {}
+func (*OrPat) patternNode()       {}
+
+// IsExported reports whether name starts with an upper-case letter.
+func IsExported(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+type (
+	// BadStmt is a placeholder for a statement that failed to parse.
+	BadStmt struct {
+		From lexer.Position
+	}
+
+	DeclStmt struct {
+		Decl Decl
+	}
+
+	// EmptyStmt is an empty statement, such as a lone semicolon.
+	EmptyStmt struct {
+		Semicolon lexer.Position
+	}
+
+	ExprStmt struct {
+		X Expr
+	}
+
+	// AssignStmt is an assignment, an operator assignment or a short variable
+	// declaration, depending on Tok.
+	AssignStmt struct {
+		Lhs    []Expr
+		TokPos lexer.Position
+		Tok    lexer.Token
+		Rhs    []Expr
+	}
+
+	IncDecStmt struct {
+		X      Expr
+		TokPos lexer.Position
+		Tok    lexer.Token
+	}
+
+	ReturnStmt struct {
+		Return  lexer.Position
+		Results []Expr
+	}
+
+	// BranchStmt is a break or continue statement.
+	BranchStmt struct {
+		TokPos lexer.Position
+		Tok    lexer.Token
+	}
+
+	BlockStmt struct {
+		Lbrace lexer.Position
+		List   []Stmt
+		Rbrace lexer.Position
+	}
+
+	IfStmt struct {
+		If   lexer.Position
+		Init Stmt
+		Cond Expr
+		Body *BlockStmt
+		Else Stmt
+	}
+
+	// CaseClause is a `values => body` arm of a switch. List is nil for the
+	// default arm. Body is a block or a simple statement.
+	CaseClause struct {
+		Case  lexer.Position
+		List  []Expr
+		Arrow lexer.Position
+		Body  Stmt
+	}
+
+	SwitchStmt struct {
+		Switch lexer.Position
+		Init   Stmt
+		Tag    Expr
+		Lbrace lexer.Position
+		Body   []*CaseClause
+		Rbrace lexer.Position
+	}
+
+	ForStmt struct {
+		For  lexer.Position
+		Init Stmt
+		Cond Expr
+		Post Stmt
+		Body *BlockStmt
+	}
+
+	// RangeStmt is a `for k, v := range x` loop. Key and Value may be nil.
+	RangeStmt struct {
+		For    lexer.Position
+		Key    Expr
+		Value  Expr
+		TokPos lexer.Position
+		Tok    lexer.Token
+		X      Expr
+		Body   *BlockStmt
+	}
+)
+
+func (s *BadStmt) Pos() lexer.Position    { return s.From }
+func (s *DeclStmt) Pos() lexer.Position   { return s.Decl.Pos() }
+func (s *EmptyStmt) Pos() lexer.Position  { return s.Semicolon }
+func (s *ExprStmt) Pos() lexer.Position   { return s.X.Pos() }
+func (s *AssignStmt) Pos() lexer.Position { return s.Lhs[0].Pos() }
+func (s *IncDecStmt) Pos() lexer.Position { return s.X.Pos() }
+func (s *ReturnStmt) Pos() lexer.Position { return s.Return }
+func (s *BranchStmt) Pos() lexer.Position { return s.TokPos }
+func (s *BlockStmt) Pos() lexer.Position  { return s.Lbrace }
+func (s *IfStmt) Pos() lexer.Position     { return s.If }
+func (s *CaseClause) Pos() lexer.Position { return s.Case }
+func (s *SwitchStmt) Pos() lexer.Position { return s.Switch }
+func (s *ForStmt) Pos() lexer.Position    { return s.For }
+func (s *RangeStmt) Pos() lexer.Position  { return s.For }
+
+func (*BadStmt) stmtNode()    {}
+func (*DeclStmt) stmtNode()   {}
+func (*EmptyStmt) stmtNode()  {}
+func (*ExprStmt) stmtNode()   {}
+func (*AssignStmt) stmtNode() {}
+func (*IncDecStmt) stmtNode() {}
+func (*ReturnStmt) stmtNode() {}
+func (*BranchStmt) stmtNode() {}
+func (*BlockStmt) stmtNode()  {}
+func (*IfStmt) stmtNode()     {}
+func (*CaseClause) stmtNode() {}
+func (*SwitchStmt) stmtNode() {}
+func (*ForStmt) stmtNode()    {}
+func (*RangeStmt) stmtNode()  {}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+type (
+	// ValueSpec is a single var or const spec.
+	ValueSpec struct {
+		Names  []*Ident
+		Type   Expr
+		Values []Expr
+	}
+
+	// TypeSpec is a single type spec. TypeParams is nil unless the type is
+	// generic.
+	TypeSpec struct {
+		Name       *Ident
+		TypeParams *FieldList
+		Type       Expr
+	}
+)
+
+func (s *ValueSpec) Pos() lexer.Position { return s.Names[0].Pos() }
+func (s *TypeSpec) Pos() lexer.Position  { return s.Name.Pos() }
+
+func (*ValueSpec) specNode() {}
+func (*TypeSpec) specNode()  {}
+
+type (
+	// BadDecl is a placeholder for a declaration that failed to parse.
+	BadDecl struct {
+		From lexer.Position
+	}
+
+	// GenDecl is a var, const or type declaration. Lparen and Rparen are only
+	// set for the grouped form `var (...)`. Attrs holds the attributes
+	// written before it.
+	GenDecl struct {
+		Attrs  []*Attribute
+		TokPos lexer.Position
+		Tok    lexer.Token
+		Lparen lexer.Position
+		Specs  []Spec
+		Rparen lexer.Position
+	}
+
+	// FuncDecl is a function or, when Recv is set, a method declaration.
+	// Attrs holds the attributes written before it.
+	FuncDecl struct {
+		Attrs []*Attribute
+		Recv  *FieldList
+		Name  *Ident
+		Type  *FuncType
+		Body  *BlockStmt
+	}
+)
+
+// Attribute is an annotation such as `#[must_use]` on its own line before a
+// declaration. Args holds the values of the string literals in parentheses
+// after the name, as in `#[extern("react")]`.
+type Attribute struct {
+	Hash lexer.Position
+	Name string
+	Args []string
+}
+
+func (a *Attribute) Pos() lexer.Position { return a.Hash }
+
+func (d *BadDecl) Pos() lexer.Position  { return d.From }
+func (d *GenDecl) Pos() lexer.Position  { return d.TokPos }
+func (d *FuncDecl) Pos() lexer.Position { return d.Type.Pos() }
+
+func (*BadDecl) declNode()  {}
+func (*GenDecl) declNode()  {}
+func (*FuncDecl) declNode() {}
+
+// ----------------------------------------------------------------------------
+// Files
+
+// File is a parsed Gusset source file.
+type File struct {
+	Filename string
+	Package  lexer.Position
+	Name     *Ident
+	Decls    []Decl
+}
+
+func (f *File) Pos() lexer.Position { return f.Package }
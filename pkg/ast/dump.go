@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Fprint writes an indented, human readable rendering of the tree rooted at
+// node to w. Nodes are printed by type name with their fields in declaration
+// order, tokens by name and positions as line:column.
+func Fprint(w io.Writer, node Node) error {
+	var buf bytes.Buffer
+	printValue(&buf, dumpValue(reflect.ValueOf(node)), 0)
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// FprintJSON writes the tree rooted at node to w as indented JSON. Every node
+// is an object whose "node" key holds the type name, followed by its fields
+// in declaration order, so the output is stable enough to snapshot.
+func FprintJSON(w io.Writer, node Node) error {
+	b, err := json.MarshalIndent(dumpValue(reflect.ValueOf(node)), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+type dumpField struct {
+	key   string
+	value any
+}
+
+// dumpObject is a node rendering that keeps its fields in order.
+type dumpObject []dumpField
+
+func (o dumpObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// dumpRaw marks positions and tokens so the text printer does not quote them.
+type dumpRaw string
+
+var (
+	positionType = reflect.TypeOf(lexer.Position{})
+	tokenType    = reflect.TypeOf(lexer.Token(0))
+	jsonKindType = reflect.TypeOf(JSONKind(0))
+)
+
+// dumpValue converts v into a tree of dumpObject, []any and scalar values.
+func dumpValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return dumpValue(v.Elem())
+	}
+
+	switch v.Type() {
+	case positionType:
+		pos := v.Interface().(lexer.Position)
+		if !pos.IsValid() {
+			return nil
+		}
+		return dumpRaw(pos.String())
+	case tokenType:
+		return dumpRaw(v.Interface().(lexer.Token).String())
+	case jsonKindType:
+		return dumpRaw(v.Interface().(JSONKind).String())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		obj := dumpObject{{"node", v.Type().Name()}}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			obj = append(obj, dumpField{field.Name, dumpValue(v.Field(i))})
+		}
+		return obj
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = dumpValue(v.Index(i))
+		}
+		return list
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	default:
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
+
+func printValue(buf *bytes.Buffer, value any, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("nil")
+	case dumpObject:
+		buf.WriteString(v[0].value.(string))
+		buf.WriteString(" {\n")
+		for _, f := range v[1:] {
+			buf.WriteString(indent)
+			buf.WriteString(f.key)
+			buf.WriteString(": ")
+			printValue(buf, f.value, depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent[2:])
+		buf.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, elt := range v {
+			buf.WriteString(indent)
+			buf.WriteString(strconv.Itoa(i))
+			buf.WriteString(": ")
+			printValue(buf, elt, depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent[2:])
+		buf.WriteByte(']')
+	case string:
+		buf.WriteString(strconv.Quote(v))
+	case dumpRaw:
+		buf.WriteString(string(v))
+	default:
+		fmt.Fprint(buf, v)
+	}
+}
@@ -0,0 +1,219 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// ExprString returns a compact source-like rendering of x for use in
+// diagnostics. Function bodies and composite literal elements are elided.
+func ExprString(x Expr) string {
+	var b strings.Builder
+	writeExpr(&b, x)
+	return b.String()
+}
+
+func writeExprList(b *strings.Builder, list []Expr) {
+	for i, x := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeExpr(b, x)
+	}
+}
+
+func writeFieldList(b *strings.Builder, list *FieldList, sep string) {
+	if list == nil {
+		return
+	}
+	for i, f := range list.List {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		for j, name := range f.Names {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(name.Name)
+		}
+		if f.Type != nil {
+			if len(f.Names) > 0 {
+				b.WriteByte(' ')
+			}
+			writeExpr(b, f.Type)
+		}
+	}
+}
+
+func writeSignature(b *strings.Builder, t *FuncType) {
+	if t.TypeParams != nil {
+		b.WriteByte('[')
+		writeFieldList(b, t.TypeParams, ", ")
+		b.WriteByte(']')
+	}
+	b.WriteByte('(')
+	writeFieldList(b, t.Params, ", ")
+	b.WriteByte(')')
+	if t.Results == nil || len(t.Results.List) == 0 {
+		return
+	}
+	b.WriteByte(' ')
+	if len(t.Results.List) == 1 && len(t.Results.List[0].Names) == 0 {
+		writeExpr(b, t.Results.List[0].Type)
+		return
+	}
+	b.WriteByte('(')
+	writeFieldList(b, t.Results, ", ")
+	b.WriteByte(')')
+}
+
+func writeClauses(b *strings.Builder, clauses []*CompClause) {
+	for _, c := range clauses {
+		b.WriteString(" for ")
+		for i, v := range c.Vars {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(v.Name)
+		}
+		b.WriteString(" in ")
+		writeExpr(b, c.X)
+		if c.Cond != nil {
+			b.WriteString(" if ")
+			writeExpr(b, c.Cond)
+		}
+	}
+}
+
+func writeExpr(b *strings.Builder, x Expr) {
+	switch x := x.(type) {
+	case nil:
+	case *BadExpr:
+		b.WriteString("BadExpr")
+	case *Ident:
+		b.WriteString(x.Name)
+	case *BasicLit:
+		b.WriteString(x.Value)
+	case *JSONLit:
+		b.WriteString(x.Raw)
+	case *JSXElement:
+		b.WriteString("<" + x.Tag + ">…</" + x.Tag + ">")
+	case *JSXText:
+		b.WriteString(x.Value)
+	case *CompositeLit:
+		writeExpr(b, x.Type)
+		b.WriteString("{…}")
+	case *KeyValueExpr:
+		writeExpr(b, x.Key)
+		b.WriteString(" => ")
+		writeExpr(b, x.Value)
+	case *TupleLit:
+		b.WriteByte('(')
+		writeExprList(b, x.Elts)
+		b.WriteByte(')')
+	case *FuncLit:
+		writeSignature(b, x.Type)
+		b.WriteString(" => {…}")
+	case *ParenExpr:
+		b.WriteByte('(')
+		writeExpr(b, x.X)
+		b.WriteByte(')')
+	case *SelectorExpr:
+		writeExpr(b, x.X)
+		b.WriteByte('.')
+		b.WriteString(x.Sel.Name)
+	case *IndexExpr:
+		writeExpr(b, x.X)
+		b.WriteByte('[')
+		writeExprList(b, x.Indices)
+		b.WriteByte(']')
+	case *CallExpr:
+		writeExpr(b, x.Fun)
+		b.WriteByte('(')
+		writeExprList(b, x.Args)
+		b.WriteByte(')')
+	case *UnaryExpr:
+		b.WriteString(lexer.Sequence(x.Op))
+		writeExpr(b, x.X)
+	case *BinaryExpr:
+		writeExpr(b, x.X)
+		b.WriteString(" " + lexer.Sequence(x.Op) + " ")
+		writeExpr(b, x.Y)
+	case *ListComp:
+		b.WriteByte('[')
+		writeExpr(b, x.Elt)
+		writeClauses(b, x.Clauses)
+		b.WriteByte(']')
+	case *MapComp:
+		b.WriteByte('{')
+		writeExpr(b, x.Key)
+		b.WriteString(" => ")
+		writeExpr(b, x.Value)
+		writeClauses(b, x.Clauses)
+		b.WriteByte('}')
+	case *TupleIndexExpr:
+		writeExpr(b, x.X)
+		b.WriteString("." + strconv.Itoa(x.Index))
+	case *WithExpr:
+		writeExpr(b, x.X)
+		b.WriteString(" with {…}")
+	case *MatchExpr:
+		b.WriteString("match ")
+		writeExpr(b, x.X)
+		b.WriteString(" {…}")
+	case *ArrayType:
+		b.WriteByte('[')
+		writeExpr(b, x.Len)
+		b.WriteByte(']')
+		writeExpr(b, x.Elt)
+	case *MapType:
+		b.WriteString("map[")
+		writeExpr(b, x.Key)
+		b.WriteByte(']')
+		writeExpr(b, x.Value)
+	case *OptionalType:
+		b.WriteByte('?')
+		writeExpr(b, x.Elt)
+	case *FuncType:
+		b.WriteString("func")
+		writeSignature(b, x)
+	case *StructType:
+		b.WriteString("struct{")
+		writeFieldList(b, x.Fields, "; ")
+		b.WriteByte('}')
+	case *RecordType:
+		b.WriteString("record{")
+		writeFieldList(b, x.Fields, "; ")
+		b.WriteByte('}')
+	case *InterfaceType:
+		b.WriteString("interface{")
+		for i, m := range x.Methods.List {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			if ft, ok := m.Type.(*FuncType); ok && len(m.Names) > 0 {
+				b.WriteString(m.Names[0].Name)
+				writeSignature(b, ft)
+				continue
+			}
+			writeExpr(b, m.Type)
+		}
+		b.WriteByte('}')
+	case *TupleType:
+		b.WriteString("tuple(")
+		writeExprList(b, x.Elts)
+		b.WriteByte(')')
+	case *EnumType:
+		b.WriteString("enum")
+		if x.Backing != nil {
+			b.WriteByte('(')
+			writeExprList(b, x.Backing)
+			b.WriteByte(')')
+		}
+		b.WriteString("{…}")
+	default:
+		b.WriteString("?")
+	}
+}
@@ -0,0 +1,373 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Visitor is called by Walk for each node. If the returned visitor is not nil,
+// Walk visits the children of the node with it and then calls it with nil.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. Children are skipped when f returns false. After the children
+// of a node are visited, f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+func walkIdents(v Visitor, list []*Ident) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+func walkExprs(v Visitor, list []Expr) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+func walkStmts(v Visitor, list []Stmt) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+func walkPatterns(v Visitor, list []Pattern) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+// Walk traverses the tree rooted at node in depth-first order.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Field:
+		walkIdents(v, n.Names)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+
+	case *FieldList:
+		for _, f := range n.List {
+			Walk(v, f)
+		}
+
+	// Expressions
+	case *BadExpr, *Ident, *BasicLit, *JSONLit, *JSXText:
+		// nothing to do
+
+	case *JSXElement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for _, a := range n.Attrs {
+			Walk(v, a)
+		}
+		walkExprs(v, n.Children)
+
+	case *JSXAttr:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *CompositeLit:
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		walkExprs(v, n.Elts)
+
+	case *KeyValueExpr:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+
+	case *TupleLit:
+		walkExprs(v, n.Elts)
+
+	case *FuncLit:
+		Walk(v, n.Type)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+
+	case *ParenExpr:
+		Walk(v, n.X)
+
+	case *SelectorExpr:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	case *IndexExpr:
+		Walk(v, n.X)
+		walkExprs(v, n.Indices)
+
+	case *CallExpr:
+		Walk(v, n.Fun)
+		walkExprs(v, n.Args)
+
+	case *UnaryExpr:
+		Walk(v, n.X)
+
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+
+	case *ListComp:
+		Walk(v, n.Elt)
+		for _, c := range n.Clauses {
+			Walk(v, c)
+		}
+
+	case *MapComp:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+		for _, c := range n.Clauses {
+			Walk(v, c)
+		}
+
+	case *CompClause:
+		walkIdents(v, n.Vars)
+		Walk(v, n.X)
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+
+	case *TupleIndexExpr:
+		Walk(v, n.X)
+
+	case *WithExpr:
+		Walk(v, n.X)
+		walkExprs(v, n.Elts)
+
+	case *MatchExpr:
+		Walk(v, n.X)
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+
+	case *MatchArm:
+		Walk(v, n.Pattern)
+		if n.Guard != nil {
+			Walk(v, n.Guard)
+		}
+		Walk(v, n.Body)
+
+	// Types
+	case *ArrayType:
+		if n.Len != nil {
+			Walk(v, n.Len)
+		}
+		Walk(v, n.Elt)
+
+	case *MapType:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+
+	case *OptionalType:
+		Walk(v, n.Elt)
+
+	case *FuncType:
+		if n.TypeParams != nil {
+			Walk(v, n.TypeParams)
+		}
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+		if n.Results != nil {
+			Walk(v, n.Results)
+		}
+
+	case *StructType:
+		Walk(v, n.Fields)
+
+	case *RecordType:
+		Walk(v, n.Fields)
+
+	case *InterfaceType:
+		Walk(v, n.Methods)
+
+	case *TupleType:
+		walkExprs(v, n.Elts)
+
+	case *EnumType:
+		walkExprs(v, n.Backing)
+		for _, variant := range n.Variants {
+			Walk(v, variant)
+		}
+
+	case *EnumVariant:
+		Walk(v, n.Name)
+		if n.Payload != nil {
+			Walk(v, n.Payload)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	// Patterns
+	case *BadPat, *WildcardPat:
+		// nothing to do
+
+	case *BindPat:
+		Walk(v, n.Name)
+
+	case *LitPat:
+		Walk(v, n.Value)
+
+	case *VariantPat:
+		Walk(v, n.Path)
+		walkPatterns(v, n.Args)
+
+	case *TuplePat:
+		walkPatterns(v, n.Elts)
+
+	case *RecordPat:
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		for _, f := range n.Fields {
+			Walk(v, f)
+		}
+
+	case *FieldPat:
+		Walk(v, n.Name)
+		if n.Pattern != nil {
+			Walk(v, n.Pattern)
+		}
+
+	case *OrPat:
+		walkPatterns(v, n.Alts)
+
+	// Statements
+	case *BadStmt, *EmptyStmt, *BranchStmt:
+		// nothing to do
+
+	case *DeclStmt:
+		Walk(v, n.Decl)
+
+	case *ExprStmt:
+		Walk(v, n.X)
+
+	case *AssignStmt:
+		walkExprs(v, n.Lhs)
+		walkExprs(v, n.Rhs)
+
+	case *IncDecStmt:
+		Walk(v, n.X)
+
+	case *ReturnStmt:
+		walkExprs(v, n.Results)
+
+	case *BlockStmt:
+		walkStmts(v, n.List)
+
+	case *IfStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+
+	case *CaseClause:
+		walkExprs(v, n.List)
+		Walk(v, n.Body)
+
+	case *SwitchStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Tag != nil {
+			Walk(v, n.Tag)
+		}
+		for _, c := range n.Body {
+			Walk(v, c)
+		}
+
+	case *ForStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		if n.Post != nil {
+			Walk(v, n.Post)
+		}
+		Walk(v, n.Body)
+
+	case *RangeStmt:
+		if n.Key != nil {
+			Walk(v, n.Key)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		Walk(v, n.X)
+		Walk(v, n.Body)
+
+	// Declarations
+	case *ValueSpec:
+		walkIdents(v, n.Names)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		walkExprs(v, n.Values)
+
+	case *TypeSpec:
+		Walk(v, n.Name)
+		if n.TypeParams != nil {
+			Walk(v, n.TypeParams)
+		}
+		Walk(v, n.Type)
+
+	case *BadDecl:
+		// nothing to do
+
+	case *GenDecl:
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *FuncDecl:
+		if n.Recv != nil {
+			Walk(v, n.Recv)
+		}
+		Walk(v, n.Name)
+		Walk(v, n.Type)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *File:
+		Walk(v, n.Name)
+		for _, d := range n.Decls {
+			Walk(v, d)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
@@ -0,0 +1,911 @@
+// Package bindgen generates Gusset extern declarations from TypeScript
+// declaration files, so that programs calling into JavaScript libraries
+// are type-checked against their typings.
+//
+// The declarations of a .d.ts file are bound as follows:
+//
+//   - functions are extern functions without bodies
+//   - variables are extern variables
+//   - classes and interfaces with properties are extern struct types, whose
+//     fields are the properties and whose methods, also without bodies, the
+//     methods of the class or interface; members inherited from types
+//     declared in the same file are copied into them
+//   - the constructor of a class is a function NewC marked #[new], and its
+//     static members are functions and variables C_name
+//   - interfaces with only methods are interfaces
+//   - type aliases of string or integer literal unions and enums are extern
+//     enums, whose values are the literals
+//   - type aliases of object literal types are extern struct types, and
+//     other type aliases types with the bound type as underlying type
+//
+// TypeScript types map to the Gusset type closest to them: number to
+// float, bigint to int64, arrays to slices, tuples to tuples, maps to maps,
+// function types to function types, unions with null or undefined to
+// optional types, and string and number literal types to string and float.
+// Types that cannot be expressed, such as other unions, intersections,
+// object literal types and mapped and conditional types, are any, as are
+// references to types the file does not declare. Type parameters are
+// unconstrained; methods cannot have them, so those of methods are any.
+//
+// Declarations are annotated with attributes:
+//
+//   - #[extern("module")] binds a function or variable to the export of the
+//     module with its name, and #[extern] binds it to the global with its
+//     name, or a type to JavaScript objects
+//   - #[js("name")] gives the JavaScript name of a declaration the binding
+//     renames, which is a dotted path for static members
+//   - #[new] calls the bound function with new
+//   - #[optional("p", ...)] lists the trailing parameters callers may omit
+//   - #[rest] spreads the last parameter, a slice, into the arguments
+//   - #[this] passes the first parameter, named this, as this
+//
+// Names that are reserved words in Gusset get a trailing underscore: fields
+// of extern types named that way bind to the property without it, and
+// other renamed declarations have a js attribute.
+//
+// The members of a namespace that the module exports with export =, as
+// the typings of CommonJS libraries such as React do, are bound as exports
+// of the module, and a function, variable or class exported that way binds
+// to the default export. Declarations the binding cannot express, such as
+// other namespaces, exports of other declarations, overloads after the
+// first and members whose names are not identifiers, are listed in a
+// comment at the start of the generated file.
+package bindgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// Config configures the generation of bindings.
+type Config struct {
+	// Module is the module the declarations of the file are imported from.
+	// If it is "", they bind to globals, except for the declarations of
+	// `declare module` blocks.
+	Module string
+	// Package is the package of the generated file, main if "".
+	Package string
+}
+
+// Generate writes the bindings for the declaration file filename with the
+// source src to w.
+func Generate(w io.Writer, filename string, src []byte) error {
+	return new(Config).Generate(w, filename, src)
+}
+
+// Generate writes the bindings for the declaration file filename with the
+// source src to w.
+func (conf *Config) Generate(w io.Writer, filename string, src []byte) error {
+	p, err := parse(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:%w", filename, err)
+	}
+	b := &binder{
+		conf:    conf,
+		types:   make(map[string]*tsDecl),
+		names:   make(map[string]string),
+		used:    make(map[string]bool),
+		skipped: p.skipped,
+	}
+	decls := b.merge(b.bindable(p.decls, p.namespaces, p.exports))
+
+	pkg := conf.Package
+	if pkg == "" {
+		pkg = "main"
+	}
+	fmt.Fprintf(&b.out, "// Code generated by gus bindgen from %s. DO NOT EDIT.\n\npackage %s\n", filename, pkg)
+	var body bytes.Buffer
+	b.out, body = body, b.out
+	for _, d := range decls {
+		b.decl(d)
+	}
+	b.out, body = body, b.out
+	if len(b.skipped) > 0 {
+		b.out.WriteString("\n// Not bound:\n")
+		for _, s := range b.skipped {
+			b.out.WriteString("//   - " + s + "\n")
+		}
+	}
+	b.out.Write(body.Bytes())
+	_, err = w.Write(b.out.Bytes())
+	return err
+}
+
+type binder struct {
+	conf    *Config
+	out     bytes.Buffer
+	types   map[string]*tsDecl // the declared types by name
+	names   map[string]string  // the Gusset names of the declared types
+	used    map[string]bool    // the Gusset names taken at package level
+	skipped []string
+
+	tparams map[string]string // the bound type parameters in scope
+	this    string            // the bound type of this, or ""
+}
+
+// bindable returns the declarations that bind: those outside namespaces
+// and those of a namespace that a module exports with export =, which are
+// exports of the module. A function, variable or class that a module
+// exports with export = binds to its default export, which is the value
+// of module.exports where modules are CommonJS.
+func (b *binder) bindable(decls []*tsDecl, namespaces []tsNamespace, exports []tsExport) []*tsDecl {
+	exported := make(map[string]string) // by module
+	for _, e := range exports {
+		module := e.module
+		if module == "" {
+			module = b.conf.Module
+		}
+		found := false
+		for _, d := range decls {
+			if d.module == e.module && (d.namespace == e.name || d.namespace == "" && d.name == e.name && isValue(d)) {
+				found = true
+			}
+		}
+		if module == "" || !found {
+			b.skipf("export = %s at line %d", e.name, e.line)
+			continue
+		}
+		exported[e.module] = e.name
+	}
+	for _, ns := range namespaces {
+		// namespaces nested in skipped ones are not listed
+		outer := ""
+		if i := strings.LastIndexByte(ns.name, '.'); i >= 0 {
+			outer = ns.name[:i]
+		}
+		name := exported[ns.module]
+		if ns.name != name && (outer == "" || outer == name) {
+			b.skipf("namespace %s", ns.name)
+		}
+	}
+
+	var list []*tsDecl
+	for _, d := range decls {
+		name, ok := exported[d.module]
+		switch {
+		case d.namespace == "":
+			if ok && d.name == name && isValue(d) {
+				d.js = "default"
+			}
+		case !ok || d.namespace != name:
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+// isValue reports whether d declares a value: a function, a variable or
+// a class.
+func isValue(d *tsDecl) bool {
+	return d.kind == funcDecl || d.kind == varDecl || d.kind == classDecl
+}
+
+// merge returns the declarations to bind, merging the members of
+// interfaces declared several times, or with a class, into the first
+// declaration and dropping the overloads of functions. It names the types.
+func (b *binder) merge(decls []*tsDecl) []*tsDecl {
+	var list []*tsDecl
+	funcs := make(map[string]bool)
+	for _, d := range decls {
+		switch d.kind {
+		case funcDecl:
+			if funcs[d.module+" "+d.name] {
+				b.skipf("overload of %s at line %d", d.name, d.line)
+				continue
+			}
+			funcs[d.module+" "+d.name] = true
+		case interfaceDecl, classDecl, aliasDecl, enumDecl:
+			if prev := b.types[d.name]; prev != nil {
+				if (prev.kind == interfaceDecl || prev.kind == classDecl) && d.kind == interfaceDecl {
+					prev.members = append(prev.members, d.members...)
+					prev.extends = append(prev.extends, d.extends...)
+					continue
+				}
+				if prev.kind == interfaceDecl && d.kind == classDecl {
+					d.members = append(d.members, prev.members...)
+					*prev = *d
+					continue
+				}
+				b.skipf("redeclaration of %s at line %d", d.name, d.line)
+				continue
+			}
+			b.types[d.name] = d
+			b.names[d.name] = b.name(d.name)
+			if d.namespace != "" {
+				// members of the namespace may refer to each other
+				// by their qualified names
+				b.types[d.namespace+"."+d.name] = d
+				b.names[d.namespace+"."+d.name] = b.names[d.name]
+			}
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+func (b *binder) skipf(format string, args ...any) {
+	b.skipped = append(b.skipped, fmt.Sprintf(format, args...))
+}
+
+// name returns an unused package-level name for the JavaScript name js,
+// which does not shadow a predeclared one, and takes it.
+func (b *binder) name(js string) string {
+	name := identifier(js)
+	for name == "_" || b.used[name] || types.Universe.Lookup(name) != nil {
+		name += "_"
+	}
+	b.used[name] = true
+	return name
+}
+
+// identifier returns a Gusset identifier for the JavaScript identifier
+// name: characters identifiers cannot have are replaced by underscores,
+// and reserved words get a trailing one.
+func identifier(name string) string {
+	id := []rune(name)
+	for i, r := range id {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			id[i] = '_'
+		}
+	}
+	name = string(id)
+	if lexer.IsReserved(name) {
+		name += "_"
+	}
+	return name
+}
+
+// isIdentifier reports whether name is a JavaScript identifier that Gusset
+// identifiers can name, possibly with a trailing underscore. The blank
+// identifier names nothing.
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != "" && name != "_"
+}
+
+func (b *binder) line(format string, args ...any) {
+	fmt.Fprintf(&b.out, format+"\n", args...)
+}
+
+// attrs writes the attributes binding a function or variable named js
+// whose Gusset name is name.
+func (b *binder) attrs(d *tsDecl, js, name string) {
+	module := d.module
+	if module == "" && !d.global {
+		module = b.conf.Module
+	}
+	if module == "" {
+		b.line("#[extern]")
+	} else {
+		b.line("#[extern(%s)]", strconv.Quote(module))
+	}
+	if js != name {
+		b.line("#[js(%s)]", strconv.Quote(js))
+	}
+}
+
+func (b *binder) decl(d *tsDecl) {
+	b.tparams = make(map[string]string)
+	b.this = ""
+	switch d.kind {
+	case funcDecl:
+		b.out.WriteByte('\n')
+		name := b.name(d.name)
+		b.attrs(d, d.jsName(), name)
+		tparams := b.typeParams(d.tparams)
+		attrs, sig := b.signature(d.params, d.result)
+		b.out.WriteString(attrs)
+		b.line("func %s%s%s", name, tparams, sig)
+	case varDecl:
+		b.out.WriteByte('\n')
+		name := b.name(d.name)
+		b.attrs(d, d.jsName(), name)
+		b.line("var %s %s", name, b.typ(d.result))
+	case interfaceDecl:
+		b.interfaceDecl(d)
+	case classDecl:
+		b.class(d)
+	case aliasDecl:
+		b.alias(d)
+	case enumDecl:
+		b.enum(d)
+	}
+}
+
+// typeParams returns the type parameter list for names, which it brings
+// into scope in place of any others.
+func (b *binder) typeParams(names []string) string {
+	b.tparams = make(map[string]string)
+	if len(names) == 0 {
+		return ""
+	}
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = identifier(name)
+		b.tparams[name] = list[i]
+	}
+	return "[" + strings.Join(list, ", ") + " any]"
+}
+
+// typeArgs returns the type arguments instantiating a generic type
+// declared with the type parameters names, or "".
+func typeArgs(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = identifier(name)
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// signature returns the parameters and result of a function, and the
+// attributes they need, each on its own line. The parameters do not take
+// the names in taken.
+func (b *binder) signature(params []*tsParam, result tsType, taken ...string) (attrs, sig string) {
+	var list, optional []string
+	names := make(map[string]bool)
+	for _, name := range taken {
+		names[name] = true
+	}
+	for _, name := range b.tparams {
+		names[name] = true
+	}
+	for i, p := range params {
+		name := identifier(p.name)
+		switch {
+		case p.name == "this" && i == 0:
+			attrs += "#[this]\n"
+		case p.name == "" || name == "_":
+			name = fmt.Sprintf("arg%d", i)
+		}
+		for names[name] {
+			name += "_"
+		}
+		names[name] = true
+		typ := b.typ(p.typ)
+		if p.rest {
+			if !strings.HasPrefix(typ, "[]") {
+				typ = "[]any"
+			}
+			attrs += "#[rest]\n"
+		} else if p.optional {
+			optional = append(optional, strconv.Quote(name))
+		}
+		list = append(list, name+" "+typ)
+	}
+	if len(optional) > 0 {
+		attrs += "#[optional(" + strings.Join(optional, ", ") + ")]\n"
+	}
+	sig = "(" + strings.Join(list, ", ") + ")"
+	if res := b.result(result); res != "" {
+		sig += " " + res
+	}
+	return attrs, sig
+}
+
+// result returns the result type of a signature, or "" if it has none.
+func (b *binder) result(t tsType) string {
+	if ref, ok := t.(tsRef); ok && (ref.name == "void" || ref.name == "undefined" || ref.name == "never") {
+		return ""
+	}
+	if t == nil {
+		return ""
+	}
+	return b.typ(t)
+}
+
+// funcType returns the function type for a TypeScript function type.
+func (b *binder) funcType(fn tsFunc) string {
+	list := make([]string, len(fn.params))
+	for i, p := range fn.params {
+		list[i] = b.typ(p.typ)
+		if p.rest && !strings.HasPrefix(list[i], "[]") {
+			list[i] = "[]any"
+		}
+	}
+	typ := "func(" + strings.Join(list, ", ") + ")"
+	if res := b.result(fn.result); res != "" {
+		typ += " " + res
+	}
+	return typ
+}
+
+var basicTypes = map[string]string{
+	"number": "float", "string": "string", "boolean": "bool", "bigint": "int64",
+	"String": "string", "Number": "float", "Boolean": "bool",
+}
+
+// typ returns the Gusset type for a TypeScript type.
+func (b *binder) typ(t tsType) string {
+	switch t := t.(type) {
+	case tsRef:
+		return b.ref(t)
+	case tsArray:
+		return "[]" + b.typ(t.elem)
+	case tsTuple:
+		if len(t.elems) < 2 {
+			return "any"
+		}
+		list := make([]string, len(t.elems))
+		for i, elem := range t.elems {
+			list[i] = b.typ(elem)
+		}
+		return "tuple(" + strings.Join(list, ", ") + ")"
+	case tsUnion:
+		return b.union(t)
+	case tsLiteral:
+		if t.kind == tokString {
+			return "string"
+		}
+		return "float"
+	case tsFunc:
+		return b.funcType(t)
+	}
+	return "any"
+}
+
+func (b *binder) ref(t tsRef) string {
+	if basic, ok := basicTypes[t.name]; ok {
+		return basic
+	}
+	if name, ok := b.tparams[t.name]; ok && len(t.args) == 0 {
+		return name
+	}
+	switch t.name {
+	case "this":
+		if b.this != "" {
+			return b.this
+		}
+	case "Array", "ReadonlyArray":
+		if len(t.args) == 1 {
+			return "[]" + b.typ(t.args[0])
+		}
+	case "Map", "ReadonlyMap":
+		if len(t.args) == 2 {
+			// Gusset maps are JavaScript maps with primitive keys
+			switch key := b.typ(t.args[0]); key {
+			case "float", "string", "bool":
+				return "map[" + key + "]" + b.typ(t.args[1])
+			}
+		}
+	}
+	d := b.types[t.name]
+	if d == nil || len(d.tparams) != len(t.args) {
+		return "any"
+	}
+	name := b.names[t.name]
+	if len(t.args) == 0 {
+		return name
+	}
+	list := make([]string, len(t.args))
+	for i, arg := range t.args {
+		list[i] = b.typ(arg)
+	}
+	return name + "[" + strings.Join(list, ", ") + "]"
+}
+
+// union returns the type for a union: an optional type if it is a type or
+// null or undefined, and a basic type if its members are literals of one
+// kind.
+func (b *binder) union(t tsUnion) string {
+	var types []tsType
+	for _, u := range t.types {
+		if ref, ok := u.(tsRef); ok && (ref.name == "null" || ref.name == "undefined") {
+			continue
+		}
+		types = append(types, u)
+	}
+	typ := "any"
+	switch {
+	case len(types) == 1:
+		typ = b.typ(types[0])
+	case len(types) > 1:
+		kind := literalKind(types[0])
+		for _, u := range types[1:] {
+			if literalKind(u) != kind {
+				kind = ""
+			}
+		}
+		if kind != "" {
+			typ = kind
+		}
+	}
+	if len(types) < len(t.types) {
+		return optional(typ)
+	}
+	return typ
+}
+
+// literalKind returns the basic type of a literal type, or "" if t is not
+// one. Booleans are unions of the literals true and false.
+func literalKind(t tsType) string {
+	switch t := t.(type) {
+	case tsLiteral:
+		if t.kind == tokString {
+			return "string"
+		}
+		return "float"
+	case tsRef:
+		if t.name == "boolean" {
+			return "bool"
+		}
+	}
+	return ""
+}
+
+// optional returns the optional type of typ.
+func optional(typ string) string {
+	if typ == "any" || strings.HasPrefix(typ, "?") {
+		return typ
+	}
+	return "?" + typ
+}
+
+// receiver returns the receiver name for the methods of the type name.
+func receiver(name string) string {
+	r := strings.ToLower(name[:1])
+	if !isIdentifier(r) || lexer.IsReserved(r) {
+		return "x"
+	}
+	return r
+}
+
+// member is a member of an interface or a class with the bound type
+// parameters in scope for it, which differ from those of the type for
+// members inherited from generic types.
+type member struct {
+	*tsMember
+	tparams map[string]string
+}
+
+// collect returns the members of d and of the types it extends, declared in
+// the same file, leaving out the members with the names of earlier ones.
+// The type parameters of d must be in scope.
+func (b *binder) collect(d *tsDecl) []member {
+	var members []member
+	seen := make(map[string]bool)
+	visited := make(map[*tsDecl]bool)
+	var visit func(d *tsDecl, tparams map[string]string)
+	visit = func(d *tsDecl, tparams map[string]string) {
+		if visited[d] {
+			return
+		}
+		visited[d] = true
+		for _, m := range d.members {
+			if m.kind == ctorMember || m.static {
+				continue
+			}
+			if !seen[m.name] {
+				seen[m.name] = true
+				members = append(members, member{m, tparams})
+			}
+		}
+		for _, t := range d.extends {
+			ref, ok := t.(tsRef)
+			base := b.types[ref.name]
+			if !ok || base == nil {
+				continue
+			}
+			// the type parameters of the base are bound to its type
+			// arguments
+			scope := make(map[string]string)
+			for i, name := range base.tparams {
+				scope[name] = "any"
+				if i < len(ref.args) {
+					b.tparams = tparams
+					scope[name] = b.typ(ref.args[i])
+				}
+			}
+			visit(base, scope)
+		}
+	}
+	outer := b.tparams
+	visit(d, outer)
+	b.tparams = outer
+	return members
+}
+
+// scope brings the type parameters in scope for the member m into scope,
+// with those of m itself as any.
+func (b *binder) scope(m member) {
+	b.tparams = make(map[string]string)
+	for name, typ := range m.tparams {
+		b.tparams[name] = typ
+	}
+	for _, name := range m.tsMember.tparams {
+		b.tparams[name] = "any"
+	}
+}
+
+func (b *binder) interfaceDecl(d *tsDecl) {
+	tparams := b.typeParams(d.tparams)
+	members := b.collect(d)
+	methodsOnly := len(members) > 0
+	for _, m := range members {
+		if m.kind != methodMember || !isIdentifier(m.name) || lexer.IsReserved(m.name) {
+			methodsOnly = false
+		}
+	}
+	if !methodsOnly {
+		b.object(d, members)
+		return
+	}
+
+	name := b.names[d.name]
+	b.out.WriteByte('\n')
+	b.line("type %s%s interface {", name, tparams)
+	b.this = name + typeArgs(d.tparams)
+	seen := make(map[string]bool)
+	for _, m := range members {
+		if seen[m.name] {
+			continue // an overload
+		}
+		seen[m.name] = true
+		b.scope(m)
+		_, sig := b.signature(m.params, m.result)
+		b.line("\t%s%s", m.name, sig)
+	}
+	b.line("}")
+}
+
+// object writes the extern struct type for the interface or class d, with
+// the given members, followed by its methods.
+func (b *binder) object(d *tsDecl, members []member) {
+	name := b.names[d.name]
+	b.out.WriteByte('\n')
+	b.line("#[extern]")
+	b.line("type %s%s struct {", name, b.typeParams(d.tparams))
+	b.this = name + typeArgs(d.tparams)
+	var methods []member
+	for _, m := range members {
+		if !isIdentifier(m.name) {
+			b.skipf("member %q of %s", m.name, d.name)
+			continue
+		}
+		if m.kind == methodMember {
+			methods = append(methods, m)
+			continue
+		}
+		b.scope(m)
+		typ := b.typ(m.typ)
+		if m.optional {
+			typ = optional(typ)
+		}
+		b.line("\t%s %s", identifier(m.name), typ)
+	}
+	b.line("}")
+
+	recv := receiver(name)
+	seen := make(map[string]bool)
+	for _, m := range methods {
+		if seen[m.name] {
+			b.skipf("overload of %s.%s", d.name, m.name)
+			continue
+		}
+		seen[m.name] = true
+		b.out.WriteByte('\n')
+		mname := identifier(m.name)
+		if mname != m.name {
+			b.line("#[js(%s)]", strconv.Quote(m.name))
+		}
+		b.scope(m)
+		params := m.params
+		if len(params) > 0 && params[0].name == "this" {
+			// the type of the receiver
+			params = params[1:]
+		}
+		attrs, sig := b.signature(params, m.result, recv)
+		b.out.WriteString(attrs)
+		b.line("func (%s %s) %s%s", recv, b.this, mname, sig)
+	}
+}
+
+func (b *binder) class(d *tsDecl) {
+	tparams := b.typeParams(d.tparams)
+	b.object(d, b.collect(d))
+	typ := b.this
+	b.typeParams(d.tparams)
+
+	var ctor *tsMember
+	for base := d; base != nil && ctor == nil; {
+		for _, m := range base.members {
+			if m.kind == ctorMember {
+				ctor = m
+				break
+			}
+		}
+		base = b.base(base)
+	}
+	if ctor == nil {
+		ctor = &tsMember{kind: ctorMember}
+	}
+	b.out.WriteByte('\n')
+	name := b.name("New" + b.names[d.name])
+	b.attrs(d, d.jsName(), name)
+	b.line("#[new]")
+	attrs, sig := b.signature(ctor.params, nil)
+	b.out.WriteString(attrs)
+	b.line("func %s%s%s %s", name, tparams, sig, typ)
+
+	// static members are not generic
+	statics := make(map[string]string)
+	for _, name := range d.tparams {
+		statics[name] = "any"
+	}
+	seen := make(map[string]bool)
+	for _, m := range d.members {
+		if !m.static {
+			continue
+		}
+		if seen[m.name] || !isIdentifier(m.name) {
+			continue
+		}
+		seen[m.name] = true
+		b.out.WriteByte('\n')
+		b.scope(member{m, statics})
+		name := b.name(b.names[d.name] + "_" + m.name)
+		b.attrs(d, d.jsName()+"."+m.name, name)
+		if m.kind == propMember {
+			typ := b.typ(m.typ)
+			if m.optional {
+				typ = optional(typ)
+			}
+			b.line("var %s %s", name, typ)
+			continue
+		}
+		attrs, sig := b.signature(m.params, m.result)
+		b.out.WriteString(attrs)
+		b.line("func %s%s", name, sig)
+	}
+}
+
+// base returns the class the class d extends, if it is declared in the
+// same file.
+func (b *binder) base(d *tsDecl) *tsDecl {
+	for _, t := range d.extends {
+		if ref, ok := t.(tsRef); ok {
+			if base := b.types[ref.name]; base != nil && base.kind == classDecl && base != d {
+				return base
+			}
+		}
+	}
+	return nil
+}
+
+func (b *binder) alias(d *tsDecl) {
+	name := b.names[d.name]
+	if obj, ok := d.result.(tsObject); ok && len(obj.members) > 0 {
+		b.typeParams(d.tparams)
+		members := make([]member, len(obj.members))
+		for i, m := range obj.members {
+			members[i] = member{m, b.tparams}
+		}
+		b.object(d, members)
+		return
+	}
+	if union, ok := d.result.(tsUnion); ok && len(d.tparams) == 0 {
+		var values []*tsEnumMember
+		for _, u := range union.types {
+			lit, ok := u.(tsLiteral)
+			if !ok {
+				values = nil
+				break
+			}
+			values = append(values, &tsEnumMember{value: lit})
+		}
+		if values != nil && b.enumValues(name, values) {
+			return
+		}
+	}
+	b.out.WriteByte('\n')
+	tparams := b.typeParams(d.tparams)
+	if ref, ok := d.result.(tsRef); ok && b.tparams[ref.name] != "" {
+		// A type declaration cannot denote its type parameter.
+		b.line("type %s%s any", name, tparams)
+		return
+	}
+	b.line("type %s%s %s", name, tparams, b.typ(d.result))
+}
+
+func (b *binder) enum(d *tsDecl) {
+	if !b.enumValues(b.names[d.name], d.values) {
+		b.skipf("enum %s, whose values are not all integers or all strings", d.name)
+	}
+}
+
+// enumValues writes the extern enum type name for the values of an enum
+// or a literal union, whose members without names are named after their
+// values. It reports false, writing nothing, if the values are neither all
+// integers nor all strings.
+func (b *binder) enumValues(name string, values []*tsEnumMember) bool {
+	backing := ""
+	for _, v := range values {
+		kind := "int"
+		if v.value != nil {
+			lit, ok := v.value.(tsLiteral)
+			if !ok {
+				return false
+			}
+			if lit.kind == tokString {
+				kind = "string"
+			} else if _, err := strconv.ParseInt(lit.value, 0, 64); err != nil {
+				return false
+			}
+		} else if backing == "string" {
+			return false
+		}
+		if backing != "" && kind != backing {
+			return false
+		}
+		backing = kind
+	}
+
+	b.out.WriteByte('\n')
+	b.line("#[extern]")
+	b.line("type %s enum(%s) {", name, backing)
+	seen := make(map[string]bool)
+	for _, v := range values {
+		variant := v.name
+		if variant == "" {
+			variant = variantName(v.value.(tsLiteral).value)
+		}
+		variant = identifier(variant)
+		for seen[variant] {
+			variant += "_"
+		}
+		seen[variant] = true
+		switch lit := v.value.(type) {
+		case tsLiteral:
+			value := lit.value
+			if lit.kind == tokString {
+				value = strconv.Quote(value)
+			}
+			b.line("\t%s = %s", variant, value)
+		default:
+			b.line("\t%s", variant)
+		}
+	}
+	b.line("}")
+	return true
+}
+
+// variantName returns the name of the variant for a literal value: the
+// value in camel case starting with an upper case letter, so that
+// "top-left" is TopLeft and -1 is Neg1.
+func variantName(value string) string {
+	var name strings.Builder
+	upper := true
+	if strings.HasPrefix(value, "-") {
+		name.WriteString("Neg")
+	}
+	for _, r := range value {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			name.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	if name.Len() == 0 || unicode.IsDigit([]rune(name.String())[0]) {
+		return "V" + name.String()
+	}
+	return name.String()
+}
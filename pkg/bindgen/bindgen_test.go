@@ -0,0 +1,395 @@
+package bindgen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "// Code generated by gus bindgen from test.d.ts. DO NOT EDIT.\n\npackage main\n"
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name   string
+		module string
+		src    string
+		want   string
+	}{
+		{
+			name:   "functions",
+			module: "date-fns",
+			src: `import { Locale } from "./locale";
+
+export declare function format(date: Date | number, pattern: string, options?: { locale?: Locale }): string;
+export declare function format(date: string): string;
+export declare function max<T>(...values: T[]): T | undefined;
+export declare function isValid(value: unknown): value is Date;
+export default format;
+`,
+			want: `
+// Not bound:
+//   - default export at line 7
+//   - overload of format at line 4
+
+#[extern("date-fns")]
+#[optional("options")]
+func format(date any, pattern string, options any) string
+
+#[extern("date-fns")]
+#[rest]
+func max[T any](values []T) ?T
+
+#[extern("date-fns")]
+func isValid(value any) bool
+`,
+		},
+		{
+			name: "globals",
+			src: `declare var document: Document;
+declare let match: RegExp, version: string;
+declare function addEventListener(this: Window, type: string, listener: (ev: Event) => void): void;
+interface Document {
+	title: string;
+	getElementById(elementId: string): HTMLElement | null;
+}
+`,
+			want: `
+#[extern]
+var document Document
+
+#[extern]
+#[js("match")]
+var match_ any
+
+#[extern]
+var version string
+
+#[extern]
+#[this]
+func addEventListener(this any, type_ string, listener func(any))
+
+#[extern]
+type Document struct {
+	title string
+}
+
+func (d Document) getElementById(elementId string) any
+`,
+		},
+		{
+			name:   "interfaces",
+			module: "shapes",
+			src: `export interface Shape {
+	area(): number;
+	scale(by: number): this;
+}
+export interface Point {
+	x: number;
+	label?: string | null;
+	default: boolean;
+	"aria-label": string;
+	[key: string]: unknown;
+	move(dx: number, dy?: number): this;
+	match(pattern: string): boolean;
+}
+export interface Point3 extends Point { z: number }
+`,
+			want: `
+// Not bound:
+//   - member "aria-label" of Point
+//   - member "aria-label" of Point3
+
+type Shape interface {
+	area() float
+	scale(by float) Shape
+}
+
+#[extern]
+type Point struct {
+	x float
+	label ?string
+	default_ bool
+}
+
+#[optional("dy")]
+func (p Point) move(dx float, dy float) Point
+
+#[js("match")]
+func (p Point) match_(pattern string) bool
+
+#[extern]
+type Point3 struct {
+	z float
+	x float
+	label ?string
+	default_ bool
+}
+
+#[optional("dy")]
+func (p Point3) move(dx float, dy float) Point3
+
+#[js("match")]
+func (p Point3) match_(pattern string) bool
+`,
+		},
+		{
+			name:   "classes",
+			module: "events",
+			src: `export declare class Emitter<T> {
+	constructor(name: string, capacity?: number);
+	private secret;
+	#hidden: number;
+	readonly name: string;
+	static create<T>(): Emitter<T>;
+	static readonly defaultCapacity: number;
+	emit(value: T): void;
+	on(listener: (value: T) => void): () => void;
+	get size(): number;
+	entries(): Array<[string, T]>;
+	lookup(key: string): Map<string, T[]>;
+}
+export declare class Timer extends Emitter<number> {
+	start(): void;
+}
+`,
+			want: `
+#[extern]
+type Emitter[T any] struct {
+	name string
+	size float
+}
+
+func (e Emitter[T]) emit(value T)
+
+func (e Emitter[T]) on(listener func(T)) func()
+
+func (e Emitter[T]) entries() []tuple(string, T)
+
+func (e Emitter[T]) lookup(key string) map[string][]T
+
+#[extern("events")]
+#[js("Emitter")]
+#[new]
+#[optional("capacity")]
+func NewEmitter[T any](name string, capacity float) Emitter[T]
+
+#[extern("events")]
+#[js("Emitter.create")]
+func Emitter_create() Emitter[any]
+
+#[extern("events")]
+#[js("Emitter.defaultCapacity")]
+var Emitter_defaultCapacity float
+
+#[extern]
+type Timer struct {
+	name string
+	size float
+}
+
+func (t Timer) start()
+
+func (t Timer) emit(value float)
+
+func (t Timer) on(listener func(float)) func()
+
+func (t Timer) entries() []tuple(string, float)
+
+func (t Timer) lookup(key string) map[string][]float
+
+#[extern("events")]
+#[js("Timer")]
+#[new]
+#[optional("capacity")]
+func NewTimer(name string, capacity float) Timer
+`,
+		},
+		{
+			name: "aliases and enums",
+			src: `type Align = "left" | "right" | "top-left";
+type Code = 200 | 404 | -1;
+type Id = string | number;
+type Handler = (event: Event, detail?: string) => void;
+type Options = { timeout?: number; label: string };
+type Partial<T> = { [K in keyof T]?: T[K] };
+type Identity<T> = T;
+declare function int64(value: string): bigint;
+declare enum Level { Debug, Info = 5, Warn }
+declare const enum Mode { Fast = "fast", Slow = "slow" }
+declare enum Mixed { A = 1, B = "b" }
+`,
+			want: `
+// Not bound:
+//   - enum Mixed, whose values are not all integers or all strings
+
+#[extern]
+type Align enum(string) {
+	Left = "left"
+	Right = "right"
+	TopLeft = "top-left"
+}
+
+#[extern]
+type Code enum(int) {
+	V200 = 200
+	V404 = 404
+	Neg1 = -1
+}
+
+type Id any
+
+type Handler func(any, string)
+
+#[extern]
+type Options struct {
+	timeout ?float
+	label string
+}
+
+type Partial[T any] any
+
+type Identity[T any] any
+
+#[extern]
+#[js("int64")]
+func int64_(value string) int64
+
+#[extern]
+type Level enum(int) {
+	Debug
+	Info = 5
+	Warn
+}
+
+#[extern]
+type Mode enum(string) {
+	Fast = "fast"
+	Slow = "slow"
+}
+`,
+		},
+		{
+			name:   "modules",
+			module: "app",
+			src: `declare namespace Internal {
+	function hidden(): void;
+}
+declare module "other" {
+	export function helper(n: bigint): [number, string];
+}
+declare global {
+	interface Window { title: string }
+	var app: string;
+}
+export declare function run(): Promise<void>;
+`,
+			want: `
+// Not bound:
+//   - namespace Internal
+
+#[extern("other")]
+func helper(n int64) tuple(float, string)
+
+#[extern]
+type Window struct {
+	title string
+}
+
+#[extern]
+var app string
+
+#[extern("app")]
+func run() any
+`,
+		},
+		{
+			name:   "export assignment",
+			module: "react",
+			src: `export = React;
+export as namespace React;
+
+declare namespace React {
+	type Key = string | number;
+	interface Attributes { key?: Key | null }
+	interface ReactElement { type: string }
+	function createElement(type: string, props?: React.Attributes | null): ReactElement;
+	const version: string;
+	namespace Children {
+		function count(children: any): number;
+	}
+}
+declare namespace Other {
+	function other(): void;
+}
+export * from "./hooks";
+export { Other };
+`,
+			want: `
+// Not bound:
+//   - export as namespace React at line 2
+//   - export * at line 17
+//   - export list at line 18
+//   - namespace React.Children
+//   - namespace Other
+
+type Key any
+
+#[extern]
+type Attributes struct {
+	key ?Key
+}
+
+#[extern]
+type ReactElement struct {
+	type_ string
+}
+
+#[extern("react")]
+#[optional("props")]
+func createElement(type_ string, props ?Attributes) ReactElement
+
+#[extern("react")]
+var version string
+`,
+		},
+		{
+			name:   "export assignment of a function",
+			module: "express",
+			src: `declare function e(): e.Express;
+declare namespace e {
+	interface Express { listen(port: number): void }
+	var json: () => void;
+}
+export = e;
+`,
+			want: `
+#[extern("express")]
+#[js("default")]
+func e() Express
+
+type Express interface {
+	listen(port float)
+}
+
+#[extern("express")]
+var json func()
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			conf := &Config{Module: tt.module}
+			require.NoError(t, conf.Generate(&out, "test.d.ts", []byte(tt.src)))
+			assert.Equal(t, header+tt.want, out.String())
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	var out bytes.Buffer
+	err := Generate(&out, "test.d.ts", []byte("declare const s: string;\n/* open"))
+	assert.EqualError(t, err, "test.d.ts:2:1: comment not terminated")
+}
@@ -0,0 +1,931 @@
+package bindgen
+
+import (
+	"fmt"
+)
+
+// The parser reads the declarations of a .d.ts file that bindings can be
+// generated for and skips the rest: imports, exports of other names and
+// any statement it does not understand. The declarations of namespaces
+// are read with the name of their namespace, and export = statements,
+// which export a namespace or another declaration as the whole module,
+// are kept for the binder to decide what binds. Types it does not
+// understand, such as mapped and conditional types, are parsed as tsOther.
+
+// tsType is a TypeScript type.
+type tsType interface{}
+
+type (
+	// tsRef is a type name, such as number, Date or Array<T>.
+	tsRef struct {
+		name string
+		args []tsType
+	}
+
+	tsArray struct{ elem tsType }
+
+	tsTuple struct{ elems []tsType }
+
+	tsUnion struct{ types []tsType }
+
+	// tsLiteral is a literal type, such as "left" or 1. The value of a
+	// string literal is unquoted.
+	tsLiteral struct {
+		kind  tokenKind
+		value string
+	}
+
+	tsFunc struct {
+		params []*tsParam
+		result tsType
+	}
+
+	// tsObject is an object literal type. Mapped types are objects without
+	// members.
+	tsObject struct{ members []*tsMember }
+
+	// tsOther is a type bindings cannot express.
+	tsOther struct{}
+)
+
+type tsParam struct {
+	name     string
+	typ      tsType
+	optional bool
+	rest     bool
+}
+
+// memberKind is the kind of a member of an interface or a class.
+type memberKind int
+
+const (
+	propMember memberKind = iota
+	methodMember
+	ctorMember
+)
+
+type tsMember struct {
+	kind     memberKind
+	name     string
+	static   bool
+	optional bool
+	tparams  []string
+	typ      tsType // of a property
+	params   []*tsParam
+	result   tsType
+}
+
+// declKind is the kind of a declaration.
+type declKind int
+
+const (
+	funcDecl declKind = iota
+	varDecl
+	interfaceDecl
+	classDecl
+	aliasDecl
+	enumDecl
+)
+
+// tsDecl is a declaration of a .d.ts file. Module is the module of a
+// `declare module` block holding it, if any, and global is set for the
+// declarations of a `declare global` block. Namespace is the dotted name
+// of the namespace declaring it, if any.
+type tsDecl struct {
+	kind      declKind
+	line      int
+	name      string
+	js        string // the name of the export it binds to, if not name
+	module    string
+	global    bool
+	namespace string
+	tparams   []string
+	extends   []tsType
+	members   []*tsMember
+	params    []*tsParam
+	result    tsType // of a function, the type of a variable or an alias
+	values    []*tsEnumMember
+}
+
+// jsName returns the JavaScript name of the binding of d.
+func (d *tsDecl) jsName() string {
+	if d.js != "" {
+		return d.js
+	}
+	return d.name
+}
+
+// A tsNamespace is a namespace of a .d.ts file, whose name is dotted if it
+// is nested in another.
+type tsNamespace struct {
+	name   string
+	module string
+}
+
+// A tsExport is an export = statement, which exports the namespace or
+// declaration name of module as the whole module.
+type tsExport struct {
+	name   string
+	module string
+	line   int
+}
+
+type tsEnumMember struct {
+	name  string
+	value tsType // a tsLiteral, or nil to follow the previous value
+}
+
+type parser struct {
+	toks       []token
+	tok        token
+	i          int
+	module     string
+	global     bool
+	namespace  string
+	decls      []*tsDecl
+	namespaces []tsNamespace
+	exports    []tsExport
+	skipped    []string // notes on what was skipped, for comments
+}
+
+// parse parses the source of a declaration file.
+func parse(src string) (*parser, error) {
+	toks, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, tok: toks[0]}
+	p.statements(false)
+	return p, nil
+}
+
+func (p *parser) next() {
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	p.tok = p.toks[p.i]
+}
+
+func (p *parser) peek(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+// is reports whether the current token is the punctuation or identifier s.
+func (p *parser) is(s string) bool {
+	return (p.tok.kind == tokPunct || p.tok.kind == tokIdent) && p.tok.text == s
+}
+
+// got consumes the current token if it is s.
+func (p *parser) got(s string) bool {
+	if p.is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// ident consumes an identifier and returns it, or "" if the current token
+// is not one.
+func (p *parser) ident() string {
+	if p.tok.kind != tokIdent {
+		return ""
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) skipf(format string, args ...any) {
+	p.skipped = append(p.skipped, fmt.Sprintf(format, args...))
+}
+
+// statements parses statements up to the end of the file or, in a block,
+// its closing brace.
+func (p *parser) statements(block bool) {
+	for p.tok.kind != tokEOF {
+		if block && p.is("}") {
+			p.next()
+			return
+		}
+		i := p.i
+		p.statement()
+		if p.i == i {
+			p.next() // a stray closing bracket
+		}
+	}
+}
+
+func (p *parser) statement() {
+	start := p.tok
+	if p.got(";") {
+		return
+	}
+	if p.is("export") {
+		p.next()
+		switch {
+		case p.got("="):
+			p.exportAssign(start)
+			return
+		case p.is("default"):
+			p.skipf("default export at line %d", start.line)
+		case p.is("{") && p.peek(1).text != "}":
+			p.skipf("export list at line %d", start.line)
+		case p.is("*"):
+			p.skipf("export * at line %d", start.line)
+		case p.is("as"):
+			p.skipf("export as namespace %s at line %d", p.peek(2).text, start.line)
+		}
+		if p.is("default") || p.is("{") || p.is("*") || p.is("as") {
+			p.skipStatement()
+			return
+		}
+	}
+	if p.is("import") {
+		p.skipStatement()
+		return
+	}
+	p.got("declare")
+
+	switch {
+	case p.is("function"):
+		p.next()
+		p.function(start)
+	case p.is("const") && p.peek(1).text == "enum":
+		p.next()
+		p.next()
+		p.enum(start)
+	case p.is("const") || p.is("let") || p.is("var"):
+		p.next()
+		p.variables(start)
+	case p.is("interface"):
+		p.next()
+		p.interfaceDecl(start)
+	case p.is("abstract") && p.peek(1).text == "class":
+		p.next()
+		p.next()
+		p.class(start)
+	case p.is("class"):
+		p.next()
+		p.class(start)
+	case p.is("type"):
+		p.next()
+		p.alias(start)
+	case p.is("enum"):
+		p.next()
+		p.enum(start)
+	case p.is("module") && p.peek(1).kind == tokString:
+		p.next()
+		module := p.tok.text
+		p.next()
+		if !p.got("{") {
+			p.skipStatement()
+			return
+		}
+		outer, global := p.module, p.global
+		p.module, p.global = module, false
+		p.statements(true)
+		p.module, p.global = outer, global
+	case p.is("global") && p.peek(1).text == "{":
+		p.next()
+		p.next()
+		outer := p.global
+		p.global = true
+		p.statements(true)
+		p.global = outer
+	case p.is("namespace") || p.is("module"):
+		p.next()
+		p.namespaceDecl(start)
+	default:
+		if p.tok.kind == tokIdent {
+			p.skipf("statement at line %d", start.line)
+		}
+		p.skipStatement()
+	}
+}
+
+// exportAssign parses the rest of an export = statement.
+func (p *parser) exportAssign(start token) {
+	name := p.ident()
+	if name == "" || p.is(".") {
+		p.skipf("export = at line %d", start.line)
+		p.skipStatement()
+		return
+	}
+	p.exports = append(p.exports, tsExport{name: name, module: p.module, line: start.line})
+	p.end()
+}
+
+// namespaceDecl parses the rest of a namespace declaration, whose
+// declarations it reads with the name of the namespace.
+func (p *parser) namespaceDecl(start token) {
+	name := p.ident()
+	for name != "" && p.got(".") {
+		if next := p.ident(); next != "" {
+			name += "." + next
+		}
+	}
+	if name == "" || !p.got("{") {
+		p.skipf("statement at line %d", start.line)
+		p.skipStatement()
+		return
+	}
+	outer := p.namespace
+	if outer != "" {
+		name = outer + "." + name
+	}
+	p.namespaces = append(p.namespaces, tsNamespace{name: name, module: p.module})
+	p.namespace = name
+	p.statements(true)
+	p.namespace = outer
+}
+
+// skipStatement skips to the end of the current statement: a semicolon or a
+// closing brace outside brackets, or a line break before a token that
+// starts a declaration.
+func (p *parser) skipStatement() {
+	depth := 0
+	line := p.tok.line
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.is("{") || p.is("(") || p.is("["):
+			depth++
+		case p.is("}") || p.is(")") || p.is("]"):
+			if depth == 0 {
+				return // the end of the enclosing block
+			}
+			depth--
+			if depth == 0 && p.is("}") && p.peek(1).line > p.tok.line {
+				p.next()
+				return
+			}
+		case depth == 0 && p.is(";"):
+			p.next()
+			return
+		case depth == 0 && p.tok.line > line && startsDecl[p.tok.text]:
+			return
+		}
+		p.next()
+	}
+}
+
+var startsDecl = map[string]bool{
+	"export": true, "declare": true, "import": true, "function": true, "interface": true,
+	"class": true, "type": true, "enum": true, "const": true, "let": true, "var": true,
+}
+
+// newDecl returns a declaration of the given kind starting at start and
+// named by the current identifier, which it consumes. The name is "" if the
+// current token is not an identifier.
+func (p *parser) newDecl(kind declKind, start token) *tsDecl {
+	return &tsDecl{kind: kind, line: start.line, name: p.ident(), module: p.module, global: p.global, namespace: p.namespace}
+}
+
+// end consumes the semicolon ending a declaration, if any.
+func (p *parser) end() {
+	p.got(";")
+}
+
+func (p *parser) function(start token) {
+	d := p.newDecl(funcDecl, start)
+	if d.name == "" {
+		p.skipStatement()
+		return
+	}
+	d.tparams = p.typeParams()
+	d.params = p.params()
+	d.result = p.resultType()
+	p.end()
+	p.decls = append(p.decls, d)
+}
+
+func (p *parser) variables(start token) {
+	for {
+		d := p.newDecl(varDecl, start)
+		if d.name == "" {
+			p.skipStatement()
+			return
+		}
+		d.result = tsOther{}
+		if p.got(":") {
+			d.result = p.typ()
+		}
+		if p.is("=") {
+			p.skipStatement()
+			p.decls = append(p.decls, d)
+			return
+		}
+		p.decls = append(p.decls, d)
+		if !p.got(",") {
+			break
+		}
+	}
+	p.end()
+}
+
+func (p *parser) interfaceDecl(start token) {
+	d := p.newDecl(interfaceDecl, start)
+	if d.name == "" {
+		p.skipStatement()
+		return
+	}
+	d.tparams = p.typeParams()
+	if p.got("extends") {
+		d.extends = p.typeList()
+	}
+	if !p.is("{") {
+		p.skipStatement()
+		return
+	}
+	d.members = p.members()
+	p.decls = append(p.decls, d)
+}
+
+func (p *parser) class(start token) {
+	d := p.newDecl(classDecl, start)
+	if d.name == "" {
+		p.skipStatement()
+		return
+	}
+	d.tparams = p.typeParams()
+	if p.got("extends") {
+		d.extends = p.typeList()
+	}
+	if p.got("implements") {
+		p.typeList()
+	}
+	if !p.is("{") {
+		p.skipStatement()
+		return
+	}
+	d.members = p.members()
+	p.decls = append(p.decls, d)
+}
+
+func (p *parser) alias(start token) {
+	d := p.newDecl(aliasDecl, start)
+	if d.name == "" {
+		p.skipStatement()
+		return
+	}
+	d.tparams = p.typeParams()
+	if !p.got("=") {
+		p.skipStatement()
+		return
+	}
+	d.result = p.typ()
+	p.end()
+	p.decls = append(p.decls, d)
+}
+
+func (p *parser) enum(start token) {
+	d := p.newDecl(enumDecl, start)
+	if d.name == "" || !p.got("{") {
+		p.skipStatement()
+		return
+	}
+	for !p.is("}") && p.tok.kind != tokEOF {
+		m := &tsEnumMember{name: p.tok.text}
+		p.next()
+		if p.got("=") {
+			m.value = p.literal()
+			if m.value == nil {
+				// a computed value
+				m.value = tsOther{}
+				for !p.is(",") && !p.is("}") && p.tok.kind != tokEOF {
+					p.next()
+				}
+			}
+		}
+		d.values = append(d.values, m)
+		if !p.got(",") {
+			break
+		}
+	}
+	if !p.got("}") {
+		p.skipStatement()
+		return
+	}
+	p.decls = append(p.decls, d)
+}
+
+// typeParams parses the names of the type parameters of a declaration, if
+// it has any. Their constraints and defaults are skipped.
+func (p *parser) typeParams() []string {
+	if !p.got("<") {
+		return nil
+	}
+	var names []string
+	for !p.is(">") && p.tok.kind != tokEOF {
+		p.got("const")
+		p.got("in")
+		p.got("out")
+		names = append(names, p.ident())
+		if p.got("extends") {
+			p.typ()
+		}
+		if p.got("=") {
+			p.typ()
+		}
+		if !p.got(",") {
+			break
+		}
+	}
+	p.got(">")
+	return names
+}
+
+func (p *parser) typeList() []tsType {
+	list := []tsType{p.typ()}
+	for p.got(",") {
+		list = append(list, p.typ())
+	}
+	return list
+}
+
+// members parses the braced members of an interface or a class. Private
+// and protected members, index and call signatures and members with
+// computed names are skipped.
+func (p *parser) members() []*tsMember {
+	p.got("{")
+	var members []*tsMember
+	for !p.is("}") && p.tok.kind != tokEOF {
+		if p.got(";") || p.got(",") {
+			continue
+		}
+		i := p.i
+		if m := p.member(); m != nil {
+			members = append(members, m)
+		}
+		if p.i == i {
+			p.next() // a stray closing bracket
+		}
+	}
+	p.got("}")
+	return members
+}
+
+var modifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "static": true, "readonly": true,
+	"abstract": true, "declare": true, "override": true, "accessor": true, "async": true,
+}
+
+func (p *parser) member() *tsMember {
+	m := &tsMember{}
+	hidden := false
+	accessor := ""
+	// a modifier followed by a name, a ? or a signature is a modifier
+	for p.tok.kind == tokIdent {
+		next := p.peek(1)
+		if next.kind != tokIdent && next.kind != tokString && next.text != "[" && next.text != "#" {
+			break
+		}
+		switch {
+		case modifiers[p.tok.text]:
+			hidden = hidden || p.tok.text == "private" || p.tok.text == "protected"
+			m.static = m.static || p.tok.text == "static"
+		case p.tok.text == "get" || p.tok.text == "set":
+			accessor = p.tok.text
+		default:
+			p.skipMember()
+			return nil
+		}
+		p.next()
+	}
+
+	switch {
+	case p.tok.kind == tokIdent || p.tok.kind == tokString || p.tok.kind == tokNumber:
+		m.name = p.tok.text
+		p.next()
+	case p.is("#"):
+		hidden = true
+		p.next()
+		p.next()
+	case p.is("(") || p.is("<") || p.is("["):
+		// call and index signatures and computed names
+		p.skipMember()
+		return nil
+	default:
+		p.skipMember()
+		return nil
+	}
+	if m.name == "new" && (p.is("(") || p.is("<")) {
+		p.skipMember() // construct signature
+		return nil
+	}
+	m.optional = p.got("?")
+	p.got("!")
+
+	switch {
+	case p.is("(") || p.is("<"):
+		m.kind = methodMember
+		if m.name == "constructor" {
+			m.kind = ctorMember
+		}
+		m.tparams = p.typeParams()
+		m.params = p.params()
+		m.result = p.resultType()
+		switch accessor {
+		case "get":
+			m.kind, m.typ = propMember, m.result
+		case "set":
+			m.kind, m.typ = propMember, tsType(tsOther{})
+			if len(m.params) == 1 {
+				m.typ = m.params[0].typ
+			}
+		}
+	default:
+		m.kind = propMember
+		m.typ = tsOther{}
+		if p.got(":") {
+			m.typ = p.typ()
+		}
+		if p.got("=") {
+			p.literal()
+		}
+	}
+	if !p.is("}") {
+		p.got(";")
+		p.got(",")
+	}
+	if hidden {
+		return nil
+	}
+	return m
+}
+
+// skipMember skips to the end of the current member.
+func (p *parser) skipMember() {
+	depth := 0
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.is("{") || p.is("(") || p.is("[") || p.is("<"):
+			depth++
+		case p.is("}") || p.is(")") || p.is("]") || p.is(">"):
+			if depth == 0 {
+				return
+			}
+			depth--
+		case depth == 0 && (p.is(";") || p.is(",")):
+			p.next()
+			return
+		}
+		p.next()
+	}
+}
+
+// params parses a parenthesized parameter list.
+func (p *parser) params() []*tsParam {
+	if !p.got("(") {
+		return nil
+	}
+	var params []*tsParam
+	for !p.is(")") && p.tok.kind != tokEOF {
+		for modifiers[p.tok.text] && p.peek(1).kind == tokIdent {
+			p.next()
+		}
+		param := &tsParam{rest: p.got("...")}
+		if p.tok.kind == tokIdent {
+			param.name = p.tok.text
+			p.next()
+		} else {
+			// a destructuring pattern
+			p.typ()
+		}
+		param.optional = p.got("?")
+		param.typ = tsType(tsOther{})
+		if p.got(":") {
+			param.typ = p.typ()
+		}
+		if p.got("=") {
+			param.optional = true
+			p.literal()
+		}
+		params = append(params, param)
+		if !p.got(",") {
+			break
+		}
+	}
+	p.got(")")
+	return params
+}
+
+// resultType parses the optional result type annotation of a signature.
+func (p *parser) resultType() tsType {
+	if !p.got(":") {
+		return nil
+	}
+	return p.returnType()
+}
+
+// returnType parses the result type of a signature. A type predicate such
+// as `x is T` is a boolean.
+func (p *parser) returnType() tsType {
+	if p.is("asserts") && p.peek(1).kind == tokIdent {
+		p.next()
+		p.next()
+		if p.got("is") {
+			p.typ()
+		}
+		return tsRef{name: "void"}
+	}
+	if p.tok.kind == tokIdent && p.peek(1).text == "is" {
+		p.next()
+		p.next()
+		p.typ()
+		return tsRef{name: "boolean"}
+	}
+	return p.typ()
+}
+
+// typ parses a type.
+func (p *parser) typ() tsType {
+	t := p.unionType()
+	if p.is("extends") {
+		// a conditional type
+		p.next()
+		p.unionType()
+		if p.got("?") {
+			p.typ()
+			p.got(":")
+			p.typ()
+		}
+		return tsOther{}
+	}
+	return t
+}
+
+func (p *parser) unionType() tsType {
+	p.got("|")
+	var types []tsType
+	for {
+		types = append(types, p.intersectionType())
+		if !p.got("|") {
+			break
+		}
+	}
+	if len(types) == 1 {
+		return types[0]
+	}
+	return tsUnion{types}
+}
+
+func (p *parser) intersectionType() tsType {
+	p.got("&")
+	t := p.postfixType()
+	for p.got("&") {
+		p.postfixType()
+		t = tsOther{}
+	}
+	return t
+}
+
+func (p *parser) postfixType() tsType {
+	t := p.primaryType()
+	// only a bracket on the same line follows the type: on the next line
+	// it starts an index signature
+	for p.is("[") && p.tok.line == p.toks[p.i-1].line {
+		if p.peek(1).text == "]" {
+			p.next()
+			p.next()
+			t = tsArray{t}
+			continue
+		}
+		p.next()
+		p.typ()
+		p.got("]")
+		t = tsOther{} // an indexed access type
+	}
+	return t
+}
+
+func (p *parser) primaryType() tsType {
+	switch {
+	case p.tok.kind == tokString || p.tok.kind == tokNumber || p.is("-"):
+		return p.literal()
+	case p.tok.kind == tokTemplate:
+		p.next()
+		return tsRef{name: "string"}
+	case p.is("("):
+		if p.isFuncType() {
+			return p.funcType()
+		}
+		p.next()
+		t := p.typ()
+		p.got(")")
+		return t
+	case p.is("<"):
+		return p.funcType()
+	case p.is("["):
+		return p.tupleType()
+	case p.is("{"):
+		return tsObject{p.members()}
+	case p.is("new") || p.is("abstract") && p.peek(1).text == "new":
+		p.got("abstract")
+		p.next()
+		p.funcType()
+		return tsOther{}
+	case p.is("typeof") || p.is("keyof") || p.is("unique") || p.is("infer"):
+		p.next()
+		p.postfixType()
+		return tsOther{}
+	case p.is("readonly"):
+		p.next()
+		return p.postfixType()
+	case p.is("true") || p.is("false"):
+		p.next()
+		return tsRef{name: "boolean"}
+	case p.tok.kind == tokIdent:
+		ref := tsRef{name: p.tok.text}
+		p.next()
+		for p.is(".") && p.peek(1).kind == tokIdent {
+			p.next()
+			ref.name += "." + p.tok.text
+			p.next()
+		}
+		if p.got("<") {
+			for !p.is(">") && p.tok.kind != tokEOF {
+				ref.args = append(ref.args, p.typ())
+				if !p.got(",") {
+					break
+				}
+			}
+			p.got(">")
+		}
+		return ref
+	}
+	// not a type: skip the token
+	p.next()
+	return tsOther{}
+}
+
+// literal parses a literal, or returns nil if the current token is not one.
+func (p *parser) literal() tsType {
+	neg := p.is("-")
+	if neg {
+		p.next()
+	}
+	switch p.tok.kind {
+	case tokString, tokNumber:
+		lit := tsLiteral{p.tok.kind, p.tok.text}
+		if neg {
+			lit.value = "-" + lit.value
+		}
+		p.next()
+		return lit
+	}
+	return nil
+}
+
+// isFuncType reports whether the parenthesis starting the current type
+// starts a function type, by looking for an arrow after the closing
+// parenthesis.
+func (p *parser) isFuncType() bool {
+	depth := 0
+	for i := p.i; i < len(p.toks); i++ {
+		switch p.toks[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i+1 < len(p.toks) && p.toks[i+1].text == "=>"
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) funcType() tsType {
+	p.typeParams()
+	fn := tsFunc{params: p.params()}
+	if p.got("=>") {
+		fn.result = p.returnType()
+	}
+	return fn
+}
+
+func (p *parser) tupleType() tsType {
+	p.got("[")
+	var tuple tsTuple
+	for !p.is("]") && p.tok.kind != tokEOF {
+		rest := p.got("...")
+		if p.tok.kind == tokIdent && (p.peek(1).text == ":" || p.peek(1).text == "?" && p.peek(2).text == ":") {
+			p.next()
+			p.got("?")
+			p.next()
+		}
+		t := p.typ()
+		if p.got("?") || rest {
+			t = tsOther{}
+		}
+		tuple.elems = append(tuple.elems, t)
+		if !p.got(",") {
+			break
+		}
+	}
+	p.got("]")
+	return tuple
+}
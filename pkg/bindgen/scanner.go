@@ -0,0 +1,179 @@
+package bindgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token of a declaration file.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokTemplate
+	tokPunct
+)
+
+// token is a token of a declaration file. The value of a string token is
+// unquoted; template literals are tokTemplate tokens.
+type token struct {
+	kind      tokenKind
+	text      string
+	line, col int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString, tokTemplate:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// puncts holds the punctuation the scanner recognizes, longest first.
+var puncts = []string{
+	"...", "=>",
+	"{", "}", "(", ")", "[", "]", "<", ">", ",", ";", ":", "?", "|", "&", "=", ".", "*", "-", "+", "@", "!", "#",
+}
+
+// scan splits the source of a declaration file into tokens, without its
+// comments.
+func scan(src string) ([]token, error) {
+	var toks []token
+	line, col := 1, 1
+	if strings.HasPrefix(src, "#!") {
+		src = "//" + src[2:] // a shebang line
+	}
+	advance := func(n int) {
+		for _, r := range src[:n] {
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		src = src[n:]
+	}
+	for {
+		// skip spaces and comments
+		for len(src) > 0 {
+			r, size := utf8.DecodeRuneInString(src)
+			switch {
+			case unicode.IsSpace(r):
+				advance(size)
+				continue
+			case strings.HasPrefix(src, "//"):
+				end := strings.IndexByte(src, '\n')
+				if end < 0 {
+					end = len(src)
+				}
+				advance(end)
+				continue
+			case strings.HasPrefix(src, "/*"):
+				end := strings.Index(src[2:], "*/")
+				if end < 0 {
+					return nil, fmt.Errorf("%d:%d: comment not terminated", line, col)
+				}
+				advance(end + 4)
+				continue
+			}
+			break
+		}
+		if src == "" {
+			toks = append(toks, token{tokEOF, "", line, col})
+			return toks, nil
+		}
+
+		tok := token{line: line, col: col}
+		r, size := utf8.DecodeRuneInString(src)
+		n := 0
+		switch {
+		case isIdentStart(r):
+			n = size
+			for n < len(src) {
+				r, size := utf8.DecodeRuneInString(src[n:])
+				if !isIdentStart(r) && !unicode.IsDigit(r) {
+					break
+				}
+				n += size
+			}
+			tok.kind, tok.text = tokIdent, src[:n]
+
+		case r == '"' || r == '\'' || r == '`':
+			n = 1
+			for n < len(src) && src[n] != byte(r) {
+				if src[n] == '\\' {
+					n++
+				}
+				n++
+			}
+			if n >= len(src) {
+				return nil, fmt.Errorf("%d:%d: string literal not terminated", line, col)
+			}
+			n++
+			tok.kind, tok.text = tokString, unquote(src[1:n-1])
+			if r == '`' {
+				tok.kind = tokTemplate
+			}
+
+		case unicode.IsDigit(r):
+			for n < len(src) && (isIdentStart(rune(src[n])) || unicode.IsDigit(rune(src[n])) || src[n] == '.') {
+				n++
+			}
+			tok.kind, tok.text = tokNumber, src[:n]
+
+		default:
+			for _, p := range puncts {
+				if strings.HasPrefix(src, p) {
+					n = len(p)
+					break
+				}
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("%d:%d: unexpected character %q", line, col, r)
+			}
+			tok.kind, tok.text = tokPunct, src[:n]
+		}
+		toks = append(toks, tok)
+		advance(n)
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+// unquote returns the value of the body of a string literal, whose simple
+// escapes it interprets.
+func unquote(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
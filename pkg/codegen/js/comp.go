@@ -0,0 +1,100 @@
+package js
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// listComp returns a list comprehension.
+func (g *generator) listComp(e *ast.ListComp) js {
+	var elem types.Type
+	if s, ok := g.info.TypeOf(e).Underlying().(*types.Slice); ok {
+		elem = s.Elem()
+	}
+	return g.comp(e.Clauses, "[]", func(r string) {
+		g.line("%s.push(%s);", r, g.value(e.Elt, elem).code)
+	})
+}
+
+// mapComp returns a map comprehension.
+func (g *generator) mapComp(e *ast.MapComp) js {
+	m, _ := g.info.TypeOf(e).Underlying().(*types.Map)
+	if m == nil {
+		return undefined
+	}
+	return g.comp(e.Clauses, g.zero(m), func(r string) {
+		xs := g.operands(2, func(i int) (js, ast.Expr) {
+			if i == 0 {
+				return g.value(e.Key, m.Key()), e.Key
+			}
+			return g.value(e.Value, m.Elem()), e.Value
+		})
+		g.line("%s.set(%s, %s);", r, xs[0].code, xs[1].code)
+	})
+}
+
+// comp returns a comprehension, which loops over its clauses and collects
+// the results in a temporary initialized to init by the statement add
+// writes. The loops are hoisted, or are the body of a function called on
+// the spot where statements may not be hoisted.
+func (g *generator) comp(clauses []*ast.CompClause, init string, add func(r string)) js {
+	if !g.hoisting() {
+		return g.iife(func() {
+			r := g.locals.temp("r")
+			g.line("const %s = %s;", r, init)
+			g.compLoops(clauses, r, add)
+			g.line("return %s;", r)
+		})
+	}
+	r := g.temp("r")
+	g.line("const %s = %s;", r, init)
+	g.compLoops(clauses, r, add)
+	return js{r, precPrimary}
+}
+
+// compLoops writes the loops of the clauses of a comprehension collecting
+// its results in r.
+func (g *generator) compLoops(clauses []*ast.CompClause, r string, add func(r string)) {
+	for _, c := range clauses {
+		var key, val *types.Var
+		vars := make([]*types.Var, len(c.Vars))
+		for i, ident := range c.Vars {
+			vars[i], _ = g.info.Defs[ident].(*types.Var)
+			if vars[i] != nil && vars[i].Name() == "_" {
+				vars[i] = nil
+			}
+		}
+		switch {
+		case len(vars) == 2:
+			key, val = vars[0], vars[1]
+		case isKeyRange(g.info.TypeOf(c.X)):
+			key = vars[0]
+		default:
+			val = vars[0]
+		}
+		head, binds := g.rangeHead(c.X, key, val, c)
+		g.open("%s {", head)
+		for _, b := range binds {
+			g.line("%s", b)
+		}
+		if c.Cond != nil {
+			g.line("if (!%s) continue;", g.expr(c.Cond).at(precUnary))
+		}
+	}
+	add(r)
+	for range clauses {
+		g.close("}")
+	}
+}
+
+// isKeyRange reports whether a single variable ranging over values of type
+// T takes the keys, as for maps and integers, rather than the elements.
+func isKeyRange(T types.Type) bool {
+	switch u := types.CoreType(T).(type) {
+	case *types.Map:
+		return true
+	case *types.Basic:
+		return !isString(u)
+	}
+	return false
+}
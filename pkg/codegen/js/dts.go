@@ -0,0 +1,593 @@
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// tsReserved holds the names of the predefined types of TypeScript, which
+// declarations may not take.
+var tsReserved = map[string]bool{
+	"any": true, "bigint": true, "boolean": true, "never": true, "number": true,
+	"object": true, "string": true, "symbol": true, "undefined": true, "unknown": true,
+	"void": true,
+}
+
+// Declarations writes the TypeScript declarations of the module that
+// Generate writes for the package pkg, which type its exports as follows:
+//
+//   - structs and records are classes, whose unexported members are
+//     private and whose record fields are readonly
+//   - enums are unions of the literal types of their values and, unless
+//     inlined, their objects hold the variants
+//   - other named types with methods are aliases of their underlying type,
+//     and their values hold the static methods
+//   - interfaces are interfaces, and constraints unions of their terms
+//   - other named types are aliases of their underlying type
+//   - tuples are readonly arrays, optional values unions with null, and
+//     maps Maps or, for keys that are not primitive, $Maps
+//   - extern structs are interfaces, and extern enums aliases of their
+//     backing type
+func Declarations(w io.Writer, pkg *types.Package) error {
+	var conf Config
+	return conf.Declarations(w, pkg)
+}
+
+// Declarations is like the package-level Declarations, for the module that
+// conf.Generate writes.
+func (conf *Config) Declarations(w io.Writer, pkg *types.Package) error {
+	if err := checkEnums(conf.Enums, pkg); err != nil {
+		return err
+	}
+	d := &declarer{pkg: pkg, enums: conf.Enums, out: new(bytes.Buffer)}
+	d.module()
+	_, err := w.Write(d.bytes())
+	return err
+}
+
+type declarer struct {
+	pkg    *types.Package
+	enums  map[string]EnumConfig
+	out    *bytes.Buffer
+	hashed bool // whether the declarations refer to $Map
+}
+
+// bytes returns the declarations, preceded by that of $Map if they refer to
+// it.
+func (d *declarer) bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gus from package %s. DO NOT EDIT.\n", d.pkg.Name())
+	if d.hashed {
+		b.WriteString(`
+interface $Map<K, V> extends Iterable<[K, V]> {
+  readonly size: number;
+  has(k: K): boolean;
+  get(k: K): V | undefined;
+  set(k: K, v: V): this;
+  delete(k: K): boolean;
+  keys(): IterableIterator<K>;
+}
+`)
+	}
+	if d.out.Len() > 0 {
+		b.WriteString("\n")
+		b.Write(d.out.Bytes())
+	}
+	return b.Bytes()
+}
+
+// module declares the types of the package, then its exported constants,
+// variables and functions, and exports the exported declarations.
+func (d *declarer) module() {
+	scope := d.pkg.Scope()
+	var typeNames, values []types.Object
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.TypeName:
+			typeNames = append(typeNames, obj)
+		case *types.Const:
+			if ast.IsExported(name) {
+				values = append(values, obj)
+			}
+		case *types.Var, *types.Func:
+			// extern bindings belong to other modules
+			if ast.IsExported(name) && externOf(obj) == nil {
+				values = append(values, obj)
+			}
+		}
+	}
+
+	var exports []string
+	for _, obj := range append(typeNames, values...) {
+		if d.out.Len() > 0 {
+			d.out.WriteString("\n")
+		}
+		d.decl(obj)
+		if ast.IsExported(obj.Name()) {
+			exports = append(exports, obj.Name())
+		}
+	}
+
+	// the export list keeps the other declarations private
+	sort.Strings(exports)
+	list := make([]string, len(exports))
+	for i, name := range exports {
+		list[i] = name
+		if ts := d.name(name); ts != name {
+			list[i] = ts + " as " + name
+		}
+	}
+	if d.out.Len() > 0 {
+		d.out.WriteString("\n")
+	}
+	fmt.Fprintf(d.out, "export { %s };\n", strings.Join(list, ", "))
+}
+
+// name returns the TypeScript name of a package-level declaration or type
+// parameter.
+func (d *declarer) name(name string) string {
+	name = Mangle(name)
+	if tsReserved[name] {
+		name += "$"
+	}
+	return name
+}
+
+func (d *declarer) line(format string, args ...any) {
+	fmt.Fprintf(d.out, format+"\n", args...)
+}
+
+// decl declares the package-level object obj.
+func (d *declarer) decl(obj types.Object) {
+	name := d.name(obj.Name())
+	switch obj := obj.(type) {
+	case *types.Const:
+		d.line("declare const %s: %s;", name, d.typ(obj.Type()))
+	case *types.Var:
+		d.line("declare let %s: %s;", name, d.typ(obj.Type()))
+	case *types.Func:
+		sig := obj.Signature()
+		d.line("declare function %s%s%s;", name, d.typeParams(sig.TypeParams()), d.signature(sig, ": "))
+	case *types.TypeName:
+		d.typeDecl(name, obj.Type().(*types.Named))
+	}
+}
+
+// typeDecl declares the named type t.
+func (d *declarer) typeDecl(name string, t *types.Named) {
+	tparams := d.typeParams(t.TypeParams())
+	self := name + d.typeArgs(t.TypeParams())
+	if t.Extern() {
+		d.externDecl(name, tparams, t)
+		return
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct, *types.Record:
+		_, frozen := u.(*types.Record)
+		d.line("declare class %s%s {", name, tparams)
+		fields := structFields(u)
+		params := make([]string, len(fields))
+		for i, f := range fields {
+			params[i] = d.param(f.Name(), i) + "?: " + d.typ(f.Type())
+		}
+		d.line("  constructor(%s);", strings.Join(params, ", "))
+		for _, f := range fields {
+			switch {
+			case !ast.IsExported(f.Name()):
+				d.line("  private %s;", propName(f.Name()))
+			case frozen:
+				d.line("  readonly %s: %s;", propName(f.Name()), d.typ(f.Type()))
+			default:
+				d.line("  %s: %s;", propName(f.Name()), d.typ(f.Type()))
+			}
+		}
+		if !frozen {
+			d.line("  $clone(): %s;", self)
+		}
+		d.methods(t, promotedMethods(t))
+		d.line("}")
+
+	case *types.Enum:
+		d.enumDecl(name, tparams, self, t, u)
+
+	case *types.Interface:
+		if terms := u.Terms(); terms != nil {
+			d.line("type %s%s = %s;", name, tparams, d.union(terms))
+			return
+		}
+		d.line("interface %s%s {", name, tparams)
+		for i := 0; i < u.NumMethods(); i++ {
+			m := u.Method(i)
+			d.line("  %s%s;", propName(m.Name()), d.signature(m.Signature(), ": "))
+		}
+		d.line("}")
+
+	default:
+		d.line("type %s%s = %s;", name, tparams, d.typ(u))
+		if !hasClass(t) {
+			return
+		}
+		// the class of a boxed type holds its methods as static methods
+		d.line("declare const %s: {", name)
+		d.staticMethods(tparams, self, t)
+		d.line("};")
+	}
+}
+
+// staticMethods declares the methods of the boxed type t as the static
+// methods of its class.
+func (d *declarer) staticMethods(tparams, self string, t *types.Named) {
+	for i := 0; i < t.NumMethods(); i++ {
+		m := t.Method(i)
+		sig := m.Signature()
+		recv := d.param(sig.Recv().Name(), 0) + ": " + self
+		params := d.params(sig.Params())
+		d.line("  %s%s(%s): %s;", staticName(m.Name()), tparams, strings.Join(append([]string{recv}, params...), ", "), d.results(sig))
+	}
+}
+
+// enumDecl declares the enum type t as the type of its values, lowered as
+// its configuration says, and its object if the module declares one.
+func (d *declarer) enumDecl(name, tparams, self string, t *types.Named, enum *types.Enum) {
+	conf, _ := enumConfig(d.enums, d.pkg, t)
+	never := name + d.typeArgsNever(t.TypeParams())
+	switch {
+	case conf.Lowering == EnumTagged:
+		alts := make([]string, enum.NumVariants())
+		for i := range alts {
+			v := enum.Variant(i)
+			props := []string{"readonly $tag: " + jsString(v.Name())}
+			if len(enum.Backing()) > 0 {
+				props = append(props, "readonly $value: "+d.variantType(enum, v))
+			}
+			for j, p := range v.Payload() {
+				props = append(props, "readonly $"+strconv.Itoa(j)+": "+d.typ(p.Type()))
+			}
+			alts[i] = "{ " + strings.Join(props, "; ") + " }"
+		}
+		d.line("type %s%s = %s;", name, tparams, strings.Join(alts, " | "))
+	case len(enum.Backing()) > 0:
+		alts := make([]string, enum.NumVariants())
+		for i := range alts {
+			alts[i] = d.variantType(enum, enum.Variant(i))
+		}
+		d.line("type %s%s = %s;", name, tparams, strings.Join(alts, " | "))
+	default:
+		names := make([]string, enum.NumVariants())
+		for i := range names {
+			names[i] = jsString(enum.Variant(i).Name())
+		}
+		d.line("type %s%s = %s;", name, tparams, strings.Join(names, " | "))
+	}
+	if conf.Lowering == EnumInline && len(conf.helpers()) == 0 && t.NumMethods() == 0 {
+		return
+	}
+
+	d.line("declare const %s: {", name)
+	if conf.Lowering != EnumInline {
+		for i := 0; i < enum.NumVariants(); i++ {
+			v := enum.Variant(i)
+			if v.Payload() == nil {
+				// the variants without payload are not generic
+				d.line("  readonly %s: %s;", staticName(v.Name()), never)
+				continue
+			}
+			params := make([]string, len(v.Payload()))
+			for j, p := range v.Payload() {
+				params[j] = d.param(p.Name(), j) + ": " + d.typ(p.Type())
+			}
+			d.line("  readonly %s: %s(%s) => %s;", staticName(v.Name()), tparams, strings.Join(params, ", "), self)
+		}
+	}
+	if conf.String {
+		d.line("  String%s(v: %s): string;", tparams, self)
+	}
+	if conf.Values {
+		d.line("  values(): %s[];", never)
+	}
+	if conf.Parse {
+		d.line("  parse(name: string): %s | null;", never)
+	}
+	d.staticMethods(tparams, self, t)
+	d.line("};")
+}
+
+// variantType returns the type of the value of the variant v of a backed
+// enum: the literal type of its value, or the backing type where the value
+// has none.
+func (d *declarer) variantType(enum *types.Enum, v *types.Variant) string {
+	backing := enum.Backing()
+	elems := make([]string, len(backing))
+	for i, val := range v.Value() {
+		elems[i] = d.typ(backing[i])
+		if val == nil {
+			continue
+		}
+		if lit := constLit(val, backing[i]).code; !strings.Contains(lit, "Infinity") {
+			elems[i] = lit
+		}
+	}
+	if len(elems) == 1 {
+		return elems[0]
+	}
+	return "readonly [" + strings.Join(elems, ", ") + "]"
+}
+
+// externDecl declares the extern type t, whose optional fields and
+// parameters may be missing.
+func (d *declarer) externDecl(name, tparams string, t *types.Named) {
+	enum, ok := t.Underlying().(*types.Enum)
+	if ok {
+		d.line("type %s%s = %s;", name, tparams, d.typ(enum.Backing()[0]))
+		return
+	}
+	d.line("interface %s%s {", name, tparams)
+	for _, f := range structFields(t) {
+		if _, ok := f.Type().Underlying().(*types.Optional); ok {
+			d.line("  %s?: %s;", externProp(f.Name()), d.typ(f.Type()))
+			continue
+		}
+		d.line("  %s: %s;", externProp(f.Name()), d.typ(f.Type()))
+	}
+	for i := 0; i < t.NumMethods(); i++ {
+		m := t.Method(i)
+		sig := m.Signature()
+		params := d.params(sig.Params())
+		for j := len(params) - sig.Optional(); j < len(params); j++ {
+			params[j] = strings.Replace(params[j], ":", "?:", 1)
+		}
+		if m.Extern().Rest && len(params) > 0 {
+			params[len(params)-1] = "..." + strings.Replace(params[len(params)-1], "?:", ":", 1)
+		}
+		name := m.Extern().Name
+		if !isJSIdent(name) {
+			name = jsString(name)
+		}
+		d.line("  %s(%s): %s;", name, strings.Join(params, ", "), d.results(sig))
+	}
+	d.line("}")
+}
+
+// methods declares the methods of the named type t, and the methods
+// promoted to it, of which the unexported ones are private.
+func (d *declarer) methods(t *types.Named, promoted []*types.Func) {
+	var methods []*types.Func
+	for i := 0; i < t.NumMethods(); i++ {
+		methods = append(methods, t.Method(i))
+	}
+	for _, m := range append(methods, promoted...) {
+		if !ast.IsExported(m.Name()) {
+			d.line("  private %s;", propName(m.Name()))
+			continue
+		}
+		d.line("  %s%s;", propName(m.Name()), d.signature(m.Signature(), ": "))
+	}
+}
+
+// signature returns the parameters and results of sig, separated by sep.
+func (d *declarer) signature(sig *types.Signature, sep string) string {
+	return "(" + strings.Join(d.params(sig.Params()), ", ") + ")" + sep + d.results(sig)
+}
+
+func (d *declarer) params(vars []*types.Var) []string {
+	params := make([]string, len(vars))
+	for i, p := range vars {
+		params[i] = d.param(p.Name(), i) + ": " + d.typ(p.Type())
+	}
+	return params
+}
+
+// param returns the name of the i'th parameter, which may be unnamed.
+func (d *declarer) param(name string, i int) string {
+	if name == "" || name == "_" {
+		return "$" + strconv.Itoa(i)
+	}
+	return Mangle(name)
+}
+
+// results returns the type of the results of sig: void, the type of its
+// result, or a tuple of its results.
+func (d *declarer) results(sig *types.Signature) string {
+	results := sig.Results()
+	switch len(results) {
+	case 0:
+		return "void"
+	case 1:
+		return d.typ(results[0].Type())
+	}
+	list := make([]types.Type, len(results))
+	for i, r := range results {
+		list[i] = r.Type()
+	}
+	return d.tuple(list)
+}
+
+// typeParams returns the declaration of the type parameters tparams, with
+// their constraints.
+func (d *declarer) typeParams(tparams []*types.TypeParam) string {
+	if len(tparams) == 0 {
+		return ""
+	}
+	list := make([]string, len(tparams))
+	for i, tp := range tparams {
+		list[i] = d.name(tp.Obj().Name())
+		if c := d.constraint(tp.Constraint()); c != "" {
+			list[i] += " extends " + c
+		}
+	}
+	return "<" + strings.Join(list, ", ") + ">"
+}
+
+// typeArgs returns the type parameters tparams as type arguments.
+func (d *declarer) typeArgs(tparams []*types.TypeParam) string {
+	if len(tparams) == 0 {
+		return ""
+	}
+	list := make([]string, len(tparams))
+	for i, tp := range tparams {
+		list[i] = d.name(tp.Obj().Name())
+	}
+	return "<" + strings.Join(list, ", ") + ">"
+}
+
+// typeArgsNever returns never for each of the type parameters tparams, as
+// type arguments.
+func (d *declarer) typeArgsNever(tparams []*types.TypeParam) string {
+	if len(tparams) == 0 {
+		return ""
+	}
+	return "<" + strings.TrimSuffix(strings.Repeat("never, ", len(tparams)), ", ") + ">"
+}
+
+// constraint returns the type a type argument must extend to satisfy the
+// constraint T, or "" if any type does.
+func (d *declarer) constraint(T types.Type) string {
+	iface, ok := T.Underlying().(*types.Interface)
+	if !ok {
+		return d.typ(T)
+	}
+	if terms := iface.Terms(); terms != nil {
+		return d.union(terms)
+	}
+	if iface.Empty() {
+		return ""
+	}
+	return d.typ(T)
+}
+
+// union returns the union of the types of terms, which may have the same
+// TypeScript type.
+func (d *declarer) union(terms []*types.Term) string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		if ts := d.elem(term.Type()); !seen[ts] {
+			seen[ts] = true
+			list = append(list, ts)
+		}
+	}
+	return strings.Join(list, " | ")
+}
+
+func (d *declarer) tuple(list []types.Type) string {
+	elems := make([]string, len(list))
+	for i, T := range list {
+		elems[i] = d.typ(T)
+	}
+	return "readonly [" + strings.Join(elems, ", ") + "]"
+}
+
+// typ returns the TypeScript type of the values of type T.
+func (d *declarer) typ(T types.Type) string {
+	switch t := T.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.Bool, types.UntypedBool:
+			return "boolean"
+		case types.Int, types.Float, types.UntypedInt, types.UntypedFloat:
+			return "number"
+		case types.Int64:
+			return "bigint"
+		case types.String, types.Symbol, types.UntypedString, types.UntypedSymbol:
+			return "string"
+		case types.UntypedNil:
+			return "null"
+		}
+	case *types.SymbolSet:
+		if t.Len() == 0 {
+			return "never"
+		}
+		list := make([]string, t.Len())
+		for i := range list {
+			list[i] = strconv.Quote(t.Name(i))
+		}
+		return strings.Join(list, " | ")
+	case *types.Named:
+		if t.Obj().Parent() != d.pkg.Scope() {
+			// a local type, which the declarations cannot name
+			return d.typ(t.Underlying())
+		}
+		name := d.name(t.Obj().Name())
+		if args := t.TypeArgs(); len(args) > 0 {
+			list := make([]string, len(args))
+			for i, arg := range args {
+				list[i] = d.typ(arg)
+			}
+			name += "<" + strings.Join(list, ", ") + ">"
+		}
+		return name
+	case *types.TypeParam:
+		return d.name(t.Obj().Name())
+	case *types.Array:
+		return d.elem(t.Elem()) + "[]"
+	case *types.Slice:
+		return d.elem(t.Elem()) + "[]"
+	case *types.Map:
+		if primitiveKey(t.Key()) {
+			return "Map<" + d.typ(t.Key()) + ", " + d.typ(t.Elem()) + ">"
+		}
+		d.hashed = true
+		return "$Map<" + d.typ(t.Key()) + ", " + d.typ(t.Elem()) + ">"
+	case *types.Optional:
+		return d.elem(t.Elem()) + " | null"
+	case *types.Tuple:
+		list := make([]types.Type, t.Len())
+		for i := range list {
+			list[i] = t.At(i)
+		}
+		return d.tuple(list)
+	case *types.Struct, *types.Record:
+		_, frozen := t.(*types.Record)
+		fields := structFields(t)
+		list := make([]string, len(fields))
+		for i, f := range fields {
+			list[i] = propName(f.Name()) + ": " + d.typ(f.Type())
+			if frozen {
+				list[i] = "readonly " + list[i]
+			}
+		}
+		return "{ " + strings.Join(list, "; ") + " }"
+	case *types.Interface:
+		if terms := t.Terms(); terms != nil {
+			return d.union(terms)
+		}
+		if t.Empty() {
+			return "unknown"
+		}
+		list := make([]string, t.NumMethods())
+		for i := range list {
+			m := t.Method(i)
+			list[i] = propName(m.Name()) + d.signature(m.Signature(), ": ")
+		}
+		return "{ " + strings.Join(list, "; ") + " }"
+	case *types.Signature:
+		return d.signature(t, " => ")
+	}
+	return "unknown"
+}
+
+// elem returns the type of T as an operand of a union or an element of an
+// array.
+func (d *declarer) elem(T types.Type) string {
+	s := d.typ(T)
+	switch t := T.(type) {
+	case *types.Optional, *types.Signature:
+		return "(" + s + ")"
+	case *types.SymbolSet:
+		if t.Len() > 1 {
+			return "(" + s + ")"
+		}
+	case *types.Interface:
+		if t.Terms() != nil {
+			return "(" + s + ")"
+		}
+	}
+	return s
+}
@@ -0,0 +1,437 @@
+package js
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// EnumLowering selects how the values of an enum type are represented.
+type EnumLowering int
+
+const (
+	// EnumAuto lowers the enums without payloads like EnumObject, and the
+	// others like EnumTagged.
+	EnumAuto EnumLowering = iota
+	// EnumObject represents a variant by its value, or by its name for
+	// enums without a backing type, which a frozen object declared for
+	// the enum holds as the property named after the variant. The values
+	// of enums backed by several types are frozen arrays.
+	EnumObject
+	// EnumInline represents the variants like EnumObject, but writes
+	// their values where they are used, as TypeScript does for const
+	// enums. The object of the enum is declared only for its helpers and
+	// methods.
+	EnumInline
+	// EnumTagged represents a variant by a frozen object whose $tag
+	// property holds the name of the variant, $value its value for backed
+	// enums, and $0, $1 and so on its payload. The object of the enum
+	// holds the variants without payload and functions constructing the
+	// others.
+	EnumTagged
+)
+
+var enumLowerings = [...]string{"auto", "object", "inline", "tagged"}
+
+func (l EnumLowering) String() string {
+	if l < 0 || int(l) >= len(enumLowerings) {
+		return "EnumLowering(" + strconv.Itoa(int(l)) + ")"
+	}
+	return enumLowerings[l]
+}
+
+// An EnumConfig configures the lowering of an enum type, and the helpers
+// the object of the enum holds.
+type EnumConfig struct {
+	Lowering EnumLowering
+	// String adds the helper String(v), which returns the name of the
+	// variant v.
+	String bool
+	// Values adds the helper values(), which returns the variants in the
+	// order of their declaration. It applies to enums without payloads.
+	Values bool
+	// Parse adds the helper parse(name), which returns the variant named
+	// name, or null if there is none. It applies to enums without
+	// payloads.
+	Parse bool
+}
+
+// helpers returns the names of the helpers c adds.
+func (c EnumConfig) helpers() []string {
+	var names []string
+	if c.String {
+		names = append(names, "String")
+	}
+	if c.Values {
+		names = append(names, "values")
+	}
+	if c.Parse {
+		names = append(names, "parse")
+	}
+	return names
+}
+
+// ParseEnumConfig parses the configuration of an enum from the form
+// name=item,item..., where name is the name of the enum type, or "*" for
+// the enums without a configuration of their own, and each item is a
+// lowering (auto, object, inline or tagged) or a helper (string, values or
+// parse).
+func ParseEnumConfig(s string) (name string, conf EnumConfig, err error) {
+	name, items, ok := strings.Cut(s, "=")
+	if !ok || name == "" || items == "" {
+		return "", conf, fmt.Errorf("invalid enum configuration %q: want name=item,...", s)
+	}
+	lowering := false
+	for _, item := range strings.Split(items, ",") {
+		switch item {
+		case "string":
+			conf.String = true
+		case "values":
+			conf.Values = true
+		case "parse":
+			conf.Parse = true
+		default:
+			i := 0
+			for i < len(enumLowerings) && enumLowerings[i] != item {
+				i++
+			}
+			if i == len(enumLowerings) {
+				return "", conf, fmt.Errorf("invalid enum configuration %q: unknown lowering or helper %q", s, item)
+			}
+			if lowering {
+				return "", conf, fmt.Errorf("invalid enum configuration %q: more than one lowering", s)
+			}
+			lowering = true
+			conf.Lowering = EnumLowering(i)
+		}
+	}
+	return name, conf, nil
+}
+
+// checkEnums reports an error if enums configures a name that does not
+// declare an enum type of pkg, or an enum in a way that does not apply to
+// it.
+func checkEnums(enums map[string]EnumConfig, pkg *types.Package) error {
+	names := make([]string, 0, len(enums))
+	for name := range enums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "*" {
+			continue
+		}
+		obj, _ := pkg.Scope().Lookup(name).(*types.TypeName)
+		if obj == nil {
+			return fmt.Errorf("enum configuration: %s is not a type of package %s", name, pkg.Name())
+		}
+		t, ok := obj.Type().(*types.Named)
+		if _, isEnum := obj.Type().Underlying().(*types.Enum); !ok || !isEnum {
+			return fmt.Errorf("enum configuration: %s is not an enum type", name)
+		}
+		if t.Extern() {
+			return fmt.Errorf("enum configuration: the values of the extern enum %s are JavaScript's", name)
+		}
+		if _, err := enumConfig(enums, pkg, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enumConfig returns the configuration of the enum type t: the entry of
+// enums for its name if t is declared at package level, or else the entry
+// "*", with the lowering resolved. It reports an error if the entry for
+// the name of t does not apply to t; the entry "*" applies to each enum as
+// far as it can. Extern enums are inlined.
+func enumConfig(enums map[string]EnumConfig, pkg *types.Package, t *types.Named) (EnumConfig, error) {
+	t = t.Origin()
+	if t.Extern() {
+		return EnumConfig{Lowering: EnumInline}, nil
+	}
+	name := t.Obj().Name()
+	conf, own := enums[name]
+	if !own || pkg.Scope().Lookup(name) != t.Obj() {
+		conf, own = enums["*"], false
+	}
+	enum := t.Underlying().(*types.Enum)
+	var err error
+	// invalid reports whether a configuration of t is invalid, and should
+	// be dropped since it is not the own one of t.
+	invalid := func(format string, args ...any) bool {
+		if own && err == nil {
+			err = fmt.Errorf("enum %s: "+format, append([]any{name}, args...)...)
+		}
+		return !own
+	}
+
+	payloads := false
+	for i := 0; i < enum.NumVariants(); i++ {
+		if enum.Variant(i).Payload() != nil {
+			payloads = true
+		}
+	}
+	switch conf.Lowering {
+	case EnumObject, EnumInline:
+		if payloads && invalid("variants with payloads require the tagged lowering") {
+			conf.Lowering = EnumAuto
+		}
+		if conf.Lowering == EnumInline && len(enum.Backing()) > 1 && invalid("tuple values cannot be inlined") {
+			conf.Lowering = EnumAuto
+		}
+	}
+	if conf.Lowering == EnumAuto {
+		conf.Lowering = EnumObject
+		if payloads {
+			conf.Lowering = EnumTagged
+		}
+	}
+	if payloads {
+		if conf.Values && invalid("the values helper requires variants without payloads") {
+			conf.Values = false
+		}
+		if conf.Parse && invalid("the parse helper requires variants without payloads") {
+			conf.Parse = false
+		}
+	}
+	for _, helper := range conf.helpers() {
+		taken := ""
+		if conf.Lowering != EnumInline && enum.Lookup(helper) != nil {
+			taken = "variant"
+		}
+		for i := 0; i < t.NumMethods(); i++ {
+			if t.Method(i).Name() == helper {
+				taken = "method"
+			}
+		}
+		if taken != "" && invalid("the %s helper conflicts with the %s %s", helper, taken, helper) {
+			switch helper {
+			case "String":
+				conf.String = false
+			case "values":
+				conf.Values = false
+			case "parse":
+				conf.Parse = false
+			}
+		}
+	}
+	return conf, err
+}
+
+// enumConfig returns the configuration of the enum type t, which
+// checkEnums has checked.
+func (g *generator) enumConfig(t *types.Named) EnumConfig {
+	t = t.Origin()
+	conf, ok := g.enumConfigs[t]
+	if !ok {
+		conf, _ = enumConfig(g.enums, g.pkg, t)
+		g.enumConfigs[t] = conf
+	}
+	return conf
+}
+
+// isTagged reports whether T is an enum type lowered to tagged objects.
+func (g *generator) isTagged(T types.Type) bool {
+	t, ok := T.(*types.Named)
+	if !ok {
+		return false
+	}
+	if _, isEnum := t.Underlying().(*types.Enum); !isEnum {
+		return false
+	}
+	return g.enumConfig(t).Lowering == EnumTagged
+}
+
+// hasEnumObject reports whether the package declares an object for the
+// enum type t, which holds its variants unless they are inlined, and its
+// helpers.
+func (g *generator) hasEnumObject(t *types.Named) bool {
+	if _, ok := t.Underlying().(*types.Enum); !ok || t.Extern() {
+		return false
+	}
+	conf := g.enumConfig(t)
+	return conf.Lowering != EnumInline || len(conf.helpers()) > 0
+}
+
+// enumValues computes the values of the variants of the enums without
+// payloads that the package declares, which their variants write when
+// they are inlined.
+func (g *generator) enumValues() {
+	for _, file := range g.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			s, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			obj, _ := g.info.Defs[s.Name].(*types.TypeName)
+			if obj == nil {
+				return true
+			}
+			enum, ok := obj.Type().Underlying().(*types.Enum)
+			if !ok {
+				return true
+			}
+			values := make([]string, enum.NumVariants())
+			for i := range values {
+				if enum.Variant(i).Payload() != nil {
+					return true
+				}
+				values[i] = variantValue(enum, enum.Variant(i))
+			}
+			g.variantValues[obj.Type().(*types.Named)] = values
+			return true
+		})
+	}
+}
+
+// variantValue returns the value of a variant of an enum without payloads:
+// for backed enums, the value the type checker gives it, and for the
+// others its name.
+func variantValue(enum *types.Enum, v *types.Variant) string {
+	backing := enum.Backing()
+	vals := v.Value()
+	if len(backing) > 1 {
+		elts := make([]string, len(vals))
+		for i, val := range vals {
+			elts[i] = constLit(val, backing[i]).code
+		}
+		return "[" + strings.Join(elts, ", ") + "]"
+	}
+	if len(vals) == 0 || vals[0] == nil {
+		return jsString(v.Name())
+	}
+	return constLit(vals[0], backing[0]).code
+}
+
+// variant returns the variant of the enum type t named name: its value if
+// it is inlined, and otherwise the property of the object of the enum.
+func (g *generator) variant(t *types.Named, name string) js {
+	if g.enumConfig(t).Lowering != EnumInline {
+		return js{g.className(t) + "." + staticName(name), precCall}
+	}
+	v := t.Underlying().(*types.Enum).Lookup(name)
+	code := g.variantValues[t.Origin()][v.Index()]
+	if strings.HasPrefix(code, "-") {
+		return js{code, precUnary}
+	}
+	return js{code, precPrimary}
+}
+
+// variantInit returns the value of the property of the object of the enum
+// type t holding its i'th variant: a value, or for variants with a payload
+// a function constructing one.
+func (g *generator) variantInit(t *types.Named, i int) string {
+	enum := t.Underlying().(*types.Enum)
+	v := enum.Variant(i)
+	if g.enumConfig(t).Lowering != EnumTagged {
+		value := g.variantValues[t.Origin()][i]
+		if len(enum.Backing()) > 1 {
+			return "Object.freeze(" + value + ")"
+		}
+		return value
+	}
+	props := []string{"$tag: " + jsString(v.Name())}
+	if len(enum.Backing()) > 0 {
+		props = append(props, "$value: "+g.variantValues[t.Origin()][i])
+	}
+	if v.Payload() == nil {
+		return "Object.freeze({ " + strings.Join(props, ", ") + " })"
+	}
+	params := make([]string, len(v.Payload()))
+	for j := range params {
+		params[j] = "$" + strconv.Itoa(j)
+	}
+	props = append(props, params...)
+	return "(" + strings.Join(params, ", ") + ") => Object.freeze({ " + strings.Join(props, ", ") + " })"
+}
+
+// enumObject generates the frozen object of an enum type without methods,
+// named name, holding its variants and helpers.
+func (g *generator) enumObject(obj *types.TypeName, name string, t *types.Named) {
+	g.open("const %s = Object.freeze({", g.named(obj, name))
+	if g.enumConfig(t).Lowering != EnumInline {
+		enum := t.Underlying().(*types.Enum)
+		for i := 0; i < enum.NumVariants(); i++ {
+			g.line("%s: %s,", staticName(enum.Variant(i).Name()), g.variantInit(t, i))
+		}
+	}
+	g.enumHelpers(t, false)
+	g.close("});")
+}
+
+// enumStatics generates the variants of an enum type with methods, named
+// name, as static members of its class, which is then frozen.
+func (g *generator) enumStatics(name string, t *types.Named) {
+	if g.enumConfig(t).Lowering != EnumInline {
+		enum := t.Underlying().(*types.Enum)
+		for i := 0; i < enum.NumVariants(); i++ {
+			g.line("%s.%s = %s;", name, staticName(enum.Variant(i).Name()), g.variantInit(t, i))
+		}
+	}
+	g.line("Object.freeze(%s);", name)
+}
+
+// enumHelpers generates the helpers of the enum type t as methods of its
+// object, or as static methods of its class.
+func (g *generator) enumHelpers(t *types.Named, static bool) {
+	prefix, sep := "", ","
+	if static {
+		prefix, sep = "static ", ""
+	}
+	conf := g.enumConfig(t)
+	enum := t.Underlying().(*types.Enum)
+	var variants []js // the variants of enums without payloads
+	if _, ok := g.variantValues[t.Origin()]; ok {
+		for i := 0; i < enum.NumVariants(); i++ {
+			variants = append(variants, g.variant(t, enum.Variant(i).Name()))
+		}
+	}
+	if conf.String {
+		g.methodSep(static)
+		g.open("%sString($v) {", prefix)
+		if conf.Lowering == EnumTagged {
+			g.line("return $v.$tag;")
+		} else {
+			g.open("switch ($v) {")
+			for i, v := range variants {
+				g.line("case %s:", v.code)
+				g.line("  return %s;", jsString(enum.Variant(i).Name()))
+			}
+			g.close("}")
+		}
+		g.close("}%s", sep)
+	}
+	if conf.Values {
+		list := make([]string, len(variants))
+		for i, v := range variants {
+			list[i] = v.code
+		}
+		g.methodSep(static)
+		g.open("%svalues() {", prefix)
+		g.line("return [%s];", strings.Join(list, ", "))
+		g.close("}%s", sep)
+	}
+	if conf.Parse {
+		g.methodSep(static)
+		g.open("%sparse($name) {", prefix)
+		g.open("switch ($name) {")
+		for i, v := range variants {
+			g.line("case %s:", jsString(enum.Variant(i).Name()))
+			g.line("  return %s;", v.code)
+		}
+		g.close("}")
+		g.line("return null;")
+		g.close("}%s", sep)
+	}
+}
+
+// methodSep separates the methods of a class by blank lines.
+func (g *generator) methodSep(static bool) {
+	if static {
+		g.line("")
+	}
+}
@@ -0,0 +1,999 @@
+package js
+
+import (
+	"go/constant"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// js is a generated JavaScript expression and its precedence.
+type js struct {
+	code string
+	prec int
+}
+
+// Precedences of JavaScript expressions, lowest first. ?? may not be mixed
+// with && or || without parentheses, so it binds less tightly than both
+// here.
+const (
+	precAssign  = iota + 1 // assignments, arrow functions and spreads
+	precCond               // ?:
+	precNullish            // ??
+	precOr                 // ||
+	precAnd                // &&
+	precBitOr              // |
+	precBitXor             // ^
+	precBitAnd             // &
+	precEq                 // === !==
+	precRel                // < <= > >= instanceof
+	precShift              // << >> >>>
+	precAdd                // + -
+	precMul                // * / %
+	precUnary              // ! ~ - + typeof
+	precCall               // calls, member accesses and new with arguments
+	precPrimary            // names, literals and parenthesized expressions
+)
+
+// at returns x as an operand of an expression requiring at least the
+// precedence prec.
+func (x js) at(prec int) string {
+	if x.prec < prec {
+		return "(" + x.code + ")"
+	}
+	return x.code
+}
+
+var undefined = js{"undefined", precPrimary}
+
+// expr returns the JavaScript expression computing e.
+func (g *generator) expr(e ast.Expr) js {
+	x := g.expr1(e)
+	if g.maps && x.code != "" {
+		name := ""
+		if ident, ok := e.(*ast.Ident); ok && plain(x.code) != ident.Name {
+			name = ident.Name
+		}
+		x.code = g.mark(e.Pos(), name) + x.code
+	}
+	return x
+}
+
+func (g *generator) expr1(e ast.Expr) js {
+	if tv, ok := g.info.Types[e]; ok && tv.Value != nil {
+		return constLit(tv.Value, tv.Type)
+	}
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return g.expr(e.X)
+	case *ast.Ident:
+		if fn, ok := g.info.Uses[e].(*types.Func); ok && externOf(fn) != nil {
+			return g.externValue(fn)
+		}
+		return js{g.name(g.info.ObjectOf(e)), precPrimary}
+	case *ast.BasicLit:
+		return g.basicLit(e)
+	case *ast.CompositeLit:
+		return g.compositeLit(e)
+	case *ast.JSONLit:
+		return g.jsonLit(e)
+	case *ast.JSXElement:
+		return g.jsxElement(e)
+	case *ast.TupleLit:
+		t, _ := g.info.TypeOf(e).(*types.Tuple)
+		elts := g.operands(len(e.Elts), func(i int) (js, ast.Expr) {
+			var T types.Type
+			if t != nil {
+				T = t.At(i)
+			}
+			return g.value(e.Elts[i], T), e.Elts[i]
+		})
+		return js{"[" + strings.Join(codes(elts), ", ") + "]", precPrimary}
+	case *ast.FuncLit:
+		return g.funcLit(e)
+	case *ast.SelectorExpr:
+		return g.selector(e)
+	case *ast.IndexExpr:
+		return g.index(e)
+	case *ast.CallExpr:
+		return g.call(e)
+	case *ast.UnaryExpr:
+		return g.unary(e)
+	case *ast.BinaryExpr:
+		return g.binary(e)
+	case *ast.ListComp:
+		return g.listComp(e)
+	case *ast.MapComp:
+		return g.mapComp(e)
+	case *ast.TupleIndexExpr:
+		x := g.expr(e.X)
+		return js{x.at(precCall) + "[" + strconv.Itoa(e.Index) + "]", precCall}
+	case *ast.WithExpr:
+		return g.with(e)
+	case *ast.MatchExpr:
+		return g.matchValue(e)
+	}
+	g.errorf(e.Pos(), "cannot generate %s", ast.ExprString(e))
+	return undefined
+}
+
+// value returns the expression computing e stored in a variable of type
+// T: a copy of values of value types read from variables, and boxed values
+// stored in interfaces. T may be nil for untyped contexts.
+func (g *generator) value(e ast.Expr, T types.Type) js {
+	x := g.expr(e)
+	V := g.info.TypeOf(e)
+	if V == nil {
+		return x
+	}
+	if isLvalue(e) && isValueType(V) {
+		x = g.clone(x, V)
+	}
+	return g.box(x, V, T)
+}
+
+// isLvalue reports whether e reads a variable, a field or an element,
+// whose value must be copied when it is stored elsewhere.
+func isLvalue(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.TupleIndexExpr:
+		return true
+	case *ast.IndexExpr:
+		return len(e.Indices) == 1
+	}
+	return false
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+// name returns the JavaScript name of obj.
+func (g *generator) name(obj types.Object) string {
+	if name, ok := g.names[obj]; ok {
+		if ref, ok := g.externImports[obj]; ok {
+			g.importName(ref.module, ref.name)
+		}
+		return name
+	}
+	if g.locals != nil {
+		if name, ok := g.locals.names[obj]; ok {
+			return name
+		}
+		return g.locals.declare(obj)
+	}
+	return Mangle(obj.Name())
+}
+
+// ----------------------------------------------------------------------------
+// Literals
+
+// constLit returns the literal of the constant val of type T.
+func constLit(val constant.Value, T types.Type) js {
+	switch val.Kind() {
+	case constant.Bool:
+		return js{strconv.FormatBool(constant.BoolVal(val)), precPrimary}
+	case constant.String:
+		return js{jsString(constant.StringVal(val)), precPrimary}
+	case constant.Int, constant.Float:
+		var s string
+		switch {
+		case isInt64(T):
+			s = constant.ToInt(val).String() + "n"
+		case isFloat(T) || val.Kind() == constant.Float:
+			f, _ := constant.Float64Val(constant.ToFloat(val))
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+			if math.IsInf(f, 0) {
+				s = strings.Replace(s, "Inf", "Infinity", 1)
+			}
+		default:
+			s = constant.ToInt(val).String()
+		}
+		if strings.HasPrefix(s, "-") {
+			return js{s, precUnary}
+		}
+		return js{s, precPrimary}
+	}
+	return undefined
+}
+
+// jsString returns the JavaScript string literal of s.
+func jsString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case utf8.RuneError, '\u2028', '\u2029':
+			b.WriteString(`\u` + strconv.FormatInt(int64(r), 16))
+		default:
+			if r < 0x20 || r == 0x7f {
+				b.WriteString(`\x`)
+				if r < 0x10 {
+					b.WriteByte('0')
+				}
+				b.WriteString(strconv.FormatInt(int64(r), 16))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (g *generator) basicLit(e *ast.BasicLit) js {
+	switch e.Kind {
+	case lexer.NIL:
+		return js{"null", precPrimary}
+	case lexer.TEMPLATE:
+		s := strings.TrimSuffix(strings.TrimPrefix(e.Value, "`"), "`")
+		return js{jsString(s), precPrimary}
+	}
+	g.errorf(e.Pos(), "%s literals are not supported", strings.ToLower(e.Kind.String()))
+	return undefined
+}
+
+// compositeLit returns a literal of a struct, record, array, slice or map.
+func (g *generator) compositeLit(e *ast.CompositeLit) js {
+	T := g.info.TypeOf(e)
+	switch u := T.Underlying().(type) {
+	case *types.Struct, *types.Record:
+		fields := structFields(u)
+		// the elements are evaluated in the order of the literal
+		index := make([]int, len(e.Elts))
+		xs := g.operands(len(e.Elts), func(i int) (js, ast.Expr) {
+			elt := e.Elts[i]
+			index[i] = i
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				f := g.info.Uses[kv.Key.(*ast.Ident)].(*types.Var)
+				for j := range fields {
+					if fields[j] == f {
+						index[i] = j
+					}
+				}
+				elt = kv.Value
+			}
+			return g.value(elt, fields[index[i]].Type()), elt
+		})
+		vals := make([]js, len(fields))
+		for i, x := range xs {
+			vals[index[i]] = x
+		}
+		return g.structLit(T, fields, vals)
+
+	case *types.Slice:
+		return g.indexedLit(e.Elts, u.Elem(), -1)
+	case *types.Array:
+		return g.indexedLit(e.Elts, u.Elem(), u.Len())
+
+	case *types.Map:
+		xs := g.operands(2*len(e.Elts), func(i int) (js, ast.Expr) {
+			kv := e.Elts[i/2].(*ast.KeyValueExpr)
+			if i%2 == 0 {
+				return g.value(kv.Key, u.Key()), kv.Key
+			}
+			return g.value(kv.Value, u.Elem()), kv.Value
+		})
+		entries := make([]string, len(e.Elts))
+		for i := range entries {
+			entries[i] = "[" + xs[2*i].code + ", " + xs[2*i+1].code + "]"
+		}
+		class := "Map"
+		if !primitiveKey(u.Key()) {
+			class = g.use("$Map")
+		}
+		if len(entries) == 0 {
+			return js{"new " + class + "()", precCall}
+		}
+		return js{"new " + class + "([" + strings.Join(entries, ", ") + "])", precCall}
+	}
+	g.errorf(e.Pos(), "cannot generate literal of type %s", T)
+	return undefined
+}
+
+// structLit returns a literal of the struct or record type T whose fields
+// take the values vals. Fields whose value has no code take their zero
+// values.
+func (g *generator) structLit(T types.Type, fields []*types.Var, vals []js) js {
+	if n, ok := T.(*types.Named); ok && hasClass(n) {
+		if n.Origin() != n {
+			// the class of a generic type defaults the fields whose types
+			// depend on its type parameters to no value of their instances
+			vals = slices.Clone(vals)
+			orig := structFields(n.Origin().Underlying())
+			for i, f := range fields {
+				if zero := g.zero(f.Type()); vals[i].code == "" && zero != g.zero(orig[i].Type()) {
+					vals[i] = js{zero, precPrimary}
+				}
+			}
+		}
+		// trailing fields take their default values
+		end := len(vals)
+		for end > 0 && vals[end-1].code == "" {
+			end--
+		}
+		args := make([]string, end)
+		for i := range args {
+			args[i] = vals[i].code
+			if args[i] == "" {
+				args[i] = "undefined"
+			}
+		}
+		return js{"new " + g.className(n) + "(" + strings.Join(args, ", ") + ")", precCall}
+	}
+	var props []string
+	for i, f := range fields {
+		v := vals[i].code
+		if v == "" {
+			if _, ok := f.Type().Underlying().(*types.Optional); ok && isExtern(T) {
+				// JavaScript APIs expect missing options to be absent
+				continue
+			}
+			v = g.zero(f.Type())
+		}
+		props = append(props, fieldName(T, f)+": "+v)
+	}
+	obj := "{ " + strings.Join(props, ", ") + " }"
+	if len(props) == 0 {
+		obj = "{}"
+	}
+	if _, ok := T.Underlying().(*types.Record); ok {
+		return js{"Object.freeze(" + obj + ")", precCall}
+	}
+	return js{obj, precPrimary}
+}
+
+// indexedLit returns an array literal whose elements may set their
+// indices. Arrays have length elements, the missing ones being zero.
+func (g *generator) indexedLit(elts []ast.Expr, elem types.Type, length int64) js {
+	var vals []string
+	indices := make([]int, len(elts))
+	index := 0
+	xs := g.operands(len(elts), func(i int) (js, ast.Expr) {
+		elt := elts[i]
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			n, _ := constant.Int64Val(g.info.Types[kv.Key].Value)
+			index = int(n)
+			elt = kv.Value
+		}
+		indices[i] = index
+		index++
+		return g.value(elt, elem), elt
+	})
+	for i, x := range xs {
+		for len(vals) <= indices[i] {
+			vals = append(vals, "")
+		}
+		vals[indices[i]] = x.code
+	}
+	for int64(len(vals)) < length {
+		vals = append(vals, "")
+	}
+	for i, v := range vals {
+		if v == "" {
+			vals[i] = g.zero(elem)
+		}
+	}
+	if len(vals) == 0 && length < 0 {
+		return js{"[]", precPrimary}
+	}
+	if len(vals) > 0 && length >= 0 && len(elts) == 0 {
+		return js{g.zero(types.NewArray(elem, length)), precCall}
+	}
+	return js{"[" + strings.Join(vals, ", ") + "]", precPrimary}
+}
+
+// ----------------------------------------------------------------------------
+// Functions
+
+// funcLit returns an arrow function.
+func (g *generator) funcLit(e *ast.FuncLit) js {
+	sig := g.info.TypeOf(e).(*types.Signature)
+	params := g.params(sig, "")
+	head := "(" + strings.Join(params, ", ") + ") =>"
+
+	outer, inline := g.fn, g.inline
+	g.fn, g.inline = &funcState{sig: sig}, 0
+	defer func() { g.fn, g.inline = outer, inline }()
+
+	if e.Result != nil {
+		var T types.Type
+		if len(sig.Results()) == 1 {
+			T = sig.Results()[0].Type()
+		}
+		var r js
+		hoisted := g.capture(func() {
+			g.indent++
+			r = g.value(e.Result, T)
+			g.indent--
+		})
+		if hoisted == "" {
+			code := r.at(precAssign)
+			if strings.HasPrefix(plain(code), "{") {
+				code = "(" + code + ")"
+			}
+			return js{head + " " + code, precAssign}
+		}
+		// the body computes the result with the statements hoisted
+		body := g.capture(func() {
+			g.out.WriteString(head + " {\n" + hoisted + "\n")
+			g.indent++
+			if len(sig.Results()) == 0 {
+				g.exprStmt(r)
+			} else {
+				g.line("return %s;", r.code)
+			}
+			g.indent--
+			g.line("}")
+		})
+		return js{body, precAssign}
+	}
+	body := g.capture(func() {
+		g.out.WriteString(head + " {\n")
+		g.indent++
+		g.namedResults(sig)
+		g.stmtList(e.Body.List)
+		g.indent--
+		g.line("}")
+	})
+	return js{body, precAssign}
+}
+
+// params declares the parameters of sig and returns their names, preceded
+// by first if it is set.
+func (g *generator) params(sig *types.Signature, first string) []string {
+	var names []string
+	if first != "" {
+		names = append(names, first)
+	}
+	for _, p := range sig.Params() {
+		if p.Name() == "" || p.Name() == "_" {
+			names = append(names, g.locals.temp("_"))
+			continue
+		}
+		names = append(names, g.named(p, g.locals.declare(p)))
+	}
+	return names
+}
+
+// function generates a function declaration, whose head is written before
+// its parameters. first names a parameter preceding the others.
+func (g *generator) function(head string, sig *types.Signature, ftype *ast.FuncType, body *ast.BlockStmt, first string) {
+	g.functionWith(head, sig, ftype, body, first, "")
+}
+
+// functionWith generates a function declaration whose body starts with the
+// statement prologue.
+func (g *generator) functionWith(head string, sig *types.Signature, ftype *ast.FuncType, body *ast.BlockStmt, first, prologue string) {
+	outer, inline := g.fn, g.inline
+	g.fn, g.inline = &funcState{sig: sig}, 0
+	defer func() { g.fn, g.inline = outer, inline }()
+
+	params := g.params(sig, first)
+	if body == nil {
+		g.line("%s(%s) {}", head, strings.Join(params, ", "))
+		return
+	}
+	g.open("%s(%s) {", head, strings.Join(params, ", "))
+	if prologue != "" {
+		g.line("%s", prologue)
+	}
+	g.namedResults(sig)
+	g.stmtList(body.List)
+	g.close("}")
+}
+
+// namedResults declares the named results of sig, which hold their zero
+// values.
+func (g *generator) namedResults(sig *types.Signature) {
+	var decls []string
+	for _, r := range sig.Results() {
+		if r.Name() == "" {
+			continue
+		}
+		name := g.locals.declare(r)
+		if r.Name() == "_" {
+			name = g.locals.temp("_")
+			g.locals.names[r] = name
+		}
+		g.fn.results = append(g.fn.results, name)
+		if zero := g.zero(r.Type()); zero != "undefined" {
+			name += " = " + zero
+		}
+		decls = append(decls, name)
+	}
+	if len(decls) > 0 {
+		g.line("let %s;", strings.Join(decls, ", "))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Selectors and indices
+
+func (g *generator) selector(e *ast.SelectorExpr) js {
+	sel := g.info.Selections[e]
+	if sel == nil {
+		// an enum variant
+		if tv := g.info.Types[e.X]; tv.IsType() {
+			if n, ok := tv.Type.(*types.Named); ok {
+				return g.variant(n, e.Sel.Name)
+			}
+		}
+		g.errorf(e.Pos(), "cannot generate %s", ast.ExprString(e))
+		return undefined
+	}
+
+	switch sel.Kind() {
+	case types.FieldVal:
+		index := sel.Index()
+		x, T := g.fieldPath(g.expr(e.X), g.info.TypeOf(e.X), index[:len(index)-1])
+		x, FT := g.fieldPath(x, T, index[len(index)-1:])
+		if o, ok := T.Underlying().(*types.Optional); ok {
+			T = o.Elem()
+		}
+		if isExtern(T) {
+			return fromJS(x, FT)
+		}
+		return x
+
+	case types.MethodVal:
+		index := sel.Index()
+		recv, T := g.fieldPath(g.expr(e.X), g.info.TypeOf(e.X), index[:len(index)-1])
+		fn := sel.Obj().(*types.Func)
+		if isBoxed(T) {
+			return js{g.className(T.(*types.Named)) + "." + staticName(fn.Name()) + ".bind(null, " + recv.code + ")", precCall}
+		}
+		name := propName(fn.Name())
+		if ext := fn.Extern(); ext != nil {
+			name = ext.Name
+		}
+		// the method value keeps the receiver as it is now
+		recv = g.clone(recv, T)
+		return js{g.use("$bind") + "(" + recv.code + ", " + jsString(name) + ")", precCall}
+
+	case types.MethodExpr:
+		T := sel.Recv()
+		fn := sel.Obj().(*types.Func)
+		index := sel.Index()
+		if len(index) == 1 && isBoxed(T) {
+			return js{g.className(T.(*types.Named)) + "." + staticName(fn.Name()), precCall}
+		}
+		recv, RT := g.fieldPath(js{"$recv", precPrimary}, T, index[:len(index)-1])
+		call := g.methodCall(recv, RT, fn, []string{"...args"})
+		return js{"($recv, ...args) => " + call.code, precAssign}
+	}
+	return undefined
+}
+
+// fieldPath returns the field of x, of type T, reached through the fields
+// of the given indices, and its type.
+func (g *generator) fieldPath(x js, T types.Type, index []int) (js, types.Type) {
+	for _, i := range index {
+		if o, ok := T.Underlying().(*types.Optional); ok {
+			T = o.Elem()
+		}
+		f := structFields(types.CoreType(T))[i]
+		x = js{x.at(precCall) + "." + fieldName(T, f), precCall}
+		T = f.Type()
+	}
+	return x, T
+}
+
+// methodCall returns the call of the method fn of recv, of type T.
+func (g *generator) methodCall(recv js, T types.Type, fn *types.Func, args []string) js {
+	if fn.Extern() != nil {
+		return g.externMethodCall(recv, fn, args)
+	}
+	if isBoxed(T) {
+		args = append([]string{recv.code}, args...)
+		return js{g.className(T.(*types.Named)) + "." + staticName(fn.Name()) + "(" + strings.Join(args, ", ") + ")", precCall}
+	}
+	return js{recv.at(precCall) + "." + propName(fn.Name()) + "(" + strings.Join(args, ", ") + ")", precCall}
+}
+
+// isInstantiation reports whether e instantiates a generic function or
+// type, which compiled code does not distinguish from the generic one.
+func (g *generator) isInstantiation(e *ast.IndexExpr) bool {
+	var ident *ast.Ident
+	switch x := unparen(e.X).(type) {
+	case *ast.Ident:
+		ident = x
+	case *ast.SelectorExpr:
+		ident = x.Sel
+	}
+	if ident == nil {
+		return false
+	}
+	_, ok := g.info.Instances[ident]
+	if !ok {
+		if tv, found := g.info.Types[e.X]; found && tv.IsType() {
+			return true
+		}
+		if sig, isSig := g.info.TypeOf(e.X).(*types.Signature); isSig && len(sig.TypeParams()) > 0 {
+			return true
+		}
+	}
+	return ok
+}
+
+func (g *generator) index(e *ast.IndexExpr) js {
+	if g.isInstantiation(e) {
+		return g.expr(e.X)
+	}
+	x := g.expr(e.X)
+	start := g.out.Len()
+	switch u := types.CoreType(g.info.TypeOf(e.X)).(type) {
+	case *types.Map:
+		key := g.value(e.Indices[0], u.Key())
+		get := g.spill(x, e.X, start).at(precCall) + ".get(" + key.code + ")"
+		if zero := g.zero(u.Elem()); zero != "undefined" {
+			return js{get + " ?? " + zero, precNullish}
+		}
+		return js{get, precCall}
+	}
+	i := g.expr(e.Indices[0])
+	return js{g.use("$at") + "(" + g.spill(x, e.X, start).code + ", " + i.code + ")", precCall}
+}
+
+// ----------------------------------------------------------------------------
+// Operators
+
+func (g *generator) unary(e *ast.UnaryExpr) js {
+	T := g.info.TypeOf(e)
+	x := g.expr(e.X)
+	switch e.Op {
+	case lexer.ADD:
+		return x
+	case lexer.SUB:
+		neg := js{"-" + x.at(precUnary), precUnary}
+		switch {
+		case isInt64(T):
+			return js{"BigInt.asIntN(64, " + neg.code + ")", precCall}
+		case isInt(T):
+			return js{neg.code + " | 0", precBitOr}
+		}
+		return neg
+	case lexer.NOT:
+		return js{"!" + x.at(precUnary), precUnary}
+	case lexer.BIT_NOT:
+		return js{"~" + x.at(precUnary), precUnary}
+	}
+	g.errorf(e.Pos(), "cannot generate %s", ast.ExprString(e))
+	return undefined
+}
+
+// binaryOps holds the JavaScript operators and their precedence.
+var binaryOps = map[lexer.Token]struct {
+	op   string
+	prec int
+}{
+	lexer.ADD:       {"+", precAdd},
+	lexer.SUB:       {"-", precAdd},
+	lexer.MULT:      {"*", precMul},
+	lexer.DIV:       {"/", precMul},
+	lexer.MOD:       {"%", precMul},
+	lexer.BIT_AND:   {"&", precBitAnd},
+	lexer.BIT_OR:    {"|", precBitOr},
+	lexer.BIT_NOT:   {"^", precBitXor},
+	lexer.BIT_LEFT:  {"<<", precShift},
+	lexer.BIT_RIGHT: {">>", precShift},
+	lexer.AND:       {"&&", precAnd},
+	lexer.OR:        {"||", precOr},
+	lexer.LT:        {"<", precRel},
+	lexer.GT:        {">", precRel},
+	lexer.LTEQ:      {"<=", precRel},
+	lexer.GTEQ:      {">=", precRel},
+}
+
+func (g *generator) binary(e *ast.BinaryExpr) js {
+	switch e.Op {
+	case lexer.EQ, lexer.NEQ:
+		return g.equal(e.X, e.Y, e.Op == lexer.NEQ)
+	case lexer.AND, lexer.OR:
+		// true && y is y, and false && y is false
+		if value, ok := g.foldedCond(e.X); ok {
+			if value == (e.Op == lexer.AND) {
+				return g.expr(e.Y)
+			}
+			return js{strconv.FormatBool(value), precPrimary}
+		}
+		// y is evaluated only if x does not decide
+		x := g.expr(e.X)
+		y := g.inlined(func() js { return g.expr(e.Y) })
+		return g.arith(e.Op, x, y, g.info.TypeOf(e))
+	}
+	xs := g.operands(2, func(i int) (js, ast.Expr) {
+		operand := []ast.Expr{e.X, e.Y}[i]
+		return g.expr(operand), operand
+	})
+	return g.arith(e.Op, xs[0], xs[1], g.info.TypeOf(e))
+}
+
+// infix returns x op y.
+func infix(x js, op string, prec int, y js) js {
+	return js{x.at(prec) + " " + op + " " + y.at(prec+1), prec}
+}
+
+// arith returns x op y for operands of type T, wrapping integers around.
+func (g *generator) arith(op lexer.Token, x, y js, T types.Type) js {
+	b, ok := binaryOps[op]
+	if !ok && op != lexer.BIT_CLEAR {
+		return undefined
+	}
+	switch {
+	case isInt(T):
+		switch op {
+		case lexer.ADD, lexer.SUB:
+			return js{infix(x, b.op, b.prec, y).code + " | 0", precBitOr}
+		case lexer.MULT:
+			return js{"Math.imul(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.DIV:
+			return js{g.use("$div") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.MOD:
+			return js{g.use("$rem") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.BIT_LEFT:
+			return js{g.use("$shl") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.BIT_RIGHT:
+			return js{g.use("$shr") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.BIT_CLEAR:
+			return infix(x, "&", precBitAnd, js{"~" + y.at(precUnary), precUnary})
+		}
+	case isInt64(T):
+		wrap := func(x js) js { return js{"BigInt.asIntN(64, " + x.code + ")", precCall} }
+		switch op {
+		case lexer.ADD, lexer.SUB, lexer.MULT:
+			return wrap(infix(x, b.op, b.prec, y))
+		case lexer.DIV:
+			return js{g.use("$div64") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.MOD:
+			return js{g.use("$rem64") + "(" + x.code + ", " + y.code + ")", precCall}
+		case lexer.BIT_LEFT:
+			return wrap(infix(x, "<<", precShift, js{"BigInt(" + y.code + ")", precCall}))
+		case lexer.BIT_RIGHT:
+			return infix(x, ">>", precShift, js{"BigInt(" + y.code + ")", precCall})
+		case lexer.BIT_CLEAR:
+			return infix(x, "&", precBitAnd, js{"~" + y.at(precUnary), precUnary})
+		}
+	}
+	return infix(x, b.op, b.prec, y)
+}
+
+// equal returns the comparison of x and y, or their difference if neg is
+// set.
+func (g *generator) equal(ex, ey ast.Expr, neg bool) js {
+	xs := g.operands(2, func(i int) (js, ast.Expr) {
+		operand := []ast.Expr{ex, ey}[i]
+		return g.expr(operand), operand
+	})
+	return g.equalJS(xs[0], g.info.TypeOf(ex), xs[1], g.info.TypeOf(ey), neg)
+}
+
+// equalJS returns the comparison of x of type X and y of type Y, or their
+// difference if neg is set.
+func (g *generator) equalJS(x js, X types.Type, y js, Y types.Type, neg bool) js {
+	op := "==="
+	if neg {
+		op = "!=="
+	}
+	if isBasic(X, types.UntypedNil) || isBasic(Y, types.UntypedNil) || identityEqual(X) && identityEqual(Y) {
+		return infix(x, op, precEq, y)
+	}
+	T := X
+	if isBasic(T, types.UntypedNil) || types.AssignableTo(X, Y) && !types.AssignableTo(Y, X) {
+		T = Y
+	}
+	x, y = g.box(x, X, T), g.box(y, Y, T)
+	eq := js{g.use("$equal") + "(" + x.code + ", " + y.code + ")", precCall}
+	if neg {
+		return js{"!" + eq.code, precUnary}
+	}
+	return eq
+}
+
+// ----------------------------------------------------------------------------
+// Calls
+
+func (g *generator) call(e *ast.CallExpr) js {
+	if tv := g.info.Types[e.Fun]; tv.IsType() {
+		return g.conversion(e.Args[0], tv.Type)
+	}
+	if ident, ok := unparen(e.Fun).(*ast.Ident); ok {
+		if b, ok := g.info.Uses[ident].(*types.Builtin); ok {
+			return g.builtin(e, b)
+		}
+	}
+
+	var sig *types.Signature
+	if T := g.info.TypeOf(e.Fun); T != nil {
+		sig, _ = types.CoreType(T).(*types.Signature)
+	}
+	if fn := g.externFunc(e.Fun); fn != nil {
+		return g.externCall(fn, g.args(e.Args, sig))
+	}
+
+	if sel, ok := unparen(e.Fun).(*ast.SelectorExpr); ok {
+		if s := g.info.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+			index := s.Index()
+			recv, T := g.fieldPath(g.expr(sel.X), g.info.TypeOf(sel.X), index[:len(index)-1])
+			start := g.out.Len()
+			args := g.args(e.Args, sig)
+			recv = g.spill(recv, sel.X, start)
+			return g.methodCall(recv, T, s.Obj().(*types.Func), args)
+		}
+	}
+	fun := g.expr(e.Fun)
+	start := g.out.Len()
+	args := g.args(e.Args, sig)
+	fun = g.spill(fun, e.Fun, start)
+	return js{fun.at(precCall) + "(" + strings.Join(args, ", ") + ")", precCall}
+}
+
+// args returns the arguments of a call of a function of type sig. The
+// results of a call with several results passed as the arguments are
+// spread.
+func (g *generator) args(list []ast.Expr, sig *types.Signature) []string {
+	var params []*types.Var
+	if sig != nil {
+		params = sig.Params()
+	}
+	if len(list) == 1 && len(params) > 1 {
+		if _, ok := g.info.TypeOf(list[0]).(*types.Tuple); ok {
+			return []string{"..." + g.expr(list[0]).at(precAssign)}
+		}
+	}
+	xs := g.operands(len(list), func(i int) (js, ast.Expr) {
+		var T types.Type
+		if i < len(params) {
+			T = params[i].Type()
+		}
+		return g.value(list[i], T), list[i]
+	})
+	return codes(xs)
+}
+
+// codes returns the code of the expressions xs.
+func codes(xs []js) []string {
+	cs := make([]string, len(xs))
+	for i, x := range xs {
+		cs[i] = x.code
+	}
+	return cs
+}
+
+// conversion returns the conversion of x to type T.
+func (g *generator) conversion(arg ast.Expr, T types.Type) js {
+	V := g.info.TypeOf(arg)
+	x := g.value(arg, T)
+	switch {
+	case isInt(T) && isFloat(V):
+		return js{"Math.trunc(" + x.code + ") | 0", precBitOr}
+	case isInt(T) && isInt64(V):
+		return js{"Number(BigInt.asIntN(32, " + x.code + "))", precCall}
+	case isInt64(T) && isFloat(V):
+		return js{"BigInt.asIntN(64, BigInt(Math.trunc(" + x.code + ")))", precCall}
+	case isInt64(T) && isInt(V):
+		return js{"BigInt(" + x.code + ")", precCall}
+	case isFloat(T) && isInt64(V):
+		return js{"Number(" + x.code + ")", precCall}
+	}
+	if g.isTagged(V) {
+		if _, ok := T.Underlying().(*types.Enum); !ok {
+			// the value of a variant of a backed enum
+			return js{x.at(precCall) + ".$value", precCall}
+		}
+	}
+	if tn, ok := T.(*types.Named); ok && hasClass(tn) && !isBoxed(tn) {
+		if vn, ok := V.(*types.Named); !ok || vn.Origin() != tn.Origin() {
+			if _, isEnum := tn.Underlying().(*types.Enum); !isEnum {
+				return js{g.use("$convert") + "(" + x.code + ", " + g.className(tn) + ")", precCall}
+			}
+		}
+	}
+	return x
+}
+
+// builtin returns the call of a builtin function.
+func (g *generator) builtin(e *ast.CallExpr, b *types.Builtin) js {
+	switch b.Name() {
+	case "len":
+		x := g.expr(e.Args[0])
+		if _, ok := types.CoreType(g.info.TypeOf(e.Args[0])).(*types.Map); ok {
+			return js{x.at(precCall) + ".size", precCall}
+		}
+		return js{x.at(precCall) + ".length", precCall}
+
+	case "append":
+		var elem types.Type
+		if sl, ok := types.CoreType(g.info.TypeOf(e)).(*types.Slice); ok {
+			elem = sl.Elem()
+		}
+		xs := g.operands(len(e.Args), func(i int) (js, ast.Expr) {
+			if i == 0 {
+				return g.expr(e.Args[0]), e.Args[0]
+			}
+			return g.value(e.Args[i], elem), e.Args[i]
+		})
+		elts := append([]string{"..." + xs[0].at(precAssign)}, codes(xs[1:])...)
+		return js{"[" + strings.Join(elts, ", ") + "]", precPrimary}
+
+	case "delete":
+		var key types.Type
+		if mt, ok := types.CoreType(g.info.TypeOf(e.Args[0])).(*types.Map); ok {
+			key = mt.Key()
+		}
+		m := g.expr(e.Args[0])
+		start := g.out.Len()
+		k := g.value(e.Args[1], key)
+		return js{g.spill(m, e.Args[0], start).at(precCall) + ".delete(" + k.code + ")", precCall}
+
+	case "json_decode":
+		s := g.value(e.Args[1], types.Typ[types.String])
+		return js{g.use("$decodeJSON") + "(" + s.code + ", " + g.jsonDesc(g.info.TypeOf(e)) + ")", precCall}
+
+	case "json_encode":
+		T := g.info.TypeOf(e.Args[0])
+		x := g.value(e.Args[0], T)
+		return js{g.use("$encodeJSON") + "(" + x.code + ", " + g.jsonDesc(T) + ")", precCall}
+
+	case "panic":
+		return js{g.use("$panic") + "(" + g.value(e.Args[0], nil).code + ")", precCall}
+
+	case "print":
+		xs := g.operands(len(e.Args), func(i int) (js, ast.Expr) {
+			x := g.expr(e.Args[i])
+			if isInt64(g.info.TypeOf(e.Args[i])) {
+				x = js{"String(" + x.code + ")", precCall}
+			}
+			return x, e.Args[i]
+		})
+		return js{"console.log(" + strings.Join(codes(xs), ", ") + ")", precCall}
+	}
+	g.errorf(e.Pos(), "cannot generate call of %s", b.Name())
+	return undefined
+}
+
+// with returns the copy of a record with some fields replaced.
+func (g *generator) with(e *ast.WithExpr) js {
+	T := g.info.TypeOf(e.X)
+	fields := structFields(T)
+	xs := g.operands(len(e.Elts)+1, func(i int) (js, ast.Expr) {
+		if i == 0 {
+			return g.expr(e.X), e.X
+		}
+		kv := e.Elts[i-1].(*ast.KeyValueExpr)
+		var FT types.Type
+		for _, f := range fields {
+			if f.Name() == kv.Key.(*ast.Ident).Name {
+				FT = f.Type()
+			}
+		}
+		return g.value(kv.Value, FT), kv.Value
+	})
+	props := make([]string, len(e.Elts))
+	for i, elt := range e.Elts {
+		props[i] = propName(elt.(*ast.KeyValueExpr).Key.(*ast.Ident).Name) + ": " + xs[i+1].code
+	}
+	return js{g.use("$with") + "(" + xs[0].code + ", { " + strings.Join(props, ", ") + " })", precCall}
+}
@@ -0,0 +1,260 @@
+package js
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// externOf returns the JavaScript binding of the package-level object obj
+// if it is an extern function or variable.
+func externOf(obj types.Object) *types.Extern {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Signature() != nil && obj.Signature().Recv() == nil {
+			return obj.Extern()
+		}
+	case *types.Var:
+		return obj.Extern()
+	}
+	return nil
+}
+
+// isExtern reports whether T is an extern type, whose values are
+// JavaScript objects, numbers or strings.
+func isExtern(T types.Type) bool {
+	n, ok := T.(*types.Named)
+	return ok && n.Extern()
+}
+
+// externs names the extern functions and variables of the package. Those
+// bound to globals are referred to by their path, from globalThis when a
+// declaration of the module takes the name of the global. Those bound to
+// modules are imported by the root of their path, under the names of the
+// imported bindings unless these are taken, if the module uses them.
+func (g *generator) externs(objs []types.Object) {
+	roots := make(map[string]bool)
+	for _, obj := range objs {
+		ext := externOf(obj)
+		if ext.Module != "" {
+			continue
+		}
+		root, _, _ := strings.Cut(ext.Name, ".")
+		if g.globals[root] && !roots[root] {
+			g.names[obj] = "globalThis." + ext.Name
+			continue
+		}
+		roots[root] = true
+		g.globals[root] = true
+		g.names[obj] = ext.Name
+	}
+	for _, obj := range objs {
+		ext := externOf(obj)
+		if ext.Module == "" {
+			continue
+		}
+		root, path, _ := strings.Cut(ext.Name, ".")
+		g.names[obj] = g.reserveImport(ext.Module, root, Mangle(root), "$import")
+		if path != "" {
+			g.names[obj] += "." + path
+		}
+		g.externImports[obj] = importRef{ext.Module, root}
+	}
+}
+
+// An importRef is a name imported from a module.
+type importRef struct {
+	module, name string
+}
+
+// reserveImport returns the local name of the name imported from module,
+// reserving base, or fallback if base is not an identifier, possibly
+// numbered, if the name has none yet.
+func (g *generator) reserveImport(module, name, base, fallback string) string {
+	if g.importLocals[module] == nil {
+		g.importLocals[module] = make(map[string]string)
+	}
+	if local, ok := g.importLocals[module][name]; ok {
+		return local
+	}
+	if !isJSIdent(base) {
+		base = fallback
+	}
+	local := base
+	for i := 1; g.globals[local]; i++ {
+		local = base + "$" + strconv.Itoa(i)
+	}
+	g.globals[local] = true
+	g.importLocals[module][name] = local
+	return local
+}
+
+// importName adds the name imported from module to the imports of the
+// module, under the local name reserved for it.
+func (g *generator) importName(module, name string) {
+	if g.imports[module] == nil {
+		g.imports[module] = make(map[string]string)
+	}
+	g.imports[module][name] = g.importLocals[module][name]
+}
+
+// importLines returns the import declarations of the module, sorted by
+// module. The name "*" imports the namespace of a module.
+func (g *generator) importLines() []string {
+	modules := make([]string, 0, len(g.imports))
+	for module := range g.imports {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	var lines []string
+	for _, module := range modules {
+		if local, ok := g.imports[module]["*"]; ok {
+			lines = append(lines, "import * as "+local+" from "+jsString(module)+";")
+		}
+		var list []string
+		for name, local := range g.imports[module] {
+			if name == "*" {
+				continue
+			}
+			if !isJSIdent(name) {
+				name = jsString(name)
+			}
+			if name != local {
+				name += " as " + local
+			}
+			list = append(list, name)
+		}
+		if len(list) == 0 {
+			continue
+		}
+		sort.Strings(list)
+		lines = append(lines, "import { "+strings.Join(list, ", ")+" } from "+jsString(module)+";")
+	}
+	return lines
+}
+
+// isJSIdent reports whether s may be written as a JavaScript identifier or
+// property name after a dot.
+func isJSIdent(s string) bool {
+	for i, r := range s {
+		if r != '_' && r != '$' && !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') && (i == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// member returns the property name of x.
+func member(x js, name string) js {
+	if isJSIdent(name) {
+		return js{x.at(precCall) + "." + name, precCall}
+	}
+	return js{x.at(precCall) + "[" + jsString(name) + "]", precCall}
+}
+
+// externProp returns the JavaScript name of the field of an extern type
+// named name. A trailing "_" makes a property named by a word Gusset
+// reserves a valid field name, as in default_.
+func externProp(name string) string {
+	if base, ok := strings.CutSuffix(name, "_"); ok && lexer.IsReserved(base) {
+		return base
+	}
+	return name
+}
+
+// fieldName returns the JavaScript property name of the field f of the
+// struct or record type T.
+func fieldName(T types.Type, f *types.Var) string {
+	if isExtern(T) {
+		return externProp(f.Name())
+	}
+	return propName(f.Name())
+}
+
+// fromJS returns the value x of type T received from JavaScript, which
+// represents missing values by undefined as well as null.
+func fromJS(x js, T types.Type) js {
+	if T == nil {
+		return x
+	}
+	switch T.Underlying().(type) {
+	case *types.Optional, *types.Interface:
+		return js{x.at(precBitOr) + " ?? null", precNullish}
+	}
+	return x
+}
+
+// externFunc returns the extern function that e, the callee of a call,
+// refers to, if any.
+func (g *generator) externFunc(e ast.Expr) *types.Func {
+	e = unparen(e)
+	if ix, ok := e.(*ast.IndexExpr); ok && g.isInstantiation(ix) {
+		e = unparen(ix.X)
+	}
+	ident, ok := e.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	fn, _ := g.info.Uses[ident].(*types.Func)
+	if fn == nil || externOf(fn) == nil {
+		return nil
+	}
+	return fn
+}
+
+// externCall returns the call of the extern function fn with the given
+// arguments, which may omit its optional parameters.
+func (g *generator) externCall(fn *types.Func, args []string) js {
+	ext := fn.Extern()
+	callee := js{g.name(fn), precCall}
+	args = restArgs(ext, fn.Signature(), args)
+	var call js
+	switch {
+	case ext.New:
+		return js{"new " + callee.at(precCall) + "(" + strings.Join(args, ", ") + ")", precCall}
+	case ext.This:
+		call = js{callee.at(precCall) + ".call(" + strings.Join(args, ", ") + ")", precCall}
+	default:
+		call = js{callee.at(precCall) + "(" + strings.Join(args, ", ") + ")", precCall}
+	}
+	return fromJS(call, fn.Signature().Result())
+}
+
+// externValue returns the value of the extern function fn, which is
+// wrapped in an arrow function when its calls are not plain calls.
+func (g *generator) externValue(fn *types.Func) js {
+	ext := fn.Extern()
+	if !ext.New && !ext.This && !ext.Rest {
+		return js{g.name(fn), precPrimary}
+	}
+	params := make([]string, len(fn.Signature().Params()))
+	for i := range params {
+		params[i] = "$" + strconv.Itoa(i)
+	}
+	call := g.externCall(fn, params)
+	return js{"(" + strings.Join(params, ", ") + ") => " + call.code, precAssign}
+}
+
+// externMethodCall returns the call of the method fn of an extern type on
+// recv.
+func (g *generator) externMethodCall(recv js, fn *types.Func, args []string) js {
+	args = restArgs(fn.Extern(), fn.Signature(), args)
+	call := member(recv, fn.Extern().Name)
+	return fromJS(js{call.code + "(" + strings.Join(args, ", ") + ")", precCall}, fn.Signature().Result())
+}
+
+// restArgs spreads the last argument of a call of a function with the rest
+// attribute, unless the call omits it.
+func restArgs(ext *types.Extern, sig *types.Signature, args []string) []string {
+	n := len(sig.Params())
+	if !ext.Rest || len(args) != n || strings.HasPrefix(args[n-1], "...") {
+		return args
+	}
+	args = append([]string(nil), args...)
+	args[n-1] = "..." + args[n-1]
+	return args
+}
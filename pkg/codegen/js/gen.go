@@ -0,0 +1,845 @@
+// Package js generates JavaScript from type-checked Gusset packages. Each
+// package becomes one ES2020 module, which declares its types as classes and
+// its functions and variables as module bindings, exports the exported
+// names and, for package main, calls main once the package is initialized.
+//
+// Values are represented as follows:
+//
+//   - int is a number kept within 32 bits, int64 a BigInt kept within 64
+//     bits, and float a number; integer arithmetic wraps and division by
+//     zero panics
+//   - string and symbol are strings, and bool is a boolean
+//   - optional values are their value or null
+//   - slices and arrays are arrays, tuples are arrays of their elements,
+//     and maps are Maps, or $Maps hashing their keys when the keys are not
+//     strings, numbers, BigInts or booleans
+//   - structs and records are instances of the class of their type, or
+//     plain objects for struct and record type literals; records are
+//     frozen, and structs and arrays are copied where Gusset copies values
+//   - enum values are their value, or their name for enums without a
+//     backing type, and the values of enums with payloads are frozen
+//     objects tagged with the name of their variant; a Config may lower
+//     each enum differently, as EnumLowering describes
+//   - values of other named types with methods are themselves, and are
+//     boxed in an instance of the class of their type when they are stored
+//     in an interface, so that their methods can be called dynamically
+//
+// Generic functions and types are compiled once for all their type
+// arguments, which the type checker ensures their code does not depend on:
+// it rejects arithmetic, constants and conversions whose JavaScript
+// differs between the types of the type set of a type parameter, such as
+// int and float. Instances of generic types pass the zero values of the
+// fields that depend on their type arguments to the constructor of their
+// class.
+//
+// Matches become switch statements on the tag or value of their subject
+// when their patterns compare it to constants, and chains of if
+// statements otherwise. Arms sharing a tag are tested in its case, reading
+// the payload fields several of them test from locals. A tuple literal
+// subject that the patterns take apart is not built; they read its
+// elements directly. The value of a match is assigned or returned by its
+// arms when a statement uses it directly, and is otherwise a chain of
+// conditional expressions, nested by case where it would be a switch, or
+// statements hoisted before the expression that assign its value to a
+// temporary if its arms do not fit in one.
+//
+// Comprehensions become for loops adding to a temporary, declared before
+// the statement that uses them. Statements hoisted out of an
+// expression are written before the statement holding it, and the
+// operands evaluated before them are first held in temporaries, so that
+// they are evaluated in order. Where an expression is evaluated
+// conditionally or repeatedly, as the right operand of && and || or the
+// condition of a for loop, the statements are the body of a function
+// called on the spot instead.
+//
+// Appending to a local slice variable that the result is assigned back
+// to, as in xs = append(xs, x), pushes onto its array instead of copying
+// it when no other value can share the array: the variable only ever
+// holds arrays created for it, and is not passed, stored or captured.
+//
+// #json literals become the JavaScript values of their type, or plain
+// objects and arrays where they are of type any. Large literals whose
+// values JSON.parse returns as they are become calls to it.
+//
+// json_decode and json_encode convert values to and from JSON text at run
+// time, as the descriptor of their type passed to a runtime helper tells;
+// package-level named types are described by functions of the module.
+// Decoded structs and records are instances of their class, frozen for
+// records, so their methods and equality work as for any other value. A
+// field missing from the JSON takes its zero value, as in #json literals.
+// int64 values are limited to the integers JSON numbers hold exactly, and
+// values that do not match their type, missing fields without a zero
+// value, and values that JSON cannot represent panic.
+//
+// JSX elements become calls of the functions of the runtime the JSXConfig
+// of a Config selects: jsx and jsxs of the automatic runtime of React by
+// default, or a createElement-style factory. Intrinsic elements are
+// created by name and components by their function, with props holding
+// the attributes and children, and for components each field of their
+// props type. Components whose props are of a struct or record type with
+// a class convert the plain objects they are passed to instances of it.
+//
+// Identifiers keep their names unless JavaScript reserves them, in which
+// case Mangle appends "$". Runtime helpers, whose names start with "$",
+// are emitted into the modules that use them.
+//
+// A Config with Minify set generates a minified module. It declares only
+// the package-level functions, types and variables that its exports, its
+// init functions and main use, or whose initializers may have effects,
+// names locals a, b and so on, leaves out the branches that constant
+// conditions rule out, and keeps no whitespace or comments that do not
+// separate tokens.
+//
+// A Config with SourceMap set also returns a source map of the module,
+// which maps its statements, expressions and declarations back to the
+// Gusset sources.
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// Error is a construct the generator does not support.
+type Error struct {
+	Filename string
+	Pos      lexer.Position
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%s: %s", e.Filename, e.Pos, e.Msg)
+}
+
+// Generate writes the ES module for the package pkg, checked from files by
+// types.Check. info must hold the Types, Defs, Uses and Selections the
+// check recorded.
+func Generate(w io.Writer, pkg *types.Package, files []*ast.File, info *types.Info) error {
+	var conf Config
+	_, err := conf.Generate(w, pkg, files, info)
+	return err
+}
+
+// A Config configures the generation of modules. The zero Config generates
+// modules without source maps.
+type Config struct {
+	// SourceMap makes Generate return the source map of the module. The
+	// caller links the module to it by appending SourceMappingURL.
+	SourceMap bool
+	// Enums configures the lowering of the enum types of the package by
+	// their names, and of the others by the entry "*".
+	Enums map[string]EnumConfig
+	// JSX configures the compilation of JSX elements.
+	JSX JSXConfig
+	// Minify generates a minified module, as described in the package
+	// documentation. Source maps map the minified module.
+	Minify bool
+}
+
+// Generate is like the package-level Generate, and returns the source map
+// of the module if conf.SourceMap is set.
+func (conf *Config) Generate(w io.Writer, pkg *types.Package, files []*ast.File, info *types.Info) (*SourceMap, error) {
+	if err := checkEnums(conf.Enums, pkg); err != nil {
+		return nil, err
+	}
+	g := newGenerator(pkg, files, info)
+	g.maps = conf.SourceMap
+	g.enums = conf.Enums
+	g.jsx = conf.JSX
+	g.minify = conf.Minify
+	g.module()
+	if len(g.errors) > 0 {
+		return nil, g.errors[0]
+	}
+	code := g.bytes()
+	var m *SourceMap
+	if g.maps {
+		code, m = g.sourceMap(code)
+	}
+	_, err := w.Write(code)
+	return m, err
+}
+
+type generator struct {
+	pkg   *types.Package
+	files []*ast.File
+	info  *types.Info
+
+	out    *bytes.Buffer
+	indent int
+	errors []*Error
+	file   *ast.File // the file being generated
+
+	// globals holds the JavaScript names of the package-level declarations
+	// and runtime helpers, which locals may not shadow.
+	globals map[string]bool
+	// names maps the package-level objects to their JavaScript names.
+	names map[types.Object]string
+	// funcs maps functions and methods to their declarations.
+	funcs map[*types.Func]*ast.FuncDecl
+	// assigned holds the variables that are assigned after their
+	// declaration, which are declared with let rather than const.
+	assigned map[types.Object]bool
+	// runtime holds the runtime helpers the module uses.
+	runtime map[string]bool
+	// imports maps the modules that extern declarations and JSX elements
+	// bind to the names the module imports from them to their local names.
+	// importLocals holds the local names reserved for all the names that
+	// may be imported, and externImports the names that extern functions
+	// and variables import, which are imported when they are used.
+	imports       map[string]map[string]string
+	importLocals  map[string]map[string]string
+	externImports map[types.Object]importRef
+	// enums configures the lowering of enums, which enumConfigs holds
+	// resolved.
+	enums       map[string]EnumConfig
+	enumConfigs map[*types.Named]EnumConfig
+	// variantValues holds the values of the variants of the enums without
+	// payloads.
+	variantValues map[*types.Named][]string
+	// jsx configures the compilation of JSX elements, whose functions are
+	// imported by the roots of their paths. jsxRefs holds their local
+	// paths, in the order of JSXConfig.names.
+	jsx     JSXConfig
+	jsxRefs []string
+	// components holds the functions that JSX elements render.
+	components map[*types.Func]bool
+	// owned holds the slice variables of the function declaration being
+	// generated that appending pushes onto.
+	owned map[*types.Var]bool
+	// jsonTypes holds the descriptors of the package-level named types
+	// that values are converted to and from JSON as, and jsonLocals the
+	// local named types whose descriptors are being generated.
+	jsonTypes  []jsonType
+	jsonLocals map[*types.Named]bool
+	// elems maps the tuple literals that matches take apart without
+	// building them to the values holding their elements.
+	elems map[js][]js
+
+	locals *scope     // the locals of the top-level declaration being generated
+	fn     *funcState // the function being generated
+	// inline counts the expressions being generated that JavaScript
+	// evaluates conditionally or repeatedly, before which no statement
+	// may be hoisted.
+	inline int
+
+	minify  bool   // whether the module is minified
+	maps    bool   // whether the module has a source map
+	marks   []mark // the positions the module maps to
+	pending string // the mark of the statement whose first line is next
+}
+
+// funcState is the state of the function whose body is being generated.
+type funcState struct {
+	sig     *types.Signature
+	results []string // the names of named results
+	// targets holds the statements that break statements may leave, inner
+	// most last: the label of a switch, or "" for loops, which JavaScript
+	// breaks without a label.
+	targets []string
+}
+
+func newGenerator(pkg *types.Package, files []*ast.File, info *types.Info) *generator {
+	g := &generator{
+		pkg:      pkg,
+		files:    files,
+		info:     info,
+		out:      new(bytes.Buffer),
+		globals:  make(map[string]bool),
+		names:    make(map[types.Object]string),
+		funcs:    make(map[*types.Func]*ast.FuncDecl),
+		assigned: make(map[types.Object]bool),
+		runtime:  make(map[string]bool),
+		imports:  make(map[string]map[string]string),
+
+		importLocals:  make(map[string]map[string]string),
+		externImports: make(map[types.Object]importRef),
+		enumConfigs:   make(map[*types.Named]EnumConfig),
+		variantValues: make(map[*types.Named][]string),
+		components:    make(map[*types.Func]bool),
+		jsonLocals:    make(map[*types.Named]bool),
+		elems:         make(map[js][]js),
+	}
+	for name := range runtimeHelpers {
+		g.globals[name] = true
+	}
+	return g
+}
+
+func (g *generator) errorf(pos lexer.Position, format string, args ...any) {
+	filename := ""
+	if g.file != nil {
+		filename = g.file.Filename
+	}
+	g.errors = append(g.errors, &Error{filename, pos, fmt.Sprintf(format, args...)})
+}
+
+// ----------------------------------------------------------------------------
+// Output
+
+// line writes a line at the current indentation.
+func (g *generator) line(format string, args ...any) {
+	if format == "" {
+		g.out.WriteByte('\n')
+		return
+	}
+	g.out.WriteString(strings.Repeat("  ", g.indent))
+	g.out.WriteString(g.pending)
+	g.pending = ""
+	fmt.Fprintf(g.out, format, args...)
+	g.out.WriteByte('\n')
+}
+
+// open writes a line opening a block, whose lines are indented.
+func (g *generator) open(format string, args ...any) {
+	g.line(format, args...)
+	g.indent++
+}
+
+// close writes the line closing the block opened last.
+func (g *generator) close(format string, args ...any) {
+	g.indent--
+	g.line(format, args...)
+}
+
+// capture returns what f writes, at the current indentation, without its
+// final newline. Statements nested in expressions, such as the bodies of
+// arrow functions, are captured.
+func (g *generator) capture(f func()) string {
+	out, pending := g.out, g.pending
+	g.out, g.pending = new(bytes.Buffer), ""
+	f()
+	s := strings.TrimSuffix(g.out.String(), "\n")
+	g.out, g.pending = out, pending
+	return s
+}
+
+// Generating an expression may write statements, before the statement
+// that uses the expression, which compute the parts of the expression that
+// are statements in JavaScript, such as the loops of comprehensions. These
+// statements are hoisted unless the expression is evaluated conditionally
+// or repeatedly, and operands evaluated before them are then stored in
+// temporaries, so that they are still evaluated first.
+
+// hoisting reports whether statements may be written before the statement
+// using the expression being generated.
+func (g *generator) hoisting() bool {
+	return g.inline == 0
+}
+
+// inlined returns the expression f generates, before which no statement
+// may be hoisted.
+func (g *generator) inlined(f func() js) js {
+	g.inline++
+	defer func() { g.inline-- }()
+	return f()
+}
+
+// iife returns a function called on the spot whose body f generates,
+// standing for statements where none may be hoisted.
+func (g *generator) iife(f func()) js {
+	inline := g.inline
+	g.inline = 0
+	defer func() { g.inline = inline }()
+	code := g.capture(func() {
+		g.out.WriteString("(() => {\n")
+		g.indent++
+		f()
+		g.indent--
+		g.line("})()")
+	})
+	return js{code, precCall}
+}
+
+// temp returns a fresh name for a temporary declared by a hoisted
+// statement. Temporaries hoisted out of package-level declarations are
+// module bindings, which later declarations may not shadow.
+func (g *generator) temp(base string) string {
+	name := g.locals.temp(base)
+	if g.fn == nil {
+		g.globals[name] = true
+	}
+	return name
+}
+
+// spill returns x, the operand e generated before the offset pos of the
+// output, or, if statements written since may change its value, a
+// temporary holding it declared before them.
+func (g *generator) spill(x js, e ast.Expr, pos int) js {
+	if g.out.Len() == pos || x.code == "" || g.stable(e) {
+		return x
+	}
+	t := g.temp("t")
+	g.insertLine(pos, "const %s = %s;", t, x.at(precAssign))
+	return js{t, precPrimary}
+}
+
+// operands returns the n operands of an expression, which JavaScript
+// evaluates in turn. gen generates the i-th operand and returns the
+// expression it computes, or nil if its value cannot change. Operands
+// followed by hoisted statements are spilled.
+func (g *generator) operands(n int, gen func(i int) (js, ast.Expr)) []js {
+	var l operandList
+	for i := 0; i < n; i++ {
+		x, e := gen(i)
+		g.operand(&l, x, e)
+	}
+	g.spillOperands(&l)
+	return l.xs
+}
+
+// An operandList holds operands generated in turn, and the offsets of the
+// output after each of them.
+type operandList struct {
+	xs   []js
+	es   []ast.Expr
+	ends []int
+}
+
+// operand adds the operand x computing e to l and returns its index.
+func (g *generator) operand(l *operandList, x js, e ast.Expr) int {
+	l.xs = append(l.xs, x)
+	l.es = append(l.es, e)
+	l.ends = append(l.ends, g.out.Len())
+	return len(l.xs) - 1
+}
+
+// spillOperands spills the operands of l followed by hoisted statements.
+func (g *generator) spillOperands(l *operandList) {
+	var spilled []int
+	for i, x := range l.xs {
+		if l.ends[i] < g.out.Len() && x.code != "" && !g.stable(l.es[i]) {
+			spilled = append(spilled, i)
+		}
+	}
+	// temporaries are named in order, and declared from the last one so
+	// that the offsets of the others remain
+	temps := make([]string, len(spilled))
+	for k := range spilled {
+		temps[k] = g.temp("t")
+	}
+	for k := len(spilled) - 1; k >= 0; k-- {
+		i := spilled[k]
+		g.insertLine(l.ends[i], "const %s = %s;", temps[k], l.xs[i].at(precAssign))
+		l.xs[i] = js{temps[k], precPrimary}
+	}
+}
+
+// stable reports whether the value of e cannot change once it is
+// evaluated: e is nil, constant, a function or a variable of a type that
+// is not a value type that is never assigned after its declaration.
+func (g *generator) stable(e ast.Expr) bool {
+	if e == nil {
+		return true
+	}
+	if tv, ok := g.info.Types[e]; ok && (tv.Value != nil || tv.IsType()) {
+		return true
+	}
+	switch e := unparen(e).(type) {
+	case *ast.Ident:
+		v, ok := g.info.Uses[e].(*types.Var)
+		return !ok || !g.assigned[v] && !isValueType(v.Type())
+	case *ast.FuncLit:
+		return true
+	}
+	return false
+}
+
+// insertLine inserts a line at the current indentation at the offset pos
+// of the output.
+func (g *generator) insertLine(pos int, format string, args ...any) {
+	tail := append([]byte(nil), g.out.Bytes()[pos:]...)
+	g.out.Truncate(pos)
+	pending := g.pending
+	g.pending = ""
+	g.line(format, args...)
+	g.pending = pending
+	g.out.Write(tail)
+}
+
+// bytes returns the module: its imports and the runtime helpers it uses
+// followed by its declarations, on a single line after its header if it is
+// minified.
+func (g *generator) bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gus from package %s. DO NOT EDIT.\n", g.pkg.Name())
+	header := b.Len()
+	if imports := g.importLines(); len(imports) > 0 {
+		b.WriteString("\n" + strings.Join(imports, "\n") + "\n")
+	}
+	for _, name := range runtimeOrder {
+		if g.runtime[name] {
+			b.WriteString("\n" + runtimeHelpers[name])
+		}
+	}
+	if g.out.Len() > 0 {
+		b.WriteString("\n")
+		b.Write(g.out.Bytes())
+	}
+	if g.minify {
+		return append(b.Bytes()[:header], append(compact(b.Bytes()[header:]), '\n')...)
+	}
+	return b.Bytes()
+}
+
+// use records that the module uses the runtime helper name and returns the
+// name.
+func (g *generator) use(name string) string {
+	if g.runtime[name] {
+		return name
+	}
+	g.runtime[name] = true
+	for _, dep := range runtimeDeps[name] {
+		g.use(dep)
+	}
+	return name
+}
+
+// ----------------------------------------------------------------------------
+// Modules
+
+// module generates the package: its types, then its functions, constants
+// and variables, and finally the calls of its init functions and of main.
+func (g *generator) module() {
+	g.collect()
+	var keep map[types.Object]bool
+	if g.minify {
+		keep = g.reachable()
+	}
+	kept := func(obj types.Object) bool { return keep == nil || keep[obj] }
+
+	var vars []*ast.ValueSpec
+	varFiles := make(map[*ast.ValueSpec]*ast.File)
+	var inits []string
+	var exports []string
+	export := func(obj types.Object) {
+		if ast.IsExported(obj.Name()) {
+			exports = append(exports, obj.Name())
+		}
+	}
+
+	for _, file := range g.files {
+		g.file = file
+		for _, decl := range file.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != lexer.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				s := spec.(*ast.TypeSpec)
+				if !kept(g.info.Defs[s.Name]) {
+					continue
+				}
+				if g.typeDecl(s) {
+					export(g.info.Defs[s.Name])
+				}
+			}
+		}
+	}
+
+	for _, file := range g.files {
+		g.file = file
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv != nil {
+					continue // generated with its type
+				}
+				obj := g.info.Defs[d.Name].(*types.Func)
+				if obj.Extern() != nil || d.Name.Name != "init" && !kept(obj) {
+					continue
+				}
+				if d.Name.Name == "init" {
+					inits = append(inits, g.names[obj])
+				} else {
+					export(obj)
+				}
+				g.funcDecl(d, obj)
+			case *ast.GenDecl:
+				switch d.Tok {
+				case lexer.CONST:
+					for _, spec := range d.Specs {
+						for _, name := range spec.(*ast.ValueSpec).Names {
+							if obj, ok := g.info.Defs[name].(*types.Const); ok && name.Name != "_" && kept(obj) {
+								g.separate()
+								g.line("const %s = %s;", g.named(obj, g.names[obj]), constLit(obj.Val(), obj.Type()).code)
+								export(obj)
+							}
+						}
+					}
+				case lexer.VAR:
+					for _, spec := range d.Specs {
+						s := spec.(*ast.ValueSpec)
+						if v, ok := g.info.Defs[s.Names[0]].(*types.Var); ok && v.Extern() != nil {
+							continue
+						}
+						used := false
+						for _, name := range s.Names {
+							if obj := g.info.Defs[name]; name.Name == "_" || obj != nil && kept(obj) {
+								used = true
+							}
+						}
+						if !used {
+							continue
+						}
+						vars = append(vars, s)
+						varFiles[s] = file
+						for _, name := range s.Names {
+							if obj := g.info.Defs[name]; obj != nil && name.Name != "_" {
+								export(obj)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if len(vars) > 0 {
+		g.separate()
+		for _, s := range g.initOrder(vars) {
+			g.file = varFiles[s]
+			g.packageVar(s)
+		}
+	}
+
+	for _, t := range g.jsonTypes {
+		g.separate()
+		g.open("function %s() {", t.name)
+		g.line("return %s;", t.desc)
+		g.close("}")
+	}
+
+	main, _ := g.pkg.Scope().Lookup("main").(*types.Func)
+	if len(inits) > 0 || (g.pkg.Name() == "main" && main != nil) {
+		g.separate()
+		for _, name := range inits {
+			g.line("%s();", name)
+		}
+		if g.pkg.Name() == "main" && main != nil {
+			g.line("%s();", g.names[main])
+		}
+	}
+
+	if len(exports) > 0 {
+		sort.Strings(exports)
+		list := make([]string, len(exports))
+		for i, name := range exports {
+			list[i] = name
+			if js := Mangle(name); js != name {
+				list[i] = js + " as " + name
+			}
+		}
+		g.separate()
+		g.line("export { %s };", strings.Join(list, ", "))
+	}
+}
+
+// separate starts a new group of lines with a blank line.
+func (g *generator) separate() {
+	if g.out.Len() > 0 && !bytes.HasSuffix(g.out.Bytes(), []byte("\n\n")) {
+		g.out.WriteByte('\n')
+	}
+}
+
+// collect names the package-level declarations, including the bindings
+// that extern declarations and JSX elements refer to, and finds the
+// variables assigned after their declaration and the functions that JSX
+// elements render.
+func (g *generator) collect() {
+	scope := g.pkg.Scope()
+	var externs []types.Object
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if externOf(obj) != nil {
+			externs = append(externs, obj)
+			continue
+		}
+		if isExtern(obj.Type()) {
+			continue // a type without a class
+		}
+		js := Mangle(name)
+		g.names[obj] = js
+		g.globals[js] = true
+	}
+	g.externs(externs)
+	g.jsxNames()
+	g.enumValues()
+	inits := 0
+	for _, file := range g.files {
+		for _, decl := range file.Decls {
+			if d, ok := decl.(*ast.FuncDecl); ok {
+				fn, _ := g.info.Defs[d.Name].(*types.Func)
+				if fn == nil {
+					continue
+				}
+				g.funcs[fn] = d
+				if d.Recv == nil && d.Name.Name == "init" {
+					// a package may have several init functions
+					name := fmt.Sprintf("$init%d", inits)
+					inits++
+					g.names[fn] = name
+					g.globals[name] = true
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok != lexer.SHORT_VAR {
+					for _, lhs := range n.Lhs {
+						g.markAssigned(lhs)
+					}
+				} else {
+					// variables redeclared by := are assigned
+					for _, lhs := range n.Lhs {
+						if ident, ok := lhs.(*ast.Ident); ok && g.info.Defs[ident] == nil {
+							g.markAssigned(ident)
+						}
+					}
+				}
+			case *ast.IncDecStmt:
+				g.markAssigned(n.X)
+			case *ast.JSXElement:
+				if ident, ok := n.Name.(*ast.Ident); ok {
+					if fn, ok := g.info.Uses[ident].(*types.Func); ok {
+						g.components[fn] = true
+					}
+				}
+			case *ast.RangeStmt:
+				if n.Tok == lexer.ASSIGN {
+					g.markAssigned(n.Key)
+					g.markAssigned(n.Value)
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (g *generator) markAssigned(e ast.Expr) {
+	if ident, ok := unparen(e).(*ast.Ident); ok {
+		if obj := g.info.Uses[ident]; obj != nil {
+			g.assigned[obj] = true
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Package-level declarations
+
+// funcDecl generates a function declaration.
+func (g *generator) funcDecl(d *ast.FuncDecl, obj *types.Func) {
+	g.file = g.fileOf(d)
+	g.locals = g.newScope()
+	g.owned = g.ownedSlices(d.Body)
+	defer func() { g.locals, g.owned = nil, nil }()
+
+	sig := obj.Signature()
+	g.separate()
+	g.pending = g.mark(d.Pos(), "")
+	prologue := ""
+	if g.components[obj] {
+		prologue = g.propsPrologue(sig)
+	}
+	g.functionWith("function "+g.named(obj, g.names[obj]), sig, d.Type, d.Body, "", prologue)
+}
+
+// fileOf returns the file declaring the function d.
+func (g *generator) fileOf(d *ast.FuncDecl) *ast.File {
+	for _, file := range g.files {
+		for _, decl := range file.Decls {
+			if decl == d {
+				return file
+			}
+		}
+	}
+	return g.file
+}
+
+// packageVar generates the declaration of package-level variables. They
+// are declared with let, since other packages may not assign them but
+// their own functions may.
+func (g *generator) packageVar(s *ast.ValueSpec) {
+	g.locals = g.newScope()
+	defer func() { g.locals = nil }()
+
+	names := make([]string, len(s.Names))
+	vars := make([]*types.Var, len(s.Names))
+	for i, name := range s.Names {
+		v, _ := g.info.Defs[name].(*types.Var)
+		vars[i] = v
+		if v == nil || name.Name == "_" {
+			names[i] = ""
+			continue
+		}
+		names[i] = g.named(v, g.names[v])
+	}
+	g.varDecl("let", names, vars, s.Values)
+}
+
+// initOrder orders the package-level variable declarations so that each
+// one follows the declarations of the variables its initializer depends
+// on, directly or through the functions it calls. Otherwise they keep the
+// order of the source.
+func (g *generator) initOrder(specs []*ast.ValueSpec) []*ast.ValueSpec {
+	specOf := make(map[types.Object]*ast.ValueSpec)
+	for _, s := range specs {
+		for _, name := range s.Names {
+			if obj := g.info.Defs[name]; obj != nil {
+				specOf[obj] = s
+			}
+		}
+	}
+
+	var order []*ast.ValueSpec
+	done := make(map[*ast.ValueSpec]bool)
+	var visit func(s *ast.ValueSpec)
+	visit = func(s *ast.ValueSpec) {
+		if done[s] {
+			return
+		}
+		done[s] = true
+		seen := make(map[types.Object]bool)
+		var deps func(n ast.Node)
+		deps = func(n ast.Node) {
+			ast.Inspect(n, func(n ast.Node) bool {
+				var obj types.Object
+				switch n := n.(type) {
+				case *ast.Ident:
+					obj = g.info.Uses[n]
+				case *ast.SelectorExpr:
+					if sel := g.info.Selections[n]; sel != nil {
+						obj = sel.Obj()
+					}
+				}
+				if obj == nil || seen[obj] {
+					return true
+				}
+				seen[obj] = true
+				if dep := specOf[obj]; dep != nil {
+					visit(dep)
+				}
+				if fn, ok := obj.(*types.Func); ok && g.funcs[fn] != nil {
+					deps(g.funcs[fn].Body)
+				}
+				return true
+			})
+		}
+		for _, v := range s.Values {
+			deps(v)
+		}
+		order = append(order, s)
+	}
+	for _, s := range specs {
+		visit(s)
+	}
+	return order
+}
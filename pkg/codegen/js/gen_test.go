@@ -0,0 +1,911 @@
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/parser"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// generate parses, checks and generates a single file.
+func generate(t *testing.T, src string) (string, error) {
+	t.Helper()
+	out, _, err := generateWith(t, &Config{}, src)
+	return out, err
+}
+
+// generateWith parses, checks and generates a single file with conf.
+func generateWith(t *testing.T, conf *Config, src string) (string, *SourceMap, error) {
+	t.Helper()
+	file, err := parser.ParseFile("test.gus", strings.NewReader(src))
+	require.NoError(t, err)
+	files := []*ast.File{file}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := types.Check(files, info)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	m, err := conf.Generate(&buf, pkg, files, info)
+	return buf.String(), m, err
+}
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			"header and exports",
+			"package lib\n\nfunc Add(x, y int) int { return x + y }\nfunc helper() {}",
+			[]string{
+				"// Code generated by gus from package lib. DO NOT EDIT.\n",
+				"function Add(x, y) {\n  return x + y | 0;\n}\n",
+				"export { Add };\n",
+			},
+		},
+		{
+			"append in place",
+			"package lib\n\ntype B struct{ items []int }\n\nfunc F(xs []int, b B) ([]int, B) {\n\txs = append(xs, 1, 2)\n\tb.items = append(b.items, 3)\n\tys := append(xs, 4)\n\treturn ys, b\n}\n\nfunc G(n int) []int {\n\tvar out []int\n\tfor i := range n {\n\t\tout = append(out, i)\n\t}\n\tif len(out) > 2 && out[0] == 0 {\n\t\treturn out\n\t}\n\tkept := []int{1}\n\tshared := kept\n\tkept = append(kept, 2)\n\tlate := []int{}\n\tf := () => len(late)\n\tlate = append(late, f())\n\treturn append(shared, late[0])\n}",
+			[]string{"xs = [...xs, 1, 2];", "b.items = [...b.items, 3];", "const ys = [...xs, 4];", "out.push(i);", "kept = [...kept, 2];", "late = [...late, f()];"},
+		},
+		{
+			"int64 arithmetic",
+			"package lib\n\nfunc Mul(x, y int64) int64 { return x * y }",
+			[]string{"return BigInt.asIntN(64, x * y);"},
+		},
+		{
+			"int multiplication and division",
+			"package lib\n\nfunc F(x, y int) int { return x * y / y }",
+			[]string{"return $div(Math.imul(x, y), y);", "function $div(x, y) {"},
+		},
+		{
+			"reserved names",
+			"package lib\n\nvar class = 1\nvar Object = 2",
+			[]string{"let class$ = 1;", "let Object$ = 2;", "export { Object$ as Object };"},
+		},
+		{
+			"struct class",
+			"package lib\n\ntype Point struct{ X, Y int }",
+			[]string{
+				"class Point {\n  constructor(X = 0, Y = 0) {\n    this.X = X;\n    this.Y = Y;\n  }\n\n  $clone() {\n    return new Point(this.X, this.Y);\n  }\n}\n",
+			},
+		},
+		{
+			"record class",
+			"package lib\n\ntype P record{ Name string }",
+			[]string{"constructor(Name = \"\") {\n    this.Name = Name;\n    Object.freeze(this);\n  }"},
+		},
+		{
+			"enum variants",
+			"package lib\n\ntype Shape enum {\n\tDot\n\tCircle(float)\n}",
+			[]string{
+				"const Shape = Object.freeze({\n  Dot: Object.freeze({ $tag: \"Dot\" }),\n  Circle: ($0) => Object.freeze({ $tag: \"Circle\", $0 }),\n});",
+			},
+		},
+		{
+			"backed enum",
+			"package lib\n\ntype Color enum(string) {\n\tRed\n\tGreen = \"g\"\n}\n\nfunc F(c Color) string { return string(c) }",
+			[]string{"const Color = Object.freeze({\n  Red: \"Red\",\n  Green: \"g\",\n});", "return c;"},
+		},
+		{
+			"enum methods",
+			"package lib\n\ntype Pair enum(int, int) {\n\tA = (1, 2)\n}\n\nfunc (p Pair) First() int { return 1 }",
+			[]string{
+				"  static First(p) {\n    return 1;\n  }",
+				"}\nPair.A = Object.freeze([1, 2]);\nObject.freeze(Pair);",
+			},
+		},
+		{
+			"value receiver",
+			"package lib\n\ntype C struct{ n int }\n\nfunc (c C) Inc() int {\n\tc.n++\n\treturn c.n\n}\n\nfunc (c C) Zero() int { return 0 }",
+			[]string{
+				"Inc() {\n    let c = this.$clone();\n",
+				"Zero() {\n    return 0;\n  }",
+			},
+		},
+		{
+			"boxed type",
+			"package lib\n\ntype Celsius float\n\nfunc (c Celsius) Double() Celsius { return c * 2 }",
+			[]string{"static Double(c) {", "Double(...args) {\n    return Celsius.Double(this.$value, ...args);\n  }"},
+		},
+		{
+			"map with primitive keys",
+			"package lib\n\nfunc F() int {\n\tm := map[string]int{\"a\" => 1}\n\treturn m[\"b\"]\n}",
+			[]string{`const m = new Map([["a", 1]]);`, `return m.get("b") ?? 0;`},
+		},
+		{
+			"copy on assignment",
+			"package lib\n\ntype P struct{ X int }\n\nfunc F(p P) P {\n\tq := p\n\tq.X = 1\n\treturn q\n}",
+			[]string{"const q = p.$clone();"},
+		},
+		{
+			"switch break",
+			"package lib\n\nfunc F(x int) {\n\tswitch x {\n\t1 => {\n\t\tbreak\n\t}\n\tdefault => print(x)\n\t}\n}",
+			[]string{"$sw: {\n    if (x === 1) {\n      break $sw;\n    } else {\n      console.log(x);\n    }\n  }"},
+		},
+		{
+			"match switch",
+			"package lib\n\ntype Op enum {\n\tNeg(int)\n\tAdd(int, int)\n\tNop\n}\n\nfunc Eval(o Op) int {\n\treturn match o {\n\t\tOp.Neg(x) => -x\n\t\tOp.Add(a, b) => a + b\n\t\tOp.Nop => 0\n\t}\n}",
+			[]string{"switch (o.$tag) {\n    case \"Neg\": {\n      const x = o.$0;\n      return -x | 0;\n    }\n    case \"Add\": {\n      const a = o.$0;\n      const b = o.$1;\n      return a + b | 0;\n    }\n    case \"Nop\": {\n      return 0;\n    }\n  }"},
+		},
+		{
+			"match switch with remaining arms",
+			"package lib\n\nfunc F(s string) int {\n\tn := 0\n\tmatch s {\n\t\t\"a\" | \"b\" => { n = 1 }\n\t\t\"c\" if n == 0 => { n = 2 }\n\t\t\"d\" => { n = 3 }\n\t\t_ => { n = 4 }\n\t}\n\treturn n\n}",
+			[]string{"$match: {\n    switch (s) {\n      case \"a\":\n      case \"b\": {\n        n = 1;\n        break $match;\n      }\n      case \"c\": {\n        if (n === 0) {\n          n = 2;\n          break $match;\n        }\n        break;\n      }\n      case \"d\": {\n        n = 3;\n        break $match;\n      }\n    }\n    n = 4;\n  }"},
+		},
+		{
+			"match conditional",
+			"package lib\n\ntype Shape enum {\n\tCircle(float)\n\tSquare(float)\n}\n\nfunc F(s Shape, b bool) float {\n\tk := match b { true => 1.5, false => 2 }\n\treturn k * match s {\n\t\tShape.Circle(r) => r * r\n\t\tShape.Square(d) => d\n\t}\n}",
+			[]string{"const k = b === true ? 1.5 : 2;", "return k * (s.$tag === \"Circle\" ? s.$0 * s.$0 : s.$0);"},
+		},
+		{
+			"match decision tree",
+			"package lib\n\ntype Opt enum {\n\tSome(int)\n\tNone\n}\n\nfunc Pick(o Opt) string {\n\treturn match o {\n\t\tOpt.Some(1) => \"one\"\n\t\tOpt.Some(2) => \"two\"\n\t\tOpt.Some(_) => \"many\"\n\t\tOpt.None => \"none\"\n\t}\n}\n\nfunc Both(a, b Opt, n int) int {\n\treturn match (a, b) {\n\t\t(Opt.Some(x), Opt.Some(y)) => x + y + n\n\t\t(Opt.Some(x), _) => x\n\t\t_ => n\n\t}\n}\n\nfunc Size(o Opt, f func() Opt) int {\n\tk := 2 * match o {\n\t\tOpt.Some(1) => 1\n\t\tOpt.Some(_) => 5\n\t\tOpt.None => 0\n\t}\n\treturn match (o, f()) {\n\t\t(Opt.None, Opt.None) => k\n\t\t(_, x) => match x { Opt.Some(n) => n, Opt.None => 0 }\n\t}\n}",
+			[]string{
+				"switch (o.$tag) {\n    case \"Some\": {\n      const $p = o.$0;\n      if ($p === 1) {\n        return \"one\";\n      }\n      if ($p === 2) {\n        return \"two\";\n      }\n      return \"many\";\n    }\n    case \"None\": {\n      return \"none\";\n    }\n  }",
+				"return a.$tag === \"Some\" && b.$tag === \"Some\" ? (a.$0 + b.$0 | 0) + n | 0 : a.$tag === \"Some\" ? a.$0 : n;",
+				"  const k = Math.imul(2, o.$tag === \"Some\" ? o.$0 === 1 ? 1 : 5 : 0);\n  const $s = o;\n  const $s1 = f();\n  return $s.$tag === \"None\" && $s1.$tag === \"None\" ? k : $s1.$tag === \"Some\" ? $s1.$0 : 0;\n",
+			},
+		},
+		{
+			"match assigned by its arms",
+			"package lib\n\nfunc F(xs []int) int {\n\tfor _, x := range xs {\n\t\ty := match x {\n\t\t\t0 => { return 1 }\n\t\t\t_ => x\n\t\t}\n\t\tprint(y)\n\t}\n\treturn 0\n}",
+			[]string{"let y;\n    if (x === 0) {\n      return 1;\n    } else {\n      y = x;\n    }"},
+		},
+		{
+			"match subject",
+			"package lib\n\ntype P struct{ n int }\n\nfunc g() int { return 1 }\n\nfunc F(p P) {\n\tprint(match g() { 0 => \"a\", _ => \"b\" }, 2 * match p.n { 0 => 1, _ => p.n })\n}",
+			[]string{"  const $s = g();\n  const $t = $s === 0 ? \"a\" : \"b\";\n  const $s1 = p.n;\n  console.log($t, Math.imul(2, $s1 === 0 ? 1 : p.n));\n"},
+		},
+		{
+			"match statements",
+			"package lib\n\nfunc F(xs []int, x int) bool {\n\tif len(xs) > 0 && match xs[0] { 0 => true, _ => false } {\n\t\treturn true\n\t}\n\treturn 2 * match x { 0 => { return false }, _ => x } > 2\n}",
+			[]string{
+				"if (xs.length > 0 && (() => {\n    const $s = $at(xs, 0);\n    if ($s === 0) {\n      return true;\n    } else {\n      return false;\n    }\n  })()) {",
+				"  let $m;\n  if (x === 0) {\n    return false;\n  } else {\n    $m = x;\n  }\n  return Math.imul(2, $m) > 2;\n",
+			},
+		},
+		{
+			"json",
+			"package lib\n\nvar Raw = #json({\"a\": [1, -2.5, null], \"b c\": true})",
+			[]string{"let Raw = { a: [1, -2.5, null], \"b c\": true };"},
+		},
+		{
+			"json typed",
+			"package lib\n\ntype Limits record{ CPU float }\n\ntype Config struct {\n\tName string\n\tPorts []int64\n\tTags map[string]?string\n\tLimits Limits\n\tRetries int\n}\n\nvar C Config = #json({\"Ports\": [80], \"Tags\": {\"env\": null}, \"Limits\": {\"CPU\": 1}, \"Name\": \"api\"})",
+			[]string{"let C = new Config(\"api\", [80n], new Map([[\"env\", null]]), new Limits(1));"},
+		},
+		{
+			"json builtins",
+			"package lib\n\ntype Tree record {\n\tName string\n\tKids []Tree\n}\n\nfunc Load(s string) Tree { return json_decode(Tree, s) }\n\nfunc Save(ids map[string]int64) string { return json_encode(ids) }",
+			[]string{
+				"return $decodeJSON(s, $json$Tree);",
+				"return $encodeJSON(ids, { map: \"int64\" });",
+				"function $json$Tree() {\n  return { fields: [[\"Name\", \"string\", \"Name\", \"\"], [\"Kids\", { elem: $json$Tree }, \"Kids\", () => []]], class: Tree, frozen: true };\n}",
+			},
+		},
+		{
+			"json parse",
+			"package lib\n\nvar Words []string = #json([" + strings.Repeat(`"word", `, 2000) + `"end"])`,
+			[]string{`let Words = JSON.parse("[\"word\", \"word\", `},
+		},
+		{
+			"list comprehension",
+			"package lib\n\nfunc F(xs []int) []int { return [x * 2 for x in xs if x > 1] }",
+			[]string{
+				"  const $r = [];\n  for (let $i = 0; $i < xs.length; $i++) {\n    const x = xs[$i];\n    if (!(x > 1)) continue;\n    $r.push(Math.imul(x, 2));\n  }\n  return $r;\n",
+			},
+		},
+		{
+			"extern declarations",
+			"package lib\n\n#[extern(\"react\")]\nfunc useState[T any](initial T) tuple(T, func(T))\n\n#[extern(\"react\")]\n#[js(\"default.version\")]\nvar version string\n\n#[extern(\"./events.js\")]\n#[js(\"Emitter\")]\n#[new]\nfunc NewEmitter(name string) Bus\n\n#[extern]\ntype Bus struct {\n\tdefault_ ?string\n\tname string\n}\n\n#[rest]\nfunc (b Bus) emit(args []any)\n\n#[extern]\nvar document any\n\n#[extern(\"react\")]\nfunc useEffect(f func())\n\n#[extern(\"./unused.js\")]\nfunc unused()\n\nfunc Emitter() {}\n\nfunc Run() string {\n\tn, set := useState(0)\n\tset(n + 1)\n\te := NewEmitter(\"e\")\n\te.emit([]any{1, 2})\n\treturn Bus{name => version}.name\n}",
+			[]string{
+				"import { Emitter as Emitter$1 } from \"./events.js\";\nimport { default as default$, useState } from \"react\";\n",
+				"const [n, set] = useState(0);",
+				"const e = new Emitter$1(\"e\");\n  e.emit(...[1, 2]);\n  return { name: default$.version }.name;",
+				"export { Emitter, Run };",
+			},
+		},
+		{
+			"init order",
+			"package lib\n\nvar a = b + 1\nvar b = f()\n\nfunc f() int { return 1 }",
+			[]string{"let b = f();\nlet a = b + 1 | 0;\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := generate(t, tc.input)
+			require.NoError(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, out, want)
+			}
+		})
+	}
+}
+
+func TestEnums(t *testing.T) {
+	const src = `package lib
+
+type Color enum(int) {
+	Red = 1
+	Green
+}
+
+type Shape enum {
+	Dot
+	Circle(float)
+}
+
+type Celsius enum(float) {
+	Freezing = 0
+}
+
+func (c Celsius) Cold() bool { return c == Celsius.Freezing }
+
+func F(c Color, s Shape) int {
+	return match s {
+		Shape.Circle(_) => int(c)
+		_ => int(Color.Green)
+	}
+}
+`
+	testCases := []struct {
+		name  string
+		enums map[string]EnumConfig
+		want  []string
+	}{
+		{
+			"inline",
+			map[string]EnumConfig{"Color": {Lowering: EnumInline}, "Celsius": {Lowering: EnumInline}},
+			[]string{
+				"return s.$tag === \"Circle\" ? c : 2;",
+				"static Cold(c) {\n    return c === 0;",
+				"}\nObject.freeze(Celsius);",
+				"export { Celsius, F, Shape };",
+			},
+		},
+		{
+			"tagged",
+			map[string]EnumConfig{"Color": {Lowering: EnumTagged}},
+			[]string{
+				"Red: Object.freeze({ $tag: \"Red\", $value: 1 }),",
+				"return s.$tag === \"Circle\" ? c.$value : Color.Green.$value;",
+			},
+		},
+		{
+			"helpers",
+			map[string]EnumConfig{"Color": {String: true, Values: true, Parse: true}, "*": {Lowering: EnumInline, String: true, Values: true}},
+			[]string{
+				"  String($v) {\n    switch ($v) {\n      case Color.Red:\n        return \"Red\";\n      case Color.Green:\n        return \"Green\";\n    }\n  },",
+				"  values() {\n    return [Color.Red, Color.Green];\n  },",
+				"  parse($name) {\n    switch ($name) {\n      case \"Red\":\n        return Color.Red;\n      case \"Green\":\n        return Color.Green;\n    }\n    return null;\n  },",
+				"  Circle: ($0) => Object.freeze({ $tag: \"Circle\", $0 }),\n  String($v) {\n    return $v.$tag;\n  },\n});",
+				"  static String($v) {\n    switch ($v) {\n      case 0:\n",
+				"  static values() {\n    return [0];\n  }",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, _, err := generateWith(t, &Config{Enums: tc.enums}, src)
+			require.NoError(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, out, want)
+			}
+		})
+	}
+
+	errors := []struct {
+		enums map[string]EnumConfig
+		want  string
+	}{
+		{map[string]EnumConfig{"Shape": {Lowering: EnumObject}}, "enum Shape: variants with payloads require the tagged lowering"},
+		{map[string]EnumConfig{"Shape": {Parse: true}}, "enum Shape: the parse helper requires variants without payloads"},
+		{map[string]EnumConfig{"Celsius": {String: true}, "Color": {Lowering: EnumInline}}, ""},
+		{map[string]EnumConfig{"F": {}}, "enum configuration: F is not a type of package lib"},
+	}
+	for _, tc := range errors {
+		_, _, err := generateWith(t, &Config{Enums: tc.enums}, src)
+		if tc.want == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.EqualError(t, err, tc.want)
+	}
+}
+
+func TestParseEnumConfig(t *testing.T) {
+	name, conf, err := ParseEnumConfig("Color=inline,string,parse")
+	require.NoError(t, err)
+	assert.Equal(t, "Color", name)
+	assert.Equal(t, EnumConfig{Lowering: EnumInline, String: true, Parse: true}, conf)
+
+	name, conf, err = ParseEnumConfig("*=values")
+	require.NoError(t, err)
+	assert.Equal(t, "*", name)
+	assert.Equal(t, EnumConfig{Values: true}, conf)
+
+	for _, s := range []string{"Color", "=tagged", "Color=", "Color=flat", "Color=object,tagged"} {
+		_, _, err := ParseEnumConfig(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestJSX(t *testing.T) {
+	const src = `package lib
+
+func jsx() string { return "a" }
+
+func Card(p struct {
+	title    string
+	wide     bool
+	children []any
+}) any {
+	return <section class="card" aria-label={p.title}>{p.children}</section>
+}
+
+func Page(items []string) any {
+	return <>
+		<Card title={jsx()} wide>Hello &amp; {1}</Card>
+		<ul>{[<li key={s}>{s}</li> for s in items]}</ul>
+		<br />
+	</>
+}
+`
+	testCases := []struct {
+		name string
+		jsx  JSXConfig
+		want []string
+	}{
+		{
+			"automatic",
+			JSXConfig{},
+			[]string{
+				"import { Fragment, jsx as jsx$1, jsxs } from \"react/jsx-runtime\";",
+				"return jsx$1(\"section\", { class: \"card\", \"aria-label\": p.title, children: p.children });",
+				"const $t = jsxs(Card, { title: jsx(), wide: true, children: [\"Hello & \", 1] });\n  const $r = [];",
+				"$r.push(jsx$1(\"li\", { children: s }, s));",
+				"[$t, jsx$1(\"ul\", { children: $r }), ",
+				"jsx$1(\"br\", {})",
+				"return jsxs(Fragment, { children: [",
+			},
+		},
+		{
+			"import source",
+			JSXConfig{ImportSource: "preact"},
+			[]string{"from \"preact/jsx-runtime\";"},
+		},
+		{
+			"classic",
+			JSXConfig{Runtime: JSXClassic, ImportSource: "preact", Factory: "h"},
+			[]string{
+				"import { Fragment, h } from \"preact\";",
+				"return h(\"section\", { class: \"card\", \"aria-label\": p.title }, p.children);",
+				"const $t = h(Card, { title: jsx(), wide: true, children: [\"Hello & \", 1] });\n  const $r = [];",
+				"$r.push(h(\"li\", { key: s }, s));",
+				"$t, h(\"ul\", null, $r), ",
+				"return h(Fragment, null, ",
+			},
+		},
+		{
+			"classic namespace",
+			JSXConfig{Runtime: JSXClassic, Factory: "React.createElement", Fragment: "React.Fragment"},
+			[]string{
+				"import * as React from \"react\";",
+				"return React.createElement(React.Fragment, null, ",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, _, err := generateWith(t, &Config{JSX: tc.jsx}, src)
+			require.NoError(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, out, want)
+			}
+		})
+	}
+
+	out, err := generate(t, "package lib\n\ntype Props record{ n int }\n\nfunc (p Props) double() int { return p.n * 2 }\n\nfunc C(p Props) any { return p.double() }\n\nvar X any = <C n={1} />")
+	require.NoError(t, err)
+	assert.Contains(t, out, "function C(p) {\n  if (!(p instanceof Props)) p = Object.freeze($convert(p, Props));\n  return p.double();\n}")
+	assert.Contains(t, out, "jsx(C, { n: 1 })")
+}
+
+func TestMinify(t *testing.T) {
+	const src = `package lib
+
+const debug = false
+
+type point struct{ x, y int }
+
+type unused struct{ a int }
+
+func (u unused) get() int { return u.a }
+
+func norm(p point) int { return p.x*p.x + p.y*p.y }
+
+func dead() int { return norm(point{}) }
+
+var origin = point{}
+var table = []int{1, 2}
+var loaded = norm(point{1, 1})
+
+// Norm returns the square of the norm of (x, y).
+func Norm(x, y int) int {
+	if debug && x < 0 {
+		print("negative")
+	}
+	if debug {
+		return 0
+	} else if x == 0 {
+		return y * y
+	}
+	return norm(point{x, y}) + table[0]
+}
+`
+	out, _, err := generateWith(t, &Config{Minify: true}, src)
+	require.NoError(t, err)
+	assert.Equal(t, "// Code generated by gus from package lib. DO NOT EDIT.\n"+
+		"function $panic(value){const err=new Error(\"panic: \"+String(value));err.value=value;throw err;}"+
+		"function $index(a,i){if(i<0||i>=a.length){$panic(\"index out of range [\"+i+\"] with length \"+a.length);}return Number(i);}"+
+		"function $at(a,i){return a[$index(a,i)];}"+
+		"class point{constructor(a=0,b=0){this.x=a;this.y=b;}$clone(){return new point(this.x,this.y);}}"+
+		"function norm(a){return Math.imul(a.x,a.x)+Math.imul(a.y,a.y)|0;}"+
+		"function Norm(a,b){if(a===0){return Math.imul(b,b);}return norm(new point(a,b))+$at(table,0)|0;}"+
+		"let table=[1,2];let loaded=norm(new point(1,1));export{Norm};\n", out)
+
+	plain, err := generate(t, src)
+	require.NoError(t, err)
+	assert.Contains(t, plain, "function dead() {")
+	assert.Contains(t, plain, "if (false) {")
+}
+
+func TestCompact(t *testing.T) {
+	testCases := []struct{ in, want string }{
+		{"let x = a - -b;\nreturn x + +y;", "let x=a- -b;return x+ +y;"},
+		{"const s = \"a  // b\"; // comment\nf( 's\\' ' );", "const s=\"a  // b\";f('s\\' ');"},
+		{"return 1 .toString();", "return 1 .toString();"},
+		{"return \x000\x01 x;", "return \x000\x01x;"},
+		{"new /* c */ $Map()", "new $Map()"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, string(compact([]byte(tc.in))), tc.in)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	src := "package lib\n\nfunc F(xs []int) int {\n\tfor _, x := range xs {\n\t\tprint(x > 0 || match x {\n\t\t\t0 => { return 1 }\n\t\t\t_ => x > 1\n\t\t})\n\t}\n\treturn 0\n}"
+	_, err := generate(t, src)
+	require.Error(t, err)
+	assert.Equal(t, "test.gus:6:11: cannot generate a match expression whose arm leaves the function or loop here", err.Error())
+}
+
+func TestSourceMap(t *testing.T) {
+	src := "package lib\n\nfunc F(class int) int {\n\tx := class * 2\n\treturn x + 1\n}"
+	out, m, err := generateWith(t, &Config{SourceMap: true}, src)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.NotContains(t, out, "\x00")
+	assert.Equal(t, 3, m.Version)
+	assert.Equal(t, []string{"test.gus"}, m.Sources)
+	assert.Equal(t, []string{"class"}, m.Names)
+
+	// the text at each mapped position of the module, and where it comes
+	// from
+	lines := strings.Split(out, "\n")
+	var got []string
+	for _, s := range decodeMappings(t, m.Mappings) {
+		entry := fmt.Sprintf("%q <- %d:%d", lines[s.line][s.col:], s.srcLine+1, s.srcCol+1)
+		if s.name >= 0 {
+			entry += " " + m.Names[s.name]
+		}
+		got = append(got, entry)
+	}
+	assert.Equal(t, []string{
+		`"function F(class$) {" <- 3:1`,
+		`"F(class$) {" <- 3:6`,
+		`"class$) {" <- 3:8 class`,
+		`"const x = Math.imul(class$, 2);" <- 4:2`,
+		`"x = Math.imul(class$, 2);" <- 4:2`,
+		`"Math.imul(class$, 2);" <- 4:7`,
+		`"class$, 2);" <- 4:7 class`,
+		`"2);" <- 4:15`,
+		`"return x + 1 | 0;" <- 5:2`,
+		`"x + 1 | 0;" <- 5:9`,
+		`"1 | 0;" <- 5:13`,
+	}, got)
+
+	plain, _, err := generateWith(t, &Config{}, src)
+	require.NoError(t, err)
+	assert.Equal(t, plain, out)
+}
+
+// segment is a decoded source map segment.
+type segment struct {
+	line, col, srcLine, srcCol, name int
+}
+
+// decodeMappings decodes the mappings of a source map with one source.
+func decodeMappings(t *testing.T, mappings string) []segment {
+	t.Helper()
+	var segs []segment
+	var prev segment
+	for line, group := range strings.Split(mappings, ";") {
+		prev.col = 0
+		if group == "" {
+			continue
+		}
+		for _, field := range strings.Split(group, ",") {
+			var vals []int
+			shift, v := 0, 0
+			for _, c := range field {
+				digit := strings.IndexRune(base64Digits, c)
+				require.GreaterOrEqual(t, digit, 0)
+				v += (digit & 31) << shift
+				shift += 5
+				if digit&32 == 0 {
+					n := v >> 1
+					if v&1 == 1 {
+						n = -n
+					}
+					vals = append(vals, n)
+					shift, v = 0, 0
+				}
+			}
+			require.True(t, len(vals) == 4 || len(vals) == 5, field)
+			s := segment{line, prev.col + vals[0], prev.srcLine + vals[2], prev.srcCol + vals[3], -1}
+			if len(vals) == 5 {
+				s.name = prev.name + vals[4]
+				prev.name = s.name
+			}
+			prev.col, prev.srcLine, prev.srcCol = s.col, s.srcLine, s.srcCol
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+func TestDeclarations(t *testing.T) {
+	src := `package lib
+
+type Point struct {
+	X, Y int
+	tag  string
+}
+
+func (p Point) Add(q Point) Point { return Point{p.X + q.X, p.Y + q.Y, ""} }
+func (p Point) norm() int         { return p.X }
+
+type Person record {
+	Name string
+	Age  int64
+}
+
+type Option[T any] enum {
+	None
+	Some(T)
+}
+
+type Color enum(string) {
+	Red
+	Green = "g"
+}
+
+type Level enum(int, string) {
+	Low = (1, "low")
+	High = (-2, "high")
+}
+
+type Celsius float
+
+func (c Celsius) String() string { return "C" }
+
+type Stringer interface {
+	String() string
+}
+
+type Number interface {
+	int | float | int64
+}
+
+#[extern]
+type Node struct {
+	default_ ?string
+	id int
+}
+
+#[optional("deep")]
+func (n Node) cloneNode(deep bool) Node
+
+#[extern]
+type Mode enum(string) {
+	Fast = "fast"
+}
+
+#[extern("./fetch.js")]
+func Fetch(url string) Node
+
+type key record{ a, b int }
+
+var Table map[key]?string = map[key]?string{}
+
+const Pi = 3.14
+
+func Max[T Number](xs []T) T {
+	m := xs[0]
+	for _, x := range xs {
+		if x > m {
+			m = x
+		}
+	}
+	return m
+}
+
+func Map[T comparable, U any](xs []T, f func(T) U) map[T]U { return map[T]U{} }
+
+func DivMod(a, b int) (int, int) { return a / b, a % b }
+
+func Show(s Stringer, t tuple(int, bool), f func() ?int) {}
+
+func hidden() {}
+`
+	file, err := parser.ParseFile("test.gus", strings.NewReader(src))
+	require.NoError(t, err)
+	pkg, err := types.Check([]*ast.File{file}, &types.Info{})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, Declarations(&buf, pkg))
+	assert.Equal(t, `// Code generated by gus from package lib. DO NOT EDIT.
+
+interface $Map<K, V> extends Iterable<[K, V]> {
+  readonly size: number;
+  has(k: K): boolean;
+  get(k: K): V | undefined;
+  set(k: K, v: V): this;
+  delete(k: K): boolean;
+  keys(): IterableIterator<K>;
+}
+
+type Celsius = number;
+declare const Celsius: {
+  String(c: Celsius): string;
+};
+
+type Color = "Red" | "g";
+declare const Color: {
+  readonly Red: Color;
+  readonly Green: Color;
+};
+
+type Level = readonly [1, "low"] | readonly [-2, "high"];
+declare const Level: {
+  readonly Low: Level;
+  readonly High: Level;
+};
+
+type Mode = string;
+
+interface Node {
+  default?: string | null;
+  id: number;
+  cloneNode(deep?: boolean): Node;
+}
+
+type Number$ = number | bigint;
+
+type Option<T> = { readonly $tag: "None" } | { readonly $tag: "Some"; readonly $0: T };
+declare const Option: {
+  readonly None: Option<never>;
+  readonly Some: <T>($0: T) => Option<T>;
+};
+
+declare class Person {
+  constructor(Name?: string, Age?: bigint);
+  readonly Name: string;
+  readonly Age: bigint;
+}
+
+declare class Point {
+  constructor(X?: number, Y?: number, tag?: string);
+  X: number;
+  Y: number;
+  private tag;
+  $clone(): Point;
+  Add(q: Point): Point;
+  private norm;
+}
+
+interface Stringer {
+  String(): string;
+}
+
+declare class key {
+  constructor(a?: number, b?: number);
+  private a;
+  private b;
+}
+
+declare function DivMod(a: number, b: number): readonly [number, number];
+
+declare function Map$<T, U>(xs: T[], f: ($0: T) => U): $Map<T, U>;
+
+declare function Max<T extends number | bigint>(xs: T[]): T;
+
+declare const Pi: number;
+
+declare function Show(s: Stringer, t: readonly [number, boolean], f: () => number | null): void;
+
+declare let Table: $Map<key, string | null>;
+
+export { Celsius, Color, DivMod, Level, Map$ as Map, Max, Mode, Node, Number$ as Number, Option, Person, Pi, Point, Show, Stringer, Table };
+`, buf.String())
+}
+
+// TestRun runs generated modules, plain and minified, with Node.js, if it
+// is installed, and compares what they print.
+func TestRun(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	testCases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"integers",
+			"var x = 2147483647\nvar y int64 = 1 << 62\n\nfunc main() {\n\tprint(x + 1, y * 4, -7 / 2, -7 % 2)\n}",
+			"-2147483648 0 -3 -1\n",
+		},
+		{
+			"value semantics",
+			"type P struct{ X int }\n\nfunc (p P) Moved() P {\n\tp.X++\n\treturn p\n}\n\nfunc main() {\n\ta := P{1}\n\tb := a\n\tb.X = 2\n\tc := []P{a}\n\tc[0].X = 3\n\tprint(a.X, b.X, c[0].X, a.Moved().X, a.X)\n}",
+			"1 2 3 2 1\n",
+		},
+		{
+			"method values",
+			"type C struct{ n int }\n\nfunc (c C) Get() int {\n\treturn c.n\n}\n\nfunc main() {\n\tc := C{5}\n\tf := c.Get\n\tc.n = 9\n\tprint(f(), c.Get())\n}",
+			"5 9\n",
+		},
+		{
+			"enums and match",
+			"type Shape enum {\n\tCircle(float)\n\tRect(float, float)\n}\n\nfunc area(s Shape) float {\n\treturn match s {\n\t\tShape.Circle(r) => 3 * r * r\n\t\tShape.Rect(w, h) => w * h\n\t}\n}\n\nfunc main() {\n\tprint(area(Shape.Circle(1)), area(Shape.Rect(2, 3)), Shape.Circle(1) == Shape.Circle(1))\n}",
+			"3 6 true\n",
+		},
+		{
+			"match lowerings",
+			"type Op enum {\n\tAdd(int, int)\n\tNeg(int)\n\tLit(int)\n\tNop\n}\n\nfunc eval(o Op) int {\n\treturn match o {\n\t\tOp.Add(a, b) => a + b\n\t\tOp.Neg(x) => match x {\n\t\t\t0 => 0\n\t\t\t1 => -1\n\t\t\t2 => -2\n\t\t\t_ => -x\n\t\t}\n\t\tOp.Lit(x) if x > 9 => 9\n\t\tOp.Lit(x) => x\n\t\tOp.Nop => 0\n\t}\n}\n\nfunc describe(o Op) string {\n\tmatch o {\n\t\tOp.Lit(0) => { return \"zero\" }\n\t\tOp.Add(a, b) if a == b => { return \"double\" }\n\t\t_ => {}\n\t}\n\treturn \"other\"\n}\n\nfunc count(xs []string) int {\n\tn := 0\n\tfor _, x := range xs {\n\t\tk := match x {\n\t\t\t\"stop\" => { return -n }\n\t\t\t\"a\" | \"b\" => 1\n\t\t\t_ => 100\n\t\t}\n\t\tn += k\n\t}\n\treturn n\n}\n\nfunc main() {\n\tprint(eval(Op.Add(1, 2)), eval(Op.Neg(1)), eval(Op.Neg(7)), eval(Op.Lit(4)), eval(Op.Lit(12)), eval(Op.Nop))\n\tprint(describe(Op.Lit(0)), describe(Op.Add(2, 2)), describe(Op.Add(1, 2)), describe(Op.Nop))\n\tprint(count([]string{\"a\", \"c\"}), count([]string{\"b\", \"stop\", \"a\"}), match eval(Op.Lit(1)) { 1 => \"one\", _ => \"many\" })\n}",
+			"3 -1 -7 4 9 0\nzero double other other\n101 -1 one\n",
+		},
+		{
+			"match decision trees",
+			"type Opt enum {\n\tSome(int)\n\tNone\n}\n\nfunc pick(o Opt) string {\n\treturn match o {\n\t\tOpt.Some(1) => \"one\"\n\t\tOpt.Some(2) => \"two\"\n\t\tOpt.Some(_) => \"many\"\n\t\tOpt.None => \"none\"\n\t}\n}\n\nfunc both(a, b Opt) int {\n\tmatch (a, b) {\n\t\t(Opt.Some(x), Opt.Some(y)) => {\n\t\t\treturn x + y\n\t\t}\n\t\t(Opt.Some(x), _) => {\n\t\t\treturn x\n\t\t}\n\t\t_ => {\n\t\t\treturn 0\n\t\t}\n\t}\n}\n\nfunc label(a Opt, n int) string {\n\treturn match (a, n + 1) {\n\t\t(Opt.Some(_), 2) => \"two\"\n\t\t(Opt.None, _) => \"none\"\n\t\t_ => \"other\"\n\t}\n}\n\nvar n = 0\n\nfunc bump() int {\n\tn++\n\treturn n\n}\n\nfunc main() {\n\tprint(match (n, bump()) { (0, 1) => \"kept\", _ => \"lost\" })\n\tprint(pick(Opt.Some(1)), pick(Opt.Some(2)), pick(Opt.Some(5)), pick(Opt.None))\n\tprint(both(Opt.Some(1), Opt.Some(2)), both(Opt.Some(4), Opt.None), both(Opt.None, Opt.Some(1)))\n\tprint(label(Opt.Some(3), 1), label(Opt.None, 0), label(Opt.Some(1), 5))\n\ts := match pick(Opt.None) {\n\t\t\"none\" => 0\n\t\t_ => 1\n\t}\n\tprint(s)\n}",
+			"kept\none two many none\n3 4 0\ntwo none other\n0\n",
+		},
+		{
+			"comprehensions",
+			"var n = 0\n\nfunc next() int {\n\tn++\n\treturn n\n}\n\nfunc main() {\n\txs := []int{1, 2, 3}\n\ta := next() + len([x for x in xs if x > next()])\n\tb := len(xs) > 2 && len([x for x in xs if x > 1]) == 2\n\tm := {x => x * x for x in xs if x != 2}\n\tprint(a, b, m[3], len(m), [x*10 + y for x in xs for y in xs if x < y])\n}",
+			"1 true 9 2 [ 12, 13, 23 ]\n",
+		},
+		{
+			"match values",
+			"type Box struct{ n int }\n\nvar calls = 0\n\nfunc g(n int) int {\n\tcalls++\n\treturn n\n}\n\nfunc h(n, x int) int {\n\treturn 2 * match g(n) {\n\t\t0 => { return -1 }\n\t\t_ => x\n\t}\n}\n\nfunc main() {\n\txs := []int{4}\n\tb := Box{1}\n\tk := match b.n {\n\t\t1 if calls > 5 => 0\n\t\t1 => 10\n\t\t_ => b.n\n\t}\n\tprint(h(0, 3), h(1, 3), 1 + match xs[0] { 4 => 2, _ => 0 }, k, len(xs) > 0 && match g(1) { 1 => true, _ => false }, calls)\n}",
+			"-1 6 3 10 true 3\n",
+		},
+		{
+			"generics",
+			"type Integer interface{ ~int }\n\ntype Real interface{ ~int | ~float }\n\ntype Age int\n\ntype Box[T any] struct{ v T }\n\nfunc Sum[T Integer](xs []T) T {\n\tvar s T\n\tfor _, x := range xs {\n\t\ts += x\n\t}\n\treturn s\n}\n\nfunc Add[T Integer](a, b T) T { return a + b }\n\nfunc Half[T Integer](a T) T { return a / 2 }\n\nfunc Twice[T ~int64](a T) T { return a * 2 }\n\nfunc Max[T Real](a, b T) T {\n\tvar zero T\n\tif a > b && a != zero {\n\t\treturn a\n\t}\n\treturn b\n}\n\nfunc main() {\n\tvar b Box[string]\n\tm := map[int]Box[int]{}\n\tprint(Sum([]Age{3, 4}), Add(2147483647, 1), Half(-7), Twice(int64(1) << 62), Max(0.5, 1.5), Max(0, -2))\n\tprint(Box[int]{}.v, b.v == \"\", m[1].v)\n}",
+			"7 -2147483648 -3 -9223372036854775808 1.5 -2\n0 true 0\n",
+		},
+		{
+			"json",
+			"type Point struct{ X, Y int }\n\nfunc (p Point) Sum() int { return p.X + p.Y }\n\nfunc main() {\n\tvar ps []Point = #json([{\"X\": 1, \"Y\": 2}, {\"Y\": 5}])\n\tvar big []int = #json([" + strings.Repeat("1, ", 4000) + "2])\n\tprint(ps[0].Sum(), ps[1].Sum(), #json({\"a\": [1, \"\\u00e9\"]}), len(big))\n}",
+			"3 5 { a: [ 1, 'é' ] } 4001\n",
+		},
+		{
+			"append copies shared slices",
+			"type B struct{ items []int }\n\ntype R record{ xs []int }\n\nfunc add(xs []int) []int {\n\txs = append(xs, 9)\n\treturn xs\n}\n\nfunc build(n int) []int {\n\tout := []int{}\n\tfor i := range n {\n\t\tout = append(out, i)\n\t}\n\treturn out\n}\n\nfunc main() {\n\txs := []int{1, 2}\n\tys := add(xs)\n\ta := B{[]int{1}}\n\tb := a\n\tb.items = append(b.items, 2)\n\tr := R{[]int{1}}\n\tzs := r.xs\n\tzs = append(zs, 2)\n\tprint(len(xs), len(ys), len(a.items), len(b.items), len(r.xs), len(zs), len(build(3)))\n}",
+			"2 3 1 2 1 2 3\n",
+		},
+		{
+			"json decode and encode",
+			"type Tag record {\n\tName string\n\tScore ?float\n}\n\nfunc (t Tag) Show() string { return \"#\" + t.Name }\n\ntype Node struct {\n\tKids []Node\n\tSize int64\n\tTags map[string]?Tag\n}\n\nfunc main() {\n\tn := json_decode(Node, `{\"Kids\": [{\"Size\": 2}], \"Size\": 1, \"Tags\": {\"a\": {\"Name\": \"x\"}, \"b\": null}}`)\n\tt := n.Tags[\"a\"]\n\tif t != nil {\n\t\tprint(len(n.Kids), n.Kids[0].Size, t.Show(), t.Score == nil)\n\t}\n\tn.Tags[\"c\"] = Tag{Name => \"y\", Score => 0.5}\n\tprint(json_encode(n))\n\tprint(json_decode(Node, json_encode(n)).Tags[\"c\"] == n.Tags[\"c\"], json_encode((1, [2]bool{true, false})))\n}",
+			"1 2 #x true\n{\"Kids\":[{\"Kids\":[],\"Size\":2,\"Tags\":{}}],\"Size\":1,\"Tags\":{\"a\":{\"Name\":\"x\",\"Score\":null},\"b\":null,\"c\":{\"Name\":\"y\",\"Score\":0.5}}}\ntrue [1,[true,false]]\n",
+		},
+		{
+			"json symbols",
+			"type Job struct {\n\tState :queued | :done\n\tTags []symbol\n}\n\nfunc main() {\n\tj := json_decode(Job, `{\"State\": \"done\", \"Tags\": [\"a\"]}`)\n\tprint(j.State == :done, j.Tags[0] == :a, json_encode(Job{State => :queued, Tags => []symbol{:b}}), json_encode(:ok))\n}",
+			"true true {\"State\":\"queued\",\"Tags\":[\"b\"]} \"ok\"\n",
+		},
+		{
+			"enum lowerings",
+			"type Shower interface{ Show() string }\n\ntype Color enum(string) {\n\tRed\n\tGreen = \"g\"\n}\n\nfunc (c Color) Show() string { return \"color \" + string(c) }\n\ntype Pair enum(int, int) {\n\tA = (1, 2)\n\tB = (3, 4)\n}\n\ntype Dir enum {\n\tNorth\n\tSouth\n}\n\nfunc main() {\n\tvar s Shower = Color.Green\n\tp := Pair.B\n\td := Dir.South\n\tm := map[Pair]Dir{Pair.A => Dir.North}\n\tprint(s.Show(), Color.Red.Show(), p == Pair.B, p == Pair.A, d, m[Pair.A] == Dir.North)\n}",
+			"color g color Red true false South true\n",
+		},
+		{
+			"interfaces",
+			"type Shower interface{ Show() string }\ntype Celsius float\ntype Name struct{ s string }\n\nfunc (c Celsius) Show() string { return \"C\" }\nfunc (n Name) Show() string { return n.s }\n\nfunc main() {\n\tfor _, s := range []Shower{Celsius(1.5), Name{\"n\"}} {\n\t\tprint(s.Show())\n\t}\n}",
+			"C\nn\n",
+		},
+		{
+			"maps",
+			"type K record{ A, B int }\n\nfunc main() {\n\tm := map[K]int{}\n\tm[K{1, 2}] += 3\n\tm[K{1, 2}]++\n\twords := map[string]int{}\n\tfor _, w := range []string{\"a\", \"b\", \"a\"} {\n\t\twords[w]++\n\t}\n\tprint(m[K{1, 2}], len(m), words[\"a\"], words[\"z\"])\n}",
+			"4 1 2 0\n",
+		},
+		{
+			"closures and tuples",
+			"func counter() func() int {\n\tn := 0\n\treturn () => {\n\t\tn++\n\t\treturn n\n\t}\n}\n\nfunc divmod(a, b int) (int, int) { return a / b, a % b }\n\nfunc main() {\n\tnext := counter()\n\tnext()\n\tq, r := divmod(7, 2)\n\tt := divmod(9, 4)\n\tprint(next(), q, r, t.0, t.1)\n}",
+			"2 3 1 2 1\n",
+		},
+		{
+			"records with",
+			"type Person record {\n\tName string\n\tAge int\n}\n\nfunc main() {\n\ta := Person{Name => \"a\", Age => 30}\n\tb := a with {Age => 31}\n\tprint(a.Age, b.Age, b.Name, a == Person{Name => \"a\", Age => 30})\n}",
+			"30 31 a true\n",
+		},
+		{
+			"externs",
+			"#[extern(\"node:path\")]\n#[optional(\"suffix\")]\nfunc basename(path string, suffix string) string\n\n#[extern]\n#[js(\"Math.max\")]\n#[rest]\nfunc maxOf(xs []float) float\n\n#[extern]\n#[js(\"Array.prototype.join\")]\n#[this]\nfunc join(this []string, sep string) string\n\n#[extern]\ntype Parsed struct {\n\tname string\n\tdefault_ ?int\n\ttags []string\n}\n\n#[js(\"hasOwnProperty\")]\nfunc (p Parsed) has(name string) bool\n\n#[extern]\n#[js(\"JSON.parse\")]\nfunc parse(s string) Parsed\n\n#[extern]\n#[js(\"JSON.stringify\")]\nfunc stringify(v any) string\n\n#[extern]\ntype Level enum(int) {\n\tDebug\n\tInfo = 5\n\tWarn\n}\n\nfunc main() {\n\tp := parse(`{\"name\": \"gus\", \"tags\": [\"a\", \"b\"]}`)\n\tprint(basename(\"/a/b.txt\"), basename(\"/a/b.txt\", \".txt\"), maxOf([]float{1, 3, 2}), join(p.tags, \"+\"))\n\tprint(p.name, p.default_ == nil, p.has(\"tags\"), stringify(Parsed{name => \"x\"}))\n\tl := Level.Info\n\tname := match l {\n\t\tLevel.Debug => \"debug\"\n\t\tLevel.Info => \"info\"\n\t\t_ => \"warn\"\n\t}\n\tprint(name, int(Level.Warn), l == Level.Info)\n}",
+			"b.txt b 3 a+b\ngus true true {\"name\":\"x\",\"tags\":[]}\ninfo 6 true\n",
+		},
+		{
+			"index out of range",
+			"func main() {\n\txs := []int{1}\n\ti := 1\n\tx := xs[i]\n\tprint(x)\n}",
+			"panic: index out of range [1] with length 1",
+		},
+		{
+			"json symbol not in the set",
+			"type Job struct {\n\tState :queued | :done\n}\n\nfunc main() {\n\tprint(json_decode(Job, `{\"State\": \"lost\"}`))\n}",
+			"panic: json: unexpected symbol :lost at $.State",
+		},
+		{
+			"json missing field",
+			"type Job struct {\n\tState :queued | :done\n}\n\nfunc main() {\n\tprint(json_decode(Job, `{}`))\n}",
+			"panic: json: missing field $.State",
+		},
+	}
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		for _, minify := range []bool{false, true} {
+			name := tc.name
+			if minify {
+				name += " minified"
+			}
+			t.Run(name, func(t *testing.T) {
+				out, _, err := generateWith(t, &Config{Minify: minify}, "package main\n\n"+tc.input)
+				require.NoError(t, err)
+				path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".mjs")
+				require.NoError(t, os.WriteFile(path, []byte(out), 0o644))
+
+				var stdout, stderr bytes.Buffer
+				cmd := exec.Command(node, path)
+				cmd.Stdout, cmd.Stderr = &stdout, &stderr
+				err = cmd.Run()
+				if strings.HasPrefix(tc.want, "panic: ") {
+					require.Error(t, err)
+					assert.Contains(t, stderr.String(), tc.want)
+					return
+				}
+				require.NoErrorf(t, err, "stderr: %s\n%s", stderr.String(), out)
+				assert.Equal(t, tc.want, stdout.String())
+			})
+		}
+	}
+}
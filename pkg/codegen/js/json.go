@@ -0,0 +1,279 @@
+package js
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// jsonParseSize is the size of the source of a #json literal from which it
+// is emitted as a call to JSON.parse, which V8 parses faster than the
+// equivalent object literal.
+const jsonParseSize = 10 << 10
+
+// jsonLit returns the value of a #json literal.
+func (g *generator) jsonLit(e *ast.JSONLit) js {
+	T := g.info.TypeOf(e)
+	if len(e.Raw) >= jsonParseSize && parsedJSON(T) {
+		src := []rune(e.Raw)
+		src = src[len("#json(") : len(src)-1]
+		return js{"JSON.parse(" + jsString(strings.TrimSpace(string(src))) + ")", precCall}
+	}
+	return g.json(e.Value, T)
+}
+
+// json returns the JavaScript value of the JSON value v of type T.
+func (g *generator) json(v *ast.JSONValue, T types.Type) js {
+	switch u := T.Underlying().(type) {
+	case *types.Optional:
+		if v.Kind == ast.JSONNull {
+			return js{"null", precPrimary}
+		}
+		return g.json(v, u.Elem())
+	case *types.Basic:
+		switch v.Kind {
+		case ast.JSONBool:
+			return js{v.Text, precPrimary}
+		case ast.JSONString:
+			return js{jsString(v.Text), precPrimary}
+		}
+		val := constant.MakeFromLiteral(v.Text, token.FLOAT, 0)
+		if isInt64(u) || isBasic(u, types.Int) {
+			val = constant.ToInt(val)
+		}
+		return constLit(val, T)
+	case *types.Slice:
+		return g.jsonArray(v, func(int) types.Type { return u.Elem() })
+	case *types.Array:
+		return g.jsonArray(v, func(int) types.Type { return u.Elem() })
+	case *types.Tuple:
+		return g.jsonArray(v, u.At)
+	case *types.Map:
+		entries := make([]string, len(v.Keys))
+		for i, key := range v.Keys {
+			entries[i] = "[" + jsString(key) + ", " + g.json(v.Elts[i], u.Elem()).code + "]"
+		}
+		if len(entries) == 0 {
+			return js{"new Map()", precCall}
+		}
+		return js{"new Map([" + strings.Join(entries, ", ") + "])", precCall}
+	case *types.Struct, *types.Record:
+		fields := structFields(u)
+		vals := make([]js, len(fields))
+		for i, key := range v.Keys {
+			for j, f := range fields {
+				if f.Name() == key {
+					vals[j] = g.json(v.Elts[i], f.Type())
+				}
+			}
+		}
+		return g.structLit(T, fields, vals)
+	}
+	return jsonAny(v)
+}
+
+func (g *generator) jsonArray(v *ast.JSONValue, elem func(i int) types.Type) js {
+	elts := make([]string, len(v.Elts))
+	for i, elt := range v.Elts {
+		elts[i] = g.json(elt, elem(i)).code
+	}
+	return js{"[" + strings.Join(elts, ", ") + "]", precPrimary}
+}
+
+// jsonAny returns the JSON value v as a plain JavaScript value, as
+// JSON.parse would.
+func jsonAny(v *ast.JSONValue) js {
+	switch v.Kind {
+	case ast.JSONNull:
+		return js{"null", precPrimary}
+	case ast.JSONBool:
+		return js{v.Text, precPrimary}
+	case ast.JSONNumber:
+		if strings.HasPrefix(v.Text, "-") {
+			return js{v.Text, precUnary}
+		}
+		return js{v.Text, precPrimary}
+	case ast.JSONString:
+		return js{jsString(v.Text), precPrimary}
+	case ast.JSONArray:
+		elts := make([]string, len(v.Elts))
+		for i, elt := range v.Elts {
+			elts[i] = jsonAny(elt).code
+		}
+		return js{"[" + strings.Join(elts, ", ") + "]", precPrimary}
+	}
+	if len(v.Keys) == 0 {
+		return js{"{}", precPrimary}
+	}
+	props := make([]string, len(v.Keys))
+	for i, key := range v.Keys {
+		switch {
+		case key == "__proto__":
+			// a literal __proto__ property would set the prototype
+			key = "[" + jsString(key) + "]"
+		case !isJSIdent(key):
+			key = jsString(key)
+		}
+		props[i] = key + ": " + jsonAny(v.Elts[i]).code
+	}
+	return js{"{ " + strings.Join(props, ", ") + " }", precPrimary}
+}
+
+// parsedJSON reports whether JSON.parse returns the values of type T, which
+// are JSON values themselves.
+func parsedJSON(T types.Type) bool {
+	switch u := T.Underlying().(type) {
+	case *types.Interface:
+		return true
+	case *types.Basic:
+		return isBasic(u, types.Bool, types.Int, types.Float, types.String, types.Symbol)
+	case *types.SymbolSet:
+		return true
+	case *types.Optional:
+		return parsedJSON(u.Elem())
+	case *types.Slice:
+		return parsedJSON(u.Elem())
+	case *types.Array:
+		return parsedJSON(u.Elem())
+	case *types.Tuple:
+		for i := 0; i < u.Len(); i++ {
+			if !parsedJSON(u.At(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// jsonType is the descriptor of a type that json_decode and json_encode
+// convert to and from JSON, returned by a function of the module.
+type jsonType struct {
+	T    *types.Named
+	name string // the name of the function
+	desc string
+}
+
+// jsonDesc returns the descriptor of type T, from which the runtime
+// helpers $decodeJSON and $encodeJSON convert values of T to and from
+// JSON. Descriptors of basic types and any are their names, and those of
+// other types objects with a property telling which they are: symbols for
+// symbol sets, whose symbols are the strings naming them, optional, elem,
+// along with len for arrays, elems for tuples, map, and fields for structs
+// and records, which are converted to instances of their class.
+// Fields missing from the JSON take their zero values, as they do in #json
+// literals, and are an error where they have none.
+// Package-level named types are described by functions returning their
+// descriptors, so that those of recursive types are finite.
+func (g *generator) jsonDesc(T types.Type) string {
+	if n, ok := T.(*types.Named); ok {
+		if _, basic := n.Underlying().(*types.Basic); !basic && g.pkg.Scope().Lookup(n.Obj().Name()) == n.Origin().Obj() {
+			return g.jsonFunc(n)
+		}
+		if g.jsonLocals[n] {
+			g.errorf(n.Obj().Pos(), "cannot convert local recursive type %s to or from JSON", n)
+			return `"any"`
+		}
+		g.jsonLocals[n] = true
+		defer delete(g.jsonLocals, n)
+	}
+	return g.jsonLayout(T)
+}
+
+// jsonLayout returns the descriptor of type T given by its underlying type.
+func (g *generator) jsonLayout(T types.Type) string {
+	switch u := T.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case isBasic(u, types.Bool):
+			return `"bool"`
+		case isInt64(u):
+			return `"int64"`
+		case isInt(u):
+			return `"int"`
+		case isFloat(u):
+			return `"float"`
+		}
+		return `"string"`
+	case *types.SymbolSet:
+		names := make([]string, u.Len())
+		for i := range names {
+			names[i] = jsString(u.Name(i))
+		}
+		return "{ symbols: [" + strings.Join(names, ", ") + "] }"
+	case *types.Optional:
+		return "{ optional: " + g.jsonDesc(u.Elem()) + " }"
+	case *types.Slice:
+		return "{ elem: " + g.jsonDesc(u.Elem()) + " }"
+	case *types.Array:
+		return fmt.Sprintf("{ elem: %s, len: %d }", g.jsonDesc(u.Elem()), u.Len())
+	case *types.Tuple:
+		elems := make([]string, u.Len())
+		for i := range elems {
+			elems[i] = g.jsonDesc(u.At(i))
+		}
+		return "{ elems: [" + strings.Join(elems, ", ") + "] }"
+	case *types.Map:
+		return "{ map: " + g.jsonDesc(u.Elem()) + " }"
+	case *types.Struct, *types.Record:
+		// fields are [key, type] or, where their property differs from their
+		// key or they have a zero value for when they are missing from the
+		// JSON, [key, type, property, zero]; zero values that are objects
+		// are given by functions, as each value takes a new one. Fields of
+		// extern types are keyed by their property
+		fields := structFields(u)
+		descs := make([]string, len(fields))
+		for i, f := range fields {
+			key, prop := f.Name(), fieldName(T, f)
+			if isExtern(T) {
+				key = prop
+			}
+			descs[i] = "[" + jsString(key) + ", " + g.jsonDesc(f.Type())
+			switch {
+			case types.HasZero(f.Type()):
+				zero := g.zero(f.Type())
+				switch f.Type().Underlying().(type) {
+				case *types.Basic, *types.Optional:
+				default:
+					zero = "() => " + zero
+				}
+				descs[i] += ", " + jsString(prop) + ", " + zero
+			case prop != key:
+				descs[i] += ", " + jsString(prop)
+			}
+			descs[i] += "]"
+		}
+		desc := "{ fields: [" + strings.Join(descs, ", ") + "]"
+		if n, ok := T.(*types.Named); ok && hasClass(n) {
+			desc += ", class: " + g.className(n)
+		}
+		if _, ok := u.(*types.Record); ok {
+			desc += ", frozen: true"
+		}
+		return desc + " }"
+	}
+	return `"any"`
+}
+
+// jsonFunc returns the name of the function returning the descriptor of
+// the package-level named type T, declaring it if the module does not yet.
+func (g *generator) jsonFunc(T *types.Named) string {
+	for _, t := range g.jsonTypes {
+		if types.Identical(t.T, T) {
+			return t.name
+		}
+	}
+	name := "$json$" + T.Obj().Name()
+	for i := 1; g.globals[name]; i++ {
+		name = fmt.Sprintf("$json$%s$%d", T.Obj().Name(), i)
+	}
+	g.globals[name] = true
+	i := len(g.jsonTypes)
+	g.jsonTypes = append(g.jsonTypes, jsonType{T: T, name: name})
+	g.jsonTypes[i].desc = g.jsonLayout(T)
+	return name
+}
@@ -0,0 +1,325 @@
+package js
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// JSXRuntime selects the functions JSX elements are compiled to calls of.
+type JSXRuntime int
+
+const (
+	// JSXAutomatic compiles an element to a call of jsx, or of jsxs when
+	// its children are an array, imported from the jsx-runtime module of
+	// the import source, as React 17 and later do. The call takes the
+	// type of the element, its props holding its children, and its key.
+	JSXAutomatic JSXRuntime = iota
+	// JSXClassic compiles an element to a call of the factory imported
+	// from the import source, which takes the type of the element, its
+	// props holding its key, or null, and its children.
+	JSXClassic
+)
+
+var jsxRuntimes = [...]string{"automatic", "classic"}
+
+func (r JSXRuntime) String() string {
+	if r < 0 || int(r) >= len(jsxRuntimes) {
+		return "JSXRuntime(" + strconv.Itoa(int(r)) + ")"
+	}
+	return jsxRuntimes[r]
+}
+
+// A JSXConfig configures the compilation of JSX elements. The zero
+// JSXConfig targets the automatic runtime of React.
+type JSXConfig struct {
+	Runtime JSXRuntime
+	// ImportSource is the module the runtime is imported from, "react" by
+	// default. The automatic runtime is imported from its jsx-runtime
+	// module.
+	ImportSource string
+	// Factory is the function the classic runtime calls, createElement by
+	// default. A path such as React.createElement is looked up in the
+	// namespace of the import source, imported under the root of the path.
+	Factory string
+	// Fragment is the type of fragments in the classic runtime, Fragment
+	// by default, imported like Factory.
+	Fragment string
+}
+
+// jsxAny is the type of the attributes and children of intrinsic elements.
+var jsxAny = types.Universe.Lookup("any").Type()
+
+// module returns the module c imports its functions from.
+func (c JSXConfig) module() string {
+	source := c.ImportSource
+	if source == "" {
+		source = "react"
+	}
+	if c.Runtime == JSXAutomatic {
+		return source + "/jsx-runtime"
+	}
+	return source
+}
+
+// names returns the paths of the functions and the fragment c imports.
+func (c JSXConfig) names() []string {
+	if c.Runtime == JSXAutomatic {
+		return []string{"jsx", "jsxs", "Fragment"}
+	}
+	factory, fragment := c.Factory, c.Fragment
+	if factory == "" {
+		factory = "createElement"
+	}
+	if fragment == "" {
+		fragment = "Fragment"
+	}
+	return []string{factory, fragment}
+}
+
+// jsxNames names the bindings JSX elements are compiled to, if the package
+// has any, under local names taken as extern declarations take theirs:
+// bindings are imported by name, and paths from the namespace of the
+// module, named by their root. They are added to the imports
+// of the module when elements use them.
+func (g *generator) jsxNames() {
+	found := false
+	for _, file := range g.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if _, ok := n.(*ast.JSXElement); ok {
+				found = true
+			}
+			return !found
+		})
+	}
+	if !found {
+		return
+	}
+	module := g.jsx.module()
+	for _, name := range g.jsx.names() {
+		root, path, _ := strings.Cut(name, ".")
+		local := g.reserveImport(module, jsxImported(name), Mangle(root), "$jsx")
+		if path != "" {
+			local += "." + path
+		}
+		g.jsxRefs = append(g.jsxRefs, local)
+	}
+}
+
+// jsxRef returns the local name of the i-th binding of g.jsx.names,
+// importing it.
+func (g *generator) jsxRef(i int) string {
+	g.importName(g.jsx.module(), jsxImported(g.jsx.names()[i]))
+	return g.jsxRefs[i]
+}
+
+// jsxImported returns the name under which the binding at path is
+// imported: its name, or "*" for the namespace of the module holding it
+// if it is a path.
+func jsxImported(path string) string {
+	if strings.Contains(path, ".") {
+		return "*"
+	}
+	return path
+}
+
+// jsxElement returns the call creating the JSX element e. The props of
+// components are plain objects holding all the fields of their type, so
+// that the components receive the values they declare.
+func (g *generator) jsxElement(e *ast.JSXElement) js {
+	// the values of the element are operands, spilled if a later one
+	// hoists statements
+	var vals operandList
+	value := func(x ast.Expr, T types.Type) int {
+		return g.operand(&vals, g.value(x, T), x)
+	}
+	literal := func(code string) int {
+		return g.operand(&vals, js{code, precPrimary}, nil)
+	}
+
+	var typ string
+	var props []jsxProp
+	key := -1
+	for _, a := range e.Attrs {
+		if a.Name == "key" {
+			key = value(a.Value, nil)
+		}
+	}
+
+	// children holds the children of an intrinsic element or a fragment,
+	// and those of a component when they are its field children.
+	var children []int
+	static := false // whether children is an array literal
+	if e.Name == nil {
+		typ = jsString(e.Tag)
+		if e.Tag == "" {
+			// the fragment is the last of the names
+			typ = g.jsxRef(len(g.jsxRefs) - 1)
+		}
+		for _, a := range e.Attrs {
+			if a.Name == "key" {
+				continue
+			}
+			var v int
+			if a.Value != nil {
+				v = value(a.Value, jsxAny)
+			} else {
+				v = literal("true")
+			}
+			props = append(props, jsxProp{propKey(a.Name), []int{v}, false})
+		}
+		for _, child := range e.Children {
+			children = append(children, value(child, jsxAny))
+		}
+		static = len(children) > 1
+	} else {
+		typ = g.expr(e.Name).code
+		sig := g.info.TypeOf(e.Name).Underlying().(*types.Signature)
+		if len(sig.Params()) == 1 {
+			T := sig.Params()[0].Type()
+			for _, f := range structFields(T.Underlying()) {
+				prop := jsxProp{key: fieldName(T, f)}
+				if f.Name() == "children" && len(e.Children) > 0 {
+					prop.vals, prop.array = g.jsxChildren(e.Children, f.Type(), value)
+					static = prop.array
+				} else if v := g.jsxAttr(e.Attrs, f, value, literal); v >= 0 {
+					prop.vals = []int{v}
+				} else {
+					if _, ok := f.Type().Underlying().(*types.Optional); ok && isExtern(T) {
+						// JavaScript APIs expect missing options to be absent
+						continue
+					}
+					prop.vals = []int{literal(g.zero(f.Type()))}
+				}
+				props = append(props, prop)
+			}
+		}
+	}
+	g.spillOperands(&vals)
+
+	code := func(i int) string { return vals.xs[i].code }
+	var fields []string
+	for _, p := range props {
+		fields = append(fields, p.key+": "+p.value(code))
+	}
+	var kids []string
+	for _, c := range children {
+		kids = append(kids, code(c))
+	}
+
+	if g.jsx.Runtime == JSXClassic {
+		if key >= 0 {
+			fields = append(fields, "key: "+code(key))
+		}
+		args := []string{typ, "null"}
+		if len(fields) > 0 {
+			args[1] = "{ " + strings.Join(fields, ", ") + " }"
+		}
+		return js{g.jsxRef(0) + "(" + strings.Join(append(args, kids...), ", ") + ")", precCall}
+	}
+
+	switch {
+	case len(kids) == 1:
+		fields = append(fields, "children: "+kids[0])
+	case len(kids) > 1:
+		fields = append(fields, "children: ["+strings.Join(kids, ", ")+"]")
+	}
+	fn := g.jsxRef(0)
+	if static {
+		fn = g.jsxRef(1)
+	}
+	args := []string{typ, "{}"}
+	if len(fields) > 0 {
+		args[1] = "{ " + strings.Join(fields, ", ") + " }"
+	}
+	if key >= 0 {
+		args = append(args, code(key))
+	}
+	return js{fn + "(" + strings.Join(args, ", ") + ")", precCall}
+}
+
+// propsPrologue returns the statement that converts the props a component
+// with the signature sig is passed, which are a plain object when JSX
+// renders it, to an instance of the class of their type, if it has one.
+func (g *generator) propsPrologue(sig *types.Signature) string {
+	if len(sig.Params()) != 1 {
+		return ""
+	}
+	p := sig.Params()[0]
+	n, ok := p.Type().(*types.Named)
+	if !ok || !hasClass(n) || p.Name() == "" || p.Name() == "_" {
+		return ""
+	}
+	name, class := g.locals.declare(p), g.className(n)
+	conv := g.use("$convert") + "(" + name + ", " + class + ")"
+	if _, ok := n.Underlying().(*types.Record); ok {
+		conv = "Object.freeze(" + conv + ")"
+	}
+	return fmt.Sprintf("if (!(%s instanceof %s)) %s = %s;", name, class, name, conv)
+}
+
+// A jsxProp is a prop of a JSX element: the operands of its value, which
+// is an array literal if array is set.
+type jsxProp struct {
+	key   string
+	vals  []int
+	array bool
+}
+
+// value returns the value of p given the code of its operands.
+func (p jsxProp) value(code func(int) string) string {
+	if !p.array {
+		return code(p.vals[0])
+	}
+	elts := make([]string, len(p.vals))
+	for i, v := range p.vals {
+		elts[i] = code(v)
+	}
+	return "[" + strings.Join(elts, ", ") + "]"
+}
+
+// jsxAttr returns the operand of the value of the prop f set by one of
+// attrs, or -1 if none sets it.
+func (g *generator) jsxAttr(attrs []*ast.JSXAttr, f *types.Var, value func(ast.Expr, types.Type) int, literal func(string) int) int {
+	for _, a := range attrs {
+		if a.Name != f.Name() {
+			continue
+		}
+		if a.Value == nil {
+			return literal("true")
+		}
+		return value(a.Value, f.Type())
+	}
+	return -1
+}
+
+// jsxChildren returns the operands of the value of the field children of
+// type T of the props of a component holding children, and whether it is
+// an array literal. A single child not of type T is an element of the
+// slice T.
+func (g *generator) jsxChildren(children []ast.Expr, T types.Type, value func(ast.Expr, types.Type) int) ([]int, bool) {
+	s, _ := T.Underlying().(*types.Slice)
+	if V := g.info.TypeOf(children[0]); len(children) == 1 && (s == nil || V != nil && types.AssignableTo(V, T)) {
+		return []int{value(children[0], T)}, false
+	}
+	elem := T
+	if s != nil {
+		elem = s.Elem()
+	}
+	vals := make([]int, len(children))
+	for i, child := range children {
+		vals[i] = value(child, elem)
+	}
+	return vals, true
+}
+
+// propKey returns the property name of the attribute named name.
+func propKey(name string) string {
+	if isJSIdent(name) {
+		return name
+	}
+	return jsString(name)
+}
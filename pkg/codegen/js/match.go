@@ -0,0 +1,1063 @@
+package js
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// binding is a variable bound by a pattern.
+type binding struct {
+	v   *types.Var
+	val js
+}
+
+// pattern returns the condition under which the value x, of type T,
+// matches p, or "" if it always does, and appends the variables p binds to
+// binds.
+func (g *generator) pattern(p ast.Pattern, x js, T types.Type, binds *[]binding) js {
+	switch p := p.(type) {
+	case *ast.BindPat:
+		if v, ok := g.info.Defs[p.Name].(*types.Var); ok && p.Name.Name != "_" {
+			*binds = append(*binds, binding{v, x})
+		}
+
+	case *ast.LitPat, *ast.VariantPat:
+		val, VT := g.patternValue(p)
+		if VT != nil {
+			return g.equalJS(x, T, val, VT, false)
+		}
+		vp := p.(*ast.VariantPat)
+		name := js{jsString(variantName(vp)), precPrimary}
+		return and([]js{infix(tag(x), "===", precEq, name), g.payload(vp, x, nil, binds)})
+
+	case *ast.TuplePat:
+		t, _ := T.Underlying().(*types.Tuple)
+		elems := g.elems[x]
+		var conds []js
+		for i, elt := range p.Elts {
+			var ET types.Type
+			if t != nil {
+				ET = t.At(i)
+			}
+			ex := js{x.at(precCall) + "[" + strconv.Itoa(i) + "]", precCall}
+			if elems != nil {
+				ex = elems[i]
+			}
+			conds = append(conds, g.pattern(elt, ex, ET, binds))
+		}
+		return and(conds)
+
+	case *ast.RecordPat:
+		var conds []js
+		for _, fp := range p.Fields {
+			f, _ := g.info.Uses[fp.Name].(*types.Var)
+			if f == nil {
+				continue
+			}
+			fx := js{x.at(precCall) + "." + fieldName(T, f), precCall}
+			if fp.Pattern == nil {
+				if v, ok := g.info.Defs[fp.Name].(*types.Var); ok {
+					*binds = append(*binds, binding{v, fx})
+				}
+				continue
+			}
+			conds = append(conds, g.pattern(fp.Pattern, fx, f.Type(), binds))
+		}
+		return and(conds)
+
+	case *ast.OrPat:
+		return g.orPattern(p, x, T, binds)
+	}
+	return js{}
+}
+
+// patternValue returns the value that the literal or variant pattern p
+// compares the matched value to, and its type, or a nil type for the
+// variants of tagged enums, which are told apart by their tags.
+func (g *generator) patternValue(p ast.Pattern) (js, types.Type) {
+	if p, ok := p.(*ast.LitPat); ok {
+		return g.expr(p.Value), g.info.TypeOf(p.Value)
+	}
+	path := p.(*ast.VariantPat).Path
+	tv := g.info.Types[path]
+	if tv.Value != nil {
+		return constLit(tv.Value, tv.Type), tv.Type
+	}
+	t, _ := tv.Type.(*types.Named)
+	if _, ok := tv.Type.Underlying().(*types.Enum); !ok || t == nil {
+		return g.expr(path), tv.Type
+	}
+	if g.isTagged(t) {
+		return js{}, nil
+	}
+	return g.variant(t, path.(*ast.SelectorExpr).Sel.Name), tv.Type
+}
+
+// variantName returns the name of the variant that p matches.
+func variantName(p *ast.VariantPat) string {
+	return p.Path.(*ast.SelectorExpr).Sel.Name
+}
+
+// tag returns the tag of x, a value of a tagged enum.
+func tag(x js) js {
+	return js{x.at(precCall) + ".$tag", precCall}
+}
+
+// payload returns the condition under which the payload of x, a value of
+// a tagged enum with the variant p matches, matches the patterns of p, and
+// appends the variables they bind to binds. The fields of the payload are
+// read from x unless locals, which may be nil, holds them.
+func (g *generator) payload(p *ast.VariantPat, x js, locals []js, binds *[]binding) js {
+	v := g.info.TypeOf(p.Path).Underlying().(*types.Enum).Lookup(variantName(p))
+	conds := make([]js, len(p.Args))
+	for i, arg := range p.Args {
+		elt := js{x.at(precCall) + ".$" + strconv.Itoa(i), precCall}
+		if i < len(locals) && locals[i].code != "" {
+			elt = locals[i]
+		}
+		conds[i] = g.pattern(arg, elt, v.Payload()[i].Type(), binds)
+	}
+	return and(conds)
+}
+
+// payloadLocals declares locals holding the fields of the payload of x, a
+// value of a tagged enum, that more than one of arms reads, which are arms
+// matching variants with the same payload fields. It returns them by index,
+// with empty values for the fields read from x, or nil if there are none.
+func (g *generator) payloadLocals(arms []*ast.MatchArm, x js) []js {
+	var reads []int
+	for _, arm := range arms {
+		p, ok := arm.Pattern.(*ast.VariantPat)
+		if !ok {
+			continue
+		}
+		for i, arg := range p.Args {
+			for len(reads) <= i {
+				reads = append(reads, 0)
+			}
+			if !ignored(arg) {
+				reads[i]++
+			}
+		}
+	}
+	var locals []js
+	for i, n := range reads {
+		if n < 2 {
+			continue
+		}
+		if locals == nil {
+			locals = make([]js, len(reads))
+		}
+		t := g.locals.temp("p")
+		g.line("const %s = %s.$%d;", t, x.at(precCall), i)
+		locals[i] = js{t, precPrimary}
+	}
+	return locals
+}
+
+// ignored reports whether the pattern p matches any value without binding
+// it.
+func ignored(p ast.Pattern) bool {
+	switch p := p.(type) {
+	case *ast.WildcardPat:
+		return true
+	case *ast.BindPat:
+		return p.Name.Name == "_"
+	}
+	return false
+}
+
+// orPattern returns the condition under which x matches one of the
+// alternatives of p. A variable bound by the alternatives takes its value
+// from the first alternative that matches.
+func (g *generator) orPattern(p *ast.OrPat, x js, T types.Type, binds *[]binding) js {
+	conds := make([]js, len(p.Alts))
+	alts := make([][]binding, len(p.Alts))
+	always := false
+	for i, alt := range p.Alts {
+		conds[i] = g.pattern(alt, x, T, &alts[i])
+		if conds[i].code == "" {
+			always = true
+		}
+	}
+	for _, b := range alts[0] {
+		var val js
+		for i := len(p.Alts) - 1; i >= 0; i-- {
+			var alt js
+			for _, ab := range alts[i] {
+				if ab.v == b.v {
+					alt = ab.val
+				}
+			}
+			if val.code == "" || conds[i].code == "" {
+				val = alt
+				continue
+			}
+			val = js{conds[i].at(precNullish) + " ? " + alt.at(precAssign) + " : " + val.at(precAssign), precCond}
+		}
+		*binds = append(*binds, binding{b.v, val})
+	}
+	if always {
+		return js{}
+	}
+	codes := make([]string, len(conds))
+	for i, c := range conds {
+		codes[i] = c.at(precOr + 1)
+	}
+	return js{strings.Join(codes, " || "), precOr}
+}
+
+// and returns the conjunction of the conditions conds, of which empty ones
+// always hold.
+func and(conds []js) js {
+	var codes []string
+	for _, c := range conds {
+		if c.code != "" {
+			codes = append(codes, c.at(precAnd+1))
+		}
+	}
+	switch len(codes) {
+	case 0:
+		return js{}
+	case 1:
+		for _, c := range conds {
+			if c.code != "" {
+				return c
+			}
+		}
+	}
+	return js{strings.Join(codes, " && "), precAnd}
+}
+
+// bind declares the variables bound by the pattern of an arm whose body is
+// body.
+func (g *generator) bind(binds []binding, body ast.Node) {
+	for _, b := range binds {
+		keyword := "const"
+		val := b.val
+		if g.assigned[b.v] || g.modifies(body, b.v) {
+			keyword = "let"
+			val = g.clone(val, b.v.Type())
+		}
+		g.line("%s %s = %s;", keyword, g.named(b.v, g.locals.declare(b.v)), val.at(precAssign))
+	}
+}
+
+// subject returns the value matched by a match, stored in a temporary
+// unless it is simple. A tuple literal whose elements the patterns take
+// apart is not built; see splitTuple.
+func (g *generator) subject(e *ast.MatchExpr) js {
+	if lit := splitSubject(e); lit != nil {
+		return g.splitTuple(lit)
+	}
+	x := g.expr(e.X)
+	if g.simpleSubject(e.X) {
+		return x
+	}
+	t := g.locals.temp("s")
+	g.line("const %s = %s;", t, x.at(precAssign))
+	return js{t, precPrimary}
+}
+
+// splitSubject returns the subject of the match e if it is a tuple literal
+// and the patterns of all arms take the tuple apart or ignore it, so that
+// its elements may be matched without building it.
+func splitSubject(e *ast.MatchExpr) *ast.TupleLit {
+	lit, ok := unparen(e.X).(*ast.TupleLit)
+	if !ok {
+		return nil
+	}
+	for _, arm := range e.Arms {
+		if !splits(arm.Pattern) {
+			return nil
+		}
+	}
+	return lit
+}
+
+// splits reports whether the pattern p of a tuple takes it apart or
+// ignores it.
+func splits(p ast.Pattern) bool {
+	switch p := p.(type) {
+	case *ast.TuplePat:
+		return true
+	case *ast.OrPat:
+		for _, alt := range p.Alts {
+			if !splits(alt) {
+				return false
+			}
+		}
+		return true
+	}
+	return ignored(p)
+}
+
+// splitTuple returns the tuple literal lit matched by a match as the array
+// literal of its elements, which tuple patterns read from the values that
+// elems maps it to: the elements themselves, or temporaries holding them
+// where splitTemps says so.
+func (g *generator) splitTuple(lit *ast.TupleLit) js {
+	t, _ := g.info.TypeOf(lit).(*types.Tuple)
+	temps := g.splitTemps(lit)
+	elems := make([]js, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		var T types.Type
+		if t != nil {
+			T = t.At(i)
+		}
+		elems[i] = g.value(elt, T)
+		if temps[i] {
+			tmp := g.temp("s")
+			g.line("const %s = %s;", tmp, elems[i].at(precAssign))
+			elems[i] = js{tmp, precPrimary}
+		}
+	}
+	x := js{"[" + strings.Join(codes(elems), ", ") + "]", precPrimary}
+	g.elems[x] = elems
+	return x
+}
+
+// splitTemps reports which elements of the tuple literal lit a match takes
+// apart are held in temporaries: those that are not simple subjects or that
+// are copied or converted to the type of their element, and the variables
+// before an element calling a function, which could assign them.
+func (g *generator) splitTemps(lit *ast.TupleLit) []bool {
+	t, _ := g.info.TypeOf(lit).(*types.Tuple)
+	temps := make([]bool, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		V := g.info.TypeOf(elt)
+		switch {
+		case !g.simpleSubject(elt) || isValueType(V):
+			temps[i] = true
+		case t != nil && V != nil && !types.Identical(V, t.At(i)):
+			temps[i] = true
+		}
+	}
+	calls := false
+	for i := len(lit.Elts) - 1; i >= 0; i-- {
+		if calls && g.info.Types[lit.Elts[i]].Value == nil {
+			temps[i] = true
+		}
+		calls = calls || hasCall(lit.Elts[i])
+	}
+	return temps
+}
+
+// hasCall reports whether e calls a function.
+func hasCall(e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		_, call := n.(*ast.CallExpr)
+		found = found || call
+		return !found
+	})
+	return found
+}
+
+// splitsInPlace reports whether the subject of the match e is a tuple
+// literal taken apart without temporaries.
+func (g *generator) splitsInPlace(e *ast.MatchExpr) bool {
+	lit := splitSubject(e)
+	return lit != nil && !slices.Contains(g.splitTemps(lit), true)
+}
+
+// simpleSubject reports whether the subject e of a match may be read by
+// each of its arms rather than stored in a temporary: it is a constant or
+// a variable, which reading does not evaluate again.
+func (g *generator) simpleSubject(e ast.Expr) bool {
+	if tv, ok := g.info.Types[e]; ok && tv.Value != nil {
+		return true
+	}
+	switch x := unparen(e).(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return g.info.Selections[x] == nil // an enum variant
+	}
+	return false
+}
+
+// A sink receives the values of the arms of a match generated as
+// statements whose value a statement uses: set returns the statement,
+// without its semicolon, storing the value v of type T, and ret reports
+// whether that statement returns.
+type sink struct {
+	set func(v string) string
+	T   types.Type
+	ret bool
+}
+
+// returns is the sink of a match whose value is returned as one of type
+// T.
+func returns(T types.Type) *sink {
+	return &sink{set: func(v string) string { return "return " + v }, T: T, ret: true}
+}
+
+// armBody generates the body of a match arm, passing its value to out if
+// the match is used as a value.
+func (g *generator) armBody(body ast.Stmt, out *sink) {
+	s, ok := body.(*ast.ExprStmt)
+	if !ok || out == nil || leaves(body) {
+		g.body(body)
+		return
+	}
+	if m := g.stmtMatch(s.X); m != nil {
+		g.matchStmt(m, out)
+		return
+	}
+	g.line("%s;", out.set(g.value(s.X, out.T).at(precAssign)))
+}
+
+// armLeaves reports whether the arm with body always leaves the match
+// that passes the values of its arms to out.
+func armLeaves(body ast.Stmt, out *sink) bool {
+	return leaves(body) || out != nil && out.ret
+}
+
+// stmtMatch returns e if it is a match whose value a statement uses that
+// is better generated as statements: one that becomes a switch statement,
+// or whose arms do not fit in conditional expressions.
+func (g *generator) stmtMatch(e ast.Expr) *ast.MatchExpr {
+	m, ok := unparen(e).(*ast.MatchExpr)
+	if !ok || g.condArms(m) && g.switchMatch(m, g.info.TypeOf(m.X)) == nil {
+		return nil
+	}
+	return m
+}
+
+// matchStmt generates a match as statements, passing the values of its
+// arms to out if it is used as a value. A match testing the discriminant
+// of its subject against constants in enough cases becomes a switch
+// statement, and the others a chain of if statements.
+func (g *generator) matchStmt(e *ast.MatchExpr, out *sink) {
+	T := g.info.TypeOf(e.X)
+	s := g.switchMatch(e, T)
+	if s == nil {
+		for _, arm := range e.Arms {
+			if arm.Guard != nil && bindsVars(arm.Pattern) {
+				g.matchBlock(e, T, out)
+				return
+			}
+		}
+	}
+
+	ret := out != nil && out.ret
+	label := ""
+	// a match that returns ends the block, which need not scope its
+	// subject
+	wrapped := !g.simpleSubject(e.X) && !g.splitsInPlace(e) && !ret
+	if s != nil && len(s.rest) > 0 && !s.leaves(out) {
+		label = g.locals.temp("match")
+		g.open("%s: {", label)
+	} else if wrapped {
+		g.open("{")
+	}
+	x := g.subject(e)
+	switch {
+	case s == nil:
+		g.matchIfs(e, x, T, out)
+	case label != "":
+		g.matchSwitch(s, x, T, out, "break "+label+";")
+	default:
+		g.matchSwitch(s, x, T, out, "break;")
+	}
+	if label != "" || wrapped {
+		g.close("}")
+	}
+}
+
+// matchIfs generates the arms of a match whose subject x has type T as a
+// chain of if statements.
+func (g *generator) matchIfs(e *ast.MatchExpr, x js, T types.Type, out *sink) {
+	first := true
+	for _, arm := range e.Arms {
+		var binds []binding
+		cond := g.pattern(arm.Pattern, x, T, &binds)
+		if arm.Guard != nil {
+			cond = and([]js{cond, g.guard(arm.Guard)})
+		}
+		switch {
+		case cond.code == "" && first:
+			g.open("{")
+		case cond.code == "":
+			g.indent--
+			g.open("} else {")
+		case first:
+			g.open("if (%s) {", cond.code)
+		default:
+			g.indent--
+			g.open("} else if (%s) {", cond.code)
+		}
+		first = false
+		g.bind(binds, arm.Body)
+		g.armBody(arm.Body, out)
+		if cond.code == "" {
+			break
+		}
+	}
+	if !first {
+		g.close("}")
+	}
+}
+
+// matchBlock generates a match with guards that read the variables of
+// their patterns, whose subject has type T. Its arms are tested in turn
+// in a labeled block, which the arm that matches leaves, unless all of
+// them return.
+func (g *generator) matchBlock(e *ast.MatchExpr, T types.Type, out *sink) {
+	label := ""
+	if out == nil || !out.ret {
+		label = g.locals.temp("match")
+		g.open("%s: {", label)
+	}
+	x := g.subject(e)
+	g.armsInTurn(e.Arms, x, T, out, "break "+label+";")
+	if label != "" {
+		g.close("}")
+	}
+}
+
+// armsInTurn generates the arms of a match whose subject x has type T, of
+// which the last one always matches, as statements testing them in turn.
+// The arm that matches then leaves the match with the statement exit,
+// unless it is the last one.
+func (g *generator) armsInTurn(arms []*ast.MatchArm, x js, T types.Type, out *sink, exit string) {
+	for _, arm := range arms {
+		var binds []binding
+		cond := g.pattern(arm.Pattern, x, T, &binds)
+		if cond.code == "" && arm.Guard == nil {
+			g.bind(binds, arm.Body)
+			g.armBody(arm.Body, out)
+			return
+		}
+		g.testArm(arm, cond, binds, out, exit)
+	}
+}
+
+// testArm generates an arm of a match that is selected if cond holds and
+// then its guard, with the variables binds of its pattern. The arm then
+// leaves the match with the statement exit.
+func (g *generator) testArm(arm *ast.MatchArm, cond js, binds []binding, out *sink, exit string) {
+	guard := arm.Guard
+	if guard != nil && len(binds) == 0 {
+		cond = and([]js{cond, g.guard(guard)})
+		guard = nil
+	}
+	if cond.code == "" {
+		g.open("{")
+	} else {
+		g.open("if (%s) {", cond.code)
+	}
+	g.bind(binds, arm.Body)
+	if guard != nil {
+		g.open("if (%s) {", g.expr(guard).code)
+	}
+	g.armBody(arm.Body, out)
+	if !armLeaves(arm.Body, out) {
+		g.line("%s", exit)
+	}
+	if guard != nil {
+		g.close("}")
+	}
+	g.close("}")
+}
+
+// guard returns the guard of a match arm tested along with its pattern,
+// or after the arms before it, before which no statement may be hoisted.
+func (g *generator) guard(e ast.Expr) js {
+	return g.inlined(func() js { return g.expr(e) })
+}
+
+// minSwitchCases is the number of cases from which a match becomes a
+// switch statement rather than a chain of if statements, which it also
+// becomes with fewer cases if several arms share one.
+const minSwitchCases = 3
+
+// A switchCase is a case of the switch statement generating a match: the
+// labels selecting it, whether it is the default case, and the arms it
+// tests in turn.
+type switchCase struct {
+	labels []string
+	def    bool
+	arms   []*ast.MatchArm
+}
+
+// A switchStmt is the switch statement on the discriminant of the subject
+// of a match: the tag of a tagged enum, and otherwise the subject itself.
+// Values that no case matches go on to the arms rest, tested in turn
+// after the switch.
+type switchStmt struct {
+	cases []*switchCase
+	rest  []*ast.MatchArm
+}
+
+// leaves reports whether the arms of the cases of s always leave the
+// match that passes their values to out.
+func (s *switchStmt) leaves(out *sink) bool {
+	for _, c := range s.cases {
+		for _, arm := range c.arms {
+			if !armLeaves(arm.Body, out) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// switchMatch returns the switch statement generating the match e, whose
+// subject has type T. It returns nil unless the patterns of the arms
+// compare the discriminant to constants, up to arms matching any
+// discriminant that follow them, each arm belongs to a single case and no
+// arm breaks out of the match, which would leave the switch instead.
+func (g *generator) switchMatch(e *ast.MatchExpr, T types.Type) *switchStmt {
+	labels := make([][]string, len(e.Arms))
+	var order []string
+	seen := make(map[string]bool)
+	n := len(e.Arms) // the number of arms with labels
+	for i, arm := range e.Arms {
+		l, ok := g.caseLabels(arm.Pattern, T)
+		if !ok || breaks(arm.Body) || l != nil && i > n {
+			return nil
+		}
+		if l == nil && i < n {
+			n = i
+		}
+		labels[i] = l
+		for _, label := range l {
+			if !seen[label] {
+				seen[label] = true
+				order = append(order, label)
+			}
+		}
+	}
+
+	s := new(switchStmt)
+	byArms := make(map[string]*switchCase)
+	used := make(map[int]bool)
+	open := false // some case may match no arm
+	for _, label := range order {
+		// the arms that the values with the label select in turn, up to
+		// the first one they always match
+		var list []int
+		matched := false
+		for i, arm := range e.Arms[:n] {
+			if slices.Contains(labels[i], label) {
+				list = append(list, i)
+				if arm.Guard == nil && irrefutableRest(arm.Pattern) {
+					matched = true
+					break
+				}
+			}
+		}
+		open = open || !matched
+		key := fmt.Sprint(list)
+		c := byArms[key]
+		if c == nil {
+			c = new(switchCase)
+			for _, i := range list {
+				if used[i] {
+					return nil
+				}
+				used[i] = true
+				c.arms = append(c.arms, e.Arms[i])
+			}
+			byArms[key] = c
+			s.cases = append(s.cases, c)
+		}
+		c.labels = append(c.labels, label)
+	}
+	for _, arm := range e.Arms[n:] {
+		s.rest = append(s.rest, arm)
+		if arm.Guard == nil && irrefutable(arm.Pattern) {
+			break
+		}
+	}
+	if len(s.rest) > 0 && !open {
+		s.cases = append(s.cases, &switchCase{def: true, arms: s.rest})
+		s.rest = nil
+	}
+	if len(s.cases)+min(len(s.rest), 1) < minSwitchCases && !s.grouped() {
+		return nil
+	}
+	return s
+}
+
+// grouped reports whether a case of s tests several arms, whose patterns
+// would each test the discriminant again in a chain of if statements.
+func (s *switchStmt) grouped() bool {
+	for _, c := range s.cases {
+		if len(c.arms) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// caseLabels returns the labels of the cases of a switch on the
+// discriminant of values of type T that select the values p matches, or
+// nil if p matches any discriminant. It reports false if p does not
+// compare the discriminant to constants, apart from the payloads of the
+// variants of tagged enums.
+func (g *generator) caseLabels(p ast.Pattern, T types.Type) ([]string, bool) {
+	switch p := p.(type) {
+	case *ast.WildcardPat, *ast.BindPat:
+		return nil, true
+
+	case *ast.LitPat, *ast.VariantPat:
+		val, VT := g.patternValue(p)
+		switch {
+		case VT == nil:
+			if g.isTagged(T) {
+				return []string{jsString(variantName(p.(*ast.VariantPat)))}, true
+			}
+		case g.isTagged(T):
+		case isBasic(VT, types.UntypedNil) || identityEqual(T) && identityEqual(VT):
+			return []string{plain(val.code)}, true
+		}
+
+	case *ast.OrPat:
+		var labels []string
+		for _, alt := range p.Alts {
+			l, ok := g.caseLabels(alt, T)
+			if !ok || l == nil || bindsVars(alt) || !irrefutableRest(alt) {
+				return nil, false
+			}
+			labels = append(labels, l...)
+		}
+		return labels, true
+	}
+	return nil, false
+}
+
+// matchSwitch generates a match whose subject x has type T as the switch
+// statement s. The arm that matches leaves the match with the statement
+// exit.
+func (g *generator) matchSwitch(s *switchStmt, x js, T types.Type, out *sink, exit string) {
+	disc := x
+	if g.isTagged(T) {
+		disc = tag(x)
+	}
+	g.open("switch (%s) {", disc.code)
+	for i, c := range s.cases {
+		var labels []string
+		for _, label := range c.labels {
+			labels = append(labels, "case "+label+":")
+		}
+		if c.def {
+			labels = append(labels, "default:")
+		}
+		for _, label := range labels[:len(labels)-1] {
+			g.line("%s", label)
+		}
+		g.open("%s {", labels[len(labels)-1])
+		last := i == len(s.cases)-1
+		matched := false
+		var locals []js
+		if g.isTagged(T) && !c.def {
+			locals = g.payloadLocals(c.arms, x)
+		}
+		for _, arm := range c.arms {
+			var binds []binding
+			cond := g.caseArm(c, arm, x, T, locals, &binds)
+			if cond.code != "" || arm.Guard != nil {
+				g.testArm(arm, cond, binds, out, exit)
+				continue
+			}
+			g.bind(binds, arm.Body)
+			g.armBody(arm.Body, out)
+			if !armLeaves(arm.Body, out) && (!last || len(s.rest) > 0) {
+				g.line("%s", exit)
+			}
+			matched = true
+		}
+		if !matched && !last {
+			// go on with the arms after the switch
+			g.line("break;")
+		}
+		g.close("}")
+	}
+	g.close("}")
+	g.armsInTurn(s.rest, x, T, out, exit)
+}
+
+// caseArm returns the condition under which the subject x of type T
+// matches the arm of the case c of a switch on its discriminant, given that
+// the switch selects c, and appends the variables the arm binds to binds.
+// locals holds the fields of the payload read into locals, if any.
+func (g *generator) caseArm(c *switchCase, arm *ast.MatchArm, x js, T types.Type, locals []js, binds *[]binding) js {
+	if p, ok := arm.Pattern.(*ast.VariantPat); ok && g.isTagged(T) {
+		return g.payload(p, x, locals, binds)
+	}
+	if c.def {
+		return g.pattern(arm.Pattern, x, T, binds)
+	}
+	return js{}
+}
+
+// condArms reports whether the arms of the match e fit in a chain of
+// conditional expressions: they are expressions without functions, which
+// could capture the variables of the patterns, so that these variables may
+// be read from the subject where they are used, and without expressions
+// that hoist statements, which would not be evaluated conditionally.
+func (g *generator) condArms(e *ast.MatchExpr) bool {
+	for _, arm := range e.Arms {
+		if _, ok := arm.Body.(*ast.ExprStmt); !ok || leaves(arm.Body) {
+			return false
+		}
+		found := false
+		ast.Inspect(arm, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit, *ast.ListComp, *ast.MapComp:
+				found = true
+			case *ast.MatchExpr:
+				found = !g.condArms(n)
+			}
+			return !found
+		})
+		if found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCond returns a match expression whose subject is x as a chain of
+// conditional expressions, in which the variables of the patterns are read
+// from the subject. Only its first condition is always evaluated. A match
+// that would become a switch statement, with no arms left to test after
+// it, tests the discriminant of its subject once for each case, and then
+// the arms of the case.
+func (g *generator) matchCond(e *ast.MatchExpr, x js) js {
+	ST := g.info.TypeOf(e.X)
+	if s := g.switchMatch(e, ST); s != nil && len(s.rest) == 0 {
+		return g.switchCond(e, s, x)
+	}
+	return g.armsCond(e, e.Arms, func(arm *ast.MatchArm, binds *[]binding) js {
+		return g.pattern(arm.Pattern, x, ST, binds)
+	})
+}
+
+// switchCond returns the match e whose subject is x, which becomes the
+// switch statement s, as a chain of conditional expressions testing the
+// discriminant of x against the labels of each case.
+func (g *generator) switchCond(e *ast.MatchExpr, s *switchStmt, x js) js {
+	ST := g.info.TypeOf(e.X)
+	disc := x
+	if g.isTagged(ST) {
+		disc = tag(x)
+	}
+	result := undefined
+	for i := len(s.cases) - 1; i >= 0; i-- {
+		c := s.cases[i]
+		val := g.armsCond(e, c.arms, func(arm *ast.MatchArm, binds *[]binding) js {
+			return g.caseArm(c, arm, x, ST, nil, binds)
+		})
+		// matches are exhaustive, so the last case holds the values that
+		// the others do not
+		if i == len(s.cases)-1 {
+			result = val
+			continue
+		}
+		conds := make([]string, len(c.labels))
+		for j, label := range c.labels {
+			conds[j] = infix(disc, "===", precEq, js{label, precUnary}).at(precOr + 1)
+		}
+		cond := js{strings.Join(conds, " || "), precOr}
+		if len(conds) == 1 {
+			cond.prec = precEq
+		}
+		result = js{cond.at(precNullish) + " ? " + val.at(precAssign) + " : " + result.at(precAssign), precCond}
+	}
+	return result
+}
+
+// armsCond returns the arms of the match e as a chain of conditional
+// expressions, given the conditions under which test reports that they
+// match, along with the variables they bind. The last arm matches the
+// values the others do not.
+func (g *generator) armsCond(e *ast.MatchExpr, arms []*ast.MatchArm, test func(arm *ast.MatchArm, binds *[]binding) js) js {
+	T := g.info.TypeOf(e)
+	var conds, vals []js
+	result := undefined
+	for i, arm := range arms {
+		var binds []binding
+		cond := test(arm, &binds)
+		for _, b := range binds {
+			g.locals.names[b.v] = b.val.at(precCall)
+		}
+		if arm.Guard != nil {
+			cond = and([]js{cond, g.guard(arm.Guard)})
+		}
+		val := g.inlined(func() js { return g.value(arm.Body.(*ast.ExprStmt).X, T) })
+		for _, b := range binds {
+			delete(g.locals.names, b.v)
+		}
+		// matches are exhaustive, so the last arm matches the values
+		// that the others do not
+		if cond.code == "" || i == len(arms)-1 && arm.Guard == nil {
+			result = val
+			break
+		}
+		conds = append(conds, cond)
+		vals = append(vals, val)
+	}
+	for i := len(conds) - 1; i >= 0; i-- {
+		result = js{conds[i].at(precNullish) + " ? " + vals[i].at(precAssign) + " : " + result.at(precAssign), precCond}
+	}
+	return result
+}
+
+// matchValue returns a match expression. A match whose arms fit in a
+// chain of conditional expressions becomes one, testing its subject or,
+// unless it is simple, a temporary holding it. Other matches become
+// statements hoisted before the expression, whose arms assign a
+// temporary, and where no statement may be hoisted, a function called on
+// the spot, whose arms return their values.
+func (g *generator) matchValue(e *ast.MatchExpr) js {
+	if g.condArms(e) && (g.simpleSubject(e.X) || g.splitsInPlace(e) || g.hoisting()) {
+		if lit := splitSubject(e); lit != nil {
+			return g.matchCond(e, g.splitTuple(lit))
+		}
+		x := g.expr(e.X)
+		if !g.simpleSubject(e.X) {
+			t := g.temp("s")
+			g.line("const %s = %s;", t, x.at(precAssign))
+			x = js{t, precPrimary}
+		}
+		return g.matchCond(e, x)
+	}
+	if g.hoisting() {
+		t := g.temp("m")
+		g.line("let %s;", t)
+		set := func(v string) string { return t + " = " + v }
+		g.matchStmt(e, &sink{set: set, T: g.info.TypeOf(e)})
+		return js{t, precPrimary}
+	}
+	for _, arm := range e.Arms {
+		if pos, ok := escapes(arm.Body); ok {
+			g.errorf(pos, "cannot generate a match expression whose arm leaves the function or loop here")
+		}
+	}
+	return g.iife(func() {
+		g.matchStmt(e, returns(g.info.TypeOf(e)))
+	})
+}
+
+// bindsVars reports whether the pattern p binds variables.
+func bindsVars(p ast.Pattern) bool {
+	found := false
+	ast.Inspect(p, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BindPat:
+			found = found || n.Name.Name != "_"
+		case *ast.FieldPat:
+			found = found || n.Pattern == nil
+		}
+		return !found
+	})
+	return found
+}
+
+// irrefutable reports whether the pattern p matches any value of its
+// type.
+func irrefutable(p ast.Pattern) bool {
+	switch p := p.(type) {
+	case *ast.WildcardPat, *ast.BindPat:
+		return true
+	case *ast.TuplePat:
+		for _, elt := range p.Elts {
+			if !irrefutable(elt) {
+				return false
+			}
+		}
+		return true
+	case *ast.RecordPat:
+		for _, f := range p.Fields {
+			if f.Pattern != nil && !irrefutable(f.Pattern) {
+				return false
+			}
+		}
+		return true
+	case *ast.OrPat:
+		for _, alt := range p.Alts {
+			if irrefutable(alt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// irrefutableRest reports whether the pattern p, which caseLabels accepts,
+// matches any value the cases it selects do.
+func irrefutableRest(p ast.Pattern) bool {
+	if p, ok := p.(*ast.VariantPat); ok {
+		for _, arg := range p.Args {
+			if !irrefutable(arg) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// leaves reports whether the statement s always ends by leaving the
+// enclosing block.
+func leaves(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt:
+		return true
+	case *ast.BlockStmt:
+		return len(s.List) > 0 && leaves(s.List[len(s.List)-1])
+	case *ast.ExprStmt:
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "panic" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// escapes returns the position of a return, break or continue statement
+// in s that leaves s, if there is one.
+func escapes(s ast.Stmt) (lexer.Position, bool) {
+	var pos lexer.Position
+	found := false
+	loops := 0
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			pos, found = n.Pos(), true
+		case *ast.BranchStmt:
+			if loops == 0 {
+				pos, found = n.Pos(), true
+			}
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt:
+			loops++
+			ast.Inspect(n, func(m ast.Node) bool {
+				if m == n {
+					return true
+				}
+				return visit(m)
+			})
+			loops--
+			return false
+		}
+		return !found
+	}
+	ast.Inspect(s, visit)
+	return pos, found
+}
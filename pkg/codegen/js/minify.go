@@ -0,0 +1,274 @@
+package js
+
+import (
+	"bytes"
+	"go/constant"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// reachable returns the package-level objects a minified module declares:
+// those its exported names, its init functions and main refer to, directly
+// or through the declarations of other objects, and those the initializers
+// of variables refer to when these may have effects. Types keep all their
+// methods, which interfaces may call.
+func (g *generator) reachable() map[types.Object]bool {
+	scope := g.pkg.Scope()
+	specs := make(map[types.Object]*ast.ValueSpec)
+	typeSpecs := make(map[types.Object]*ast.TypeSpec)
+	var work []ast.Node
+	keep := make(map[types.Object]bool)
+	var mark func(obj types.Object)
+	mark = func(obj types.Object) {
+		if obj == nil || keep[obj] || scope.Lookup(obj.Name()) != obj {
+			return
+		}
+		keep[obj] = true
+		switch obj := obj.(type) {
+		case *types.Func:
+			if d := g.funcs[obj]; d != nil {
+				work = append(work, d)
+			}
+		case *types.Var:
+			if s := specs[obj]; s != nil {
+				work = append(work, s)
+			}
+		case *types.TypeName:
+			if s := typeSpecs[obj]; s != nil {
+				work = append(work, s)
+			}
+			if t, ok := obj.Type().(*types.Named); ok {
+				for i := 0; i < t.NumMethods(); i++ {
+					if d := g.funcs[t.Method(i)]; d != nil {
+						work = append(work, d)
+					}
+				}
+			}
+		}
+	}
+
+	var roots []types.Object
+	for _, file := range g.files {
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil && d.Name.Name == "init" {
+					work = append(work, d)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if obj := g.info.Defs[s.Name]; obj != nil {
+							typeSpecs[obj] = s
+						}
+					case *ast.ValueSpec:
+						// blank variables are declared for their initializers
+						effects := false
+						for _, name := range s.Names {
+							if name.Name == "_" {
+								effects = true
+							}
+						}
+						for _, v := range s.Values {
+							if !g.pure(v) {
+								effects = true
+							}
+						}
+						if effects {
+							work = append(work, s)
+						}
+						for _, name := range s.Names {
+							if obj := g.info.Defs[name]; obj != nil {
+								specs[obj] = s
+								if effects {
+									roots = append(roots, obj)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, name := range scope.Names() {
+		if ast.IsExported(name) || g.pkg.Name() == "main" && name == "main" {
+			roots = append(roots, scope.Lookup(name))
+		}
+	}
+	for _, obj := range roots {
+		mark(obj)
+	}
+
+	for len(work) > 0 {
+		n := work[len(work)-1]
+		work = work[:len(work)-1]
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				// constants are written where they are used
+				if _, ok := g.info.Uses[n].(*types.Const); !ok {
+					mark(g.info.Uses[n])
+				}
+			case ast.Expr:
+				g.markTypes(g.info.TypeOf(n), mark)
+			}
+			return true
+		})
+	}
+	return keep
+}
+
+// markTypes calls mark for the named types T is made of.
+func (g *generator) markTypes(T types.Type, mark func(types.Object)) {
+	switch t := T.(type) {
+	case *types.Named:
+		mark(t.Origin().Obj())
+		for _, arg := range t.TypeArgs() {
+			g.markTypes(arg, mark)
+		}
+	case *types.Slice:
+		g.markTypes(t.Elem(), mark)
+	case *types.Array:
+		g.markTypes(t.Elem(), mark)
+	case *types.Optional:
+		g.markTypes(t.Elem(), mark)
+	case *types.Map:
+		g.markTypes(t.Key(), mark)
+		g.markTypes(t.Elem(), mark)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			g.markTypes(t.At(i), mark)
+		}
+	case *types.Signature:
+		for _, v := range t.Params() {
+			g.markTypes(v.Type(), mark)
+		}
+		for _, v := range t.Results() {
+			g.markTypes(v.Type(), mark)
+		}
+	}
+}
+
+// pure reports whether evaluating e has no effects, so that a variable it
+// initializes may be left out when nothing refers to it: e calls nothing
+// and cannot panic.
+func (g *generator) pure(e ast.Expr) bool {
+	pure := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false // not called
+		case *ast.CallExpr, *ast.IndexExpr, *ast.JSXElement, *ast.MatchExpr:
+			pure = false
+		case *ast.BinaryExpr:
+			if (n.Op == lexer.DIV || n.Op == lexer.MOD) && !isFloat(g.info.TypeOf(n)) {
+				pure = false
+			}
+		}
+		return pure
+	})
+	return pure
+}
+
+// foldedCond returns the value of the condition cond of a minified module
+// if it is known: cond is constant, or a constant operand of && or || it
+// is made of decides it.
+func (g *generator) foldedCond(cond ast.Expr) (value, ok bool) {
+	if !g.minify {
+		return false, false
+	}
+	if e, isBinary := unparen(cond).(*ast.BinaryExpr); isBinary && (e.Op == lexer.AND || e.Op == lexer.OR) {
+		x, ok := g.foldedCond(e.X)
+		switch {
+		case !ok:
+			return false, false
+		case x == (e.Op == lexer.AND):
+			return g.foldedCond(e.Y)
+		}
+		return x, true
+	}
+	tv := g.info.Types[cond]
+	if tv.Value == nil || tv.Value.Kind() != constant.Bool {
+		return false, false
+	}
+	return constant.BoolVal(tv.Value), true
+}
+
+// compact removes the whitespace and the comments of the module code that
+// do not separate tokens. Marks stay before the tokens they precede. The
+// generator writes neither regular expressions nor template literals with
+// substitutions, which compact would not recognize.
+func compact(code []byte) []byte {
+	var out bytes.Buffer
+	var marks []byte // the marks preceding the next token
+	var last byte    // the last byte of the last token written
+	space := false   // whether whitespace precedes the next token
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := bytes.Index(code[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(code)
+			}
+			i += end + 3
+			space = true
+			continue
+		case c == markStart:
+			end := i + bytes.IndexByte(code[i:], markEnd)
+			marks = append(marks, code[i:end+1]...)
+			i = end
+			continue
+		}
+		if space && separated(last, c) {
+			out.WriteByte(' ')
+		}
+		space = false
+		out.Write(marks)
+		marks = marks[:0]
+
+		end := i
+		if c == '"' || c == '\'' || c == '`' {
+			for end++; end < len(code) && code[end] != c; end++ {
+				if code[end] == '\\' {
+					end++
+				}
+			}
+		}
+		out.Write(code[i : end+1])
+		last = code[end]
+		i = end
+	}
+	out.Write(marks)
+	return out.Bytes()
+}
+
+// separated reports whether tokens ending with a and starting with b must
+// be separated by a space.
+func separated(a, b byte) bool {
+	switch {
+	case isWordByte(a) && isWordByte(b):
+		return true
+	case (a == '+' || a == '-') && a == b:
+		return true // a - -b
+	case a >= '0' && a <= '9' && b == '.':
+		return true // 1 .toString()
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,149 @@
+package js
+
+import (
+	"strconv"
+
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// reserved holds the words that may not name JavaScript bindings in a
+// module, and the globals that generated code refers to, which Gusset
+// names must not shadow.
+var reserved = map[string]bool{
+	"arguments": true, "await": true, "break": true, "case": true, "catch": true,
+	"class": true, "const": true, "continue": true, "debugger": true, "default": true,
+	"delete": true, "do": true, "else": true, "enum": true, "eval": true,
+	"export": true, "extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "implements": true, "import": true, "in": true,
+	"instanceof": true, "interface": true, "let": true, "new": true, "null": true,
+	"package": true, "private": true, "protected": true, "public": true, "return": true,
+	"static": true, "super": true, "switch": true, "this": true, "throw": true,
+	"true": true, "try": true, "typeof": true, "undefined": true, "var": true,
+	"void": true, "while": true, "with": true, "yield": true,
+
+	"Array": true, "BigInt": true, "Error": true, "Infinity": true, "JSON": true,
+	"Map": true, "Math": true, "NaN": true, "Number": true, "Object": true,
+	"String": true, "Symbol": true, "console": true, "globalThis": true,
+}
+
+// reservedProps holds the property names that have a meaning of their own
+// in classes and objects.
+var reservedProps = map[string]bool{
+	"constructor": true,
+	"__proto__":   true,
+	"prototype":   true,
+}
+
+// Mangle returns the JavaScript name of a Gusset identifier. Identifiers
+// that are reserved in JavaScript get a "$" appended, which Gusset
+// identifiers cannot contain, so the result is stable and never collides
+// with another Gusset name. The names of the runtime helpers start with
+// "$".
+func Mangle(name string) string {
+	if reserved[name] {
+		return name + "$"
+	}
+	return name
+}
+
+// propName returns the JavaScript property name of a field or method.
+func propName(name string) string {
+	if reservedProps[name] {
+		return name + "$"
+	}
+	return name
+}
+
+// scope assigns the names of the local variables of a top-level
+// declaration. Each variable gets its own name, so that no declaration
+// shadows another one it may need to read, as in x := x + 1.
+type scope struct {
+	names map[types.Object]string
+	used  map[string]bool
+	// short makes the scope name locals and temporaries a, b and so on,
+	// for minified modules. next counts the short names tried.
+	short bool
+	next  int
+}
+
+func newScope(global map[string]bool) *scope {
+	used := make(map[string]bool, len(global))
+	for name := range global {
+		used[name] = true
+	}
+	return &scope{names: make(map[types.Object]string), used: used}
+}
+
+// newScope returns the scope of the locals of a top-level declaration.
+func (g *generator) newScope() *scope {
+	s := newScope(g.globals)
+	s.short = g.minify
+	return s
+}
+
+// declare assigns a name to the local obj.
+func (s *scope) declare(obj types.Object) string {
+	if name, ok := s.names[obj]; ok {
+		return name
+	}
+	if s.short {
+		name := s.shortName()
+		s.names[obj] = name
+		return name
+	}
+	base := Mangle(obj.Name())
+	if base == "_" {
+		base = "$_"
+	}
+	name := base
+	for i := 1; s.used[name]; i++ {
+		name = base + "$" + strconv.Itoa(i)
+	}
+	s.used[name] = true
+	s.names[obj] = name
+	return name
+}
+
+// temp returns a fresh name for a temporary such as the value of a match
+// subject.
+func (s *scope) temp(base string) string {
+	if s.short {
+		return s.shortName()
+	}
+	name := "$" + base
+	for i := 1; s.used[name]; i++ {
+		name = "$" + base + strconv.Itoa(i)
+	}
+	s.used[name] = true
+	return name
+}
+
+// fork returns a scope for declarations nested in those of s, whose names
+// do not shadow the names s has assigned.
+func (s *scope) fork() *scope {
+	used := make(map[string]bool, len(s.used))
+	for name := range s.used {
+		used[name] = true
+	}
+	return &scope{names: make(map[types.Object]string), used: used, short: s.short, next: s.next}
+}
+
+// shortName returns the shortest name not used yet: a to z, A to Z, then
+// these followed by letters and digits.
+func (s *scope) shortName() string {
+	const first = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	const rest = first + "0123456789"
+	for {
+		n := s.next
+		s.next++
+		name := string(first[n%len(first)])
+		for n /= len(first); n > 0; n /= len(rest) {
+			n--
+			name += string(rest[n%len(rest)])
+		}
+		if !s.used[name] && !reserved[name] {
+			s.used[name] = true
+			return name
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package js
+
+// runtimeHelpers holds the sources of the runtime helpers, which modules
+// declare when they use them.
+var runtimeHelpers = map[string]string{
+	"$panic": `function $panic(value) {
+  const err = new Error("panic: " + String(value));
+  err.value = value;
+  throw err;
+}
+`,
+	"$index": `function $index(a, i) {
+  if (i < 0 || i >= a.length) {
+    $panic("index out of range [" + i + "] with length " + a.length);
+  }
+  return Number(i);
+}
+`,
+	"$at": `function $at(a, i) {
+  return a[$index(a, i)];
+}
+`,
+	"$div": `function $div(x, y) {
+  if (y === 0) {
+    $panic("integer divide by zero");
+  }
+  return (x / y) | 0;
+}
+`,
+	"$rem": `function $rem(x, y) {
+  if (y === 0) {
+    $panic("integer divide by zero");
+  }
+  return (x % y) | 0;
+}
+`,
+	"$div64": `function $div64(x, y) {
+  if (y === 0n) {
+    $panic("integer divide by zero");
+  }
+  return BigInt.asIntN(64, x / y);
+}
+`,
+	"$rem64": `function $rem64(x, y) {
+  if (y === 0n) {
+    $panic("integer divide by zero");
+  }
+  return x % y;
+}
+`,
+	"$shl": `function $shl(x, n) {
+  return n < 32 ? x << n : 0;
+}
+`,
+	"$shr": `function $shr(x, n) {
+  return x >> (n < 32 ? n : 31);
+}
+`,
+	"$equal": `function $equal(x, y) {
+  if (x === y) {
+    return true;
+  }
+  if (x === null || y === null || typeof x !== "object" || typeof y !== "object") {
+    return false;
+  }
+  if (Array.isArray(x) !== Array.isArray(y)) {
+    return false;
+  }
+  const keys = Object.keys(x);
+  if (keys.length !== Object.keys(y).length) {
+    return false;
+  }
+  for (const k of keys) {
+    if (!$equal(x[k], y[k])) {
+      return false;
+    }
+  }
+  return true;
+}
+`,
+	"$hash": `function $hash(v) {
+  switch (typeof v) {
+    case "string":
+      return JSON.stringify(v);
+    case "object":
+      if (v === null) {
+        return "null";
+      }
+      return (Array.isArray(v) ? "[" : "{") + Object.keys(v).map((k) => $hash(v[k])).join(",") + "}";
+  }
+  return typeof v + ":" + String(v);
+}
+`,
+	"$Map": `class $Map {
+  constructor(entries) {
+    this.entries = new Map();
+    if (entries) {
+      for (const [k, v] of entries) {
+        this.set(k, v);
+      }
+    }
+  }
+
+  get size() {
+    return this.entries.size;
+  }
+
+  has(k) {
+    return this.entries.has($hash(k));
+  }
+
+  get(k) {
+    const e = this.entries.get($hash(k));
+    return e === undefined ? undefined : e[1];
+  }
+
+  set(k, v) {
+    this.entries.set($hash(k), [k, v]);
+    return this;
+  }
+
+  delete(k) {
+    return this.entries.delete($hash(k));
+  }
+
+  *keys() {
+    for (const e of this.entries.values()) {
+      yield e[0];
+    }
+  }
+
+  *[Symbol.iterator]() {
+    for (const e of this.entries.values()) {
+      yield [e[0], e[1]];
+    }
+  }
+}
+`,
+	"$with": `function $with(r, fields) {
+  return Object.freeze(Object.assign(Object.create(Object.getPrototypeOf(r)), r, fields));
+}
+`,
+	"$convert": `function $convert(x, T) {
+  const y = Object.assign(Object.create(T.prototype), x);
+  return Object.isFrozen(x) ? Object.freeze(y) : y;
+}
+`,
+	"$decodeJSON": `function $decodeJSON(s, t) {
+  const decode = (v, t, path) => {
+    const fail = () => {
+      const kind = v === null ? "null" : Array.isArray(v) ? "array" : typeof v;
+      $panic("json: unexpected " + kind + " at " + path);
+    };
+    if (typeof t === "function") {
+      t = t();
+    }
+    switch (t) {
+      case "any":
+        return v;
+      case "bool":
+        return typeof v === "boolean" ? v : fail();
+      case "string":
+        return typeof v === "string" ? v : fail();
+      case "float":
+        return typeof v === "number" ? v : fail();
+      case "int":
+        return Number.isInteger(v) && (v | 0) === v ? v : fail();
+      case "int64":
+        return Number.isSafeInteger(v) ? BigInt(v) : fail();
+    }
+    if ("symbols" in t) {
+      if (typeof v === "string" && !t.symbols.includes(v)) {
+        $panic("json: unexpected symbol :" + v + " at " + path);
+      }
+      return typeof v === "string" ? v : fail();
+    }
+    if ("optional" in t) {
+      return v === null ? null : decode(v, t.optional, path);
+    }
+    if ("elem" in t) {
+      if (!Array.isArray(v) || t.len !== undefined && v.length !== t.len) {
+        fail();
+      }
+      return v.map((x, i) => decode(x, t.elem, path + "[" + i + "]"));
+    }
+    if ("elems" in t) {
+      if (!Array.isArray(v) || v.length !== t.elems.length) {
+        fail();
+      }
+      return t.elems.map((u, i) => decode(v[i], u, path + "[" + i + "]"));
+    }
+    if (v === null || typeof v !== "object" || Array.isArray(v)) {
+      fail();
+    }
+    if ("map" in t) {
+      return new Map(Object.keys(v).map((k) => [k, decode(v[k], t.map, path + "[" + JSON.stringify(k) + "]")]));
+    }
+    const x = t.class ? Object.create(t.class.prototype) : {};
+    for (const [key, u, prop = key, zero] of t.fields) {
+      if (Object.prototype.hasOwnProperty.call(v, key)) {
+        x[prop] = decode(v[key], u, path + "." + key);
+      } else if (zero !== undefined) {
+        x[prop] = typeof zero === "function" ? zero() : zero;
+      } else {
+        $panic("json: missing field " + path + "." + key);
+      }
+    }
+    return t.frozen ? Object.freeze(x) : x;
+  };
+  let v;
+  try {
+    v = JSON.parse(s);
+  } catch (err) {
+    $panic("json: " + err.message);
+  }
+  return decode(v, t, "$");
+}
+`,
+	"$encodeJSON": `function $encodeJSON(v, t) {
+  const encode = (v, t, path) => {
+    if (typeof t === "function") {
+      t = t();
+    }
+    switch (t) {
+      case "float":
+        if (!Number.isFinite(v)) {
+          $panic("json: unsupported value " + v + " at " + path);
+        }
+        return v;
+      case "int64":
+        if (v < -9007199254740991n || v > 9007199254740991n) {
+          $panic("json: unsupported value " + v + " at " + path);
+        }
+        return Number(v);
+    }
+    if (typeof t === "string" || "symbols" in t) {
+      return v;
+    }
+    if ("optional" in t) {
+      return v === null ? null : encode(v, t.optional, path);
+    }
+    if ("elem" in t) {
+      return v.map((x, i) => encode(x, t.elem, path + "[" + i + "]"));
+    }
+    if ("elems" in t) {
+      return t.elems.map((u, i) => encode(v[i], u, path + "[" + i + "]"));
+    }
+    if ("map" in t) {
+      return Object.fromEntries(Array.from(v, ([k, x]) => [k, encode(x, t.map, path + "[" + JSON.stringify(k) + "]")]));
+    }
+    return Object.fromEntries(t.fields.map(([key, u, prop = key]) => [key, encode(v[prop], u, path + "." + key)]));
+  };
+  return JSON.stringify(encode(v, t, "$"));
+}
+`,
+	"$bind": `function $bind(recv, name) {
+  return (...args) => recv[name](...args);
+}
+`,
+	"$chars": `function* $chars(s) {
+  let i = 0;
+  for (const c of s) {
+    yield [i, c];
+    i += c.length;
+  }
+}
+`,
+}
+
+// runtimeOrder is the order in which modules declare the runtime helpers.
+var runtimeOrder = []string{
+	"$panic", "$index", "$at", "$div", "$rem", "$div64", "$rem64", "$shl", "$shr",
+	"$equal", "$hash", "$Map", "$with", "$convert", "$decodeJSON", "$encodeJSON",
+	"$bind", "$chars",
+}
+
+// runtimeDeps holds the helpers each runtime helper uses.
+var runtimeDeps = map[string][]string{
+	"$index": {"$panic"},
+	"$at":    {"$index"},
+	"$div":   {"$panic"},
+	"$rem":   {"$panic"},
+	"$div64": {"$panic"},
+	"$rem64": {"$panic"},
+	"$Map":   {"$hash"},
+
+	"$decodeJSON": {"$panic"},
+	"$encodeJSON": {"$panic"},
+}
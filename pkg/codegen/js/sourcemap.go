@@ -0,0 +1,199 @@
+package js
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// SourceMap is a source map, version 3, mapping the statements and
+// expressions of a module to the positions in the Gusset sources the
+// lexer recorded for them. Mappings of identifiers that the module renames
+// name them.
+type SourceMap struct {
+	Version  int      `json:"version"`
+	File     string   `json:"file,omitempty"`
+	Sources  []string `json:"sources"` // the names of the parsed files
+	Names    []string `json:"names"`
+	Mappings string   `json:"mappings"`
+}
+
+// JSON returns the encoding of the source map.
+func (m *SourceMap) JSON() []byte {
+	data, _ := json.Marshal(m) // cannot fail
+	return data
+}
+
+// DataURL returns the source map as a data URL, for embedding it in its
+// module.
+func (m *SourceMap) DataURL() string {
+	return "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(m.JSON())
+}
+
+// SourceMappingURL returns the comment that ends a module whose source map is at
+// url.
+func SourceMappingURL(url string) string {
+	return "//# sourceMappingURL=" + url + "\n"
+}
+
+// A mark is a position in the sources that generated code maps to, and
+// the name of the identifier there if the code renames it.
+type mark struct {
+	file int
+	pos  lexer.Position
+	name string
+}
+
+// Generated code refers to marks by their index between markStart and
+// markEnd, which never occur in JavaScript the generator writes: string
+// literals escape control characters.
+const (
+	markStart = '\x00'
+	markEnd   = '\x01'
+)
+
+// mark returns the reference to a mark for the position pos of the file
+// being generated, with the name of the identifier there if it is not "",
+// or "" if the module has no source map.
+func (g *generator) mark(pos lexer.Position, name string) string {
+	if !g.maps {
+		return ""
+	}
+	file := 0
+	for i, f := range g.files {
+		if f == g.file {
+			file = i
+		}
+	}
+	g.marks = append(g.marks, mark{file, pos, name})
+	return string(rune(markStart)) + strconv.Itoa(len(g.marks)-1) + string(rune(markEnd))
+}
+
+// named returns the JavaScript name of a declaration of obj, preceded by a
+// mark for the declaration.
+func (g *generator) named(obj types.Object, name string) string {
+	orig := ""
+	if name != obj.Name() {
+		orig = obj.Name()
+	}
+	return g.mark(obj.Pos(), orig) + name
+}
+
+// plain returns code without its marks, for inspecting it.
+func plain(code string) string {
+	if strings.IndexByte(code, markStart) < 0 {
+		return code
+	}
+	var b strings.Builder
+	for i := 0; i < len(code); i++ {
+		if code[i] == markStart {
+			i += strings.IndexByte(code[i:], markEnd)
+			continue
+		}
+		b.WriteByte(code[i])
+	}
+	return b.String()
+}
+
+// sourceMap removes the marks from the module code and returns the module
+// and its source map. Where marks coincide, the last one, which is the
+// innermost expression, is mapped.
+func (g *generator) sourceMap(code []byte) ([]byte, *SourceMap) {
+	m := &SourceMap{Version: 3, Sources: make([]string, len(g.files)), Names: []string{}}
+	for i, f := range g.files {
+		m.Sources[i] = f.Filename
+	}
+	names := make(map[string]int)
+
+	var out bytes.Buffer
+	var mappings strings.Builder
+	var prev struct{ col, file, line, srcCol, name int }
+	col := 0          // the column of the generated code, in UTF-16 units
+	lineStart := true // whether the line has no segment yet
+	var pending *mark
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		if !lineStart {
+			mappings.WriteByte(',')
+		}
+		lineStart = false
+		vlq(&mappings, col-prev.col)
+		vlq(&mappings, pending.file-prev.file)
+		vlq(&mappings, pending.pos.Line-1-prev.line)
+		vlq(&mappings, pending.pos.Col-prev.srcCol)
+		prev.col, prev.file, prev.line, prev.srcCol = col, pending.file, pending.pos.Line-1, pending.pos.Col
+		if pending.name != "" {
+			index, ok := names[pending.name]
+			if !ok {
+				index = len(m.Names)
+				names[pending.name] = index
+				m.Names = append(m.Names, pending.name)
+			}
+			vlq(&mappings, index-prev.name)
+			prev.name = index
+		}
+		pending = nil
+	}
+
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == markStart:
+			end := bytes.IndexByte(code[i:], markEnd)
+			index, _ := strconv.Atoi(string(code[i+1 : i+end]))
+			pending = &g.marks[index]
+			i += end + 1
+			continue
+		case c == '\n':
+			pending = nil
+			mappings.WriteByte(';')
+			col, prev.col, lineStart = 0, 0, true
+			out.WriteByte(c)
+			i++
+			continue
+		}
+		flush()
+		r, size := utf8.DecodeRune(code[i:])
+		out.Write(code[i : i+size])
+		col += utf16Len(r)
+		i += size
+	}
+	m.Mappings = strings.TrimRight(mappings.String(), ";")
+	return out.Bytes(), m
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// vlq writes n as a base 64 variable-length quantity.
+func vlq(b *strings.Builder, n int) {
+	v := n << 1
+	if n < 0 {
+		v = -n<<1 | 1
+	}
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64Digits[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,1047 @@
+package js
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+func (g *generator) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		g.stmt(s)
+	}
+}
+
+// body generates the body of a switch case or match arm: a block or a
+// simple statement.
+func (g *generator) body(s ast.Stmt) {
+	if b, ok := s.(*ast.BlockStmt); ok {
+		g.stmtList(b.List)
+		return
+	}
+	g.stmt(s)
+}
+
+func (g *generator) stmt(s ast.Stmt) {
+	g.pending = g.mark(s.Pos(), "")
+	switch s := s.(type) {
+	case *ast.BadStmt, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.DeclStmt:
+		g.declStmt(s.Decl.(*ast.GenDecl))
+
+	case *ast.ExprStmt:
+		if m, ok := s.X.(*ast.MatchExpr); ok {
+			g.matchStmt(m, nil)
+			return
+		}
+		g.exprStmt(g.expr(s.X))
+
+	case *ast.AssignStmt:
+		g.assign(s)
+
+	case *ast.IncDecStmt:
+		op := lexer.ADD
+		if s.Tok == lexer.ASSIGN_DEC {
+			op = lexer.SUB
+		}
+		T := g.info.TypeOf(s.X)
+		one := js{"1", precPrimary}
+		if isInt64(T) {
+			one.code = "1n"
+		}
+		g.opAssign(s.X, op, one, T)
+
+	case *ast.ReturnStmt:
+		g.returnStmt(s)
+
+	case *ast.BranchStmt:
+		if s.Tok == lexer.CONTINUE {
+			g.line("continue;")
+			return
+		}
+		if n := len(g.fn.targets); n > 0 && g.fn.targets[n-1] != "" {
+			g.line("break %s;", g.fn.targets[n-1])
+			return
+		}
+		g.line("break;")
+
+	case *ast.BlockStmt:
+		g.open("{")
+		g.stmtList(s.List)
+		g.close("}")
+
+	case *ast.IfStmt:
+		g.ifStmt(s)
+
+	case *ast.SwitchStmt:
+		g.switchStmt(s)
+
+	case *ast.ForStmt:
+		g.forStmt(s)
+
+	case *ast.RangeStmt:
+		g.rangeStmt(s)
+
+	default:
+		g.errorf(s.Pos(), "cannot generate statement %T", s)
+	}
+}
+
+// exprStmt writes an expression statement, parenthesized if JavaScript
+// would otherwise take it for a declaration or block.
+func (g *generator) exprStmt(x js) {
+	code := x.code
+	if p := plain(code); strings.HasPrefix(p, "{") || startsWithWord(p, "function") || startsWithWord(p, "class") || startsWithWord(p, "let") {
+		code = "(" + code + ")"
+	}
+	g.line("%s;", code)
+}
+
+// startsWithWord reports whether code starts with the word w.
+func startsWithWord(code, w string) bool {
+	if !strings.HasPrefix(code, w) || len(code) == len(w) {
+		return strings.HasPrefix(code, w)
+	}
+	c := code[len(w)]
+	return !(c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80)
+}
+
+func (g *generator) declStmt(d *ast.GenDecl) {
+	switch d.Tok {
+	case lexer.VAR:
+		for _, spec := range d.Specs {
+			s := spec.(*ast.ValueSpec)
+			names := make([]string, len(s.Names))
+			vars := make([]*types.Var, len(s.Names))
+			keyword := "const"
+			for i, name := range s.Names {
+				v, _ := g.info.Defs[name].(*types.Var)
+				vars[i] = v
+				if v == nil || name.Name == "_" {
+					continue
+				}
+				names[i] = g.named(v, g.locals.declare(v))
+				if g.assigned[v] || len(s.Values) == 0 {
+					keyword = "let"
+				}
+			}
+			g.varDecl(keyword, names, vars, s.Values)
+		}
+	case lexer.TYPE:
+		for _, spec := range d.Specs {
+			g.typeDecl(spec.(*ast.TypeSpec))
+		}
+	}
+	// constants are inlined
+}
+
+// varDecl declares the variables names, of which blank ones are "", with
+// their values or zero values.
+func (g *generator) varDecl(keyword string, names []string, vars []*types.Var, values []ast.Expr) {
+	switch {
+	case len(values) == 0:
+		var decls []string
+		for i, name := range names {
+			if name == "" {
+				continue
+			}
+			if zero := g.zero(vars[i].Type()); zero != "undefined" {
+				name += " = " + zero
+			}
+			decls = append(decls, name)
+		}
+		if len(decls) > 0 {
+			g.line("let %s;", strings.Join(decls, ", "))
+		}
+
+	case len(names) == 1 && names[0] != "" && g.stmtMatch(values[0]) != nil:
+		// the arms of the match assign the variable
+		g.line("let %s;", names[0])
+		name := plain(names[0])
+		set := func(v string) string { return name + " = " + v }
+		g.matchStmt(g.stmtMatch(values[0]), &sink{set: set, T: vars[0].Type()})
+
+	case len(values) == len(names):
+		vals := g.operands(len(values), func(i int) (js, ast.Expr) {
+			var T types.Type
+			if vars[i] != nil {
+				T = vars[i].Type()
+			}
+			return g.value(values[i], T), values[i]
+		})
+		var decls []string
+		for i, name := range names {
+			v := vals[i]
+			if name == "" {
+				if len(decls) > 0 {
+					g.line("%s %s;", keyword, strings.Join(decls, ", "))
+					decls = nil
+				}
+				g.exprStmt(v)
+				continue
+			}
+			decls = append(decls, name+" = "+v.at(precAssign))
+		}
+		if len(decls) > 0 {
+			g.line("%s %s;", keyword, strings.Join(decls, ", "))
+		}
+
+	default:
+		// the elements of a tuple
+		x := g.expr(values[0])
+		if allBlank(names) {
+			g.exprStmt(x)
+			return
+		}
+		g.line("%s [%s] = %s;", keyword, strings.Join(names, ", "), x.at(precAssign))
+	}
+}
+
+func allBlank(names []string) bool {
+	for _, name := range names {
+		if name != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// Assignments
+
+// lvalue is an assignable expression.
+type lvalue struct {
+	get    js                    // reads the value
+	set    func(v string) string // assigns v, as an expression
+	target string                // the destructuring target, or "" if there is none
+	typ    types.Type            // the type of the value, or nil for _
+}
+
+// lvalue returns the assignable expression e. Operands that would
+// otherwise be evaluated twice are first stored in temporaries.
+func (g *generator) lvalue(e ast.Expr) lvalue {
+	T := g.info.TypeOf(e)
+	if ident, ok := unparen(e).(*ast.Ident); ok && ident.Name == "_" {
+		return lvalue{set: func(v string) string { return v }}
+	}
+	switch x := unparen(e).(type) {
+	case *ast.IndexExpr:
+		base := g.simple(x.X)
+		switch u := types.CoreType(g.info.TypeOf(x.X)).(type) {
+		case *types.Map:
+			key := g.simple(x.Indices[0])
+			key = g.box(key, g.info.TypeOf(x.Indices[0]), u.Key())
+			get := js{base.at(precCall) + ".get(" + key.code + ")", precCall}
+			if zero := g.zero(u.Elem()); zero != "undefined" {
+				get = js{get.code + " ?? " + zero, precNullish}
+			}
+			return lvalue{
+				get: get,
+				set: func(v string) string {
+					return base.at(precCall) + ".set(" + key.code + ", " + v + ")"
+				},
+				typ: T,
+			}
+		}
+		index := g.simple(x.Indices[0])
+		target := base.at(precCall) + "[" + g.use("$index") + "(" + base.code + ", " + index.code + ")]"
+		return lvalue{
+			get:    js{target, precCall},
+			set:    func(v string) string { return target + " = " + v },
+			target: target,
+			typ:    T,
+		}
+	case *ast.SelectorExpr:
+		if s := g.info.Selections[x]; s != nil && s.Kind() == types.FieldVal {
+			base, BT := g.lvalueBase(x.X)
+			target, _ := g.fieldPath(base, BT, s.Index())
+			return lvalue{
+				get:    target,
+				set:    func(v string) string { return target.code + " = " + v },
+				target: target.code,
+				typ:    T,
+			}
+		}
+	case *ast.TupleIndexExpr:
+		base, _ := g.lvalueBase(x.X)
+		target := base.at(precCall) + "[" + strconv.Itoa(x.Index) + "]"
+		return lvalue{
+			get:    js{target, precCall},
+			set:    func(v string) string { return target + " = " + v },
+			target: target,
+			typ:    T,
+		}
+	}
+	x := g.expr(e)
+	return lvalue{
+		get:    x,
+		set:    func(v string) string { return x.code + " = " + v },
+		target: x.code,
+		typ:    T,
+	}
+}
+
+// lvalueBase returns the value holding a field or element that is
+// assigned, which is itself assignable when it is a struct or an array.
+func (g *generator) lvalueBase(e ast.Expr) (js, types.Type) {
+	T := g.info.TypeOf(e)
+	if isValueType(T) {
+		return g.lvalue(e).get, T
+	}
+	return g.simple(e), T
+}
+
+// simple returns e if it is a name, a constant or a field of a name, and
+// otherwise a temporary holding its value.
+func (g *generator) simple(e ast.Expr) js {
+	x := g.expr(e)
+	if g.isSimple(e) {
+		return x
+	}
+	t := g.locals.temp("t")
+	g.line("const %s = %s;", t, x.at(precAssign))
+	return js{t, precPrimary}
+}
+
+func (g *generator) isSimple(e ast.Expr) bool {
+	if tv, ok := g.info.Types[e]; ok && tv.Value != nil {
+		return true
+	}
+	switch x := unparen(e).(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		if s := g.info.Selections[x]; s != nil && s.Kind() == types.FieldVal {
+			return g.isSimple(x.X)
+		}
+		return g.info.Selections[x] == nil // an enum variant
+	}
+	return false
+}
+
+func (g *generator) assign(s *ast.AssignStmt) {
+	switch s.Tok {
+	case lexer.SHORT_VAR:
+		g.shortVarDecl(s)
+		return
+	case lexer.ASSIGN:
+	default:
+		op := assignOps[s.Tok]
+		g.opAssign(s.Lhs[0], op, g.expr(s.Rhs[0]), g.info.TypeOf(s.Lhs[0]))
+		return
+	}
+
+	if len(s.Lhs) == 1 {
+		if call := g.appendTo(s.Lhs[0], s.Rhs[0]); call != nil {
+			g.push(call)
+			return
+		}
+		lhs := g.lvalue(s.Lhs[0])
+		if m := g.stmtMatch(s.Rhs[0]); m != nil {
+			g.matchStmt(m, &sink{set: lhs.set, T: lhs.typ})
+			return
+		}
+		g.exprStmt(js{lhs.set(g.value(s.Rhs[0], lhs.typ).at(precAssign)), precAssign})
+		return
+	}
+
+	lhs := make([]lvalue, len(s.Lhs))
+	for i, e := range s.Lhs {
+		lhs[i] = g.lvalue(e)
+	}
+	var rhs js
+	if len(s.Rhs) == 1 {
+		rhs = g.expr(s.Rhs[0])
+	} else {
+		vals := g.operands(len(s.Rhs), func(i int) (js, ast.Expr) {
+			return g.value(s.Rhs[i], lhs[i].typ), s.Rhs[i]
+		})
+		rhs = js{"[" + strings.Join(codes(vals), ", ") + "]", precPrimary}
+	}
+	g.destructure(lhs, rhs)
+}
+
+// appendTo returns the call of append that rhs is if it appends to the
+// local variable lhs, which owns its array, as in xs = append(xs, x), and
+// nil otherwise.
+func (g *generator) appendTo(lhs, rhs ast.Expr) *ast.CallExpr {
+	call, ok := unparen(rhs).(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 || g.builtinName(call) != "append" {
+		return nil
+	}
+	x, ok := unparen(lhs).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	y, ok := unparen(call.Args[0]).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	v, ok := g.info.Uses[x].(*types.Var)
+	if !ok || !g.owned[v] || g.info.Uses[y] != v {
+		return nil
+	}
+	return call
+}
+
+// builtinName returns the name of the builtin function e calls, or "".
+func (g *generator) builtinName(e *ast.CallExpr) string {
+	ident, ok := unparen(e.Fun).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if b, ok := g.info.Uses[ident].(*types.Builtin); ok {
+		return b.Name()
+	}
+	return ""
+}
+
+// ownedSlices returns the local variables of slice type declared in body
+// whose arrays no other value may share, onto which appending may push.
+// Such a variable is only assigned new arrays or appended to, and is only
+// read to index, measure, range over, compare, print or append it, or as
+// a result of the function declaring it, which then ends. Uses in other
+// function literals, which may run at any time, disqualify it.
+func (g *generator) ownedSlices(body ast.Node) map[*types.Var]bool {
+	candidates := make(map[*types.Var]bool)
+	bad := make(map[*types.Var]bool)
+	safe := make(map[*ast.Ident]bool)
+	fn := make(map[types.Object]ast.Node) // the function declaring a variable
+	var uses []*ast.Ident
+	useFn := make(map[*ast.Ident]ast.Node)
+
+	local := func(e ast.Expr) *types.Var {
+		ident, ok := unparen(e).(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		v, _ := g.info.ObjectOf(ident).(*types.Var)
+		if v == nil {
+			return nil
+		}
+		if _, ok := types.CoreType(v.Type()).(*types.Slice); !ok {
+			return nil
+		}
+		return v
+	}
+	markSafe := func(es ...ast.Expr) {
+		for _, e := range es {
+			if ident, ok := unparen(e).(*ast.Ident); ok {
+				safe[ident] = true
+			}
+		}
+	}
+	assign := func(lhs, rhs []ast.Expr, define bool) {
+		for i, e := range lhs {
+			v := local(e)
+			if v == nil {
+				continue
+			}
+			markSafe(e)
+			switch {
+			case len(lhs) != len(rhs) || !g.fresh(rhs[i]) && !g.selfAppend(v, rhs[i]):
+				bad[v] = true
+			case define && g.info.Defs[e.(*ast.Ident)] == v:
+				candidates[v] = true
+			}
+		}
+	}
+
+	var visit func(n ast.Node, f ast.Node)
+	visit = func(n ast.Node, f ast.Node) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if n.Body != nil {
+					visit(n.Body, n)
+				}
+				if n.Result != nil {
+					visit(n.Result, n)
+				}
+				return false
+			case *ast.Ident:
+				if v, ok := g.info.Defs[n].(*types.Var); ok {
+					fn[v] = f
+				} else if _, ok := g.info.Uses[n].(*types.Var); ok {
+					uses = append(uses, n)
+					useFn[n] = f
+				}
+			case *ast.AssignStmt:
+				if n.Tok == lexer.ASSIGN || n.Tok == lexer.SHORT_VAR {
+					assign(n.Lhs, n.Rhs, n.Tok == lexer.SHORT_VAR)
+				}
+			case *ast.ValueSpec:
+				if len(n.Values) == 0 {
+					for _, name := range n.Names {
+						if v := local(name); v != nil {
+							candidates[v] = true
+						}
+					}
+				} else {
+					names := make([]ast.Expr, len(n.Names))
+					for i, name := range n.Names {
+						names[i] = name
+					}
+					assign(names, n.Values, true)
+				}
+			case *ast.CallExpr:
+				switch g.builtinName(n) {
+				case "append", "len":
+					markSafe(n.Args[0])
+				case "print":
+					markSafe(n.Args...)
+				}
+			case *ast.IndexExpr:
+				markSafe(n.X)
+			case *ast.RangeStmt:
+				markSafe(n.X)
+			case *ast.CompClause:
+				markSafe(n.X)
+			case *ast.BinaryExpr:
+				if n.Op == lexer.EQ || n.Op == lexer.NEQ {
+					markSafe(n.X, n.Y)
+				}
+			case *ast.ReturnStmt:
+				markSafe(n.Results...)
+			}
+			return true
+		})
+	}
+	visit(body, nil)
+
+	for _, ident := range uses {
+		v := g.info.Uses[ident].(*types.Var)
+		if !safe[ident] || useFn[ident] != fn[v] {
+			bad[v] = true
+		}
+	}
+	owned := make(map[*types.Var]bool)
+	for v := range candidates {
+		if !bad[v] {
+			owned[v] = true
+		}
+	}
+	return owned
+}
+
+// selfAppend reports whether e appends to v.
+func (g *generator) selfAppend(v *types.Var, e ast.Expr) bool {
+	call, ok := unparen(e).(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 || g.builtinName(call) != "append" {
+		return false
+	}
+	ident, ok := unparen(call.Args[0]).(*ast.Ident)
+	return ok && g.info.Uses[ident] == v
+}
+
+// fresh reports whether e evaluates to an array no other value shares.
+func (g *generator) fresh(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.CompositeLit, *ast.ListComp, *ast.JSONLit:
+		return true
+	case *ast.BasicLit:
+		return e.Kind == lexer.NIL
+	case *ast.CallExpr:
+		// appends that do not push copy the array
+		name := g.builtinName(e)
+		return name == "append" || name == "json_decode"
+	}
+	return false
+}
+
+// push generates the call of append e, whose result is assigned back to
+// its first argument, as a push onto the array of the argument, instead
+// of copying the array each time.
+func (g *generator) push(e *ast.CallExpr) {
+	var elem types.Type
+	if sl, ok := types.CoreType(g.info.TypeOf(e)).(*types.Slice); ok {
+		elem = sl.Elem()
+	}
+	xs := g.operands(len(e.Args), func(i int) (js, ast.Expr) {
+		if i == 0 {
+			return g.expr(e.Args[0]), e.Args[0]
+		}
+		return g.value(e.Args[i], elem), e.Args[i]
+	})
+	g.exprStmt(js{xs[0].at(precCall) + ".push(" + strings.Join(codes(xs[1:]), ", ") + ")", precCall})
+}
+
+// destructure assigns the elements of the array rhs to lhs.
+func (g *generator) destructure(lhs []lvalue, rhs js) {
+	targets := make([]string, len(lhs))
+	direct := true
+	for i, l := range lhs {
+		targets[i] = l.target
+		if l.target == "" && l.typ != nil {
+			direct = false
+		}
+	}
+	if direct {
+		g.line("[%s] = %s;", strings.Join(targets, ", "), rhs.at(precAssign))
+		return
+	}
+	t := g.locals.temp("t")
+	g.line("const %s = %s;", t, rhs.at(precAssign))
+	for i, l := range lhs {
+		if l.typ != nil {
+			g.exprStmt(js{l.set(t + "[" + strconv.Itoa(i) + "]"), precAssign})
+		}
+	}
+}
+
+// shortVarDecl generates a short variable declaration, which may also
+// assign variables declared before.
+func (g *generator) shortVarDecl(s *ast.AssignStmt) {
+	names := make([]string, len(s.Lhs))
+	vars := make([]*types.Var, len(s.Lhs))
+	keyword := "const"
+	redeclared := false
+	for i, e := range s.Lhs {
+		ident := e.(*ast.Ident)
+		if ident.Name == "_" {
+			continue
+		}
+		v, _ := g.info.ObjectOf(ident).(*types.Var)
+		vars[i] = v
+		if g.info.Defs[ident] == nil {
+			redeclared = true
+			continue
+		}
+		names[i] = g.named(v, g.locals.declare(v))
+		if g.assigned[v] {
+			keyword = "let"
+		}
+	}
+	if !redeclared {
+		g.varDecl(keyword, names, vars, s.Rhs)
+		return
+	}
+
+	// declare the new variables, then assign all of them
+	var decls []string
+	for _, name := range names {
+		if name != "" {
+			decls = append(decls, name)
+		}
+	}
+	if len(decls) > 0 {
+		g.line("let %s;", strings.Join(decls, ", "))
+	}
+	g.assign(&ast.AssignStmt{Lhs: s.Lhs, TokPos: s.TokPos, Tok: lexer.ASSIGN, Rhs: s.Rhs})
+}
+
+// assignOps maps operator assignments to their operators.
+var assignOps = map[lexer.Token]lexer.Token{
+	lexer.ASSIGN_ADD:       lexer.ADD,
+	lexer.ASSIGN_SUB:       lexer.SUB,
+	lexer.ASSIGN_MULT:      lexer.MULT,
+	lexer.ASSIGN_DIV:       lexer.DIV,
+	lexer.ASSIGN_MOD:       lexer.MOD,
+	lexer.ASSIGN_BIT_AND:   lexer.BIT_AND,
+	lexer.ASSIGN_BIT_OR:    lexer.BIT_OR,
+	lexer.ASSIGN_BIT_NOT:   lexer.BIT_NOT,
+	lexer.ASSIGN_BIT_LEFT:  lexer.BIT_LEFT,
+	lexer.ASSIGN_BIT_RIGHT: lexer.BIT_RIGHT,
+	lexer.ASSIGN_BIT_CLEAR: lexer.BIT_CLEAR,
+}
+
+// opAssign generates lhs op= y, for lhs of type T.
+func (g *generator) opAssign(e ast.Expr, op lexer.Token, y js, T types.Type) {
+	lhs := g.lvalue(e)
+	if !isInt(T) && !isInt64(T) && lhs.target != "" {
+		if b, ok := binaryOps[op]; ok {
+			g.line("%s %s= %s;", lhs.target, b.op, y.at(precAssign))
+			return
+		}
+	}
+	g.exprStmt(js{lhs.set(g.arith(op, lhs.get, y, T).at(precAssign)), precAssign})
+}
+
+func (g *generator) returnStmt(s *ast.ReturnStmt) {
+	results := g.fn.sig.Results()
+	switch {
+	case len(s.Results) == 0 && len(g.fn.results) == 0:
+		g.line("return;")
+	case len(s.Results) == 0:
+		if len(g.fn.results) == 1 {
+			g.line("return %s;", g.fn.results[0])
+		} else {
+			g.line("return [%s];", strings.Join(g.fn.results, ", "))
+		}
+	case len(s.Results) == 1 && len(results) == 1:
+		if m := g.stmtMatch(s.Results[0]); m != nil {
+			g.matchStmt(m, returns(results[0].Type()))
+			return
+		}
+		g.line("return %s;", g.value(s.Results[0], results[0].Type()).code)
+	case len(s.Results) == 1:
+		// a call with the same results
+		g.line("return %s;", g.expr(s.Results[0]).code)
+	default:
+		vals := g.operands(len(s.Results), func(i int) (js, ast.Expr) {
+			return g.value(s.Results[i], results[i].Type()), s.Results[i]
+		})
+		g.line("return [%s];", strings.Join(codes(vals), ", "))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Control flow
+
+func (g *generator) ifStmt(s *ast.IfStmt) {
+	if s.Init != nil {
+		g.open("{")
+		defer g.close("}")
+		g.stmt(s.Init)
+	}
+	if value, ok := g.foldedCond(s.Cond); ok {
+		// only the branch taken is left
+		switch {
+		case value:
+			g.stmt(s.Body)
+		case s.Else != nil:
+			g.stmt(s.Else)
+		}
+		return
+	}
+	g.open("if (%s) {", g.expr(s.Cond).code)
+	g.stmtList(s.Body.List)
+	for s.Else != nil {
+		switch e := s.Else.(type) {
+		case *ast.IfStmt:
+			if value, ok := g.foldedCond(e.Cond); ok && e.Init == nil {
+				if !value {
+					s = e
+					continue
+				}
+				g.indent--
+				g.open("} else {")
+				g.stmtList(e.Body.List)
+				break
+			}
+			if e.Init == nil {
+				// no statement may precede the condition
+				g.indent--
+				cond := g.inlined(func() js { return g.expr(e.Cond) })
+				g.open("} else if (%s) {", cond.code)
+				g.stmtList(e.Body.List)
+				s = e
+				continue
+			}
+			g.indent--
+			g.open("} else {")
+			g.ifStmt(e)
+		case *ast.BlockStmt:
+			g.indent--
+			g.open("} else {")
+			g.stmtList(e.List)
+		}
+		break
+	}
+	g.close("}")
+}
+
+// switchStmt generates a switch as a chain of if statements, in a block
+// labeled for the break statements leaving it.
+func (g *generator) switchStmt(s *ast.SwitchStmt) {
+	label := ""
+	if breaksSwitch(s) {
+		label = g.locals.temp("sw")
+		g.open("%s: {", label)
+	} else if s.Init != nil {
+		g.open("{")
+	}
+	if s.Init != nil {
+		g.stmt(s.Init)
+	}
+
+	var tag js
+	var T types.Type
+	if s.Tag != nil {
+		T = g.info.TypeOf(s.Tag)
+		tag = g.simple(s.Tag)
+	}
+
+	g.fn.targets = append(g.fn.targets, label)
+	var def *ast.CaseClause
+	first := true
+	for _, c := range s.Body {
+		if c.List == nil {
+			def = c
+			continue
+		}
+		conds := make([]string, len(c.List))
+		for i, e := range c.List {
+			cond := func() js {
+				if s.Tag == nil {
+					return g.expr(e)
+				}
+				return g.equalJS(tag, T, g.expr(e), g.info.TypeOf(e), false)
+			}
+			// only the first condition is always evaluated
+			if first && i == 0 {
+				conds[i] = cond().at(precOr + 1)
+			} else {
+				conds[i] = g.inlined(cond).at(precOr + 1)
+			}
+		}
+		if first {
+			g.open("if (%s) {", strings.Join(conds, " || "))
+			first = false
+		} else {
+			g.indent--
+			g.open("} else if (%s) {", strings.Join(conds, " || "))
+		}
+		g.body(c.Body)
+	}
+	if def != nil {
+		if first {
+			g.open("{")
+		} else {
+			g.indent--
+			g.open("} else {")
+		}
+		g.body(def.Body)
+		first = false
+	}
+	if !first {
+		g.close("}")
+	}
+	if s.Tag != nil && len(s.Body) == 0 {
+		g.exprStmt(tag)
+	}
+	g.fn.targets = g.fn.targets[:len(g.fn.targets)-1]
+
+	if label != "" || s.Init != nil {
+		g.close("}")
+	}
+}
+
+// breaksSwitch reports whether a break statement leaves the switch s.
+func breaksSwitch(s *ast.SwitchStmt) bool {
+	for _, c := range s.Body {
+		if breaks(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// breaks reports whether a break statement in n leaves the statement
+// enclosing n.
+func breaks(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			if n.Tok == lexer.BREAK {
+				found = true
+			}
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// clause returns a statement as the init or post statement of a for
+// loop.
+func (g *generator) clause(s ast.Stmt) string {
+	if s == nil {
+		return ""
+	}
+	code := g.capture(func() {
+		indent := g.indent
+		g.indent = 0
+		g.inline++
+		g.stmt(s)
+		g.inline--
+		g.indent = indent
+	})
+	if strings.Contains(code, "\n") {
+		g.errorf(s.Pos(), "cannot generate this statement in a for clause")
+	}
+	return strings.TrimSuffix(code, ";")
+}
+
+func (g *generator) forStmt(s *ast.ForStmt) {
+	init := g.clause(s.Init)
+	cond := ""
+	if s.Cond != nil {
+		cond = g.inlined(func() js { return g.expr(s.Cond) }).code
+	}
+	post := g.clause(s.Post)
+	if s.Init == nil && s.Post == nil {
+		if cond == "" {
+			cond = "true"
+		}
+		g.open("while (%s) {", cond)
+	} else {
+		g.open("for (%s; %s; %s) {", init, cond, post)
+	}
+	g.loopBody(s.Body.List)
+	g.close("}")
+}
+
+// loopBody generates the body of a loop, which break statements leave.
+func (g *generator) loopBody(list []ast.Stmt) {
+	g.fn.targets = append(g.fn.targets, "")
+	g.stmtList(list)
+	g.fn.targets = g.fn.targets[:len(g.fn.targets)-1]
+}
+
+func (g *generator) rangeStmt(s *ast.RangeStmt) {
+	vars := [2]*types.Var{}
+	for i, e := range []ast.Expr{s.Key, s.Value} {
+		if e == nil {
+			continue
+		}
+		if ident, ok := e.(*ast.Ident); ok && ident.Name == "_" {
+			continue
+		}
+		if s.Tok == lexer.SHORT_VAR {
+			vars[i], _ = g.info.Defs[e.(*ast.Ident)].(*types.Var)
+			continue
+		}
+		// assign the variables of the loop to those of the statement
+		v := types.NewVar(e.Pos(), "", g.info.TypeOf(e))
+		g.locals.names[v] = g.locals.temp([]string{"k", "v"}[i])
+		vars[i] = v
+	}
+
+	head, binds := g.rangeHead(s.X, vars[0], vars[1], s.Body)
+	g.open("%s {", head)
+	for _, b := range binds {
+		g.line("%s", b)
+	}
+	for i, v := range vars {
+		if v != nil && s.Tok != lexer.SHORT_VAR {
+			lhs := g.lvalue([]ast.Expr{s.Key, s.Value}[i])
+			g.exprStmt(js{lhs.set(g.locals.names[v]), precAssign})
+		}
+	}
+	g.loopBody(s.Body.List)
+	g.close("}")
+}
+
+// rangeHead returns the head of a loop over the range x declaring the
+// variables key and value, either of which may be nil, and the statements
+// starting its body that assign them. body is the body of the loop, in
+// which the variables may be assigned.
+func (g *generator) rangeHead(x ast.Expr, key, val *types.Var, body ast.Node) (head string, binds []string) {
+	T := g.info.TypeOf(x)
+	decl := func(v *types.Var) string {
+		if g.assigned[v] || g.modifies(body, v) {
+			return "let"
+		}
+		return "const"
+	}
+	name := func(v *types.Var) string {
+		if v == nil {
+			return ""
+		}
+		return g.name(v)
+	}
+
+	switch u := types.CoreType(T).(type) {
+	case *types.Slice, *types.Array:
+		var elem types.Type
+		if s, ok := u.(*types.Slice); ok {
+			elem = s.Elem()
+		} else {
+			elem = u.(*types.Array).Elem()
+		}
+		xs := g.expr(x)
+		var inits []string
+		if !g.isSimple(x) {
+			t := g.locals.temp("a")
+			inits = append(inits, t+" = "+xs.at(precAssign))
+			xs = js{t, precPrimary}
+		}
+		i := name(key)
+		if i == "" || g.assigned[key] {
+			i = g.locals.temp("i")
+			if key != nil {
+				binds = append(binds, "let "+name(key)+" = "+i+";")
+			}
+		}
+		n := xs.at(precCall) + ".length"
+		if ident, ok := unparen(x).(*ast.Ident); ok && g.assigned[g.info.Uses[ident]] {
+			// the length of the range is that of the slice before the loop
+			t := g.locals.temp("n")
+			inits = append(inits, t+" = "+n)
+			n = t
+		}
+		inits = append([]string{i + " = 0"}, inits...)
+		head = "for (let " + strings.Join(inits, ", ") + "; " + i + " < " + n + "; " + i + "++)"
+		if val != nil {
+			elt := js{xs.at(precCall) + "[" + i + "]", precCall}
+			if g.modifies(body, val) {
+				elt = g.clone(elt, elem)
+			}
+			binds = append(binds, decl(val)+" "+name(val)+" = "+elt.code+";")
+		}
+		return head, binds
+
+	case *types.Map:
+		m := g.expr(x).at(precCall)
+		k, v := name(key), name(val)
+		keyword := "const"
+		if key != nil && decl(key) == "let" || val != nil && decl(val) == "let" {
+			keyword = "let"
+		}
+		switch {
+		case val == nil && key == nil:
+			return "for (const " + g.locals.temp("_") + " of " + m + ".keys())", nil
+		case val == nil:
+			return "for (" + keyword + " " + k + " of " + m + ".keys())", nil
+		}
+		if val != nil && g.modifies(body, val) && isValueType(u.Elem()) {
+			t := g.locals.temp("v")
+			binds = append(binds, "let "+v+" = "+g.clone(js{t, precPrimary}, u.Elem()).code+";")
+			v = t
+		}
+		return "for (" + keyword + " [" + k + ", " + v + "] of " + m + ")", binds
+
+	case *types.Basic:
+		if isString(u) {
+			k, v := name(key), name(val)
+			keyword := "const"
+			if key != nil && decl(key) == "let" || val != nil && decl(val) == "let" {
+				keyword = "let"
+			}
+			if k == "" && v == "" {
+				k = g.locals.temp("_")
+			}
+			return "for (" + keyword + " [" + k + ", " + v + "] of " + g.use("$chars") + "(" + g.expr(x).code + "))", nil
+		}
+		// a range over the integers from 0
+		n := g.expr(x)
+		i := name(key)
+		if i == "" || g.assigned[key] {
+			i = g.locals.temp("i")
+			if key != nil {
+				binds = append(binds, "let "+name(key)+" = "+i+";")
+			}
+		}
+		zero := "0"
+		if isInt64(u) {
+			zero = "0n"
+		}
+		init := i + " = " + zero
+		if !g.isSimple(x) {
+			t := g.locals.temp("n")
+			init += ", " + t + " = " + n.at(precAssign)
+			n = js{t, precPrimary}
+		}
+		return "for (let " + init + "; " + i + " < " + n.at(precRel+1) + "; " + i + "++)", binds
+	}
+	g.errorf(x.Pos(), "cannot range over %s", T)
+	return "for (;;)", nil
+}
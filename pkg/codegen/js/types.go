@@ -0,0 +1,604 @@
+package js
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+	"github.com/gusset-lang/gusset/pkg/types"
+)
+
+// staticProps holds the properties of functions, which the static members
+// of classes must not replace.
+var staticProps = map[string]bool{
+	"apply": true, "arguments": true, "bind": true, "call": true,
+	"caller": true, "length": true, "name": true, "prototype": true,
+}
+
+// staticName returns the JavaScript name of a static member of a class: an
+// enum variant or a method of a boxed type.
+func staticName(name string) string {
+	if staticProps[name] {
+		return name + "$"
+	}
+	return name
+}
+
+// hasClass reports whether values of the named type t are represented by
+// instances of a class: structs and records, and the boxed types, unless t
+// is extern. The values of enums are boxed when they have methods.
+func hasClass(t *types.Named) bool {
+	if t.Extern() {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Record:
+		return true
+	case *types.Interface:
+		return false
+	}
+	return t.Origin().NumMethods() > 0
+}
+
+// isBoxed reports whether T is a named type with methods whose values are
+// not objects, and are boxed when they are stored in an interface.
+func isBoxed(T types.Type) bool {
+	t, ok := T.(*types.Named)
+	if !ok || !hasClass(t) {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Record:
+		return false
+	}
+	return true
+}
+
+// className returns the name of the class of the named type t.
+func (g *generator) className(t *types.Named) string {
+	return g.names[t.Origin().Obj()]
+}
+
+// typeDecl generates the class of a type declaration, or the object of an
+// enum type without methods, if its type needs one, and reports whether it
+// does.
+func (g *generator) typeDecl(s *ast.TypeSpec) bool {
+	obj, _ := g.info.Defs[s.Name].(*types.TypeName)
+	if obj == nil {
+		return false
+	}
+	t, ok := obj.Type().(*types.Named)
+	if !ok || !hasClass(t) && !g.hasEnumObject(t) {
+		return false
+	}
+	outer := g.locals
+	defer func() { g.locals = outer }()
+	name := g.names[obj]
+	if name == "" {
+		// a local type, whose fields must not shadow the names around it
+		name = outer.declare(obj)
+		g.names[obj] = name
+		g.locals = outer.fork()
+	} else {
+		g.locals = g.newScope()
+	}
+
+	g.separate()
+	g.pending = g.mark(s.Pos(), "")
+	if !hasClass(t) {
+		g.enumObject(obj, name, t)
+		return true
+	}
+	g.open("class %s {", g.named(obj, name))
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		g.structClass(name, structFields(u), false)
+	case *types.Record:
+		g.structClass(name, structFields(u), true)
+	default:
+		g.line("constructor($value) {")
+		g.line("  this.$value = $value;")
+		g.line("}")
+	}
+	g.methods(t)
+	_, isEnum := t.Underlying().(*types.Enum)
+	if isEnum {
+		g.enumHelpers(t, true)
+	}
+	g.close("}")
+
+	if isEnum {
+		g.enumStatics(name, t)
+	}
+	return true
+}
+
+// structFields returns the fields of a struct or record type.
+func structFields(T types.Type) []*types.Var {
+	var fields []*types.Var
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			fields = append(fields, u.Field(i))
+		}
+	case *types.Record:
+		for i := 0; i < u.NumFields(); i++ {
+			fields = append(fields, u.Field(i))
+		}
+	}
+	return fields
+}
+
+// structClass generates the constructor of a struct or record class, whose
+// parameters default to the zero values of the fields, and for structs the
+// $clone method copying a value.
+func (g *generator) structClass(name string, fields []*types.Var, frozen bool) {
+	params := make([]string, len(fields))
+	args := make([]string, len(fields))
+	for i, f := range fields {
+		param := g.locals.declare(f)
+		params[i] = param
+		if zero := g.zero(f.Type()); zero != "undefined" {
+			params[i] += " = " + zero
+		}
+		args[i] = g.clone(js{"this." + propName(f.Name()), precCall}, f.Type()).code
+	}
+	g.open("constructor(%s) {", strings.Join(params, ", "))
+	for _, f := range fields {
+		g.line("this.%s = %s;", propName(f.Name()), g.locals.names[f])
+	}
+	if frozen {
+		g.line("Object.freeze(this);")
+	}
+	g.close("}")
+	if !frozen {
+		g.line("")
+		g.open("$clone() {")
+		g.line("return new %s(%s);", name, strings.Join(args, ", "))
+		g.close("}")
+	}
+}
+
+// methods generates the methods declared for the named type t. Methods of
+// classes are methods of their instances; those of boxed types are static
+// methods taking the receiver as first argument, which the instances
+// forward to. Classes of structs also forward the methods promoted from
+// their embedded fields.
+func (g *generator) methods(t *types.Named) {
+	boxed := isBoxed(t)
+	for _, file := range g.files {
+		for _, decl := range file.Decls {
+			d, ok := decl.(*ast.FuncDecl)
+			if !ok || d.Recv == nil {
+				continue
+			}
+			fn, _ := g.info.Defs[d.Name].(*types.Func)
+			if fn == nil || recvNamed(fn) != t.Origin() {
+				continue
+			}
+			g.file = file
+			g.locals = g.newScope()
+			g.line("")
+			g.pending = g.mark(d.Pos(), "")
+			g.method(d, fn, boxed)
+			if boxed {
+				g.line("")
+				name := propName(fn.Name())
+				g.open("%s(...args) {", name)
+				g.line("return %s.%s(this.$value, ...args);", g.className(t), staticName(fn.Name()))
+				g.close("}")
+			}
+		}
+	}
+	if _, ok := t.Underlying().(*types.Struct); ok {
+		g.promoted(t)
+	}
+}
+
+// recvNamed returns the named type declaring the method fn.
+func recvNamed(fn *types.Func) *types.Named {
+	recv := fn.Signature().Recv()
+	if recv == nil {
+		return nil
+	}
+	t, _ := recv.Type().(*types.Named)
+	if t == nil {
+		return nil
+	}
+	return t.Origin()
+}
+
+// method generates a method declaration. The receiver is a copy of this,
+// made only when the method modifies it.
+func (g *generator) method(d *ast.FuncDecl, fn *types.Func, boxed bool) {
+	g.owned = g.ownedSlices(d.Body)
+	defer func() { g.owned = nil }()
+	recv := fn.Signature().Recv()
+	var recvName, prologue string
+	if recv.Name() != "" && recv.Name() != "_" && (boxed || g.refers(d.Body, recv)) {
+		recvName = g.locals.declare(recv)
+	}
+	if boxed {
+		if recvName == "" {
+			recvName = g.locals.temp("recv")
+		}
+		g.function("static "+staticName(fn.Name()), fn.Signature(), d.Type, d.Body, recvName)
+		return
+	}
+	if recvName != "" {
+		this := js{"this", precPrimary}
+		if g.modifies(d.Body, recv) {
+			prologue = fmt.Sprintf("let %s = %s;", recvName, g.clone(this, recv.Type()).code)
+		} else {
+			prologue = fmt.Sprintf("const %s = this;", recvName)
+		}
+	}
+	g.functionWith(propName(fn.Name()), fn.Signature(), d.Type, d.Body, "", prologue)
+}
+
+// promoted generates the methods of a struct class that forward to the
+// methods promoted from its embedded fields.
+func (g *generator) promoted(t *types.Named) {
+	for _, fn := range promotedMethods(t) {
+		_, index := types.LookupFieldOrMethod(t, fn.Name())
+		path := js{"this", precPrimary}
+		T := types.Type(t)
+		for _, i := range index[:len(index)-1] {
+			f := structFields(T)[i]
+			path = js{path.at(precCall) + "." + propName(f.Name()), precCall}
+			T = f.Type()
+		}
+		g.line("")
+		g.open("%s(...args) {", propName(fn.Name()))
+		g.line("return %s;", g.methodCall(path, T, fn, []string{"...args"}).code)
+		g.close("}")
+	}
+}
+
+// promotedMethods returns the methods promoted to the named struct type t
+// from its embedded fields, which t does not declare itself.
+func promotedMethods(t *types.Named) []*types.Func {
+	declared := make(map[string]bool)
+	for i := 0; i < t.NumMethods(); i++ {
+		declared[t.Method(i).Name()] = true
+	}
+	seen := make(map[string]bool)
+	var names []string
+	var collect func(T types.Type, depth int)
+	collect = func(T types.Type, depth int) {
+		if depth > 8 {
+			return
+		}
+		if n, ok := T.(*types.Named); ok {
+			for i := 0; i < n.NumMethods(); i++ {
+				name := n.Method(i).Name()
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		for _, f := range structFields(T) {
+			if f.Embedded() {
+				collect(f.Type(), depth+1)
+			}
+		}
+	}
+	for _, f := range structFields(t) {
+		if f.Embedded() {
+			collect(f.Type(), 0)
+		}
+	}
+	var methods []*types.Func
+	for _, name := range names {
+		if declared[name] {
+			continue
+		}
+		obj, index := types.LookupFieldOrMethod(t, name)
+		if fn, ok := obj.(*types.Func); ok && len(index) >= 2 {
+			methods = append(methods, fn)
+		}
+	}
+	return methods
+}
+
+// refers reports whether body refers to the object obj.
+func (g *generator) refers(body ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && g.info.Uses[ident] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// modifies reports whether body assigns the variable v or one of its
+// fields or elements.
+func (g *generator) modifies(body ast.Node, v types.Object) bool {
+	found := false
+	check := func(e ast.Expr) {
+		if g.rootVar(e) == v {
+			found = true
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				check(lhs)
+			}
+		case *ast.IncDecStmt:
+			check(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == lexer.ASSIGN {
+				check(n.Key)
+				check(n.Value)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// rootVar returns the variable whose value the lvalue e is part of.
+func (g *generator) rootVar(e ast.Expr) types.Object {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			if s := g.info.Selections[x]; s == nil || s.Kind() != types.FieldVal {
+				return nil
+			}
+			if !isValueType(g.info.TypeOf(x.X)) {
+				return nil
+			}
+			e = x.X
+		case *ast.IndexExpr:
+			if _, ok := g.info.TypeOf(x.X).Underlying().(*types.Array); !ok {
+				return nil
+			}
+			e = x.X
+		case *ast.Ident:
+			return g.info.ObjectOf(x)
+		default:
+			return nil
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Values
+
+// isValueType reports whether values of type T are copied: structs and
+// arrays, which JavaScript represents by mutable objects. The objects of
+// extern structs are shared.
+func isValueType(T types.Type) bool {
+	if T == nil || isExtern(T) {
+		return false
+	}
+	switch T.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
+
+// clone returns a copy of the value x of type T if T is a value type.
+func (g *generator) clone(x js, T types.Type) js {
+	switch u := T.Underlying().(type) {
+	case *types.Struct:
+		if n, ok := T.(*types.Named); ok && hasClass(n) {
+			return js{x.at(precCall) + ".$clone()", precCall}
+		}
+		return js{"{ ..." + x.at(precAssign) + " }", precPrimary}
+	case *types.Array:
+		if isValueType(u.Elem()) {
+			e := g.clone(js{"e", precPrimary}, u.Elem())
+			return js{x.at(precCall) + ".map((e) => " + e.code + ")", precCall}
+		}
+		return js{x.at(precCall) + ".slice()", precCall}
+	}
+	return x
+}
+
+// zero returns the zero value of type T, or "undefined" for types without
+// one.
+func (g *generator) zero(T types.Type) string {
+	switch u := T.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Kind() == types.Bool || u.Kind() == types.UntypedBool:
+			return "false"
+		case u.Kind() == types.Int64:
+			return "0n"
+		case isNumber(u):
+			return "0"
+		case u.Kind() == types.String || u.Kind() == types.UntypedString:
+			return `""`
+		}
+	case *types.Optional:
+		return "null"
+	case *types.Slice:
+		return "[]"
+	case *types.Map:
+		if primitiveKey(u.Key()) {
+			return "new Map()"
+		}
+		return "new " + g.use("$Map") + "()"
+	case *types.Array:
+		if u.Len() == 0 {
+			return "[]"
+		}
+		zero := g.zero(u.Elem())
+		if isValueType(u.Elem()) || strings.HasPrefix(zero, "new ") || zero == "[]" {
+			return fmt.Sprintf("Array.from({ length: %d }, () => %s)", u.Len(), zero)
+		}
+		return fmt.Sprintf("new Array(%d).fill(%s)", u.Len(), zero)
+	case *types.Tuple:
+		elts := make([]string, u.Len())
+		for i := range elts {
+			elts[i] = g.zero(u.At(i))
+		}
+		return "[" + strings.Join(elts, ", ") + "]"
+	case *types.Struct, *types.Record:
+		fields := structFields(u)
+		if n, ok := T.(*types.Named); ok && hasClass(n) {
+			return g.structLit(n, fields, make([]js, len(fields))).code
+		}
+		elts := make([]string, len(fields))
+		for i, f := range fields {
+			elts[i] = fieldName(T, f) + ": " + g.zero(f.Type())
+		}
+		obj := "{ " + strings.Join(elts, ", ") + " }"
+		if len(fields) == 0 {
+			obj = "{}"
+		}
+		if _, ok := u.(*types.Record); ok {
+			return "Object.freeze(" + obj + ")"
+		}
+		return obj
+	case *types.TypeParam:
+		// the zero value common to the types of the type set
+		zero := ""
+		for _, term := range u.Terms() {
+			z := g.zero(term.Type())
+			if zero != "" && z != zero {
+				return "undefined"
+			}
+			zero = z
+		}
+		if zero != "" {
+			return zero
+		}
+	}
+	return "undefined"
+}
+
+// box returns the value x of type V stored in a variable of type T: boxed
+// in an instance of its class when T is an interface and V a boxed type,
+// and with the class of T when V and T are distinct classes of records.
+func (g *generator) box(x js, V, T types.Type) js {
+	if V == nil || T == nil {
+		return x
+	}
+	if o, ok := T.Underlying().(*types.Optional); ok {
+		if isBasic(V, types.UntypedNil) {
+			return x
+		}
+		T = o.Elem()
+	}
+	switch T.Underlying().(type) {
+	case *types.Interface:
+		if isBoxed(V) {
+			return js{"new " + g.className(V.(*types.Named)) + "(" + x.code + ")", precCall}
+		}
+		if o, ok := V.Underlying().(*types.Optional); ok && isBoxed(o.Elem()) {
+			v := x.at(precEq)
+			return js{v + " === null ? null : new " + g.className(o.Elem().(*types.Named)) + "(" + x.code + ")", precCond}
+		}
+	case *types.Record:
+		optional := false
+		if o, ok := V.Underlying().(*types.Optional); ok {
+			V, optional = o.Elem(), true
+		}
+		vn, ok1 := V.(*types.Named)
+		tn, ok2 := T.(*types.Named)
+		if ok2 && (!ok1 || vn.Origin() != tn.Origin()) {
+			conv := g.use("$convert") + "(" + x.code + ", " + g.className(tn) + ")"
+			if optional {
+				return js{x.at(precEq) + " === null ? null : " + conv, precCond}
+			}
+			return js{conv, precCall}
+		}
+	}
+	return x
+}
+
+// ----------------------------------------------------------------------------
+// Predicates
+
+// isBasic reports whether T is a basic type of one of kinds, or a type
+// parameter whose type set holds only such types.
+func isBasic(T types.Type, kinds ...types.BasicKind) bool {
+	if T == nil {
+		return false
+	}
+	if tp, ok := T.(*types.TypeParam); ok {
+		terms := tp.Terms()
+		for _, term := range terms {
+			if !isBasic(term.Type(), kinds...) {
+				return false
+			}
+		}
+		return len(terms) > 0
+	}
+	b, ok := T.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	for _, k := range kinds {
+		if b.Kind() == k {
+			return true
+		}
+	}
+	return false
+}
+
+func isInt(T types.Type) bool    { return isBasic(T, types.Int, types.UntypedInt) }
+func isInt64(T types.Type) bool  { return isBasic(T, types.Int64) }
+func isFloat(T types.Type) bool  { return isBasic(T, types.Float, types.UntypedFloat) }
+func isString(T types.Type) bool { return isBasic(T, types.String, types.UntypedString) }
+
+func isNumber(T types.Type) bool {
+	return isBasic(T, types.Int, types.Float, types.UntypedInt, types.UntypedFloat)
+}
+
+// primitiveKey reports whether JavaScript compares values of type T by
+// value: booleans, numbers, BigInts, strings and symbols.
+func primitiveKey(T types.Type) bool {
+	switch u := T.Underlying().(type) {
+	case *types.Basic:
+		return u.Kind() != types.UntypedNil
+	case *types.SymbolSet:
+		return true
+	case *types.TypeParam:
+		terms := u.Terms()
+		if len(terms) == 0 {
+			return false
+		}
+		for _, term := range terms {
+			if !primitiveKey(term.Type()) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// identityEqual reports whether values of type T are equal exactly when
+// === holds: the primitive types, their optionals, functions, enums
+// without payload and extern types.
+func identityEqual(T types.Type) bool {
+	if primitiveKey(T) || isExtern(T) {
+		return true
+	}
+	switch u := T.Underlying().(type) {
+	case *types.Optional:
+		return identityEqual(u.Elem())
+	case *types.Signature:
+		return true
+	case *types.Enum:
+		for i := 0; i < u.NumVariants(); i++ {
+			if u.Variant(i).Payload() != nil {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -1,10 +1,31 @@
 package lexer
 
 import (
+	"fmt"
 	"io"
 	"unicode"
 )
 
+// Items lexes everything read from reader and returns the resulting items,
+// ending with EOF, or the first error reported by the lexer.
+func Items(reader io.Reader) ([]Item, error) {
+	result := make(chan Result)
+	go Exec(New(reader, result))
+
+	var items []Item
+	var err error
+	for r := range result {
+		if r.Error != nil {
+			if err == nil {
+				err = fmt.Errorf("%w: %w", ErrLexer, r.Error)
+			}
+			continue
+		}
+		items = append(items, *r.Item)
+	}
+	return items, err
+}
+
 func Exec(l *Lexer) {
 	for {
 		start := l.pos
@@ -29,6 +50,44 @@ func Exec(l *Lexer) {
 			continue
 		}
 
+		if r == '/' {
+			skipped, err := l.skipComment(start)
+			if err != nil {
+				break
+			}
+			if skipped {
+				continue
+			}
+		}
+
+		if r == '_' {
+			next, err := l.peek()
+			if err != nil {
+				break
+			}
+			if isIdentRune(next) {
+				item, err := l.itemFromAlphanum(start, r)
+				if err != nil {
+					break
+				}
+				l.sendItem(item)
+				continue
+			}
+		}
+
+		if r == '<' && precedesOperand(l.last) {
+			next, err := l.peek()
+			if err != nil {
+				break
+			}
+			if next == '>' || unicode.IsLetter(next) {
+				if err := l.collectJSX(start); err != nil {
+					break
+				}
+				continue
+			}
+		}
+
 		matchedRuneSeq, err := l.matchRuneSequence(start, r)
 		if err != nil {
 			break
@@ -66,7 +125,11 @@ func Exec(l *Lexer) {
 		}
 
 		if unicode.IsDigit(r) {
-			item, err := l.itemFromNumeric(start, r)
+			itemFromNumeric := l.itemFromNumeric
+			if l.last == ACCESS {
+				itemFromNumeric = l.itemFromIndex
+			}
+			item, err := itemFromNumeric(start, r)
 			if err != nil {
 				break
 			}
@@ -859,6 +859,54 @@ var testCases = []lexerTestCase{
 		idents: []string{"i", "i", "i"},
 		input:  `for i := 0; i < 10; i++ {}`,
 	},
+	{
+		name: "tuple index",
+		tokens: tokens{
+			IDENT, SHORT_VAR, IDENT, ACCESS, INT, ACCESS, INT, ADD, FLOAT, EOF,
+		},
+		idents: []string{"x", "t"},
+		input:  `x := t.1.0 + 1.5`,
+	},
+	{
+		name: "with",
+		tokens: tokens{
+			IDENT, SHORT_VAR, IDENT, WITH, OPEN_BRACE, IDENT, ARROW, INT, CLOSE_BRACE, EOF,
+		},
+		idents: []string{"next", "state", "Count"},
+		input:  `next := state with {Count => 0}`,
+	},
+	{
+		name: "list comprehension",
+		tokens: tokens{
+			IDENT, SHORT_VAR, OPEN_BRACKET, IDENT, MULT, INT, FOR, IDENT, IN, IDENT, IF, IDENT, GT, INT, CLOSE_BRACKET, EOF,
+		},
+		idents: []string{"s", "x", "x", "xs", "x"},
+		input:  `s := [x * 2 for x in xs if x > 0]`,
+	},
+	{
+		name: "map comprehension",
+		tokens: tokens{
+			IDENT, SHORT_VAR, OPEN_BRACE, IDENT, ARROW, IDENT, FOR, IDENT, COMMA, IDENT, IN, IDENT, CLOSE_BRACE, EOF,
+		},
+		idents: []string{"m", "k", "v", "k", "v", "m"},
+		input:  `m := {k => v for k, v in m}`,
+	},
+	{
+		name: "type parameters",
+		tokens: tokens{
+			TYPE, IDENT, OPEN_BRACKET, IDENT, TILDE, T_INT, BIT_OR, T_FLOAT, CLOSE_BRACKET, OPEN_BRACKET, CLOSE_BRACKET, IDENT, EOF,
+		},
+		idents: []string{"Nums", "T", "T"},
+		input:  `type Nums[T ~int | float] []T`,
+	},
+	{
+		name: "optional type",
+		tokens: tokens{
+			VAR, IDENT, QUESTION, OPEN_BRACKET, CLOSE_BRACKET, IDENT, ASSIGN, NIL, EOF,
+		},
+		idents: []string{"users", "User"},
+		input:  `var users ?[]User = nil`,
+	},
 	{
 		name: "match",
 		tokens: tokens{
@@ -893,6 +941,71 @@ var testCases = []lexerTestCase{
 		idents: []string{"q"},
 		input:  `var q = #json{{"test": [1, 2, 3]}}`,
 	},
+	{
+		name:   "structured literal with delimiters in strings",
+		tokens: tokens{VAR, IDENT, ASSIGN, STRUCTURED, EOF},
+		idents: []string{"q"},
+		input:  `var q = #json({"a)": "\")"})`,
+	},
+	{
+		name:   "line comment",
+		tokens: tokens{IDENT, SHORT_VAR, INT, NEWLINE, IDENT, EOF},
+		idents: []string{"a", "b"},
+		input:  "a := 1 // one\nb",
+	},
+	{
+		name:   "block comment",
+		tokens: tokens{IDENT, ADD, IDENT, EOF},
+		idents: []string{"a", "b"},
+		input:  "a /* plus */ + b",
+	},
+	{
+		name:   "underscore ident",
+		tokens: tokens{IDENT, ASSIGN, OMIT, EOF},
+		idents: []string{"_private"},
+		input:  "_private = _",
+	},
+	shortVarWithNumericLiteralTestCase("test4", FLOAT, "1e-5"),
+	{
+		name:   "three rune sequence",
+		tokens: tokens{IDENT, ASSIGN_BIT_CLEAR, IDENT, EOF},
+		idents: []string{"a", "b"},
+		input:  "a &^= b",
+	},
+	{
+		name:   "unterminated string",
+		tokens: tokens{IDENT, ASSIGN, ILLEGAL, EOF},
+		idents: []string{"s"},
+		input:  `s = "open`,
+	},
+	{
+		name:   "jsx element",
+		tokens: tokens{IDENT, SHORT_VAR, JSX, EOF},
+		idents: []string{"el"},
+		input:  `el := <ul class="a>b">{items.map((x) => <li key={x}>{x + "}"}</li>)}<br /></ul>`,
+	},
+	{
+		name:   "jsx fragment argument",
+		tokens: tokens{IDENT, OPEN_PAREN, JSX, COMMA, IDENT, CLOSE_PAREN, EOF},
+		idents: []string{"render", "root"},
+		input:  "render(<>\n\t<App />\n</>, root)",
+	},
+	{
+		name:   "less than after operand",
+		tokens: tokens{IDENT, LT, IDENT, AND, IDENT, CLOSE_PAREN, LT, IDENT, EOF},
+		idents: []string{"a", "b", "c", "d"},
+		input:  "a <b && c) <d",
+	},
+	{
+		name:   "unterminated jsx",
+		tokens: tokens{RETURN, ILLEGAL, EOF},
+		input:  "return <div>{x}",
+	},
+	{
+		name:   "unterminated structured literal",
+		tokens: tokens{ILLEGAL, EOF},
+		input:  `#json{"a": 1`,
+	},
 }
 
 func TestLexer(t *testing.T) {
@@ -918,6 +1031,7 @@ func TestLexer(t *testing.T) {
 				currentToken += 1
 				lastPos = r.Item.Pos
 			}
+			require.Equalf(t, len(testCase.tokens), currentToken, "expected %d tokens; received %d", len(testCase.tokens), currentToken)
 		})
 	}
 }
@@ -0,0 +1,193 @@
+package lexer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// precedesOperand reports whether an operand rather than an operator
+// follows the token t, so that a '<' after it starts a JSX element.
+func precedesOperand(t Token) bool {
+	switch t {
+	case IDENT, OMIT,
+		SYMBOL, STRING, TEMPLATE, STRUCTURED, JSX, INT, FLOAT, BOOL, NIL,
+		T_SYMBOL, T_STRING, T_INT, T_FLOAT, T_BOOL, ANY,
+		ASSIGN_INC, ASSIGN_DEC,
+		CLOSE_PAREN, CLOSE_BRACKET, CLOSE_BRACE:
+		return false
+	}
+	return true
+}
+
+// collectJSX collects a JSX element, whose '<' was just read, into a
+// single JSX item. The element ends with its closing tag, which the
+// elements and the braced expressions it contains may not close. The
+// parser parses the element from the item.
+func (l *Lexer) collectJSX(start Position) error {
+	var seq strings.Builder
+	seq.WriteRune('<')
+	ok, err := l.jsxElement(&seq)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		l.sendItem(&Item{start, ILLEGAL, seq.String()})
+		return nil
+	}
+	l.sendItem(&Item{start, JSX, seq.String()})
+	return nil
+}
+
+// jsxElement collects an element after its '<'. It reports false if the
+// input ends before the element does.
+func (l *Lexer) jsxElement(seq *strings.Builder) (bool, error) {
+	// the opening tag
+	for {
+		r, err := l.next()
+		if err != nil {
+			return false, err
+		}
+		if r == EOF_RUNE {
+			return false, nil
+		}
+		seq.WriteRune(r)
+		switch r {
+		case '"', '\'':
+			if ok, err := l.jsxString(seq, r); !ok || err != nil {
+				return false, err
+			}
+			continue
+		case '{':
+			if ok, err := l.jsxBraces(seq); !ok || err != nil {
+				return false, err
+			}
+			continue
+		case '/':
+			next, err := l.peek()
+			if err != nil {
+				return false, err
+			}
+			if next != '>' {
+				continue
+			}
+			r, _ = l.next()
+			seq.WriteRune(r)
+			return true, nil // self-closing
+		case '>':
+		default:
+			continue
+		}
+		break
+	}
+
+	// the children and the closing tag
+	for {
+		r, err := l.next()
+		if err != nil {
+			return false, err
+		}
+		if r == EOF_RUNE {
+			return false, nil
+		}
+		seq.WriteRune(r)
+		switch r {
+		case '{':
+			if ok, err := l.jsxBraces(seq); !ok || err != nil {
+				return false, err
+			}
+		case '<':
+			next, err := l.peek()
+			if err != nil {
+				return false, err
+			}
+			if next != '/' {
+				if ok, err := l.jsxElement(seq); !ok || err != nil {
+					return false, err
+				}
+				continue
+			}
+			for r != '>' {
+				if r, err = l.next(); err != nil {
+					return false, err
+				}
+				if r == EOF_RUNE {
+					return false, nil
+				}
+				seq.WriteRune(r)
+			}
+			return true, nil
+		}
+	}
+}
+
+// jsxString collects a quoted attribute value after its opening quote.
+func (l *Lexer) jsxString(seq *strings.Builder, quote rune) (bool, error) {
+	for {
+		r, err := l.next()
+		if err != nil {
+			return false, err
+		}
+		if r == EOF_RUNE {
+			return false, nil
+		}
+		seq.WriteRune(r)
+		if r == quote {
+			return true, nil
+		}
+	}
+}
+
+// jsxBraces collects a braced expression after its '{'. Braces in strings
+// do not count, and elements nested in the expression are collected as
+// elements, so that their text does not count either.
+func (l *Lexer) jsxBraces(seq *strings.Builder) (bool, error) {
+	depth := 1
+	prev := '{' // the last rune outside strings that is not a space
+	for {
+		r, err := l.next()
+		if err != nil {
+			return false, err
+		}
+		if r == EOF_RUNE {
+			return false, nil
+		}
+		seq.WriteRune(r)
+		switch {
+		case r == '"' || r == '`':
+			for escaped := false; ; {
+				s, err := l.next()
+				if err != nil {
+					return false, err
+				}
+				if s == EOF_RUNE {
+					return false, nil
+				}
+				seq.WriteRune(s)
+				if s == r && !escaped {
+					break
+				}
+				escaped = !escaped && s == '\\'
+			}
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+			if depth == 0 {
+				return true, nil
+			}
+		case r == '<' && strings.ContainsRune("({[,=:?&|!>", prev):
+			next, err := l.peek()
+			if err != nil {
+				return false, err
+			}
+			if next == '>' || unicode.IsLetter(next) {
+				if ok, err := l.jsxElement(seq); !ok || err != nil {
+					return false, err
+				}
+			}
+		}
+		if !unicode.IsSpace(r) {
+			prev = r
+		}
+	}
+}
@@ -52,6 +52,16 @@ func (p Position) IsAfter(t Position) bool {
 	}
 }
 
+// IsValid reports whether the position was recorded by the lexer.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as line:column, both counted from 1.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col+1)
+}
+
 func (p Position) Add(line, col int) Position {
 	return Position{
 		Line: p.Line + line,
@@ -61,9 +71,9 @@ func (p Position) Add(line, col int) Position {
 
 func New(reader io.Reader, items chan Result) *Lexer {
 	return &Lexer{
-		Position{Line: 1, Col: 0},
-		bufio.NewReader(reader),
-		items,
+		pos:    Position{Line: 1, Col: 0},
+		reader: bufio.NewReader(reader),
+		result: items,
 	}
 }
 
@@ -71,17 +81,21 @@ type Lexer struct {
 	pos    Position
 	reader *bufio.Reader
 	result chan Result
+
+	// last is the token of the most recently sent item.
+	last Token
 }
 
 func (l *Lexer) sendEOF() {
-	l.result <- itemResult(Item{l.pos, EOF, ""})
+	l.sendItem(&Item{l.pos, EOF, ""})
 }
 
 func (l *Lexer) sendNewLine(pos Position) {
-	l.result <- itemResult(Item{pos, NEWLINE, "\n"})
+	l.sendItem(&Item{pos, NEWLINE, "\n"})
 }
 
 func (l *Lexer) sendItem(item *Item) {
+	l.last = item.Token
 	l.result <- itemResult(*item)
 }
 
@@ -137,70 +151,97 @@ func (l *Lexer) backup(r rune) error {
 func (l *Lexer) peek() (rune, error) {
 	b, err := l.reader.Peek(1)
 	if err != nil {
+		if err == io.EOF {
+			return EOF_RUNE, nil
+		}
 		l.sendError(err)
 		return 0, err
 	}
 	return rune(b[0]), nil
 }
 
-func (l *Lexer) peek2() ([2]rune, error) {
-	b, err := l.reader.Peek(2)
-	if err != nil {
-		return [2]rune{}, err
-	}
-	return [2]rune{rune(b[0]), rune(b[1])}, nil
-}
-
 func (l *Lexer) matchRuneSequence(start Position, r rune) (bool, error) {
 	node, ok := runeSequenceTree[r]
 	if !ok {
 		return false, nil
 	}
-	if node.t == nil {
-		return false, fmt.Errorf("%w: matched leaf node of symbolic tree has no token", ErrLexer)
-	}
-	item := &Item{start, *node.t, runeSequences[*node.t]}
 
-	if len(node.children) == 0 {
-		l.sendItem(item)
-		return true, nil
-	}
-
-	nextRunes, err := l.peek2()
-	if err != nil {
+	upcoming, err := l.reader.Peek(maxRuneSequenceLen - 1)
+	if err != nil && err != io.EOF {
+		l.sendError(err)
 		return false, err
 	}
 
-	secondNode, ok := node.children[nextRunes[0]]
-	if !ok {
-		l.sendItem(item)
-		return true, nil
+	// Walk down the tree as far as the upcoming runes allow, keeping the
+	// longest sequence that ends on a token. A rune like ':' only starts a
+	// token when followed by '=', otherwise it is left for the symbol collector.
+	matched := node.t
+	extra := 0
+	for i, b := range upcoming {
+		child, ok := node.children[rune(b)]
+		if !ok {
+			break
+		}
+		node = child
+		if node.t != nil {
+			matched = node.t
+			extra = i + 1
+		}
 	}
-	if secondNode.t == nil {
-		return false, fmt.Errorf("%w: matched leaf node of symbolic tree has no token", ErrLexer)
+	if matched == nil {
+		return false, nil
 	}
-	item = &Item{start, *secondNode.t, runeSequences[*secondNode.t]}
-	if err := l.skip(1); err != nil {
+
+	if err := l.skip(extra); err != nil {
 		return false, err
 	}
-	if len(secondNode.children) == 0 {
-		l.sendItem(item)
-		return true, nil
-	}
+	l.sendItem(&Item{start, *matched, runeSequences[*matched]})
+	return true, nil
+}
 
-	thirdNode, ok := node.children[nextRunes[1]]
-	if !ok {
-		l.sendItem(item)
-		return true, nil
-	}
-	if thirdNode.t == nil {
-		return false, fmt.Errorf("%w: matched leaf node of symbolic tree has no token", ErrLexer)
-	}
-	l.sendItem(&Item{start, *thirdNode.t, runeSequences[*thirdNode.t]})
-	if err := l.skip(1); err != nil {
+// skipComment consumes a line or block comment when the '/' just read starts
+// one. Line comments stop before the newline so it is still sent.
+func (l *Lexer) skipComment(start Position) (bool, error) {
+	next, err := l.peek()
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+
+	switch next {
+	case '/':
+		for {
+			r, err := l.peek()
+			if err != nil {
+				return false, err
+			}
+			if r == '\n' || r == EOF_RUNE {
+				return true, nil
+			}
+			if _, err := l.next(); err != nil {
+				return false, err
+			}
+		}
+	case '*':
+		if err := l.skip(1); err != nil {
+			return false, err
+		}
+		var prev rune
+		for {
+			r, err := l.next()
+			if err != nil {
+				return false, err
+			}
+			if r == EOF_RUNE {
+				l.sendItem(&Item{start, ILLEGAL, "/*"})
+				return true, nil
+			}
+			if prev == '*' && r == '/' {
+				return true, nil
+			}
+			prev = r
+		}
+	}
+	return false, nil
 }
 
 func (l *Lexer) collectSymbol(start Position) error {
@@ -214,7 +255,7 @@ func (l *Lexer) collectSymbol(start Position) error {
 		if r == EOF_RUNE {
 			break
 		}
-		if r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) {
+		if isIdentRune(r) {
 			seq.WriteRune(r)
 			continue
 		}
@@ -228,7 +269,15 @@ func (l *Lexer) collectSymbol(start Position) error {
 	return nil
 }
 
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
 func (l *Lexer) itemFromAlphanum(startPos Position, initial rune) (*Item, error) {
+	if !isIdentRune(initial) {
+		return &Item{startPos, ILLEGAL, string(initial)}, nil
+	}
+
 	var seq strings.Builder
 	seq.WriteRune(initial)
 
@@ -259,7 +308,7 @@ func (l *Lexer) itemFromAlphanum(startPos Position, initial rune) (*Item, error)
 			return collectSequence(), nil
 		}
 
-		if r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) {
+		if isIdentRune(r) {
 			seq.WriteRune(r)
 			continue
 		}
@@ -281,10 +330,11 @@ func (l *Lexer) collectTemplateLiteral(start Position) error {
 			return err
 		}
 		if r == EOF_RUNE {
-			// TODO: illegal
+			l.sendItem(&Item{start, ILLEGAL, "`" + seq.String()})
+			return nil
 		}
 		seq.WriteRune(r)
-		if r == '`' && seq.Len() > 1 {
+		if r == '`' {
 			break
 		}
 	}
@@ -301,29 +351,26 @@ func (l *Lexer) collectStructuredLiteral(start Position) error {
 			return err
 		}
 		if r == EOF_RUNE {
-			// todo: illegal
+			l.sendItem(&Item{start, ILLEGAL, "#" + seq.String()})
+			return nil
 		}
 		seq.WriteRune(r)
 		if unicode.IsLetter(r) {
 			continue
 		}
-		if !(r == '{' || r == '(' || r == '|') {
-			// todo: illegal
-		}
 		delim = r
 		break
 	}
 
-	if delim == 0 {
-		return fmt.Errorf("%w: expected matched delimeter", ErrLexer)
-	}
-
 	matchedDelim, ok := structuredLiteralDelimiters[delim]
 	if !ok {
-		return fmt.Errorf("%w: expected to have a matched delimeter for %s", ErrLexer, string(delim))
+		l.sendItem(&Item{start, ILLEGAL, "#" + seq.String()})
+		return nil
 	}
 
 	delimOffset := 1
+	// delimiters in double-quoted strings do not count
+	inString, escaped := false, false
 
 	for {
 		r, err := l.next()
@@ -331,9 +378,22 @@ func (l *Lexer) collectStructuredLiteral(start Position) error {
 			return err
 		}
 		if r == EOF_RUNE {
-			// TODO: illegal
+			l.sendItem(&Item{start, ILLEGAL, "#" + seq.String()})
+			return nil
 		}
 		seq.WriteRune(r)
+		switch {
+		case escaped:
+			escaped = false
+			continue
+		case inString:
+			escaped = r == '\\'
+			inString = r != '"'
+			continue
+		case r == '"':
+			inString = true
+			continue
+		}
 		if r == delim {
 			delimOffset++
 		}
@@ -350,26 +410,53 @@ func (l *Lexer) collectStructuredLiteral(start Position) error {
 
 func (l *Lexer) collectStringLiteral(start Position) error {
 	var seq strings.Builder
-	var prevRune rune
+	escaped := false
 
 	for {
 		r, err := l.next()
 		if err != nil {
 			return err
 		}
-		if r == EOF_RUNE {
-			// TODO: illegal
+		if r == EOF_RUNE || r == '\n' {
+			if r == '\n' {
+				if err := l.backup(r); err != nil {
+					return err
+				}
+			}
+			l.sendItem(&Item{start, ILLEGAL, "\"" + seq.String()})
+			return nil
 		}
 		seq.WriteRune(r)
-		if r == '"' && prevRune != '\\' && seq.Len() > 1 {
+		if r == '"' && !escaped {
 			break
 		}
-		prevRune = r
+		escaped = r == '\\' && !escaped
 	}
 	l.sendItem(&Item{start, STRING, "\"" + seq.String()})
 	return nil
 }
 
+// itemFromIndex collects the decimal digits of a tuple index such as the 1 of
+// t.1, so that t.1.0 selects twice instead of ending in the float 1.0.
+func (l *Lexer) itemFromIndex(start Position, initial rune) (*Item, error) {
+	var seq strings.Builder
+	seq.WriteRune(initial)
+	for {
+		r, err := l.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !unicode.IsDigit(r) {
+			break
+		}
+		if _, err := l.next(); err != nil {
+			return nil, err
+		}
+		seq.WriteRune(r)
+	}
+	return &Item{start, INT, seq.String()}, nil
+}
+
 func (l *Lexer) itemFromNumeric(start Position, initial rune) (*Item, error) {
 	var item *Item
 	var seq strings.Builder
@@ -423,6 +510,7 @@ func (l *Lexer) itemFromNumeric(start Position, initial rune) (*Item, error) {
 			if err := writeWhileMatch(unicode.IsDigit); err != nil {
 				return nil, err
 			}
+		case next == EOF_RUNE:
 		default:
 			if err := l.backup(next); err != nil {
 				return nil, err
@@ -432,6 +520,7 @@ func (l *Lexer) itemFromNumeric(start Position, initial rune) (*Item, error) {
 	} else {
 		fractional := false
 		exponent := false
+		prev := initial
 		for {
 			r, err := l.next()
 			if err != nil {
@@ -440,6 +529,11 @@ func (l *Lexer) itemFromNumeric(start Position, initial rune) (*Item, error) {
 			if r == EOF_RUNE {
 				break
 			}
+			if (r == '+' || r == '-') && prev == 'e' {
+				seq.WriteRune(r)
+				prev = r
+				continue
+			}
 			if r == '.' || r == 'e' || unicode.IsDigit(r) {
 				if (fractional && r == '.') || (exponent && r == 'e') {
 					return &Item{l.pos, ILLEGAL, string(r)}, nil
@@ -452,6 +546,7 @@ func (l *Lexer) itemFromNumeric(start Position, initial rune) (*Item, error) {
 				if r == 'e' {
 					exponent = true
 				}
+				prev = r
 			} else {
 				if err := l.backup(r); err != nil {
 					return nil, err
@@ -18,6 +18,9 @@ func init() {
 
 	for _, t := range keys {
 		runeSet := runeSequences[t]
+		if len(runeSet) > maxRuneSequenceLen {
+			maxRuneSequenceLen = len(runeSet)
+		}
 		runeSequenceTree.insert(
 			t,
 			rune(runeSet[0]),
@@ -51,6 +54,7 @@ const (
 	STRING
 	TEMPLATE
 	STRUCTURED
+	JSX
 	INT
 	FLOAT
 	BOOL
@@ -115,6 +119,8 @@ const (
 	ARROW
 	OMIT
 	SPREAD
+	TILDE
+	QUESTION
 
 	// Keywords
 	PACKAGE
@@ -132,7 +138,9 @@ const (
 	CONTINUE
 	BREAK
 	FOR
+	IN
 	MATCH
+	WITH
 )
 
 func (t Token) String() string {
@@ -162,6 +170,7 @@ var tokenNames = [...]string{
 	STRING:     "STRING",
 	TEMPLATE:   "TEMPLATE",
 	STRUCTURED: "STRUCTURED",
+	JSX:        "JSX",
 	INT:        "INT",
 	FLOAT:      "FLOAT",
 	BOOL:       "BOOL",
@@ -226,6 +235,8 @@ var tokenNames = [...]string{
 	ARROW:         "ARROW",
 	OMIT:          "OMIT",
 	SPREAD:        "SPREAD",
+	TILDE:         "TILDE",
+	QUESTION:      "QUESTION",
 
 	// Keywords
 	PACKAGE:   "PACKAGE",
@@ -243,11 +254,16 @@ var tokenNames = [...]string{
 	CONTINUE:  "CONTINUE",
 	BREAK:     "BREAK",
 	FOR:       "FOR",
+	IN:        "IN",
 	MATCH:     "MATCH",
+	WITH:      "WITH",
 }
 
 var runeSequenceTree runeTree
 
+// maxRuneSequenceLen is the length of the longest entry in runeSequences.
+var maxRuneSequenceLen int
+
 var runeSequences = map[Token]string{
 	ADD:  "+",
 	SUB:  "-",
@@ -302,6 +318,8 @@ var runeSequences = map[Token]string{
 	ARROW:         "=>",
 	OMIT:          "_",
 	SPREAD:        "..",
+	TILDE:         "~",
+	QUESTION:      "?",
 }
 
 type runeTree map[rune]runeTreeNode
@@ -350,7 +368,9 @@ var reserved = map[string]Token{
 	"continue":  CONTINUE,
 	"break":     BREAK,
 	"for":       FOR,
+	"in":        IN,
 	"match":     MATCH,
+	"with":      WITH,
 
 	"symbol": T_SYMBOL,
 	"string": T_STRING,
@@ -364,6 +384,13 @@ var reserved = map[string]Token{
 	"record": T_RECORD,
 }
 
+// IsReserved reports whether word is a keyword, a built-in type name or a
+// boolean literal, which identifiers cannot be.
+func IsReserved(word string) bool {
+	_, ok := reserved[word]
+	return ok || word == boolLiteral[0] || word == boolLiteral[1]
+}
+
 // ReservedWord gets the alphanum string reserved for the given token, if it exists.
 // Otherwise an empty string is returned (not all tokens are reserved words).
 func ReservedWord(t Token) string {
@@ -375,6 +402,12 @@ func ReservedWord(t Token) string {
 	return ""
 }
 
+// Sequence gets the symbolic rune sequence for the given token, if it exists.
+// Otherwise an empty string is returned.
+func Sequence(t Token) string {
+	return runeSequences[t]
+}
+
 var structuredLiteralDelimiters = map[rune]rune{
 	'{': '}',
 	'(': ')',
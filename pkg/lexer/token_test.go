@@ -10,7 +10,7 @@ func TestRuneSequenceTree(t *testing.T) {
 	tree := runeSequenceTree
 
 	assert.NotNil(t, tree)
-	assert.Len(t, tree, 22)
+	assert.Len(t, tree, 25)
 
 	// test single character token
 	openParen, ok := tree['(']
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// parseArrayTypeOrComp parses an operand starting with '[', which is either
+// an array or slice type or a list comprehension.
+func (p *parser) parseArrayTypeOrComp() ast.Expr {
+	lbrack := p.expect(lexer.OPEN_BRACKET)
+	if p.tok == lexer.CLOSE_BRACKET {
+		return p.finishArrayType(lbrack, nil)
+	}
+
+	p.exprLev++
+	x := p.parseRhs()
+	if p.tok != lexer.FOR {
+		p.exprLev--
+		return p.finishArrayType(lbrack, x)
+	}
+	comp := &ast.ListComp{Lbrack: lbrack, Elt: x, Clauses: p.parseCompClauses()}
+	p.exprLev--
+	comp.Rbrack = p.expectClosing(lexer.CLOSE_BRACKET, "list comprehension")
+	return comp
+}
+
+// parseMapComp parses the clauses of a map comprehension whose opening brace
+// and `key => value` element have been parsed.
+func (p *parser) parseMapComp(lbrace lexer.Position, elt ast.Expr) *ast.MapComp {
+	comp := &ast.MapComp{Lbrace: lbrace}
+	if kv, ok := elt.(*ast.KeyValueExpr); ok {
+		comp.Key, comp.Arrow, comp.Value = kv.Key, kv.Arrow, kv.Value
+	} else {
+		p.errorExpected(elt.Pos(), "'key => value' in map comprehension")
+		comp.Key, comp.Value = elt, &ast.BadExpr{From: elt.Pos()}
+	}
+	comp.Clauses = p.parseCompClauses()
+	comp.Rbrace = p.expectClosing(lexer.CLOSE_BRACE, "map comprehension")
+	return comp
+}
+
+// parseCompClauses parses one or more `for vars in x if cond` clauses.
+func (p *parser) parseCompClauses() []*ast.CompClause {
+	var list []*ast.CompClause
+	for p.tok == lexer.FOR {
+		c := &ast.CompClause{For: p.pos}
+		p.next()
+		c.Vars = p.parseIdentList()
+		if len(c.Vars) > 2 {
+			p.error(c.Vars[2].Pos(), "comprehension permits at most two iteration variables")
+		}
+		c.In = p.expect(lexer.IN)
+		c.X = p.parseExpr()
+		if p.tok == lexer.IF {
+			c.If = p.pos
+			p.next()
+			c.Cond = p.parseExpr()
+		}
+		list = append(list, c)
+	}
+	return list
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+func (p *parser) parseDecl() ast.Decl {
+	switch p.tok {
+	case lexer.VAR, lexer.CONST:
+		return p.parseGenDecl(p.tok, p.parseValueSpec)
+	case lexer.TYPE:
+		return p.parseGenDecl(p.tok, p.parseTypeSpec)
+	case lexer.FUNC:
+		return p.parseFuncDecl(nil)
+	case lexer.STRUCTURED:
+		if strings.HasPrefix(p.lit, "#[") {
+			attrs := p.parseAttributes()
+			switch p.tok {
+			case lexer.VAR, lexer.CONST, lexer.TYPE:
+				parseSpec := p.parseValueSpec
+				if p.tok == lexer.TYPE {
+					parseSpec = p.parseTypeSpec
+				}
+				decl := p.parseGenDecl(p.tok, parseSpec)
+				decl.Attrs = attrs
+				return decl
+			}
+			return p.parseFuncDecl(attrs)
+		}
+	}
+
+	pos := p.pos
+	p.errorExpected(pos, "declaration")
+	p.next()
+	p.advance(declStart)
+	return &ast.BadDecl{From: pos}
+}
+
+type specParser func(keyword lexer.Token) ast.Spec
+
+func (p *parser) parseGenDecl(keyword lexer.Token, parseSpec specParser) *ast.GenDecl {
+	decl := &ast.GenDecl{TokPos: p.pos, Tok: keyword}
+	p.next()
+
+	if p.tok == lexer.OPEN_PAREN {
+		decl.Lparen = p.pos
+		p.next()
+		for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+			decl.Specs = append(decl.Specs, parseSpec(keyword))
+			p.expectSemi()
+		}
+		decl.Rparen = p.expect(lexer.CLOSE_PAREN)
+		p.expectSemi()
+		return decl
+	}
+
+	decl.Specs = append(decl.Specs, parseSpec(keyword))
+	p.expectSemi()
+	return decl
+}
+
+func (p *parser) parseValueSpec(keyword lexer.Token) ast.Spec {
+	spec := &ast.ValueSpec{Names: p.parseIdentList()}
+	if p.tok != lexer.ASSIGN && p.tok != lexer.SEMI && p.tok != lexer.CLOSE_PAREN {
+		spec.Type = p.parseType()
+	}
+	if p.tok == lexer.ASSIGN {
+		p.next()
+		spec.Values = p.parseExprList()
+	}
+
+	switch {
+	case keyword == lexer.CONST && spec.Values == nil:
+		p.error(p.pos, "missing constant value")
+	case len(spec.Values) > 1 && len(spec.Values) != len(spec.Names):
+		// a single value may be a tuple destructured by the names
+		p.error(spec.Values[0].Pos(), "assignment mismatch: number of names and values differ")
+	}
+	return spec
+}
+
+func (p *parser) parseTypeSpec(lexer.Token) ast.Spec {
+	spec := &ast.TypeSpec{Name: p.parseIdent()}
+	if p.tok == lexer.OPEN_BRACKET && p.isTypeParamList() {
+		spec.TypeParams = p.parseTypeParams()
+	}
+	spec.Type = p.parseType()
+	return spec
+}
+
+// parseAttributes parses the attributes before a declaration, each on its
+// own line. The lexer reads `#[name]` and `#[name("arg", ...)]` as
+// structured literals without a kind.
+func (p *parser) parseAttributes() []*ast.Attribute {
+	var attrs []*ast.Attribute
+	for p.tok == lexer.STRUCTURED && strings.HasPrefix(p.lit, "#[") {
+		name, args, ok := splitAttribute(strings.TrimSpace(p.lit[2 : len(p.lit)-1]))
+		if !ok {
+			p.error(p.pos, fmt.Sprintf("invalid attribute %s", p.lit))
+		}
+		attrs = append(attrs, &ast.Attribute{Hash: p.pos, Name: name, Args: args})
+		p.next()
+		p.expectSemi()
+	}
+	return attrs
+}
+
+// splitAttribute splits the body of an attribute into its name and the
+// values of its string arguments, if any, and reports whether it is well
+// formed.
+func splitAttribute(s string) (name string, args []string, ok bool) {
+	name, rest, found := strings.Cut(s, "(")
+	name = strings.TrimSpace(name)
+	if !found {
+		return name, nil, isIdentifier(name)
+	}
+	rest = strings.TrimSpace(rest)
+	for !strings.HasPrefix(rest, ")") {
+		if !strings.HasPrefix(rest, `"`) {
+			return name, nil, false
+		}
+		end := 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return name, nil, false
+		}
+		arg, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return name, nil, false
+		}
+		args = append(args, arg)
+		rest = strings.TrimSpace(rest[end+1:])
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, ")") {
+			return name, nil, false
+		}
+	}
+	return name, args, isIdentifier(name) && args != nil && rest == ")"
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (p *parser) parseFuncDecl(attrs []*ast.Attribute) *ast.FuncDecl {
+	decl := &ast.FuncDecl{Attrs: attrs}
+	pos := p.expect(lexer.FUNC)
+
+	if p.tok == lexer.OPEN_PAREN {
+		decl.Recv = p.parseParameters(false)
+		switch decl.Recv.NumFields() {
+		case 0:
+			p.error(decl.Recv.Opening, "method has no receiver")
+		case 1:
+		default:
+			p.error(decl.Recv.Opening, "method has multiple receivers")
+		}
+	}
+
+	decl.Name = p.parseIdent()
+	var tparams *ast.FieldList
+	if p.tok == lexer.OPEN_BRACKET {
+		tparams = p.parseTypeParams()
+		if decl.Recv != nil {
+			p.error(tparams.Opening, "methods cannot have type parameters")
+		}
+	}
+	decl.Type = p.parseSignature(pos)
+	decl.Type.TypeParams = tparams
+
+	if p.tok == lexer.OPEN_BRACE {
+		decl.Body = p.parseBody()
+	}
+	p.expectSemi()
+	return decl
+}
@@ -0,0 +1,307 @@
+package parser
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+func (p *parser) parseEnumType() *ast.EnumType {
+	typ := &ast.EnumType{Enum: p.expect(lexer.T_ENUM)}
+
+	if p.tok == lexer.OPEN_PAREN {
+		lparen := p.pos
+		p.next()
+		for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+			typ.Backing = append(typ.Backing, p.parseType())
+			if !p.atComma("enum backing type", lexer.CLOSE_PAREN) {
+				break
+			}
+			p.next()
+		}
+		p.expect(lexer.CLOSE_PAREN)
+		if len(typ.Backing) == 0 {
+			p.error(lparen, "missing enum backing type")
+		}
+	}
+
+	typ.Lbrace = p.expect(lexer.OPEN_BRACE)
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		typ.Variants = append(typ.Variants, p.parseEnumVariant())
+		p.expectSemi()
+	}
+	typ.Rbrace = p.expect(lexer.CLOSE_BRACE)
+
+	p.checkEnum(typ)
+	return typ
+}
+
+func (p *parser) parseEnumVariant() *ast.EnumVariant {
+	variant := &ast.EnumVariant{Name: p.parseIdent()}
+	if p.tok == lexer.OPEN_PAREN {
+		variant.Payload = p.parseParameters(false)
+	}
+	if p.tok == lexer.ASSIGN {
+		p.next()
+		variant.Value = p.parseRhs()
+	}
+	return variant
+}
+
+// checkEnum reports variants that repeat the name or value of an earlier
+// variant, and values or payloads that do not fit the backing type. Values are
+// only checked when they are literals; anything else is left to the type
+// checker.
+func (p *parser) checkEnum(typ *ast.EnumType) {
+	if len(typ.Variants) == 0 {
+		p.error(typ.Lbrace, "enum type must have at least one variant")
+		return
+	}
+
+	names := make(map[string]bool)
+	values := make(map[string]*ast.EnumVariant)
+	next := constant.MakeInt64(0)
+
+	for _, v := range typ.Variants {
+		name := v.Name.Name
+		if names[name] {
+			p.error(v.Name.Pos(), fmt.Sprintf("duplicate enum variant %s", name))
+		}
+		names[name] = true
+
+		if typ.Backing == nil {
+			if v.Value != nil {
+				p.error(v.Value.Pos(), fmt.Sprintf("enum variant %s has a value but the enum has no backing type", name))
+			}
+			continue
+		}
+		if v.Payload != nil {
+			p.error(v.Payload.Opening, fmt.Sprintf("enum variant %s cannot have a payload in an enum with a backing type", name))
+			continue
+		}
+
+		key, ok := p.enumValueKey(typ, v, next)
+		if !ok {
+			continue
+		}
+		if first, dup := values[key]; dup {
+			p.error(v.Name.Pos(), fmt.Sprintf("duplicate value %s for enum variants %s and %s", displayKey(key), first.Name.Name, name))
+		} else {
+			values[key] = v
+		}
+		if val, isInt := intValue(key); isInt {
+			next = constant.BinaryOp(val, token.ADD, constant.MakeInt64(1))
+		}
+	}
+}
+
+// backingName returns the name of a predeclared backing type, or "" for any
+// other type.
+func backingName(x ast.Expr) string {
+	if ident, ok := x.(*ast.Ident); ok {
+		switch ident.Name {
+		case "int", "float", "string", "bool", "symbol":
+			return ident.Name
+		}
+	}
+	return ""
+}
+
+// enumValueKey returns a key identifying the value of variant v, which is
+// equal for equal values. The bool result is false when the value is not a
+// literal or does not fit the backing type, which has then been reported.
+func (p *parser) enumValueKey(typ *ast.EnumType, v *ast.EnumVariant, next constant.Value) (string, bool) {
+	if v.Value == nil {
+		if len(typ.Backing) == 1 {
+			switch backingName(typ.Backing[0]) {
+			case "int":
+				return "int:" + next.ExactString(), true
+			case "string":
+				return "string:" + constant.MakeString(v.Name.Name).ExactString(), true
+			case "":
+				return "", false
+			}
+		}
+		p.error(v.Name.Pos(), fmt.Sprintf("enum variant %s needs a value of type %s", v.Name.Name, backingString(typ.Backing)))
+		return "", false
+	}
+
+	if len(typ.Backing) == 1 {
+		return p.literalKey(typ.Backing[0], v, v.Value)
+	}
+
+	tuple, ok := unparen(v.Value).(*ast.TupleLit)
+	if !ok || len(tuple.Elts) != len(typ.Backing) {
+		if _, isLit := literalValue(v.Value); isLit || ok {
+			p.error(v.Value.Pos(), fmt.Sprintf("enum variant %s value %s does not match backing type %s", v.Name.Name, ast.ExprString(v.Value), backingString(typ.Backing)))
+		}
+		return "", false
+	}
+
+	keys := make([]string, len(tuple.Elts))
+	for i, elt := range tuple.Elts {
+		key, ok := p.literalKey(typ.Backing[i], v, elt)
+		if !ok {
+			return "", false
+		}
+		keys[i] = key
+	}
+	return "(" + strings.Join(keys, ", ") + ")", true
+}
+
+// literalKey checks a literal value x of variant v against the backing type
+// and returns its key.
+func (p *parser) literalKey(backing ast.Expr, v *ast.EnumVariant, x ast.Expr) (string, bool) {
+	name := backingName(backing)
+	lit, ok := literalValue(x)
+	if name == "" || !ok {
+		return "", false
+	}
+
+	fits := false
+	switch name {
+	case "int":
+		fits = lit.kind == lexer.INT
+	case "float":
+		fits = lit.kind == lexer.INT || lit.kind == lexer.FLOAT
+		if fits {
+			lit.value = constant.ToFloat(lit.value)
+		}
+	case "string":
+		fits = lit.kind == lexer.STRING
+	case "bool":
+		fits = lit.kind == lexer.BOOL
+	case "symbol":
+		fits = lit.kind == lexer.SYMBOL
+	}
+	if !fits {
+		p.error(x.Pos(), fmt.Sprintf("enum variant %s value %s does not match backing type %s", v.Name.Name, ast.ExprString(x), name))
+		return "", false
+	}
+	return name + ":" + lit.value.ExactString(), true
+}
+
+type literal struct {
+	kind  lexer.Token
+	value constant.Value
+}
+
+// literalValue evaluates a literal, possibly negated or parenthesized.
+func literalValue(x ast.Expr) (literal, bool) {
+	switch x := unparen(x).(type) {
+	case *ast.BasicLit:
+		switch x.Kind {
+		case lexer.INT:
+			v := constant.MakeFromLiteral(x.Value, token.INT, 0)
+			return literal{x.Kind, v}, v.Kind() != constant.Unknown
+		case lexer.FLOAT:
+			v := constant.MakeFromLiteral(x.Value, token.FLOAT, 0)
+			return literal{x.Kind, v}, v.Kind() != constant.Unknown
+		case lexer.STRING:
+			v := constant.MakeFromLiteral(x.Value, token.STRING, 0)
+			return literal{x.Kind, v}, v.Kind() != constant.Unknown
+		case lexer.BOOL:
+			return literal{x.Kind, constant.MakeBool(x.Value == "true")}, true
+		case lexer.SYMBOL:
+			return literal{x.Kind, constant.MakeString(x.Value)}, true
+		}
+	case *ast.UnaryExpr:
+		if x.Op != lexer.SUB && x.Op != lexer.ADD {
+			return literal{}, false
+		}
+		lit, ok := literalValue(x.X)
+		if !ok || (lit.kind != lexer.INT && lit.kind != lexer.FLOAT) {
+			return literal{}, false
+		}
+		if x.Op == lexer.SUB {
+			lit.value = constant.UnaryOp(token.SUB, lit.value, 0)
+		}
+		return lit, true
+	}
+	return literal{}, false
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		paren, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = paren.X
+	}
+}
+
+func intValue(key string) (constant.Value, bool) {
+	digits, ok := strings.CutPrefix(key, "int:")
+	if !ok {
+		return nil, false
+	}
+	v := constant.MakeFromLiteral(digits, token.INT, 0)
+	return v, v.Kind() == constant.Int
+}
+
+// displayKey strips the type prefixes from a value key.
+func displayKey(key string) string {
+	for _, name := range []string{"int:", "float:", "string:", "bool:", "symbol:"} {
+		key = strings.ReplaceAll(key, name, "")
+	}
+	return key
+}
+
+func backingString(backing []ast.Expr) string {
+	if len(backing) == 1 {
+		return ast.ExprString(backing[0])
+	}
+	parts := make([]string, len(backing))
+	for i, x := range backing {
+		parts[i] = ast.ExprString(x)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// checkEnumMethods reports methods declared on an enum of the same file whose
+// name is also the name of one of its variants, since both are selected as
+// Color.Red.
+func (p *parser) checkEnumMethods(file *ast.File) {
+	variants := make(map[string]map[string]bool)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != lexer.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			enum, ok := ts.Type.(*ast.EnumType)
+			if !ok {
+				continue
+			}
+			names := make(map[string]bool)
+			for _, v := range enum.Variants {
+				names[v.Name.Name] = true
+			}
+			variants[ts.Name.Name] = names
+		}
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+			continue
+		}
+		recv, ok := fn.Recv.List[0].Type.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if variants[recv.Name][fn.Name.Name] {
+			p.error(fn.Name.Pos(), fmt.Sprintf("method %s.%s conflicts with enum variant of the same name", recv.Name, fn.Name.Name))
+		}
+	}
+}
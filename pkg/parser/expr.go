@@ -0,0 +1,349 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// precedence returns the binding power of a binary operator, or 0 if t is not
+// one. Operators share Go's five precedence levels.
+func precedence(t lexer.Token) int {
+	switch t {
+	case lexer.OR:
+		return 1
+	case lexer.AND:
+		return 2
+	case lexer.EQ, lexer.NEQ, lexer.LT, lexer.LTEQ, lexer.GT, lexer.GTEQ:
+		return 3
+	case lexer.ADD, lexer.SUB, lexer.BIT_OR, lexer.BIT_NOT:
+		return 4
+	case lexer.MULT, lexer.DIV, lexer.MOD, lexer.BIT_LEFT, lexer.BIT_RIGHT, lexer.BIT_AND, lexer.BIT_CLEAR:
+		return 5
+	}
+	return 0
+}
+
+func (p *parser) parseExpr() ast.Expr {
+	return p.parseBinaryExpr(1)
+}
+
+// parseRhs parses an expression in a position where composite literals are
+// always allowed.
+func (p *parser) parseRhs() ast.Expr {
+	old := p.exprLev
+	if p.exprLev < 0 {
+		p.exprLev = 0
+	}
+	x := p.parseExpr()
+	p.exprLev = old
+	return x
+}
+
+func (p *parser) parseExprList() []ast.Expr {
+	list := []ast.Expr{p.parseExpr()}
+	for p.tok == lexer.COMMA {
+		p.next()
+		list = append(list, p.parseExpr())
+	}
+	return list
+}
+
+func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
+	x := p.parseUnaryExpr()
+	for {
+		prec := precedence(p.tok)
+		if prec < prec1 {
+			return x
+		}
+		op, pos := p.tok, p.pos
+		p.next()
+		y := p.parseBinaryExpr(prec + 1)
+		x = &ast.BinaryExpr{X: x, OpPos: pos, Op: op, Y: y}
+	}
+}
+
+func (p *parser) parseUnaryExpr() ast.Expr {
+	switch p.tok {
+	case lexer.ADD, lexer.SUB, lexer.NOT, lexer.BIT_NOT:
+		pos, op := p.pos, p.tok
+		p.next()
+		x := p.parseUnaryExpr()
+		return &ast.UnaryExpr{OpPos: pos, Op: op, X: x}
+	}
+	return p.parsePrimaryExpr()
+}
+
+func (p *parser) parsePrimaryExpr() ast.Expr {
+	x := p.parseOperand()
+	for {
+		switch p.tok {
+		case lexer.ACCESS:
+			p.next()
+			if p.tok == lexer.INT {
+				x = p.parseTupleIndex(x)
+				continue
+			}
+			x = &ast.SelectorExpr{X: x, Sel: p.parseIdent()}
+		case lexer.OPEN_BRACKET:
+			x = p.parseIndex(x)
+		case lexer.OPEN_PAREN:
+			x = p.parseCall(x)
+		case lexer.WITH:
+			x = p.parseWithExpr(x)
+		case lexer.OPEN_BRACE:
+			if !isLiteralType(x) || (p.exprLev < 0 && (isTypeName(x) || isGenericType(x))) {
+				return x
+			}
+			x = p.parseCompositeLit(x)
+		default:
+			return x
+		}
+	}
+}
+
+func (p *parser) parseOperand() ast.Expr {
+	switch p.tok {
+	case lexer.IDENT, lexer.OMIT:
+		return p.parseIdent()
+
+	case lexer.INT, lexer.FLOAT, lexer.STRING, lexer.TEMPLATE, lexer.STRUCTURED,
+		lexer.SYMBOL, lexer.BOOL, lexer.NIL:
+		if p.tok == lexer.STRUCTURED && structuredKind(p.lit) == "json" {
+			return p.parseJSONLit()
+		}
+		lit := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+		p.next()
+		return lit
+
+	case lexer.JSX:
+		return p.parseJSX()
+
+	case lexer.OPEN_PAREN:
+		if p.isArrowFunc() {
+			return p.parseFuncLit()
+		}
+		return p.parseParenOrTuple()
+
+	case lexer.FUNC:
+		pos := p.pos
+		p.next()
+		typ := p.parseSignature(pos)
+		if p.tok != lexer.OPEN_BRACE {
+			return typ
+		}
+		return &ast.FuncLit{Type: typ, Body: p.parseBody()}
+
+	case lexer.MATCH:
+		return p.parseMatchExpr()
+
+	case lexer.OPEN_BRACKET:
+		return p.parseArrayTypeOrComp()
+
+	case lexer.OPEN_BRACE:
+		if p.exprLev >= 0 {
+			x := p.parseCompositeLit(nil)
+			if lit, ok := x.(*ast.CompositeLit); ok {
+				p.error(lit.Lbrace, "missing type in composite literal")
+			}
+			return x
+		}
+	}
+
+	if typ := p.tryType(); typ != nil {
+		return typ
+	}
+
+	pos := p.pos
+	p.errorExpected(pos, "expression")
+	p.advance(stmtStart)
+	return &ast.BadExpr{From: pos}
+}
+
+// isArrowFunc reports whether the current '(' opens the parameter list of an
+// arrow function, which is the case when its matching ')' is followed by '=>'.
+func (p *parser) isArrowFunc() bool {
+	depth := 0
+	for i := p.offset - 1; i < len(p.items); i++ {
+		switch p.items[i].Token {
+		case lexer.OPEN_PAREN, lexer.OPEN_BRACKET, lexer.OPEN_BRACE:
+			depth++
+		case lexer.CLOSE_PAREN, lexer.CLOSE_BRACKET, lexer.CLOSE_BRACE:
+			depth--
+			if depth == 0 {
+				return i+1 < len(p.items) && p.items[i+1].Token == lexer.ARROW
+			}
+		case lexer.EOF:
+			return false
+		}
+	}
+	return false
+}
+
+func (p *parser) parseFuncLit() *ast.FuncLit {
+	lit := &ast.FuncLit{
+		Type: &ast.FuncType{Params: p.parseParameters(true)},
+	}
+	lit.Arrow = p.expect(lexer.ARROW)
+	if p.tok == lexer.OPEN_BRACE {
+		lit.Body = p.parseBody()
+		return lit
+	}
+	lit.Result = p.parseRhs()
+	return lit
+}
+
+// parseBody parses a function body, in which composite literals are always
+// allowed regardless of the enclosing context.
+func (p *parser) parseBody() *ast.BlockStmt {
+	old := p.exprLev
+	p.exprLev = 0
+	body := p.parseBlockStmt()
+	p.exprLev = old
+	return body
+}
+
+func (p *parser) parseParenOrTuple() ast.Expr {
+	lparen := p.expect(lexer.OPEN_PAREN)
+	p.exprLev++
+	x := p.parseRhs()
+	if p.tok != lexer.COMMA {
+		p.exprLev--
+		rparen := p.expectClosing(lexer.CLOSE_PAREN, "parenthesized expression")
+		return &ast.ParenExpr{Lparen: lparen, X: x, Rparen: rparen}
+	}
+
+	tuple := &ast.TupleLit{Lparen: lparen, Elts: []ast.Expr{x}}
+	for p.tok == lexer.COMMA {
+		p.next()
+		if p.tok == lexer.CLOSE_PAREN {
+			break
+		}
+		tuple.Elts = append(tuple.Elts, p.parseRhs())
+	}
+	p.exprLev--
+	tuple.Rparen = p.expectClosing(lexer.CLOSE_PAREN, "tuple literal")
+	return tuple
+}
+
+// parseTupleIndex parses the position following the '.' of t.0.
+func (p *parser) parseTupleIndex(x ast.Expr) *ast.TupleIndexExpr {
+	index := &ast.TupleIndexExpr{X: x, IndexPos: p.pos}
+	n, err := strconv.Atoi(p.lit)
+	if err != nil || (len(p.lit) > 1 && p.lit[0] == '0') {
+		p.error(p.pos, fmt.Sprintf("invalid tuple index %s", p.lit))
+	}
+	index.Index = n
+	p.next()
+	return index
+}
+
+func (p *parser) parseIndex(x ast.Expr) ast.Expr {
+	index := &ast.IndexExpr{X: x, Lbrack: p.expect(lexer.OPEN_BRACKET)}
+	p.exprLev++
+	for p.tok != lexer.CLOSE_BRACKET && p.tok != lexer.EOF {
+		index.Indices = append(index.Indices, p.parseRhs())
+		if !p.atComma("index expression", lexer.CLOSE_BRACKET) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	index.Rbrack = p.expect(lexer.CLOSE_BRACKET)
+	if len(index.Indices) == 0 {
+		p.error(index.Lbrack, "expected operand")
+	}
+	return index
+}
+
+func (p *parser) parseCall(fun ast.Expr) *ast.CallExpr {
+	call := &ast.CallExpr{Fun: fun, Lparen: p.expect(lexer.OPEN_PAREN)}
+	p.exprLev++
+	for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+		call.Args = append(call.Args, p.parseRhs())
+		if !p.atComma("argument list", lexer.CLOSE_PAREN) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	call.Rparen = p.expectClosing(lexer.CLOSE_PAREN, "argument list")
+	return call
+}
+
+// parseCompositeLit parses a composite literal. When typ is nil, as for an
+// elided literal type or a bare brace in an expression, the braces may instead
+// hold a map comprehension.
+func (p *parser) parseCompositeLit(typ ast.Expr) ast.Expr {
+	lit := &ast.CompositeLit{Type: typ, Lbrace: p.expect(lexer.OPEN_BRACE)}
+	old := p.exprLev
+	p.exprLev = 0
+	defer func() { p.exprLev = old }()
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		elt := p.parseElement()
+		if p.tok == lexer.FOR && typ == nil && len(lit.Elts) == 0 {
+			return p.parseMapComp(lit.Lbrace, elt)
+		}
+		lit.Elts = append(lit.Elts, elt)
+		if !p.atComma("composite literal", lexer.CLOSE_BRACE) {
+			break
+		}
+		p.next()
+	}
+	lit.Rbrace = p.expectClosing(lexer.CLOSE_BRACE, "composite literal")
+	return lit
+}
+
+// parseElement parses an element of a composite literal, which may be keyed
+// with `=>` and may omit the type of a nested literal.
+func (p *parser) parseElement() ast.Expr {
+	x := p.parseElementValue()
+	if p.tok == lexer.ARROW {
+		arrow := p.pos
+		p.next()
+		x = &ast.KeyValueExpr{Key: x, Arrow: arrow, Value: p.parseElementValue()}
+	}
+	return x
+}
+
+func (p *parser) parseElementValue() ast.Expr {
+	if p.tok == lexer.OPEN_BRACE {
+		return p.parseCompositeLit(nil)
+	}
+	return p.parseExpr()
+}
+
+// isTypeName reports whether x is a possibly qualified type name.
+func isTypeName(x ast.Expr) bool {
+	switch t := x.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := t.X.(*ast.Ident)
+		return ok
+	}
+	return false
+}
+
+// isGenericType reports whether x may be an instantiated generic type name,
+// as in List[int].
+func isGenericType(x ast.Expr) bool {
+	index, ok := x.(*ast.IndexExpr)
+	return ok && isTypeName(index.X)
+}
+
+// isLiteralType reports whether x can be the type of a composite literal.
+func isLiteralType(x ast.Expr) bool {
+	switch t := x.(type) {
+	case *ast.Ident, *ast.ArrayType, *ast.MapType, *ast.StructType, *ast.RecordType:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := t.X.(*ast.Ident)
+		return ok
+	case *ast.IndexExpr:
+		return isGenericType(t)
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// parseTypeParams parses the bracketed type parameter list of a generic
+// function or type. Consecutive names share a constraint, as in [K, V any].
+func (p *parser) parseTypeParams() *ast.FieldList {
+	list := &ast.FieldList{Opening: p.expect(lexer.OPEN_BRACKET)}
+
+	var pending []*ast.Ident
+	for p.tok != lexer.CLOSE_BRACKET && p.tok != lexer.EOF {
+		name := p.parseIdent()
+		if p.tok == lexer.COMMA || p.tok == lexer.CLOSE_BRACKET {
+			pending = append(pending, name)
+		} else {
+			list.List = append(list.List, &ast.Field{
+				Names: append(pending, name),
+				Type:  p.parseConstraint(),
+			})
+			pending = nil
+		}
+		if !p.atComma("type parameter list", lexer.CLOSE_BRACKET) {
+			break
+		}
+		p.next()
+	}
+	list.Closing = p.expect(lexer.CLOSE_BRACKET)
+
+	switch {
+	case len(pending) > 0:
+		p.error(pending[len(pending)-1].Pos(), "missing type constraint")
+		list.List = append(list.List, &ast.Field{
+			Names: pending,
+			Type:  &ast.BadExpr{From: pending[0].Pos()},
+		})
+	case len(list.List) == 0:
+		p.error(list.Opening, "empty type parameter list")
+	}
+	return list
+}
+
+// parseConstraint parses a type constraint, which is a type or a union of
+// terms such as `~int | float`. A term prefixed with `~` stands for all types
+// with that underlying type.
+func (p *parser) parseConstraint() ast.Expr {
+	return p.finishUnion(p.parseTypeTerm())
+}
+
+// finishUnion parses the remaining terms of a union whose first term is x.
+func (p *parser) finishUnion(x ast.Expr) ast.Expr {
+	for p.tok == lexer.BIT_OR {
+		pos := p.pos
+		p.next()
+		x = &ast.BinaryExpr{X: x, OpPos: pos, Op: lexer.BIT_OR, Y: p.parseTypeTerm()}
+	}
+	return x
+}
+
+func (p *parser) parseTypeTerm() ast.Expr {
+	if p.tok == lexer.TILDE {
+		pos := p.pos
+		p.next()
+		return &ast.UnaryExpr{OpPos: pos, Op: lexer.TILDE, X: p.parseType()}
+	}
+	return p.parseType()
+}
+
+// parseTypeArgs parses the type arguments instantiating the generic type x.
+func (p *parser) parseTypeArgs(x ast.Expr) *ast.IndexExpr {
+	index := &ast.IndexExpr{X: x, Lbrack: p.expect(lexer.OPEN_BRACKET)}
+	for p.tok != lexer.CLOSE_BRACKET && p.tok != lexer.EOF {
+		index.Indices = append(index.Indices, p.parseType())
+		if !p.atComma("type argument list", lexer.CLOSE_BRACKET) {
+			break
+		}
+		p.next()
+	}
+	index.Rbrack = p.expect(lexer.CLOSE_BRACKET)
+	if len(index.Indices) == 0 {
+		p.error(index.Lbrack, "expected type argument list")
+	}
+	return index
+}
+
+// isTypeParamList reports whether the current '[' following the name of a
+// type spec opens a type parameter list rather than an array length. Type
+// parameters begin with a name followed by a constraint or another name,
+// whereas a length is an expression.
+func (p *parser) isTypeParamList() bool {
+	if p.offset+1 >= len(p.items) || p.items[p.offset].Token != lexer.IDENT {
+		return false
+	}
+	next := p.items[p.offset+1].Token
+	return next == lexer.COMMA || next == lexer.TILDE || startsType(next)
+}
+
+// isTypeArgs reports whether the current '[' following a name in a parameter
+// list instantiates that name, as in func(List[int]), rather than starting
+// the type of a parameter with that name, as in (xs []int). Only type
+// arguments are directly followed by the end of the parameter.
+func (p *parser) isTypeArgs() bool {
+	depth := 0
+	for i := p.offset - 1; i < len(p.items); i++ {
+		switch p.items[i].Token {
+		case lexer.OPEN_PAREN, lexer.OPEN_BRACKET, lexer.OPEN_BRACE:
+			depth++
+		case lexer.CLOSE_PAREN, lexer.CLOSE_BRACKET, lexer.CLOSE_BRACE:
+			depth--
+			if depth == 0 {
+				if i+1 >= len(p.items) {
+					return false
+				}
+				next := p.items[i+1].Token
+				return next == lexer.COMMA || next == lexer.CLOSE_PAREN
+			}
+		case lexer.EOF:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// structuredKind returns the kind of the structured literal lit, the
+// letters following its '#', as in json for #json(...).
+func structuredKind(lit string) string {
+	end := 1
+	for end < len(lit) && unicode.IsLetter(rune(lit[end])) {
+		end++
+	}
+	return lit[1:end]
+}
+
+// parseJSONLit parses a #json literal. The JSON value between its
+// delimiters is parsed when the file is, following RFC 8259.
+func (p *parser) parseJSONLit() ast.Expr {
+	lit := &ast.JSONLit{ValuePos: p.pos, Raw: p.lit}
+	p.next()
+
+	// the value is between the delimiters following #json
+	src := []rune(lit.Raw)
+	s := &jsonScanner{runeScanner: newRuneScanner(src[:len(src)-1], lit.ValuePos)}
+	for range "#json(" {
+		s.advance()
+	}
+	s.skipSpace()
+	lit.Value = s.value()
+	if s.err == "" {
+		s.skipSpace()
+		if s.i < len(s.src) {
+			s.fail(fmt.Sprintf("unexpected %q after JSON value", s.src[s.i]))
+		}
+	}
+	if s.err != "" {
+		p.error(s.errPos, "invalid JSON in #json literal: "+s.err)
+		return &ast.BadExpr{From: lit.ValuePos}
+	}
+	return lit
+}
+
+// runeScanner reads the runes of a literal that the lexer collected into a
+// single item, keeping track of their positions.
+type runeScanner struct {
+	src []rune
+	i   int
+	pos lexer.Position // the position of src[i]
+}
+
+// newRuneScanner returns a scanner of src, whose first rune is at pos.
+func newRuneScanner(src []rune, pos lexer.Position) runeScanner {
+	return runeScanner{src: src, pos: lexer.Position{Line: pos.Line, Col: pos.Col}}
+}
+
+// advance moves past the next rune.
+func (s *runeScanner) advance() {
+	if s.src[s.i] == '\n' {
+		s.pos.Line++
+		s.pos.Col = 0
+	} else {
+		s.pos.Col++
+	}
+	s.i++
+}
+
+// next returns the next rune, or -1 at the end of the source.
+func (s *runeScanner) next() rune {
+	if s.i >= len(s.src) {
+		return -1
+	}
+	return s.src[s.i]
+}
+
+// jsonScanner parses the JSON value in src. It stops at the first error.
+type jsonScanner struct {
+	runeScanner
+	err    string
+	errPos lexer.Position
+}
+
+// fail records an error at the next rune unless there is one already.
+func (s *jsonScanner) fail(msg string) {
+	if s.err == "" {
+		s.err, s.errPos = msg, s.pos
+	}
+}
+
+func (s *jsonScanner) skipSpace() {
+	for {
+		switch s.next() {
+		case ' ', '\t', '\n', '\r':
+			s.advance()
+		default:
+			return
+		}
+	}
+}
+
+// found describes the next rune for an error message.
+func (s *jsonScanner) found() string {
+	if r := s.next(); r >= 0 {
+		return strconv.QuoteRune(r)
+	}
+	return "end of literal"
+}
+
+// value parses a value and the white space following it.
+func (s *jsonScanner) value() *ast.JSONValue {
+	v := &ast.JSONValue{Pos: s.pos}
+	switch r := s.next(); {
+	case r == '{':
+		v.Kind = ast.JSONObject
+		s.object(v)
+	case r == '[':
+		v.Kind = ast.JSONArray
+		s.array(v)
+	case r == '"':
+		v.Kind = ast.JSONString
+		v.Text = s.string()
+	case r == '-' || r >= '0' && r <= '9':
+		v.Kind = ast.JSONNumber
+		v.Text = s.number()
+	case r >= 'a' && r <= 'z':
+		start := s.i
+		for r := s.next(); r >= 'a' && r <= 'z'; r = s.next() {
+			s.advance()
+		}
+		switch word := string(s.src[start:s.i]); word {
+		case "true", "false":
+			v.Kind, v.Text = ast.JSONBool, word
+		case "null":
+			v.Kind = ast.JSONNull
+		default:
+			s.err, s.errPos = fmt.Sprintf("expected value, found %s", word), v.Pos
+		}
+	default:
+		s.fail("expected value, found " + s.found())
+	}
+	s.skipSpace()
+	return v
+}
+
+func (s *jsonScanner) object(v *ast.JSONValue) {
+	s.advance()
+	s.skipSpace()
+	if s.next() == '}' {
+		s.advance()
+		return
+	}
+	keys := make(map[string]bool)
+	for s.err == "" {
+		if s.next() != '"' {
+			s.fail("expected string key, found " + s.found())
+			return
+		}
+		pos := s.pos
+		key := s.string()
+		if keys[key] && s.err == "" {
+			s.err, s.errPos = fmt.Sprintf("duplicate key %q in object", key), pos
+			return
+		}
+		keys[key] = true
+		s.skipSpace()
+		if s.next() != ':' {
+			s.fail("expected ':' after object key, found " + s.found())
+			return
+		}
+		s.advance()
+		s.skipSpace()
+		v.Keys = append(v.Keys, key)
+		v.Elts = append(v.Elts, s.value())
+		switch s.next() {
+		case ',':
+			s.advance()
+			s.skipSpace()
+		case '}':
+			s.advance()
+			return
+		default:
+			s.fail("expected ',' or '}' after object member, found " + s.found())
+		}
+	}
+}
+
+func (s *jsonScanner) array(v *ast.JSONValue) {
+	s.advance()
+	s.skipSpace()
+	if s.next() == ']' {
+		s.advance()
+		return
+	}
+	for s.err == "" {
+		v.Elts = append(v.Elts, s.value())
+		switch s.next() {
+		case ',':
+			s.advance()
+			s.skipSpace()
+		case ']':
+			s.advance()
+			return
+		default:
+			s.fail("expected ',' or ']' after array element, found " + s.found())
+		}
+	}
+}
+
+// string parses a string and returns its contents.
+func (s *jsonScanner) string() string {
+	var b strings.Builder
+	s.advance()
+	for {
+		r := s.next()
+		switch {
+		case r < 0:
+			s.fail("unterminated string")
+			return b.String()
+		case r == '"':
+			s.advance()
+			return b.String()
+		case r < ' ':
+			s.fail("control character in string")
+			return b.String()
+		case r != '\\':
+			b.WriteRune(r)
+			s.advance()
+			continue
+		}
+		s.advance()
+		switch e := s.next(); e {
+		case '"', '\\', '/':
+			b.WriteRune(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r := s.hex4()
+			if utf16.IsSurrogate(r) && s.i+2 < len(s.src) && s.src[s.i+1] == '\\' && s.src[s.i+2] == 'u' {
+				s.advance()
+				s.advance()
+				r = utf16.DecodeRune(r, s.hex4())
+			}
+			b.WriteRune(r)
+		default:
+			s.fail("invalid escape in string")
+			return b.String()
+		}
+		s.advance()
+	}
+}
+
+// hex4 parses the four hexadecimal digits following the rune at s.i and
+// leaves s at the last one.
+func (s *jsonScanner) hex4() rune {
+	if s.i+4 >= len(s.src) {
+		s.fail("invalid escape in string")
+		return unicode.ReplacementChar
+	}
+	n, err := strconv.ParseUint(string(s.src[s.i+1:s.i+5]), 16, 16)
+	if err != nil {
+		s.fail("invalid escape in string")
+		return unicode.ReplacementChar
+	}
+	for i := 0; i < 4; i++ {
+		s.advance()
+	}
+	return rune(n)
+}
+
+// number parses a number and returns it as written.
+func (s *jsonScanner) number() string {
+	start := s.i
+	digits := func() bool {
+		n := 0
+		for r := s.next(); r >= '0' && r <= '9'; r = s.next() {
+			s.advance()
+			n++
+		}
+		return n > 0
+	}
+	if s.next() == '-' {
+		s.advance()
+	}
+	ok := true
+	if s.next() == '0' {
+		s.advance()
+	} else {
+		ok = digits()
+	}
+	if ok && s.next() == '.' {
+		s.advance()
+		ok = digits()
+	}
+	if ok && (s.next() == 'e' || s.next() == 'E') {
+		s.advance()
+		if s.next() == '+' || s.next() == '-' {
+			s.advance()
+		}
+		ok = digits()
+	}
+	if !ok {
+		s.fail("invalid number")
+	}
+	return string(s.src[start:s.i])
+}
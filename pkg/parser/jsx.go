@@ -0,0 +1,282 @@
+package parser
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// parseJSX parses the JSX element the lexer collected into the current
+// item. The expressions in its braces are lexed and parsed on their own.
+func (p *parser) parseJSX() ast.Expr {
+	pos := p.pos
+	s := newRuneScanner([]rune(p.lit), pos)
+	p.next()
+
+	x, ok := p.jsxElement(&s)
+	if !ok {
+		return &ast.BadExpr{From: pos}
+	}
+	return x
+}
+
+// jsxElement parses an element starting at its '<'. It reports false if
+// the element is malformed, which is reported.
+func (p *parser) jsxElement(s *runeScanner) (*ast.JSXElement, bool) {
+	el := &ast.JSXElement{Lt: s.pos}
+	s.advance()
+	namePos := s.pos
+	el.Tag = jsxName(s)
+	if el.Tag != "" && !isIntrinsic(el.Tag) {
+		el.Name = jsxComponent(el.Tag, namePos)
+		if el.Name == nil {
+			p.error(namePos, "invalid JSX element name "+el.Tag)
+			return nil, false
+		}
+	}
+
+	// attributes
+	seen := make(map[string]bool)
+	for {
+		skipJSXSpace(s)
+		switch r := s.next(); {
+		case r == '/':
+			s.advance()
+			if s.next() != '>' {
+				p.error(s.pos, "expected '>' after '/' in JSX element")
+				return nil, false
+			}
+			s.advance()
+			return el, true
+		case r == '>':
+			s.advance()
+		case el.Tag != "" && (unicode.IsLetter(r) || r == '_'):
+			attr := &ast.JSXAttr{NamePos: s.pos}
+			attr.Name = jsxName(s)
+			if seen[attr.Name] {
+				p.error(attr.NamePos, "duplicate attribute "+attr.Name+" in JSX element")
+				return nil, false
+			}
+			seen[attr.Name] = true
+			if !p.jsxAttrValue(s, attr) {
+				return nil, false
+			}
+			el.Attrs = append(el.Attrs, attr)
+			continue
+		default:
+			p.error(s.pos, "expected JSX attribute, found "+strconv.QuoteRune(r))
+			return nil, false
+		}
+		break
+	}
+
+	// children and the closing tag
+	for {
+		switch s.next() {
+		case '{':
+			x, ok := p.jsxExpr(s)
+			if !ok {
+				return nil, false
+			}
+			if x != nil {
+				el.Children = append(el.Children, x)
+			}
+		case '<':
+			if s.src[s.i+1] != '/' {
+				child, ok := p.jsxElement(s)
+				if !ok {
+					return nil, false
+				}
+				el.Children = append(el.Children, child)
+				continue
+			}
+			pos := s.pos
+			s.advance()
+			s.advance()
+			skipJSXSpace(s)
+			tag := jsxName(s)
+			skipJSXSpace(s)
+			if tag != el.Tag || s.next() != '>' {
+				p.error(pos, "expected closing tag </"+el.Tag+"> of the JSX element at "+el.Lt.String())
+				return nil, false
+			}
+			s.advance()
+			return el, true
+		default:
+			if text := jsxText(s); text != nil {
+				el.Children = append(el.Children, text)
+			}
+		}
+	}
+}
+
+// jsxAttrValue parses the value of attr, if it has one.
+func (p *parser) jsxAttrValue(s *runeScanner, attr *ast.JSXAttr) bool {
+	skipJSXSpace(s)
+	if s.next() != '=' {
+		return true
+	}
+	s.advance()
+	skipJSXSpace(s)
+	switch quote := s.next(); quote {
+	case '"', '\'':
+		pos := s.pos
+		s.advance()
+		start := s.i
+		for s.next() != quote {
+			s.advance()
+		}
+		value := html.UnescapeString(string(s.src[start:s.i]))
+		s.advance()
+		attr.Value = &ast.BasicLit{ValuePos: pos, Kind: lexer.STRING, Value: strconv.Quote(value)}
+	case '{':
+		pos := s.pos
+		x, ok := p.jsxExpr(s)
+		if !ok {
+			return false
+		}
+		if x == nil {
+			p.error(pos, "empty expression in JSX attribute "+attr.Name)
+			return false
+		}
+		attr.Value = x
+	default:
+		p.error(s.pos, "expected JSX attribute value, found "+strconv.QuoteRune(quote))
+		return false
+	}
+	return true
+}
+
+// jsxExpr parses the expression in braces at the '{' s is at. It returns
+// nil for braces holding nothing but comments.
+func (p *parser) jsxExpr(s *runeScanner) (ast.Expr, bool) {
+	s.advance()
+	items, err := lexer.Items(strings.NewReader(string(s.src[s.i:])))
+	if err != nil {
+		p.error(s.pos, err.Error())
+		return nil, false
+	}
+	// the items are positioned in the expression
+	for i := range items {
+		pos := &items[i].Pos
+		if pos.Line == 1 {
+			pos.Col += s.pos.Col
+		}
+		pos.Line += s.pos.Line - 1
+		pos.MaxPrevCol = 0
+	}
+
+	items0, offset0 := p.items, p.offset
+	pos0, tok0, lit0, exprLev0 := p.pos, p.tok, p.lit, p.exprLev
+	defer func() {
+		p.items, p.offset = items0, offset0
+		p.pos, p.tok, p.lit, p.exprLev = pos0, tok0, lit0, exprLev0
+	}()
+	p.items, p.offset, p.exprLev = insertSemis(items), 0, 0
+	p.next()
+
+	var x ast.Expr
+	if p.tok != lexer.CLOSE_BRACE {
+		errs := len(p.errors)
+		x = p.parseExpr()
+		if p.tok == lexer.SEMI && p.lit == "\n" {
+			p.next()
+		}
+		if p.tok != lexer.CLOSE_BRACE {
+			p.errorExpected(p.pos, "'}' after JSX expression")
+			return nil, false
+		}
+		if len(p.errors) > errs {
+			return nil, false
+		}
+	}
+	for s.pos.Line != p.pos.Line || s.pos.Col != p.pos.Col {
+		s.advance()
+	}
+	s.advance()
+	return x, true
+}
+
+// jsxText parses the text up to the next '{' or '<', and returns nil if
+// nothing is left of it once cleaned up as React does: lines are trimmed,
+// except at the start of the first and the end of the last, and joined by
+// spaces, leaving out empty ones.
+func jsxText(s *runeScanner) *ast.JSXText {
+	pos, start := s.pos, s.i
+	for r := s.next(); r != '{' && r != '<'; r = s.next() {
+		s.advance()
+	}
+	raw := html.UnescapeString(string(s.src[start:s.i]))
+
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	var kept []string
+	for i, line := range lines {
+		line = strings.ReplaceAll(line, "\t", " ")
+		if i > 0 {
+			line = strings.TrimLeft(line, " ")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, " ")
+		}
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return &ast.JSXText{ValuePos: pos, Value: strings.Join(kept, " ")}
+}
+
+// jsxName reads the name of an element or an attribute.
+func jsxName(s *runeScanner) string {
+	start := s.i
+	for r := s.next(); r > 0 && (isIdentRune(r) || strings.ContainsRune("-.:", r)); r = s.next() {
+		s.advance()
+	}
+	return string(s.src[start:s.i])
+}
+
+func skipJSXSpace(s *runeScanner) {
+	for r := s.next(); r > 0 && unicode.IsSpace(r); r = s.next() {
+		s.advance()
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isIntrinsic reports whether the element named tag is an intrinsic element
+// of the host, such as a DOM element, rather than a component.
+func isIntrinsic(tag string) bool {
+	return !strings.Contains(tag, ".") && unicode.IsLower([]rune(tag)[0])
+}
+
+// jsxComponent returns the expression referring to the component named
+// tag, an identifier or a selector, or nil if tag is not one.
+func jsxComponent(tag string, pos lexer.Position) ast.Expr {
+	var x ast.Expr
+	for i, name := range strings.Split(tag, ".") {
+		for j, r := range name {
+			if r == '$' || !isIdentRune(r) || j == 0 && unicode.IsDigit(r) {
+				return nil
+			}
+		}
+		if name == "" || lexer.IsReserved(name) {
+			return nil
+		}
+		id := &ast.Ident{NamePos: pos, Name: name}
+		if i == 0 {
+			x = id
+		} else {
+			x = &ast.SelectorExpr{X: x, Sel: id}
+		}
+		pos.Col += len([]rune(name)) + 1
+	}
+	return x
+}
@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// patternEnd is the set of items at which parsing resumes after a malformed
+// pattern.
+var patternEnd = map[lexer.Token]bool{
+	lexer.ARROW:       true,
+	lexer.COMMA:       true,
+	lexer.SEMI:        true,
+	lexer.CLOSE_PAREN: true,
+	lexer.CLOSE_BRACE: true,
+}
+
+func (p *parser) parseMatchExpr() *ast.MatchExpr {
+	x := &ast.MatchExpr{Match: p.expect(lexer.MATCH)}
+
+	old := p.exprLev
+	p.exprLev = -1
+	x.X = p.parseExpr()
+	p.exprLev = old
+
+	x.Lbrace = p.expect(lexer.OPEN_BRACE)
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		x.Arms = append(x.Arms, p.parseMatchArm())
+	}
+	x.Rbrace = p.expect(lexer.CLOSE_BRACE)
+	return x
+}
+
+// parseMatchArm parses a `pattern if guard => body` arm. Like switch arms,
+// match arms are separated by commas or newlines.
+func (p *parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	p.checkBindings(arm.Pattern, make(map[string]bool))
+	if p.tok == lexer.IF {
+		arm.If = p.pos
+		p.next()
+		arm.Guard = p.parseRhs()
+	}
+	arm.Arrow = p.expect(lexer.ARROW)
+	arm.Body = p.parseArmBody()
+	p.expectArmEnd("match arm")
+	return arm
+}
+
+// parsePattern parses a pattern with optional `|` alternatives.
+func (p *parser) parsePattern() ast.Pattern {
+	x := p.parseAltPattern()
+	if p.tok != lexer.BIT_OR {
+		return x
+	}
+	or := &ast.OrPat{Alts: []ast.Pattern{x}}
+	for p.tok == lexer.BIT_OR {
+		p.next()
+		or.Alts = append(or.Alts, p.parseAltPattern())
+	}
+	return or
+}
+
+func (p *parser) parseAltPattern() ast.Pattern {
+	switch p.tok {
+	case lexer.OMIT:
+		x := &ast.WildcardPat{Underscore: p.pos}
+		p.next()
+		return x
+
+	case lexer.INT, lexer.FLOAT, lexer.STRING, lexer.SYMBOL, lexer.BOOL, lexer.NIL:
+		lit := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+		p.next()
+		return &ast.LitPat{Value: lit}
+
+	case lexer.SUB, lexer.ADD:
+		pos, op := p.pos, p.tok
+		p.next()
+		if p.tok != lexer.INT && p.tok != lexer.FLOAT {
+			p.errorExpected(p.pos, "number")
+			p.advance(patternEnd)
+			return &ast.BadPat{From: pos}
+		}
+		lit := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+		p.next()
+		return &ast.LitPat{Value: &ast.UnaryExpr{OpPos: pos, Op: op, X: lit}}
+
+	case lexer.OPEN_PAREN:
+		return p.parseTuplePat()
+
+	case lexer.OPEN_BRACE:
+		return p.parseRecordPat(nil)
+
+	case lexer.IDENT:
+		return p.parsePathPat()
+	}
+
+	pos := p.pos
+	p.errorExpected(pos, "pattern")
+	if !patternEnd[p.tok] {
+		p.advance(patternEnd)
+	}
+	return &ast.BadPat{From: pos}
+}
+
+// parseTuplePat parses a parenthesized pattern, which is a tuple pattern when
+// it holds more than one element or ends with a comma.
+func (p *parser) parseTuplePat() ast.Pattern {
+	lparen := p.expect(lexer.OPEN_PAREN)
+	var elts []ast.Pattern
+	comma := false
+	for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+		elts = append(elts, p.parsePattern())
+		if !p.atComma("tuple pattern", lexer.CLOSE_PAREN) {
+			break
+		}
+		comma = true
+		p.next()
+	}
+	rparen := p.expectClosing(lexer.CLOSE_PAREN, "tuple pattern")
+	if len(elts) == 1 && !comma {
+		return elts[0]
+	}
+	if len(elts) == 0 {
+		p.error(lparen, "empty tuple pattern")
+	}
+	return &ast.TuplePat{Lparen: lparen, Elts: elts, Rparen: rparen}
+}
+
+// parsePathPat parses a pattern starting with an identifier: a binding, an
+// enum variant or constant, or a typed record pattern.
+func (p *parser) parsePathPat() ast.Pattern {
+	name := p.parseIdent()
+	var path ast.Expr = name
+	for p.tok == lexer.ACCESS {
+		p.next()
+		path = &ast.SelectorExpr{X: path, Sel: p.parseIdent()}
+	}
+
+	switch p.tok {
+	case lexer.OPEN_PAREN:
+		x := &ast.VariantPat{Path: path, Lparen: p.pos}
+		p.next()
+		for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+			x.Args = append(x.Args, p.parsePattern())
+			if !p.atComma("variant pattern", lexer.CLOSE_PAREN) {
+				break
+			}
+			p.next()
+		}
+		x.Rparen = p.expectClosing(lexer.CLOSE_PAREN, "variant pattern")
+		return x
+	case lexer.OPEN_BRACE:
+		return p.parseRecordPat(path)
+	}
+
+	if path == name {
+		return &ast.BindPat{Name: name}
+	}
+	return &ast.VariantPat{Path: path}
+}
+
+func (p *parser) parseRecordPat(typ ast.Expr) *ast.RecordPat {
+	x := &ast.RecordPat{Type: typ, Lbrace: p.expect(lexer.OPEN_BRACE)}
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		f := &ast.FieldPat{Name: p.parseIdent()}
+		if p.tok == lexer.ARROW {
+			f.Arrow = p.pos
+			p.next()
+			f.Pattern = p.parsePattern()
+		}
+		x.Fields = append(x.Fields, f)
+		if !p.atComma("record pattern", lexer.CLOSE_BRACE) {
+			break
+		}
+		p.next()
+	}
+	x.Rbrace = p.expectClosing(lexer.CLOSE_BRACE, "record pattern")
+	return x
+}
+
+// checkBindings reports the fields a record pattern lists twice and the
+// variables x binds more than once or in only some alternatives of an
+// or-pattern. bound collects the variables bound by x. Whether the
+// patterns fit the values they match, and cover them, is for the type
+// checker to tell.
+func (p *parser) checkBindings(x ast.Pattern, bound map[string]bool) {
+	bind := func(name *ast.Ident) {
+		if bound[name.Name] {
+			p.error(name.Pos(), fmt.Sprintf("%s is bound more than once in the same pattern", name.Name))
+		}
+		bound[name.Name] = true
+	}
+
+	switch x := x.(type) {
+	case *ast.BindPat:
+		bind(x.Name)
+
+	case *ast.VariantPat:
+		for _, arg := range x.Args {
+			p.checkBindings(arg, bound)
+		}
+
+	case *ast.TuplePat:
+		for _, elt := range x.Elts {
+			p.checkBindings(elt, bound)
+		}
+
+	case *ast.RecordPat:
+		fields := make(map[string]bool)
+		for _, f := range x.Fields {
+			if fields[f.Name.Name] {
+				p.error(f.Name.Pos(), fmt.Sprintf("duplicate field %s in record pattern", f.Name.Name))
+			}
+			fields[f.Name.Name] = true
+			if f.Pattern == nil {
+				bind(f.Name)
+				continue
+			}
+			p.checkBindings(f.Pattern, bound)
+		}
+
+	case *ast.OrPat:
+		sets := make([]map[string]bool, len(x.Alts))
+		for i, alt := range x.Alts {
+			sets[i] = make(map[string]bool)
+			p.checkBindings(alt, sets[i])
+		}
+		all := make(map[string]bool)
+		for _, set := range sets {
+			for name := range set {
+				all[name] = true
+			}
+		}
+		for i, set := range sets {
+			for _, name := range sortedKeys(all) {
+				if !set[name] {
+					p.error(x.Alts[i].Pos(), fmt.Sprintf("variable %s is not bound in all alternatives", name))
+				}
+			}
+		}
+		for _, name := range sortedKeys(sets[0]) {
+			bind(&ast.Ident{NamePos: x.Pos(), Name: name})
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,354 @@
+// Package parser builds Gusset syntax trees from the items produced by the
+// lexer.
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+var (
+	ErrParser = errors.New("parser error")
+)
+
+// maxErrors is the number of errors after which parsing gives up.
+const maxErrors = 10
+
+// Error is a syntax error at a position of a source file.
+type Error struct {
+	Filename string
+	Pos      lexer.Position
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	if e.Filename != "" {
+		return fmt.Sprintf("%s:%s: %s", e.Filename, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrParser
+}
+
+// ErrorList is the list of errors found while parsing a file, in source order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Sort orders the list by position.
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[j].Pos.IsAfter(l[i].Pos)
+	})
+}
+
+// Err returns the list as an error, or nil when it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// ParseFile parses the source read from src. The returned file is never nil;
+// when the source has syntax errors it holds BadExpr, BadStmt and BadDecl
+// nodes where parsing failed and the error is an ErrorList.
+func ParseFile(filename string, src io.Reader) (*ast.File, error) {
+	items, err := lexer.Items(src)
+	if err != nil {
+		return &ast.File{Filename: filename}, err
+	}
+
+	p := &parser{
+		filename: filename,
+		items:    insertSemis(items),
+	}
+	p.next()
+
+	file := p.parse()
+	p.errors.Sort()
+	return file, p.errors.Err()
+}
+
+// ParseExpr parses a single expression, which is useful in tests and tools.
+func ParseExpr(src string) (ast.Expr, error) {
+	items, err := lexer.Items(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{items: insertSemis(items)}
+	p.next()
+
+	var x ast.Expr
+	func() {
+		defer p.recoverBailout()
+		x = p.parseExpr()
+		if p.tok == lexer.SEMI && p.lit == "\n" {
+			p.next()
+		}
+		if p.tok != lexer.EOF {
+			p.errorExpected(p.pos, "end of expression")
+		}
+	}()
+	return x, p.errors.Err()
+}
+
+// endsStatement reports whether a newline after t terminates a statement,
+// following the same rule Go uses to insert semicolons.
+func endsStatement(t lexer.Token) bool {
+	switch t {
+	case lexer.IDENT, lexer.OMIT,
+		lexer.INT, lexer.FLOAT, lexer.STRING, lexer.TEMPLATE, lexer.STRUCTURED, lexer.JSX,
+		lexer.SYMBOL, lexer.BOOL, lexer.NIL,
+		lexer.T_SYMBOL, lexer.T_STRING, lexer.T_INT, lexer.T_FLOAT, lexer.T_BOOL, lexer.ANY,
+		lexer.RETURN, lexer.BREAK, lexer.CONTINUE,
+		lexer.ASSIGN_INC, lexer.ASSIGN_DEC,
+		lexer.CLOSE_PAREN, lexer.CLOSE_BRACKET, lexer.CLOSE_BRACE:
+		return true
+	}
+	return false
+}
+
+// insertSemis turns significant NEWLINE items into SEMI items with the text
+// "\n" and drops the rest, so the grammar only has to deal with semicolons.
+func insertSemis(items []lexer.Item) []lexer.Item {
+	out := make([]lexer.Item, 0, len(items))
+	prev := lexer.ILLEGAL
+	for _, item := range items {
+		switch item.Token {
+		case lexer.NEWLINE:
+			if endsStatement(prev) {
+				out = append(out, lexer.Item{Pos: item.Pos, Token: lexer.SEMI, String: "\n"})
+				prev = lexer.SEMI
+			}
+			continue
+		case lexer.EOF:
+			if endsStatement(prev) {
+				out = append(out, lexer.Item{Pos: item.Pos, Token: lexer.SEMI, String: "\n"})
+			}
+		}
+		out = append(out, item)
+		prev = item.Token
+	}
+	return out
+}
+
+type parser struct {
+	filename string
+	items    []lexer.Item
+	offset   int
+	errors   ErrorList
+
+	// current item
+	pos lexer.Position
+	tok lexer.Token
+	lit string
+
+	// exprLev is < 0 in control clauses, where `T {` starts a block rather
+	// than a composite literal, and >= 0 otherwise.
+	exprLev int
+}
+
+// bailout is panicked with once too many errors were found.
+type bailout struct{}
+
+func (p *parser) recoverBailout() {
+	if r := recover(); r != nil {
+		if _, ok := r.(bailout); !ok {
+			panic(r)
+		}
+	}
+}
+
+func (p *parser) next() {
+	if p.offset >= len(p.items) {
+		p.tok = lexer.EOF
+		p.lit = ""
+		return
+	}
+	item := p.items[p.offset]
+	p.offset++
+	p.pos, p.tok, p.lit = item.Pos, item.Token, item.String
+}
+
+func (p *parser) error(pos lexer.Position, msg string) {
+	// only report the first error on a line
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos.Line == pos.Line {
+		return
+	}
+	p.errors = append(p.errors, &Error{p.filename, pos, msg})
+	if len(p.errors) >= maxErrors {
+		panic(bailout{})
+	}
+}
+
+func (p *parser) errorExpected(pos lexer.Position, what string) {
+	found := tokenDescription(p.tok)
+	switch {
+	case p.tok == lexer.SEMI && p.lit == "\n":
+		found = "newline"
+	case p.tok == lexer.IDENT || p.tok == lexer.ILLEGAL:
+		found = fmt.Sprintf("%s %q", found, p.lit)
+	}
+	p.error(pos, fmt.Sprintf("expected %s, found %s", what, found))
+}
+
+func (p *parser) expect(tok lexer.Token) lexer.Position {
+	pos := p.pos
+	if p.tok != tok {
+		p.errorExpected(pos, tokenDescription(tok))
+	}
+	p.next()
+	return pos
+}
+
+// expectClosing is like expect but explains the common mistake of a missing
+// trailing comma before a newline.
+func (p *parser) expectClosing(tok lexer.Token, context string) lexer.Position {
+	if p.tok != tok && p.tok == lexer.SEMI && p.lit == "\n" {
+		p.error(p.pos, fmt.Sprintf("missing ',' before newline in %s", context))
+		p.next()
+	}
+	return p.expect(tok)
+}
+
+func (p *parser) expectSemi() {
+	switch p.tok {
+	case lexer.CLOSE_PAREN, lexer.CLOSE_BRACE:
+		// a semicolon may be omitted before a closing ")" or "}"
+	case lexer.SEMI:
+		p.next()
+	default:
+		p.errorExpected(p.pos, "';' or newline")
+		p.advance(stmtStart)
+	}
+}
+
+// atComma reports whether a list continues after the current item. A missing
+// comma is reported and then treated as present.
+func (p *parser) atComma(context string, follow lexer.Token) bool {
+	if p.tok == lexer.COMMA {
+		return true
+	}
+	if p.tok != follow {
+		msg := "missing ','"
+		if p.tok == lexer.SEMI && p.lit == "\n" {
+			msg += " before newline"
+		}
+		p.error(p.pos, msg+" in "+context)
+		return true
+	}
+	return false
+}
+
+var stmtStart = map[lexer.Token]bool{
+	lexer.SEMI:        true,
+	lexer.CLOSE_BRACE: true,
+	lexer.VAR:         true,
+	lexer.CONST:       true,
+	lexer.TYPE:        true,
+	lexer.RETURN:      true,
+	lexer.IF:          true,
+	lexer.SWITCH:      true,
+	lexer.MATCH:       true,
+	lexer.FOR:         true,
+	lexer.BREAK:       true,
+	lexer.CONTINUE:    true,
+}
+
+var declStart = map[lexer.Token]bool{
+	lexer.VAR:   true,
+	lexer.CONST: true,
+	lexer.TYPE:  true,
+	lexer.FUNC:  true,
+}
+
+// advance skips items until one in to is found, leaving it as the current
+// item. A semicolon ending the skipped statement is consumed.
+func (p *parser) advance(to map[lexer.Token]bool) {
+	for ; p.tok != lexer.EOF; p.next() {
+		if to[p.tok] {
+			if p.tok == lexer.SEMI {
+				p.next()
+			}
+			return
+		}
+	}
+}
+
+func tokenDescription(t lexer.Token) string {
+	if t == lexer.IDENT {
+		return "identifier"
+	}
+	if seq := lexer.Sequence(t); seq != "" {
+		return "'" + seq + "'"
+	}
+	if w := lexer.ReservedWord(t); w != "" {
+		return "'" + w + "'"
+	}
+	return t.String()
+}
+
+func (p *parser) parse() *ast.File {
+	file := &ast.File{Filename: p.filename}
+	defer p.recoverBailout()
+
+	file.Package = p.expect(lexer.PACKAGE)
+	file.Name = p.parseIdent()
+	p.expectSemi()
+
+	for p.tok != lexer.EOF {
+		file.Decls = append(file.Decls, p.parseDecl())
+	}
+
+	p.checkEnumMethods(file)
+	return file
+}
+
+func (p *parser) parseIdent() *ast.Ident {
+	pos, name := p.pos, "_"
+	switch p.tok {
+	case lexer.IDENT:
+		name = p.lit
+		p.next()
+	case lexer.OMIT:
+		p.next()
+	default:
+		p.expect(lexer.IDENT)
+	}
+	return &ast.Ident{NamePos: pos, Name: name}
+}
+
+func (p *parser) parseIdentList() []*ast.Ident {
+	list := []*ast.Ident{p.parseIdent()}
+	for p.tok == lexer.COMMA {
+		p.next()
+		list = append(list, p.parseIdent())
+	}
+	return list
+}
@@ -0,0 +1,641 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+func parseSource(t *testing.T, src string) *ast.File {
+	t.Helper()
+	file, err := ParseFile("test.gus", strings.NewReader(src))
+	require.NoError(t, err)
+	return file
+}
+
+// stmtSource wraps statements in a package and function.
+func stmtSource(stmts string) string {
+	return fmt.Sprintf("package main\n\nfunc main() {\n%s\n}\n", stmts)
+}
+
+func funcBody(t *testing.T, file *ast.File) []ast.Stmt {
+	t.Helper()
+	require.NotEmpty(t, file.Decls)
+	fn, ok := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
+	require.True(t, ok, "expected last declaration to be a function")
+	return fn.Body.List
+}
+
+type parserTestCase struct {
+	name  string
+	input string
+}
+
+var declTestCases = []parserTestCase{
+	{"var type", "var Test string"},
+	{"var any nil", "var t any = nil"},
+	{"const", `const Test int = 1`},
+	{"grouped var", "var (\n\ta = 1\n\tb, c string\n)"},
+	{"basic type", "type Test float"},
+	{"tuple type", "type Test tuple(string, int)"},
+	{"struct type multiline", "type Row struct {\n\tCol1 int\n\tCol2 string\n}"},
+	{"struct type singleline", "type Row struct {F1 int; F2 string}"},
+	{"struct type grouped", "type Point struct {X, Y float}"},
+	{"map type", "type StructSliceFunc map[Item][]func(int) string"},
+	{"map of interface", "type StringInterface map[string]interface{}"},
+	{"interface singleline", "type Named interface{First() string; Second(bool) int}"},
+	{"interface multiline", "type Named interface{\n\tFirst() string\n\tSecond(bool) int\n}"},
+	{"func empty", "func testEmpty() {}"},
+	{"func args", "func testMultiArg(first int, second float, third bool, fourth string) {}"},
+	{"func grouped args", "func add(a, b int) int { return a + b }"},
+	{"func results", "func testSingleArgMultiReturn(first int) (string, bool) {}"},
+	{"method receiver", "func (r Receiver) Exec(s string) (int, bool) {}"},
+	{"record type", "type State record {\n\tCount int\n\tItems []string\n}"},
+	{"record type singleline", "type Point record {X, Y float}"},
+	{"enum plain", "type Color enum {\n\tRed\n\tGreen\n\tBlue\n}"},
+	{"enum singleline", "type Color enum {Red; Green; Blue}"},
+	{"enum payload", "type ScreenColor enum {\n\tRGB(int, int, int)\n\tCMYK(int, int, int, int)\n}"},
+	{"enum named payload", "type Shape enum {\n\tCircle(radius float)\n\tRect(w, h float)\n}"},
+	{"enum int", "type Value enum(int) {\n\tFirst\n\tSecond\n\tThird\n}"},
+	{"enum int values", "type Code enum(int) {\n\tOk = 200\n\tNotFound = 0x194\n\tUnknown = -1\n}"},
+	{"enum string", "type Name enum(string) {\n\tFirst = \"first\"\n\tSecond = \"second\"\n\tThird\n}"},
+	{"enum tuple", "type Loc enum(int, int) {\n\tZero = (0, 0)\n\tPlayerStart = (10, 20)\n}"},
+	{"enum float", "type Ratio enum(float) {\n\tHalf = 0.5\n\tWhole = 1\n}"},
+	{"enum symbol", "type Status enum(symbol) {\n\tOk = :ok\n\tErr = :err\n}"},
+	{"symbol set", "type Status :ok | :err | :retry"},
+	{"symbol set var", "var done :ok = :ok"},
+	{"generic type", "type List[T any] struct {\n\tItems []T\n}"},
+	{"generic type grouped params", "type Pair[K, V any] record{Key K; Value V}"},
+	{"generic enum", "type Result[T, E any] enum {\n\tOk(T)\n\tErr(E)\n}"},
+	{"generic func", "func Filter[T any](xs []T, keep func(T) bool) []T { return xs }"},
+	{"generic func constraint", "func Sum[T ~int | float](xs []T) T {}"},
+	{"generic param type", "func Len[T any](l List[T], f func(List[T]) int) int {}"},
+	{"generic method", "func (l List[T]) Len() int { return len(l.Items) }"},
+	{"constraint interface", "type Number interface {\n\t~int | ~float\n}"},
+	{"constraint interface methods", "type Stringish interface{ string | symbol; String() string }"},
+	{"instantiated type", "var m Map[string, List[int]]"},
+	{"array type with constant length", "type Buf [N]int"},
+	{"enum constant value", "type Flag enum(int) {\n\tRead = readBit\n\tWrite = readBit << 1\n}"},
+	{"optional var", "var u ?User = nil"},
+	{"optional field", "type Node struct {\n\tValue int\n\tNext ?Node\n}"},
+	{"optional result", "func find(id int) ?User { return nil }"},
+}
+
+var stmtTestCases = []parserTestCase{
+	{"short var", `test1 := "gus"`},
+	{"assign ops", "a += 1\nb <<= 2\nc &^= d"},
+	{"inc dec", "i++\nj--"},
+	{"multi assign", "a, b = b, a"},
+	{"return multi", "return 0, false"},
+	{"template", "var message = `this is\n a test\n`"},
+	{"symbol", "s := :ok"},
+	{"numbers", "n := 0x00ff00 + 0b0011 + 0.1e16"},
+	{"tuple literal", "t := (100, 0.1, false)"},
+	{"tuple conversion", "t := Sizes(20, 104)"},
+	{"struct literal multiline", "r := Row{\n\tCol1 => 123,\n\tCol2 => 0.5,\n}"},
+	{"array literal", `a := [3]string{"first", "second", "third"}`},
+	{"slice literal elided", "s := []Row{{}, {Col1 => 1}}"},
+	{"slice literal multiline", "s := []string{\n\t\"one\",\n\t\"two\",\n}"},
+	{"map literal", `m := map[symbol]int{:first => 1, :second => 2}`},
+	{"arrow no args", `exists("test", () => {})`},
+	{"arrow args", `exists("test", (ok) => {})`},
+	{"arrow implicit return", `users.Filter((user) => user.inactive)`},
+	{"arrow typed", `f := (a, b int) => a + b`},
+	{"if else", "if condition {\n\tuser.enabled = true\n} else {}"},
+	{"if init", "if x := f(); x > 0 {\n}"},
+	{"if composite", "if r == (Row{}) {\n}"},
+	{"switch", "switch {\n\tcond1 => console.Log(\"cond1\"),\n\tdefault => panic(\"bad\"),\n}"},
+	{"switch tag", "switch x {\n\t1, 2 => y = 1\n\t3 => {\n\t\ty = 2\n\t}\n}"},
+	{"for simple", "for {\n\tbreak\n}"},
+	{"for cond", "for x < 10 {\n\tcontinue\n}"},
+	{"for range", "for i, v := range list {}"},
+	{"for step", "for i := 0; i < 10; i++ {}"},
+	{"for range composite", "for _, r := range []Row{{}} {}"},
+	{"structured literal", `var q = #json({"test": [1, 2, 3]})`},
+	{"comments", "// leading\nx := 1 /* inline */ + 2 // trailing"},
+	{"conversion", "f := float(i)"},
+	{"unary", "x := -a + !b"},
+	{"list comprehension", "s := [x * 2 for x in xs if x > 0]"},
+	{"list comprehension nested", "s := [(i, y) for i, ys in grid for y in ys if y != nil]"},
+	{"list comprehension literal", "s := [Row{Col1 => x} for x in []int{1, 2}]"},
+	{"map comprehension", "m := {k => v for k, v in m}"},
+	{"map comprehension cond", "m := {u.id => u for u in users if u.active}"},
+	{"slice of map comprehensions", "s := []map[string]int{{k => 1 for k in keys}, {}}"},
+	{"array length expr", "var a [n * 2]int"},
+	{"tuple index", "x := t.0 + t.1"},
+	{"tuple index nested", "y := pairs[0].1.0"},
+	{"tuple destructuring", "a, b := pair\nname, ok := lookup(key)"},
+	{"tuple return", "return (0, false)"},
+	{"record literal", "p := Point{X => 1, Y => 2}"},
+	{"anonymous record literal", "p := record{X int}{X => 1}"},
+	{"with", "next := state with {Count => state.Count + 1}"},
+	{"with multiline", "next := state with {\n\tCount => 0,\n\tItems => []string{},\n}"},
+	{"with nested", "s = s with {Pos => s.Pos with {X => 0}}.Normalize()"},
+	{"with condition", "if s with {Count => 0} == initial {\n}"},
+	{"explicit instantiation", "ys := Map[int, string](xs, f)"},
+	{"generic literal", "l := List[int]{Items => xs}"},
+	{"index condition", "if xs[i] {\n}"},
+	{"optional element", "xs := []?int{1, nil}"},
+	{"match", "match q {\n\t\"include\" => query.include,\n\t\"expect\" => query.expect,\n\t_ => query.unknown,\n}"},
+	{"match value", "x := match n {\n\t0 | 1 => \"small\"\n\t-1 => \"negative\"\n\tn if n > 100 => \"large\"\n\t_ => \"other\"\n}"},
+	{"match symbols", "match status {:ok => done(), :err => { retry() }, _ => {}}"},
+	{"match tuple", "match (a, b) {\n\t(true, _) => 1\n\t(false, x) => x\n}"},
+	{"match record", "match p {\n\tPoint{x => 0, y} => y\n\t{x, y => 0} => x\n\t_ => 0\n}"},
+	{"match variant", "match c {\n\tColor.Red => 1\n\tScreenColor.RGB(r, _, b) => r + b\n\tpkg.Opt.Some(x) | pkg.Opt.Other(x) => x\n\t_ => 0\n}"},
+}
+
+func TestParseDecls(t *testing.T) {
+	for _, tc := range declTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := parseSource(t, "package main\n\n"+tc.input+"\n")
+			assert.Len(t, file.Decls, 1)
+		})
+	}
+}
+
+func TestParseStmts(t *testing.T) {
+	for _, tc := range stmtTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parseSource(t, stmtSource(tc.input))
+		})
+	}
+}
+
+func TestParseFileStructure(t *testing.T) {
+	file := parseSource(t, multilineInput(`
+		package main
+
+		func (r Row) Exec(s string) (int, bool) {
+			for i, v := range list {
+				total += v
+			}
+			return r.Col1, true
+		}
+	`))
+
+	assert.Equal(t, "main", file.Name.Name)
+	require.Len(t, file.Decls, 1)
+	fn := file.Decls[0].(*ast.FuncDecl)
+	assert.Equal(t, "Exec", fn.Name.Name)
+	require.NotNil(t, fn.Recv)
+	assert.Equal(t, 1, fn.Recv.NumFields())
+	assert.Equal(t, 1, fn.Type.Params.NumFields())
+	assert.Equal(t, 2, fn.Type.Results.NumFields())
+
+	require.Len(t, fn.Body.List, 2)
+	rng, ok := fn.Body.List[0].(*ast.RangeStmt)
+	require.True(t, ok)
+	assert.Equal(t, lexer.SHORT_VAR, rng.Tok)
+	assert.Equal(t, "i", rng.Key.(*ast.Ident).Name)
+	assert.Equal(t, "v", rng.Value.(*ast.Ident).Name)
+	assert.Equal(t, "4:4", rng.For.String())
+}
+
+func TestParseGroupedParams(t *testing.T) {
+	file := parseSource(t, "package main\n\nfunc f(a, b int, c string) {}\n")
+	params := file.Decls[0].(*ast.FuncDecl).Type.Params
+	require.Len(t, params.List, 2)
+	assert.Len(t, params.List[0].Names, 2)
+	assert.Equal(t, "int", params.List[0].Type.(*ast.Ident).Name)
+	assert.Equal(t, 3, params.NumFields())
+}
+
+func TestParseArrowFunc(t *testing.T) {
+	x, err := ParseExpr("users.Filter((user) => user.inactive)")
+	require.NoError(t, err)
+
+	call := x.(*ast.CallExpr)
+	require.Len(t, call.Args, 1)
+	fn := call.Args[0].(*ast.FuncLit)
+	require.Len(t, fn.Type.Params.List, 1)
+	assert.Nil(t, fn.Type.Params.List[0].Type)
+	assert.Nil(t, fn.Body)
+	assert.IsType(t, &ast.SelectorExpr{}, fn.Result)
+}
+
+func TestParsePrecedence(t *testing.T) {
+	x, err := ParseExpr("a || b && c == d + e * f")
+	require.NoError(t, err)
+
+	var ops []lexer.Token
+	for {
+		bin, ok := x.(*ast.BinaryExpr)
+		if !ok {
+			break
+		}
+		ops = append(ops, bin.Op)
+		x = bin.Y
+	}
+	assert.Equal(t, []lexer.Token{lexer.OR, lexer.AND, lexer.EQ, lexer.ADD, lexer.MULT}, ops)
+}
+
+func TestParseSwitchArms(t *testing.T) {
+	file := parseSource(t, stmtSource(multilineInput(`
+		switch {
+			cond1 => console.Log("cond1"),
+			default => panic("bad"),
+		}
+	`)))
+
+	sw := funcBody(t, file)[0].(*ast.SwitchStmt)
+	assert.Nil(t, sw.Tag)
+	require.Len(t, sw.Body, 2)
+	assert.Len(t, sw.Body[0].List, 1)
+	assert.Nil(t, sw.Body[1].List)
+	assert.IsType(t, &ast.ExprStmt{}, sw.Body[1].Body)
+}
+
+func TestParseEnum(t *testing.T) {
+	file := parseSource(t, multilineInput(`
+		package main
+
+		type Loc enum(int, int) {
+			Zero = (0, 0)
+			PlayerStart = (10, 20)
+		}
+
+		type Shape enum {
+			Circle(radius float)
+			Point
+		}
+
+		func (s Shape) Area() float {
+			return 0
+		}
+	`))
+	require.Len(t, file.Decls, 3)
+
+	loc := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.EnumType)
+	require.Len(t, loc.Backing, 2)
+	assert.Equal(t, "int", loc.Backing[1].(*ast.Ident).Name)
+	require.Len(t, loc.Variants, 2)
+	assert.Equal(t, "PlayerStart", loc.Variants[1].Name.Name)
+	assert.Nil(t, loc.Variants[1].Payload)
+	assert.Len(t, loc.Variants[1].Value.(*ast.TupleLit).Elts, 2)
+
+	shape := file.Decls[1].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.EnumType)
+	assert.Nil(t, shape.Backing)
+	require.Len(t, shape.Variants, 2)
+	require.NotNil(t, shape.Variants[0].Payload)
+	assert.Equal(t, "radius", shape.Variants[0].Payload.List[0].Names[0].Name)
+	assert.Nil(t, shape.Variants[1].Payload)
+
+	method := file.Decls[2].(*ast.FuncDecl)
+	assert.Equal(t, "Shape", method.Recv.List[0].Type.(*ast.Ident).Name)
+}
+
+func TestParseEnumErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"empty", "type E enum {}", "test.gus:3:13: enum type must have at least one variant"},
+		{"duplicate name", "type E enum {\n\tA\n\tA\n}", "test.gus:5:2: duplicate enum variant A"},
+		{"int mismatch", "type E enum(int) {\n\tA = \"a\"\n}", `test.gus:4:6: enum variant A value "a" does not match backing type int`},
+		{"string mismatch", "type E enum(string) {\n\tA = 1\n}", "test.gus:4:6: enum variant A value 1 does not match backing type string"},
+		{"tuple arity", "type E enum(int, int) {\n\tA = (1, 2, 3)\n}", "test.gus:4:6: enum variant A value (1, 2, 3) does not match backing type (int, int)"},
+		{"tuple element", "type E enum(int, string) {\n\tA = (1, 2)\n}", "test.gus:4:10: enum variant A value 2 does not match backing type string"},
+		{"missing value", "type E enum(int, int) {\n\tA\n}", "test.gus:4:2: enum variant A needs a value of type (int, int)"},
+		{"duplicate value", "type E enum(int) {\n\tA = 1\n\tB = 0\n\tC\n}", "test.gus:6:2: duplicate value 1 for enum variants A and C"},
+		{"duplicate string", "type E enum(string) {\n\tA\n\tB = \"A\"\n}", `test.gus:5:2: duplicate value "A" for enum variants A and B`},
+		{"payload with backing", "type E enum(int) {\n\tA(int)\n}", "test.gus:4:3: enum variant A cannot have a payload in an enum with a backing type"},
+		{"value without backing", "type E enum {\n\tA = 1\n}", "test.gus:4:6: enum variant A has a value but the enum has no backing type"},
+		{"method conflict", "type E enum {\n\tA\n}\n\nfunc (e E) A() {}", "test.gus:7:12: method E.A conflicts with enum variant of the same name"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFile("test.gus", strings.NewReader("package main\n\n"+tc.input+"\n"))
+			var list ErrorList
+			require.ErrorAs(t, err, &list)
+			assert.Equal(t, tc.err, list[0].Error())
+		})
+	}
+}
+
+func TestParseTupleIndex(t *testing.T) {
+	file := parseSource(t, stmtSource("x := t.1.0.name"))
+	sel := funcBody(t, file)[0].(*ast.AssignStmt).Rhs[0].(*ast.SelectorExpr)
+	assert.Equal(t, "name", sel.Sel.Name)
+	outer := sel.X.(*ast.TupleIndexExpr)
+	assert.Equal(t, 0, outer.Index)
+	inner := outer.X.(*ast.TupleIndexExpr)
+	assert.Equal(t, 1, inner.Index)
+	assert.Equal(t, "t", inner.X.(*ast.Ident).Name)
+	assert.Equal(t, "t.1.0.name", ast.ExprString(sel))
+}
+
+func TestParseGenerics(t *testing.T) {
+	file := parseSource(t, multilineInput(`
+		package main
+
+		type Map[K comparable, V any] struct{}
+
+		func Keys[K comparable, V any](m Map[K, V]) []K {}
+
+		func Sum[T ~int | float](xs []T) T {}
+	`))
+	require.Len(t, file.Decls, 3)
+
+	spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	require.NotNil(t, spec.TypeParams)
+	assert.Equal(t, 2, spec.TypeParams.NumFields())
+
+	fn := file.Decls[1].(*ast.FuncDecl)
+	assert.Equal(t, "func[K comparable, V any](m Map[K, V]) []K", ast.ExprString(fn.Type))
+	index := fn.Type.Params.List[0].Type.(*ast.IndexExpr)
+	assert.Len(t, index.Indices, 2)
+
+	sum := file.Decls[2].(*ast.FuncDecl)
+	union := sum.Type.TypeParams.List[0].Type.(*ast.BinaryExpr)
+	assert.Equal(t, lexer.BIT_OR, union.Op)
+	assert.Equal(t, lexer.TILDE, union.X.(*ast.UnaryExpr).Op)
+	assert.Equal(t, "~int | float", ast.ExprString(union))
+}
+
+func TestParseOptional(t *testing.T) {
+	file := parseSource(t, "package main\n\nvar m map[string]?[]?User\n")
+	spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	m := spec.Type.(*ast.MapType)
+	opt := m.Value.(*ast.OptionalType)
+	assert.IsType(t, &ast.ArrayType{}, opt.Elt)
+	assert.Equal(t, "map[string]?[]?User", ast.ExprString(spec.Type))
+}
+
+func TestParseAttributes(t *testing.T) {
+	file := parseSource(t, "package main\n\n#[must_use]\n#[ inline ]\nfunc parse(s string) int { return 0 }\n")
+	fn := file.Decls[0].(*ast.FuncDecl)
+	require.Len(t, fn.Attrs, 2)
+	assert.Equal(t, "must_use", fn.Attrs[0].Name)
+	assert.Equal(t, "3:1", fn.Attrs[0].Pos().String())
+	assert.Equal(t, "inline", fn.Attrs[1].Name)
+	assert.Equal(t, "parse", fn.Name.Name)
+
+	file = parseSource(t, "package main\n\n#[extern(\"react-dom/client\")]\n#[js( \"a\\\"b\", \"c\" )]\nfunc f()\n\n#[extern]\nvar document any\n")
+	fn = file.Decls[0].(*ast.FuncDecl)
+	require.Len(t, fn.Attrs, 2)
+	assert.Equal(t, []string{"react-dom/client"}, fn.Attrs[0].Args)
+	assert.Equal(t, "js", fn.Attrs[1].Name)
+	assert.Equal(t, []string{`a"b`, "c"}, fn.Attrs[1].Args)
+	decl := file.Decls[1].(*ast.GenDecl)
+	require.Len(t, decl.Attrs, 1)
+	assert.Equal(t, "extern", decl.Attrs[0].Name)
+	assert.Nil(t, decl.Attrs[0].Args)
+}
+
+func TestParseSymbolSet(t *testing.T) {
+	file := parseSource(t, "package main\n\nfunc f(s :ok | :err) :ok | :err { return s }\n")
+	fn := file.Decls[0].(*ast.FuncDecl)
+	union := fn.Type.Params.List[0].Type.(*ast.BinaryExpr)
+	assert.Equal(t, lexer.BIT_OR, union.Op)
+	assert.Equal(t, lexer.SYMBOL, union.Y.(*ast.BasicLit).Kind)
+	assert.Equal(t, ":ok | :err", ast.ExprString(fn.Type.Results.List[0].Type))
+}
+
+func TestParseWith(t *testing.T) {
+	file := parseSource(t, stmtSource(`next := state with {Count => state.Count + 1, Done => true}`))
+	w := funcBody(t, file)[0].(*ast.AssignStmt).Rhs[0].(*ast.WithExpr)
+	assert.Equal(t, "state", w.X.(*ast.Ident).Name)
+	require.Len(t, w.Elts, 2)
+	kv := w.Elts[1].(*ast.KeyValueExpr)
+	assert.Equal(t, "Done", kv.Key.(*ast.Ident).Name)
+	assert.Equal(t, "true", kv.Value.(*ast.BasicLit).Value)
+}
+
+func TestParseComprehension(t *testing.T) {
+	file := parseSource(t, stmtSource(multilineInput(`
+		evens := [x for x in xs if x % 2 == 0]
+		index := {v => i for i, v in evens}
+	`)))
+	body := funcBody(t, file)
+	require.Len(t, body, 2)
+
+	list := body[0].(*ast.AssignStmt).Rhs[0].(*ast.ListComp)
+	assert.Equal(t, "x", list.Elt.(*ast.Ident).Name)
+	require.Len(t, list.Clauses, 1)
+	assert.Len(t, list.Clauses[0].Vars, 1)
+	assert.Equal(t, "xs", list.Clauses[0].X.(*ast.Ident).Name)
+	assert.IsType(t, &ast.BinaryExpr{}, list.Clauses[0].Cond)
+
+	m := body[1].(*ast.AssignStmt).Rhs[0].(*ast.MapComp)
+	assert.Equal(t, "v", m.Key.(*ast.Ident).Name)
+	assert.Equal(t, "i", m.Value.(*ast.Ident).Name)
+	require.Len(t, m.Clauses, 1)
+	assert.Len(t, m.Clauses[0].Vars, 2)
+	assert.Nil(t, m.Clauses[0].Cond)
+	assert.Equal(t, "{v => i for i, v in evens}", ast.ExprString(m))
+}
+
+func TestParseJSON(t *testing.T) {
+	file := parseSource(t, stmtSource(multilineInput(`
+		q := #json({
+			"name": "a\u00e9\n",
+			"sizes": [1, -2.5e3, true, null],
+			"empty": {}
+		})
+		p := #json[[1, 2]]
+	`)))
+	body := funcBody(t, file)
+	require.Len(t, body, 2)
+
+	lit := body[0].(*ast.AssignStmt).Rhs[0].(*ast.JSONLit)
+	v := lit.Value
+	assert.Equal(t, ast.JSONObject, v.Kind)
+	assert.Equal(t, []string{"name", "sizes", "empty"}, v.Keys)
+	assert.Equal(t, "a\u00e9\n", v.Elts[0].Text)
+	assert.Equal(t, lexer.Position{Line: 5, Col: 11}, v.Elts[0].Pos)
+
+	sizes := v.Elts[1]
+	assert.Equal(t, ast.JSONArray, sizes.Kind)
+	require.Len(t, sizes.Elts, 4)
+	assert.Equal(t, "-2.5e3", sizes.Elts[1].Text)
+	assert.Equal(t, ast.JSONBool, sizes.Elts[2].Kind)
+	assert.Equal(t, ast.JSONNull, sizes.Elts[3].Kind)
+	assert.Empty(t, v.Elts[2].Elts)
+	assert.True(t, strings.HasPrefix(ast.ExprString(lit), "#json({"))
+
+	arr := body[1].(*ast.AssignStmt).Rhs[0].(*ast.JSONLit).Value
+	assert.Equal(t, ast.JSONArray, arr.Kind)
+	assert.Len(t, arr.Elts, 2)
+}
+
+func TestParseJSX(t *testing.T) {
+	file := parseSource(t, stmtSource(multilineInput(`
+		el := <ui.Panel title="a &amp; b" open>
+			Hello,
+			  {user.Name}!
+			<img src={url} />
+			{/* nothing */}
+			{each(items, (x) => <li key={x}>{x}</li>)}
+		</ui.Panel>
+		f := <></>
+	`)))
+	body := funcBody(t, file)
+	require.Len(t, body, 2)
+
+	el := body[0].(*ast.AssignStmt).Rhs[0].(*ast.JSXElement)
+	assert.Equal(t, "ui.Panel", el.Tag)
+	assert.Equal(t, "ui.Panel", ast.ExprString(el.Name))
+	require.Len(t, el.Attrs, 2)
+	assert.Equal(t, "title", el.Attrs[0].Name)
+	assert.Equal(t, `"a & b"`, el.Attrs[0].Value.(*ast.BasicLit).Value)
+	assert.Nil(t, el.Attrs[1].Value)
+
+	require.Len(t, el.Children, 5)
+	assert.Equal(t, "Hello,", el.Children[0].(*ast.JSXText).Value)
+	name := el.Children[1].(*ast.SelectorExpr)
+	assert.Equal(t, lexer.Position{Line: 6, Col: 6}, name.X.Pos())
+	assert.Equal(t, "!", el.Children[2].(*ast.JSXText).Value)
+	img := el.Children[3].(*ast.JSXElement)
+	assert.Nil(t, img.Name)
+	assert.Equal(t, "url", img.Attrs[0].Value.(*ast.Ident).Name)
+	li := el.Children[4].(*ast.CallExpr).Args[1].(*ast.FuncLit).Result.(*ast.JSXElement)
+	assert.Equal(t, "li", li.Tag)
+	assert.IsType(t, &ast.Ident{}, li.Children[0])
+
+	f := body[1].(*ast.AssignStmt).Rhs[0].(*ast.JSXElement)
+	assert.Equal(t, "", f.Tag)
+	assert.Empty(t, f.Children)
+}
+
+func TestParseMatch(t *testing.T) {
+	file := parseSource(t, stmtSource(multilineInput(`
+		label := match shape {
+			Shape.Circle(r) if r > 10 => "big circle",
+			Shape.Rect(w, _) | Shape.Square(w) => "box",
+			_ => "other",
+		}
+	`)))
+
+	assign := funcBody(t, file)[0].(*ast.AssignStmt)
+	x := assign.Rhs[0].(*ast.MatchExpr)
+	assert.Equal(t, "shape", x.X.(*ast.Ident).Name)
+	require.Len(t, x.Arms, 3)
+
+	circle := x.Arms[0].Pattern.(*ast.VariantPat)
+	assert.Equal(t, "Shape.Circle", ast.ExprString(circle.Path))
+	require.Len(t, circle.Args, 1)
+	assert.Equal(t, "r", circle.Args[0].(*ast.BindPat).Name.Name)
+	assert.NotNil(t, x.Arms[0].Guard)
+
+	or := x.Arms[1].Pattern.(*ast.OrPat)
+	require.Len(t, or.Alts, 2)
+	assert.IsType(t, &ast.WildcardPat{}, or.Alts[0].(*ast.VariantPat).Args[1])
+	assert.Nil(t, x.Arms[1].Guard)
+
+	assert.IsType(t, &ast.WildcardPat{}, x.Arms[2].Pattern)
+	assert.IsType(t, &ast.ExprStmt{}, x.Arms[2].Body)
+}
+
+func TestParseMatchErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			"duplicate binding",
+			"match p {\n\t(x, x) => x\n}",
+			"test.gus:5:6: x is bound more than once in the same pattern",
+		},
+		{
+			"or binding",
+			"match s {\n\tShape.Circle(r) | Shape.Rect(w, _) => 0\n}",
+			"test.gus:5:2: variable w is not bound in all alternatives",
+		},
+		{
+			"duplicate field",
+			"match p {\n\t{x, y, x => 1} => 0\n}",
+			"test.gus:5:9: duplicate field x in record pattern",
+		},
+		{
+			"bad pattern",
+			"match c {\n\t[1] => 0\n}",
+			"test.gus:5:2: expected pattern, found '['",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := "package main\n\nfunc main() {\n" + tc.input + "\n}\n"
+			_, err := ParseFile("test.gus", strings.NewReader(src))
+			var list ErrorList
+			require.ErrorAs(t, err, &list)
+			assert.Equal(t, tc.err, list[0].Error())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"missing package", "func main() {}", "test.gus:1:1: expected 'package', found 'func'"},
+		{"missing expression", stmtSource("x :="), "test.gus:5:1: expected expression, found '}'"},
+		{"missing comma", stmtSource("f(a b)"), "test.gus:4:5: missing ',' in argument list"},
+		{"trailing comma", stmtSource("s := []int{\n\t1,\n\t2\n}"), "test.gus:6:3: missing ',' before newline in composite literal"},
+		{"mixed params", "package main\nfunc f(a int, []string) {}", "test.gus:2:15: mixed named and unnamed parameters"},
+		{"const without value", "package main\nconst x int", "test.gus:2:12: missing constant value"},
+		{"short tuple type", "package main\ntype T tuple(int)", "test.gus:2:8: tuple type must have at least two elements"},
+		{"declaration", "package main\nx := 1", "test.gus:2:1: expected declaration, found identifier \"x\""},
+		{"illegal", stmtSource(`s := "open`), `test.gus:4:6: expected expression, found ILLEGAL "\"open"`},
+		{"comprehension vars", stmtSource("s := [x for i, k, v in m]"), "test.gus:4:19: comprehension permits at most two iteration variables"},
+		{"comprehension in", stmtSource("s := [x for x := range xs]"), "test.gus:4:15: expected 'in', found ':='"},
+		{"map comprehension key", stmtSource("m := {v for v in xs}"), "test.gus:4:7: expected 'key => value' in map comprehension, found 'for'"},
+		{"tuple index leading zero", stmtSource("x := t.01"), "test.gus:4:8: invalid tuple index 01"},
+		{"record embedded", "package main\ntype R record {\n\tBase\n}", "test.gus:3:2: embedded field in record type"},
+		{"record duplicate field", "package main\ntype R record {X int; X string}", "test.gus:2:23: duplicate field X in record type"},
+		{"with duplicate field", stmtSource("s = s with {X => 1, X => 2}"), "test.gus:4:21: duplicate field X in with expression"},
+		{"with field name", stmtSource("s = s with {1 => 2}"), "test.gus:4:13: expected field name in with expression, found INT"},
+		{"with empty", stmtSource("s = s with {}"), "test.gus:4:12: with expression must update at least one field"},
+		{"symbol set", "package main\ntype S :ok | int", "test.gus:2:14: expected symbol, found 'int'"},
+		{"missing constraint", "package main\nfunc f[T](x T) {}", "test.gus:2:8: missing type constraint"},
+		{"method type params", "package main\nfunc (l List) Map[T any]() {}", "test.gus:2:18: methods cannot have type parameters"},
+		{"attribute name", "package main\n#[must use]\nfunc f() {}", "test.gus:2:1: invalid attribute #[must use]"},
+		{"attribute arguments", "package main\n#[extern(react)]\nfunc f()", "test.gus:2:1: invalid attribute #[extern(react)]"},
+		{"attribute on statement", "package main\n#[must_use]\nreturn", "test.gus:3:1: expected 'func', found 'return'"},
+		{"untyped composite", stmtSource("m := {1, 2}"), "test.gus:4:6: missing type in composite literal"},
+		{"jsx closing tag", stmtSource("el := <div><p></div></p>"), "test.gus:4:15: expected closing tag </p> of the JSX element at 4:12"},
+		{"jsx duplicate attribute", stmtSource(`el := <a href="x" href="y" />`), "test.gus:4:19: duplicate attribute href in JSX element"},
+		{"jsx empty attribute", stmtSource("el := <a href={} />"), "test.gus:4:15: empty expression in JSX attribute href"},
+		{"jsx expression", stmtSource("el := <p>{a b}</p>"), "test.gus:4:13: expected '}' after JSX expression, found identifier \"b\""},
+		{"jsx component name", stmtSource("el := <Icon.2x />"), "test.gus:4:8: invalid JSX element name Icon.2x"},
+		{"json trailing comma", stmtSource(`q := #json([1, 2,])`), "test.gus:4:18: invalid JSON in #json literal: expected value, found ']'"},
+		{"json duplicate key", stmtSource("q := #json({\n\t\"a\": 1,\n\t\"a\": 2\n})"), `test.gus:6:2: invalid JSON in #json literal: duplicate key "a" in object`},
+		{"json number", stmtSource(`q := #json({"a": 01})`), "test.gus:4:19: invalid JSON in #json literal: expected ',' or '}' after object member, found '1'"},
+		{"json trailing value", stmtSource(`q := #json(1 2)`), "test.gus:4:14: invalid JSON in #json literal: unexpected '2' after JSON value"},
+		{"json word", stmtSource(`q := #json([True])`), "test.gus:4:13: invalid JSON in #json literal: expected value, found 'T'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := ParseFile("test.gus", strings.NewReader(tc.input))
+			require.NotNil(t, file)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrParser)
+
+			var list ErrorList
+			require.ErrorAs(t, err, &list)
+			assert.Equal(t, tc.err, list[0].Error())
+		})
+	}
+}
+
+func TestParseErrorLimit(t *testing.T) {
+	var stmts strings.Builder
+	for i := 0; i < 2*maxErrors; i++ {
+		stmts.WriteString("f(a b)\n")
+	}
+
+	_, err := ParseFile("test.gus", strings.NewReader(stmtSource(stmts.String())))
+	var list ErrorList
+	require.ErrorAs(t, err, &list)
+	assert.Len(t, list, maxErrors)
+}
+
+func multilineInput(input string) string {
+	return strings.TrimSpace(input) + "\n"
+}
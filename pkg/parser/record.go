@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// parseRecordType parses a record type, whose fields are declared like those
+// of a struct but may not be embedded.
+func (p *parser) parseRecordType() *ast.RecordType {
+	typ := &ast.RecordType{Record: p.expect(lexer.T_RECORD)}
+	typ.Fields = p.parseFieldDecls("record type")
+
+	names := make(map[string]bool)
+	for _, f := range typ.Fields.List {
+		if len(f.Names) == 0 {
+			if _, bad := f.Type.(*ast.BadExpr); !bad {
+				p.error(f.Type.Pos(), "embedded field in record type")
+			}
+			continue
+		}
+		for _, name := range f.Names {
+			if names[name.Name] {
+				p.error(name.Pos(), fmt.Sprintf("duplicate field %s in record type", name.Name))
+			}
+			names[name.Name] = true
+		}
+	}
+	return typ
+}
+
+// parseWithExpr parses the `with {field => value, ...}` update applied to x.
+func (p *parser) parseWithExpr(x ast.Expr) *ast.WithExpr {
+	w := &ast.WithExpr{X: x, With: p.expect(lexer.WITH)}
+	w.Lbrace = p.expect(lexer.OPEN_BRACE)
+
+	old := p.exprLev
+	p.exprLev = 0
+	names := make(map[string]bool)
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok != lexer.IDENT {
+			pos := p.pos
+			p.errorExpected(pos, "field name in with expression")
+			p.advance(map[lexer.Token]bool{lexer.COMMA: true, lexer.CLOSE_BRACE: true, lexer.SEMI: true})
+			w.Elts = append(w.Elts, &ast.BadExpr{From: pos})
+		} else {
+			name := p.parseIdent()
+			if names[name.Name] {
+				p.error(name.Pos(), fmt.Sprintf("duplicate field %s in with expression", name.Name))
+			}
+			names[name.Name] = true
+			arrow := p.expect(lexer.ARROW)
+			w.Elts = append(w.Elts, &ast.KeyValueExpr{Key: name, Arrow: arrow, Value: p.parseElementValue()})
+		}
+		if !p.atComma("with expression", lexer.CLOSE_BRACE) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev = old
+	w.Rbrace = p.expectClosing(lexer.CLOSE_BRACE, "with expression")
+
+	if len(w.Elts) == 0 {
+		p.error(w.Lbrace, "with expression must update at least one field")
+	}
+	return w
+}
@@ -0,0 +1,294 @@
+package parser
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+func (p *parser) parseBlockStmt() *ast.BlockStmt {
+	block := &ast.BlockStmt{Lbrace: p.expect(lexer.OPEN_BRACE)}
+	block.List = p.parseStmtList()
+	block.Rbrace = p.expect(lexer.CLOSE_BRACE)
+	return block
+}
+
+func (p *parser) parseStmtList() []ast.Stmt {
+	var list []ast.Stmt
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		list = append(list, p.parseStmt())
+	}
+	return list
+}
+
+func (p *parser) parseStmt() ast.Stmt {
+	switch p.tok {
+	case lexer.VAR, lexer.CONST:
+		return &ast.DeclStmt{Decl: p.parseGenDecl(p.tok, p.parseValueSpec)}
+	case lexer.TYPE:
+		return &ast.DeclStmt{Decl: p.parseGenDecl(p.tok, p.parseTypeSpec)}
+	case lexer.RETURN:
+		return p.parseReturnStmt()
+	case lexer.BREAK, lexer.CONTINUE:
+		s := &ast.BranchStmt{TokPos: p.pos, Tok: p.tok}
+		p.next()
+		p.expectSemi()
+		return s
+	case lexer.OPEN_BRACE:
+		s := p.parseBlockStmt()
+		p.expectSemi()
+		return s
+	case lexer.IF:
+		return p.parseIfStmt()
+	case lexer.SWITCH:
+		return p.parseSwitchStmt()
+	case lexer.FOR:
+		return p.parseForStmt()
+	}
+
+	s := p.parseSimpleStmt(basic)
+	p.expectSemi()
+	return s
+}
+
+func (p *parser) parseReturnStmt() *ast.ReturnStmt {
+	s := &ast.ReturnStmt{Return: p.expect(lexer.RETURN)}
+	if p.tok != lexer.SEMI && p.tok != lexer.CLOSE_BRACE {
+		s.Results = p.parseExprList()
+	}
+	p.expectSemi()
+	return s
+}
+
+func isAssignOp(t lexer.Token) bool {
+	switch t {
+	case lexer.ASSIGN, lexer.SHORT_VAR,
+		lexer.ASSIGN_ADD, lexer.ASSIGN_SUB, lexer.ASSIGN_MULT, lexer.ASSIGN_DIV, lexer.ASSIGN_MOD,
+		lexer.ASSIGN_BIT_AND, lexer.ASSIGN_BIT_OR, lexer.ASSIGN_BIT_NOT,
+		lexer.ASSIGN_BIT_LEFT, lexer.ASSIGN_BIT_RIGHT, lexer.ASSIGN_BIT_CLEAR:
+		return true
+	}
+	return false
+}
+
+// Modes of parseSimpleStmt.
+const (
+	basic = iota
+	// rangeOk parses `k, v := range x` into a partial RangeStmt whose For
+	// and Body are filled in by the caller.
+	rangeOk
+	// armBody parses single expressions on both sides, since a comma ends
+	// the arm.
+	armBody
+)
+
+// parseSimpleStmt parses an expression, assignment or inc/dec statement.
+func (p *parser) parseSimpleStmt(mode int) ast.Stmt {
+	parseList := p.parseExprList
+	if mode == armBody {
+		parseList = func() []ast.Expr { return []ast.Expr{p.parseExpr()} }
+	}
+	lhs := parseList()
+
+	switch {
+	case isAssignOp(p.tok):
+		pos, tok := p.pos, p.tok
+		p.next()
+		if mode == rangeOk && p.tok == lexer.IDENT && p.lit == "range" && (tok == lexer.SHORT_VAR || tok == lexer.ASSIGN) {
+			p.next()
+			s := &ast.RangeStmt{TokPos: pos, Tok: tok, X: p.parseExpr()}
+			switch len(lhs) {
+			case 2:
+				s.Value = lhs[1]
+				fallthrough
+			case 1:
+				s.Key = lhs[0]
+			default:
+				p.error(lhs[2].Pos(), "range clause permits at most two iteration variables")
+				s.Key, s.Value = lhs[0], lhs[1]
+			}
+			return s
+		}
+		s := &ast.AssignStmt{Lhs: lhs, TokPos: pos, Tok: tok, Rhs: parseList()}
+		if tok != lexer.ASSIGN && tok != lexer.SHORT_VAR && (len(lhs) > 1 || len(s.Rhs) > 1) {
+			p.error(pos, "assignment operation "+tokenDescription(tok)+" requires single-valued expressions")
+		}
+		return s
+
+	case p.tok == lexer.ASSIGN_INC || p.tok == lexer.ASSIGN_DEC:
+		s := &ast.IncDecStmt{X: lhs[0], TokPos: p.pos, Tok: p.tok}
+		p.next()
+		return s
+	}
+
+	if len(lhs) > 1 {
+		p.errorExpected(lhs[0].Pos(), "1 expression")
+	}
+	return &ast.ExprStmt{X: lhs[0]}
+}
+
+// parseControlClause parses the header of an if or switch statement, which
+// may start with a simple statement followed by a semicolon.
+func (p *parser) parseControlClause() (init ast.Stmt, cond ast.Stmt) {
+	old := p.exprLev
+	p.exprLev = -1
+	defer func() { p.exprLev = old }()
+
+	if p.tok == lexer.OPEN_BRACE {
+		return nil, nil
+	}
+	if p.tok != lexer.SEMI {
+		cond = p.parseSimpleStmt(basic)
+	}
+	if p.tok == lexer.SEMI && p.lit != "\n" {
+		p.next()
+		init, cond = cond, nil
+		if p.tok != lexer.OPEN_BRACE {
+			cond = p.parseSimpleStmt(basic)
+		}
+	}
+	return init, cond
+}
+
+func (p *parser) condExpr(s ast.Stmt, what string) ast.Expr {
+	if s == nil {
+		return nil
+	}
+	if es, ok := s.(*ast.ExprStmt); ok {
+		return es.X
+	}
+	p.error(s.Pos(), "cannot use statement as "+what)
+	return &ast.BadExpr{From: s.Pos()}
+}
+
+func (p *parser) parseIfStmt() *ast.IfStmt {
+	s := &ast.IfStmt{If: p.expect(lexer.IF)}
+	init, cond := p.parseControlClause()
+	s.Init = init
+	s.Cond = p.condExpr(cond, "condition")
+	if s.Cond == nil {
+		p.error(p.pos, "missing condition in if statement")
+		s.Cond = &ast.BadExpr{From: p.pos}
+	}
+	s.Body = p.parseBlockStmt()
+
+	if p.tok == lexer.ELSE {
+		p.next()
+		switch p.tok {
+		case lexer.IF:
+			s.Else = p.parseIfStmt()
+			return s
+		case lexer.OPEN_BRACE:
+			s.Else = p.parseBlockStmt()
+		default:
+			p.errorExpected(p.pos, "if statement or block")
+			s.Else = &ast.BadStmt{From: p.pos}
+		}
+	}
+	p.expectSemi()
+	return s
+}
+
+func (p *parser) parseSwitchStmt() *ast.SwitchStmt {
+	s := &ast.SwitchStmt{Switch: p.expect(lexer.SWITCH)}
+	init, tag := p.parseControlClause()
+	s.Init = init
+	s.Tag = p.condExpr(tag, "switch expression")
+
+	s.Lbrace = p.expect(lexer.OPEN_BRACE)
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		s.Body = append(s.Body, p.parseCaseClause())
+	}
+	s.Rbrace = p.expect(lexer.CLOSE_BRACE)
+	p.expectSemi()
+	return s
+}
+
+// parseCaseClause parses a `values => body` or `default => body` arm. Arms
+// are separated by commas or newlines.
+func (p *parser) parseCaseClause() *ast.CaseClause {
+	c := &ast.CaseClause{Case: p.pos}
+	if p.tok == lexer.DEFAULT {
+		p.next()
+	} else {
+		c.List = p.parseExprList()
+	}
+	c.Arrow = p.expect(lexer.ARROW)
+	c.Body = p.parseArmBody()
+	p.expectArmEnd("switch arm")
+	return c
+}
+
+// expectArmEnd consumes the comma or newline that separates the arms of a
+// switch statement or match expression.
+func (p *parser) expectArmEnd(context string) {
+	switch p.tok {
+	case lexer.COMMA, lexer.SEMI:
+		p.next()
+	case lexer.CLOSE_BRACE:
+	default:
+		p.errorExpected(p.pos, "',' or newline after "+context)
+		p.advance(map[lexer.Token]bool{lexer.SEMI: true, lexer.CLOSE_BRACE: true})
+	}
+}
+
+// parseArmBody parses the block or simple statement on the right of `=>`.
+func (p *parser) parseArmBody() ast.Stmt {
+	old := p.exprLev
+	p.exprLev = 0
+	defer func() { p.exprLev = old }()
+
+	if p.tok == lexer.OPEN_BRACE {
+		return p.parseBlockStmt()
+	}
+	return p.parseSimpleStmt(armBody)
+}
+
+func (p *parser) parseForStmt() ast.Stmt {
+	pos := p.expect(lexer.FOR)
+
+	old := p.exprLev
+	p.exprLev = -1
+
+	var s1, s2, s3 ast.Stmt
+	if p.tok != lexer.OPEN_BRACE {
+		if p.tok != lexer.SEMI {
+			s2 = p.parseSimpleStmt(rangeOk)
+		}
+		if _, isRange := s2.(*ast.RangeStmt); !isRange && p.tok == lexer.SEMI {
+			p.next()
+			s1, s2 = s2, nil
+			if p.tok != lexer.SEMI {
+				s2 = p.parseSimpleStmt(basic)
+			}
+			p.expect(lexer.SEMI)
+			if p.tok != lexer.OPEN_BRACE {
+				s3 = p.parseSimpleStmt(basic)
+			}
+		}
+	}
+	p.exprLev = old
+
+	body := p.parseBlockStmt()
+	p.expectSemi()
+
+	if rs, ok := s2.(*ast.RangeStmt); ok {
+		rs.For = pos
+		rs.Body = body
+		return rs
+	}
+	return &ast.ForStmt{
+		For:  pos,
+		Init: s1,
+		Cond: p.condExpr(s2, "for condition"),
+		Post: s3,
+		Body: body,
+	}
+}
@@ -0,0 +1,369 @@
+package parser
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// builtinTypes are the reserved words that name predeclared types. They are
+// represented in the tree as identifiers.
+var builtinTypes = map[lexer.Token]bool{
+	lexer.T_SYMBOL: true,
+	lexer.T_STRING: true,
+	lexer.T_INT:    true,
+	lexer.T_FLOAT:  true,
+	lexer.T_BOOL:   true,
+	lexer.ANY:      true,
+}
+
+// startsType reports whether t can begin a type.
+func startsType(t lexer.Token) bool {
+	switch t {
+	case lexer.IDENT, lexer.OPEN_BRACKET, lexer.OPEN_PAREN, lexer.T_MAP, lexer.FUNC, lexer.QUESTION,
+		lexer.T_STRUCT, lexer.T_RECORD, lexer.INTERFACE, lexer.T_TUPLE, lexer.T_ENUM, lexer.SYMBOL:
+		return true
+	}
+	return builtinTypes[t]
+}
+
+func (p *parser) parseType() ast.Expr {
+	if typ := p.tryType(); typ != nil {
+		return typ
+	}
+	pos := p.pos
+	p.errorExpected(pos, "type")
+	p.advance(stmtStart)
+	return &ast.BadExpr{From: pos}
+}
+
+// tryType parses a type if the current item starts one and returns nil
+// otherwise.
+func (p *parser) tryType() ast.Expr {
+	switch {
+	case p.tok == lexer.IDENT:
+		return p.parseTypeName()
+	case builtinTypes[p.tok]:
+		ident := &ast.Ident{NamePos: p.pos, Name: p.lit}
+		p.next()
+		return ident
+	}
+
+	switch p.tok {
+	case lexer.OPEN_BRACKET:
+		return p.parseArrayType()
+	case lexer.T_MAP:
+		return p.parseMapType()
+	case lexer.QUESTION:
+		pos := p.pos
+		p.next()
+		return &ast.OptionalType{Question: pos, Elt: p.parseType()}
+	case lexer.FUNC:
+		pos := p.pos
+		p.next()
+		return p.parseSignature(pos)
+	case lexer.T_STRUCT:
+		return p.parseStructType()
+	case lexer.T_RECORD:
+		return p.parseRecordType()
+	case lexer.INTERFACE:
+		return p.parseInterfaceType()
+	case lexer.T_TUPLE:
+		return p.parseTupleType()
+	case lexer.T_ENUM:
+		return p.parseEnumType()
+	case lexer.SYMBOL:
+		return p.parseSymbolType()
+	case lexer.OPEN_PAREN:
+		lparen := p.pos
+		p.next()
+		typ := p.parseType()
+		rparen := p.expect(lexer.CLOSE_PAREN)
+		return &ast.ParenExpr{Lparen: lparen, X: typ, Rparen: rparen}
+	}
+	return nil
+}
+
+// parseTypeName parses a possibly package-qualified type name, followed by
+// type arguments if the type is generic.
+func (p *parser) parseTypeName() ast.Expr {
+	return p.finishTypeName(p.parseIdent())
+}
+
+// finishTypeName parses the rest of a type name once its first identifier
+// has been parsed.
+func (p *parser) finishTypeName(name *ast.Ident) ast.Expr {
+	var typ ast.Expr = name
+	if p.tok == lexer.ACCESS {
+		p.next()
+		typ = &ast.SelectorExpr{X: typ, Sel: p.parseIdent()}
+	}
+	if p.tok == lexer.OPEN_BRACKET {
+		typ = p.parseTypeArgs(typ)
+	}
+	return typ
+}
+
+// parseSymbolType parses a symbol set type such as :ok | :err, which is
+// represented as a symbol literal or a union of them.
+func (p *parser) parseSymbolType() ast.Expr {
+	var typ ast.Expr = p.parseSymbolLit()
+	for p.tok == lexer.BIT_OR {
+		pos := p.pos
+		p.next()
+		if p.tok != lexer.SYMBOL {
+			p.errorExpected(p.pos, "symbol")
+			p.advance(stmtStart)
+			return &ast.BadExpr{From: typ.Pos()}
+		}
+		typ = &ast.BinaryExpr{X: typ, OpPos: pos, Op: lexer.BIT_OR, Y: p.parseSymbolLit()}
+	}
+	return typ
+}
+
+func (p *parser) parseSymbolLit() *ast.BasicLit {
+	lit := &ast.BasicLit{ValuePos: p.pos, Kind: lexer.SYMBOL, Value: p.lit}
+	p.next()
+	return lit
+}
+
+func (p *parser) parseArrayType() *ast.ArrayType {
+	lbrack := p.expect(lexer.OPEN_BRACKET)
+	var length ast.Expr
+	if p.tok != lexer.CLOSE_BRACKET {
+		p.exprLev++
+		length = p.parseRhs()
+		p.exprLev--
+	}
+	return p.finishArrayType(lbrack, length)
+}
+
+// finishArrayType parses the rest of an array or slice type once its length,
+// if any, has been parsed.
+func (p *parser) finishArrayType(lbrack lexer.Position, length ast.Expr) *ast.ArrayType {
+	p.expect(lexer.CLOSE_BRACKET)
+	return &ast.ArrayType{Lbrack: lbrack, Len: length, Elt: p.parseType()}
+}
+
+func (p *parser) parseMapType() *ast.MapType {
+	typ := &ast.MapType{Map: p.expect(lexer.T_MAP)}
+	p.expect(lexer.OPEN_BRACKET)
+	typ.Key = p.parseType()
+	p.expect(lexer.CLOSE_BRACKET)
+	typ.Value = p.parseType()
+	return typ
+}
+
+func (p *parser) parseTupleType() *ast.TupleType {
+	typ := &ast.TupleType{Tuple: p.expect(lexer.T_TUPLE)}
+	typ.Lparen = p.expect(lexer.OPEN_PAREN)
+	for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+		typ.Elts = append(typ.Elts, p.parseType())
+		if !p.atComma("tuple type", lexer.CLOSE_PAREN) {
+			break
+		}
+		p.next()
+	}
+	typ.Rparen = p.expect(lexer.CLOSE_PAREN)
+	if len(typ.Elts) < 2 {
+		p.error(typ.Tuple, "tuple type must have at least two elements")
+	}
+	return typ
+}
+
+func (p *parser) parseStructType() *ast.StructType {
+	typ := &ast.StructType{Struct: p.expect(lexer.T_STRUCT)}
+	typ.Fields = p.parseFieldDecls("struct type")
+	return typ
+}
+
+// parseFieldDecls parses the braced field list of a struct type. A field is
+// either a list of names followed by a type or an embedded type name.
+func (p *parser) parseFieldDecls(context string) *ast.FieldList {
+	list := &ast.FieldList{Opening: p.expect(lexer.OPEN_BRACE)}
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		list.List = append(list.List, p.parseFieldDecl(context))
+		p.expectSemi()
+	}
+	list.Closing = p.expect(lexer.CLOSE_BRACE)
+	return list
+}
+
+func (p *parser) parseFieldDecl(context string) *ast.Field {
+	if p.tok != lexer.IDENT {
+		pos := p.pos
+		p.errorExpected(pos, "field name or embedded type in "+context)
+		p.advance(map[lexer.Token]bool{lexer.SEMI: true, lexer.CLOSE_BRACE: true})
+		return &ast.Field{Type: &ast.BadExpr{From: pos}}
+	}
+
+	name := p.parseIdent()
+	switch p.tok {
+	case lexer.SEMI, lexer.CLOSE_BRACE:
+		return &ast.Field{Type: name}
+	case lexer.ACCESS:
+		p.next()
+		return &ast.Field{Type: &ast.SelectorExpr{X: name, Sel: p.parseIdent()}}
+	}
+
+	names := []*ast.Ident{name}
+	for p.tok == lexer.COMMA {
+		p.next()
+		names = append(names, p.parseIdent())
+	}
+	return &ast.Field{Names: names, Type: p.parseType()}
+}
+
+func (p *parser) parseInterfaceType() *ast.InterfaceType {
+	typ := &ast.InterfaceType{Interface: p.expect(lexer.INTERFACE)}
+	list := &ast.FieldList{Opening: p.expect(lexer.OPEN_BRACE)}
+	for p.tok != lexer.CLOSE_BRACE && p.tok != lexer.EOF {
+		if p.tok == lexer.SEMI {
+			p.next()
+			continue
+		}
+		list.List = append(list.List, p.parseMethodSpec())
+		p.expectSemi()
+	}
+	list.Closing = p.expect(lexer.CLOSE_BRACE)
+	typ.Methods = list
+	return typ
+}
+
+// parseMethodSpec parses an interface method, an embedded interface name or
+// a type element of a constraint.
+func (p *parser) parseMethodSpec() *ast.Field {
+	if p.tok == lexer.TILDE || (p.tok != lexer.IDENT && startsType(p.tok)) {
+		return &ast.Field{Type: p.parseConstraint()}
+	}
+	if p.tok != lexer.IDENT {
+		pos := p.pos
+		p.errorExpected(pos, "method or embedded interface")
+		p.advance(map[lexer.Token]bool{lexer.SEMI: true, lexer.CLOSE_BRACE: true})
+		return &ast.Field{Type: &ast.BadExpr{From: pos}}
+	}
+
+	name := p.parseIdent()
+	if p.tok == lexer.OPEN_PAREN {
+		return &ast.Field{Names: []*ast.Ident{name}, Type: p.parseSignature(lexer.Position{})}
+	}
+	typ := p.finishTypeName(name)
+	if p.tok == lexer.BIT_OR {
+		typ = p.finishUnion(typ)
+	}
+	return &ast.Field{Type: typ}
+}
+
+// parseSignature parses parameters and optional results. pos is the position
+// of the func keyword, if any.
+func (p *parser) parseSignature(pos lexer.Position) *ast.FuncType {
+	typ := &ast.FuncType{Func: pos}
+	typ.Params = p.parseParameters(false)
+	typ.Results = p.parseResults()
+	return typ
+}
+
+func (p *parser) parseResults() *ast.FieldList {
+	if p.tok == lexer.OPEN_PAREN {
+		return p.parseParameters(false)
+	}
+	if p.tok != lexer.OPEN_PAREN && startsType(p.tok) {
+		typ := p.parseType()
+		return &ast.FieldList{List: []*ast.Field{{Type: typ}}}
+	}
+	return nil
+}
+
+type paramEntry struct {
+	name *ast.Ident
+	typ  ast.Expr
+}
+
+// parseParameters parses a parenthesized parameter list. Parameters are
+// either all named, with consecutive names sharing a type as in `(a, b int)`,
+// or all anonymous types. With untyped set, as for arrow functions, a list of
+// bare names such as `(user)` declares parameters whose types are inferred.
+func (p *parser) parseParameters(untyped bool) *ast.FieldList {
+	list := &ast.FieldList{Opening: p.expect(lexer.OPEN_PAREN)}
+
+	var entries []paramEntry
+	named := false
+	for p.tok != lexer.CLOSE_PAREN && p.tok != lexer.EOF {
+		entry := p.parseParamEntry()
+		if entry.name != nil {
+			named = true
+		}
+		entries = append(entries, entry)
+		if !p.atComma("parameter list", lexer.CLOSE_PAREN) {
+			break
+		}
+		p.next()
+	}
+	list.Closing = p.expect(lexer.CLOSE_PAREN)
+
+	if !named {
+		for _, e := range entries {
+			if untyped {
+				ident, ok := e.typ.(*ast.Ident)
+				if !ok {
+					p.error(e.typ.Pos(), "expected parameter name")
+					continue
+				}
+				list.List = append(list.List, &ast.Field{Names: []*ast.Ident{ident}})
+				continue
+			}
+			list.List = append(list.List, &ast.Field{Type: e.typ})
+		}
+		return list
+	}
+
+	// group names without a type with the next named parameter's type
+	var pending []*ast.Ident
+	for _, e := range entries {
+		if e.name == nil {
+			ident, ok := e.typ.(*ast.Ident)
+			if !ok {
+				p.error(e.typ.Pos(), "mixed named and unnamed parameters")
+				continue
+			}
+			pending = append(pending, ident)
+			continue
+		}
+		list.List = append(list.List, &ast.Field{
+			Names: append(pending, e.name),
+			Type:  e.typ,
+		})
+		pending = nil
+	}
+	if len(pending) > 0 {
+		p.error(pending[len(pending)-1].Pos(), "missing parameter type")
+		list.List = append(list.List, &ast.Field{
+			Names: pending,
+			Type:  &ast.BadExpr{From: pending[0].Pos()},
+		})
+	}
+	return list
+}
+
+func (p *parser) parseParamEntry() paramEntry {
+	if p.tok != lexer.IDENT {
+		return paramEntry{typ: p.parseType()}
+	}
+
+	ident := p.parseIdent()
+	switch p.tok {
+	case lexer.COMMA, lexer.CLOSE_PAREN:
+		// either a name or a type, decided by the rest of the list
+		return paramEntry{typ: ident}
+	case lexer.ACCESS:
+		return paramEntry{typ: p.finishTypeName(ident)}
+	case lexer.OPEN_BRACKET:
+		if p.isTypeArgs() {
+			return paramEntry{typ: p.parseTypeArgs(ident)}
+		}
+	}
+	return paramEntry{name: ident, typ: p.parseType()}
+}
@@ -0,0 +1,203 @@
+// Package types declares the data types and implements the type checker for
+// Gusset packages. Check resolves the identifiers of a package's files,
+// records the scopes it builds and assigns a type to every expression.
+package types
+
+import (
+	"errors"
+	"fmt"
+	"go/constant"
+	"sort"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+var (
+	ErrType = errors.New("type error")
+)
+
+// Error is a type error at a position of a source file. Code is set for
+// the errors that tools may want to recognize.
+type Error struct {
+	Filename string
+	Pos      lexer.Position
+	Msg      string
+	Code     Code
+}
+
+func (e *Error) Error() string {
+	msg := e.Msg
+	if e.Code != "" {
+		msg += " [" + string(e.Code) + "]"
+	}
+	if e.Filename != "" {
+		return fmt.Sprintf("%s:%s: %s", e.Filename, e.Pos, msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, msg)
+}
+
+// Code identifies a kind of error independently of its message, which may
+// change. Codes are never renamed or reused.
+type Code string
+
+const (
+	// UnusedVar reports a local variable that is never used. Assigning to
+	// a variable does not use it.
+	UnusedVar Code = "unused-var"
+	// UnusedImport is reserved for imports that are never used. Gusset has
+	// no import declarations yet, so the checker never reports it: a
+	// package reaches JavaScript modules through extern declarations,
+	// which may go unused because bindgen emits whole files of them.
+	UnusedImport Code = "unused-import"
+	// UnusedResult reports a call of a function with the must_use
+	// attribute whose result is discarded.
+	UnusedResult Code = "unused-result"
+	// UnassignedVar reports a variable declared without a value, whose
+	// type has no zero value, that is read before it is definitely
+	// assigned, or that is declared at package level, where any function
+	// may read it.
+	UnassignedVar Code = "unassigned-var"
+	// UnreachableCode reports a statement following one that leaves the
+	// block.
+	UnreachableCode Code = "unreachable-code"
+)
+
+func (e *Error) Unwrap() error {
+	return ErrType
+}
+
+// ErrorList is the list of errors found while checking a package, ordered by
+// file and position.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Sort orders the list by file name and position.
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Filename != l[j].Filename {
+			return l[i].Filename < l[j].Filename
+		}
+		return l[j].Pos.IsAfter(l[i].Pos)
+	})
+}
+
+// Err returns the list as an error, or nil when it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Package is a checked package.
+type Package struct {
+	name  string
+	scope *Scope
+}
+
+func (p *Package) Name() string   { return p.name }
+func (p *Package) Scope() *Scope  { return p.scope }
+func (p *Package) String() string { return "package " + p.name }
+
+// TypeAndValue is the type, and the value for constants, of an expression.
+// Constants converted to a type parameter are values, whose constant value
+// is recorded too.
+type TypeAndValue struct {
+	mode  operandMode
+	Type  Type
+	Value constant.Value
+}
+
+// IsType reports whether the expression denotes a type.
+func (tv TypeAndValue) IsType() bool { return tv.mode == typexpr }
+
+// IsValue reports whether the expression denotes a value.
+func (tv TypeAndValue) IsValue() bool {
+	switch tv.mode {
+	case constant_, variable, value:
+		return true
+	}
+	return false
+}
+
+// IsVoid reports whether the expression is a call without results.
+func (tv TypeAndValue) IsVoid() bool { return tv.mode == novalue }
+
+// Info holds the results of type checking. Maps that are nil are not filled
+// in.
+type Info struct {
+	// Types maps expressions to their types and, for constants, values.
+	Types map[ast.Expr]TypeAndValue
+	// Defs maps identifiers to the objects they declare.
+	Defs map[*ast.Ident]Object
+	// Uses maps identifiers to the objects they denote.
+	Uses map[*ast.Ident]Object
+	// Scopes maps files, functions, blocks, control statements, match arms
+	// and comprehension clauses to the scopes they introduce, and generic
+	// type specs to the scopes of their type parameters.
+	Scopes map[ast.Node]*Scope
+	// Instances maps the identifiers of generic functions and types that are
+	// instantiated, explicitly or by inference, to their instances.
+	Instances map[*ast.Ident]Instance
+	// Selections maps selector expressions denoting fields and methods to
+	// their selections.
+	Selections map[*ast.SelectorExpr]*Selection
+}
+
+// Instance is the instantiation of a generic function or type.
+type Instance struct {
+	TypeArgs []Type
+	Type     Type
+}
+
+// TypeOf returns the type of x, or nil if it is unknown.
+func (info *Info) TypeOf(x ast.Expr) Type {
+	if tv, ok := info.Types[x]; ok {
+		return tv.Type
+	}
+	if ident, ok := x.(*ast.Ident); ok {
+		if obj := info.ObjectOf(ident); obj != nil {
+			return obj.Type()
+		}
+	}
+	return nil
+}
+
+// ObjectOf returns the object declared or denoted by ident, or nil.
+func (info *Info) ObjectOf(ident *ast.Ident) Object {
+	if obj := info.Defs[ident]; obj != nil {
+		return obj
+	}
+	return info.Uses[ident]
+}
+
+// Check type checks the files of a package. All files must declare the same
+// package. info may be nil. The returned package is never nil; when the files
+// have type errors the error is an ErrorList.
+func Check(files []*ast.File, info *Info) (*Package, error) {
+	if info == nil {
+		info = new(Info)
+	}
+	c := newChecker(files, info)
+	c.checkFiles()
+	c.errors.Sort()
+	return c.pkg, c.errors.Err()
+}
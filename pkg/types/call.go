@@ -0,0 +1,380 @@
+package types
+
+import (
+	"go/constant"
+	"unicode/utf16"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+func (c *Checker) callExpr(x *operand, call *ast.CallExpr) {
+	// the explicit type arguments of a generic function may be partial, the
+	// rest being inferred from the arguments
+	var explicit *ast.IndexExpr
+	var targs []Type
+	if ix, ok := call.Fun.(*ast.IndexExpr); ok {
+		c.rawExpr(x, ix.X, nil)
+		if isGeneric(x) {
+			explicit = ix
+			if targs = c.typeArgs(ix.Indices); targs == nil {
+				c.use(call.Args...)
+				x.mode = invalid
+				return
+			}
+		} else if x.mode == typexpr {
+			x.typ = c.instantiatedType(ix, x.typ)
+			if !isValid(x.typ) {
+				x.mode = invalid
+			}
+		} else {
+			c.indexed(x, ix)
+		}
+		if explicit == nil {
+			x.expr = ix
+			c.record(x)
+		}
+	} else {
+		c.rawExpr(x, call.Fun, nil)
+	}
+
+	switch x.mode {
+	case invalid:
+		c.use(call.Args...)
+		return
+	case typexpr:
+		c.conversion(x, call)
+		return
+	case builtin:
+		c.builtinCall(x, call, x.id)
+		return
+	}
+
+	if isCall(call.Fun) {
+		// the function returned by a call is used by calling it
+		x.mustUse = false
+	}
+	c.singleValue(x)
+	if x.mode == invalid || !c.nonNil(x, call) {
+		c.use(call.Args...)
+		return
+	}
+	sig, ok := x.typ.Underlying().(*Signature)
+	if !ok {
+		c.errorf(x.expr.Pos(), "invalid operation: cannot call non-function %s", x)
+		c.use(call.Args...)
+		x.mode = invalid
+		return
+	}
+
+	if sig.tparams != nil {
+		c.genericCall(x, call, targs)
+		if x.mode == invalid {
+			return
+		}
+		sig = x.typ.(*Signature)
+		if explicit != nil {
+			c.record(&operand{mode: value, expr: explicit, typ: sig})
+		}
+	} else {
+		c.arguments(call, sig)
+	}
+	x.val = nil
+	if res := sig.Result(); res != nil {
+		x.mode = value
+		x.typ = res
+		return
+	}
+	x.mode = novalue
+}
+
+// arguments checks the arguments of a call to a function of type sig. A
+// single tuple argument is spread over several parameters, so that the
+// results of one call may be passed to another. Calls of extern functions
+// may omit their optional parameters.
+func (c *Checker) arguments(call *ast.CallExpr, sig *Signature) {
+	name := ast.ExprString(call.Fun)
+	params, args := sig.params, call.Args
+	required := len(params) - sig.optional
+
+	if len(args) == 1 && required > 1 {
+		var x operand
+		c.expr(&x, args[0])
+		if x.mode == invalid {
+			return
+		}
+		t, ok := x.typ.Underlying().(*Tuple)
+		if !ok || t.Len() != len(params) {
+			c.errorf(call.Rparen, "not enough arguments in call to %s: have 1, want %d", name, len(params))
+			return
+		}
+		for i, p := range params {
+			if !AssignableTo(t.elems[i], p.typ) {
+				c.errorf(x.expr.Pos(), "cannot use element %d of %s (type %s) as %s value in argument to %s%s", i, ast.ExprString(x.expr), t.elems[i], p.typ, name, assignReason(t.elems[i], p.typ))
+			}
+		}
+		return
+	}
+
+	switch {
+	case len(args) < required:
+		c.use(args...)
+		c.errorf(call.Rparen, "not enough arguments in call to %s: have %d, want %d", name, len(args), required)
+		return
+	case len(args) > len(params):
+		c.use(args...)
+		c.errorf(args[len(params)].Pos(), "too many arguments in call to %s: have %d, want %d", name, len(args), len(params))
+		return
+	}
+	for i, arg := range args {
+		var x operand
+		c.exprWithHint(&x, arg, params[i].typ)
+		c.assignment(&x, params[i].typ, "argument to "+name)
+	}
+}
+
+// conversion checks the conversion T(x), where the operand x holds the type
+// T on entry.
+func (c *Checker) conversion(x *operand, call *ast.CallExpr) {
+	T := x.typ
+	if len(call.Args) != 1 {
+		if len(call.Args) == 0 {
+			c.errorf(call.Rparen, "missing argument in conversion to %s", T)
+		} else {
+			c.errorf(call.Args[1].Pos(), "too many arguments in conversion to %s", T)
+		}
+		c.use(call.Args...)
+		x.mode = invalid
+		return
+	}
+
+	var arg operand
+	c.exprWithHint(&arg, call.Args[0], T)
+	if arg.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	if set, ok := T.Underlying().(*SymbolSet); ok && arg.mode == constant_ && isBasic(arg.typ, Symbol, UntypedSymbol) {
+		// a symbol constant converts to a symbol set including it
+		if !set.Contains(constant.StringVal(arg.val)) {
+			c.errorf(arg.expr.Pos(), "cannot convert %s to type %s (not in the set)", &arg, T)
+			x.mode = invalid
+			return
+		}
+		c.implicitConversion(&arg, T)
+		x.setConst(arg.val, T)
+		return
+	}
+	if isUntyped(arg.typ) && !arg.isNil() {
+		// untyped numbers convert to any numeric type they are representable
+		// by
+		b, ok := T.Underlying().(*Basic)
+		if ok && arg.mode == constant_ && isNumeric(arg.typ) && isNumeric(b) {
+			if _, reason := representable(arg.val, b); reason != "" {
+				c.errorf(arg.expr.Pos(), "cannot convert %s to type %s (%s)", &arg, T, reason)
+				x.mode = invalid
+				return
+			}
+		}
+		if reason, ok := c.implicitConversion(&arg, T); !ok && reason != "" {
+			c.errorf(arg.expr.Pos(), "cannot convert %s to type %s (%s)", &arg, T, reason)
+			x.mode = invalid
+			return
+		}
+	}
+	if !convertible(arg.typ, T) {
+		c.errorf(arg.expr.Pos(), "cannot convert %s to type %s", &arg, T)
+		x.mode = invalid
+		return
+	}
+	if isNumeric(arg.typ) && isNumeric(T) && !Identical(arg.typ, T) {
+		// generic code converts the values of every type of the type set
+		// alike
+		for _, t := range []Type{arg.typ, T} {
+			if a, b := mixedBasics(t, false); a != nil {
+				c.errorf(arg.expr.Pos(), "cannot convert %s to type %s (%s and %s values convert differently in JavaScript)", &arg, T, a, b)
+				x.mode = invalid
+				return
+			}
+		}
+	}
+	if isUntyped(arg.typ) && !arg.isNil() {
+		// an untyped symbol or string converted to the other kind
+		c.implicitConversion(&arg, nil)
+	}
+
+	x.typ = T
+	x.val = nil
+	x.mode = value
+	if arg.mode != constant_ {
+		return
+	}
+	switch {
+	case isInteger(T) && isNumeric(arg.typ):
+		val, reason := representable(arg.val, T.Underlying().(*Basic))
+		if reason != "" {
+			c.errorf(arg.expr.Pos(), "cannot convert %s to type %s (%s)", &arg, T, reason)
+			x.mode = invalid
+			return
+		}
+		x.setConst(val, T)
+	case isBasic(T, Float) && isNumeric(arg.typ):
+		val, _ := representable(arg.val, T.Underlying().(*Basic))
+		x.setConst(val, T)
+	case isBasic(T, Bool, String, Symbol) && convertible(arg.typ, T.Underlying()):
+		x.setConst(arg.val, T)
+	}
+}
+
+// convertible reports whether values of type V can be converted to type T.
+// Besides assignable values, numbers convert between int and float, values
+// convert between types with identical underlying types, and variants of
+// enums with a single backing type convert to that type.
+func convertible(V, T Type) bool {
+	if AssignableTo(V, T) {
+		return true
+	}
+	if Identical(V.Underlying(), T.Underlying()) {
+		return true
+	}
+	if isNumeric(V) && isNumeric(T) {
+		return true
+	}
+	if enum, ok := V.Underlying().(*Enum); ok && len(enum.backing) == 1 {
+		return Identical(enum.backing[0], T)
+	}
+	// symbols convert to and from their names
+	if isSymbol(V) && isBasic(T, String) || isString(V) && isBasic(T, Symbol) {
+		return true
+	}
+	return false
+}
+
+func (c *Checker) builtinCall(x *operand, call *ast.CallExpr, id builtinID) {
+	b := builtins[id]
+	args := call.Args
+	switch {
+	case len(args) < b.nargs:
+		c.errorf(call.Rparen, "not enough arguments for %s: have %d, want %d", b.name, len(args), b.nargs)
+		c.use(args...)
+		x.mode = invalid
+		return
+	case !b.variadic && len(args) > b.nargs:
+		c.errorf(args[b.nargs].Pos(), "too many arguments for %s: have %d, want %d", b.name, len(args), b.nargs)
+		c.use(args...)
+		x.mode = invalid
+		return
+	}
+
+	x.mode = value
+	x.val = nil
+	switch id {
+	case _Append:
+		var s operand
+		c.expr(&s, args[0])
+		if s.mode == invalid {
+			c.use(args[1:]...)
+			x.mode = invalid
+			return
+		}
+		slice, ok := coreType(s.typ).(*Slice)
+		if !ok {
+			c.errorf(s.expr.Pos(), "invalid argument: %s is not a slice", &s)
+			c.use(args[1:]...)
+			x.mode = invalid
+			return
+		}
+		for _, arg := range args[1:] {
+			var v operand
+			c.exprWithHint(&v, arg, slice.elem)
+			c.assignment(&v, slice.elem, "argument to append")
+		}
+		x.typ = s.typ
+
+	case _Delete:
+		var m, k operand
+		c.expr(&m, args[0])
+		if m.mode == invalid {
+			c.use(args[1])
+			x.mode = invalid
+			return
+		}
+		mt, ok := m.typ.Underlying().(*Map)
+		if !ok {
+			c.errorf(m.expr.Pos(), "invalid argument: %s is not a map", &m)
+			c.use(args[1])
+			x.mode = invalid
+			return
+		}
+		c.exprWithHint(&k, args[1], mt.key)
+		c.assignment(&k, mt.key, "argument to delete")
+		x.mode = novalue
+
+	case _JSONDecode:
+		T := c.typExpr(args[0])
+		var s operand
+		c.exprWithHint(&s, args[1], Typ[String])
+		c.assignment(&s, Typ[String], "argument to json_decode")
+		if !isValid(T) {
+			x.mode = invalid
+			return
+		}
+		if !c.jsonType(args[0].Pos(), T, "decode %s from") {
+			x.mode = invalid
+			return
+		}
+		x.typ = T
+
+	case _JSONEncode:
+		var a operand
+		c.expr(&a, args[0])
+		c.assignment(&a, nil, "argument to json_encode")
+		if a.mode != invalid && !c.jsonType(a.expr.Pos(), a.typ, "encode %s as") {
+			x.mode = invalid
+			return
+		}
+		x.typ = Typ[String]
+
+	case _Len:
+		var a operand
+		c.expr(&a, args[0])
+		if a.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		x.typ = Typ[Int]
+		switch u := coreType(a.typ).(type) {
+		case *Basic:
+			if isString(u) {
+				if a.mode == constant_ {
+					// strings are measured in UTF-16 code units, as in JavaScript
+					n := len(utf16.Encode([]rune(constant.StringVal(a.val))))
+					x.setConst(constant.MakeInt64(int64(n)), Typ[Int])
+				}
+				return
+			}
+		case *Array:
+			if u.len >= 0 {
+				x.setConst(constant.MakeInt64(u.len), Typ[Int])
+			}
+			return
+		case *Slice, *Map:
+			return
+		}
+		c.errorf(a.expr.Pos(), "invalid argument: %s for built-in len", &a)
+		x.mode = invalid
+
+	case _Panic:
+		var a operand
+		c.expr(&a, args[0])
+		c.assignment(&a, anyType, "argument to panic")
+		x.mode = novalue
+
+	case _Print:
+		for _, arg := range args {
+			var a operand
+			c.expr(&a, arg)
+			c.assignment(&a, nil, "argument to print")
+		}
+		x.mode = novalue
+	}
+}
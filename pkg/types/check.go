@@ -0,0 +1,793 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// declState tracks the resolution of a package-level object, so that
+// references to an object whose declaration is being checked are detected as
+// cycles.
+type declState int
+
+const (
+	unresolved declState = iota
+	resolving
+	resolved
+)
+
+// declInfo describes the declaration of a package-level object.
+type declInfo struct {
+	file  *ast.File
+	scope *Scope // file scope
+	state declState
+
+	spec   *ast.ValueSpec // var and const declarations
+	index  int            // index of the object's name in spec
+	lhs    []*Var         // all variables of a spec destructuring one value
+	tspec  *ast.TypeSpec
+	extern bool // the type declaration has the extern attribute
+	fdecl  *ast.FuncDecl
+
+	// the package-level variables and functions that the initializer or
+	// body refers to, in order
+	deps   []Object
+	depSet map[Object]bool
+	cyclic bool // an initialization cycle through the variable is reported
+}
+
+// addDep records that the declaration refers to the package-level object
+// obj.
+func (d *declInfo) addDep(obj Object) {
+	if d.depSet[obj] {
+		return
+	}
+	if d.depSet == nil {
+		d.depSet = make(map[Object]bool)
+	}
+	d.depSet[obj] = true
+	d.deps = append(d.deps, obj)
+}
+
+// funcContext holds the state of the function body being checked.
+type funcContext struct {
+	sig       *Signature
+	scope     *Scope   // scope of the parameters
+	body      ast.Node // block or arrow result
+	outer     *funcContext
+	loops     int // depth of enclosing loops
+	breakable int // depth of enclosing loops and switches
+
+	narrowed   narrowing    // optional variables known to be non-nil
+	assigns    map[*Var]int // number of assignments to narrowed variables
+	unassigned varSet       // variables not definitely assigned yet
+
+	inferResults bool // results are taken from the next return statement
+}
+
+// newFuncContext returns the context of a function body nested in outer,
+// which may be nil. The variables of outer that are not assigned yet when
+// a function literal is checked cannot be read by it.
+func newFuncContext(sig *Signature, scope *Scope, body ast.Node, outer *funcContext) *funcContext {
+	unassigned := make(varSet)
+	if outer != nil {
+		unassigned = maps.Clone(outer.unassigned)
+	}
+	return &funcContext{
+		sig:        sig,
+		scope:      scope,
+		body:       body,
+		outer:      outer,
+		narrowed:   make(narrowing),
+		assigns:    make(map[*Var]int),
+		unassigned: unassigned,
+	}
+}
+
+// Checker holds the state of a type check.
+type Checker struct {
+	pkg    *Package
+	info   *Info
+	files  []*ast.File
+	errors ErrorList
+
+	filename string // file being checked, for error positions
+	objMap   map[Object]*declInfo
+	objList  []Object // package-level objects in source order
+	methods  []*declInfo
+	decl     *declInfo // package-level declaration being checked
+	bodies   []func()
+	delayed  []func() // checks run once all bodies have been checked
+
+	scope  *Scope
+	fn     *funcContext
+	locals []localVar // variables declared in functions and expressions
+}
+
+func newChecker(files []*ast.File, info *Info) *Checker {
+	return &Checker{
+		pkg:    &Package{scope: NewScope(Universe, "package")},
+		info:   info,
+		files:  files,
+		objMap: make(map[Object]*declInfo),
+	}
+}
+
+func (c *Checker) errorf(pos lexer.Position, format string, args ...any) {
+	c.reportf(pos, "", format, args...)
+}
+
+// reportf is like errorf for an error with a code.
+func (c *Checker) reportf(pos lexer.Position, code Code, format string, args ...any) {
+	c.errors = append(c.errors, &Error{
+		Filename: c.filename,
+		Pos:      pos,
+		Msg:      fmt.Sprintf(format, args...),
+		Code:     code,
+	})
+}
+
+func (c *Checker) checkFiles() {
+	c.collectObjects()
+	c.packageObjects()
+	for len(c.bodies) > 0 {
+		body := c.bodies[0]
+		c.bodies = c.bodies[1:]
+		body()
+	}
+	for _, f := range c.delayed {
+		f()
+	}
+	c.initCycles()
+	c.unusedVars()
+}
+
+// collectObjects declares the package-level objects of all files and
+// associates them with their declarations.
+func (c *Checker) collectObjects() {
+	for _, file := range c.files {
+		c.filename = file.Filename
+		switch {
+		case c.pkg.name == "":
+			c.pkg.name = file.Name.Name
+		case file.Name.Name != c.pkg.name:
+			c.errorf(file.Name.Pos(), "package %s; expected %s", file.Name.Name, c.pkg.name)
+			continue
+		}
+
+		fileScope := NewScope(c.pkg.scope, "file "+file.Filename)
+		c.recordScope(file, fileScope)
+
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				ext, externType := c.externDecl(d)
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.ValueSpec:
+						if ext != nil {
+							vars := make([]*Var, len(s.Names))
+							for i, name := range s.Names {
+								vars[i] = NewVar(name.Pos(), name.Name, nil)
+								c.declarePkgObj(name, vars[i], &declInfo{file: file, scope: fileScope, spec: s, index: i})
+							}
+							c.externVars(s, vars, ext)
+							continue
+						}
+						if d.Tok == lexer.VAR && len(s.Names) > 1 && len(s.Values) == 1 {
+							// the variables share one declaration, which
+							// checks the value once
+							d2 := &declInfo{file: file, scope: fileScope, spec: s}
+							for _, name := range s.Names {
+								d2.lhs = append(d2.lhs, NewVar(name.Pos(), name.Name, nil))
+							}
+							for i, name := range s.Names {
+								c.declarePkgObj(name, d2.lhs[i], d2)
+							}
+							continue
+						}
+						for i, name := range s.Names {
+							var obj Object
+							if d.Tok == lexer.CONST {
+								obj = NewConst(name.Pos(), name.Name, nil, nil)
+							} else {
+								obj = NewVar(name.Pos(), name.Name, nil)
+							}
+							c.declarePkgObj(name, obj, &declInfo{file: file, scope: fileScope, spec: s, index: i})
+						}
+					case *ast.TypeSpec:
+						obj := NewTypeName(s.Name.Pos(), s.Name.Name, nil)
+						c.declarePkgObj(s.Name, obj, &declInfo{file: file, scope: fileScope, tspec: s, extern: externType})
+					}
+				}
+			case *ast.FuncDecl:
+				d2 := &declInfo{file: file, scope: fileScope, fdecl: d}
+				if d.Recv != nil {
+					c.methods = append(c.methods, d2)
+					continue
+				}
+				fn := NewFunc(d.Name.Pos(), d.Name.Name, nil)
+				c.funcAttributes(fn, d, nil)
+				c.declarePkgObj(d.Name, fn, d2)
+			}
+		}
+	}
+}
+
+func (c *Checker) declarePkgObj(ident *ast.Ident, obj Object, d *declInfo) {
+	if ident.Name == "_" {
+		c.recordDef(ident, obj)
+		c.objMap[obj] = d
+		c.objList = append(c.objList, obj)
+		return
+	}
+	c.declare(c.pkg.scope, ident, obj)
+	c.objMap[obj] = d
+	c.objList = append(c.objList, obj)
+}
+
+// packageObjects type checks the package-level declarations: types first,
+// then methods, then constants, variables and function signatures.
+func (c *Checker) packageObjects() {
+	for _, obj := range c.objList {
+		if _, ok := obj.(*TypeName); ok {
+			c.objDecl(obj)
+		}
+	}
+	var methods []*Func
+	for _, d := range c.methods {
+		methods = append(methods, c.collectMethod(d))
+	}
+	// method signatures are needed to check interface satisfaction
+	for _, m := range methods {
+		c.objDecl(m)
+	}
+	for _, obj := range c.objList {
+		c.objDecl(obj)
+	}
+}
+
+// initCycles reports the package-level variables whose initializers refer
+// to themselves through the functions they call, which objDecl does not
+// detect as function bodies are checked after all declarations.
+func (c *Checker) initCycles() {
+	for _, obj := range c.objList {
+		v, ok := obj.(*Var)
+		d := c.objMap[obj]
+		if !ok || d.cyclic {
+			continue
+		}
+		path := c.initPath(v, v, make(map[Object]bool))
+		if path == nil {
+			continue
+		}
+		reported := false
+		for _, obj := range path {
+			reported = reported || c.objMap[obj].cyclic
+		}
+		if reported {
+			// the cycle was reported from another of its variables
+			continue
+		}
+		refs := make([]string, len(path))
+		for i, obj := range path {
+			next := v.Name()
+			if i+1 < len(path) {
+				next = path[i+1].Name()
+			}
+			refs[i] = obj.Name() + " refers to " + next
+			c.objMap[obj].cyclic = true
+		}
+		c.filename = d.file.Filename
+		c.errorf(v.Pos(), "initialization cycle: %s", strings.Join(refs, ", "))
+	}
+}
+
+// initPath returns the path of declarations from obj to the variable v
+// through the dependencies of obj, or nil if there is none. seen holds the
+// declarations already visited.
+func (c *Checker) initPath(v *Var, obj Object, seen map[Object]bool) []Object {
+	for _, dep := range c.objMap[obj].deps {
+		if dep == v {
+			return []Object{obj}
+		}
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+		if path := c.initPath(v, dep, seen); path != nil {
+			return append([]Object{obj}, path...)
+		}
+	}
+	return nil
+}
+
+// objDecl type checks the declaration of a package-level object unless it
+// has already been checked. It is called lazily whenever an object is used
+// before its declaration has been checked.
+func (c *Checker) objDecl(obj Object) {
+	d := c.objMap[obj]
+	if d == nil || d.state == resolved {
+		return
+	}
+	if d.state == resolving {
+		switch obj := obj.(type) {
+		case *Const, *Var:
+			c.errorf(obj.Pos(), "initialization cycle: %s refers to itself", obj.Name())
+			d.cyclic = true
+			if obj.Type() == nil {
+				setObjType(obj, Typ[Invalid])
+			}
+		}
+		return
+	}
+	d.state = resolving
+
+	// save the context of the current declaration, if any
+	oldFilename, oldScope, oldFn, oldDecl := c.filename, c.scope, c.fn, c.decl
+	c.filename, c.scope, c.fn, c.decl = d.file.Filename, d.scope, nil, d
+	defer func() {
+		c.filename, c.scope, c.fn, c.decl = oldFilename, oldScope, oldFn, oldDecl
+		d.state = resolved
+	}()
+
+	switch obj := obj.(type) {
+	case *TypeName:
+		c.typeDecl(obj, d.tspec)
+		if d.extern {
+			c.externType(obj.typ.(*Named))
+		}
+	case *Const:
+		c.constDecl(obj, d.spec, d.index)
+	case *Var:
+		if d.lhs != nil {
+			c.varDeclTuple(d.lhs, d.spec)
+			break
+		}
+		c.varDecl(obj, d.spec, d.index)
+	case *Func:
+		c.funcDecl(obj, d)
+	}
+}
+
+func setObjType(obj Object, typ Type) {
+	switch obj := obj.(type) {
+	case *Var:
+		obj.setType(typ)
+	case *Const:
+		obj.setType(typ)
+	}
+}
+
+func (c *Checker) typeDecl(obj *TypeName, spec *ast.TypeSpec) {
+	named := NewNamed(obj, nil, nil)
+	if d := c.objMap[obj]; d != nil {
+		named.extern = d.extern
+	}
+	if spec.TypeParams != nil {
+		// the type parameters are in scope for the whole declaration
+		scope := NewScope(c.scope, "type "+obj.name)
+		c.recordScope(spec, scope)
+		named.tparams = c.declareTypeParams(spec.TypeParams, scope)
+		oldScope := c.scope
+		c.scope = scope
+		defer func() { c.scope = oldScope }()
+	}
+	rhs := c.definedType(spec.Type, named)
+	if _, ok := rhs.(*TypeParam); ok {
+		c.errorf(spec.Type.Pos(), "cannot use a type parameter as RHS in type declaration")
+		named.underlying = Typ[Invalid]
+		return
+	}
+	if n, ok := rhs.(*Named); ok && n.resolve() == nil {
+		c.errorf(obj.Pos(), "invalid recursive type %s", obj.name)
+		named.underlying = Typ[Invalid]
+		return
+	}
+	named.SetUnderlying(rhs)
+	if !c.validType(named, nil) {
+		named.underlying = Typ[Invalid]
+	}
+}
+
+// validType reports whether the values of named have a finite
+// representation. Structs, records, arrays and tuples may not contain
+// themselves; enum payloads, slices, maps, functions and extern types are
+// references and may.
+func (c *Checker) validType(named *Named, path []*Named) bool {
+	if named.Origin().extern {
+		return true
+	}
+	for _, n := range path {
+		if n == named {
+			c.errorf(named.obj.pos, "invalid recursive type %s", named.obj.name)
+			return false
+		}
+	}
+	path = append(path, named)
+
+	var check func(t Type) bool
+	check = func(t Type) bool {
+		switch t := t.(type) {
+		case *Named:
+			if t.resolve() == nil {
+				// still being declared; it is checked once complete
+				return true
+			}
+			return c.validType(t, path)
+		case *Array:
+			return check(t.elem)
+		case *Interface:
+			// an interface may not embed itself
+			for _, e := range t.embeddeds {
+				if !check(e) {
+					return false
+				}
+			}
+		case *Tuple:
+			for _, e := range t.elems {
+				if !check(e) {
+					return false
+				}
+			}
+		case *Struct:
+			for _, f := range t.fields {
+				if !check(f.typ) {
+					return false
+				}
+			}
+		case *Record:
+			for _, f := range t.fields {
+				if !check(f.typ) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return check(named.underlying)
+}
+
+// collectMethod declares a method on the named type of its receiver.
+func (c *Checker) collectMethod(d *declInfo) *Func {
+	c.filename, c.scope = d.file.Filename, d.scope
+	decl := d.fdecl
+
+	var base *Named
+	var recvType Type = Typ[Invalid]
+	if recv := decl.Recv; recv.NumFields() == 1 {
+		field := recv.List[0]
+		recvType = c.recvBase(field.Type)
+		if n, ok := recvType.(*Named); ok && n.obj.parent == c.pkg.scope {
+			base = n
+		} else if isValid(recvType) {
+			c.errorf(field.Type.Pos(), "invalid receiver type %s", recvType)
+			recvType = Typ[Invalid]
+		}
+	}
+
+	fn := NewFunc(decl.Name.Pos(), decl.Name.Name, nil)
+	c.funcAttributes(fn, decl, base)
+	c.recordDef(decl.Name, fn)
+	c.objMap[fn] = d
+	c.objList = append(c.objList, fn)
+
+	if base == nil || decl.Name.Name == "_" {
+		return fn
+	}
+	if obj, _ := LookupFieldOrMethod(base, decl.Name.Name); obj != nil {
+		if f, ok := obj.(*Var); ok && f.field {
+			c.errorf(decl.Name.Pos(), "field and method with the same name %s", decl.Name.Name)
+			return fn
+		}
+	}
+	for _, m := range base.methods {
+		if m.name == decl.Name.Name {
+			c.errorf(decl.Name.Pos(), "method %s.%s already declared", base.obj.name, m.name)
+			return fn
+		}
+	}
+	if _, ok := base.Underlying().(*Interface); ok {
+		c.errorf(decl.Name.Pos(), "invalid receiver type %s (pointer or interface type)", base)
+		return fn
+	}
+	base.AddMethod(fn)
+	return fn
+}
+
+// recvBase returns the type of the receiver type expression e of a method.
+// The receiver of a method of a generic type names the type parameters of
+// the method, as in List[T], and denotes the generic type itself here.
+func (c *Checker) recvBase(e ast.Expr) Type {
+	ix, ok := e.(*ast.IndexExpr)
+	if !ok {
+		return c.typExpr(e)
+	}
+	var x operand
+	c.rawExpr(&x, ix.X, nil)
+	switch x.mode {
+	case invalid:
+		return Typ[Invalid]
+	case typexpr:
+	default:
+		c.errorf(ix.X.Pos(), "%s is not a type", ast.ExprString(ix.X))
+		return Typ[Invalid]
+	}
+	if n, ok := x.typ.(*Named); ok && n.tparams != nil && n.targs == nil {
+		return n
+	}
+	if isValid(x.typ) {
+		c.errorf(ix.X.Pos(), "%s is not a generic type", x.typ)
+	}
+	return Typ[Invalid]
+}
+
+// genericRecv declares the receiver type parameters of a method of a generic
+// type in scope. It returns them with the receiver type, which is the
+// generic type instantiated with them. Their constraints are those of the
+// type parameters of the type.
+func (c *Checker) genericRecv(ix *ast.IndexExpr, scope *Scope) (Type, []*TypeParam) {
+	base, ok := c.recvBase(ix).(*Named)
+	if !ok {
+		return Typ[Invalid], nil
+	}
+	if len(ix.Indices) != len(base.tparams) {
+		c.errorf(ix.Lbrack, "receiver declares %d type parameters, but type %s has %d", len(ix.Indices), base, len(base.tparams))
+		return Typ[Invalid], nil
+	}
+
+	rtparams := make([]*TypeParam, len(ix.Indices))
+	targs := make([]Type, len(ix.Indices))
+	for i, e := range ix.Indices {
+		name, ok := e.(*ast.Ident)
+		if !ok {
+			c.errorf(e.Pos(), "receiver type parameter %s must be an identifier", ast.ExprString(e))
+			return Typ[Invalid], nil
+		}
+		rtparams[i] = NewTypeParam(NewTypeName(name.Pos(), name.Name, nil), i, nil)
+		targs[i] = rtparams[i]
+		c.declare(scope, name, rtparams[i].obj)
+	}
+	smap := makeSubstMap(base.tparams, targs)
+	for i, tp := range rtparams {
+		tp.bound = subst(base.tparams[i].bound, smap)
+		base.tparams[i].feeds = append(base.tparams[i].feeds, tp)
+	}
+
+	recv := instance(base, targs)
+	if c.info.Types != nil {
+		c.info.Types[ix] = TypeAndValue{mode: typexpr, Type: recv}
+	}
+	return recv, rtparams
+}
+
+func (c *Checker) funcDecl(obj *Func, d *declInfo) {
+	decl := d.fdecl
+	scope := NewScope(c.scope, "function "+obj.name)
+	c.recordScope(decl.Type, scope)
+
+	var recv *Var
+	var rtparams []*TypeParam
+	if decl.Recv != nil && decl.Recv.NumFields() == 1 {
+		field := decl.Recv.List[0]
+		var recvType Type
+		if ix, ok := field.Type.(*ast.IndexExpr); ok {
+			recvType, rtparams = c.genericRecv(ix, scope)
+		} else {
+			recvType = c.typExpr(field.Type)
+		}
+		name, pos := "", field.Type.Pos()
+		if len(field.Names) > 0 {
+			name, pos = field.Names[0].Name, field.Names[0].Pos()
+		}
+		recv = NewVar(pos, name, recvType)
+		if len(field.Names) > 0 {
+			c.declare(scope, field.Names[0], recv)
+		}
+	}
+	var tparams []*TypeParam
+	if decl.Type.TypeParams != nil {
+		tparams = c.declareTypeParams(decl.Type.TypeParams, scope)
+	}
+	if tparams != nil || rtparams != nil {
+		// the signature may refer to the type parameters
+		oldScope := c.scope
+		c.scope = scope
+		defer func() { c.scope = oldScope }()
+	}
+	sig := c.funcType(decl.Type, recv, scope)
+	sig.tparams, sig.rtparams = tparams, rtparams
+	obj.setType(sig)
+
+	if obj.extern != nil {
+		c.externSig(obj, decl, sig)
+		return
+	}
+	if decl.Body == nil {
+		c.errorf(decl.Name.Pos(), "missing function body")
+		return
+	}
+	if obj.name == "main" || obj.name == "init" {
+		if recv == nil && (len(sig.params) > 0 || len(sig.results) > 0) {
+			c.errorf(decl.Name.Pos(), "func %s must have no arguments and no return values", obj.name)
+		}
+		if recv == nil && tparams != nil {
+			c.errorf(decl.Name.Pos(), "func %s must have no type parameters", obj.name)
+		}
+	}
+
+	filename := c.filename
+	c.bodies = append(c.bodies, func() {
+		c.filename, c.decl = filename, d
+		c.funcBody(scope, sig, decl.Body)
+		c.decl = nil
+	})
+}
+
+// funcBody checks the body of a function with the given signature, whose
+// parameters are declared in scope.
+func (c *Checker) funcBody(scope *Scope, sig *Signature, body *ast.BlockStmt) {
+	oldScope, oldFn := c.scope, c.fn
+	c.scope, c.fn = scope, newFuncContext(sig, scope, body, oldFn)
+	defer func() { c.scope, c.fn = oldScope, oldFn }()
+
+	c.stmtList(body.List)
+	if len(sig.results) > 0 && !isTerminating(body) {
+		c.errorf(body.Rbrace, "missing return")
+	}
+}
+
+func (c *Checker) constDecl(obj *Const, spec *ast.ValueSpec, index int) {
+	var typ Type
+	if spec.Type != nil {
+		typ = c.typExpr(spec.Type)
+	}
+	if index >= len(spec.Values) {
+		obj.setType(Typ[Invalid])
+		return
+	}
+
+	var x operand
+	c.exprWithHint(&x, spec.Values[index], typ)
+	if x.mode == invalid {
+		obj.setType(Typ[Invalid])
+		return
+	}
+	if x.mode != constant_ {
+		c.errorf(x.expr.Pos(), "%s is not constant", &x)
+		obj.setType(Typ[Invalid])
+		return
+	}
+	if typ != nil {
+		if !c.assignment(&x, typ, "constant declaration") {
+			obj.setType(Typ[Invalid])
+			return
+		}
+		x.typ = typ
+	}
+	obj.setType(x.typ)
+	obj.val = x.val
+}
+
+func (c *Checker) varDecl(obj *Var, spec *ast.ValueSpec, index int) {
+	var typ Type
+	if spec.Type != nil {
+		typ = c.typExpr(spec.Type)
+		obj.setType(typ)
+	}
+
+	switch {
+	case len(spec.Values) == 0:
+		if typ == nil {
+			obj.setType(Typ[Invalid])
+		} else if obj.parent == c.pkg.scope && obj.extern == nil && isValid(typ) && !hasZero(typ) {
+			// any function may read the variable before init assigns it
+			c.reportf(obj.pos, UnassignedVar, "package-level variable %s declared without a value (%s has no zero value)", obj.name, typ)
+		}
+
+	case len(spec.Values) == len(spec.Names):
+		var x operand
+		c.exprWithHint(&x, spec.Values[index], typ)
+		c.initVar(obj, &x, "variable declaration")
+
+	default:
+		if index == 0 {
+			c.errorf(spec.Values[0].Pos(), "assignment mismatch: %d variables but %d values", len(spec.Names), len(spec.Values))
+		}
+		obj.setType(Typ[Invalid])
+	}
+}
+
+// varDeclTuple checks a declaration such as `var a, b = f()` whose variables
+// destructure a single tuple value.
+func (c *Checker) varDeclTuple(lhs []*Var, spec *ast.ValueSpec) {
+	var typ Type
+	if spec.Type != nil {
+		typ = c.typExpr(spec.Type)
+		for _, v := range lhs {
+			v.setType(typ)
+		}
+	}
+	var x operand
+	c.expr(&x, spec.Values[0])
+	c.destructure(lhs, &x, "variable declaration")
+}
+
+// declare inserts obj into scope and records it as defined by ident.
+func (c *Checker) declare(scope *Scope, ident *ast.Ident, obj Object) {
+	if ident != nil {
+		c.recordDef(ident, obj)
+	}
+	if obj.Name() == "_" {
+		return
+	}
+	if alt := scope.Insert(obj); alt != nil {
+		c.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
+	}
+}
+
+// openScope starts a new scope for node nested in the current one.
+func (c *Checker) openScope(node ast.Node, comment string) {
+	scope := NewScope(c.scope, comment)
+	c.recordScope(node, scope)
+	c.scope = scope
+}
+
+func (c *Checker) closeScope() {
+	c.scope = c.scope.parent
+}
+
+// lookup resolves name in the current scope, checking the declaration of
+// package-level objects that have not been checked yet.
+func (c *Checker) lookup(name string) Object {
+	_, obj := c.scope.LookupParent(name)
+	if obj != nil {
+		c.objDecl(obj)
+	}
+	return obj
+}
+
+func (c *Checker) record(x *operand) {
+	if c.info.Types == nil || x.expr == nil {
+		return
+	}
+	typ := x.typ
+	if x.mode == invalid || x.mode == novalue || x.mode == builtin {
+		typ = nil
+	}
+	c.info.Types[x.expr] = TypeAndValue{mode: x.mode, Type: typ, Value: x.val}
+}
+
+func (c *Checker) recordDef(ident *ast.Ident, obj Object) {
+	if c.info.Defs != nil {
+		c.info.Defs[ident] = obj
+	}
+}
+
+func (c *Checker) recordUse(ident *ast.Ident, obj Object) {
+	switch obj.(type) {
+	case *Var, *Func:
+		if c.decl != nil && c.objMap[obj] != nil {
+			c.decl.addDep(obj)
+		}
+	}
+	if c.info.Uses != nil {
+		c.info.Uses[ident] = obj
+	}
+}
+
+func (c *Checker) recordSelection(e *ast.SelectorExpr, s *Selection) {
+	if c.info.Selections != nil {
+		c.info.Selections[e] = s
+	}
+}
+
+func (c *Checker) recordScope(node ast.Node, scope *Scope) {
+	if c.info.Scopes != nil {
+		c.info.Scopes[node] = scope
+	}
+}
@@ -0,0 +1,922 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/parser"
+)
+
+func newInfo() *Info {
+	return &Info{
+		Types:      make(map[ast.Expr]TypeAndValue),
+		Defs:       make(map[*ast.Ident]Object),
+		Uses:       make(map[*ast.Ident]Object),
+		Scopes:     make(map[ast.Node]*Scope),
+		Instances:  make(map[*ast.Ident]Instance),
+		Selections: make(map[*ast.SelectorExpr]*Selection),
+	}
+}
+
+// checkSource parses and checks a single file.
+func checkSource(t *testing.T, src string, info *Info) (*Package, error) {
+	t.Helper()
+	file, err := parser.ParseFile("test.gus", strings.NewReader(src))
+	require.NoError(t, err)
+	return Check([]*ast.File{file}, info)
+}
+
+// errorStrings formats the errors of a check as "line:col: message".
+func errorStrings(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return out
+}
+
+// funcSource wraps statements in a package and function.
+func funcSource(stmts string) string {
+	return fmt.Sprintf("package main\n\nfunc main() {\n%s\n}\n", stmts)
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"empty", "package main"},
+		{"hello", "package main\n\nfunc main() {\n\tprint(\"hello\")\n}"},
+		{"forward references", "package main\n\nvar a = b + 1\nvar b = f()\n\nfunc f() int { return 1 }"},
+		{"recursive type", "package main\n\ntype Node struct {\n\tValue int\n\tChildren []Node\n}"},
+		{"recursive enum", "package main\n\ntype List enum {\n\tNil\n\tCons(int, List)\n}\n\nvar l = List.Cons(1, List.Nil)"},
+		{"methods", "package main\n\ntype Point struct{ X, Y int }\n\nfunc (p Point) Sum() int { return p.X + p.Y }\n\nvar s = Point{X => 1, Y => 2}.Sum()"},
+		{"interface", "package main\n\ntype Shape interface{ Area() float }\ntype Square struct{ Side float }\n\nfunc (s Square) Area() float { return s.Side * s.Side }\n\nvar sh Shape = Square{Side => 2.0}"},
+		{"embedded", "package main\n\ntype Base struct{ ID int }\ntype User struct {\n\tBase\n\tName string\n}\n\nfunc id(u User) int { return u.ID }"},
+		{"multiple results", "package main\n\nfunc pair() (int, string) { return 1, \"a\" }\n\nfunc main() {\n\tn, s := pair()\n\tt := pair()\n\tprint(n, s, t.0, t.1)\n}"},
+		{"tuple return", "package main\n\nfunc pair() (int, string) { return 1, \"a\" }\n\nfunc again() (int, string) { return pair() }"},
+		{"tuple argument", "package main\n\nfunc pair() (int, string) { return 1, \"a\" }\nfunc take(n int, s string) {}\n\nfunc main() { take(pair()) }"},
+		{"var destructuring", "package main\n\nfunc pair() (int, string) { return 1, \"a\" }\n\nvar n, s = pair()"},
+		{"arrow inference", "package main\n\nfunc apply(f func(int) int, x int) int { return f(x) }\n\nvar y = apply((x) => x * 2, 3)"},
+		{"arrow result inference", "package main\n\nvar double = (x int) => x * 2\nvar y int = double(2)"},
+		{"arrow block result inference", "package main\n\nfunc Map[T, U any](xs []T, f func(T) U) []U {\n\tout := []U{}\n\tfor _, x := range xs {\n\t\tout = append(out, f(x))\n\t}\n\treturn out\n}\n\nvar ys []int = Map([]int{1}, (x) => {\n\ty := x * 2\n\treturn y\n})\nvar label = (n int) => {\n\tif n > 2 {\n\t\treturn \"big\"\n\t}\n\treturn \"small\"\n}\nvar s string = label(1)"},
+		{"closure", "package main\n\nfunc counter() func() int {\n\tn := 0\n\treturn () => {\n\t\tn++\n\t\treturn n\n\t}\n}"},
+		{"composite literals", "package main\n\ntype Row struct{ A int }\n\nvar rows = []Row{{A => 1}, {2}}\nvar m = map[string][]int{\"a\" => {1, 2}}\nvar a = [3]int{1, 2, 3}"},
+		{"records", "package main\n\ntype P record{ X, Y int }\ntype Q record{ X, Y int }\n\nvar p = P{X => 1, Y => 2}\nvar q Q = p with {X => 3}"},
+		{"comprehensions", "package main\n\nvar xs = []int{1, 2, 3}\nvar ys []int = [x * 2 for x in xs if x > 1]\nvar m map[int]string = {i => \"a\" for i, _ in xs}"},
+		{"control flow", funcSource("\tfor i := 0; i < 10; i++ {\n\t\tif i%2 == 0 {\n\t\t\tcontinue\n\t\t}\n\t\tswitch i {\n\t\t1 => {\n\t\t\tbreak\n\t\t}\n\t\tdefault => print(i)\n\t\t}\n\t}")},
+		{"range", funcSource("\tm := map[string]int{}\n\tfor k, v := range m {\n\t\tprint(k, v)\n\t}\n\tfor i := range 3 {\n\t\tprint(i)\n\t}")},
+		{"match", "package main\n\ntype Shape enum {\n\tCircle(float)\n\tRect(float, float)\n}\n\nfunc area(s Shape) float {\n\treturn match s {\n\t\tShape.Circle(r) => r * r * 3.14\n\t\tShape.Rect(w, h) => w * h\n\t}\n}"},
+		{"match statement", "package main\n\nfunc f(n int) {\n\tmatch n {\n\t\t0 | 1 => print(\"small\")\n\t\tx if x > 10 => print(x)\n\t\t_ => {}\n\t}\n}"},
+		{"constants", "package main\n\nconst a = 1 << 4\nconst b = a * 2\nvar arr [b]int"},
+		{"nil", "package main\n\nvar s ?[]int = nil\nvar ok = s == nil"},
+		{"optional narrowing", "package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name(id int) string {\n\tu := find(id)\n\tif u != nil {\n\t\treturn u.Name\n\t}\n\treturn \"\"\n}"},
+		{"optional early return", "package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name(id int) string {\n\tu := find(id)\n\tif u == nil {\n\t\treturn \"\"\n\t}\n\treturn u.Name\n}"},
+		{"optional conditions", "package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc named(u ?User) bool { return u != nil && u.Name != \"\" }\nfunc unnamed(u ?User) bool { return u == nil || u.Name == \"\" }"},
+		{"optional loop", "package main\n\ntype Node struct {\n\tValue int\n\tNext ?Node\n}\n\nfunc sum(n ?Node) int {\n\ts := 0\n\tfor n != nil {\n\t\ts += n.Value\n\t\tn = n.Next\n\t}\n\treturn s\n}"},
+		{"local types", funcSource("\ttype pair struct{ a, b int }\n\tp := pair{1, 2}\n\tprint(p.a)")},
+		{"missing return after panic", "package main\n\nfunc f() int {\n\tpanic(\"no\")\n}"},
+		{"backed enum", "package main\n\ntype Level enum(int) {\n\tLow\n\tHigh = 10\n}\n\nvar n int = int(Level.High)"},
+		{"generic function", "package main\n\nfunc Keys[K comparable, V any](m map[K]V) []K {\n\tout := []K{}\n\tfor k := range m {\n\t\tout = append(out, k)\n\t}\n\treturn out\n}\n\nvar ks []string = Keys(map[string]bool{})"},
+		{"generic type", "package main\n\ntype List[T any] struct{ Items []T }\n\nfunc (l List[T]) Filter(keep func(T) bool) List[T] {\n\tout := []T{}\n\tfor _, x := range l.Items {\n\t\tif keep(x) {\n\t\t\tout = append(out, x)\n\t\t}\n\t}\n\treturn List[T]{Items => out}\n}\n\ntype User struct{ inactive bool }\n\nvar users = List[User]{}\nvar inactive List[User] = users.Filter((user) => user.inactive)"},
+		{"lambda result inference", "package main\n\nfunc Map[T, U any](xs []T, f func(T) U) []U {\n\tout := []U{}\n\tfor _, x := range xs {\n\t\tout = append(out, f(x))\n\t}\n\treturn out\n}\n\nvar flags []bool = Map([]int{1, 2}, (n) => n > 1)"},
+		{"explicit instantiation", "package main\n\nfunc Convert[To, From any](x From) To { panic(x) }\n\nvar a = Convert[string](1)\nvar b = Convert[string, int](1)"},
+		{"type set operations", "package main\n\ntype Number interface{ ~int | ~float }\ntype Integer interface{ ~int }\ntype Age int\n\nfunc Sum[T Integer](xs []T) T {\n\tvar s T\n\tfor _, x := range xs {\n\t\ts = s + x / 2\n\t}\n\treturn s\n}\n\nfunc Max[T Number](a, b T) T {\n\tvar zero T\n\tif a > b && a != zero {\n\t\treturn a\n\t}\n\treturn b\n}\n\nvar total float = Max(1.5, 2)\nvar age Age = Sum([]Age{})"},
+		{"constraint methods", "package main\n\ntype Stringer interface{ String() string }\ntype Name string\n\nfunc (n Name) String() string { return string(n) }\n\nfunc Join[T Stringer](xs []T) string {\n\ts := \"\"\n\tfor _, x := range xs {\n\t\ts += x.String()\n\t}\n\treturn s\n}\n\nvar j = Join([]Name{})"},
+		{"generic enum", "package main\n\ntype Result[T, E any] enum {\n\tOk(T)\n\tErr(E)\n}\n\nfunc unwrap(r Result[int, string]) int {\n\treturn match r {\n\t\tResult.Ok(n) => n\n\t\tResult.Err(_) => 0\n\t}\n}\n\nvar n = unwrap(Result.Ok[int, string](1))"},
+		{"embedded interface", "package main\n\ntype Reader interface{ Read() string }\ntype ReadCloser interface {\n\tReader\n\tClose()\n}\ntype File struct{}\n\nfunc (f File) Read() string { return \"\" }\nfunc (f File) Close() {}\n\nvar _ ReadCloser = File{}\nvar r Reader = ReadCloser(File{})"},
+		{"untyped constants", "package main\n\nconst a = 1\nconst big = 1 << 100\nconst small = big >> 98\n\nvar f float = a\nvar g float = a/2 + 0.5\nvar n int = small\nvar m int = 3.0\nvar s = string(\"a\" + \"b\")\nconst mask int = 0xff & 0b1010"},
+		{"untyped generic argument", "package main\n\nfunc Max[T ~int | ~float](a, b T) T { return a }\n\nvar f float = Max(1, 2.5)\nvar n int = Max(1, 2)"},
+		{"definite assignment", "package main\n\ntype Shape enum {\n\tCircle(float)\n\tSquare(float)\n}\n\nfunc area(s Shape, big bool) float {\n\tvar n int\n\tvar f func() float\n\tswitch big {\n\ttrue => {\n\t\tf = () => 2.0\n\t}\n\tdefault => {\n\t\tf = () => 1.0\n\t}\n\t}\n\tvar a float\n\tvar g func(float) float\n\tmatch s {\n\t\tShape.Circle(r) => {\n\t\t\tg = (x) => r * x\n\t\t}\n\t\tShape.Square(w) => {\n\t\t\tg = (x) => w * x\n\t\t}\n\t}\n\treturn g(a) * f() + float(n)\n}"},
+		{"symbols", "package main\n\ntype Status :ok | :err | :retry\ntype Result :ok | :err\n\nconst ok = :ok\n\nfunc code(r Result) int {\n\treturn match r {\n\t\t:ok => 0\n\t\t_ => 1\n\t}\n}\n\nvar counts = map[symbol]int{:first => 1, :second => 2}\nvar r Result = ok\nvar st :ok | :err | :retry = r\nvar s symbol = r\nvar status = Status(:retry)\nvar name = string(r) + string(:ok)\nvar same = symbol(\"ok\") == :ok && r != :err\nvar n = code(:err) + counts[s]"},
+		{"extern declarations", "package main\n\n#[extern]\nvar document Document\n\n#[extern]\ntype Document struct {\n\ttitle string\n}\n\n#[optional(\"deep\")]\nfunc (d Document) cloneNode(deep bool) Document\n\n#[extern]\ntype Align enum(string) {\n\tLeft = \"left\"\n}\n\n#[extern(\"date-fns\")]\n#[optional(\"options\")]\n#[rest]\nfunc format(date any, options any, args []any) string\n\n#[extern]\n#[js(\"Date\")]\n#[new]\nfunc newDate[T any](value T) any\n\nfunc main() {\n\tdocument.title = format(0) + format(1, 2, []any{Align.Left})\n\tprint(document.cloneNode().title, newDate(1))\n}"},
+		{"json", "package main\n\ntype Config struct {\n\tName string\n\tPorts []int\n\tLimits map[string]float\n\tOwner ?Owner\n}\ntype Owner record{ ID int64 }\n\nfunc load(c Config) {}\n\nvar raw = #json({\"a\": [1, null]})\nvar c Config = #json({\"Name\": \"api\", \"Ports\": [80, 443], \"Limits\": {\"cpu\": 0.5}, \"Owner\": {\"ID\": 12345678901}})\nvar pair tuple(string, bool) = #json([\"a\", true])\nvar back Config = json_decode(Config, json_encode(c))\nvar text = json_encode(json_decode([]?Owner, \"[null]\")) + json_encode(1) + json_encode(raw)\nvar status :ok | :err = #json(\"ok\")\nvar tags []symbol = json_decode([]symbol, json_encode((:a, status)))\n\nfunc main() { load(#json({\"Owner\": null})) }"},
+		{"jsx", "package main\n\ntype ButtonProps struct {\n\tLabel string\n\tDisabled bool\n\tOnClick func()\n}\n\ntype ListProps record {\n\tTitle ?string\n\tchildren []any\n}\n\nfunc Button(p ButtonProps) any { return <button disabled={p.Disabled} onClick={p.OnClick}>{p.Label}</button> }\nfunc List(p ListProps) any { return <ul>{p.children}</ul> }\nfunc Logo() any { return <img src=\"logo.svg\" /> }\n\nfunc App(names []string) any {\n\treturn <>\n\t\t<Logo />\n\t\t<List Title=\"names\">\n\t\t\t<Button Label=\"ok\" Disabled OnClick={() => print(1)} />\n\t\t\t{len(names)} names\n\t\t</List>\n\t\t<List key={1}>{[<li key={n}>{n}</li> for n in names]}</List>\n\t</>\n}"},
+		{"generic recursive type", "package main\n\ntype Tree[T any] struct {\n\tValue T\n\tKids []Tree[T]\n}\n\nfunc (t Tree[T]) Size() int {\n\tn := 1\n\tfor _, k := range t.Kids {\n\t\tn += k.Size()\n\t}\n\treturn n\n}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := checkSource(t, tc.input, newInfo())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		errors []string
+	}{
+		{
+			"undefined",
+			funcSource("\tprint(x)"),
+			[]string{"4:8: undefined: x"},
+		},
+		{
+			"redeclared",
+			"package main\n\nvar a int\nvar a string",
+			[]string{"4:5: a redeclared in this block"},
+		},
+		{
+			"mismatched types",
+			funcSource("\tx := 1 + \"a\"\n\tprint(x)"),
+			[]string{`4:9: invalid operation: 1 + "a" (mismatched types untyped int and untyped string)`},
+		},
+		{
+			"assignment",
+			"package main\n\nvar s string = 1",
+			[]string{"3:16: cannot use 1 (untyped int constant) as string value in variable declaration"},
+		},
+		{
+			"argument",
+			"package main\n\nfunc f(s string) {}\n\nfunc main() { f(true) }",
+			[]string{"5:17: cannot use true (untyped bool constant) as string value in argument to f"},
+		},
+		{
+			"argument count",
+			"package main\n\nfunc f(a, b int) {}\n\nfunc main() {\n\tf(1)\n\tf(1, 2, 3)\n}",
+			[]string{
+				"6:5: not enough arguments in call to f: have 1, want 2",
+				"7:10: too many arguments in call to f: have 3, want 2",
+			},
+		},
+		{
+			"missing field",
+			"package main\n\ntype P struct{ X int }\n\nvar y = P{}.Y",
+			[]string{"5:13: P{…}.Y undefined (type P has no field or method Y)"},
+		},
+		{
+			"unknown variant",
+			"package main\n\ntype Color enum {\n\tRed\n}\n\nvar c = Color.Blue",
+			[]string{"7:15: Color.Blue undefined (type Color has no variant Blue)"},
+		},
+		{
+			"not a type",
+			"package main\n\nvar x int\nvar y x",
+			[]string{"4:7: x is not a type"},
+		},
+		{
+			"recursive type",
+			"package main\n\ntype T struct{ next T }",
+			[]string{"3:6: invalid recursive type T"},
+		},
+		{
+			"initialization cycle",
+			"package main\n\nvar a = b\nvar b = a",
+			[]string{"3:5: initialization cycle: a refers to itself"},
+		},
+		{
+			"initialization cycle through functions",
+			"package main\n\ntype T struct{}\n\nfunc (T) m() int { return f() }\n\nvar a = g()\n\nfunc g() int { return b }\n\nvar b = a + 1\n\nfunc f() int { return c }\n\nvar c = T{}.m()",
+			[]string{
+				"7:5: initialization cycle: a refers to g, g refers to b, b refers to a",
+				"15:5: initialization cycle: c refers to m, m refers to f, f refers to c",
+			},
+		},
+		{
+			"missing return",
+			"package main\n\nfunc f() int {\n\tprint(1)\n}",
+			[]string{"5:1: missing return"},
+		},
+		{
+			"return count",
+			"package main\n\nfunc f() (int, string) {\n\treturn 1\n}",
+			[]string{"4:9: not enough return values: have 1, want 2"},
+		},
+		{
+			"destructuring mismatch",
+			"package main\n\nfunc pair() (int, string) { return 1, \"a\" }\n\nfunc main() {\n\ta, b, c := pair()\n\tprint(a, b, c)\n}",
+			[]string{"6:13: assignment mismatch: 3 variables but pair() returns 2 values"},
+		},
+		{
+			"no new variables",
+			funcSource("\tx := 1\n\tx := 2\n\tprint(x)"),
+			[]string{"5:4: no new variables on left side of :="},
+		},
+		{
+			"non-boolean condition",
+			funcSource("\tif 1 {\n\t}"),
+			[]string{"4:5: non-boolean condition in if statement"},
+		},
+		{
+			"break outside loop",
+			funcSource("\tbreak"),
+			[]string{"4:2: break is not in a loop or switch"},
+		},
+		{
+			"record field assignment",
+			"package main\n\ntype P record{ X int }\n\nfunc main() {\n\tp := P{X => 1}\n\tp.X = 2\n}",
+			[]string{"7:4: cannot assign to p.X (fields of record type P are immutable)"},
+		},
+		{
+			"omitted fields without zero values",
+			"package main\n\ntype Shower interface{ Show() string }\ntype H struct {\n\ts Shower\n\tn int\n}\ntype R record {\n\tA int\n\tS symbol\n\tF ?func()\n}\n\nvar a = H{}\nvar b = R{A => 1}\nvar c = [2]symbol{:x}\nvar d = []Shower{2 => H{}.s}\nvar e = []symbol{:x, :y}\nvar f R = #json({\"A\": 1})",
+			[]string{
+				"14:11: missing field s in struct literal of type H (Shower has no zero value)",
+				"15:17: missing field S in struct literal of type R (symbol has no zero value)",
+				"16:21: missing elements in literal of type [2]symbol (symbol has no zero value)",
+				"17:25: missing field s in struct literal of type H (Shower has no zero value)",
+				"17:28: missing elements in literal of type []Shower (Shower has no zero value)",
+				"19:17: missing field \"S\" in #json literal of type R (symbol has no zero value)",
+			},
+		},
+		{
+			"nested record field assignment",
+			"package main\n\ntype In struct{ n int }\ntype R record {\n\tinr In\n\txs []int\n\tm map[string]In\n}\n\nfunc main() {\n\tr := R{inr => In{1}, xs => []int{1}, m => map[string]In{}}\n\tr.inr.n = 3\n\tr.inr.n++\n\tr.xs[0] = 2\n\t(r).m[\"a\"] = In{2}\n\ts := r.inr\n\ts.n = 4\n}",
+			[]string{
+				"12:4: cannot assign to r.inr.n (fields of record type R are immutable)",
+				"13:4: cannot assign to r.inr.n (fields of record type R are immutable)",
+				"14:4: cannot assign to r.xs[0] (fields of record type R are immutable)",
+				"15:6: cannot assign to (r).m[\"a\"] (fields of record type R are immutable)",
+			},
+		},
+		{
+			"tuple element assignment",
+			funcSource("\tt := (1, 2)\n\tt.0 = 3"),
+			[]string{"5:4: cannot assign to t.0 (tuple elements are immutable)"},
+		},
+		{
+			"tuple index out of range",
+			funcSource("\tt := (1, 2)\n\tprint(t.2)"),
+			[]string{"5:10: invalid argument: index 2 out of bounds [0:2]"},
+		},
+		{
+			"with on struct",
+			"package main\n\ntype P struct{ X int }\n\nvar p = P{} with {X => 1}",
+			[]string{"5:13: invalid operation: P{…} (value of type P) is not a record"},
+		},
+		{
+			"named types",
+			"package main\n\ntype A int\n\nvar a A = 1\nvar b int = a",
+			[]string{"6:13: cannot use a (variable of type A) as int value in variable declaration"},
+		},
+		{
+			"interface missing method",
+			"package main\n\ntype Shape interface{ Area() float }\ntype Square struct{}\n\nvar s Shape = Square{}",
+			[]string{"6:15: cannot use Square{…} (value of type Square) as Shape value in variable declaration (missing method Area)"},
+		},
+		{
+			"interface missing methods",
+			"package main\n\ntype Pair interface {\n\tFirst() string\n\tSecond(bool) int\n}\ntype T struct{}\n\nvar _ Pair = T{}",
+			[]string{"9:14: cannot use T{…} (value of type T) as Pair value in variable declaration (missing methods First, Second)"},
+		},
+		{
+			"interface wrong method type",
+			"package main\n\ntype Pair interface {\n\tFirst() string\n\tSecond(bool) int\n}\ntype T struct{ Second int }\n\nfunc (t T) First() int { return 0 }\n\nvar _ Pair = T{}",
+			[]string{"11:14: cannot use T{…} (value of type T) as Pair value in variable declaration (wrong type for method First: have First() int, want First() string; Second is a field, not a method)"},
+		},
+		{
+			"interface missing embedded method",
+			"package main\n\ntype Reader interface{ Read() string }\ntype ReadCloser interface {\n\tReader\n\tClose()\n}\ntype File struct{}\n\nfunc (f File) Close() {}\n\nvar _ ReadCloser = File{}",
+			[]string{"12:20: cannot use File{…} (value of type File) as ReadCloser value in variable declaration (missing method Read)"},
+		},
+		{
+			"duplicate embedded method",
+			"package main\n\ntype A interface{ M() int }\ntype B interface {\n\tA\n\tM() string\n}",
+			[]string{"5:2: duplicate method M"},
+		},
+		{
+			"interface embedding cycle",
+			"package main\n\ntype A interface{ B }\ntype B interface{ A }",
+			[]string{"3:6: invalid recursive type A"},
+		},
+		{
+			"nil without optional",
+			"package main\n\nvar t any = nil\nvar s []int = nil",
+			[]string{
+				"3:13: cannot use nil (untyped nil value) as any value in variable declaration",
+				"4:15: cannot use nil (untyped nil value) as []int value in variable declaration",
+			},
+		},
+		{
+			"optional dereference",
+			"package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name(u ?User) string { return u.Name }\nfunc first(xs ?[]int) int { return xs[0] }\nfunc call(f ?func()) { f() }",
+			[]string{
+				"12:36: invalid operation: u.Name (u may be nil)",
+				"13:36: invalid operation: xs[0] (xs may be nil)",
+				"14:24: invalid operation: f() (f may be nil)",
+			},
+		},
+		{
+			"optional narrowing ends",
+			"package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name() string {\n\tu := find(1)\n\tif u != nil {\n\t\tu = find(2)\n\t\treturn u.Name\n\t}\n\tfor i := 0; i < 2; i++ {\n\t\tif u == nil {\n\t\t\tcontinue\n\t\t}\n\t\tprint(u.Name)\n\t\tu = nil\n\t}\n\treturn u.Name\n}",
+			[]string{
+				"16:10: invalid operation: u.Name (u may be nil)",
+				"25:9: invalid operation: u.Name (u may be nil)",
+			},
+		},
+		{
+			"optional closure",
+			"package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name(u ?User) func() string {\n\tif u == nil {\n\t\treturn () => \"\"\n\t}\n\treturn () => u.Name\n}",
+			[]string{"16:15: invalid operation: u.Name (u may be nil)"},
+		},
+		{
+			"optional assigned by closure",
+			"package main\n\ntype User struct{ Name string }\n\nfunc find(id int) ?User {\n\tif id == 0 {\n\t\treturn nil\n\t}\n\treturn User{Name => \"a\"}\n}\n\nfunc name() string {\n\tu := find(1)\n\treset := () => {\n\t\tu = nil\n\t}\n\tif u != nil {\n\t\treset()\n\t\treturn u.Name\n\t}\n\treturn \"\"\n}\n\nfunc greet(u ?User) func() string {\n\treturn () => {\n\t\tif u != nil {\n\t\t\treturn u.Name\n\t\t}\n\t\treturn \"\"\n\t}\n}\n\nfunc later() func() string {\n\tu := find(1)\n\tvar f func() string = () => {\n\t\tif u != nil {\n\t\t\treturn u.Name\n\t\t}\n\t\treturn \"\"\n\t}\n\tu = nil\n\treturn f\n}",
+			[]string{
+				"19:10: invalid operation: u.Name (u may be nil)",
+				"37:11: invalid operation: u.Name (u may be nil)",
+			},
+		},
+		{
+			"redundant optional",
+			"package main\n\nvar x ??int",
+			[]string{"3:7: invalid optional type ??int (?int is already optional)"},
+		},
+		{
+			"optional type argument",
+			"package main\n\nfunc First[T any](xs []T) ?T {\n\tif len(xs) == 0 {\n\t\treturn nil\n\t}\n\treturn xs[0]\n}\n\nfunc Some[T any](xs []T) bool { return First(xs) != nil }\n\nfunc Id[T any](x T) T { return x }\n\ntype Box[T any] struct{ v T }\n\nfunc (b Box[T]) Get() ?T { return b.v }\n\nvar b Box[?int]\n\nfunc main() {\n\tprint(First([]?int{nil}), Some[?int]([]?int{}), Id[?int](nil), b.Get())\n}",
+			[]string{
+				"18:11: cannot use optional type ?int as T (?T would be ??int)",
+				"21:8: cannot use optional type ?int as T (?T would be ??int)",
+				"21:33: cannot use optional type ?int as T (?T would be ??int)",
+			},
+		},
+		{
+			"untyped arrow parameter",
+			"package main\n\nvar f = (x) => x",
+			[]string{"3:10: cannot infer type of parameter x"},
+		},
+		{
+			"arrow block result mismatch",
+			"package main\n\nvar f = (n int) => {\n\tif n > 2 {\n\t\treturn \"big\"\n\t}\n\treturn n\n}\n\nvar g = () => {\n\treturn nil\n}",
+			[]string{
+				"7:9: cannot use n (variable of type int) as string value in return statement",
+				"11:9: use of untyped nil in return statement",
+			},
+		},
+		{
+			"match arm types",
+			"package main\n\nfunc f(n int) int {\n\treturn match n {\n\t\t0 => \"zero\"\n\t\t_ => n\n\t}\n}",
+			[]string{`5:8: cannot use "zero" (untyped string constant) as int value in match arm`},
+		},
+		{
+			"pattern type",
+			"package main\n\nfunc f(n int) {\n\tmatch n {\n\t\t\"a\" => print(1)\n\t\t_ => print(2)\n\t}\n}",
+			[]string{`5:3: cannot use "a" (untyped string constant) as int value in match pattern`},
+		},
+		{
+			"division by zero",
+			"package main\n\nconst x = 1 / 0",
+			[]string{"3:15: invalid operation: division by zero"},
+		},
+		{
+			"unused value",
+			funcSource("\tx := 1\n\tx + 1"),
+			[]string{"5:2: x + 1 is not used"},
+		},
+		{
+			"range variables",
+			funcSource("\tfor i, x := range 10 {\n\t\tprint(i, x)\n\t}"),
+			[]string{"4:9: range over 10 (constant of type int) permits only one iteration variable"},
+		},
+		{
+			"unsatisfied type set",
+			"package main\n\ntype Number interface{ ~int | ~float }\n\nfunc Sum[T Number](xs []T) {}\n\nfunc main() { Sum([]string{}) }",
+			[]string{"7:15: string does not satisfy Number (string missing in ~int | ~float)"},
+		},
+		{
+			"unsatisfied methods",
+			"package main\n\ntype Stringer interface{ String() string }\ntype Box[T Stringer] struct{ V T }\n\nvar b Box[int]",
+			[]string{"6:11: int does not satisfy Stringer (missing method String)"},
+		},
+		{
+			"not comparable",
+			"package main\n\nfunc Index[T comparable](xs []T, x T) int { return 0 }\n\nvar i = Index([][]int{}, []int{})",
+			[]string{"5:9: []int does not satisfy comparable"},
+		},
+		{
+			"cannot infer",
+			"package main\n\nfunc Zero[T any]() T {\n\tpanic(\"zero\")\n}\n\nvar z = Zero()",
+			[]string{"7:14: in call to Zero, cannot infer T"},
+		},
+		{
+			"erased type parameters",
+			"package main\n\ntype Number interface{ ~int | ~int64 | ~float }\n\nfunc F[T Number, U ~int | ~float](a T, b U) {\n\tvar s T\n\t_ = a + s\n\t_ = -b\n\tb++\n\tb += b\n\t_ = a == 1\n\t_ = T(2)\n\t_ = float(b)\n\t_ = b < 1\n}",
+			[]string{
+				"7:8: invalid operation: operator + not defined on a (variable of type T) (int and int64 arithmetic differ in JavaScript)",
+				"7:10: s used before assignment",
+				"8:6: invalid operation: operator - not defined on b (variable of type U) (int and float arithmetic differ in JavaScript)",
+				"9:3: invalid operation: operator ++ not defined on b (variable of type U) (int and float arithmetic differ in JavaScript)",
+				"10:4: invalid operation: operator + not defined on b (variable of type U) (int and float arithmetic differ in JavaScript)",
+				"11:11: cannot convert 1 (untyped int constant) to type T (int and int64 constants differ in JavaScript)",
+				"12:8: cannot convert 2 (untyped int constant) to type T (int and int64 constants differ in JavaScript)",
+				"13:12: cannot convert b (variable of type U) to type float (int and float values convert differently in JavaScript)",
+			},
+		},
+		{
+			"generic without instantiation",
+			"package main\n\ntype List[T any] []T\n\nfunc Id[T any](x T) T { return x }\n\nvar l List\nvar f = Id",
+			[]string{
+				"7:7: cannot use generic type List without instantiation",
+				"8:9: cannot use generic function Id without instantiation",
+			},
+		},
+		{
+			"type argument count",
+			"package main\n\ntype Pair[K, V any] struct{}\n\nvar p Pair[int]\nvar q Pair[int, int, int]",
+			[]string{
+				"5:15: not enough type arguments for type Pair: have 1, want 2",
+				"6:22: too many type arguments for type Pair: have 3, want 2",
+			},
+		},
+		{
+			"constraint interface as type",
+			"package main\n\ntype Number interface{ ~int | ~float }\n\nvar n Number",
+			[]string{"5:7: cannot use type Number outside a type constraint: interface contains type constraints"},
+		},
+		{
+			"type parameter operations",
+			"package main\n\nfunc Add[T any](a, b T) T { return a + b }\nfunc Eq[T any](a, b T) bool { return a == b }",
+			[]string{
+				"3:38: invalid operation: operator + not defined on a (variable of type T)",
+				"4:40: invalid operation: a == b (T cannot be compared)",
+			},
+		},
+		{
+			"invalid tilde",
+			"package main\n\ntype Age int\n\nfunc f[T ~Age](x T) {}",
+			[]string{"5:11: invalid use of ~ (underlying type of Age is int)"},
+		},
+		{
+			"constant overflow",
+			"package main\n\nvar x int = 1 << 70\nvar y = 1 << 70",
+			[]string{
+				"3:13: cannot use 1 << 70 (untyped int constant 1180591620717411303424) as int value in variable declaration (overflows)",
+				"4:9: cannot use 1 << 70 (untyped int constant 1180591620717411303424) as int value in variable declaration (overflows)",
+			},
+		},
+		{
+			"int range",
+			"package main\n\nvar a int = 2147483647\nvar b int = 2147483648\nvar c int64 = 2147483648",
+			[]string{"4:13: cannot use 2147483648 (untyped int constant) as int value in variable declaration (overflows)"},
+		},
+		{
+			"mixed integer types",
+			"package main\n\nvar a int = 1\nvar b int64 = 2\nvar c = a + b\nvar d = int64(a) + b",
+			[]string{"5:11: invalid operation: a + b (mismatched types int and int64)"},
+		},
+		{
+			"constant truncated",
+			"package main\n\nvar n int = 1.5\nvar m = int(2.5)",
+			[]string{
+				"3:13: cannot use 1.5 (untyped float constant) as int value in variable declaration (truncated)",
+				"4:13: cannot convert 2.5 (untyped float constant) to type int (truncated)",
+			},
+		},
+		{
+			"conversion overflow",
+			"package main\n\nvar x = int(1 << 70)",
+			[]string{"3:13: cannot convert 1 << 70 (untyped int constant 1180591620717411303424) to type int (overflows)"},
+		},
+		{
+			"typed constant overflow",
+			"package main\n\nconst m int = 1 << 30\nconst n = m * 2\nconst l int64 = 1 << 62\nconst k = l * 2",
+			[]string{
+				"4:13: m * 2 (constant 2147483648 of type int) overflows int",
+				"6:13: l * 2 (constant 9223372036854775808 of type int64) overflows int64",
+			},
+		},
+		{
+			"untyped constant too large",
+			"package main\n\nconst huge = 1 << 1000",
+			[]string{"3:16: constant overflow"},
+		},
+		{
+			"unused variables",
+			funcSource("\tx := 1\n\tvar y int\n\ty = 2\n\tz := 0\n\tz++\n\tfor i, v := range []int{} {\n\t\tprint(v)\n\t}\n\tf := (n int) => [0 for k in []int{n}]\n\tprint(f)"),
+			[]string{
+				"4:2: declared and not used: x",
+				"5:6: declared and not used: y",
+				"7:2: declared and not used: z",
+				"9:6: declared and not used: i",
+				"12:25: declared and not used: k",
+			},
+		},
+		{
+			"used before assignment",
+			"package main\n\ntype Shape interface{ Area() float }\ntype Square struct{}\n\nfunc (s Square) Area() float { return 1 }\n\nfunc f(c bool) float {\n\tvar s, t, u, v Shape\n\tif c {\n\t\ts = Square{}\n\t}\n\tif c {\n\t\tt = Square{}\n\t} else {\n\t\tt = Square{}\n\t}\n\tfor c {\n\t\tu = Square{}\n\t}\n\tg := () => v.Area()\n\tv = Square{}\n\treturn s.Area() + t.Area() + u.Area() + g()\n}",
+			[]string{
+				"21:13: v used before assignment",
+				"23:9: s used before assignment",
+				"23:31: u used before assignment",
+			},
+		},
+		{
+			"package-level variable without value",
+			"package main\n\ntype Shower interface{ Show() string }\n\nvar g Shower\nvar n int\nvar h, _ func()\n\n#[extern]\nvar document Shower\n\nfunc main() { print(g.Show(), n, h, document) }",
+			[]string{
+				"5:5: package-level variable g declared without a value (Shower has no zero value)",
+				"7:5: package-level variable h declared without a value (func() has no zero value)",
+			},
+		},
+		{
+			"unreachable code",
+			"package main\n\nfunc f(n int) int {\n\tfor {\n\t\tif n > 0 {\n\t\t\tbreak\n\t\t\tn--\n\t\t}\n\t\treturn n\n\t\tprint(n)\n\t\tprint(n)\n\t}\n\treturn 0\n}",
+			[]string{
+				"7:4: unreachable code",
+				"10:3: unreachable code",
+			},
+		},
+		{
+			"unused result",
+			"package main\n\ntype Parser struct{}\n\n#[must_use]\nfunc (p Parser) Next() (int, bool) { return 0, false }\n\n#[must_use]\nfunc parse(s string) int { return 0 }\n\nfunc main() {\n\tparse(\"1\")\n\t_ = parse(\"2\")\n\tParser{}.Next()\n}",
+			[]string{
+				"12:2: result of parse(\"1\") is not used",
+				"14:2: result of Parser{…}.Next() is not used",
+			},
+		},
+		{
+			"attributes",
+			"package main\n\n#[must_use]\nfunc f() {}\n\n#[inline]\nfunc g() {}",
+			[]string{
+				"3:1: must_use function f has no results",
+				"6:1: unknown attribute inline",
+			},
+		},
+		{
+			"extern declarations",
+			"package main\n\n#[extern(\"m\")]\nfunc f(a int) int { return a }\n\nfunc g()\n\n#[js(\"h\")]\nfunc h()\n\n#[extern]\n#[new]\n#[optional(\"a\", \"c\")]\nfunc k(a, b int, c string)\n\n#[extern]\n#[rest]\nfunc r(xs int)\n\n#[extern]\nvar v = 1\n\n#[extern]\ntype T int\n\n#[extern]\ntype E struct{ T }\n\n#[extern]\nconst c = 1\n\nfunc (e E) m() {}\n\nfunc main() { k(1) }",
+			[]string{
+				"4:6: extern function f has a body",
+				"6:6: missing function body",
+				"8:1: attribute js applies only to extern functions",
+				"9:6: missing function body",
+				"14:6: new function k must have exactly one result",
+				"14:11: parameter b must be optional, as it follows an optional parameter",
+				"18:8: rest parameter xs must be a slice, not int",
+				"21:5: extern variable v must have a type",
+				"21:9: extern variable v has a value",
+				"24:6: extern type T must be a struct or an enum(int) or enum(string)",
+				"27:16: extern type E cannot embed T",
+				"29:1: attribute extern does not apply to constants",
+				"32:12: method m of an extern type has a body",
+				"34:18: not enough arguments in call to k: have 1, want 3",
+			},
+		},
+		{
+			"method expressions",
+			"package main\n\ntype P struct{ X int }\ntype L[T any] struct{}\n\nfunc (l L[T]) Len() int { return 0 }\n\nvar x = P.X\nvar n = L.Len\nvar y = P.Y",
+			[]string{
+				"8:11: P.X undefined (type P has no method X)",
+				"9:9: cannot use generic type L without instantiation",
+				"10:11: P.Y undefined (type P has no method Y)",
+			},
+		},
+		{
+			"symbol sets",
+			"package main\n\ntype Status :ok | :err | :ok\ntype Result :ok | :err\n\nvar r Result = :retry\nvar s = :ok\nvar t Result = s\nvar u Status = r\nvar v = Result(:retry)\nvar less = :a < :b\nvar same = r == :retry",
+			[]string{
+				"3:26: duplicate symbol :ok in symbol set",
+				"6:16: cannot use :retry (untyped symbol constant) as Result value in variable declaration (not in the set)",
+				"8:16: cannot use s (variable of type symbol) as Result value in variable declaration",
+				"9:16: cannot use r (variable of type Result) as Status value in variable declaration",
+				"10:16: cannot convert :retry (untyped symbol constant) to type Result (not in the set)",
+				"11:15: invalid operation: :a < :b (operator < not defined on untyped symbol)",
+				"12:17: cannot convert :retry (untyped symbol constant) to type Result (not in the set)",
+			},
+		},
+		{
+			"duplicate enum values",
+			"package main\n\nconst one = 1\n\ntype Num enum(int) {\n\tOne = 1\n\tUno = 2 - 1\n\tTwo\n\tDos = one + 1\n}\n\ntype Ratio enum(float) {\n\tHalf = 0.5\n\tOne = 1\n\tWhole = 2 * 0.5\n}\n\ntype Word enum(string) {\n\tHi\n\tHello = \"H\" + \"i\"\n}\n\nconst done = :ok\n\ntype Status enum(symbol) {\n\tOk = :ok\n\tFine = done\n}\n\ntype Pair enum(int, int) {\n\tA = (1, 2)\n\tB = (one, 2)\n}",
+			[]string{
+				"7:2: duplicate value 1 for enum variants One and Uno",
+				"9:2: duplicate value 2 for enum variants Two and Dos",
+				"15:2: duplicate value 1 for enum variants One and Whole",
+				"20:2: duplicate value \"Hi\" for enum variants Hi and Hello",
+				"27:2: duplicate value :ok for enum variants Ok and Fine",
+				"32:2: duplicate value (1, 2) for enum variants A and B",
+			},
+		},
+		{
+			"json",
+			"package main\n\ntype Config struct {\n\tName string\n\tPorts [2]int\n}\n\nvar a Config = #json({\"Name\": 1})\nvar b Config = #json({\"Port\": 1})\nvar c Config = #json({\"Ports\": [1]})\nvar d []int = #json([1.5, 3000000000])\nvar e map[int]int = #json({})\nvar f interface{ Len() int } = #json(1)\nvar g :ok | :err = #json(\"retry\")",
+			[]string{
+				"8:31: cannot use JSON number as string value in #json literal",
+				"9:31: unknown field \"Port\" in #json literal of type Config",
+				"10:32: JSON array of length 1 in #json literal of type [2]int",
+				"11:22: JSON number 1.5 truncated int",
+				"11:27: JSON number 3000000000 overflows int",
+				"12:27: invalid #json literal type map[int]int: map keys must be strings",
+				"13:38: cannot use JSON number as interface{Len() int} value in #json literal",
+				"14:26: cannot use JSON string \"retry\" as :ok | :err value in #json literal (not in the set)",
+			},
+		},
+		{
+			"runtime json",
+			"package main\n\ntype Handler struct {\n\tName string\n\tRun func()\n}\n\nfunc load[T any](s string) T {\n\treturn json_decode(T, s)\n}\n\nvar a = json_decode(Handler, \"{}\")\nvar b = json_encode(map[int]string{})\nvar c = json_encode(func() {})\nvar d = json_decode(int, 1)",
+			[]string{
+				"9:21: cannot decode T from JSON: T is a type parameter",
+				"12:21: cannot decode Handler from JSON: func() has no JSON values",
+				"13:21: cannot encode map[int]string as JSON",
+				"14:21: cannot encode func() as JSON",
+				"15:26: cannot use 1 (untyped int constant) as string value in argument to json_decode",
+			},
+		},
+		{
+			"jsx",
+			"package main\n\ntype Props struct {\n\tLabel string\n\tchildren string\n}\n\nfunc C(p Props) any { return <b /> }\nfunc Empty() any { return <b /> }\nfunc Two(a, b int) any { return <b /> }\n\nvar N = 1\nvar a = <C Label={N} Size=\"2\" />\nvar b = <C Label>x{N}</C>\nvar c = <Empty title=\"x\">y</Empty>\nvar d = <Two />\nvar e = <N key={1.5} />\nvar f = <div key={nil}>{undefined}</div>\nvar Cf = C\nvar g = <Cf Label=\"x\" />",
+			[]string{
+				"13:19: cannot use N (variable of type int) as string value in JSX attribute",
+				"13:22: unknown field Size in props of type Props",
+				"14:12: missing value for prop Label of type string",
+				"14:20: too many JSX children for field children of type string",
+				"15:16: unknown prop title: Empty takes no props",
+				"15:26: Empty takes no children: its props have no field children",
+				"16:10: cannot use Two (value of type func(a int, b int) any) as JSX component: components take their props and return what they render",
+				"17:10: cannot use N (variable of type int) as JSX component: not a function",
+				"18:19: invalid JSX key nil (untyped nil value): must be a string or an integer",
+				"18:25: undefined: undefined",
+				"20:10: cannot use Cf (variable of type func(p Props) any) as JSX component: props of named type Props require a function declared by the package",
+			},
+		},
+		{
+			"tuple var mismatch",
+			"package main\n\nvar a, b = 1",
+			[]string{"3:12: assignment mismatch: 2 variables but 1 value"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := checkSource(t, tc.input, nil)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrType)
+			assert.Equal(t, tc.errors, errorStrings(t, err))
+		})
+	}
+}
+
+func TestCheckErrorCodes(t *testing.T) {
+	src := "package main\n\n#[must_use]\nfunc f() int { return 0 }\n\nfunc g() {\n\tx := 1\n\tvar h func()\n\tf()\n\th()\n\treturn\n\tprint(0)\n}"
+	_, err := checkSource(t, src, nil)
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	codes := make([]Code, len(list))
+	for i, e := range list {
+		codes[i] = e.Code
+	}
+	assert.Equal(t, []Code{UnusedVar, UnusedResult, UnassignedVar, UnreachableCode}, codes)
+	assert.Equal(t, "test.gus:7:2: declared and not used: x [unused-var]", list[0].Error())
+}
+
+func TestCheckMatch(t *testing.T) {
+	const decls = `package main
+
+type Color enum {
+	Red
+	Green
+	Blue
+}
+
+type Shape enum {
+	Circle(radius float)
+	Rect(w, h float)
+}
+
+type Result :ok | :err
+
+func f(c Color, s Shape, b bool, n int, ok bool, r Result) any {
+	return `
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   string // "" if the match is exhaustive
+	}{
+		{"variants", "match c {\n\tColor.Red | Color.Green => 1\n\tColor.Blue => 2\n}", ""},
+		{"bool", "match (b, ok) {\n\t(true, _) => 1\n\t(false, true) => 2\n\t(_, false) => 3\n}", ""},
+		{"symbols", "match r {\n\t:ok => 1\n\t:err => 2\n}", ""},
+		{"records", "match s {\n\tShape.Circle(_) => 1\n\tShape.Rect(w, _) if w > 1 => 2\n\tShape.Rect(_, _) => 3\n}", ""},
+		{
+			"missing variants",
+			"match c {\n\tColor.Red => 1\n}",
+			"17:9: match is not exhaustive: missing Color.Green, Color.Blue",
+		},
+		{
+			"missing payload variant",
+			"match s {\n\tShape.Circle(r) => r\n}",
+			"17:9: match is not exhaustive: missing Shape.Rect(_, _)",
+		},
+		{
+			"missing symbol",
+			"match r {\n\t:ok => 1\n}",
+			"17:9: match is not exhaustive: missing :err",
+		},
+		{
+			"guarded arm",
+			"match c {\n\tColor.Red => 1\n\tColor.Green => 2\n\tColor.Blue if ok => 3\n}",
+			"17:9: match is not exhaustive: missing Color.Blue",
+		},
+		{
+			"nested",
+			"match (c, b) {\n\t(Color.Red, _) => 1\n\t(_, true) => 2\n}",
+			"17:9: match is not exhaustive: missing (Color.Green, false), (Color.Blue, false)",
+		},
+		{
+			"literals",
+			"match n {\n\t1 => 1\n\t2 => 2\n}",
+			"17:9: match is not exhaustive: add a _ arm",
+		},
+		{
+			"unreachable arm",
+			"match c {\n\t_ => 0\n\tColor.Red => 1\n}",
+			"19:2: unreachable match arm",
+		},
+		{
+			"unreachable literal",
+			"match n {\n\t1 | 2 => 0\n\t-1 => 1\n\t2 => 2\n\t_ => 3\n}",
+			"20:2: unreachable match arm",
+		},
+		{
+			"unreachable alternative",
+			"match c {\n\tColor.Red => 0\n\tColor.Blue | Color.Red => 1\n\t_ => 2\n}",
+			"19:15: unreachable alternative in or-pattern",
+		},
+		{
+			"unknown variant",
+			"match c {\n\tColor.Purple => 1\n\t_ => 2\n}",
+			"18:8: Color.Purple undefined (type Color has no variant Purple)",
+		},
+		{
+			"payload arity",
+			"match s {\n\tShape.Rect(w) => w\n\t_ => 0\n}",
+			"18:12: wrong number of fields in pattern Shape.Rect: have 1, want 2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := checkSource(t, decls+tc.input+"\n}\n", nil)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, []string{tc.err}, errorStrings(t, err))
+		})
+	}
+}
+
+// TestCheckMatchFiles checks that a match on an enum declared in another
+// file of the package is exhaustive.
+func TestCheckMatchFiles(t *testing.T) {
+	var files []*ast.File
+	for i, src := range []string{
+		"package main\n\ntype Color enum {\n\tRed\n\tGreen\n}",
+		"package main\n\nfunc name(c Color) string {\n\treturn match c {\n\t\tColor.Red => \"red\"\n\t\tColor.Green => \"green\"\n\t}\n}",
+	} {
+		file, err := parser.ParseFile(fmt.Sprintf("f%d.gus", i), strings.NewReader(src))
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+	_, err := Check(files, nil)
+	assert.NoError(t, err)
+}
+
+func TestCheckTypes(t *testing.T) {
+	testCases := []struct {
+		expr string
+		typ  string
+	}{
+		{"1", "int"},
+		{"1.5", "float"},
+		{"1 + 2.5", "float"},
+		{"1.0 << 3", "int"},
+		{"7 / 2", "int"},
+		{"7 / 2.0", "float"},
+		{"int64(1) << 40", "int64"},
+		{"-7 % 2", "int"},
+		{`"s"`, "string"},
+		{"`t`", "string"},
+		{":sym", "symbol"},
+		{":a == :b", "bool"},
+		{"string(:sym)", "string"},
+		{"symbol(\"sym\")", "symbol"},
+		{"true", "bool"},
+		{"1 < 2", "bool"},
+		{`"ab"[0]`, "string"},
+		{"(1, \"a\")", "tuple(int, string)"},
+		{"(1, \"a\").1", "string"},
+		{"pair()", "tuple(int, bool)"},
+		{"[x * 2 for x in []int{1}]", "[]int"},
+		{"[s for s in \"abc\"]", "[]string"},
+		{"{k => v for k, v in map[string]float{}}", "map[string]float"},
+		{"(x int) => x > 0", "func(x int) bool"},
+		{"Shape.Circle", "func(float) Shape"},
+		{"Shape.Empty", "Shape"},
+		{"len(\"abc\")", "int"},
+		{"append([]int{}, 1)", "[]int"},
+		{"Point{1, 2} with {X => 3}", "Point"},
+		{"match 1 { 0 => \"zero\", _ => \"many\" }", "string"},
+		{"Point{1, 2}.Add", "func(q Point) Point"},
+		{"Point.Add", "func(p Point, q Point) Point"},
+		{"Point.Add(Point{}, Point{})", "Point"},
+		{"Shape.Area", "func(s Shape) float"},
+		{"Shape.Empty.Area()", "float"},
+	}
+
+	prelude := "package main\n\ntype Shape enum {\n\tEmpty\n\tCircle(float)\n}\ntype Point record{ X, Y int }\n\nfunc (p Point) Add(q Point) Point { return Point{p.X + q.X, p.Y + q.Y} }\nfunc (s Shape) Area() float { return 0 }\n\nfunc pair() (int, bool) { return 1, true }\n\n"
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			pkg, err := checkSource(t, prelude+"var x = "+tc.expr, nil)
+			require.NoError(t, err)
+			obj := pkg.Scope().Lookup("x")
+			require.NotNil(t, obj)
+			assert.Equal(t, tc.typ, obj.Type().String())
+		})
+	}
+}
+
+func TestCheckInfo(t *testing.T) {
+	src := "package main\n\nvar g = 1\n\nfunc f(p int) int {\n\tx := p + g\n\tif x > 0 {\n\t\tx := 2\n\t\treturn x\n\t}\n\treturn x\n}"
+	info := newInfo()
+	pkg, err := checkSource(t, src, info)
+	require.NoError(t, err)
+	assert.Equal(t, "main", pkg.Name())
+	assert.Equal(t, []string{"f", "g"}, pkg.Scope().Names())
+
+	defs := make(map[string]int)
+	for ident, obj := range info.Defs {
+		assert.Equal(t, ident.Name, obj.Name())
+		defs[ident.Name]++
+	}
+	assert.Equal(t, map[string]int{"g": 1, "f": 1, "p": 1, "x": 2}, defs)
+
+	// every use of x and p resolves to a local variable of f
+	for ident, obj := range info.Uses {
+		switch ident.Name {
+		case "x", "p":
+			_, ok := obj.(*Var)
+			assert.True(t, ok)
+			assert.NotEqual(t, pkg.Scope(), obj.Parent())
+		case "g":
+			assert.Equal(t, pkg.Scope(), obj.Parent())
+		}
+	}
+
+	// the file, function, if statement and block each have a scope
+	kinds := make(map[string]int)
+	for node := range info.Scopes {
+		kinds[fmt.Sprintf("%T", node)]++
+	}
+	assert.Equal(t, map[string]int{"*ast.File": 1, "*ast.FuncType": 1, "*ast.IfStmt": 1, "*ast.BlockStmt": 1}, kinds)
+
+	for e, tv := range info.Types {
+		if lit, ok := e.(*ast.BasicLit); ok {
+			assert.True(t, tv.IsValue())
+			assert.Equal(t, Typ[Int], tv.Type)
+			assert.Equal(t, lit.Value, tv.Value.String())
+		}
+	}
+}
+
+func TestCheckSelections(t *testing.T) {
+	src := "package main\n\ntype Comp struct{ Count int }\n\nfunc (c Comp) Handle(n int) {}\n\ntype Button struct {\n\tComp\n\tOnClick func(int)\n}\n\nfunc main() {\n\tb := Button{OnClick => (n) => {}}\n\tb.OnClick = b.Comp.Handle\n\tb.OnClick = b.Handle\n\tComp.Handle(b.Comp, b.Count)\n}"
+	info := newInfo()
+	_, err := checkSource(t, src, info)
+	require.NoError(t, err)
+
+	kinds := [...]string{FieldVal: "field", MethodVal: "method value", MethodExpr: "method expression"}
+	got := make(map[string]string)
+	for e, sel := range info.Selections {
+		got[fmt.Sprintf("%s %s", e.Sel.Pos(), ast.ExprString(e))] = fmt.Sprintf("%s of %s %v: %s", kinds[sel.Kind()], sel.Recv(), sel.Index(), sel.Type())
+	}
+	assert.Equal(t, map[string]string{
+		"14:4 b.OnClick":      "field of Button [1]: func(int)",
+		"14:16 b.Comp":        "field of Button [0]: Comp",
+		"14:21 b.Comp.Handle": "method value of Comp [0]: func(n int)",
+		"15:4 b.OnClick":      "field of Button [1]: func(int)",
+		"15:16 b.Handle":      "method value of Button [0 0]: func(n int)",
+		"16:7 Comp.Handle":    "method expression of Comp [0]: func(c Comp, n int)",
+		"16:16 b.Comp":        "field of Button [0]: Comp",
+		"16:24 b.Count":       "field of Button [0 0]: int",
+	}, got)
+}
+
+func TestCheckInstances(t *testing.T) {
+	src := "package main\n\ntype List[T any] struct{ Items []T }\n\nfunc Map[T, U any](xs []T, f func(T) U) []U { return []U{} }\n\nvar l = List[string]{}\nvar ys = Map(l.Items, (s) => s == \"\")\nvar zs = Map[int, int]([]int{}, (n) => n)"
+	info := newInfo()
+	_, err := checkSource(t, src, info)
+	require.NoError(t, err)
+
+	got := make(map[string]string)
+	for ident, inst := range info.Instances {
+		got[fmt.Sprintf("%s %s", ident.Pos(), ident.Name)] = fmt.Sprintf("[%s] %s", typeList(inst.TypeArgs), inst.Type)
+	}
+	assert.Equal(t, map[string]string{
+		"7:9 List": "[string] List[string]",
+		"8:10 Map": "[string, bool] func(xs []string, f func(string) bool) []bool",
+		"9:10 Map": "[int, int] func(xs []int, f func(int) int) []int",
+	}, got)
+}
@@ -0,0 +1,273 @@
+package types
+
+import (
+	"fmt"
+	"go/constant"
+	"math"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Literals and the constants declared without a type are untyped: their
+// values have arbitrary precision, and they take the type the context
+// expects when they are used, or their default type where it expects none.
+// A constant must be representable by the type it takes.
+
+// maxUntypedBits bounds the size of untyped integer constants, so that
+// folding cannot grow them without limit.
+const maxUntypedBits = 512
+
+// untypedAssignable reports whether values of the untyped kind v may be
+// used where a value of type t is expected, ignoring their values.
+func untypedAssignable(v *Basic, t Type) bool {
+	if tp, ok := t.(*TypeParam); ok {
+		return allTerms(tp, func(t Type) bool { return untypedAssignable(v, t) })
+	}
+	switch u := t.Underlying().(type) {
+	case *Basic:
+		switch v.kind {
+		case UntypedBool:
+			return isBoolean(u)
+		case UntypedInt, UntypedFloat:
+			return isNumeric(u)
+		case UntypedString:
+			return isString(u)
+		case UntypedSymbol:
+			return isBasic(u, Symbol)
+		}
+	case *Interface:
+		return AssignableTo(defaultType(v), t)
+	case *Optional:
+		return untypedAssignable(v, u.elem)
+	case *SymbolSet:
+		return v.kind == UntypedSymbol
+	}
+	return false
+}
+
+// representable returns the value val takes as a constant of the basic
+// type t. The reason is "truncated" or "overflows" if t cannot represent
+// it. Floats are rounded to 64 bits.
+func representable(val constant.Value, t *Basic) (constant.Value, string) {
+	switch t.kind {
+	case Int, Int64, UntypedInt:
+		v := constant.ToInt(val)
+		if v.Kind() != constant.Int {
+			return nil, "truncated"
+		}
+		if t.kind != UntypedInt {
+			n, ok := constant.Int64Val(v)
+			if !ok || t.kind == Int && n != int64(int32(n)) {
+				return nil, "overflows"
+			}
+		}
+		return v, ""
+	case Float:
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		if math.IsInf(f, 0) {
+			return nil, "overflows"
+		}
+		return constant.MakeFloat64(f), ""
+	case UntypedFloat:
+		return constant.ToFloat(val), ""
+	}
+	return val, ""
+}
+
+// implicitType returns the type the untyped operand x takes where a value
+// of type T is expected, or nil if it cannot take one. The reason is
+// "truncated" or "overflows" if x is a constant that T cannot represent,
+// and "not in the set" for a symbol that the symbol set T does not hold.
+func implicitType(x *operand, T Type) (Type, string) {
+	if !untypedAssignable(x.typ.(*Basic), T) {
+		return nil, ""
+	}
+	if tp, ok := T.(*TypeParam); ok {
+		// the constant must be representable by every type of the type set,
+		// as one value
+		if x.mode == constant_ {
+			if a, b := mixedBasics(tp, true); a != nil {
+				return nil, fmt.Sprintf("%s and %s constants differ in JavaScript", a, b)
+			}
+			for _, term := range typeParamTerms(tp) {
+				b, ok := term.typ.Underlying().(*Basic)
+				if !ok {
+					return nil, ""
+				}
+				if _, reason := representable(x.val, b); reason != "" {
+					return nil, reason
+				}
+			}
+		}
+		return T, ""
+	}
+	switch u := T.Underlying().(type) {
+	case *Basic:
+		if x.mode == constant_ {
+			if _, reason := representable(x.val, u); reason != "" {
+				return nil, reason
+			}
+		}
+		return T, ""
+	case *Interface:
+		return implicitType(x, defaultType(x.typ))
+	case *Optional:
+		return implicitType(x, u.elem)
+	case *SymbolSet:
+		// symbol literals are always constants
+		if !u.Contains(constant.StringVal(x.val)) {
+			return nil, "not in the set"
+		}
+		return T, ""
+	}
+	return nil, ""
+}
+
+// implicitConversion converts the untyped operand x to the type it takes
+// where a value of type T is expected, or to its default type if T is nil,
+// and records the types of the expressions it was computed from. If x
+// cannot take a type, it is left unchanged and the reason is returned as
+// by implicitType.
+func (c *Checker) implicitConversion(x *operand, T Type) (reason string, ok bool) {
+	if x.mode == invalid || !isUntyped(x.typ) || x.isNil() {
+		return "", true
+	}
+	if T == nil {
+		T = defaultType(x.typ)
+	}
+	typ, reason := implicitType(x, T)
+	if typ == nil {
+		return reason, false
+	}
+	var val constant.Value // of a constant converted to a type parameter
+	if x.mode == constant_ {
+		if b, isBasic := typ.Underlying().(*Basic); isBasic {
+			x.val, _ = representable(x.val, b)
+		} else if _, isSet := typ.Underlying().(*SymbolSet); !isSet {
+			// a constant converted to a type parameter is a value, which
+			// is still recorded
+			val = x.val
+			x.mode = value
+			x.val = nil
+		}
+	}
+	c.setUntypedType(x, typ)
+	if val != nil && x.expr != nil && c.info.Types != nil {
+		tv := c.info.Types[x.expr]
+		tv.Value = val
+		c.info.Types[x.expr] = tv
+	}
+	return "", true
+}
+
+// setUntypedType sets the type of the untyped operand x to typ, also for
+// the untyped operands of the expression computing it.
+func (c *Checker) setUntypedType(x *operand, typ Type) {
+	x.typ = typ
+	if x.expr != nil {
+		c.updateExprType(x.expr, typ)
+		c.record(x)
+	}
+}
+
+// updateExprType records typ as the type of the untyped expression e and of
+// the untyped operands it was computed from. Operands of comparisons keep
+// their types, as do the counts of shifts.
+func (c *Checker) updateExprType(e ast.Expr, typ Type) {
+	if c.info.Types == nil {
+		return
+	}
+	tv, ok := c.info.Types[e]
+	if !ok || tv.Type == nil || !isUntyped(tv.Type) {
+		return
+	}
+	switch x := e.(type) {
+	case *ast.ParenExpr:
+		c.updateExprType(x.X, typ)
+	case *ast.UnaryExpr:
+		c.updateExprType(x.X, typ)
+	case *ast.BinaryExpr:
+		switch {
+		case isComparison(x.Op):
+		case x.Op == lexer.BIT_LEFT || x.Op == lexer.BIT_RIGHT:
+			c.updateExprType(x.X, typ)
+		default:
+			c.updateExprType(x.X, typ)
+			c.updateExprType(x.Y, typ)
+		}
+	}
+	tv.Type = typ
+	c.info.Types[e] = tv
+}
+
+// matchTypes converts an untyped operand of a binary operation to the type
+// of the other operand. Untyped numeric operands both take the float kind
+// if either has it. It reports whether the conversion succeeded; operands
+// whose types do not match at all are left for the caller to report.
+func (c *Checker) matchTypes(x, y *operand) bool {
+	switch {
+	case x.isNil() || y.isNil():
+		return true
+	case isUntyped(x.typ) && isUntyped(y.typ):
+		if isNumeric(x.typ) && isNumeric(y.typ) {
+			if x.typ.(*Basic).kind < y.typ.(*Basic).kind {
+				c.implicitConversion(x, y.typ)
+			} else {
+				c.implicitConversion(y, x.typ)
+			}
+		}
+		return true
+	case isUntyped(x.typ):
+		return c.convertOperand(x, y.typ)
+	case isUntyped(y.typ):
+		return c.convertOperand(y, x.typ)
+	}
+	return true
+}
+
+// convertOperand converts the untyped operand x of a binary operation to
+// type T, reporting an error and invalidating x if it is a constant that T
+// cannot represent.
+func (c *Checker) convertOperand(x *operand, T Type) bool {
+	reason, ok := c.implicitConversion(x, T)
+	if ok || reason == "" {
+		return true
+	}
+	switch reason {
+	case "truncated":
+		c.errorf(x.expr.Pos(), "%s truncated to %s", x, T)
+	case "overflows":
+		c.errorf(x.expr.Pos(), "%s overflows %s", x, T)
+	default:
+		c.errorf(x.expr.Pos(), "cannot convert %s to type %s (%s)", x, T, reason)
+	}
+	x.mode = invalid
+	return false
+}
+
+// overflow reports an error and invalidates x if the result x of a
+// constant operation at pos is not representable by its type.
+func (c *Checker) overflow(x *operand, pos lexer.Position) {
+	if x.mode != constant_ {
+		return
+	}
+	if isUntyped(x.typ) {
+		if x.val.Kind() == constant.Int && constant.BitLen(x.val) > maxUntypedBits {
+			c.errorf(pos, "constant overflow")
+			x.mode = invalid
+		}
+		return
+	}
+	b, ok := x.typ.Underlying().(*Basic)
+	if !ok {
+		return
+	}
+	val, reason := representable(x.val, b)
+	if reason != "" {
+		c.errorf(pos, "%s overflows %s", x, x.typ)
+		x.mode = invalid
+		return
+	}
+	x.val = val
+}
@@ -0,0 +1,415 @@
+package types
+
+import (
+	"fmt"
+	"go/constant"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+// maxWitnesses bounds the number of missing cases listed for a match that is
+// not exhaustive.
+const maxWitnesses = 8
+
+// ctorKind classifies the head of a pattern.
+type ctorKind int
+
+const (
+	ctorVariant ctorKind = iota // variant of an enum
+	ctorBool                    // true or false
+	ctorSymbol                  // symbol of a symbol set
+	ctorLit                     // any other constant or literal
+	ctorTuple
+	ctorRecord
+)
+
+// ctor is the constructor a pattern matches at its root. Patterns with equal
+// keys match the same values at the root.
+type ctor struct {
+	kind    ctorKind
+	key     string
+	name    string
+	variant *Variant
+}
+
+// variantCtor returns the constructor of the variant v.
+func variantCtor(v *Variant) *ctor {
+	return &ctor{kind: ctorVariant, key: "variant:" + v.name, name: v.name, variant: v}
+}
+
+// constCtor returns the constructor of a pattern matching the constant val
+// of type T, or written text if it is not constant.
+func constCtor(val constant.Value, T Type, text string) *ctor {
+	switch {
+	case val == nil:
+		return &ctor{kind: ctorLit, key: "expr:" + text, name: text}
+	case val.Kind() == constant.Bool:
+		return &ctor{kind: ctorBool, key: "bool:" + val.String(), name: val.String()}
+	case isSymbol(T) && val.Kind() == constant.String:
+		name := constant.StringVal(val)
+		return &ctor{kind: ctorSymbol, key: "symbol:" + name, name: ":" + name}
+	case val.Kind() == constant.Int || val.Kind() == constant.Float:
+		// 1 and 1.0 match the same values
+		return &ctor{kind: ctorLit, key: "lit:" + constant.ToFloat(val).ExactString(), name: text}
+	}
+	return &ctor{kind: ctorLit, key: "lit:" + val.ExactString(), name: text}
+}
+
+// column collects the constructors of the first column of a pattern matrix,
+// whose values have type T.
+type column struct {
+	T      Type
+	ctors  []*ctor
+	fields []string // union of the fields of record patterns
+}
+
+// matchSpace reports match arms that can never be selected and match
+// expressions that do not cover every value, following Maranget's
+// "Warnings for pattern matching". The constructors of enum variant,
+// constant and literal patterns are those the checker found for them.
+type matchSpace struct {
+	c     *Checker
+	heads map[ast.Pattern]*ctor
+}
+
+var wildcard ast.Pattern = &ast.WildcardPat{}
+
+// exhaustive checks the arms of the match expression e, whose subject has
+// type T. heads holds the constructors of the patterns of its arms.
+func (c *Checker) exhaustive(e *ast.MatchExpr, T Type, heads map[ast.Pattern]*ctor) {
+	m := &matchSpace{c: c, heads: heads}
+	ts := []Type{T}
+	var rows [][]ast.Pattern
+	for _, arm := range e.Arms {
+		if m.useful(rows, []ast.Pattern{arm.Pattern}, ts) == nil {
+			c.errorf(arm.Pos(), "unreachable match arm")
+		} else if or, ok := arm.Pattern.(*ast.OrPat); ok {
+			prev := rows
+			for _, alt := range or.Alts {
+				if m.useful(prev, []ast.Pattern{alt}, ts) == nil {
+					c.errorf(alt.Pos(), "unreachable alternative in or-pattern")
+				}
+				prev = append(prev[:len(prev):len(prev)], []ast.Pattern{alt})
+			}
+		}
+
+		// a guarded arm may decline a value its pattern matches
+		if arm.Guard == nil {
+			rows = append(rows, []ast.Pattern{arm.Pattern})
+		}
+	}
+
+	missing := m.useful(rows, []ast.Pattern{wildcard}, ts)
+	if missing == nil {
+		return
+	}
+	if len(missing) == 1 && missing[0][0] == "_" {
+		c.errorf(e.Match, "match is not exhaustive: add a _ arm")
+		return
+	}
+	list := make([]string, 0, len(missing))
+	seen := make(map[string]bool)
+	for _, w := range missing {
+		if !seen[w[0]] {
+			seen[w[0]] = true
+			list = append(list, w[0])
+		}
+	}
+	if len(list) > maxWitnesses {
+		c.errorf(e.Match, "match is not exhaustive: missing %s and %d more", strings.Join(list[:maxWitnesses], ", "), len(list)-maxWitnesses)
+		return
+	}
+	c.errorf(e.Match, "match is not exhaustive: missing %s", strings.Join(list, ", "))
+}
+
+// head returns the constructor of x, or nil if x matches any value.
+func (m *matchSpace) head(x ast.Pattern) *ctor {
+	switch x := x.(type) {
+	case *ast.LitPat, *ast.VariantPat:
+		return m.heads[x]
+	case *ast.TuplePat:
+		return &ctor{kind: ctorTuple, key: "tuple"}
+	case *ast.RecordPat:
+		return &ctor{kind: ctorRecord, key: "record"}
+	}
+	return nil
+}
+
+// args returns the sub-patterns of x, whose constructor is h or which matches
+// any value, in the order of col.
+func (m *matchSpace) args(x ast.Pattern, h *ctor, col *column) []ast.Pattern {
+	args := make([]ast.Pattern, len(col.argTypes(h)))
+	for i := range args {
+		args[i] = wildcard
+	}
+	switch x := x.(type) {
+	case *ast.VariantPat:
+		copy(args, x.Args)
+	case *ast.TuplePat:
+		copy(args, x.Elts)
+	case *ast.RecordPat:
+		for _, f := range x.Fields {
+			if f.Pattern == nil {
+				continue
+			}
+			for i, name := range col.fields {
+				if name == f.Name.Name {
+					args[i] = f.Pattern
+				}
+			}
+		}
+	}
+	return args
+}
+
+// argTypes returns the types of the sub-values of the values of col built
+// by h.
+func (col *column) argTypes(h *ctor) []Type {
+	var ts []Type
+	switch h.kind {
+	case ctorVariant:
+		for _, v := range h.variant.payload {
+			ts = append(ts, v.typ)
+		}
+	case ctorTuple:
+		if t, ok := col.T.Underlying().(*Tuple); ok {
+			ts = t.elems
+		}
+	case ctorRecord:
+		for _, name := range col.fields {
+			obj, _ := LookupFieldOrMethod(col.T, name)
+			ts = append(ts, obj.Type())
+		}
+	}
+	return ts
+}
+
+// expand replaces the rows whose first pattern is an or-pattern with one row
+// per alternative.
+func expand(rows [][]ast.Pattern) [][]ast.Pattern {
+	var out [][]ast.Pattern
+	for _, row := range rows {
+		or, ok := row[0].(*ast.OrPat)
+		if !ok {
+			out = append(out, row)
+			continue
+		}
+		for _, alt := range or.Alts {
+			out = append(out, expand([][]ast.Pattern{prepend(alt, row[1:])})...)
+		}
+	}
+	return out
+}
+
+func prepend(x ast.Pattern, rest []ast.Pattern) []ast.Pattern {
+	return append([]ast.Pattern{x}, rest...)
+}
+
+// column returns the constructors of the first column of rows and of q,
+// whose values have type T.
+func (m *matchSpace) column(rows [][]ast.Pattern, q ast.Pattern, T Type) *column {
+	col := &column{T: T}
+	seen := make(map[string]bool)
+	fields := make(map[string]bool)
+	add := func(x ast.Pattern) {
+		h := m.head(x)
+		if h == nil {
+			return
+		}
+		if !seen[h.key] {
+			seen[h.key] = true
+			col.ctors = append(col.ctors, h)
+		}
+		if rec, ok := x.(*ast.RecordPat); ok {
+			for _, f := range rec.Fields {
+				if !fields[f.Name.Name] {
+					fields[f.Name.Name] = true
+					col.fields = append(col.fields, f.Name.Name)
+				}
+			}
+		}
+	}
+	for _, row := range rows {
+		add(row[0])
+	}
+	add(q)
+	return col
+}
+
+// all returns every constructor of the values of col, or nil when they
+// have infinitely many or no pattern of col has a constructor.
+func (col *column) all() []*ctor {
+	if len(col.ctors) == 0 {
+		return nil
+	}
+	switch t := col.T.Underlying().(type) {
+	case *Enum:
+		all := make([]*ctor, len(t.variants))
+		for i, v := range t.variants {
+			all[i] = variantCtor(v)
+		}
+		return all
+	case *SymbolSet:
+		all := make([]*ctor, len(t.names))
+		for i, name := range t.names {
+			all[i] = &ctor{kind: ctorSymbol, key: "symbol:" + name, name: ":" + name}
+		}
+		return all
+	case *Tuple:
+		return []*ctor{{kind: ctorTuple, key: "tuple"}}
+	case *Struct, *Record:
+		return []*ctor{{kind: ctorRecord, key: "record"}}
+	}
+	if isBoolean(col.T) {
+		return []*ctor{
+			{kind: ctorBool, key: "bool:true", name: "true"},
+			{kind: ctorBool, key: "bool:false", name: "false"},
+		}
+	}
+	return nil
+}
+
+func (col *column) has(h *ctor) bool {
+	for _, x := range col.ctors {
+		if x.key == h.key {
+			return true
+		}
+	}
+	return false
+}
+
+// specialize keeps the rows that match constructor h and replaces their first
+// pattern by its sub-patterns.
+func (m *matchSpace) specialize(rows [][]ast.Pattern, h *ctor, col *column) [][]ast.Pattern {
+	var out [][]ast.Pattern
+	for _, row := range rows {
+		if rh := m.head(row[0]); rh != nil && rh.key != h.key {
+			continue
+		}
+		out = append(out, append(m.args(row[0], h, col), row[1:]...))
+	}
+	return out
+}
+
+// defaults keeps the rows whose first pattern matches any value, without it.
+func (m *matchSpace) defaults(rows [][]ast.Pattern) [][]ast.Pattern {
+	var out [][]ast.Pattern
+	for _, row := range rows {
+		if m.head(row[0]) == nil {
+			out = append(out, row[1:])
+		}
+	}
+	return out
+}
+
+// useful returns the values matched by q but by none of rows, written as
+// patterns, or nil if there are none. ts holds the types of the columns.
+func (m *matchSpace) useful(rows [][]ast.Pattern, q []ast.Pattern, ts []Type) [][]string {
+	if len(q) == 0 {
+		if len(rows) == 0 {
+			return [][]string{{}}
+		}
+		return nil
+	}
+	rows = expand(rows)
+
+	if or, ok := q[0].(*ast.OrPat); ok {
+		var ws [][]string
+		for _, alt := range or.Alts {
+			ws = append(ws, m.useful(rows, prepend(alt, q[1:]), ts)...)
+		}
+		return ws
+	}
+
+	col := m.column(rows, q[0], ts[0])
+	sub := func(h *ctor, q0 ast.Pattern) [][]string {
+		ws := m.useful(m.specialize(rows, h, col), append(m.args(q0, h, col), q[1:]...), append(col.argTypes(h), ts[1:]...))
+		return m.wrap(h, col, ws)
+	}
+	if h := m.head(q[0]); h != nil {
+		return sub(h, q[0])
+	}
+
+	all := col.all()
+	complete := all != nil
+	for _, h := range all {
+		complete = complete && col.has(h)
+	}
+	if complete {
+		var ws [][]string
+		for _, h := range all {
+			ws = append(ws, sub(h, wildcard)...)
+			if len(ws) > maxWitnesses {
+				break
+			}
+		}
+		return ws
+	}
+
+	rest := m.useful(m.defaults(rows), q[1:], ts[1:])
+	if rest == nil {
+		return nil
+	}
+	var heads []string
+	for _, h := range all {
+		if !col.has(h) {
+			heads = append(heads, m.format(h, col, nil))
+		}
+	}
+	if heads == nil {
+		heads = []string{"_"}
+	}
+	var ws [][]string
+	for _, head := range heads {
+		for _, w := range rest {
+			ws = append(ws, append([]string{head}, w...))
+		}
+	}
+	return ws
+}
+
+// wrap rebuilds the witnesses of a specialized matrix by applying h to their
+// leading sub-patterns.
+func (m *matchSpace) wrap(h *ctor, col *column, ws [][]string) [][]string {
+	n := len(col.argTypes(h))
+	var out [][]string
+	for _, w := range ws {
+		out = append(out, append([]string{m.format(h, col, w[:n])}, w[n:]...))
+	}
+	return out
+}
+
+// format writes constructor h applied to args, which default to wildcards.
+func (m *matchSpace) format(h *ctor, col *column, args []string) string {
+	n := len(col.argTypes(h))
+	if args == nil {
+		args = make([]string, n)
+		for i := range args {
+			args[i] = "_"
+		}
+	}
+	switch h.kind {
+	case ctorVariant:
+		name := col.T.String()
+		if t, ok := col.T.(*Named); ok {
+			name = t.Obj().Name()
+		}
+		name += "." + h.name
+		if n == 0 {
+			return name
+		}
+		return name + "(" + strings.Join(args, ", ") + ")"
+	case ctorTuple:
+		return "(" + strings.Join(args, ", ") + ")"
+	case ctorRecord:
+		var fields []string
+		for i, name := range col.fields {
+			if args[i] != "_" {
+				fields = append(fields, fmt.Sprintf("%s => %s", name, args[i]))
+			}
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return h.name
+}
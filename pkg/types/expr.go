@@ -0,0 +1,1370 @@
+package types
+
+import (
+	"go/constant"
+	"go/token"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// expr checks e, which must denote a single value.
+func (c *Checker) expr(x *operand, e ast.Expr) {
+	c.exprWithHint(x, e, nil)
+}
+
+// exprWithHint is like expr. hint is the type the context expects, if
+// known, from which the types of composite literals with elided types and
+// the parameters of arrow functions are inferred.
+func (c *Checker) exprWithHint(x *operand, e ast.Expr, hint Type) {
+	c.rawExpr(x, e, hint)
+	c.singleValue(x)
+	if isGeneric(x) {
+		c.errorf(x.expr.Pos(), "cannot use generic function %s without instantiation", ast.ExprString(x.expr))
+		x.mode = invalid
+	}
+}
+
+// singleValue reports an error and invalidates x if it does not denote a
+// value.
+func (c *Checker) singleValue(x *operand) {
+	switch x.mode {
+	case novalue:
+		c.errorf(x.expr.Pos(), "%s used as value", x)
+	case builtin:
+		c.errorf(x.expr.Pos(), "%s must be called", x)
+	case typexpr:
+		c.errorf(x.expr.Pos(), "%s is not an expression", x)
+	default:
+		return
+	}
+	x.mode = invalid
+}
+
+// rawExpr checks e, which may denote a value, a type, a built-in or a call
+// without results, and records its type.
+func (c *Checker) rawExpr(x *operand, e ast.Expr, hint Type) {
+	x.mode = invalid
+	x.typ = Typ[Invalid]
+	x.val = nil
+	x.mustUse = false
+	c.exprInternal(x, e, hint)
+	switch e.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.ParenExpr, *ast.CallExpr:
+	default:
+		// operands of other expressions may have set it
+		x.mustUse = false
+	}
+	x.expr = e
+	c.record(x)
+}
+
+// use checks expressions whose values are not needed, such as the
+// arguments of an invalid call, so that their identifiers are resolved.
+func (c *Checker) use(list ...ast.Expr) {
+	for _, e := range list {
+		if e == nil {
+			continue
+		}
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			c.use(kv.Key, kv.Value)
+			continue
+		}
+		var x operand
+		c.rawExpr(&x, e, nil)
+	}
+}
+
+func (c *Checker) exprInternal(x *operand, e ast.Expr, hint Type) {
+	switch e := e.(type) {
+	case *ast.BadExpr:
+		// error reported by the parser
+
+	case *ast.Ident:
+		c.ident(x, e)
+
+	case *ast.BasicLit:
+		c.basicLit(x, e)
+
+	case *ast.CompositeLit:
+		c.compositeLit(x, e, hint)
+
+	case *ast.JSONLit:
+		c.jsonLit(x, e, hint)
+
+	case *ast.JSXElement:
+		c.jsxElement(x, e)
+
+	case *ast.JSXText:
+		x.setConst(constant.MakeString(e.Value), Typ[UntypedString])
+
+	case *ast.TupleLit:
+		c.tupleLit(x, e, hint)
+
+	case *ast.FuncLit:
+		c.funcLit(x, e, hint)
+
+	case *ast.ParenExpr:
+		c.rawExpr(x, e.X, hint)
+
+	case *ast.SelectorExpr:
+		c.selector(x, e)
+
+	case *ast.IndexExpr:
+		c.indexExpr(x, e)
+
+	case *ast.CallExpr:
+		c.callExpr(x, e)
+
+	case *ast.UnaryExpr:
+		c.unary(x, e)
+
+	case *ast.BinaryExpr:
+		c.binary(x, e)
+
+	case *ast.ListComp:
+		c.listComp(x, e, hint)
+
+	case *ast.MapComp:
+		c.mapComp(x, e, hint)
+
+	case *ast.TupleIndexExpr:
+		c.tupleIndex(x, e)
+
+	case *ast.WithExpr:
+		c.withExpr(x, e)
+
+	case *ast.MatchExpr:
+		c.matchExpr(x, e, hint, false)
+
+	case *ast.KeyValueExpr:
+		c.errorf(e.Pos(), "unexpected key => value expression")
+		c.use(e.Key, e.Value)
+
+	case *ast.ArrayType, *ast.MapType, *ast.OptionalType, *ast.FuncType, *ast.StructType, *ast.RecordType,
+		*ast.InterfaceType, *ast.TupleType, *ast.EnumType:
+		x.mode = typexpr
+		x.typ = c.typExpr(e)
+	}
+}
+
+func (c *Checker) ident(x *operand, e *ast.Ident) {
+	if e.Name == "_" {
+		c.errorf(e.Pos(), "cannot use _ as value")
+		return
+	}
+	obj := c.lookup(e.Name)
+	if obj == nil {
+		c.errorf(e.Pos(), "undefined: %s", e.Name)
+		return
+	}
+	c.recordUse(e, obj)
+
+	typ := obj.Type()
+	if typ == nil || !isValid(typ) {
+		if _, ok := obj.(*Builtin); !ok {
+			return
+		}
+	}
+	x.typ = typ
+	switch obj := obj.(type) {
+	case *Var:
+		x.mode = variable
+		c.readVar(obj, e)
+		if t := c.narrowedType(obj); t != nil {
+			x.typ = t
+		}
+	case *Const:
+		x.mode = constant_
+		x.val = obj.val
+	case *TypeName:
+		x.mode = typexpr
+	case *Func:
+		x.mode = value
+		x.mustUse = obj.mustUse
+	case *Builtin:
+		x.mode = builtin
+		x.id = obj.id
+	}
+}
+
+func (c *Checker) basicLit(x *operand, e *ast.BasicLit) {
+	switch e.Kind {
+	case lexer.INT, lexer.FLOAT:
+		kind, typ := token.INT, Typ[UntypedInt]
+		if e.Kind == lexer.FLOAT {
+			kind, typ = token.FLOAT, Typ[UntypedFloat]
+		}
+		val := constant.MakeFromLiteral(e.Value, kind, 0)
+		if val.Kind() == constant.Unknown {
+			c.errorf(e.Pos(), "malformed constant: %s", e.Value)
+			return
+		}
+		x.setConst(val, typ)
+	case lexer.STRING:
+		val := constant.MakeFromLiteral(e.Value, token.STRING, 0)
+		if val.Kind() == constant.Unknown {
+			c.errorf(e.Pos(), "malformed string literal: %s", e.Value)
+			return
+		}
+		x.setConst(val, Typ[UntypedString])
+	case lexer.BOOL:
+		x.setConst(constant.MakeBool(e.Value == "true"), Typ[UntypedBool])
+	case lexer.TEMPLATE:
+		x.mode, x.typ = value, Typ[String]
+	case lexer.SYMBOL:
+		x.setConst(symbolVal(e.Value), Typ[UntypedSymbol])
+	case lexer.NIL:
+		x.mode, x.typ = value, Typ[UntypedNil]
+	case lexer.STRUCTURED:
+		x.mode, x.typ = value, anyType
+	}
+}
+
+func (c *Checker) selector(x *operand, e *ast.SelectorExpr) {
+	c.rawExpr(x, e.X, nil)
+	if x.mode == invalid {
+		return
+	}
+	sel := e.Sel.Name
+
+	if x.mode == typexpr {
+		if enum, ok := x.typ.Underlying().(*Enum); ok {
+			if v := enum.Lookup(sel); v != nil {
+				c.variant(x, x.typ, v)
+				return
+			}
+		}
+		c.methodExpr(x, e)
+		return
+	}
+
+	c.singleValue(x)
+	if x.mode == invalid || !c.nonNil(x, e) {
+		return
+	}
+	obj, index := LookupFieldOrMethod(x.typ, sel)
+	if obj == nil {
+		c.errorf(e.Sel.Pos(), "%s.%s undefined (type %s has no field or method %s)", ast.ExprString(e.X), sel, x.typ, sel)
+		x.mode = invalid
+		return
+	}
+	c.objDecl(obj)
+	c.recordUse(e.Sel, obj)
+	x.val = nil
+	x.mustUse = false
+
+	switch obj := obj.(type) {
+	case *Var:
+		c.recordSelection(e, &Selection{FieldVal, x.typ, obj, index})
+		if x.mode != variable {
+			x.mode = value
+		}
+		x.typ = obj.typ
+	case *Func:
+		sig := obj.Signature()
+		if sig == nil {
+			x.mode = invalid
+			return
+		}
+		c.recordSelection(e, &Selection{MethodVal, x.typ, obj, index})
+		x.mode = value
+		x.typ = &Signature{params: sig.params, results: sig.results, optional: sig.optional}
+		x.mustUse = obj.mustUse
+	}
+}
+
+// methodExpr checks the selector e of the type x, which is not a variant.
+// It denotes a method expression: the method as a function taking the
+// receiver as its first argument, as in Point.Sum(p).
+func (c *Checker) methodExpr(x *operand, e *ast.SelectorExpr) {
+	sel := e.Sel.Name
+	if n, ok := x.typ.(*Named); ok && n.tparams != nil && n.targs == nil {
+		c.errorf(e.X.Pos(), "cannot use generic type %s without instantiation", x.typ)
+		x.mode = invalid
+		return
+	}
+	obj, index := LookupFieldOrMethod(x.typ, sel)
+	fn, _ := obj.(*Func)
+	if fn == nil {
+		what := "method"
+		if _, ok := x.typ.Underlying().(*Enum); ok {
+			what = "variant"
+		}
+		c.errorf(e.Sel.Pos(), "%s.%s undefined (type %s has no %s %s)", ast.ExprString(e.X), sel, x.typ, what, sel)
+		x.mode = invalid
+		return
+	}
+	c.objDecl(fn)
+	c.recordUse(e.Sel, fn)
+	if fn.Signature() == nil {
+		x.mode = invalid
+		return
+	}
+	s := &Selection{MethodExpr, x.typ, fn, index}
+	c.recordSelection(e, s)
+	x.mode = value
+	x.typ = s.Type()
+	x.mustUse = fn.mustUse
+}
+
+// variant sets x to the enum variant v of type T. A variant with a payload
+// denotes the function constructing it, which is generic if T is, so that
+// the type arguments are inferred from the payload.
+func (c *Checker) variant(x *operand, T Type, v *Variant) {
+	x.mode = value
+	x.val = nil
+	n, generic := T.(*Named)
+	generic = generic && n.tparams != nil && n.targs == nil
+	if v.payload == nil {
+		if generic {
+			c.errorf(x.expr.Pos(), "cannot use generic type %s without instantiation", T)
+			x.mode = invalid
+			return
+		}
+		x.typ = T
+		return
+	}
+	if !generic {
+		x.typ = NewSignature(nil, v.payload, []*Var{NewVar(x.expr.Pos(), "", T)})
+		return
+	}
+	targs := make([]Type, len(n.tparams))
+	for i, tp := range n.tparams {
+		targs[i] = tp
+	}
+	sig := NewSignature(nil, v.payload, []*Var{NewVar(x.expr.Pos(), "", instance(n, targs))})
+	sig.tparams = n.tparams
+	x.typ = sig
+}
+
+// indexExpr checks an index expression, which may also instantiate a
+// generic type or function.
+func (c *Checker) indexExpr(x *operand, e *ast.IndexExpr) {
+	c.rawExpr(x, e.X, nil)
+	switch {
+	case x.mode == typexpr:
+		x.typ = c.instantiatedType(e, x.typ)
+		if !isValid(x.typ) {
+			x.mode = invalid
+		}
+		return
+	case isGeneric(x):
+		c.funcInst(x, e)
+		return
+	}
+	c.indexed(x, e)
+}
+
+// indexed checks the index of e, whose indexed operand x has been checked.
+func (c *Checker) indexed(x *operand, e *ast.IndexExpr) {
+	c.singleValue(x)
+	if x.mode == invalid || !c.nonNil(x, e) {
+		c.use(e.Indices...)
+		return
+	}
+	if len(e.Indices) != 1 {
+		c.errorf(e.Indices[1].Pos(), "invalid operation: more than one index")
+		c.use(e.Indices...)
+		x.mode = invalid
+		return
+	}
+	index := e.Indices[0]
+	x.val = nil
+
+	switch u := coreType(x.typ).(type) {
+	case *Basic:
+		if isString(u) {
+			c.index(index, -1)
+			x.mode = value
+			x.typ = Typ[String]
+			return
+		}
+	case *Array:
+		c.index(index, u.len)
+		if x.mode != variable {
+			x.mode = value
+		}
+		x.typ = u.elem
+		return
+	case *Slice:
+		c.index(index, -1)
+		x.mode = variable
+		x.typ = u.elem
+		return
+	case *Map:
+		var k operand
+		c.exprWithHint(&k, index, u.key)
+		c.assignment(&k, u.key, "map index")
+		x.mode = mapindex
+		x.typ = u.elem
+		return
+	}
+
+	c.errorf(x.expr.Pos(), "invalid operation: cannot index %s", x)
+	c.use(index)
+	x.mode = invalid
+}
+
+// index checks an index expression, which must be an integer within
+// [0, max) when it is constant. max is negative when the length is not
+// known.
+func (c *Checker) index(e ast.Expr, max int64) {
+	var x operand
+	c.expr(&x, e)
+	if isUntyped(x.typ) && isNumeric(x.typ) {
+		c.convertOperand(&x, Typ[Int])
+	}
+	if x.mode == invalid {
+		return
+	}
+	if !isInteger(x.typ) {
+		c.errorf(e.Pos(), "invalid argument: index %s must be integer", &x)
+		return
+	}
+	if x.mode != constant_ {
+		return
+	}
+	n, ok := constant.Int64Val(x.val)
+	switch {
+	case !ok || n < 0:
+		c.errorf(e.Pos(), "invalid argument: index %s must not be negative", &x)
+	case max >= 0 && n >= max:
+		c.errorf(e.Pos(), "invalid argument: index %d out of bounds [0:%d]", n, max)
+	}
+}
+
+var unaryOps = map[lexer.Token]token.Token{
+	lexer.ADD:     token.ADD,
+	lexer.SUB:     token.SUB,
+	lexer.NOT:     token.NOT,
+	lexer.BIT_NOT: token.XOR,
+}
+
+func (c *Checker) unary(x *operand, e *ast.UnaryExpr) {
+	c.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+
+	var ok bool
+	switch e.Op {
+	case lexer.ADD, lexer.SUB:
+		ok = isNumeric(x.typ)
+	case lexer.NOT:
+		ok = isBoolean(x.typ)
+	case lexer.BIT_NOT:
+		ok = isInteger(x.typ)
+	}
+	if !ok {
+		c.errorf(e.OpPos, "invalid operation: operator %s not defined on %s", lexer.Sequence(e.Op), x)
+		x.mode = invalid
+		return
+	}
+	if e.Op != lexer.NOT && !c.erasedArith(x, e.Op, e.OpPos) {
+		x.mode = invalid
+		return
+	}
+
+	if x.mode == constant_ {
+		x.val = constant.UnaryOp(unaryOps[e.Op], x.val, 0)
+		x.expr = e
+		c.overflow(x, e.OpPos)
+		return
+	}
+	x.mode = value
+}
+
+var binaryOps = map[lexer.Token]token.Token{
+	lexer.ADD:       token.ADD,
+	lexer.SUB:       token.SUB,
+	lexer.MULT:      token.MUL,
+	lexer.DIV:       token.QUO,
+	lexer.MOD:       token.REM,
+	lexer.BIT_AND:   token.AND,
+	lexer.BIT_OR:    token.OR,
+	lexer.BIT_NOT:   token.XOR,
+	lexer.BIT_CLEAR: token.AND_NOT,
+	lexer.BIT_LEFT:  token.SHL,
+	lexer.BIT_RIGHT: token.SHR,
+	lexer.AND:       token.LAND,
+	lexer.OR:        token.LOR,
+	lexer.EQ:        token.EQL,
+	lexer.NEQ:       token.NEQ,
+	lexer.LT:        token.LSS,
+	lexer.GT:        token.GTR,
+	lexer.LTEQ:      token.LEQ,
+	lexer.GTEQ:      token.GEQ,
+}
+
+func isComparison(op lexer.Token) bool {
+	switch op {
+	case lexer.EQ, lexer.NEQ, lexer.LT, lexer.GT, lexer.LTEQ, lexer.GTEQ:
+		return true
+	}
+	return false
+}
+
+// opAllowed reports whether the arithmetic or logical operator op is defined
+// on values of type t.
+func opAllowed(op lexer.Token, t Type) bool {
+	switch op {
+	case lexer.ADD:
+		return isNumeric(t) || isString(t)
+	case lexer.SUB, lexer.MULT, lexer.DIV:
+		return isNumeric(t)
+	case lexer.MOD, lexer.BIT_AND, lexer.BIT_OR, lexer.BIT_NOT, lexer.BIT_CLEAR:
+		return isInteger(t)
+	case lexer.AND, lexer.OR:
+		return isBoolean(t)
+	}
+	return false
+}
+
+// erasedArith reports whether the operator op may be applied to the
+// operand x in generic code, reporting an error at pos if the types of
+// the type set of its type parameter compute it differently.
+func (c *Checker) erasedArith(x *operand, op lexer.Token, pos lexer.Position) bool {
+	a, b := mixedBasics(x.typ, false)
+	if a == nil {
+		return true
+	}
+	c.errorf(pos, "invalid operation: operator %s not defined on %s (%s and %s arithmetic differ in JavaScript)", lexer.Sequence(op), x, a, b)
+	return false
+}
+
+func (c *Checker) binary(x *operand, e *ast.BinaryExpr) {
+	var y operand
+	c.expr(x, e.X)
+	if (e.Op == lexer.AND || e.Op == lexer.OR) && c.fn != nil {
+		// the right operand is only evaluated if the left one is true for
+		// && and false for ||
+		restore := c.narrow(c.nilChecks(e.X, e.Op == lexer.AND))
+		c.expr(&y, e.Y)
+		restore()
+	} else {
+		c.expr(&y, e.Y)
+	}
+	if x.mode == invalid {
+		return
+	}
+	if y.mode == invalid {
+		x.mode = invalid
+		return
+	}
+
+	if e.Op != lexer.BIT_LEFT && e.Op != lexer.BIT_RIGHT && !c.matchTypes(x, &y) {
+		x.mode = invalid
+		return
+	}
+	switch {
+	case isComparison(e.Op):
+		c.comparison(x, &y, e)
+		return
+	case e.Op == lexer.BIT_LEFT || e.Op == lexer.BIT_RIGHT:
+		c.shift(x, &y, e)
+		return
+	}
+
+	if !Identical(x.typ, y.typ) {
+		c.errorf(e.OpPos, "invalid operation: %s (mismatched types %s and %s)", ast.ExprString(e), x.typ, y.typ)
+		x.mode = invalid
+		return
+	}
+	if !opAllowed(e.Op, x.typ) {
+		c.errorf(e.OpPos, "invalid operation: operator %s not defined on %s", lexer.Sequence(e.Op), x)
+		x.mode = invalid
+		return
+	}
+	if !c.erasedArith(x, e.Op, e.OpPos) {
+		x.mode = invalid
+		return
+	}
+	if (e.Op == lexer.DIV || e.Op == lexer.MOD) && y.mode == constant_ && constant.Sign(y.val) == 0 {
+		c.errorf(y.expr.Pos(), "invalid operation: division by zero")
+		x.mode = invalid
+		return
+	}
+
+	if x.mode == constant_ && y.mode == constant_ {
+		op := binaryOps[e.Op]
+		if op == token.QUO && isInteger(x.typ) {
+			op = token.QUO_ASSIGN // integer division
+		}
+		x.val = constant.BinaryOp(x.val, op, y.val)
+		x.expr = e
+		c.overflow(x, e.OpPos)
+		return
+	}
+	x.mode = value
+	x.val = nil
+}
+
+func (c *Checker) comparison(x, y *operand, e *ast.BinaryExpr) {
+	if !AssignableTo(x.typ, y.typ) && !AssignableTo(y.typ, x.typ) {
+		c.errorf(e.OpPos, "invalid operation: %s (mismatched types %s and %s)", ast.ExprString(e), x.typ, y.typ)
+		x.mode = invalid
+		return
+	}
+
+	op := lexer.Sequence(e.Op)
+	switch {
+	case e.Op != lexer.EQ && e.Op != lexer.NEQ:
+		if !ordered(x.typ) {
+			c.errorf(e.OpPos, "invalid operation: %s (operator %s not defined on %s)", ast.ExprString(e), op, x.typ)
+			x.mode = invalid
+			return
+		}
+	case x.isNil() && y.isNil():
+		c.errorf(e.OpPos, "invalid operation: %s (operator %s not defined on nil)", ast.ExprString(e), op)
+		x.mode = invalid
+		return
+	case x.isNil() || y.isNil():
+		// comparison against nil, whose type has nil values
+	case !comparable(x.typ):
+		c.errorf(e.OpPos, "invalid operation: %s (%s cannot be compared)", ast.ExprString(e), x.typ)
+		x.mode = invalid
+		return
+	}
+
+	if x.mode == constant_ && y.mode == constant_ {
+		x.val = constant.MakeBool(constant.Compare(x.val, binaryOps[e.Op], y.val))
+	} else {
+		x.mode = value
+		x.val = nil
+	}
+	x.typ = Typ[UntypedBool]
+}
+
+func (c *Checker) shift(x, y *operand, e *ast.BinaryExpr) {
+	if x.mode == constant_ && isUntyped(x.typ) && isNumeric(x.typ) {
+		// an untyped float constant with an integer value is shifted as an
+		// integer
+		if v := constant.ToInt(x.val); v.Kind() == constant.Int {
+			x.val = v
+			c.setUntypedType(x, Typ[UntypedInt])
+		}
+	}
+	if !isInteger(x.typ) {
+		c.errorf(x.expr.Pos(), "invalid operation: shifted operand %s must be integer", x)
+		x.mode = invalid
+		return
+	}
+	if !c.erasedArith(x, e.Op, e.OpPos) {
+		x.mode = invalid
+		return
+	}
+	if isUntyped(y.typ) && !c.convertOperand(y, Typ[Int]) {
+		x.mode = invalid
+		return
+	}
+	if !isInteger(y.typ) {
+		c.errorf(y.expr.Pos(), "invalid operation: shift count %s must be integer", y)
+		x.mode = invalid
+		return
+	}
+	if y.mode == constant_ {
+		if constant.Sign(y.val) < 0 {
+			c.errorf(y.expr.Pos(), "invalid operation: negative shift count %s", y)
+			x.mode = invalid
+			return
+		}
+		if x.mode == constant_ {
+			if s, ok := constant.Uint64Val(y.val); ok && s < 1024 {
+				x.val = constant.Shift(x.val, binaryOps[e.Op], uint(s))
+				x.expr = e
+				c.overflow(x, e.OpPos)
+				return
+			}
+			c.errorf(y.expr.Pos(), "invalid shift count %s", y)
+			x.mode = invalid
+			return
+		}
+	}
+	// an untyped constant shifted by a variable count takes its default
+	// type
+	if isUntyped(x.typ) && !c.convertOperand(x, defaultType(x.typ)) {
+		return
+	}
+	x.mode = value
+	x.val = nil
+}
+
+func (c *Checker) compositeLit(x *operand, e *ast.CompositeLit, hint Type) {
+	var typ Type
+	switch {
+	case e.Type != nil:
+		typ = c.typExpr(e.Type)
+	case hint != nil:
+		typ = hint
+	default:
+		c.errorf(e.Pos(), "invalid composite literal type: missing type")
+		c.use(e.Elts...)
+		return
+	}
+
+	switch u := typ.Underlying().(type) {
+	case *Struct:
+		c.structLit(e, typ, u.fields)
+	case *Record:
+		c.structLit(e, typ, u.fields)
+	case *Array:
+		c.indexedElts(e, typ, u.elem, u.len)
+	case *Slice:
+		c.indexedElts(e, typ, u.elem, -1)
+	case *Map:
+		c.mapElts(e.Elts, u)
+	default:
+		if isValid(typ) {
+			c.errorf(e.Pos(), "invalid composite literal type %s", typ)
+		}
+		c.use(e.Elts...)
+		return
+	}
+	x.mode = value
+	x.typ = typ
+}
+
+// structLit checks the elements of a struct or record literal, which either
+// name the fields they set or list the values of all fields in order.
+// Fields left out take their zero values, so their types must have one.
+func (c *Checker) structLit(e *ast.CompositeLit, typ Type, fields []*Var) {
+	keyed := len(e.Elts) == 0
+	if !keyed {
+		_, keyed = e.Elts[0].(*ast.KeyValueExpr)
+	}
+	if keyed {
+		seen := make(map[string]bool)
+		defer func() {
+			for _, f := range fields {
+				if !seen[f.name] && !hasZero(f.typ) {
+					c.errorf(e.Rbrace, "missing field %s in struct literal of type %s (%s has no zero value)", f.name, typ, f.typ)
+				}
+			}
+		}()
+		for _, elt := range e.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				c.errorf(elt.Pos(), "mixture of field => value and value elements in struct literal")
+				c.use(elt)
+				continue
+			}
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				c.errorf(kv.Key.Pos(), "invalid field name %s in struct literal", ast.ExprString(kv.Key))
+				c.use(kv.Value)
+				continue
+			}
+			f := lookupField(fields, key.Name)
+			if f == nil {
+				c.errorf(key.Pos(), "unknown field %s in struct literal of type %s", key.Name, typ)
+				c.use(kv.Value)
+				continue
+			}
+			c.recordUse(key, f)
+			if seen[key.Name] {
+				c.errorf(key.Pos(), "duplicate field name %s in struct literal", key.Name)
+			}
+			seen[key.Name] = true
+
+			var v operand
+			c.exprWithHint(&v, kv.Value, f.typ)
+			c.assignment(&v, f.typ, "struct literal")
+		}
+		return
+	}
+
+	for i, elt := range e.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			c.errorf(kv.Pos(), "mixture of field => value and value elements in struct literal")
+			c.use(kv.Value)
+			continue
+		}
+		if i >= len(fields) {
+			c.errorf(elt.Pos(), "too many values in struct literal of type %s", typ)
+			c.use(e.Elts[i:]...)
+			return
+		}
+		var v operand
+		c.exprWithHint(&v, elt, fields[i].typ)
+		c.assignment(&v, fields[i].typ, "struct literal")
+	}
+	if len(e.Elts) < len(fields) {
+		c.errorf(e.Rbrace, "too few values in struct literal of type %s", typ)
+	}
+}
+
+func lookupField(fields []*Var, name string) *Var {
+	for _, f := range fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// indexedElts checks the elements of an array or slice literal of type typ.
+// length is negative for slices. Elements left out take their zero value,
+// so their type must have one.
+func (c *Checker) indexedElts(e *ast.CompositeLit, typ, elem Type, length int64) {
+	seen := make(map[int64]bool)
+	var index, max int64
+	defer func() {
+		if length < 0 {
+			length = max
+		}
+		if int64(len(seen)) < length && !hasZero(elem) {
+			c.errorf(e.Rbrace, "missing elements in literal of type %s (%s has no zero value)", typ, elem)
+		}
+	}()
+	for _, elt := range e.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			var k operand
+			c.expr(&k, kv.Key)
+			if k.mode == constant_ && isInteger(k.typ) {
+				if n, ok := constant.Int64Val(k.val); ok && n >= 0 {
+					index = n
+				} else {
+					c.errorf(kv.Key.Pos(), "index %s must be a non-negative integer constant", ast.ExprString(kv.Key))
+				}
+			} else if k.mode != invalid {
+				c.errorf(kv.Key.Pos(), "index %s must be a non-negative integer constant", ast.ExprString(kv.Key))
+			}
+			elt = kv.Value
+		}
+		switch {
+		case length >= 0 && index >= length:
+			c.errorf(elt.Pos(), "index %d out of bounds [0:%d]", index, length)
+		case seen[index]:
+			c.errorf(elt.Pos(), "duplicate index %d in array or slice literal", index)
+		}
+		seen[index] = true
+		index++
+		if index > max {
+			max = index
+		}
+
+		var v operand
+		c.exprWithHint(&v, elt, elem)
+		c.assignment(&v, elem, "array or slice literal")
+	}
+}
+
+func (c *Checker) mapElts(elts []ast.Expr, m *Map) {
+	seen := make(map[string]bool)
+	for _, elt := range elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			c.errorf(elt.Pos(), "missing key in map literal")
+			c.use(elt)
+			continue
+		}
+		var k, v operand
+		c.exprWithHint(&k, kv.Key, m.key)
+		if c.assignment(&k, m.key, "map literal") && k.mode == constant_ {
+			key := constKey(k.val)
+			if seen[key] {
+				c.errorf(kv.Key.Pos(), "duplicate key %s in map literal", ast.ExprString(kv.Key))
+			}
+			seen[key] = true
+		}
+		c.exprWithHint(&v, kv.Value, m.elem)
+		c.assignment(&v, m.elem, "map literal")
+	}
+}
+
+// constKey returns a key identifying a constant value, for detecting
+// duplicates.
+func constKey(val constant.Value) string {
+	return val.Kind().String() + ":" + val.ExactString()
+}
+
+func (c *Checker) tupleLit(x *operand, e *ast.TupleLit, hint Type) {
+	var hints []Type
+	if hint != nil {
+		if t, ok := hint.Underlying().(*Tuple); ok && t.Len() == len(e.Elts) {
+			hints = t.elems
+		}
+	}
+
+	elems := make([]Type, len(e.Elts))
+	valid := true
+	for i, elt := range e.Elts {
+		var h Type
+		if hints != nil {
+			h = hints[i]
+		}
+		var v operand
+		c.exprWithHint(&v, elt, h)
+		switch {
+		case v.mode == invalid:
+			valid = false
+		case v.isNil():
+			if h == nil {
+				c.errorf(elt.Pos(), "use of untyped nil in tuple literal")
+				valid = false
+				continue
+			}
+			c.assignment(&v, h, "tuple literal")
+			elems[i] = h
+		default:
+			if isUntyped(v.typ) && !c.assignment(&v, h, "tuple literal") {
+				valid = false
+				continue
+			}
+			elems[i] = v.typ
+		}
+	}
+	if !valid {
+		return
+	}
+	x.mode = value
+	x.typ = NewTuple(elems...)
+}
+
+// funcLit checks a function literal. The types of the untyped parameters
+// of an arrow function, and its results, are taken from the function type
+// the context expects; without one, the results of an arrow function are
+// the types of the expression it returns, or of the values of the first
+// return statement of its block.
+func (c *Checker) funcLit(x *operand, e *ast.FuncLit, hint Type) {
+	var want *Signature
+	if hint != nil {
+		if sig, ok := hint.Underlying().(*Signature); ok {
+			want = sig
+		}
+	}
+	c.funcLitSig(x, e, want, want != nil)
+}
+
+// funcLitSig checks a function literal against the signature want, which may
+// be nil. The results of want are only used if known is set; otherwise those
+// of an arrow function are inferred from its body.
+func (c *Checker) funcLitSig(x *operand, e *ast.FuncLit, want *Signature, known bool) {
+	if want != nil && len(want.params) != e.Type.Params.NumFields() {
+		want, known = nil, false
+	}
+
+	scope := NewScope(c.scope, "function literal")
+	c.recordScope(e.Type, scope)
+
+	var params []*Var
+	for _, field := range e.Type.Params.List {
+		if field.Type != nil {
+			params = append(params, c.params(&ast.FieldList{List: []*ast.Field{field}}, scope)...)
+			continue
+		}
+		for _, name := range field.Names {
+			var typ Type = Typ[Invalid]
+			if want != nil {
+				typ = want.params[len(params)].typ
+			} else {
+				c.errorf(name.Pos(), "cannot infer type of parameter %s", name.Name)
+			}
+			v := NewVar(name.Pos(), name.Name, typ)
+			c.declare(scope, name, v)
+			params = append(params, v)
+		}
+	}
+
+	var results []*Var
+	switch {
+	case e.Type.Results != nil:
+		results = c.params(e.Type.Results, scope)
+	case known && e.Arrow.IsValid():
+		for _, r := range want.results {
+			results = append(results, NewVar(r.pos, "", r.typ))
+		}
+	}
+	sig := NewSignature(nil, params, results)
+
+	switch {
+	case e.Body != nil && e.Arrow.IsValid() && e.Type.Results == nil && !known:
+		c.arrowBody(sig, scope, e.Body)
+	case e.Body != nil:
+		c.funcBody(scope, sig, e.Body)
+	case e.Result != nil:
+		c.arrowResult(sig, scope, e.Result, known)
+	}
+	x.mode = value
+	x.typ = sig
+}
+
+// arrowResult checks the expression returned by an arrow function. Unless
+// the results are known from the context, they are inferred from it.
+func (c *Checker) arrowResult(sig *Signature, scope *Scope, e ast.Expr, known bool) {
+	oldScope, oldFn := c.scope, c.fn
+	c.scope, c.fn = scope, newFuncContext(sig, scope, e, oldFn)
+	defer func() { c.scope, c.fn = oldScope, oldFn }()
+
+	var r operand
+	c.rawExpr(&r, e, sig.Result())
+	switch {
+	case !known:
+		if r.mode == novalue {
+			return
+		}
+		c.assignment(&r, nil, "return statement")
+		switch {
+		case r.mode == invalid:
+			sig.results = []*Var{NewVar(e.Pos(), "", Typ[Invalid])}
+		case r.isNil():
+			c.errorf(e.Pos(), "use of untyped nil in return statement")
+			sig.results = []*Var{NewVar(e.Pos(), "", Typ[Invalid])}
+		default:
+			sig.results = []*Var{NewVar(e.Pos(), "", r.typ)}
+		}
+	case len(sig.results) > 0:
+		c.assignment(&r, sig.Result(), "return statement")
+	}
+}
+
+// arrowBody checks the block of an arrow function whose results are not
+// known from the context. They are inferred from the first return statement
+// and the later ones are checked against them.
+func (c *Checker) arrowBody(sig *Signature, scope *Scope, body *ast.BlockStmt) {
+	oldScope, oldFn := c.scope, c.fn
+	c.scope, c.fn = scope, newFuncContext(sig, scope, body, oldFn)
+	c.fn.inferResults = true
+	defer func() { c.scope, c.fn = oldScope, oldFn }()
+
+	c.stmtList(body.List)
+	if len(sig.results) > 0 && !isTerminating(body) {
+		c.errorf(body.Rbrace, "missing return")
+	}
+}
+
+func (c *Checker) tupleIndex(x *operand, e *ast.TupleIndexExpr) {
+	c.expr(x, e.X)
+	if x.mode == invalid || !c.nonNil(x, e) {
+		return
+	}
+	t, ok := x.typ.Underlying().(*Tuple)
+	if !ok {
+		c.errorf(e.IndexPos, "%s.%d undefined (type %s is not a tuple)", ast.ExprString(e.X), e.Index, x.typ)
+		x.mode = invalid
+		return
+	}
+	if e.Index >= t.Len() {
+		c.errorf(e.IndexPos, "invalid argument: index %d out of bounds [0:%d]", e.Index, t.Len())
+		x.mode = invalid
+		return
+	}
+	x.mode = value
+	x.typ = t.elems[e.Index]
+	x.val = nil
+}
+
+func (c *Checker) withExpr(x *operand, e *ast.WithExpr) {
+	c.expr(x, e.X)
+	if x.mode == invalid || !c.nonNil(x, e) {
+		c.useValues(e.Elts)
+		return
+	}
+	r, ok := x.typ.Underlying().(*Record)
+	if !ok {
+		c.errorf(e.With, "invalid operation: %s is not a record", x)
+		c.useValues(e.Elts)
+		x.mode = invalid
+		return
+	}
+
+	for _, elt := range e.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue // reported by the parser
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			c.use(kv.Value)
+			continue
+		}
+		f := lookupField(r.fields, key.Name)
+		if f == nil {
+			c.errorf(key.Pos(), "unknown field %s in with expression of type %s", key.Name, x.typ)
+			c.use(kv.Value)
+			continue
+		}
+		c.recordUse(key, f)
+		var v operand
+		c.exprWithHint(&v, kv.Value, f.typ)
+		c.assignment(&v, f.typ, "with expression")
+	}
+	x.mode = value
+	x.val = nil
+}
+
+// useValues checks the values of the key => value elements of a with
+// expression whose keys are field names.
+func (c *Checker) useValues(elts []ast.Expr) {
+	for _, elt := range elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			c.use(kv.Value)
+		}
+	}
+}
+
+func (c *Checker) listComp(x *operand, e *ast.ListComp, hint Type) {
+	var elemHint Type
+	if hint != nil {
+		if s, ok := hint.Underlying().(*Slice); ok {
+			elemHint = s.elem
+		}
+	}
+
+	oldScope := c.scope
+	for _, clause := range e.Clauses {
+		c.compClause(clause)
+	}
+	var elt operand
+	c.exprWithHint(&elt, e.Elt, elemHint)
+	c.scope = oldScope
+
+	typ := c.compElem(&elt, elemHint)
+	if typ == nil {
+		return
+	}
+	x.mode = value
+	x.typ = NewSlice(typ)
+}
+
+func (c *Checker) mapComp(x *operand, e *ast.MapComp, hint Type) {
+	var keyHint, valueHint Type
+	if hint != nil {
+		if m, ok := hint.Underlying().(*Map); ok {
+			keyHint, valueHint = m.key, m.elem
+		}
+	}
+
+	oldScope := c.scope
+	for _, clause := range e.Clauses {
+		c.compClause(clause)
+	}
+	var k, v operand
+	c.exprWithHint(&k, e.Key, keyHint)
+	c.exprWithHint(&v, e.Value, valueHint)
+	c.scope = oldScope
+
+	key, elem := c.compElem(&k, keyHint), c.compElem(&v, valueHint)
+	if key == nil || elem == nil {
+		return
+	}
+	if isValid(key) && !comparable(key) {
+		c.errorf(e.Key.Pos(), "invalid map key type %s", key)
+		return
+	}
+	x.mode = value
+	x.typ = NewMap(key, elem)
+}
+
+// compElem returns the type of an element of a comprehension, or nil if it
+// is invalid.
+func (c *Checker) compElem(x *operand, hint Type) Type {
+	switch {
+	case x.mode == invalid:
+		return nil
+	case hint != nil:
+		if !c.assignment(x, hint, "comprehension") {
+			return nil
+		}
+		return hint
+	case x.isNil():
+		c.errorf(x.expr.Pos(), "use of untyped nil in comprehension")
+		return nil
+	case !c.assignment(x, nil, "comprehension"):
+		return nil
+	}
+	return x.typ
+}
+
+// compClause checks a comprehension clause and opens the scope declaring
+// its variables, in which the following clauses and the elements of the
+// comprehension are checked.
+func (c *Checker) compClause(clause *ast.CompClause) {
+	var x operand
+	c.expr(&x, clause.X)
+	c.assignment(&x, nil, "comprehension clause")
+	c.openScope(clause, "comprehension clause")
+
+	types := make([]Type, len(clause.Vars))
+	for i := range types {
+		types[i] = Typ[Invalid]
+	}
+	if x.mode != invalid {
+		key, val, ok := rangeTypes(x.typ)
+		switch {
+		case !ok:
+			c.errorf(x.expr.Pos(), "cannot range over %s", &x)
+		case len(types) == 1:
+			// a single variable ranges over elements, or the keys of a map
+			types[0] = val
+			if _, isMap := x.typ.Underlying().(*Map); isMap || val == nil {
+				types[0] = key
+			}
+		case val == nil:
+			c.errorf(clause.Vars[1].Pos(), "range over %s permits only one iteration variable", &x)
+		default:
+			types[0], types[1] = key, val
+		}
+	}
+	for i, name := range clause.Vars {
+		v := NewVar(name.Pos(), name.Name, types[i])
+		c.declare(c.scope, name, v)
+		c.local(v)
+	}
+
+	if clause.Cond != nil {
+		var cond operand
+		c.expr(&cond, clause.Cond)
+		if cond.mode != invalid && !isBoolean(cond.typ) {
+			c.errorf(clause.Cond.Pos(), "non-boolean condition in comprehension")
+		}
+	}
+}
+
+// rangeTypes returns the types of the key and value of a range over values
+// of type typ. val is nil for ranges over integers, which have only a key.
+func rangeTypes(typ Type) (key, val Type, ok bool) {
+	switch u := coreType(typ).(type) {
+	case *Basic:
+		switch {
+		case isString(u):
+			return Typ[Int], Typ[String], true
+		case isInteger(u):
+			return typ, nil, true
+		}
+	case *Array:
+		return Typ[Int], u.elem, true
+	case *Slice:
+		return Typ[Int], u.elem, true
+	case *Map:
+		return u.key, u.elem, true
+	}
+	return nil, nil, false
+}
+
+// assignment reports whether x may be assigned to a variable of type T,
+// reporting an error and invalidating x if not. context describes the
+// assignment for the error message.
+func (c *Checker) assignment(x *operand, T Type, context string) bool {
+	c.singleValue(x)
+	if x.mode == invalid {
+		return false
+	}
+	if reason, ok := c.implicitConversion(x, T); !ok && reason != "" {
+		target := T
+		if target == nil {
+			target = defaultType(x.typ)
+		}
+		c.errorf(x.expr.Pos(), "cannot use %s as %s value in %s (%s)", x, target, context, reason)
+		x.mode = invalid
+		return false
+	}
+	if T == nil || AssignableTo(x.typ, T) {
+		return true
+	}
+
+	c.errorf(x.expr.Pos(), "cannot use %s as %s value in %s%s", x, T, context, assignReason(x.typ, T))
+	x.mode = invalid
+	return false
+}
+
+// assignReason explains why a value of type V is not assignable to T when T
+// is an interface, in the form " (reason)", and returns "" otherwise.
+func assignReason(V, T Type) string {
+	iface, ok := T.Underlying().(*Interface)
+	if !ok {
+		return ""
+	}
+	if reason := notImplemented(V, iface); reason != "" {
+		return " (" + reason + ")"
+	}
+	return ""
+}
+
+// initVar initializes v with x, inferring the type of v if it has none.
+func (c *Checker) initVar(v *Var, x *operand, context string) {
+	if x.mode == invalid {
+		if v.typ == nil {
+			v.typ = Typ[Invalid]
+		}
+		return
+	}
+	if v.typ != nil {
+		c.assignment(x, v.typ, context)
+		return
+	}
+	c.assignment(x, nil, context)
+	switch {
+	case x.mode == invalid:
+		v.typ = Typ[Invalid]
+	case x.isNil():
+		c.errorf(x.expr.Pos(), "use of untyped nil in %s", context)
+		v.typ = Typ[Invalid]
+	default:
+		v.typ = x.typ
+	}
+}
+
+// destructure initializes the variables of lhs, which may be nil for blank
+// identifiers, with the elements of the tuple x.
+func (c *Checker) destructure(lhs []*Var, x *operand, context string) {
+	t, ok := x.typ.Underlying().(*Tuple)
+	if x.mode != invalid && (!ok || t.Len() != len(lhs)) {
+		c.assignMismatch(x, len(lhs))
+		x.mode = invalid
+	}
+	if x.mode == invalid {
+		for _, v := range lhs {
+			if v != nil && v.typ == nil {
+				v.typ = Typ[Invalid]
+			}
+		}
+		return
+	}
+
+	for i, v := range lhs {
+		switch {
+		case v == nil:
+		case v.typ == nil:
+			v.typ = t.elems[i]
+		case !AssignableTo(t.elems[i], v.typ):
+			c.errorf(x.expr.Pos(), "cannot use element %d of %s (type %s) as %s value in %s%s", i, ast.ExprString(x.expr), t.elems[i], v.typ, context, assignReason(t.elems[i], v.typ))
+		}
+	}
+}
+
+func (c *Checker) assignMismatch(x *operand, vars int) {
+	t, ok := x.typ.Underlying().(*Tuple)
+	switch {
+	case !ok:
+		c.errorf(x.expr.Pos(), "assignment mismatch: %d variables but 1 value", vars)
+	case isCall(x.expr):
+		c.errorf(x.expr.Pos(), "assignment mismatch: %d variables but %s returns %d values", vars, ast.ExprString(x.expr), t.Len())
+	default:
+		c.errorf(x.expr.Pos(), "assignment mismatch: %d variables but %s has %d elements", vars, ast.ExprString(x.expr), t.Len())
+	}
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		paren, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = paren.X
+	}
+}
+
+func isCall(e ast.Expr) bool {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.CallExpr:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Extern declarations bind Gusset names to JavaScript. The extern
+// attribute on a function or variable without a value binds it to an
+// export of the module named by its argument, or without argument to a
+// global such as document. The js attribute gives the JavaScript name when
+// it differs from the Gusset one; it may be a path such as "Date.now".
+// Extern functions have no body. Their calls may omit the trailing
+// parameters named by the optional attribute; those of functions with the
+// rest attribute spread their last parameter, a slice, over the remaining
+// arguments; the this attribute passes the first parameter as this, and
+// the new attribute constructs an instance, as the new operator does.
+//
+// The extern attribute on a struct type declares the type of JavaScript
+// objects with the fields of the struct as properties, and on an enum(int)
+// or enum(string) type without payloads the type of JavaScript numbers or
+// strings with the values of the variants. The methods of extern types are
+// JavaScript methods: they are declared without a body, and may have the
+// js, optional and rest attributes.
+
+// Extern is the JavaScript binding of an extern function, method or
+// variable.
+type Extern struct {
+	// Module is the module the binding is imported from, or "" for a
+	// global or a method.
+	Module string
+	// Name is the JavaScript name of the binding: the name it is exported
+	// by or the path of a global, or the name of a method.
+	Name string
+	New  bool // calls construct an instance
+	This bool // the first parameter is passed as this
+	Rest bool // the last parameter is spread over the arguments
+}
+
+// externAttrs holds the attributes of extern functions and methods other
+// than extern itself.
+var externAttrs = map[string]bool{
+	"js": true, "new": true, "optional": true, "rest": true, "this": true,
+}
+
+// attrArgs reports whether attr has at least min and at most max
+// arguments, a negative max meaning no limit.
+func (c *Checker) attrArgs(attr *ast.Attribute, min, max int) bool {
+	if n := len(attr.Args); n < min || max >= 0 && n > max {
+		switch {
+		case max == 0:
+			c.errorf(attr.Pos(), "attribute %s takes no arguments", attr.Name)
+		case min == max:
+			c.errorf(attr.Pos(), "attribute %s takes %d argument", attr.Name, min)
+		case min == 0:
+			c.errorf(attr.Pos(), "attribute %s takes at most %d argument", attr.Name, max)
+		default:
+			c.errorf(attr.Pos(), "attribute %s takes at least %d argument", attr.Name, min)
+		}
+		return false
+	}
+	return true
+}
+
+// externAttributes checks the attributes binding the function or method fn
+// to JavaScript, whose receiver has the named type recv. Methods of extern
+// types are extern themselves.
+func (c *Checker) externAttributes(fn *Func, decl *ast.FuncDecl, recv *Named) {
+	if recv != nil && recv.extern {
+		fn.extern = &Extern{Name: fn.name}
+	}
+	for _, attr := range decl.Attrs {
+		if attr.Name != "extern" || !c.attrArgs(attr, 0, 1) {
+			continue
+		}
+		if decl.Recv != nil {
+			c.errorf(attr.Pos(), "attribute extern does not apply to methods; the methods of extern types are extern")
+			continue
+		}
+		fn.extern = &Extern{Name: fn.name}
+		if len(attr.Args) > 0 {
+			fn.extern.Module = attr.Args[0]
+		}
+	}
+	for _, attr := range decl.Attrs {
+		if !externAttrs[attr.Name] {
+			continue
+		}
+		if fn.extern == nil {
+			c.errorf(attr.Pos(), "attribute %s applies only to extern functions", attr.Name)
+			continue
+		}
+		if decl.Recv != nil && (attr.Name == "new" || attr.Name == "this") {
+			c.errorf(attr.Pos(), "attribute %s does not apply to methods", attr.Name)
+			continue
+		}
+		switch attr.Name {
+		case "js":
+			if c.attrArgs(attr, 1, 1) {
+				fn.extern.Name = attr.Args[0]
+			}
+		case "new":
+			fn.extern.New = c.attrArgs(attr, 0, 0)
+		case "rest":
+			fn.extern.Rest = c.attrArgs(attr, 0, 0)
+		case "this":
+			fn.extern.This = c.attrArgs(attr, 0, 0)
+		case "optional":
+			c.attrArgs(attr, 1, -1)
+		}
+	}
+}
+
+// externSig checks the signature of the extern function fn declared by
+// decl, and sets the number of parameters its calls may omit.
+func (c *Checker) externSig(fn *Func, decl *ast.FuncDecl, sig *Signature) {
+	ext := fn.extern
+	if decl.Body != nil {
+		if decl.Recv != nil {
+			c.errorf(decl.Name.Pos(), "method %s of an extern type has a body", fn.name)
+		} else {
+			c.errorf(decl.Name.Pos(), "extern function %s has a body", fn.name)
+		}
+	}
+	params := sig.params
+	if ext.New && len(sig.results) != 1 {
+		c.errorf(decl.Name.Pos(), "new function %s must have exactly one result", fn.name)
+	}
+	if ext.This {
+		if len(params) == 0 {
+			c.errorf(decl.Name.Pos(), "this function %s must have a parameter for this", fn.name)
+			return
+		}
+		if ext.New {
+			c.errorf(decl.Name.Pos(), "function %s cannot be both new and this", fn.name)
+		}
+	}
+	fixed := len(params) // parameters before the rest parameter
+	if ext.Rest {
+		if len(params) == 0 {
+			c.errorf(decl.Name.Pos(), "rest function %s has no parameters", fn.name)
+			return
+		}
+		last := params[len(params)-1]
+		if _, ok := last.typ.Underlying().(*Slice); !ok {
+			c.errorf(last.pos, "rest parameter %s must be a slice, not %s", last.name, last.typ)
+			return
+		}
+		fixed--
+	}
+
+	optional := make(map[int]bool)
+	for _, attr := range decl.Attrs {
+		if attr.Name != "optional" {
+			continue
+		}
+		for _, name := range attr.Args {
+			i := 0
+			for i < len(params) && params[i].name != name {
+				i++
+			}
+			switch {
+			case i == len(params):
+				c.errorf(attr.Pos(), "function %s has no parameter %s", fn.name, name)
+			case i == fixed:
+				c.errorf(attr.Pos(), "rest parameter %s is always optional", name)
+			case ext.This && i == 0:
+				c.errorf(attr.Pos(), "this parameter %s cannot be optional", name)
+			default:
+				optional[i] = true
+			}
+		}
+	}
+	for i := fixed - len(optional); i < fixed; i++ {
+		if !optional[i] {
+			c.errorf(params[i].pos, "parameter %s must be optional, as it follows an optional parameter", params[i].name)
+			return
+		}
+	}
+	sig.optional = len(optional)
+	if ext.Rest {
+		sig.optional++
+	}
+}
+
+// externDecl checks the attributes of a var, const or type declaration. It
+// returns the binding of extern variables, whose Name is the one given by
+// the js attribute, if any, and reports whether types are extern.
+func (c *Checker) externDecl(d *ast.GenDecl) (ext *Extern, typ bool) {
+	for _, attr := range d.Attrs {
+		switch attr.Name {
+		case "extern":
+			if !c.attrArgs(attr, 0, 1) {
+				continue
+			}
+			switch d.Tok {
+			case lexer.TYPE:
+				if len(attr.Args) > 0 {
+					c.errorf(attr.Pos(), "extern type cannot be imported from a module")
+					continue
+				}
+				typ = true
+			case lexer.VAR:
+				name := ""
+				if ext != nil {
+					name = ext.Name
+				}
+				ext = &Extern{Name: name}
+				if len(attr.Args) > 0 {
+					ext.Module = attr.Args[0]
+				}
+			default:
+				c.errorf(attr.Pos(), "attribute extern does not apply to constants")
+			}
+		case "js":
+			if d.Tok != lexer.VAR {
+				c.errorf(attr.Pos(), "attribute js does not apply to %s declarations", d.Tok)
+				continue
+			}
+			if !c.attrArgs(attr, 1, 1) {
+				continue
+			}
+			if ext == nil {
+				ext = new(Extern)
+			}
+			ext.Name = attr.Args[0]
+		default:
+			c.errorf(attr.Pos(), "attribute %s does not apply to %s declarations", attr.Name, d.Tok)
+		}
+	}
+	if ext != nil && !hasAttr(d.Attrs, "extern") {
+		c.errorf(d.Attrs[0].Pos(), "attribute js applies only to extern variables")
+		return nil, typ
+	}
+	return ext, typ
+}
+
+func hasAttr(attrs []*ast.Attribute, name string) bool {
+	for _, attr := range attrs {
+		if attr.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// externVars binds the variables of spec to JavaScript as ext describes.
+func (c *Checker) externVars(spec *ast.ValueSpec, vars []*Var, ext *Extern) {
+	if len(spec.Values) > 0 {
+		c.errorf(spec.Values[0].Pos(), "extern variable %s has a value", spec.Names[0].Name)
+	}
+	if spec.Type == nil {
+		c.errorf(spec.Names[0].Pos(), "extern variable %s must have a type", spec.Names[0].Name)
+	}
+	if ext.Name != "" && len(vars) > 1 {
+		c.errorf(spec.Names[1].Pos(), "attribute js binds a single variable")
+	}
+	for _, v := range vars {
+		bound := *ext
+		if bound.Name == "" {
+			bound.Name = v.name
+		}
+		v.extern = &bound
+	}
+}
+
+// externType checks that the extern type named has a JavaScript
+// representation: a struct without embedded fields, or an enum(int) or
+// enum(string) without payloads.
+func (c *Checker) externType(named *Named) {
+	switch u := named.underlying.(type) {
+	case *Struct:
+		for _, f := range u.fields {
+			if f.embedded {
+				c.errorf(f.pos, "extern type %s cannot embed %s", named.obj.name, f.typ)
+				return
+			}
+		}
+		return
+	case *Enum:
+		if len(u.backing) == 1 && isBasic(u.backing[0], Int, String) {
+			for _, v := range u.variants {
+				if v.payload != nil {
+					c.errorf(named.obj.pos, "extern enum %s cannot have payloads", named.obj.name)
+					return
+				}
+			}
+			return
+		}
+	}
+	if isValid(named.underlying) {
+		c.errorf(named.obj.pos, "extern type %s must be a struct or an enum(int) or enum(string)", named.obj.name)
+	}
+}
@@ -0,0 +1,302 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// declareTypeParams declares the type parameters of list in scope and
+// returns them. All names are declared before the constraints are checked,
+// so that a constraint may refer to any of the type parameters.
+func (c *Checker) declareTypeParams(list *ast.FieldList, scope *Scope) []*TypeParam {
+	var tparams []*TypeParam
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			tp := NewTypeParam(NewTypeName(name.Pos(), name.Name, nil), len(tparams), nil)
+			c.declare(scope, name, tp.obj)
+			tparams = append(tparams, tp)
+		}
+	}
+
+	oldScope := c.scope
+	c.scope = scope
+	defer func() { c.scope = oldScope }()
+
+	i := 0
+	for _, field := range list.List {
+		bound := c.constraint(field.Type)
+		for range field.Names {
+			tparams[i].bound = bound
+			i++
+		}
+	}
+	return tparams
+}
+
+// constraint returns the constraint of a type parameter. A union such as
+// `~int | float`, or a type that is not an interface, stands for the
+// implicit interface whose type set it describes.
+func (c *Checker) constraint(e ast.Expr) Type {
+	if isUnion(e) {
+		return implicitInterface(c.union(e))
+	}
+	typ := c.definedType(e, nil)
+	switch {
+	case !isValid(typ):
+		return Typ[Invalid]
+	case isInterface(typ):
+		return typ
+	}
+	if _, ok := typ.(*TypeParam); ok {
+		c.errorf(e.Pos(), "cannot use a type parameter as constraint")
+		return Typ[Invalid]
+	}
+	return implicitInterface(NewUnion([]*Term{NewTerm(false, typ)}))
+}
+
+func implicitInterface(u *Union) *Interface {
+	iface := NewInterface(nil, []Type{u})
+	iface.implicit = true
+	return iface
+}
+
+// isUnion reports whether e is a union of terms or a single term with `~`,
+// which may only occur in constraints.
+func isUnion(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		return e.Op == lexer.BIT_OR
+	case *ast.UnaryExpr:
+		return e.Op == lexer.TILDE
+	}
+	return false
+}
+
+// union returns the union of the terms of e, which are separated by |.
+func (c *Checker) union(e ast.Expr) *Union {
+	var terms []*Term
+	var collect func(e ast.Expr)
+	collect = func(e ast.Expr) {
+		if b, ok := e.(*ast.BinaryExpr); ok && b.Op == lexer.BIT_OR {
+			collect(b.X)
+			collect(b.Y)
+			return
+		}
+		if t := c.term(e); t != nil {
+			terms = append(terms, t)
+		}
+	}
+	collect(e)
+	return NewUnion(terms)
+}
+
+// term returns a term of a union, or nil after reporting an error.
+func (c *Checker) term(e ast.Expr) *Term {
+	tilde := false
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == lexer.TILDE {
+		tilde, e = true, u.X
+	}
+	typ := c.definedType(e, nil)
+	if !isValid(typ) {
+		return nil
+	}
+	if _, ok := typ.(*TypeParam); ok {
+		c.errorf(e.Pos(), "term cannot be a type parameter")
+		return nil
+	}
+	if isInterface(typ) {
+		c.errorf(e.Pos(), "cannot use interface %s in union", typ)
+		return nil
+	}
+	if tilde && !Identical(typ, typ.Underlying()) {
+		c.errorf(e.Pos(), "invalid use of ~ (underlying type of %s is %s)", typ, typ.Underlying())
+		return nil
+	}
+	return NewTerm(tilde, typ)
+}
+
+// instantiatedType returns the instance of the generic type gen with the
+// type arguments of e. The type arguments are verified against their
+// constraints once all declarations have been checked, since the methods of
+// a type argument may not be known yet.
+func (c *Checker) instantiatedType(e *ast.IndexExpr, gen Type) Type {
+	named, ok := gen.(*Named)
+	if !ok || named.tparams == nil || named.targs != nil {
+		if isValid(gen) {
+			c.errorf(e.Pos(), "%s is not a generic type", gen)
+		}
+		c.use(e.Indices...)
+		return Typ[Invalid]
+	}
+
+	targs := c.typeArgs(e.Indices)
+	if targs == nil {
+		return Typ[Invalid]
+	}
+	n := len(named.tparams)
+	switch {
+	case len(targs) < n:
+		c.errorf(e.Rbrack, "not enough type arguments for type %s: have %d, want %d", named, len(targs), n)
+		return Typ[Invalid]
+	case len(targs) > n:
+		c.errorf(e.Indices[n].Pos(), "too many type arguments for type %s: have %d, want %d", named, len(targs), n)
+		return Typ[Invalid]
+	}
+
+	inst := instance(named, targs)
+	c.recordInstance(e.X, targs, inst)
+	c.optionalArgs(exprPositions(e.Indices), named.tparams, targs)
+	c.later(func() {
+		c.verify(exprPositions(e.Indices), named.tparams, targs)
+	})
+	return inst
+}
+
+// typeArgs returns the types of a list of type arguments, or nil if any of
+// them is invalid.
+func (c *Checker) typeArgs(list []ast.Expr) []Type {
+	targs := make([]Type, len(list))
+	valid := true
+	for i, e := range list {
+		targs[i] = c.typExpr(e)
+		valid = valid && isValid(targs[i])
+	}
+	if !valid {
+		return nil
+	}
+	return targs
+}
+
+func exprPositions(list []ast.Expr) []lexer.Position {
+	pos := make([]lexer.Position, len(list))
+	for i, e := range list {
+		pos[i] = e.Pos()
+	}
+	return pos
+}
+
+// verify reports whether each type argument satisfies the constraint of its
+// type parameter, reporting an error at the corresponding position if not.
+func (c *Checker) verify(pos []lexer.Position, tparams []*TypeParam, targs []Type) bool {
+	smap := makeSubstMap(tparams, targs)
+	ok := true
+	for i, tp := range tparams {
+		bound := subst(tp.bound, smap)
+		if bound == nil || !isValid(bound) {
+			continue
+		}
+		iface, isIface := bound.Underlying().(*Interface)
+		if !isIface {
+			continue
+		}
+		if sat, cause := satisfies(targs[i], iface); !sat {
+			msg := fmt.Sprintf("%s does not satisfy %s", targs[i], bound)
+			if cause != "" {
+				msg += " (" + cause + ")"
+			}
+			c.errorf(pos[i], "%s", msg)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// optionalArgs reports an error for each optional type argument whose type
+// parameter forms an optional type, since ?T would then be a nested
+// optional. Which type parameters do is only known once all bodies have been
+// checked, so the check is delayed; a type argument that is itself a type
+// parameter records that it is passed on instead.
+func (c *Checker) optionalArgs(pos []lexer.Position, tparams []*TypeParam, targs []Type) {
+	for i, tp := range tparams {
+		if arg, ok := targs[i].(*TypeParam); ok {
+			arg.feeds = append(arg.feeds, tp)
+			continue
+		}
+		if !hasNil(targs[i]) {
+			continue
+		}
+		pos, targ := pos[i], targs[i]
+		c.later(func() {
+			if tp.formsOptional() {
+				c.errorf(pos, "cannot use optional type %s as %s (?%s would be ?%s)", targ, tp, tp, targ)
+			}
+		})
+	}
+}
+
+// funcInst instantiates the generic function x with the explicit type
+// arguments of e. All type parameters must be given; calls may give fewer
+// and infer the rest from their arguments.
+func (c *Checker) funcInst(x *operand, e *ast.IndexExpr) {
+	sig := x.typ.(*Signature)
+	targs := c.typeArgs(e.Indices)
+	if targs == nil {
+		x.mode = invalid
+		return
+	}
+	n := len(sig.tparams)
+	switch {
+	case len(targs) < n:
+		c.errorf(e.Rbrack, "not enough type arguments for %s: have %d, want %d", ast.ExprString(e.X), len(targs), n)
+		x.mode = invalid
+		return
+	case len(targs) > n:
+		c.errorf(e.Indices[n].Pos(), "too many type arguments for %s: have %d, want %d", ast.ExprString(e.X), len(targs), n)
+		x.mode = invalid
+		return
+	}
+	if !c.verify(exprPositions(e.Indices), sig.tparams, targs) {
+		x.mode = invalid
+		return
+	}
+	c.optionalArgs(exprPositions(e.Indices), sig.tparams, targs)
+	x.typ = instantiate(sig, targs)
+	c.recordInstance(e.X, targs, x.typ)
+}
+
+// isGeneric reports whether x is a generic function that has not been
+// instantiated.
+func isGeneric(x *operand) bool {
+	if x.mode != value {
+		return false
+	}
+	sig, ok := x.typ.(*Signature)
+	return ok && sig.tparams != nil
+}
+
+// later schedules f to run once all declarations and function bodies have
+// been checked.
+func (c *Checker) later(f func()) {
+	filename := c.filename
+	c.delayed = append(c.delayed, func() {
+		c.filename = filename
+		f()
+	})
+}
+
+func (c *Checker) recordInstance(e ast.Expr, targs []Type, typ Type) {
+	if c.info.Instances == nil {
+		return
+	}
+	if ident := instanceIdent(e); ident != nil {
+		c.info.Instances[ident] = Instance{TypeArgs: targs, Type: typ}
+	}
+}
+
+// instanceIdent returns the identifier naming the generic function or type
+// denoted by e.
+func instanceIdent(e ast.Expr) *ast.Ident {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return e.Sel
+	case *ast.ParenExpr:
+		return instanceIdent(e.X)
+	case *ast.IndexExpr:
+		return instanceIdent(e.X)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// genericCall checks a call of the generic function x, whose leading type
+// arguments targs may be given explicitly. The remaining type arguments are
+// inferred from the arguments of the call. Arrow functions with untyped
+// parameters are checked last, once the types of their parameters are known,
+// and the results of their bodies complete the inference.
+func (c *Checker) genericCall(x *operand, call *ast.CallExpr, targs []Type) {
+	sig := x.typ.(*Signature)
+	name := ast.ExprString(call.Fun)
+	params, args := sig.params, call.Args
+	required := len(params) - sig.optional
+
+	if n := len(sig.tparams); len(targs) > n {
+		ix := call.Fun.(*ast.IndexExpr)
+		c.errorf(ix.Indices[n].Pos(), "too many type arguments for %s: have %d, want %d", ast.ExprString(ix.X), len(targs), n)
+		c.use(args...)
+		x.mode = invalid
+		return
+	}
+	u := newUnifier(sig.tparams, targs)
+	operands := make([]*operand, len(args))
+
+	switch {
+	case len(args) == 1 && required > 1:
+		// a single tuple argument spread over several parameters
+		var a operand
+		c.expr(&a, args[0])
+		if a.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		t, ok := a.typ.Underlying().(*Tuple)
+		if !ok || t.Len() != len(params) {
+			c.errorf(call.Rparen, "not enough arguments in call to %s: have 1, want %d", name, len(params))
+			x.mode = invalid
+			return
+		}
+		for i, p := range params {
+			u.unify(p.typ, t.elems[i])
+		}
+		operands[0] = &a
+	case len(args) < required:
+		c.use(args...)
+		c.errorf(call.Rparen, "not enough arguments in call to %s: have %d, want %d", name, len(args), required)
+		x.mode = invalid
+		return
+	case len(args) > len(params):
+		c.use(args...)
+		c.errorf(args[len(params)].Pos(), "too many arguments in call to %s: have %d, want %d", name, len(args), len(params))
+		x.mode = invalid
+		return
+	default:
+		var lits, untyped []int
+		for i, arg := range args {
+			if lit, ok := arg.(*ast.FuncLit); ok && hasUntypedParams(lit) {
+				lits = append(lits, i)
+				continue
+			}
+			var a operand
+			c.exprWithHint(&a, arg, u.hint(params[i].typ))
+			operands[i] = &a
+			if isUntyped(a.typ) {
+				untyped = append(untyped, i)
+				continue
+			}
+			u.unify(params[i].typ, a.typ)
+		}
+		u.defaults(params, operands, untyped)
+		for _, i := range lits {
+			operands[i] = c.inferFuncLit(args[i].(*ast.FuncLit), params[i].typ, u)
+		}
+	}
+
+	for i, tp := range sig.tparams {
+		if u.targs[i] == nil {
+			c.errorf(call.Rparen, "in call to %s, cannot infer %s", name, tp)
+			x.mode = invalid
+			return
+		}
+	}
+	if !c.verify(inferredPositions(call, len(sig.tparams)), sig.tparams, u.targs) {
+		x.mode = invalid
+		return
+	}
+	c.optionalArgs(inferredPositions(call, len(sig.tparams)), sig.tparams, u.targs)
+
+	inst := instantiate(sig, u.targs)
+	c.recordInstance(call.Fun, u.targs, inst)
+	if len(args) == 1 && required > 1 {
+		t := operands[0].typ.Underlying().(*Tuple)
+		for i, p := range inst.params {
+			if !AssignableTo(t.elems[i], p.typ) {
+				c.errorf(args[0].Pos(), "cannot use element %d of %s (type %s) as %s value in argument to %s%s", i, ast.ExprString(args[0]), t.elems[i], p.typ, name, assignReason(t.elems[i], p.typ))
+			}
+		}
+	} else {
+		for i, a := range operands {
+			c.assignment(a, inst.params[i].typ, "argument to "+name)
+		}
+	}
+	x.typ = inst
+}
+
+// inferredPositions returns the positions at which unsatisfied type
+// arguments of a call are reported: those given explicitly at their own
+// positions, inferred ones at the function.
+func inferredPositions(call *ast.CallExpr, n int) []lexer.Position {
+	pos := make([]lexer.Position, n)
+	var explicit []ast.Expr
+	if ix, ok := call.Fun.(*ast.IndexExpr); ok {
+		explicit = ix.Indices
+	}
+	for i := range pos {
+		if i < len(explicit) {
+			pos[i] = explicit[i].Pos()
+		} else {
+			pos[i] = call.Fun.Pos()
+		}
+	}
+	return pos
+}
+
+// inferFuncLit checks an arrow function with untyped parameters passed for a
+// parameter of type ptyp. The types of its parameters must be known from the
+// type arguments inferred so far; unless the same holds for its results,
+// they are taken from its body and unified with those of ptyp.
+func (c *Checker) inferFuncLit(lit *ast.FuncLit, ptyp Type, u *unifier) *operand {
+	x := &operand{mode: invalid, expr: lit, typ: Typ[Invalid]}
+	want, ok := subst(ptyp, u.substMap()).(*Signature)
+	if !ok || mentionsVars(want.params, u.tparams) {
+		c.rawExpr(x, lit, nil)
+		return x
+	}
+	c.funcLitSig(x, lit, want, !mentionsVars(want.results, u.tparams))
+	c.record(x)
+	u.unify(ptyp, x.typ)
+	return x
+}
+
+// hasUntypedParams reports whether the types of the parameters of lit are
+// inferred from the context.
+func hasUntypedParams(lit *ast.FuncLit) bool {
+	for _, field := range lit.Type.Params.List {
+		if field.Type == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unifier infers the type arguments of a generic function by matching the
+// types of its parameters against those of the arguments.
+type unifier struct {
+	tparams []*TypeParam
+	targs   []Type // nil for type arguments not yet inferred
+}
+
+func newUnifier(tparams []*TypeParam, explicit []Type) *unifier {
+	targs := make([]Type, len(tparams))
+	copy(targs, explicit)
+	return &unifier{tparams: tparams, targs: targs}
+}
+
+func (u *unifier) substMap() substMap {
+	return makeSubstMap(u.tparams, u.targs)
+}
+
+// hint returns the type of a parameter with the type arguments inferred so
+// far, or nil if it still depends on others.
+func (u *unifier) hint(ptyp Type) Type {
+	t := subst(ptyp, u.substMap())
+	if mentions(t, u.tparams) {
+		return nil
+	}
+	return t
+}
+
+// defaults infers the type arguments for the type parameters that are the
+// types of parameters given the untyped arguments at the indices untyped,
+// and that the other arguments did not infer. They take the default type of
+// the largest kind among those arguments, as in 1 + 2.5.
+func (u *unifier) defaults(params []*Var, args []*operand, untyped []int) {
+	kinds := make(map[int]BasicKind)
+	for _, i := range untyped {
+		tp, ok := params[i].typ.(*TypeParam)
+		if !ok || args[i].isNil() {
+			continue
+		}
+		if j := u.index(tp); j >= 0 && u.targs[j] == nil {
+			kinds[j] = max(kinds[j], args[i].typ.(*Basic).kind)
+		}
+	}
+	for j, k := range kinds {
+		u.targs[j] = defaultType(Typ[k])
+	}
+}
+
+func (u *unifier) index(t *TypeParam) int {
+	for i, tp := range u.tparams {
+		if tp == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// unify matches the parameter type x against the argument type y, inferring
+// the type arguments for the type parameters it finds in x. Mismatches are
+// not reported here; they surface when the arguments are assigned to the
+// instantiated parameters.
+func (u *unifier) unify(x, y Type) {
+	if y == nil || !isValid(y) || isUntypedNil(y) {
+		return
+	}
+	if tp, ok := x.(*TypeParam); ok {
+		if i := u.index(tp); i >= 0 {
+			if u.targs[i] == nil {
+				u.targs[i] = y
+			}
+			return
+		}
+	}
+
+	if xn, ok := x.(*Named); ok {
+		if yn, ok := y.(*Named); ok && xn.targs != nil && xn.Origin() == yn.Origin() {
+			for i := range xn.targs {
+				if i < len(yn.targs) {
+					u.unify(xn.targs[i], yn.targs[i])
+				}
+			}
+		}
+		return
+	}
+
+	// a named argument type matches through its underlying type
+	switch x := x.(type) {
+	case *Array:
+		if y, ok := y.Underlying().(*Array); ok {
+			u.unify(x.elem, y.elem)
+		}
+	case *Slice:
+		if y, ok := y.Underlying().(*Slice); ok {
+			u.unify(x.elem, y.elem)
+		}
+	case *Map:
+		if y, ok := y.Underlying().(*Map); ok {
+			u.unify(x.key, y.key)
+			u.unify(x.elem, y.elem)
+		}
+	case *Optional:
+		if y, ok := y.Underlying().(*Optional); ok {
+			u.unify(x.elem, y.elem)
+		} else {
+			u.unify(x.elem, y)
+		}
+	case *Tuple:
+		if y, ok := y.Underlying().(*Tuple); ok && x.Len() == y.Len() {
+			for i := range x.elems {
+				u.unify(x.elems[i], y.elems[i])
+			}
+		}
+	case *Struct:
+		if y, ok := y.Underlying().(*Struct); ok {
+			u.unifyVars(x.fields, y.fields)
+		}
+	case *Record:
+		if y, ok := y.Underlying().(*Record); ok {
+			u.unifyVars(x.fields, y.fields)
+		}
+	case *Signature:
+		if y, ok := y.Underlying().(*Signature); ok {
+			u.unifyVars(x.params, y.params)
+			u.unifyVars(x.results, y.results)
+		}
+	}
+}
+
+func (u *unifier) unifyVars(x, y []*Var) {
+	if len(x) != len(y) {
+		return
+	}
+	for i := range x {
+		u.unify(x[i].typ, y[i].typ)
+	}
+}
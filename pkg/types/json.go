@@ -0,0 +1,215 @@
+package types
+
+import (
+	"go/constant"
+	"go/token"
+	"slices"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// jsonLit checks a #json literal. Without a hint the literal has type
+// any. Otherwise its value must be one of the hint type, which the literal
+// takes: objects are values of struct, record and map types, arrays those
+// of slice, array and tuple types, and strings those of string and symbol
+// types, where symbol sets must hold the symbol the string names.
+func (c *Checker) jsonLit(x *operand, e *ast.JSONLit, hint Type) {
+	switch {
+	case hint == nil:
+		x.mode, x.typ = value, anyType
+	case isValid(hint) && c.jsonValue(e.Value, hint):
+		x.mode, x.typ = value, hint
+	}
+}
+
+// jsonValue reports whether the JSON value v is one of type T, reporting
+// an error at v if it is not.
+func (c *Checker) jsonValue(v *ast.JSONValue, T Type) bool {
+	switch u := T.Underlying().(type) {
+	case *Interface:
+		if u.Empty() {
+			return true
+		}
+	case *Optional:
+		if v.Kind == ast.JSONNull {
+			return true
+		}
+		return c.jsonValue(v, u.elem)
+	case *Basic:
+		switch {
+		case v.Kind == ast.JSONBool && u.kind == Bool,
+			v.Kind == ast.JSONString && (u.kind == String || u.kind == Symbol):
+			return true
+		case v.Kind == ast.JSONNumber && (u.kind == Int || u.kind == Int64 || u.kind == Float):
+			val := constant.MakeFromLiteral(v.Text, token.FLOAT, 0)
+			if _, reason := representable(val, u); reason != "" {
+				c.errorf(v.Pos, "JSON number %s %s %s", v.Text, reason, T)
+				return false
+			}
+			return true
+		}
+	case *SymbolSet:
+		if v.Kind == ast.JSONString {
+			if !u.Contains(v.Text) {
+				c.errorf(v.Pos, "cannot use JSON string %q as %s value in #json literal (not in the set)", v.Text, T)
+				return false
+			}
+			return true
+		}
+	case *Slice:
+		if v.Kind == ast.JSONArray {
+			return c.jsonElts(v, func(int) Type { return u.elem })
+		}
+	case *Array:
+		if v.Kind == ast.JSONArray {
+			if int64(len(v.Elts)) != u.len {
+				c.errorf(v.Pos, "JSON array of length %d in #json literal of type %s", len(v.Elts), T)
+				return false
+			}
+			return c.jsonElts(v, func(int) Type { return u.elem })
+		}
+	case *Tuple:
+		if v.Kind == ast.JSONArray {
+			if len(v.Elts) != len(u.elems) {
+				c.errorf(v.Pos, "JSON array of length %d in #json literal of type %s", len(v.Elts), T)
+				return false
+			}
+			return c.jsonElts(v, func(i int) Type { return u.elems[i] })
+		}
+	case *Map:
+		if !isString(u.key) {
+			c.errorf(v.Pos, "invalid #json literal type %s: map keys must be strings", T)
+			return false
+		}
+		if v.Kind == ast.JSONObject {
+			return c.jsonElts(v, func(int) Type { return u.elem })
+		}
+	case *Struct:
+		if v.Kind == ast.JSONObject {
+			return c.jsonFields(v, T, u.fields)
+		}
+	case *Record:
+		if v.Kind == ast.JSONObject {
+			return c.jsonFields(v, T, u.fields)
+		}
+	}
+	c.errorf(v.Pos, "cannot use JSON %s as %s value in #json literal", v.Kind, T)
+	return false
+}
+
+// jsonElts checks the elements of a JSON array or the member values of a
+// JSON object against the types elem returns for them.
+func (c *Checker) jsonElts(v *ast.JSONValue, elem func(i int) Type) bool {
+	ok := true
+	for i, elt := range v.Elts {
+		if !c.jsonValue(elt, elem(i)) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// jsonFields checks the members of a JSON object against the fields of a
+// struct or record type T. Missing fields take their zero values.
+func (c *Checker) jsonFields(v *ast.JSONValue, T Type, fields []*Var) bool {
+	ok := true
+	for i, key := range v.Keys {
+		f := lookupField(fields, key)
+		if f == nil {
+			c.errorf(v.Elts[i].Pos, "unknown field %q in #json literal of type %s", key, T)
+			ok = false
+			continue
+		}
+		if !c.jsonValue(v.Elts[i], f.typ) {
+			ok = false
+		}
+	}
+	// fields left out take their zero values
+	for _, f := range fields {
+		if !slices.Contains(v.Keys, f.name) && !hasZero(f.typ) {
+			c.errorf(v.Pos, "missing field %q in #json literal of type %s (%s has no zero value)", f.name, T, f.typ)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// jsonType reports whether the values of type T convert to and from JSON
+// at run time, as json_decode and json_encode do, reporting an error at pos
+// if they do not. They are the values of the types #json literals may
+// have, except for types depending on type parameters, which generic code
+// does not know. conv describes the conversion, such as "decode %s from".
+func (c *Checker) jsonType(pos lexer.Position, T Type, conv string) bool {
+	bad := nonJSON(T, make(map[*Named]bool))
+	_, tparam := bad.(*TypeParam)
+	switch {
+	case bad == nil:
+		return true
+	case tparam:
+		c.errorf(pos, "cannot "+conv+" JSON: %s is a type parameter", T, bad)
+	case bad == T:
+		c.errorf(pos, "cannot "+conv+" JSON", T)
+	default:
+		c.errorf(pos, "cannot "+conv+" JSON: %s has no JSON values", T, bad)
+	}
+	return false
+}
+
+// nonJSON returns the type in T whose values have no JSON values, or nil
+// if there is none. seen holds the named types being checked.
+func nonJSON(T Type, seen map[*Named]bool) Type {
+	switch t := T.(type) {
+	case *TypeParam:
+		return T
+	case *Named:
+		if seen[t] {
+			return nil
+		}
+		seen[t] = true
+	}
+	switch u := T.Underlying().(type) {
+	case *Interface:
+		if u.Empty() {
+			return nil
+		}
+	case *Optional:
+		return nonJSON(u.elem, seen)
+	case *Basic:
+		switch u.kind {
+		case Bool, String, Symbol, Int, Int64, Float:
+			return nil
+		}
+	case *SymbolSet:
+		return nil
+	case *Slice:
+		return nonJSON(u.elem, seen)
+	case *Array:
+		return nonJSON(u.elem, seen)
+	case *Tuple:
+		for _, elem := range u.elems {
+			if bad := nonJSON(elem, seen); bad != nil {
+				return bad
+			}
+		}
+		return nil
+	case *Map:
+		if isString(u.key) {
+			return nonJSON(u.elem, seen)
+		}
+	case *Struct:
+		return nonJSONFields(u.fields, seen)
+	case *Record:
+		return nonJSONFields(u.fields, seen)
+	}
+	return T
+}
+
+func nonJSONFields(fields []*Var, seen map[*Named]bool) Type {
+	for _, f := range fields {
+		if bad := nonJSON(f.typ, seen); bad != nil {
+			return bad
+		}
+	}
+	return nil
+}
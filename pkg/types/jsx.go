@@ -0,0 +1,177 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+// jsxElement checks a JSX element, whose value has type any. The
+// attributes and children of intrinsic elements and fragments may be any
+// values. Those of components are checked against the props of the
+// component, a function taking its props as a struct or record and
+// returning what it renders: each attribute sets the field it names, which
+// a bare attribute sets to true, and the children set the field children.
+// The key attribute identifies elements rather than setting a field.
+// Components whose props are of a named struct or record type must be
+// functions declared by the package, which convert the plain objects
+// they are passed in JavaScript to values of that type.
+func (c *Checker) jsxElement(x *operand, e *ast.JSXElement) {
+	x.mode, x.typ = value, anyType
+
+	var props Type
+	var fields []*Var
+	if e.Name != nil {
+		var f operand
+		c.expr(&f, e.Name)
+		if f.mode == invalid {
+			c.jsxUse(e)
+			return
+		}
+		sig, _ := f.typ.Underlying().(*Signature)
+		switch {
+		case sig == nil:
+			c.errorf(e.Name.Pos(), "cannot use %s as JSX component: not a function", &f)
+			c.jsxUse(e)
+			return
+		case len(sig.params) > 1 || len(sig.results) != 1:
+			c.errorf(e.Name.Pos(), "cannot use %s as JSX component: components take their props and return what they render", &f)
+			c.jsxUse(e)
+			return
+		case len(sig.params) == 1:
+			props = sig.params[0].typ
+			switch u := props.Underlying().(type) {
+			case *Struct:
+				fields = u.fields
+			case *Record:
+				fields = u.fields
+			default:
+				c.errorf(e.Name.Pos(), "cannot use %s as JSX component: props must be a struct or record, not %s", &f, props)
+				c.jsxUse(e)
+				return
+			}
+			if n, ok := props.(*Named); ok && !n.Extern() && !c.packageFunc(e.Name) {
+				c.errorf(e.Name.Pos(), "cannot use %s as JSX component: props of named type %s require a function declared by the package", &f, props)
+				c.jsxUse(e)
+				return
+			}
+		}
+	}
+
+	for _, a := range e.Attrs {
+		if a.Name == "key" {
+			c.jsxKey(a)
+			continue
+		}
+		if e.Name == nil {
+			if a.Value != nil {
+				var v operand
+				c.expr(&v, a.Value)
+				c.assignment(&v, nil, "JSX attribute")
+			}
+			continue
+		}
+		f := lookupField(fields, a.Name)
+		switch {
+		case f == nil && props == nil:
+			c.errorf(a.Pos(), "unknown prop %s: %s takes no props", a.Name, e.Tag)
+			c.use(a.Value)
+		case f == nil:
+			c.errorf(a.Pos(), "unknown field %s in props of type %s", a.Name, props)
+			c.use(a.Value)
+		case a.Value == nil:
+			if !isBoolean(f.typ) {
+				c.errorf(a.Pos(), "missing value for prop %s of type %s", a.Name, f.typ)
+			}
+		default:
+			var v operand
+			c.exprWithHint(&v, a.Value, f.typ)
+			c.assignment(&v, f.typ, "JSX attribute")
+		}
+	}
+
+	if len(e.Children) == 0 {
+		return
+	}
+	if e.Name == nil {
+		for _, child := range e.Children {
+			var v operand
+			c.expr(&v, child)
+			c.assignment(&v, nil, "JSX child")
+		}
+		return
+	}
+	f := lookupField(fields, "children")
+	if f == nil {
+		c.errorf(e.Children[0].Pos(), "%s takes no children: its props have no field children", e.Tag)
+		c.use(e.Children...)
+		return
+	}
+	c.jsxChildren(e.Children, f.typ)
+}
+
+// packageFunc reports whether e denotes a function declared by the
+// package.
+func (c *Checker) packageFunc(e ast.Expr) bool {
+	ident, ok := unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	fn, ok := c.lookup(ident.Name).(*Func)
+	return ok && c.pkg.scope.Lookup(ident.Name) == fn
+}
+
+// jsxChildren checks the children of a component element against the type
+// T of the field children of its props. A single child is its value, or
+// an element of it if it is a slice, and several children are the
+// elements of a slice or any value.
+func (c *Checker) jsxChildren(children []ast.Expr, T Type) {
+	s, _ := T.Underlying().(*Slice)
+	if len(children) == 1 {
+		var v operand
+		c.exprWithHint(&v, children[0], T)
+		if s != nil && v.mode != invalid && !AssignableTo(v.typ, T) {
+			c.assignment(&v, s.elem, "JSX child")
+			return
+		}
+		c.assignment(&v, T, "JSX child")
+		return
+	}
+	if s == nil && !isInterface(T) {
+		c.errorf(children[1].Pos(), "too many JSX children for field children of type %s", T)
+		c.use(children...)
+		return
+	}
+	for _, child := range children {
+		var v operand
+		if s != nil {
+			c.exprWithHint(&v, child, s.elem)
+			c.assignment(&v, s.elem, "JSX child")
+		} else {
+			c.expr(&v, child)
+			c.assignment(&v, T, "JSX child")
+		}
+	}
+}
+
+// jsxKey checks the key attribute a, which must be a string or an integer.
+func (c *Checker) jsxKey(a *ast.JSXAttr) {
+	if a.Value == nil {
+		c.errorf(a.Pos(), "missing value for JSX key")
+		return
+	}
+	var v operand
+	c.expr(&v, a.Value)
+	if v.mode != invalid && !isString(v.typ) && !isInteger(v.typ) {
+		c.errorf(a.Value.Pos(), "invalid JSX key %s: must be a string or an integer", &v)
+		return
+	}
+	c.assignment(&v, nil, "JSX key")
+}
+
+// jsxUse checks the attributes and children of an element whose component
+// is invalid.
+func (c *Checker) jsxUse(e *ast.JSXElement) {
+	for _, a := range e.Attrs {
+		c.use(a.Value)
+	}
+	c.use(e.Children...)
+}
@@ -0,0 +1,151 @@
+package types
+
+import (
+	"strings"
+)
+
+// LookupFieldOrMethod returns the field or method of values of type T with
+// the given name, or nil. Fields and methods of embedded struct fields are
+// promoted, and values of a type parameter have the methods of its
+// constraint; index is the sequence of field indices leading to the result,
+// ending with the index of the field or method itself. A name declared more
+// than once at the shallowest depth at which it occurs is ambiguous and not
+// found.
+func LookupFieldOrMethod(T Type, name string) (obj Object, index []int) {
+	type entry struct {
+		typ   Type
+		index []int
+	}
+	current := []entry{{typ: T}}
+	seen := make(map[*Named]bool)
+
+	for len(current) > 0 {
+		var next []entry
+		var found Object
+		var foundIndex []int
+		count := 0
+
+		for _, e := range current {
+			if named, ok := e.typ.(*Named); ok {
+				if seen[named] {
+					continue
+				}
+				seen[named] = true
+				for i, m := range named.methodList() {
+					if m.name == name {
+						found, foundIndex = m, concat(e.index, i)
+						count++
+					}
+				}
+			}
+
+			var fields []*Var
+			switch u := e.typ.Underlying().(type) {
+			case *Struct:
+				fields = u.fields
+			case *Record:
+				fields = u.fields
+			case *Interface:
+				for i, m := range u.allMethods() {
+					if m.name == name {
+						found, foundIndex = m, concat(e.index, i)
+						count++
+					}
+				}
+			case *TypeParam:
+				// the methods of the constraint
+				for i, m := range u.iface().allMethods() {
+					if m.name == name {
+						found, foundIndex = m, concat(e.index, i)
+						count++
+					}
+				}
+			}
+			for i, f := range fields {
+				if f.name == name {
+					found, foundIndex = f, concat(e.index, i)
+					count++
+				}
+				if f.embedded {
+					next = append(next, entry{f.typ, concat(e.index, i)})
+				}
+			}
+		}
+
+		switch {
+		case count == 1:
+			return found, foundIndex
+		case count > 1:
+			return nil, nil
+		}
+		current = next
+	}
+	return nil, nil
+}
+
+func concat(list []int, i int) []int {
+	out := make([]int, len(list)+1)
+	copy(out, list)
+	out[len(list)] = i
+	return out
+}
+
+// Implements reports whether values of type V implement the interface T:
+// V must have every method of T, with an identical signature. Interfaces are
+// satisfied structurally; V need not mention T.
+func Implements(V Type, T *Interface) bool {
+	m, _ := missingMethod(V, T)
+	return m == nil
+}
+
+// missingMethod returns the first method of T, by name, that values of type
+// V lack or have with a different signature, and reports which is the case.
+// It returns nil if V implements T.
+func missingMethod(V Type, T *Interface) (method *Func, wrongType bool) {
+	for _, m := range T.allMethods() {
+		obj, _ := LookupFieldOrMethod(V, m.name)
+		f, ok := obj.(*Func)
+		if !ok {
+			return m, false
+		}
+		if !Identical(f.typ, m.typ) {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// notImplemented explains why values of type V do not implement T, naming
+// every method that V lacks and every method V has with another signature.
+// It returns "" if V implements T.
+func notImplemented(V Type, T *Interface) string {
+	var missing, reasons []string
+	for _, m := range T.allMethods() {
+		obj, _ := LookupFieldOrMethod(V, m.name)
+		switch obj := obj.(type) {
+		case *Func:
+			if Identical(obj.typ, m.typ) {
+				continue
+			}
+			have := obj.Signature()
+			if have == nil {
+				reasons = append(reasons, "wrong type for method "+m.name)
+				continue
+			}
+			reasons = append(reasons, "wrong type for method "+m.name+": have "+m.name+signatureString(have)+
+				", want "+m.name+signatureString(m.Signature()))
+		case *Var:
+			reasons = append(reasons, m.name+" is a field, not a method")
+		default:
+			missing = append(missing, m.name)
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		reasons = append([]string{"missing method " + missing[0]}, reasons...)
+	default:
+		reasons = append([]string{"missing methods " + strings.Join(missing, ", ")}, reasons...)
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,265 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+// matchExpr checks a match expression. In statement context the arms are
+// checked as statements; otherwise each arm must produce a value, unless it
+// ends the function, and the arm values determine the type of the match.
+func (c *Checker) matchExpr(x *operand, e *ast.MatchExpr, hint Type, isStmt bool) {
+	var subject operand
+	c.expr(&subject, e.X)
+	T := subject.typ
+	if subject.mode == invalid {
+		T = Typ[Invalid]
+	}
+
+	result := hint
+	valid := true
+	heads := make(map[ast.Pattern]*ctor)
+	patternErrs := false
+	arms := c.branches()
+	for _, arm := range e.Arms {
+		arms.begin()
+		c.openScope(arm, "match arm")
+		b := &binder{vars: make(map[string]*Var), heads: heads}
+		errs := len(c.errors)
+		c.pattern(arm.Pattern, T, b)
+		patternErrs = patternErrs || len(c.errors) > errs
+		for _, v := range b.list {
+			c.declare(c.scope, nil, v)
+			c.local(v)
+		}
+		if arm.Guard != nil {
+			c.cond(arm.Guard, "match guard")
+		}
+		if isStmt {
+			c.stmt(arm.Body)
+		} else if !c.armValue(arm.Body, &result) {
+			valid = false
+		}
+		c.closeScope()
+		arms.end(!isTerminating(arm.Body))
+	}
+	arms.join()
+	if isValid(T) && !patternErrs {
+		c.exhaustive(e, T, heads)
+	}
+
+	switch {
+	case isStmt || (valid && result == nil):
+		// a statement, or a match whose arms all end the function
+		x.mode = novalue
+	case valid:
+		x.mode = value
+		x.typ = result
+	}
+}
+
+// armValue checks the body of a match arm whose value is used. The type of
+// the first arm with a value is the type of the match unless the context
+// expects one.
+func (c *Checker) armValue(body ast.Stmt, result *Type) bool {
+	s, ok := body.(*ast.ExprStmt)
+	if !ok || isTerminating(body) {
+		c.stmt(body)
+		if !isTerminating(body) {
+			c.errorf(body.Pos(), "missing value in match arm")
+			return false
+		}
+		return true
+	}
+
+	var v operand
+	c.exprWithHint(&v, s.X, *result)
+	switch {
+	case v.mode == invalid:
+		return false
+	case *result != nil:
+		return c.assignment(&v, *result, "match arm")
+	case v.isNil():
+		c.errorf(s.X.Pos(), "use of untyped nil in match arm")
+		return false
+	case !c.assignment(&v, nil, "match arm"):
+		return false
+	}
+	*result = v.typ
+	return true
+}
+
+// binder collects the variables bound by a pattern. The alternatives of an
+// or-pattern bind the same variables. heads collects the constructors of
+// the enum variant, constant and literal patterns.
+type binder struct {
+	vars  map[string]*Var
+	list  []*Var
+	heads map[ast.Pattern]*ctor
+}
+
+func (c *Checker) bind(ident *ast.Ident, T Type, b *binder) {
+	if v := b.vars[ident.Name]; v != nil {
+		c.recordDef(ident, v)
+		if isValid(v.typ) && isValid(T) && !Identical(v.typ, T) {
+			c.errorf(ident.Pos(), "%s has type %s in one alternative and %s in another", ident.Name, v.typ, T)
+		}
+		return
+	}
+	v := NewVar(ident.Pos(), ident.Name, T)
+	c.recordDef(ident, v)
+	if ident.Name == "_" {
+		return
+	}
+	b.vars[ident.Name] = v
+	b.list = append(b.list, v)
+}
+
+// pattern checks a pattern matched against values of type T and collects
+// the variables it binds.
+func (c *Checker) pattern(p ast.Pattern, T Type, b *binder) {
+	switch p := p.(type) {
+	case *ast.BadPat, *ast.WildcardPat:
+		// nothing to do
+
+	case *ast.BindPat:
+		c.bind(p.Name, T, b)
+
+	case *ast.LitPat:
+		var x operand
+		c.exprWithHint(&x, p.Value, T)
+		if c.assignment(&x, T, "match pattern") {
+			b.heads[p] = constCtor(x.val, T, ast.ExprString(p.Value))
+		}
+
+	case *ast.VariantPat:
+		c.variantPat(p, T, b)
+
+	case *ast.TuplePat:
+		t, ok := T.Underlying().(*Tuple)
+		if !ok || t.Len() != len(p.Elts) {
+			if isValid(T) {
+				c.errorf(p.Pos(), "cannot match tuple pattern with %d elements against value of type %s", len(p.Elts), T)
+			}
+			c.invalidPatterns(p.Elts, b)
+			return
+		}
+		for i, elt := range p.Elts {
+			c.pattern(elt, t.elems[i], b)
+		}
+
+	case *ast.RecordPat:
+		c.recordPat(p, T, b)
+
+	case *ast.OrPat:
+		for _, alt := range p.Alts {
+			c.pattern(alt, T, b)
+		}
+	}
+}
+
+// invalidPatterns checks patterns matched against a value of invalid type,
+// so that their variables are still declared.
+func (c *Checker) invalidPatterns(list []ast.Pattern, b *binder) {
+	for _, p := range list {
+		c.pattern(p, Typ[Invalid], b)
+	}
+}
+
+func (c *Checker) variantPat(p *ast.VariantPat, T Type, b *binder) {
+	if sel, ok := p.Path.(*ast.SelectorExpr); ok {
+		var base operand
+		c.rawExpr(&base, sel.X, nil)
+		if base.mode == invalid {
+			c.invalidPatterns(p.Args, b)
+			return
+		}
+		if n, ok := base.typ.(*Named); ok && n.tparams != nil && n.targs == nil {
+			// the variants of a generic enum match its instances
+			if tn, ok := T.(*Named); ok && tn.Origin() == n {
+				base.typ = T
+			}
+		}
+		if enum, ok := base.typ.Underlying().(*Enum); ok && base.mode == typexpr {
+			v := enum.Lookup(sel.Sel.Name)
+			if v == nil {
+				c.errorf(sel.Sel.Pos(), "%s undefined (type %s has no variant %s)", ast.ExprString(sel), base.typ, sel.Sel.Name)
+				c.invalidPatterns(p.Args, b)
+				return
+			}
+			c.record(&operand{mode: value, expr: p.Path, typ: base.typ})
+			if isValid(T) && !Identical(base.typ, T) {
+				c.errorf(p.Path.Pos(), "cannot match %s against value of type %s", ast.ExprString(p.Path), T)
+				c.invalidPatterns(p.Args, b)
+				return
+			}
+			b.heads[p] = variantCtor(v)
+			if !p.Lparen.IsValid() {
+				return
+			}
+			if len(p.Args) != len(v.payload) {
+				c.errorf(p.Lparen, "wrong number of fields in pattern %s: have %d, want %d", ast.ExprString(p.Path), len(p.Args), len(v.payload))
+				c.invalidPatterns(p.Args, b)
+				return
+			}
+			for i, arg := range p.Args {
+				c.pattern(arg, v.payload[i].typ, b)
+			}
+			return
+		}
+	}
+
+	// the path names a constant, whose value is matched
+	if p.Lparen.IsValid() {
+		c.errorf(p.Path.Pos(), "%s is not an enum variant", ast.ExprString(p.Path))
+		c.invalidPatterns(p.Args, b)
+		return
+	}
+	var x operand
+	c.exprWithHint(&x, p.Path, T)
+	if x.mode != invalid && x.mode != constant_ {
+		c.errorf(p.Path.Pos(), "%s is not constant", &x)
+		return
+	}
+	if c.assignment(&x, T, "match pattern") {
+		b.heads[p] = constCtor(x.val, T, ast.ExprString(p.Path))
+	}
+}
+
+func (c *Checker) recordPat(p *ast.RecordPat, T Type, b *binder) {
+	typ := T
+	if p.Type != nil {
+		pt := c.typExpr(p.Type)
+		if isValid(pt) && isValid(T) && !Identical(pt, T) && !AssignableTo(T, pt) {
+			c.errorf(p.Type.Pos(), "cannot match %s pattern against value of type %s", pt, T)
+			pt = Typ[Invalid]
+		}
+		typ = pt
+	}
+
+	switch typ.Underlying().(type) {
+	case *Struct, *Record:
+	default:
+		if isValid(typ) {
+			c.errorf(p.Lbrace, "cannot match record pattern against value of type %s", typ)
+		}
+		typ = Typ[Invalid]
+	}
+
+	for _, fp := range p.Fields {
+		ftype := Type(Typ[Invalid])
+		if isValid(typ) {
+			obj, _ := LookupFieldOrMethod(typ, fp.Name.Name)
+			if f, ok := obj.(*Var); ok && f.field {
+				c.recordUse(fp.Name, f)
+				ftype = f.typ
+			} else {
+				c.errorf(fp.Name.Pos(), "unknown field %s in pattern of type %s", fp.Name.Name, typ)
+			}
+		}
+		if fp.Pattern == nil {
+			c.bind(fp.Name, ftype, b)
+			continue
+		}
+		c.pattern(fp.Pattern, ftype, b)
+	}
+}
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"go/constant"
+	"sort"
+
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Object is a named language entity such as a variable, constant, type or
+// function.
+type Object interface {
+	Name() string
+	Type() Type
+	Pos() lexer.Position
+	// Parent returns the scope in which the object is declared, or nil for
+	// fields and methods.
+	Parent() *Scope
+	setParent(*Scope)
+}
+
+type object struct {
+	parent *Scope
+	pos    lexer.Position
+	name   string
+	typ    Type
+}
+
+func (obj *object) Name() string        { return obj.name }
+func (obj *object) Type() Type          { return obj.typ }
+func (obj *object) Pos() lexer.Position { return obj.pos }
+func (obj *object) Parent() *Scope      { return obj.parent }
+func (obj *object) setParent(s *Scope)  { obj.parent = s }
+func (obj *object) setType(typ Type)    { obj.typ = typ }
+
+// Var is a variable, parameter, result or field.
+type Var struct {
+	object
+	field    bool
+	embedded bool
+	used     bool    // read by an expression other than an assignment
+	extern   *Extern // the JavaScript binding of an extern variable
+}
+
+func NewVar(pos lexer.Position, name string, typ Type) *Var {
+	return &Var{object: object{pos: pos, name: name, typ: typ}}
+}
+
+func NewField(pos lexer.Position, name string, typ Type, embedded bool) *Var {
+	return &Var{object: object{pos: pos, name: name, typ: typ}, field: true, embedded: embedded}
+}
+
+func (v *Var) IsField() bool  { return v.field }
+func (v *Var) Embedded() bool { return v.embedded }
+
+// Extern returns the JavaScript binding of an extern variable, or nil.
+func (v *Var) Extern() *Extern { return v.extern }
+
+// Const is a declared constant.
+type Const struct {
+	object
+	val constant.Value
+}
+
+func NewConst(pos lexer.Position, name string, typ Type, val constant.Value) *Const {
+	return &Const{object: object{pos: pos, name: name, typ: typ}, val: val}
+}
+
+func (c *Const) Val() constant.Value { return c.val }
+
+// TypeName is a declared or predeclared type.
+type TypeName struct {
+	object
+}
+
+func NewTypeName(pos lexer.Position, name string, typ Type) *TypeName {
+	return &TypeName{object{pos: pos, name: name, typ: typ}}
+}
+
+// Func is a declared function or method, or an interface method.
+type Func struct {
+	object
+	mustUse bool    // the results of calls must be used
+	extern  *Extern // the JavaScript binding of an extern function or method
+}
+
+func NewFunc(pos lexer.Position, name string, sig *Signature) *Func {
+	var typ Type
+	if sig != nil {
+		typ = sig
+	}
+	return &Func{object: object{pos: pos, name: name, typ: typ}}
+}
+
+// Signature returns the signature of f.
+func (f *Func) Signature() *Signature {
+	sig, _ := f.typ.(*Signature)
+	return sig
+}
+
+// Extern returns the JavaScript binding of an extern function or of a
+// method of an extern type, or nil.
+func (f *Func) Extern() *Extern { return f.extern }
+
+// Builtin is a predeclared function such as len.
+type Builtin struct {
+	object
+	id builtinID
+}
+
+func sortFuncs(list []*Func) {
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+}
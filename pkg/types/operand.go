@@ -0,0 +1,91 @@
+package types
+
+import (
+	"go/constant"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+// operandMode describes what an expression denotes.
+type operandMode int
+
+const (
+	invalid   operandMode = iota // the expression has errors
+	novalue                      // a call without results
+	builtin                      // a built-in function
+	typexpr                      // a type
+	constant_                    // a constant, whose value is known
+	variable                     // an addressable value
+	mapindex                     // a map index, which may be assigned to
+	value                        // any other value
+)
+
+var operandModeString = [...]string{
+	invalid:   "invalid operand",
+	novalue:   "no value",
+	builtin:   "built-in",
+	typexpr:   "type",
+	constant_: "constant",
+	variable:  "variable",
+	mapindex:  "map index expression",
+	value:     "value",
+}
+
+// operand is the result of checking an expression.
+type operand struct {
+	mode operandMode
+	expr ast.Expr
+	typ  Type
+	val  constant.Value
+	id   builtinID
+
+	// mustUse is set for functions with the must_use attribute and for
+	// the results of their calls.
+	mustUse bool
+}
+
+// String formats x as in `f() (value of type int)` for use in errors. The
+// values of constants are shown unless they are spelled out by the
+// expression, as in `1 << 70 (untyped int constant 1180591620717411303424)`.
+func (x *operand) String() string {
+	expr := "?"
+	if x.expr != nil {
+		expr = ast.ExprString(x.expr)
+	}
+	switch x.mode {
+	case invalid, novalue, builtin, typexpr:
+		return expr + " (" + operandModeString[x.mode] + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString(expr + " (")
+	if isUntyped(x.typ) {
+		b.WriteString(x.typ.String() + " ")
+	}
+	b.WriteString(operandModeString[x.mode])
+	if x.mode == constant_ {
+		s := x.val.String()
+		if isSymbol(x.typ) {
+			s = ":" + constant.StringVal(x.val)
+		}
+		if s != expr {
+			b.WriteString(" " + s)
+		}
+	}
+	if !isUntyped(x.typ) {
+		b.WriteString(" of type " + x.typ.String())
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (x *operand) isNil() bool {
+	return x.mode == value && isUntypedNil(x.typ)
+}
+
+func (x *operand) setConst(val constant.Value, typ Type) {
+	x.mode = constant_
+	x.val = val
+	x.typ = typ
+}
@@ -0,0 +1,316 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Values of optional types are narrowed to their element types where a
+// check against nil proves them non-nil: in the branches of an if statement
+// and the operands of && and || that its condition guards, in the body of a
+// for loop, and in the rest of a block after an if statement that leaves it
+// when the value is nil.
+// Only local variables are narrowed, and only in the function that checks
+// them; assigning to a variable ends its narrowing. A function literal may
+// assign a variable it captures whenever it is called, so a variable that
+// one assigns is never narrowed.
+
+// narrowing maps local variables of optional type to the types they are
+// narrowed to.
+type narrowing map[*Var]Type
+
+// narrowedType returns the type v is narrowed to, or nil if it is not.
+func (c *Checker) narrowedType(v *Var) Type {
+	if c.fn == nil {
+		return nil
+	}
+	return c.fn.narrowed[v]
+}
+
+// nilChecks returns the variables that the condition e proves non-nil when
+// it evaluates to truth.
+func (c *Checker) nilChecks(e ast.Expr, truth bool) narrowing {
+	switch e := unparen(e).(type) {
+	case *ast.UnaryExpr:
+		if e.Op == lexer.NOT {
+			return c.nilChecks(e.X, !truth)
+		}
+
+	case *ast.BinaryExpr:
+		switch {
+		case e.Op == lexer.AND && truth, e.Op == lexer.OR && !truth:
+			facts := c.nilChecks(e.X, truth)
+			for v, t := range c.nilChecks(e.Y, truth) {
+				if facts == nil {
+					facts = make(narrowing)
+				}
+				facts[v] = t
+			}
+			return facts
+		case e.Op == lexer.NEQ && truth, e.Op == lexer.EQ && !truth:
+			x, y := e.X, e.Y
+			if isNilLit(x) {
+				x, y = y, x
+			}
+			if !isNilLit(y) {
+				return nil
+			}
+			if v, elem := c.optionalVar(x); v != nil && !c.closureAssigned(v) {
+				return narrowing{v: elem}
+			}
+		}
+	}
+	return nil
+}
+
+func isNilLit(e ast.Expr) bool {
+	lit, ok := unparen(e).(*ast.BasicLit)
+	return ok && lit.Kind == lexer.NIL
+}
+
+// optionalVar returns the local variable of optional type that e denotes,
+// if any, and the element type of its type.
+func (c *Checker) optionalVar(e ast.Expr) (*Var, Type) {
+	v := c.localVar(e)
+	if v == nil || v.typ == nil {
+		return nil, nil
+	}
+	opt, ok := v.typ.Underlying().(*Optional)
+	if !ok {
+		return nil, nil
+	}
+	return v, opt.elem
+}
+
+// localVar returns the variable declared in a function that e denotes, if
+// any.
+func (c *Checker) localVar(e ast.Expr) *Var {
+	ident, ok := unparen(e).(*ast.Ident)
+	if !ok || c.fn == nil {
+		return nil
+	}
+	v, ok := c.lookup(ident.Name).(*Var)
+	if !ok || v.field || v.parent == nil || v.parent == c.pkg.scope {
+		return nil
+	}
+	return v
+}
+
+// closureAssigned reports whether v may be assigned while the function
+// being checked runs without assigning it itself: by a function literal
+// nested in the function declaring v or, if v is captured from an enclosing
+// function, by that function after declaring v. Variables are matched by
+// name, which is conservative where names are shadowed.
+func (c *Checker) closureAssigned(v *Var) bool {
+	if within(v.parent, c.fn.scope) {
+		return assignsVar(c.fn.body, v, true)
+	}
+	for fn := c.fn.outer; fn != nil; fn = fn.outer {
+		if within(v.parent, fn.scope) {
+			return assignsVar(fn.body, v, false)
+		}
+	}
+	return true
+}
+
+// within reports whether s is the scope outer or nested in it.
+func within(s, outer *Scope) bool {
+	for ; s != nil; s = s.parent {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// assignsVar reports whether n assigns a variable named like v, other than
+// by declaring v, and if closures is set, only within function literals.
+func assignsVar(n ast.Node, v *Var, closures bool) bool {
+	found := false
+	match := func(lhs ...ast.Expr) {
+		for _, e := range lhs {
+			if ident, ok := unparen(e).(*ast.Ident); ok && ident.Name == v.name && ident.Pos() != v.pos {
+				found = true
+			}
+		}
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			if closures {
+				found = assignsVar(n, v, false)
+				return false
+			}
+		case *ast.AssignStmt:
+			if !closures {
+				match(n.Lhs...)
+			}
+		case *ast.IncDecStmt:
+			if !closures {
+				match(n.X)
+			}
+		case *ast.RangeStmt:
+			if !closures && n.Tok != lexer.SHORT_VAR {
+				match(n.Key, n.Value)
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// narrow narrows the variables of facts until the returned function is
+// called. Variables assigned to in the meantime are no longer narrowed
+// afterwards.
+func (c *Checker) narrow(facts narrowing) (restore func()) {
+	if len(facts) == 0 {
+		return func() {}
+	}
+	type saved struct {
+		typ     Type
+		assigns int
+	}
+	old := make(map[*Var]saved, len(facts))
+	for v, t := range facts {
+		old[v] = saved{c.fn.narrowed[v], c.fn.assigns[v]}
+		c.fn.narrowed[v] = t
+	}
+	return func() {
+		for v, s := range old {
+			if s.typ == nil || c.fn.assigns[v] != s.assigns {
+				delete(c.fn.narrowed, v)
+			} else {
+				c.fn.narrowed[v] = s.typ
+			}
+		}
+	}
+}
+
+// assigned ends the narrowing of the variables among lhs, which are now
+// definitely assigned.
+func (c *Checker) assigned(lhs ...ast.Expr) {
+	for _, e := range lhs {
+		if v := c.localVar(e); v != nil {
+			c.unnarrow(v)
+			delete(c.fn.unassigned, v)
+		}
+	}
+}
+
+func (c *Checker) unnarrow(v *Var) {
+	delete(c.fn.narrowed, v)
+	c.fn.assigns[v]++
+}
+
+// assignedIn ends the narrowing of the variables assigned to anywhere in n,
+// such as the body of a loop, which may run after the assignment.
+func (c *Checker) assignedIn(n ast.Node) {
+	unnarrow := func(lhs ...ast.Expr) {
+		for _, e := range lhs {
+			if v := c.localVar(e); v != nil {
+				c.unnarrow(v)
+			}
+		}
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			unnarrow(n.Lhs...)
+		case *ast.RangeStmt:
+			if n.Tok != lexer.SHORT_VAR {
+				unnarrow(n.Key, n.Value)
+			}
+		}
+		return true
+	})
+}
+
+// ifStmt checks an if statement and returns the variables narrowed after
+// it: those its condition proves non-nil when one branch leaves the
+// enclosing block and the other does not.
+func (c *Checker) ifStmt(s *ast.IfStmt) narrowing {
+	c.openScope(s, "if")
+	defer c.closeScope()
+	if s.Init != nil {
+		c.stmt(s.Init)
+	}
+	c.cond(s.Cond, "if statement")
+	if c.fn == nil {
+		c.stmt(s.Body)
+		if s.Else != nil {
+			c.stmt(s.Else)
+		}
+		return nil
+	}
+
+	then, els := c.nilChecks(s.Cond, true), c.nilChecks(s.Cond, false)
+	assigns := make(map[*Var]int)
+	for _, facts := range []narrowing{then, els} {
+		for v := range facts {
+			assigns[v] = c.fn.assigns[v]
+		}
+	}
+	b := c.branches()
+	restore := c.narrow(then)
+	b.begin()
+	c.stmt(s.Body)
+	b.end(!leaves(s.Body))
+	restore()
+	if s.Else != nil {
+		restore = c.narrow(els)
+		b.begin()
+		c.stmt(s.Else)
+		b.end(!leaves(s.Else))
+		restore()
+	} else {
+		b.skip()
+	}
+	b.join()
+
+	var after narrowing
+	switch {
+	case leaves(s.Body) && (s.Else == nil || !leaves(s.Else)):
+		after = els
+	case s.Else != nil && leaves(s.Else) && !leaves(s.Body):
+		after = then
+	}
+	for v := range after {
+		if c.fn.assigns[v] != assigns[v] {
+			// assigned in the branch that does not leave
+			delete(after, v)
+		}
+	}
+	return after
+}
+
+// leaves reports whether s ends the execution of the enclosing block, by
+// terminating the function or by a break or continue statement.
+func leaves(s ast.Stmt) bool {
+	if isTerminating(s) {
+		return true
+	}
+	if b, ok := s.(*ast.BlockStmt); ok {
+		for i := len(b.List) - 1; i >= 0; i-- {
+			if _, empty := b.List[i].(*ast.EmptyStmt); !empty {
+				return leaves(b.List[i])
+			}
+		}
+		return false
+	}
+	_, ok := s.(*ast.BranchStmt)
+	return ok
+}
+
+// nonNil reports whether x, which is used by the expression e, is not of
+// an optional type, reporting an error and invalidating x if it is.
+func (c *Checker) nonNil(x *operand, e ast.Expr) bool {
+	if _, ok := x.typ.Underlying().(*Optional); !ok {
+		return true
+	}
+	c.errorf(x.expr.Pos(), "invalid operation: %s (%s may be nil)", ast.ExprString(e), ast.ExprString(x.expr))
+	x.mode = invalid
+	return false
+}
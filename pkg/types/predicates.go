@@ -0,0 +1,264 @@
+package types
+
+// isBasic reports whether t has a basic underlying type of one of the given
+// kinds. A type parameter does if all types of its type set do.
+func isBasic(t Type, kinds ...BasicKind) bool {
+	if tp, ok := t.(*TypeParam); ok {
+		return allTerms(tp, func(t Type) bool { return isBasic(t, kinds...) })
+	}
+	b, ok := t.Underlying().(*Basic)
+	if !ok {
+		return false
+	}
+	for _, k := range kinds {
+		if b.kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumeric(t Type) bool { return isBasic(t, Int, Int64, Float, UntypedInt, UntypedFloat) }
+func isInteger(t Type) bool { return isBasic(t, Int, Int64, UntypedInt) }
+func isString(t Type) bool  { return isBasic(t, String, UntypedString) }
+func isBoolean(t Type) bool { return isBasic(t, Bool, UntypedBool) }
+func isUntypedNil(t Type) bool {
+	b, ok := t.(*Basic)
+	return ok && b.kind == UntypedNil
+}
+
+// isUntyped reports whether t is the type of an untyped constant or of nil.
+func isUntyped(t Type) bool {
+	b, ok := t.(*Basic)
+	return ok && b.kind >= UntypedBool
+}
+
+// defaultType returns the type an untyped constant takes where the context
+// does not determine one, and t itself for other types.
+func defaultType(t Type) Type {
+	if b, ok := t.(*Basic); ok {
+		switch b.kind {
+		case UntypedBool:
+			return Typ[Bool]
+		case UntypedInt:
+			return Typ[Int]
+		case UntypedFloat:
+			return Typ[Float]
+		case UntypedString:
+			return Typ[String]
+		case UntypedSymbol:
+			return Typ[Symbol]
+		}
+	}
+	return t
+}
+
+func isInterface(t Type) bool {
+	_, ok := t.Underlying().(*Interface)
+	return ok
+}
+
+// isValid reports whether t is a valid type. A named type whose declaration
+// is being checked is valid.
+func isValid(t Type) bool {
+	if n, ok := t.(*Named); ok && n.resolve() == nil {
+		return true
+	}
+	return t != nil && t.Underlying() != Typ[Invalid]
+}
+
+// hasName reports whether t is a predeclared or named type.
+func hasName(t Type) bool {
+	switch t.(type) {
+	case *Basic, *Named, *TypeParam:
+		return true
+	}
+	return false
+}
+
+// hasNil reports whether nil may be assigned to values of type t. Only
+// optional types have nil values.
+func hasNil(t Type) bool {
+	_, ok := t.Underlying().(*Optional)
+	return ok
+}
+
+// comparable reports whether values of type t can be compared with == and !=.
+// Values of a type parameter can be if its constraint is comparable or all
+// types of its type set are.
+func comparable(t Type) bool {
+	switch t := t.Underlying().(type) {
+	case *TypeParam:
+		return t.iface().typeSet().comparable || allTerms(t, comparable)
+	case *Basic:
+		return t.kind != UntypedNil
+	case *Array:
+		return comparable(t.elem)
+	case *Optional:
+		return comparable(t.elem)
+	case *Tuple:
+		for _, e := range t.elems {
+			if !comparable(e) {
+				return false
+			}
+		}
+		return true
+	case *Struct:
+		return fieldsComparable(t.fields)
+	case *Record:
+		return fieldsComparable(t.fields)
+	case *Interface, *Enum, *SymbolSet:
+		return true
+	}
+	return false
+}
+
+func fieldsComparable(fields []*Var) bool {
+	for _, f := range fields {
+		if !comparable(f.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// ordered reports whether values of type t can be compared with < and >.
+func ordered(t Type) bool {
+	return isNumeric(t) || isString(t)
+}
+
+// Identical reports whether x and y are the same type. Named types are
+// identical only to themselves; other types are identical when they have the
+// same structure.
+func Identical(x, y Type) bool {
+	if x == y {
+		return true
+	}
+	switch x := x.(type) {
+	case *Array:
+		y, ok := y.(*Array)
+		return ok && x.len == y.len && Identical(x.elem, y.elem)
+	case *Slice:
+		y, ok := y.(*Slice)
+		return ok && Identical(x.elem, y.elem)
+	case *Map:
+		y, ok := y.(*Map)
+		return ok && Identical(x.key, y.key) && Identical(x.elem, y.elem)
+	case *Optional:
+		y, ok := y.(*Optional)
+		return ok && Identical(x.elem, y.elem)
+	case *Tuple:
+		y, ok := y.(*Tuple)
+		return ok && identicalTypes(x.elems, y.elems)
+	case *Struct:
+		y, ok := y.(*Struct)
+		return ok && identicalFields(x.fields, y.fields)
+	case *Record:
+		y, ok := y.(*Record)
+		return ok && identicalFields(x.fields, y.fields)
+	case *Signature:
+		y, ok := y.(*Signature)
+		return ok && identicalVars(x.params, y.params) && identicalVars(x.results, y.results)
+	case *SymbolSet:
+		y, ok := y.(*SymbolSet)
+		return ok && x.Len() == y.Len() && x.includes(y)
+	case *Interface:
+		y, ok := y.(*Interface)
+		if !ok || x.NumMethods() != y.NumMethods() {
+			return false
+		}
+		for i := 0; i < x.NumMethods(); i++ {
+			mx, my := x.Method(i), y.Method(i)
+			if mx.name != my.name || !Identical(mx.typ, my.typ) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func identicalTypes(x, y []Type) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !Identical(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func identicalVars(x, y []*Var) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !Identical(x[i].typ, y[i].typ) {
+			return false
+		}
+	}
+	return true
+}
+
+func identicalFields(x, y []*Var) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].name != y[i].name || x[i].embedded != y[i].embedded || !Identical(x[i].typ, y[i].typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasZero reports whether type t has a zero value, which variables and
+// fields of the type take when given none.
+func HasZero(t Type) bool { return hasZero(t) }
+
+// AssignableTo reports whether a value of type v can be assigned to a
+// variable of type t.
+func AssignableTo(v, t Type) bool {
+	if !isValid(v) || !isValid(t) || Identical(v, t) {
+		return true
+	}
+	if isUntypedNil(v) {
+		return hasNil(t)
+	}
+	if isUntyped(v) {
+		return untypedAssignable(v.(*Basic), t)
+	}
+
+	// values of identical underlying types are assignable when at least one
+	// of the types has no name; records are always compared structurally
+	vu, tu := v.Underlying(), t.Underlying()
+	if Identical(vu, tu) {
+		_, isRecord := tu.(*Record)
+		if !hasName(v) || !hasName(t) || isRecord {
+			return true
+		}
+	}
+
+	if iface, ok := tu.(*Interface); ok {
+		m, _ := missingMethod(v, iface)
+		return m == nil
+	}
+
+	// the values of a symbol set are symbols, and values of larger sets
+	if vs, ok := vu.(*SymbolSet); ok {
+		if ts, ok := tu.(*SymbolSet); ok {
+			return ts.includes(vs) && (!hasName(v) || !hasName(t))
+		}
+		return isBasic(tu, Symbol)
+	}
+
+	// a value that is not optional may be used where one is expected
+	if opt, ok := tu.(*Optional); ok {
+		if _, ok := vu.(*Optional); !ok {
+			return AssignableTo(v, opt.elem)
+		}
+	}
+	return false
+}
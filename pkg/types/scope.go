@@ -0,0 +1,67 @@
+package types
+
+import (
+	"sort"
+)
+
+// Scope maps names to the objects declared in a package, file, function or
+// block.
+type Scope struct {
+	parent   *Scope
+	children []*Scope
+	elems    map[string]Object
+	comment  string // for debugging
+}
+
+// NewScope returns a scope nested in parent, which may be nil.
+func NewScope(parent *Scope, comment string) *Scope {
+	s := &Scope{parent: parent, comment: comment}
+	if parent != nil && parent != Universe {
+		parent.children = append(parent.children, s)
+	}
+	return s
+}
+
+func (s *Scope) Parent() *Scope { return s.parent }
+
+// Names returns the names declared in s, sorted.
+func (s *Scope) Names() []string {
+	names := make([]string, 0, len(s.elems))
+	for name := range s.elems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the object declared in s with the given name, or nil.
+func (s *Scope) Lookup(name string) Object {
+	return s.elems[name]
+}
+
+// LookupParent returns the innermost scope enclosing s, starting with s, that
+// declares name, and the object it declares.
+func (s *Scope) LookupParent(name string) (*Scope, Object) {
+	for ; s != nil; s = s.parent {
+		if obj := s.elems[name]; obj != nil {
+			return s, obj
+		}
+	}
+	return nil, nil
+}
+
+// Insert declares obj in s unless s already declares an object with the same
+// name, which is then returned instead.
+func (s *Scope) Insert(obj Object) Object {
+	if alt := s.elems[obj.Name()]; alt != nil {
+		return alt
+	}
+	if s.elems == nil {
+		s.elems = make(map[string]Object)
+	}
+	s.elems[obj.Name()] = obj
+	if obj.Parent() == nil {
+		obj.setParent(s)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package types
+
+// SelectionKind describes what a selector expression x.f denotes.
+type SelectionKind int
+
+const (
+	FieldVal   SelectionKind = iota // x.f is a field
+	MethodVal                       // x.f is a method bound to the value x
+	MethodExpr                      // x.f is a method of the type x, taking the receiver as first argument
+)
+
+// Selection describes a selector expression x.f other than the variants of
+// enums. Backends use it to keep the receiver of a method value, as in
+// btn.onClick = comp.Handle, and to pass it to a method expression, as in
+// Comp.Handle(comp).
+type Selection struct {
+	kind  SelectionKind
+	recv  Type
+	obj   Object
+	index []int
+}
+
+// Kind returns the kind of the selection.
+func (s *Selection) Kind() SelectionKind { return s.kind }
+
+// Recv returns the type of x in x.f.
+func (s *Selection) Recv() Type { return s.recv }
+
+// Obj returns the field or method selected.
+func (s *Selection) Obj() Object { return s.obj }
+
+// Index returns the sequence of field indices leading to f, ending with the
+// index of f itself, as returned by LookupFieldOrMethod.
+func (s *Selection) Index() []int { return s.index }
+
+// Indirect reports whether f is promoted from an embedded field.
+func (s *Selection) Indirect() bool { return len(s.index) > 1 }
+
+// Type returns the type of x.f: the type of the field, the signature of the
+// method without its receiver, or for a method expression the signature
+// taking the receiver as first parameter.
+func (s *Selection) Type() Type {
+	switch s.kind {
+	case MethodVal:
+		sig := s.obj.(*Func).Signature()
+		return &Signature{params: sig.params, results: sig.results, optional: sig.optional}
+	case MethodExpr:
+		sig := s.obj.(*Func).Signature()
+		recv := NewVar(s.obj.Pos(), "", s.recv)
+		if sig.recv != nil {
+			recv = NewVar(sig.recv.pos, sig.recv.name, s.recv)
+		}
+		params := append([]*Var{recv}, sig.params...)
+		return &Signature{params: params, results: sig.results, optional: sig.optional}
+	}
+	return s.obj.Type()
+}
@@ -0,0 +1,687 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+func (c *Checker) stmtList(list []ast.Stmt) {
+	c.unreachable(list)
+	var restore []func()
+	for _, s := range list {
+		if s, ok := s.(*ast.IfStmt); ok {
+			// an if statement leaving the block narrows the rest of it
+			restore = append(restore, c.narrow(c.ifStmt(s)))
+			continue
+		}
+		c.stmt(s)
+	}
+	for i := len(restore) - 1; i >= 0; i-- {
+		restore[i]()
+	}
+}
+
+func (c *Checker) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.BadStmt, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.DeclStmt:
+		c.declStmt(s.Decl)
+
+	case *ast.ExprStmt:
+		c.exprStmt(s)
+
+	case *ast.AssignStmt:
+		switch s.Tok {
+		case lexer.SHORT_VAR:
+			c.shortVarDecl(s)
+		case lexer.ASSIGN:
+			c.assignVars(s.Lhs, s.Rhs)
+		default:
+			c.opAssign(s)
+		}
+
+	case *ast.IncDecStmt:
+		var x operand
+		c.target(s.X, true, func() { c.expr(&x, s.X) })
+		if x.mode == invalid {
+			return
+		}
+		if !isNumeric(x.typ) {
+			c.errorf(s.TokPos, "invalid operation: %s%s (non-numeric type %s)", ast.ExprString(s.X), lexer.Sequence(s.Tok), x.typ)
+			return
+		}
+		if !c.erasedArith(&x, s.Tok, s.TokPos) {
+			return
+		}
+		c.lhsVar(s.X)
+
+	case *ast.ReturnStmt:
+		c.returnStmt(s)
+
+	case *ast.BranchStmt:
+		switch {
+		case s.Tok == lexer.BREAK && c.fn.breakable == 0:
+			c.errorf(s.Pos(), "break is not in a loop or switch")
+		case s.Tok == lexer.CONTINUE && c.fn.loops == 0:
+			c.errorf(s.Pos(), "continue is not in a loop")
+		}
+
+	case *ast.BlockStmt:
+		c.openScope(s, "block")
+		c.stmtList(s.List)
+		c.closeScope()
+
+	case *ast.IfStmt:
+		c.ifStmt(s)
+
+	case *ast.SwitchStmt:
+		c.switchStmt(s)
+
+	case *ast.ForStmt:
+		c.openScope(s, "for")
+		if s.Init != nil {
+			c.stmt(s.Init)
+		}
+		c.assignedIn(s)
+		if s.Cond != nil {
+			c.cond(s.Cond, "for loop")
+		}
+		if s.Post != nil {
+			c.stmt(s.Post)
+		}
+		if s.Cond != nil && c.fn != nil {
+			restore := c.narrow(c.nilChecks(s.Cond, true))
+			c.loopBody(s.Body)
+			restore()
+		} else {
+			c.loopBody(s.Body)
+		}
+		c.closeScope()
+
+	case *ast.RangeStmt:
+		c.rangeStmt(s)
+	}
+}
+
+// loopBody checks the body of a loop. The variables it assigns are not
+// definitely assigned after the loop, which may not iterate; vars are
+// assigned by each iteration.
+func (c *Checker) loopBody(body *ast.BlockStmt, vars ...ast.Expr) {
+	b := c.branches()
+	b.begin()
+	c.assigned(vars...)
+	c.fn.loops++
+	c.fn.breakable++
+	c.stmt(body)
+	c.fn.loops--
+	c.fn.breakable--
+	b.skip()
+	b.join()
+}
+
+// cond checks the condition of a control statement.
+func (c *Checker) cond(e ast.Expr, context string) {
+	var x operand
+	c.expr(&x, e)
+	if x.mode != invalid && !isBoolean(x.typ) {
+		c.errorf(e.Pos(), "non-boolean condition in %s", context)
+	}
+}
+
+func (c *Checker) exprStmt(s *ast.ExprStmt) {
+	var x operand
+	if m, ok := s.X.(*ast.MatchExpr); ok {
+		// a match statement, whose arms need not have values
+		c.matchExpr(&x, m, nil, true)
+		x.expr = m
+		c.record(&x)
+		return
+	}
+
+	c.rawExpr(&x, s.X, nil)
+	switch x.mode {
+	case invalid, novalue:
+	case builtin:
+		c.errorf(s.X.Pos(), "%s must be called", &x)
+	case typexpr:
+		c.errorf(s.X.Pos(), "%s is not an expression", &x)
+	default:
+		if !isCall(s.X) {
+			c.errorf(s.X.Pos(), "%s is not used", ast.ExprString(s.X))
+		} else if x.mustUse {
+			c.reportf(s.X.Pos(), UnusedResult, "result of %s is not used", ast.ExprString(s.X))
+		}
+	}
+}
+
+func (c *Checker) declStmt(decl ast.Decl) {
+	d, ok := decl.(*ast.GenDecl)
+	if !ok {
+		return
+	}
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.ValueSpec:
+			objs := make([]Object, len(s.Names))
+			if d.Tok == lexer.CONST {
+				for i, name := range s.Names {
+					obj := NewConst(name.Pos(), name.Name, nil, nil)
+					c.constDecl(obj, s, i)
+					objs[i] = obj
+				}
+			} else {
+				vars := make([]*Var, len(s.Names))
+				for i, name := range s.Names {
+					vars[i] = NewVar(name.Pos(), name.Name, nil)
+					objs[i] = vars[i]
+				}
+				if len(s.Names) > 1 && len(s.Values) == 1 {
+					c.varDeclTuple(vars, s)
+				} else {
+					for i, v := range vars {
+						c.varDecl(v, s, i)
+					}
+				}
+				for _, v := range vars {
+					c.local(v)
+				}
+				if len(s.Values) == 0 {
+					c.unassigned(vars)
+				}
+			}
+			// the scope of the names starts after the spec
+			for i, name := range s.Names {
+				c.declare(c.scope, name, objs[i])
+			}
+
+		case *ast.TypeSpec:
+			// the scope of a type name starts at the name, so that the type
+			// may refer to itself
+			obj := NewTypeName(s.Name.Pos(), s.Name.Name, nil)
+			c.declare(c.scope, s.Name, obj)
+			c.typeDecl(obj, s)
+		}
+	}
+}
+
+// lhsVar checks the left-hand side of an assignment and returns its type,
+// or nil for the blank identifier.
+func (c *Checker) lhsVar(e ast.Expr) Type {
+	if ident, ok := e.(*ast.Ident); ok && ident.Name == "_" {
+		return nil
+	}
+
+	// the fields of records are immutable, and so is everything reached
+	// through them, at any depth
+	var path []ast.Expr // e and the operands it selects or indexes from
+	for x := e; x != nil; {
+		path = append(path, x)
+		switch y := unparen(x).(type) {
+		case *ast.SelectorExpr:
+			x = y.X
+		case *ast.IndexExpr:
+			x = y.X
+		case *ast.TupleIndexExpr:
+			x = y.X
+		default:
+			x = nil
+		}
+	}
+	for i := len(path) - 1; i > 0; i-- {
+		var base operand
+		c.rawExpr(&base, path[i], nil)
+		if base.mode == invalid {
+			return Typ[Invalid]
+		}
+		if base.mode == typexpr {
+			break
+		}
+		if _, ok := base.typ.Underlying().(*Record); ok {
+			pos := path[i-1].Pos()
+			if sel, ok := unparen(path[i-1]).(*ast.SelectorExpr); ok {
+				pos = sel.Sel.Pos()
+			}
+			c.errorf(pos, "cannot assign to %s (fields of record type %s are immutable)", ast.ExprString(e), base.typ)
+			return Typ[Invalid]
+		}
+	}
+
+	switch lhs := e.(type) {
+	case *ast.TupleIndexExpr:
+		var base operand
+		c.expr(&base, lhs.X)
+		if base.mode == invalid {
+			return Typ[Invalid]
+		}
+		if _, ok := base.typ.Underlying().(*Tuple); ok {
+			c.errorf(lhs.IndexPos, "cannot assign to %s (tuple elements are immutable)", ast.ExprString(e))
+			return Typ[Invalid]
+		}
+	}
+
+	var x operand
+	c.target(e, false, func() { c.expr(&x, e) })
+	switch x.mode {
+	case invalid:
+		return Typ[Invalid]
+	case variable, mapindex:
+		if v := c.localVar(e); v != nil {
+			// the declared type rather than a narrowed one
+			return v.typ
+		}
+		return x.typ
+	}
+	c.errorf(e.Pos(), "cannot assign to %s", &x)
+	return Typ[Invalid]
+}
+
+func (c *Checker) assignVars(lhs, rhs []ast.Expr) {
+	switch {
+	case len(lhs) == len(rhs):
+		for i := range lhs {
+			typ := c.lhsVar(lhs[i])
+			var x operand
+			c.exprWithHint(&x, rhs[i], typ)
+			if typ != nil {
+				c.assignment(&x, typ, "assignment")
+			} else if x.isNil() {
+				c.errorf(x.expr.Pos(), "use of untyped nil in assignment")
+			}
+		}
+		c.assigned(lhs...)
+
+	case len(rhs) == 1:
+		vars := make([]*Var, len(lhs))
+		for i, e := range lhs {
+			if typ := c.lhsVar(e); typ != nil {
+				vars[i] = NewVar(e.Pos(), "", typ)
+			}
+		}
+		var x operand
+		c.expr(&x, rhs[0])
+		c.destructure(vars, &x, "assignment")
+		c.assigned(lhs...)
+
+	default:
+		c.errorf(rhs[0].Pos(), "assignment mismatch: %d variables but %d values", len(lhs), len(rhs))
+		c.use(lhs...)
+		c.use(rhs...)
+	}
+}
+
+var opAssignOps = map[lexer.Token]lexer.Token{
+	lexer.ASSIGN_ADD:       lexer.ADD,
+	lexer.ASSIGN_SUB:       lexer.SUB,
+	lexer.ASSIGN_MULT:      lexer.MULT,
+	lexer.ASSIGN_DIV:       lexer.DIV,
+	lexer.ASSIGN_MOD:       lexer.MOD,
+	lexer.ASSIGN_BIT_AND:   lexer.BIT_AND,
+	lexer.ASSIGN_BIT_OR:    lexer.BIT_OR,
+	lexer.ASSIGN_BIT_NOT:   lexer.BIT_NOT,
+	lexer.ASSIGN_BIT_LEFT:  lexer.BIT_LEFT,
+	lexer.ASSIGN_BIT_RIGHT: lexer.BIT_RIGHT,
+	lexer.ASSIGN_BIT_CLEAR: lexer.BIT_CLEAR,
+}
+
+// opAssign checks an operator assignment such as x += y as the assignment
+// of x + y to x.
+func (c *Checker) opAssign(s *ast.AssignStmt) {
+	if len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+		return // reported by the parser
+	}
+	var x operand
+	c.target(s.Lhs[0], true, func() {
+		c.binary(&x, &ast.BinaryExpr{X: s.Lhs[0], OpPos: s.TokPos, Op: opAssignOps[s.Tok], Y: s.Rhs[0]})
+	})
+	if x.mode == invalid {
+		return
+	}
+	if typ := c.lhsVar(s.Lhs[0]); typ != nil {
+		x.expr = s.Rhs[0]
+		c.assignment(&x, typ, "assignment")
+	}
+	c.assigned(s.Lhs[0])
+}
+
+func (c *Checker) shortVarDecl(s *ast.AssignStmt) {
+	vars := make([]*Var, len(s.Lhs))
+	var newVars []*Var
+	var newIdents []*ast.Ident
+	seen := make(map[string]bool)
+	valid := true
+
+	for i, e := range s.Lhs {
+		ident, ok := e.(*ast.Ident)
+		if !ok {
+			c.errorf(e.Pos(), "non-name %s on left side of :=", ast.ExprString(e))
+			c.use(e)
+			valid = false
+			continue
+		}
+		if ident.Name != "_" {
+			if seen[ident.Name] {
+				c.errorf(ident.Pos(), "%s repeated on left side of :=", ident.Name)
+				valid = false
+				continue
+			}
+			seen[ident.Name] = true
+		}
+
+		if alt := c.scope.Lookup(ident.Name); alt != nil {
+			c.recordUse(ident, alt)
+			if v, ok := alt.(*Var); ok {
+				vars[i] = v
+			} else {
+				c.errorf(ident.Pos(), "cannot assign to %s", ident.Name)
+				valid = false
+			}
+			continue
+		}
+
+		v := NewVar(ident.Pos(), ident.Name, nil)
+		vars[i] = v
+		if ident.Name != "_" {
+			newVars = append(newVars, v)
+			newIdents = append(newIdents, ident)
+		} else {
+			c.recordDef(ident, v)
+		}
+	}
+
+	switch {
+	case len(s.Lhs) == len(s.Rhs):
+		for i, e := range s.Rhs {
+			var hint Type
+			if vars[i] != nil {
+				hint = vars[i].typ
+			}
+			var x operand
+			c.exprWithHint(&x, e, hint)
+			if vars[i] != nil {
+				c.initVar(vars[i], &x, "assignment")
+			}
+		}
+
+	case len(s.Rhs) == 1:
+		var x operand
+		c.expr(&x, s.Rhs[0])
+		c.destructure(vars, &x, "assignment")
+
+	default:
+		c.errorf(s.Rhs[0].Pos(), "assignment mismatch: %d variables but %d values", len(s.Lhs), len(s.Rhs))
+		c.use(s.Rhs...)
+		for _, v := range newVars {
+			v.typ = Typ[Invalid]
+		}
+	}
+
+	// the scope of the new variables starts after the statement
+	c.assigned(s.Lhs...)
+	for i, v := range newVars {
+		c.declare(c.scope, newIdents[i], v)
+		c.local(v)
+	}
+	if len(newVars) == 0 && valid {
+		c.errorf(s.TokPos, "no new variables on left side of :=")
+	}
+}
+
+// inferResults sets the results of sig, an arrow function with a block
+// body, to the types of the values returned by s.
+func (c *Checker) inferResults(sig *Signature, s *ast.ReturnStmt) {
+	for _, e := range s.Results {
+		var x operand
+		c.expr(&x, e)
+		c.assignment(&x, nil, "return statement")
+		switch {
+		case x.mode == invalid:
+			x.typ = Typ[Invalid]
+		case x.isNil():
+			c.errorf(e.Pos(), "use of untyped nil in return statement")
+			x.typ = Typ[Invalid]
+		}
+		sig.results = append(sig.results, NewVar(e.Pos(), "", x.typ))
+	}
+}
+
+func (c *Checker) returnStmt(s *ast.ReturnStmt) {
+	sig := c.fn.sig
+	if c.fn.inferResults {
+		c.fn.inferResults = false
+		c.inferResults(sig, s)
+		return
+	}
+	results := sig.results
+
+	switch {
+	case len(s.Results) == 0:
+		if len(results) > 0 && results[0].name == "" {
+			c.errorf(s.Pos(), "not enough return values: have 0, want %d", len(results))
+		}
+
+	case len(results) == 0:
+		c.use(s.Results...)
+		c.errorf(s.Results[0].Pos(), "too many return values: have %d, want 0", len(s.Results))
+
+	case len(s.Results) == len(results):
+		for i, e := range s.Results {
+			var x operand
+			c.exprWithHint(&x, e, results[i].typ)
+			c.assignment(&x, results[i].typ, "return statement")
+		}
+
+	case len(s.Results) == 1:
+		// a single tuple value returned by a function with several results
+		want := sig.Result()
+		var x operand
+		c.exprWithHint(&x, s.Results[0], want)
+		if x.mode == invalid {
+			return
+		}
+		if t, ok := x.typ.Underlying().(*Tuple); !ok || t.Len() != len(results) {
+			c.errorf(s.Results[0].Pos(), "not enough return values: have 1, want %d", len(results))
+			return
+		}
+		c.assignment(&x, want, "return statement")
+
+	default:
+		c.use(s.Results...)
+		if len(s.Results) < len(results) {
+			c.errorf(s.Results[0].Pos(), "not enough return values: have %d, want %d", len(s.Results), len(results))
+		} else {
+			c.errorf(s.Results[len(results)].Pos(), "too many return values: have %d, want %d", len(s.Results), len(results))
+		}
+	}
+}
+
+func (c *Checker) switchStmt(s *ast.SwitchStmt) {
+	c.openScope(s, "switch")
+	defer c.closeScope()
+
+	if s.Init != nil {
+		c.stmt(s.Init)
+	}
+	var tag operand
+	if s.Tag != nil {
+		c.expr(&tag, s.Tag)
+		c.assignment(&tag, nil, "switch expression")
+		if tag.mode != invalid && !comparable(tag.typ) {
+			c.errorf(s.Tag.Pos(), "cannot switch on %s (%s cannot be compared)", &tag, tag.typ)
+			tag.mode = invalid
+		}
+	}
+
+	seen := make(map[string]ast.Expr)
+	b := c.branches()
+	hasDefault := false
+	c.fn.breakable++
+	for _, clause := range s.Body {
+		hasDefault = hasDefault || clause.List == nil
+		for _, e := range clause.List {
+			var x operand
+			if s.Tag == nil {
+				c.expr(&x, e)
+				if x.mode != invalid && !isBoolean(x.typ) {
+					c.errorf(e.Pos(), "invalid case %s in switch (mismatched types %s and bool)", ast.ExprString(e), x.typ)
+				}
+				continue
+			}
+			c.exprWithHint(&x, e, tag.typ)
+			if x.mode == invalid || tag.mode == invalid || !c.matchTypes(&x, &tag) {
+				continue
+			}
+			if !AssignableTo(x.typ, tag.typ) && !AssignableTo(tag.typ, x.typ) {
+				c.errorf(e.Pos(), "invalid case %s in switch on %s (mismatched types %s and %s)", ast.ExprString(e), ast.ExprString(s.Tag), x.typ, tag.typ)
+				continue
+			}
+			if x.mode == constant_ {
+				key := constKey(x.val)
+				if _, dup := seen[key]; dup {
+					c.errorf(e.Pos(), "duplicate case %s in switch", ast.ExprString(e))
+				}
+				seen[key] = e
+			}
+		}
+		c.openScope(clause, "case")
+		b.begin()
+		c.stmt(clause.Body)
+		b.end(!isTerminating(clause.Body))
+		c.closeScope()
+	}
+	c.fn.breakable--
+	if !hasDefault || hasBreak(s) {
+		// no clause runs, or one is left early by a break
+		b.skip()
+	}
+	b.join()
+}
+
+func (c *Checker) rangeStmt(s *ast.RangeStmt) {
+	c.openScope(s, "range")
+	defer c.closeScope()
+
+	var x operand
+	c.expr(&x, s.X)
+	c.assignment(&x, nil, "range clause")
+	c.assignedIn(s)
+
+	types := [2]Type{Typ[Invalid], Typ[Invalid]}
+	if x.mode != invalid {
+		key, val, ok := rangeTypes(x.typ)
+		switch {
+		case !ok:
+			c.errorf(x.expr.Pos(), "cannot range over %s", &x)
+		case val == nil && s.Value != nil:
+			c.errorf(s.Value.Pos(), "range over %s permits only one iteration variable", &x)
+			types[0] = key
+		default:
+			types[0], types[1] = key, val
+		}
+	}
+
+	lhs := [2]ast.Expr{s.Key, s.Value}
+	if s.Tok == lexer.SHORT_VAR {
+		var vars []*Var
+		var idents []*ast.Ident
+		for i, e := range lhs {
+			if e == nil {
+				continue
+			}
+			ident, ok := e.(*ast.Ident)
+			if !ok {
+				c.errorf(e.Pos(), "non-name %s on left side of :=", ast.ExprString(e))
+				continue
+			}
+			vars = append(vars, NewVar(ident.Pos(), ident.Name, types[i]))
+			idents = append(idents, ident)
+		}
+		for i, v := range vars {
+			c.declare(c.scope, idents[i], v)
+			c.local(v)
+		}
+	} else {
+		for i, e := range lhs {
+			if e == nil {
+				continue
+			}
+			typ := c.lhsVar(e)
+			if typ != nil && !AssignableTo(types[i], typ) {
+				c.errorf(e.Pos(), "cannot assign %s to %s (type %s) in range", types[i], ast.ExprString(e), typ)
+			}
+		}
+		c.loopBody(s.Body, s.Key, s.Value)
+		return
+	}
+
+	c.loopBody(s.Body)
+}
+
+// isTerminating reports whether s ends the execution of the enclosing
+// function, so that no return statement needs to follow it.
+func isTerminating(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStmt:
+		return true
+
+	case *ast.ExprStmt:
+		switch x := s.X.(type) {
+		case *ast.CallExpr:
+			ident, ok := x.Fun.(*ast.Ident)
+			return ok && ident.Name == "panic"
+		case *ast.MatchExpr:
+			// matches are exhaustive, so one whose arms all terminate does
+			for _, arm := range x.Arms {
+				if !isTerminating(arm.Body) {
+					return false
+				}
+			}
+			return len(x.Arms) > 0
+		}
+
+	case *ast.BlockStmt:
+		for i := len(s.List) - 1; i >= 0; i-- {
+			if _, empty := s.List[i].(*ast.EmptyStmt); !empty {
+				return isTerminating(s.List[i])
+			}
+		}
+
+	case *ast.IfStmt:
+		return s.Else != nil && isTerminating(s.Body) && isTerminating(s.Else)
+
+	case *ast.ForStmt:
+		return s.Cond == nil && !hasBreak(s.Body)
+
+	case *ast.SwitchStmt:
+		hasDefault := false
+		for _, clause := range s.Body {
+			if clause.List == nil {
+				hasDefault = true
+			}
+			if !isTerminating(clause.Body) || hasBreak(clause.Body) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
+
+// hasBreak reports whether s contains a break statement that is not nested
+// in another loop, switch or function.
+func hasBreak(s ast.Stmt) bool {
+	found := false
+	ast.Inspect(s, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			if n.Tok == lexer.BREAK {
+				found = true
+			}
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.FuncLit:
+			return n == s
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,283 @@
+package types
+
+// substMap maps type parameters to the types substituted for them.
+type substMap map[*TypeParam]Type
+
+func makeSubstMap(tparams []*TypeParam, targs []Type) substMap {
+	m := make(substMap, len(tparams))
+	for i, tp := range tparams {
+		if i < len(targs) && targs[i] != nil {
+			m[tp] = targs[i]
+		}
+	}
+	return m
+}
+
+// subst returns typ with the type parameters in m replaced by their types.
+// Types that do not refer to those type parameters are returned unchanged.
+func subst(typ Type, m substMap) Type {
+	if len(m) == 0 || typ == nil {
+		return typ
+	}
+	switch t := typ.(type) {
+	case *TypeParam:
+		if r := m[t]; r != nil {
+			return r
+		}
+
+	case *Array:
+		if elem := subst(t.elem, m); elem != t.elem {
+			return NewArray(elem, t.len)
+		}
+
+	case *Slice:
+		if elem := subst(t.elem, m); elem != t.elem {
+			return NewSlice(elem)
+		}
+
+	case *Optional:
+		if elem := subst(t.elem, m); elem != t.elem {
+			return NewOptional(elem)
+		}
+
+	case *Map:
+		key, elem := subst(t.key, m), subst(t.elem, m)
+		if key != t.key || elem != t.elem {
+			return NewMap(key, elem)
+		}
+
+	case *Tuple:
+		if elems, changed := substTypes(t.elems, m); changed {
+			return NewTuple(elems...)
+		}
+
+	case *Struct:
+		if fields, changed := substVars(t.fields, m); changed {
+			return NewStruct(fields)
+		}
+
+	case *Record:
+		if fields, changed := substVars(t.fields, m); changed {
+			return NewRecord(fields)
+		}
+
+	case *Signature:
+		params, pchanged := substVars(t.params, m)
+		results, rchanged := substVars(t.results, m)
+		if pchanged || rchanged {
+			return &Signature{recv: t.recv, tparams: t.tparams, rtparams: t.rtparams, params: params, results: results, optional: t.optional}
+		}
+
+	case *Interface:
+		return substInterface(t, m)
+
+	case *Union:
+		var terms []*Term
+		changed := false
+		for _, term := range t.terms {
+			typ := subst(term.typ, m)
+			changed = changed || typ != term.typ
+			terms = append(terms, NewTerm(term.tilde, typ))
+		}
+		if changed {
+			return NewUnion(terms)
+		}
+
+	case *Enum:
+		return substEnum(t, m)
+
+	case *Named:
+		if t.targs != nil {
+			if targs, changed := substTypes(t.targs, m); changed {
+				return instance(t.orig, targs)
+			}
+		}
+	}
+	return typ
+}
+
+func substTypes(list []Type, m substMap) ([]Type, bool) {
+	out := make([]Type, len(list))
+	changed := false
+	for i, t := range list {
+		out[i] = subst(t, m)
+		changed = changed || out[i] != t
+	}
+	return out, changed
+}
+
+func substVars(list []*Var, m substMap) ([]*Var, bool) {
+	if list == nil {
+		return nil, false
+	}
+	out := make([]*Var, len(list))
+	changed := false
+	for i, v := range list {
+		typ := subst(v.typ, m)
+		if typ == v.typ {
+			out[i] = v
+			continue
+		}
+		changed = true
+		nv := *v
+		nv.typ = typ
+		out[i] = &nv
+	}
+	return out, changed
+}
+
+func substInterface(t *Interface, m substMap) Type {
+	changed := false
+	methods := make([]*Func, len(t.methods))
+	for i, f := range t.methods {
+		methods[i] = f
+		if typ := subst(f.typ, m); typ != f.typ {
+			methods[i] = NewFunc(f.pos, f.name, typ.(*Signature))
+			changed = true
+		}
+	}
+	embeddeds, echanged := substTypes(t.embeddeds, m)
+	if !changed && !echanged {
+		return t
+	}
+	iface := NewInterface(methods, embeddeds)
+	iface.comparable = t.comparable
+	iface.implicit = t.implicit
+	return iface
+}
+
+func substEnum(t *Enum, m substMap) Type {
+	changed := false
+	variants := make([]*Variant, len(t.variants))
+	for i, v := range t.variants {
+		variants[i] = v
+		if payload, pchanged := substVars(v.payload, m); pchanged {
+			variants[i] = &Variant{name: v.name, index: v.index, payload: payload}
+			changed = true
+		}
+	}
+	if !changed {
+		return t
+	}
+	return &Enum{backing: t.backing, variants: variants}
+}
+
+// instance returns the instance of the generic type orig with the given
+// type arguments. Instances with identical type arguments are the same type.
+func instance(orig *Named, targs []Type) *Named {
+	for _, inst := range orig.instances {
+		if identicalTypes(inst.targs, targs) {
+			return inst
+		}
+	}
+	inst := &Named{obj: orig.obj, orig: orig, targs: targs}
+	orig.instances = append(orig.instances, inst)
+	return inst
+}
+
+// resolve returns the underlying type of t, or nil if the declaration of t
+// has not been checked yet. The underlying type of an instance is computed
+// from that of its generic type on first use.
+func (t *Named) resolve() Type {
+	if t.underlying == nil && t.orig != nil && t.orig.underlying != nil {
+		u := subst(t.orig.underlying, makeSubstMap(t.orig.tparams, t.targs))
+		t.underlying = u.Underlying()
+	}
+	return t.underlying
+}
+
+// methodList returns the methods of t. The methods of an instance are those
+// of its generic type with the type arguments substituted for the receiver
+// type parameters; methods whose signature has not been checked yet are
+// returned as declared.
+func (t *Named) methodList() []*Func {
+	if t.orig == nil {
+		return t.methods
+	}
+	orig := t.orig.methods
+	for len(t.methods) < len(orig) {
+		m := orig[len(t.methods)]
+		sig := m.Signature()
+		if sig == nil {
+			return append(t.methods[:len(t.methods):len(t.methods)], orig[len(t.methods):]...)
+		}
+		smap := makeSubstMap(sig.rtparams, t.targs)
+		inst := subst(sig, smap).(*Signature)
+		if inst == sig {
+			copied := *sig
+			inst = &copied
+		}
+		if sig.recv != nil {
+			recv := *sig.recv
+			recv.typ = t
+			inst.recv = &recv
+		}
+		inst.rtparams = nil
+		fn := NewFunc(m.pos, m.name, inst)
+		fn.mustUse = m.mustUse
+		fn.extern = m.extern
+		t.methods = append(t.methods, fn)
+	}
+	return t.methods
+}
+
+// instantiate returns the signature of the generic function sig with the
+// given type arguments substituted for its type parameters.
+func instantiate(sig *Signature, targs []Type) *Signature {
+	inst := subst(sig, makeSubstMap(sig.tparams, targs)).(*Signature)
+	if inst == sig {
+		copied := *sig
+		inst = &copied
+	}
+	inst.tparams = nil
+	return inst
+}
+
+// mentions reports whether typ refers to any of the type parameters.
+func mentions(typ Type, tparams []*TypeParam) bool {
+	switch t := typ.(type) {
+	case *TypeParam:
+		for _, tp := range tparams {
+			if tp == t {
+				return true
+			}
+		}
+	case *Array:
+		return mentions(t.elem, tparams)
+	case *Slice:
+		return mentions(t.elem, tparams)
+	case *Optional:
+		return mentions(t.elem, tparams)
+	case *Map:
+		return mentions(t.key, tparams) || mentions(t.elem, tparams)
+	case *Tuple:
+		return mentionsAny(t.elems, tparams)
+	case *Struct:
+		return mentionsVars(t.fields, tparams)
+	case *Record:
+		return mentionsVars(t.fields, tparams)
+	case *Signature:
+		return mentionsVars(t.params, tparams) || mentionsVars(t.results, tparams)
+	case *Named:
+		return mentionsAny(t.targs, tparams)
+	}
+	return false
+}
+
+func mentionsAny(list []Type, tparams []*TypeParam) bool {
+	for _, t := range list {
+		if mentions(t, tparams) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentionsVars(list []*Var, tparams []*TypeParam) bool {
+	for _, v := range list {
+		if mentions(v.typ, tparams) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"go/constant"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// A symbol is an interned name such as :ok. Symbols are equal when their
+// names are; they are comparable and may be map keys, but are not ordered
+// and have no zero value. Symbol literals are untyped constants whose value
+// is the name without the colon, and take the type symbol unless the
+// context expects a symbol set. string(s) is the name of the symbol s and
+// symbol(name) the symbol with the given name.
+//
+// A symbol set type such as :ok | :err holds the symbols it lists. Its
+// values may be used where a symbol is expected, and where a set including
+// all of its symbols is expected if one of the two types has no name. Only
+// constants convert to a symbol set.
+//
+// Compiled code represents a symbol by the string holding its name, not by
+// a JavaScript Symbol: comparing symbols compares strings, which engines
+// intern, symbol keys are string keys, and a symbol serializes to JSON as
+// its name.
+
+// SymbolSet is a symbol set type.
+type SymbolSet struct {
+	names []string // in the order written, without duplicates
+}
+
+func NewSymbolSet(names []string) *SymbolSet { return &SymbolSet{names: names} }
+func (s *SymbolSet) Len() int                { return len(s.names) }
+func (s *SymbolSet) Name(i int) string       { return s.names[i] }
+func (s *SymbolSet) Underlying() Type        { return s }
+
+func (s *SymbolSet) String() string {
+	parts := make([]string, len(s.names))
+	for i, name := range s.names {
+		parts[i] = ":" + name
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Contains reports whether name is one of the symbols of s.
+func (s *SymbolSet) Contains(name string) bool {
+	for _, n := range s.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether all symbols of t are symbols of s.
+func (s *SymbolSet) includes(t *SymbolSet) bool {
+	for _, name := range t.names {
+		if !s.Contains(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSymbol reports whether t is symbol or a symbol set, or their untyped
+// kind.
+func isSymbol(t Type) bool {
+	if _, ok := t.Underlying().(*SymbolSet); ok {
+		return true
+	}
+	return isBasic(t, Symbol, UntypedSymbol)
+}
+
+// symbolVal returns the constant value of the symbol literal lit.
+func symbolVal(lit string) constant.Value {
+	return constant.MakeString(strings.TrimPrefix(lit, ":"))
+}
+
+// isSymbolType reports whether e is a symbol set type: a symbol literal or
+// a union of them.
+func isSymbolType(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return e.Kind == lexer.SYMBOL
+	case *ast.BinaryExpr:
+		return e.Op == lexer.BIT_OR && isSymbolType(e.X) && isSymbolType(e.Y)
+	}
+	return false
+}
+
+// symbolSetType returns the symbol set type e, for which isSymbolType
+// holds.
+func (c *Checker) symbolSetType(e ast.Expr) Type {
+	var names []string
+	var collect func(e ast.Expr)
+	collect = func(e ast.Expr) {
+		if b, ok := e.(*ast.BinaryExpr); ok {
+			collect(b.X)
+			collect(b.Y)
+			return
+		}
+		lit := e.(*ast.BasicLit)
+		name := constant.StringVal(symbolVal(lit.Value))
+		for _, n := range names {
+			if n == name {
+				c.errorf(lit.Pos(), "duplicate symbol %s in symbol set", lit.Value)
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	collect(e)
+	return NewSymbolSet(names)
+}
@@ -0,0 +1,436 @@
+package types
+
+import (
+	"go/constant"
+	"strconv"
+	"strings"
+)
+
+// Type is implemented by all types.
+type Type interface {
+	// Underlying returns the underlying type of a named type and the type
+	// itself otherwise.
+	Underlying() Type
+	String() string
+}
+
+// BasicKind describes the kind of a basic type.
+//
+// The numeric types are defined so that compiled code computes the same
+// results as the checker folds and JavaScript can represent: an int is a
+// 32-bit signed integer, an int64 a 64-bit signed integer held in a BigInt,
+// and a float a 64-bit IEEE 754 number. Integer arithmetic wraps around on
+// overflow, and integer division and remainder truncate towards zero.
+type BasicKind int
+
+const (
+	Invalid BasicKind = iota
+	Bool
+	Int
+	Int64
+	Float
+	String
+	Symbol
+
+	// the types of untyped constants and of nil
+	UntypedBool
+	UntypedInt
+	UntypedFloat
+	UntypedString
+	UntypedSymbol
+	UntypedNil
+)
+
+// Basic is a predeclared type such as int, or the type of an untyped
+// constant or of nil.
+type Basic struct {
+	kind BasicKind
+	name string
+}
+
+func (b *Basic) Kind() BasicKind { return b.kind }
+func (b *Basic) Name() string    { return b.name }
+
+// Typ holds the basic types by kind.
+var Typ = [...]*Basic{
+	Invalid: {Invalid, "invalid type"},
+	Bool:    {Bool, "bool"},
+	Int:     {Int, "int"},
+	Int64:   {Int64, "int64"},
+	Float:   {Float, "float"},
+	String:  {String, "string"},
+	Symbol:  {Symbol, "symbol"},
+
+	UntypedBool:   {UntypedBool, "untyped bool"},
+	UntypedInt:    {UntypedInt, "untyped int"},
+	UntypedFloat:  {UntypedFloat, "untyped float"},
+	UntypedString: {UntypedString, "untyped string"},
+	UntypedSymbol: {UntypedSymbol, "untyped symbol"},
+	UntypedNil:    {UntypedNil, "untyped nil"},
+}
+
+// Array is a fixed-length array type.
+type Array struct {
+	len  int64
+	elem Type
+}
+
+func NewArray(elem Type, len int64) *Array { return &Array{len: len, elem: elem} }
+func (a *Array) Len() int64                { return a.len }
+func (a *Array) Elem() Type                { return a.elem }
+
+type Slice struct {
+	elem Type
+}
+
+func NewSlice(elem Type) *Slice { return &Slice{elem: elem} }
+func (s *Slice) Elem() Type     { return s.elem }
+
+type Map struct {
+	key, elem Type
+}
+
+func NewMap(key, elem Type) *Map { return &Map{key: key, elem: elem} }
+func (m *Map) Key() Type         { return m.key }
+func (m *Map) Elem() Type        { return m.elem }
+
+// Optional is a `?T` type, whose values are those of T or nil. Values of
+// other types are never nil.
+type Optional struct {
+	elem Type
+}
+
+func NewOptional(elem Type) *Optional { return &Optional{elem: elem} }
+func (o *Optional) Elem() Type        { return o.elem }
+
+// Tuple is an ordered list of element types. It is the type of tuple
+// literals and tuple(...) types, and of calls to functions with several
+// results.
+type Tuple struct {
+	elems []Type
+}
+
+func NewTuple(elems ...Type) *Tuple { return &Tuple{elems: elems} }
+func (t *Tuple) Len() int           { return len(t.elems) }
+func (t *Tuple) At(i int) Type      { return t.elems[i] }
+
+// Struct is a struct type. Embedded fields are marked as such on their Var.
+type Struct struct {
+	fields []*Var
+}
+
+func NewStruct(fields []*Var) *Struct { return &Struct{fields: fields} }
+func (s *Struct) NumFields() int      { return len(s.fields) }
+func (s *Struct) Field(i int) *Var    { return s.fields[i] }
+
+// Record is an immutable struct-like type. Records are typed structurally:
+// values of record types with the same fields are assignable to each other
+// even when the types are named.
+type Record struct {
+	fields []*Var
+}
+
+func NewRecord(fields []*Var) *Record { return &Record{fields: fields} }
+func (r *Record) NumFields() int      { return len(r.fields) }
+func (r *Record) Field(i int) *Var    { return r.fields[i] }
+
+// Interface is an interface type. The any type is the empty interface.
+// Interfaces embedding unions or comparable restrict their type set and may
+// only be used as constraints.
+type Interface struct {
+	methods    []*Func // explicitly declared methods
+	embeddeds  []Type  // embedded interfaces and unions
+	all        []*Func // declared and embedded methods, sorted by name
+	complete   bool
+	any        bool
+	comparable bool // only comparable types are in the type set
+	implicit   bool // the interface of a constraint written as a union
+}
+
+func NewInterface(methods []*Func, embeddeds []Type) *Interface {
+	return &Interface{methods: methods, embeddeds: embeddeds}
+}
+
+// NumMethods returns the number of methods of t including embedded ones.
+func (t *Interface) NumMethods() int { return len(t.allMethods()) }
+
+// Method returns the i'th method of t, ordered by name.
+func (t *Interface) Method(i int) *Func { return t.allMethods()[i] }
+
+// Empty reports whether t has no methods.
+func (t *Interface) Empty() bool { return t.NumMethods() == 0 }
+
+func (t *Interface) allMethods() []*Func {
+	if t.complete {
+		return t.all
+	}
+	t.complete = true
+	seen := make(map[string]bool)
+	add := func(m *Func) {
+		if !seen[m.name] {
+			seen[m.name] = true
+			t.all = append(t.all, m)
+		}
+	}
+	for _, m := range t.methods {
+		add(m)
+	}
+	for _, e := range t.embeddeds {
+		if iface, ok := e.Underlying().(*Interface); ok {
+			for _, m := range iface.allMethods() {
+				add(m)
+			}
+		}
+	}
+	sortFuncs(t.all)
+	return t.all
+}
+
+// Signature is the type of a function or method. Results are named only when
+// the declaration names them. The signature of a generic function has type
+// parameters, and that of a method of a generic type has the receiver type
+// parameters declared by its receiver.
+type Signature struct {
+	recv     *Var
+	tparams  []*TypeParam
+	rtparams []*TypeParam
+	params   []*Var
+	results  []*Var
+	optional int // number of trailing parameters calls may omit
+}
+
+func NewSignature(recv *Var, params, results []*Var) *Signature {
+	return &Signature{recv: recv, params: params, results: results}
+}
+
+func (s *Signature) Recv() *Var                   { return s.recv }
+func (s *Signature) TypeParams() []*TypeParam     { return s.tparams }
+func (s *Signature) RecvTypeParams() []*TypeParam { return s.rtparams }
+func (s *Signature) Params() []*Var               { return s.params }
+func (s *Signature) Results() []*Var              { return s.results }
+
+// Optional returns the number of trailing parameters that calls of an
+// extern function may omit.
+func (s *Signature) Optional() int { return s.optional }
+
+// Result returns the type of a call to a function of type s: nil for no
+// results, the result type for one, and a Tuple for several.
+func (s *Signature) Result() Type {
+	switch len(s.results) {
+	case 0:
+		return nil
+	case 1:
+		return s.results[0].typ
+	}
+	elems := make([]Type, len(s.results))
+	for i, r := range s.results {
+		elems[i] = r.typ
+	}
+	return NewTuple(elems...)
+}
+
+// Enum is an enum type. Backing is empty for enums whose variants are plain
+// tags or carry payloads.
+type Enum struct {
+	backing  []Type
+	variants []*Variant
+}
+
+func (e *Enum) Backing() []Type        { return e.backing }
+func (e *Enum) NumVariants() int       { return len(e.variants) }
+func (e *Enum) Variant(i int) *Variant { return e.variants[i] }
+
+// Lookup returns the variant with the given name, or nil.
+func (e *Enum) Lookup(name string) *Variant {
+	for _, v := range e.variants {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Variant is a variant of an enum type.
+type Variant struct {
+	name    string
+	index   int
+	payload []*Var
+	value   []constant.Value
+}
+
+func (v *Variant) Name() string    { return v.name }
+func (v *Variant) Index() int      { return v.index }
+func (v *Variant) Payload() []*Var { return v.payload }
+
+// Value returns the value of a variant of a backed enum, one constant for
+// each backing type, or nil for other enums. A variant without a value has
+// the value following that of the previous variant for integer backing
+// types, starting at zero, and its name for string backing types. The
+// constants of values that are not constant are nil.
+func (v *Variant) Value() []constant.Value { return v.value }
+
+// Named is a type declared by a type declaration. A generic named type has
+// type parameters; its instantiations, such as List[int], are named types
+// with type arguments whose underlying type and methods are those of the
+// generic type with the type arguments substituted for the parameters.
+type Named struct {
+	obj        *TypeName
+	underlying Type
+	methods    []*Func
+
+	tparams   []*TypeParam
+	orig      *Named   // generic type of an instance
+	targs     []Type   // type arguments of an instance
+	instances []*Named // instances of a generic type
+
+	extern bool // values are JavaScript objects or values
+}
+
+// NewNamed returns a named type for obj, which is also set as the type of
+// obj. The underlying type may be set later with SetUnderlying.
+func NewNamed(obj *TypeName, underlying Type, methods []*Func) *Named {
+	t := &Named{obj: obj, underlying: underlying, methods: methods}
+	if obj.typ == nil {
+		obj.typ = t
+	}
+	return t
+}
+
+func (t *Named) Obj() *TypeName           { return t.obj }
+func (t *Named) NumMethods() int          { return len(t.methodList()) }
+func (t *Named) Method(i int) *Func       { return t.methodList()[i] }
+func (t *Named) SetUnderlying(typ Type)   { t.underlying = typ.Underlying() }
+func (t *Named) AddMethod(m *Func)        { t.methods = append(t.methods, m) }
+func (t *Named) TypeParams() []*TypeParam { return t.tparams }
+func (t *Named) TypeArgs() []Type         { return t.targs }
+
+// Extern reports whether t is declared with the extern attribute.
+func (t *Named) Extern() bool { return t.Origin().extern }
+
+// Origin returns the generic type of an instance and t itself otherwise.
+func (t *Named) Origin() *Named {
+	if t.orig != nil {
+		return t.orig
+	}
+	return t
+}
+
+func (t *Basic) Underlying() Type     { return t }
+func (t *Array) Underlying() Type     { return t }
+func (t *Slice) Underlying() Type     { return t }
+func (t *Map) Underlying() Type       { return t }
+func (t *Optional) Underlying() Type  { return t }
+func (t *Tuple) Underlying() Type     { return t }
+func (t *Struct) Underlying() Type    { return t }
+func (t *Record) Underlying() Type    { return t }
+func (t *Interface) Underlying() Type { return t }
+func (t *Signature) Underlying() Type { return t }
+func (t *Enum) Underlying() Type      { return t }
+func (t *Named) Underlying() Type {
+	if t.resolve() == nil {
+		return Typ[Invalid]
+	}
+	return t.underlying
+}
+
+func (t *Basic) String() string     { return t.name }
+func (t *Array) String() string     { return "[" + strconv.FormatInt(t.len, 10) + "]" + t.elem.String() }
+func (t *Slice) String() string     { return "[]" + t.elem.String() }
+func (t *Map) String() string       { return "map[" + t.key.String() + "]" + t.elem.String() }
+func (t *Optional) String() string  { return "?" + t.elem.String() }
+func (t *Tuple) String() string     { return "tuple(" + typeList(t.elems) + ")" }
+func (t *Struct) String() string    { return "struct{" + fieldList(t.fields) + "}" }
+func (t *Record) String() string    { return "record{" + fieldList(t.fields) + "}" }
+func (t *Signature) String() string { return "func" + signatureString(t) }
+func (t *TypeParam) String() string { return t.obj.name }
+
+func (t *Named) String() string {
+	if t.targs != nil {
+		return t.obj.name + "[" + typeList(t.targs) + "]"
+	}
+	return t.obj.name
+}
+
+func (t *Interface) String() string {
+	switch {
+	case t.any:
+		return "any"
+	case t.implicit:
+		return t.embeddeds[0].String()
+	}
+	var elems []string
+	if t.comparable {
+		elems = append(elems, "comparable")
+	}
+	for _, e := range t.embeddeds {
+		if u, ok := e.(*Union); ok {
+			elems = append(elems, u.String())
+		}
+	}
+	for _, m := range t.allMethods() {
+		elems = append(elems, m.name+signatureString(m.typ.(*Signature)))
+	}
+	return "interface{" + strings.Join(elems, "; ") + "}"
+}
+
+func (t *Enum) String() string {
+	var b strings.Builder
+	b.WriteString("enum")
+	if len(t.backing) > 0 {
+		b.WriteString("(" + typeList(t.backing) + ")")
+	}
+	b.WriteByte('{')
+	for i, v := range t.variants {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(v.name)
+		if v.payload != nil {
+			b.WriteString("(" + varList(v.payload) + ")")
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func typeList(list []Type) string {
+	parts := make([]string, len(list))
+	for i, t := range list {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func varList(list []*Var) string {
+	parts := make([]string, len(list))
+	for i, v := range list {
+		parts[i] = v.typ.String()
+		if v.name != "" {
+			parts[i] = v.name + " " + parts[i]
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func fieldList(fields []*Var) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.typ.String()
+		if !f.embedded {
+			parts[i] = f.name + " " + parts[i]
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func signatureString(s *Signature) string {
+	str := "(" + varList(s.params) + ")"
+	switch {
+	case len(s.results) == 0:
+	case len(s.results) == 1 && s.results[0].name == "":
+		str += " " + s.results[0].typ.String()
+	default:
+		str += " (" + varList(s.results) + ")"
+	}
+	return str
+}
@@ -0,0 +1,340 @@
+package types
+
+import (
+	"strings"
+)
+
+// TypeParam is a type parameter of a generic function or type. Its
+// constraint is an interface, possibly named, whose type set holds the types
+// that may be used as type arguments.
+type TypeParam struct {
+	obj   *TypeName
+	index int
+	bound Type
+
+	optional bool         // used as the element type of an optional type
+	feeds    []*TypeParam // type parameters given this one as type argument
+}
+
+// NewTypeParam returns a type parameter for obj, which is also set as the
+// type of obj. The constraint may be set later with SetConstraint.
+func NewTypeParam(obj *TypeName, index int, constraint Type) *TypeParam {
+	t := &TypeParam{obj: obj, index: index, bound: constraint}
+	if obj.typ == nil {
+		obj.typ = t
+	}
+	return t
+}
+
+func (t *TypeParam) Obj() *TypeName           { return t.obj }
+func (t *TypeParam) Index() int               { return t.index }
+func (t *TypeParam) Constraint() Type         { return t.bound }
+func (t *TypeParam) SetConstraint(bound Type) { t.bound = bound }
+
+// Underlying returns t itself: the operations permitted on values of a type
+// parameter are those permitted on all types of its type set.
+func (t *TypeParam) Underlying() Type { return t }
+
+// Terms returns the terms of the type set of t, which are nil unless its
+// constraint restricts the types it holds.
+func (t *TypeParam) Terms() []*Term { return typeParamTerms(t) }
+
+// iface returns the interface of the constraint of t, which is the empty
+// interface until the constraint has been checked.
+func (t *TypeParam) iface() *Interface {
+	if t.bound != nil {
+		if iface, ok := t.bound.Underlying().(*Interface); ok {
+			return iface
+		}
+	}
+	return anyType
+}
+
+// formsOptional reports whether a type argument for t becomes the element
+// type of an optional type, either in ?T directly or through the type
+// parameters t is passed on to. An optional type argument would then form
+// a nested optional, whose nil could not be told apart from that of its
+// element.
+func (t *TypeParam) formsOptional() bool {
+	return t.formsOptionalSeen(make(map[*TypeParam]bool))
+}
+
+func (t *TypeParam) formsOptionalSeen(seen map[*TypeParam]bool) bool {
+	if t.optional {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	for _, u := range t.feeds {
+		if u.formsOptionalSeen(seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// Term is a term of a union. A term with Tilde set stands for all types
+// whose underlying type is its type.
+type Term struct {
+	tilde bool
+	typ   Type
+}
+
+func NewTerm(tilde bool, typ Type) *Term { return &Term{tilde: tilde, typ: typ} }
+func (t *Term) Tilde() bool              { return t.tilde }
+func (t *Term) Type() Type               { return t.typ }
+
+func (t *Term) String() string {
+	if t.tilde {
+		return "~" + t.typ.String()
+	}
+	return t.typ.String()
+}
+
+// includes reports whether the type T is in the set of types of t.
+func (t *Term) includes(T Type) bool {
+	if t.tilde {
+		return Identical(T.Underlying(), t.typ)
+	}
+	return Identical(T, t.typ)
+}
+
+// Union is a union of terms such as ~int | float. Unions only occur as
+// elements of constraint interfaces.
+type Union struct {
+	terms []*Term
+}
+
+func NewUnion(terms []*Term) *Union { return &Union{terms: terms} }
+func (u *Union) Len() int           { return len(u.terms) }
+func (u *Union) Term(i int) *Term   { return u.terms[i] }
+func (u *Union) Underlying() Type   { return u }
+
+func (u *Union) String() string {
+	parts := make([]string, len(u.terms))
+	for i, t := range u.terms {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// typeSet describes the types implementing an interface beyond its methods.
+// terms is nil when the set is not restricted by unions.
+type typeSet struct {
+	terms      []*Term
+	comparable bool
+}
+
+// typeSet returns the type set of t, intersecting the terms of the unions
+// and interfaces it embeds.
+func (t *Interface) typeSet() typeSet {
+	ts := typeSet{comparable: t.comparable}
+	for _, e := range t.embeddeds {
+		var terms []*Term
+		switch e := e.Underlying().(type) {
+		case *Union:
+			terms = e.terms
+		case *Interface:
+			ets := e.typeSet()
+			ts.comparable = ts.comparable || ets.comparable
+			terms = ets.terms
+		}
+		if terms != nil {
+			ts.terms = intersectTerms(ts.terms, terms)
+		}
+	}
+	return ts
+}
+
+// Terms returns the terms of the type set of t, which are nil unless the
+// unions it embeds restrict the types it holds.
+func (t *Interface) Terms() []*Term { return t.typeSet().terms }
+
+// IsMethodSet reports whether t is described by its methods alone, so that
+// it may be used as the type of values.
+func (t *Interface) IsMethodSet() bool {
+	ts := t.typeSet()
+	return ts.terms == nil && !ts.comparable
+}
+
+// intersectTerms returns the terms of the types in both x and y; x is nil
+// for the set of all types.
+func intersectTerms(x, y []*Term) []*Term {
+	if x == nil {
+		return y
+	}
+	out := []*Term{}
+	for _, a := range x {
+		for _, b := range y {
+			switch {
+			case a.tilde && b.tilde:
+				if Identical(a.typ, b.typ) {
+					out = append(out, a)
+				}
+			case a.tilde:
+				if a.includes(b.typ) {
+					out = append(out, b)
+				}
+			case b.includes(a.typ):
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+// typeParamTerms returns the terms of the type set of t, or nil if it is a
+// type parameter whose constraint does not restrict its type set by terms.
+func typeParamTerms(t Type) []*Term {
+	if tp, ok := t.(*TypeParam); ok {
+		return tp.iface().typeSet().terms
+	}
+	return nil
+}
+
+// Generic code is compiled once for all the type arguments of its type
+// parameters, so it cannot depend on how JavaScript represents them: ints
+// are numbers kept within 32 bits, int64s BigInts and floats numbers.
+
+// mixedBasics returns two basic types of the type set of the type
+// parameter t whose arithmetic differs in JavaScript, or nils if there are
+// none. If constants is set, only numeric types whose constants differ
+// are returned: int64, whose constants are BigInts, and the others.
+func mixedBasics(t Type, constants bool) (*Basic, *Basic) {
+	var first *Basic
+	for _, term := range typeParamTerms(t) {
+		b, ok := term.typ.Underlying().(*Basic)
+		if !ok {
+			continue
+		}
+		switch {
+		case first == nil:
+			first = b
+		case constants && isNumeric(first) && isNumeric(b) && (first.kind == Int64) != (b.kind == Int64):
+			return first, b
+		case !constants && first.kind != b.kind:
+			return first, b
+		}
+	}
+	return nil, nil
+}
+
+// sameZero reports whether the types of the type set of the type
+// parameter t share one zero value in JavaScript: 0 for ints and floats,
+// and otherwise that of their identical underlying types.
+func sameZero(t *TypeParam) bool {
+	zero := func(t Type) Type {
+		u := t.Underlying()
+		switch u.(type) {
+		case *Basic:
+			if isBasic(u, Int, Float) {
+				return Typ[Float]
+			}
+		case *Struct, *Record, *Array, *Tuple:
+			// values of distinct named types have distinct classes
+			return t
+		}
+		return u
+	}
+	var first Type
+	for _, term := range typeParamTerms(t) {
+		z := zero(term.typ)
+		if first != nil && !Identical(first, z) {
+			return false
+		}
+		first = z
+	}
+	return true
+}
+
+// allTerms reports whether the type parameter t has a restricted type set
+// whose types all satisfy f.
+func allTerms(t *TypeParam, f func(Type) bool) bool {
+	terms := typeParamTerms(t)
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		if !f(term.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// CoreType returns the underlying type of t, or for a type parameter the
+// underlying type shared by all types of its type set, if any.
+func CoreType(t Type) Type { return coreType(t) }
+
+// coreType returns the underlying type of t. For a type parameter it is the
+// underlying type shared by all types of its type set, or nil if there is
+// none.
+func coreType(t Type) Type {
+	tp, ok := t.(*TypeParam)
+	if !ok {
+		return t.Underlying()
+	}
+	var core Type
+	for _, term := range typeParamTerms(tp) {
+		u := term.typ.Underlying()
+		if core != nil && !Identical(core, u) {
+			return nil
+		}
+		core = u
+	}
+	return core
+}
+
+// satisfies reports whether the type argument T satisfies the constraint
+// bound. If it does not, the returned cause explains why.
+func satisfies(T Type, bound *Interface) (ok bool, cause string) {
+	if !isValid(T) {
+		return true, ""
+	}
+	ts := bound.typeSet()
+	if ts.comparable && !comparable(T) {
+		return false, ""
+	}
+	if ts.terms != nil {
+		if tp, ok := T.(*TypeParam); ok {
+			// every type of T's type set must be in bound's
+			terms := typeParamTerms(tp)
+			if terms == nil {
+				return false, tp.String() + " missing in " + termsString(ts.terms)
+			}
+			for _, term := range terms {
+				if !termsInclude(ts.terms, term) {
+					return false, tp.String() + " missing in " + termsString(ts.terms)
+				}
+			}
+		} else if !termsInclude(ts.terms, &Term{typ: T}) {
+			return false, T.String() + " missing in " + termsString(ts.terms)
+		}
+	}
+	if reason := notImplemented(T, bound); reason != "" {
+		return false, reason
+	}
+	return true, ""
+}
+
+// termsInclude reports whether all types of the term t are in terms.
+func termsInclude(terms []*Term, t *Term) bool {
+	for _, u := range terms {
+		if t.tilde {
+			if u.tilde && Identical(u.typ, t.typ) {
+				return true
+			}
+			continue
+		}
+		if u.includes(t.typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func termsString(terms []*Term) string {
+	return NewUnion(terms).String()
+}
@@ -0,0 +1,431 @@
+package types
+
+import (
+	"go/constant"
+	"go/token"
+	"slices"
+	"strings"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// typExpr returns the type denoted by e, reporting an error and returning the
+// invalid type if e is not a type. Interfaces restricting their type set may
+// only be used as constraints.
+func (c *Checker) typExpr(e ast.Expr) Type {
+	typ := c.definedType(e, nil)
+	if iface, ok := typ.Underlying().(*Interface); ok && !iface.IsMethodSet() {
+		c.errorf(e.Pos(), "cannot use type %s outside a type constraint: interface contains type constraints", typ)
+		return Typ[Invalid]
+	}
+	return typ
+}
+
+// definedType is like typExpr; def is the named type being declared with e
+// as its underlying type, if any.
+func (c *Checker) definedType(e ast.Expr, def *Named) Type {
+	typ := c.typExprInternal(e, def)
+	if c.info.Types != nil {
+		c.info.Types[e] = TypeAndValue{mode: typexpr, Type: typ}
+	}
+	return typ
+}
+
+func (c *Checker) typExprInternal(e ast.Expr, def *Named) Type {
+	switch e := e.(type) {
+	case *ast.BadExpr:
+		return Typ[Invalid]
+
+	case *ast.Ident, *ast.SelectorExpr:
+		var x operand
+		c.rawExpr(&x, e, nil)
+		switch x.mode {
+		case typexpr:
+			if n, ok := x.typ.(*Named); ok && n.tparams != nil && n.targs == nil {
+				c.errorf(e.Pos(), "cannot use generic type %s without instantiation", n)
+				return Typ[Invalid]
+			}
+			return x.typ
+		case invalid:
+		default:
+			c.errorf(e.Pos(), "%s is not a type", ast.ExprString(e))
+		}
+		return Typ[Invalid]
+
+	case *ast.IndexExpr:
+		var x operand
+		c.rawExpr(&x, e.X, nil)
+		switch x.mode {
+		case typexpr:
+			return c.instantiatedType(e, x.typ)
+		case invalid:
+			c.use(e.Indices...)
+		default:
+			c.errorf(e.Pos(), "%s is not a type", ast.ExprString(e))
+		}
+		return Typ[Invalid]
+
+	case *ast.ParenExpr:
+		return c.definedType(e.X, def)
+
+	case *ast.ArrayType:
+		elem := c.typExpr(e.Elt)
+		if e.Len == nil {
+			return NewSlice(elem)
+		}
+		return NewArray(elem, c.arrayLength(e.Len))
+
+	case *ast.OptionalType:
+		elem := c.typExpr(e.Elt)
+		if _, ok := elem.Underlying().(*Optional); ok {
+			c.errorf(e.Pos(), "invalid optional type %s (%s is already optional)", ast.ExprString(e), elem)
+			return Typ[Invalid]
+		}
+		if tp, ok := elem.(*TypeParam); ok {
+			tp.optional = true
+		}
+		return NewOptional(elem)
+
+	case *ast.MapType:
+		key := c.typExpr(e.Key)
+		elem := c.typExpr(e.Value)
+		if isValid(key) && !comparable(key) {
+			c.errorf(e.Key.Pos(), "invalid map key type %s", key)
+		}
+		return NewMap(key, elem)
+
+	case *ast.FuncType:
+		return c.funcType(e, nil, NewScope(c.scope, "function type"))
+
+	case *ast.StructType:
+		return NewStruct(c.fields(e.Fields))
+
+	case *ast.RecordType:
+		return NewRecord(c.fields(e.Fields))
+
+	case *ast.InterfaceType:
+		return c.interfaceType(e)
+
+	case *ast.TupleType:
+		elems := make([]Type, len(e.Elts))
+		for i, elt := range e.Elts {
+			elems[i] = c.typExpr(elt)
+		}
+		return NewTuple(elems...)
+
+	case *ast.EnumType:
+		return c.enumType(e)
+
+	case *ast.BasicLit, *ast.BinaryExpr:
+		if isSymbolType(e) {
+			return c.symbolSetType(e)
+		}
+	}
+
+	c.errorf(e.Pos(), "%s is not a type", ast.ExprString(e))
+	return Typ[Invalid]
+}
+
+// arrayLength returns the value of the constant length of an array type, or
+// -1 after reporting an error.
+func (c *Checker) arrayLength(e ast.Expr) int64 {
+	var x operand
+	c.expr(&x, e)
+	if x.mode == invalid {
+		return -1
+	}
+	if x.mode == constant_ && isInteger(x.typ) {
+		if n, ok := constant.Int64Val(x.val); ok && n >= 0 {
+			return n
+		}
+	}
+	c.errorf(e.Pos(), "array length %s must be a non-negative integer constant", ast.ExprString(e))
+	return -1
+}
+
+// funcType returns the signature of a function type, declaring its receiver,
+// parameters and results in scope.
+func (c *Checker) funcType(e *ast.FuncType, recv *Var, scope *Scope) *Signature {
+	params := c.params(e.Params, scope)
+	results := c.params(e.Results, scope)
+	return NewSignature(recv, params, results)
+}
+
+func (c *Checker) params(list *ast.FieldList, scope *Scope) []*Var {
+	if list == nil {
+		return nil
+	}
+	var vars []*Var
+	for _, field := range list.List {
+		var typ Type
+		if field.Type == nil {
+			// untyped arrow function parameters are inferred by funcLit
+			typ = Typ[Invalid]
+			for _, name := range field.Names {
+				c.errorf(name.Pos(), "missing type of parameter %s", name.Name)
+			}
+		} else {
+			typ = c.typExpr(field.Type)
+		}
+		if len(field.Names) == 0 {
+			vars = append(vars, NewVar(field.Type.Pos(), "", typ))
+			continue
+		}
+		for _, name := range field.Names {
+			v := NewVar(name.Pos(), name.Name, typ)
+			c.declare(scope, name, v)
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// fields returns the fields of a struct or record type.
+func (c *Checker) fields(list *ast.FieldList) []*Var {
+	var fields []*Var
+	seen := make(map[string]bool)
+	add := func(ident *ast.Ident, f *Var) {
+		if f.name != "_" && seen[f.name] {
+			c.errorf(f.pos, "%s redeclared", f.name)
+			return
+		}
+		seen[f.name] = true
+		if ident != nil {
+			c.recordDef(ident, f)
+		}
+		fields = append(fields, f)
+	}
+
+	for _, field := range list.List {
+		typ := c.typExpr(field.Type)
+		if len(field.Names) == 0 {
+			name := embeddedName(field.Type)
+			if _, ok := typ.(*Named); !ok && isValid(typ) {
+				c.errorf(field.Type.Pos(), "embedded field type %s must be a type name", typ)
+				typ = Typ[Invalid]
+			}
+			add(nil, NewField(field.Type.Pos(), name, typ, true))
+			continue
+		}
+		for _, name := range field.Names {
+			add(name, NewField(name.Pos(), name.Name, typ, false))
+		}
+	}
+	return fields
+}
+
+// embeddedName returns the field name of an embedded type, which is the name
+// of the type without any qualifier.
+func embeddedName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.ParenExpr:
+		return embeddedName(e.X)
+	}
+	return "_"
+}
+
+// interfaceType returns the type of an interface, whose method set is the
+// union of its declared methods and those of the interfaces it embeds.
+func (c *Checker) interfaceType(e *ast.InterfaceType) Type {
+	var methods []*Func
+	var embeddeds []Type
+	var embedPos []lexer.Position
+	seen := make(map[string]bool)
+	for _, field := range e.Methods.List {
+		if len(field.Names) == 0 {
+			if isUnion(field.Type) {
+				embeddeds = append(embeddeds, c.union(field.Type))
+				embedPos = append(embedPos, field.Type.Pos())
+				continue
+			}
+			typ := c.definedType(field.Type, nil)
+			if _, ok := typ.(*TypeParam); ok {
+				c.errorf(field.Type.Pos(), "cannot embed a type parameter")
+				continue
+			}
+			if n, ok := typ.(*Named); ok && n.resolve() == nil {
+				// an embedding cycle, reported by validType
+				embeddeds = append(embeddeds, typ)
+				embedPos = append(embedPos, field.Type.Pos())
+				continue
+			}
+			if isValid(typ) && !isInterface(typ) {
+				// a single type restricts the type set to that type
+				embeddeds = append(embeddeds, NewUnion([]*Term{NewTerm(false, typ)}))
+				embedPos = append(embedPos, field.Type.Pos())
+				continue
+			}
+			embeddeds = append(embeddeds, typ)
+			embedPos = append(embedPos, field.Type.Pos())
+			continue
+		}
+		name := field.Names[0]
+		ftype, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		sig := c.funcType(ftype, nil, NewScope(c.scope, "method "+name.Name))
+		m := NewFunc(name.Pos(), name.Name, sig)
+		c.recordDef(name, m)
+		if seen[name.Name] {
+			c.errorf(name.Pos(), "duplicate method %s", name.Name)
+			continue
+		}
+		seen[name.Name] = true
+		methods = append(methods, m)
+	}
+
+	iface := NewInterface(methods, embeddeds)
+	if len(embedPos) > 0 {
+		// embedded interfaces may still be being declared
+		c.later(func() {
+			c.embeddedMethods(iface, embedPos)
+		})
+	}
+	return iface
+}
+
+// embeddedMethods reports methods that an interface declares, or gets from
+// the interfaces it embeds, more than once with different signatures. pos
+// holds the positions of the elements of t.embeddeds.
+func (c *Checker) embeddedMethods(t *Interface, pos []lexer.Position) {
+	methods := make(map[string]*Func)
+	for _, m := range t.methods {
+		methods[m.name] = m
+	}
+	for i, e := range t.embeddeds {
+		iface, ok := e.Underlying().(*Interface)
+		if !ok {
+			continue
+		}
+		for _, m := range iface.allMethods() {
+			if prev := methods[m.name]; prev != nil && !Identical(prev.typ, m.typ) {
+				c.errorf(pos[i], "duplicate method %s", m.name)
+				continue
+			}
+			methods[m.name] = m
+		}
+	}
+}
+
+// enumType returns the type of an enum declaration. The values of backed
+// enum variants must be assignable to the backing types; payloads may refer
+// to the enum being declared.
+func (c *Checker) enumType(e *ast.EnumType) Type {
+	enum := &Enum{}
+	for _, b := range e.Backing {
+		enum.backing = append(enum.backing, c.typExpr(b))
+	}
+
+	next := constant.MakeInt64(0) // value of a variant of an integer enum without one
+	for i, ev := range e.Variants {
+		v := &Variant{name: ev.Name.Name, index: i}
+		if ev.Payload != nil {
+			v.payload = []*Var{}
+			for _, field := range ev.Payload.List {
+				typ := c.typExpr(field.Type)
+				if len(field.Names) == 0 {
+					v.payload = append(v.payload, NewVar(field.Type.Pos(), "", typ))
+					continue
+				}
+				for _, name := range field.Names {
+					p := NewVar(name.Pos(), name.Name, typ)
+					c.recordDef(name, p)
+					v.payload = append(v.payload, p)
+				}
+			}
+		}
+		switch {
+		case ev.Value != nil:
+			v.value = c.enumValue(enum, ev.Value)
+		case len(enum.backing) == 1 && isInteger(enum.backing[0]):
+			v.value = []constant.Value{next}
+		case len(enum.backing) == 1 && (isString(enum.backing[0]) || isSymbol(enum.backing[0])):
+			v.value = []constant.Value{constant.MakeString(v.name)}
+		case len(enum.backing) > 0:
+			v.value = make([]constant.Value, len(enum.backing))
+		}
+		if len(v.value) == 1 && v.value[0] != nil && v.value[0].Kind() == constant.Int {
+			next = constant.BinaryOp(v.value[0], token.ADD, constant.MakeInt64(1))
+		}
+		enum.variants = append(enum.variants, v)
+	}
+	c.duplicateValues(e, enum)
+	return enum
+}
+
+// duplicateValues reports the variants of a backed enum whose values are
+// those of an earlier variant. The parser reports values spelled as the
+// same literals; the others are only known to be equal once folded.
+func (c *Checker) duplicateValues(e *ast.EnumType, enum *Enum) {
+	first := make(map[string]*Variant)
+	for i, v := range enum.variants {
+		if len(v.value) == 0 || slices.Contains(v.value, nil) {
+			continue
+		}
+		keys := make([]string, len(v.value))
+		vals := make([]string, len(v.value))
+		for j, val := range v.value {
+			keys[j], vals[j] = constKey(val), val.String()
+			switch {
+			case val.Kind() == constant.Int || val.Kind() == constant.Float:
+				// 1 and 1.0 are the same float
+				keys[j] = "number:" + constant.ToFloat(val).ExactString()
+			case isSymbol(enum.backing[j]):
+				vals[j] = ":" + constant.StringVal(val)
+			}
+		}
+		key := strings.Join(keys, ", ")
+		if prev := first[key]; prev != nil {
+			val := strings.Join(vals, ", ")
+			if len(vals) > 1 {
+				val = "(" + val + ")"
+			}
+			c.errorf(e.Variants[i].Name.Pos(), "duplicate value %s for enum variants %s and %s", val, prev.name, v.name)
+			continue
+		}
+		first[key] = v
+	}
+}
+
+// enumValue checks the value e of a variant of the backed enum and returns
+// its constants.
+func (c *Checker) enumValue(enum *Enum, e ast.Expr) []constant.Value {
+	switch len(enum.backing) {
+	case 0:
+		return nil // reported by the parser
+	case 1:
+		return []constant.Value{c.enumConst(e, enum.backing[0])}
+	}
+
+	// the parser has checked that the values are literals
+	vals := make([]constant.Value, len(enum.backing))
+	if lit, ok := unparen(e).(*ast.TupleLit); ok && len(lit.Elts) == len(vals) {
+		for i, elt := range lit.Elts {
+			vals[i] = c.enumConst(elt, enum.backing[i])
+		}
+		c.record(&operand{mode: value, expr: e, typ: NewTuple(enum.backing...)})
+		return vals
+	}
+	want := NewTuple(enum.backing...)
+	var x operand
+	c.exprWithHint(&x, e, want)
+	c.assignment(&x, want, "enum value")
+	return vals
+}
+
+// enumConst checks the value e of type T of a variant and returns its
+// constant, or nil if it is not constant.
+func (c *Checker) enumConst(e ast.Expr, T Type) constant.Value {
+	var x operand
+	c.exprWithHint(&x, e, T)
+	if !c.assignment(&x, T, "enum value") || x.mode != constant_ {
+		return nil
+	}
+	return x.val
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"github.com/gusset-lang/gusset/pkg/lexer"
+)
+
+// Universe is the scope of the predeclared types and functions.
+var Universe *Scope
+
+// anyType is the predeclared any type, the empty interface.
+var anyType = &Interface{complete: true, any: true}
+
+type builtinID int
+
+const (
+	_Append builtinID = iota
+	_Delete
+	_JSONDecode
+	_JSONEncode
+	_Len
+	_Panic
+	_Print
+)
+
+var builtins = [...]struct {
+	name     string
+	nargs    int
+	variadic bool
+}{
+	_Append:     {"append", 1, true},
+	_Delete:     {"delete", 2, false},
+	_JSONDecode: {"json_decode", 2, false},
+	_JSONEncode: {"json_encode", 1, false},
+	_Len:        {"len", 1, false},
+	_Panic:      {"panic", 1, false},
+	_Print:      {"print", 0, true},
+}
+
+func init() {
+	Universe = NewScope(nil, "universe")
+
+	for _, t := range Typ {
+		if t.kind != Invalid && !isUntyped(t) {
+			Universe.Insert(NewTypeName(lexer.Position{}, t.name, t))
+		}
+	}
+	Universe.Insert(NewTypeName(lexer.Position{}, "any", anyType))
+
+	// comparable is the constraint satisfied by types whose values can be
+	// compared with == and !=
+	obj := NewTypeName(lexer.Position{}, "comparable", nil)
+	NewNamed(obj, &Interface{complete: true, comparable: true}, nil)
+	Universe.Insert(obj)
+
+	for id, b := range builtins {
+		obj := &Builtin{object{name: b.name, typ: Typ[Invalid]}, builtinID(id)}
+		Universe.Insert(obj)
+	}
+}
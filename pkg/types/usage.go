@@ -0,0 +1,226 @@
+package types
+
+import (
+	"maps"
+
+	"github.com/gusset-lang/gusset/pkg/ast"
+)
+
+// The values a function computes must be used: every local variable must be
+// read, and the results of calls of functions with the must_use attribute
+// may not be discarded. A variable declared without a value starts with the
+// zero value of its type; if the type has none, as for interfaces and
+// functions, the variable must be assigned before it is read on every path
+// leading to the read, and a package-level variable must have a value. No
+// statement may follow one that leaves its block. Each of these errors has
+// a code. Unused imports are not checked, since
+// packages cannot import others yet; UnusedImport only reserves their code.
+
+// varSet is a set of variables.
+type varSet map[*Var]bool
+
+// localVar is a variable declared in a function or an expression, with the
+// file declaring it.
+type localVar struct {
+	v        *Var
+	filename string
+}
+
+// local records the variable v declared in a function or an expression,
+// which must be used.
+func (c *Checker) local(v *Var) {
+	if v.name != "_" {
+		c.locals = append(c.locals, localVar{v, c.filename})
+	}
+}
+
+// unusedVars reports the local variables that are never used. Variables
+// whose declarations have errors are not reported.
+func (c *Checker) unusedVars() {
+	for _, l := range c.locals {
+		if !l.v.used && l.v.typ != nil && isValid(l.v.typ) {
+			c.filename = l.filename
+			c.reportf(l.v.pos, UnusedVar, "declared and not used: %s", l.v.name)
+		}
+	}
+}
+
+// target calls check to check the target e of an assignment, which does not
+// use the variable e denotes, if any. Unless read is set, as for x += 1,
+// it does not read it either.
+func (c *Checker) target(e ast.Expr, read bool, check func()) {
+	v := c.localVar(e)
+	if v == nil {
+		check()
+		return
+	}
+	used, unassigned := v.used, c.fn.unassigned[v]
+	if !read {
+		delete(c.fn.unassigned, v)
+	}
+	check()
+	v.used = used
+	if unassigned && !read {
+		c.fn.unassigned[v] = true
+	}
+}
+
+// unassigned records the local variables of a declaration without values
+// whose types have no zero value, which must be assigned before they are
+// read. Package-level variables of such types must have values.
+func (c *Checker) unassigned(vars []*Var) {
+	if c.fn == nil {
+		return
+	}
+	for _, v := range vars {
+		if v.name != "_" && !hasZero(v.typ) {
+			c.fn.unassigned[v] = true
+		}
+	}
+}
+
+// hasZero reports whether type t has a zero value: false, 0, "", nil for
+// optionals, empty slices and maps, and the values of arrays, tuples,
+// structs and records whose elements are zero. Symbols, interfaces,
+// functions and enums have none, nor do type parameters whose type sets
+// include such types or types whose zero values differ, as those of int
+// and int64 do.
+func hasZero(t Type) bool {
+	if tp, ok := t.(*TypeParam); ok {
+		return allTerms(tp, hasZero) && sameZero(tp)
+	}
+	switch u := t.Underlying().(type) {
+	case *Basic:
+		return u.kind != Symbol
+	case *Optional, *Slice, *Map:
+		return true
+	case *Array:
+		return hasZero(u.elem)
+	case *Tuple:
+		for _, e := range u.elems {
+			if !hasZero(e) {
+				return false
+			}
+		}
+		return true
+	case *Struct:
+		return allZero(u.fields)
+	case *Record:
+		return allZero(u.fields)
+	}
+	return false
+}
+
+func allZero(fields []*Var) bool {
+	for _, f := range fields {
+		if !hasZero(f.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// readVar reports a read of the variable v at the identifier e before it is
+// definitely assigned. It is reported once.
+func (c *Checker) readVar(v *Var, e *ast.Ident) {
+	v.used = true
+	if c.fn != nil && c.fn.unassigned[v] {
+		c.reportf(e.Pos(), UnassignedVar, "%s used before assignment", e.Name)
+		delete(c.fn.unassigned, v)
+	}
+}
+
+// branches tracks definite assignment through the alternative branches of
+// a statement, each of which starts with the variables unassigned before
+// the statement. After it, the variables unassigned at the end of any
+// branch that reaches its end are unassigned.
+type branches struct {
+	fn    *funcContext // nil outside of functions, where nothing is tracked
+	start varSet
+	ends  []varSet
+}
+
+func (c *Checker) branches() *branches {
+	b := &branches{fn: c.fn}
+	if c.fn != nil {
+		b.start = c.fn.unassigned
+	}
+	return b
+}
+
+// begin starts a branch.
+func (b *branches) begin() {
+	if b.fn != nil {
+		b.fn.unassigned = maps.Clone(b.start)
+	}
+}
+
+// end ends the branch begun last. reaches reports whether the end of the
+// statement may be reached from it.
+func (b *branches) end(reaches bool) {
+	if b.fn != nil && reaches {
+		b.ends = append(b.ends, b.fn.unassigned)
+	}
+}
+
+// skip records a way through the statement that runs no branch, such as
+// the missing else of an if statement or a loop that does not iterate.
+func (b *branches) skip() {
+	if b.fn != nil {
+		b.ends = append(b.ends, b.start)
+	}
+}
+
+// join completes the statement.
+func (b *branches) join() {
+	if b.fn == nil {
+		return
+	}
+	after := make(varSet)
+	for _, end := range b.ends {
+		for v := range end {
+			after[v] = true
+		}
+	}
+	b.fn.unassigned = after
+}
+
+// unreachable reports the first statement of list that follows one leaving
+// the block.
+func (c *Checker) unreachable(list []ast.Stmt) {
+	var prev ast.Stmt
+	for _, s := range list {
+		if _, empty := s.(*ast.EmptyStmt); empty {
+			continue
+		}
+		if prev != nil && leaves(prev) {
+			c.reportf(s.Pos(), UnreachableCode, "unreachable code")
+			return
+		}
+		prev = s
+	}
+}
+
+// funcAttributes checks the attributes of the declaration of fn, a method
+// of the named type recv if it is not nil.
+func (c *Checker) funcAttributes(fn *Func, decl *ast.FuncDecl, recv *Named) {
+	for _, attr := range decl.Attrs {
+		switch attr.Name {
+		case "must_use":
+			if !c.attrArgs(attr, 0, 0) {
+				continue
+			}
+			if decl.Type.Results.NumFields() == 0 {
+				c.errorf(attr.Pos(), "must_use function %s has no results", fn.name)
+				continue
+			}
+			fn.mustUse = true
+		case "extern":
+		default:
+			if !externAttrs[attr.Name] {
+				c.errorf(attr.Pos(), "unknown attribute %s", attr.Name)
+			}
+		}
+	}
+	c.externAttributes(fn, decl, recv)
+}